@@ -33,6 +33,7 @@ func main() {
 	logsMode := flag.Bool("logs", false, "Enable real-time log viewer mode")
 	logDir := flag.String("log-dir", "", "Directory to store log files")
 	enablePro := flag.Bool("enable-pro", false, "Enable built-in Pro routes and services")
+	metricsAddr := flag.String("metrics-addr", "", "Extra listen address exposing only /metrics for Prometheus (e.g. 0.0.0.0:9464)")
 	flag.Parse()
 
 	proEnabled := *enablePro
@@ -41,6 +42,9 @@ func main() {
 			proEnabled = parsed
 		}
 	}
+	if raw := strings.TrimSpace(os.Getenv("MEDIA_ASSISTANT_METRICS_ADDR")); raw != "" {
+		*metricsAddr = raw
+	}
 
 	// 初始化日志
 	if *logDir == "" {
@@ -296,6 +300,9 @@ func main() {
 		ListAssetHistory: func(ctx context.Context, assetID string, limit int) (any, error) {
 			return system.AssetService.ListAssetHistory(ctx, assetID, limit)
 		},
+		FindSimilarAssets: func(ctx context.Context, assetID string, maxDistance int, limit int) (any, error) {
+			return system.AssetService.FindSimilarAssets(ctx, assetID, maxDistance, limit)
+		},
 		GetSearchHistory: func(ctx context.Context, limit int) (any, error) {
 			return system.AssetService.GetSearchHistory(ctx, limit)
 		},
@@ -476,14 +483,28 @@ func main() {
 			return system.TaskService.EnqueueTask(ctx, assetID, taskType, priority, force)
 		},
 		GenerateThumbnail: func(ctx context.Context, assetID string, force bool) (any, error) {
+			if force {
+				// 强制重新生成：先删除当前内容的全部规格，否则任务会直接复用
+				if asset, err := system.AssetRepo.GetByID(ctx, assetID); err == nil && asset != nil {
+					if err := system.ThumbnailService.Invalidate(ctx, asset); err != nil {
+						return nil, err
+					}
+				}
+			}
 			return system.TaskService.EnqueueThumbnailTask(ctx, assetID, force)
 		},
 		EventsWS: func(w http.ResponseWriter, r *http.Request) {
 			system.EventHub.ServeWS(w, r)
 		},
-		TagService:            system.TagService,
-		WorkflowService:       system.WorkflowService,
-		PublishMetricsService: system.PublishMetricsService,
+		TagService:             system.TagService,
+		WorkflowService:        system.WorkflowService,
+		PublishMetricsService:  system.PublishMetricsService,
+		SmartCollectionService: system.SmartCollectionService,
+		TaskService:            system.TaskService,
+		ThumbnailService:       system.ThumbnailService,
+		ScanIgnore:             system.ScanIgnore,
+		ScanService:            system.ScanService,
+		VolumeService:          system.VolumeService,
 	}
 
 	srv, err := httpapi.Start(ctx, 32000, 5, deps)
@@ -491,6 +512,14 @@ func main() {
 		log.Fatalf("http server start failed: %v", err)
 	}
 	log.Printf("core server listening on %s", srv.BaseURL())
+	if *metricsAddr != "" {
+		// 核心 API 只监听本机；远程抓取通过独立的 /metrics 监听
+		if err := srv.StartMetricsListener(*metricsAddr); err != nil {
+			logger.Error("metrics listener start failed", zap.String("addr", *metricsAddr), zap.Error(err))
+		} else {
+			log.Printf("prometheus metrics listening on %s/metrics", *metricsAddr)
+		}
+	}
 
 	// Write server port to data directory for discovery
 	if system.DataDir != "" {
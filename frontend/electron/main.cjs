@@ -1,4 +1,4 @@
-const { app, BrowserWindow, ipcMain, screen, Tray, Menu, nativeImage, protocol } = require('electron')
+const { app, BrowserWindow, ipcMain, screen, Tray, Menu, nativeImage, protocol, net } = require('electron')
 const { spawn } = require('child_process')
 const path = require('path')
 const fs = require('fs')
@@ -866,19 +866,20 @@ if (!singleInstance) {
     process.env.MA_CORE_BASE_URL = coreBaseUrl
 
     // Register custom protocol for assets
-    protocol.registerFileProtocol('assets', (request, callback) => {
+    // assets://thumbnails/{assetId}[/{size}] -> core /api/thumbnails/{assetId}?size={size}
+    // Thumbnails are content-addressed by fingerprint in the core cache, so resolve them through the API.
+    protocol.handle('assets', (request) => {
       const url = request.url.replace('assets://', '')
       const parts = url.split('/')
-      
+
       if (parts[0] === 'thumbnails' && parts[1]) {
-        const assetId = parts[1]
-        // Thumbnails are stored in {projectRoot}/data/cache/thumbnails/{assetId}.jpg
-        const projectRoot = path.join(__dirname, '..', '..')
-        const thumbnailPath = path.join(projectRoot, 'data', 'cache', 'thumbnails', `${assetId}.jpg`)
-        callback({ path: thumbnailPath })
-      } else {
-        callback({ error: -6 }) // FILE_NOT_FOUND
+        const assetId = encodeURIComponent(parts[1])
+        const size = encodeURIComponent(parts[2] || 'grid')
+        return net.fetch(`${coreBaseUrl}/api/thumbnails/${assetId}?size=${size}`, {
+          headers: request.headers,
+        })
       }
+      return new Response(null, { status: 404 })
     })
 
     const applicationMenu = buildApplicationMenu()
@@ -385,6 +385,7 @@ export interface ListAssetsParams {
   sortOrder?: 'asc' | 'desc'
   limit?: number
   cursor?: string
+  count?: 'exact' | 'estimate' | 'none'
 }
 
 export interface AssetListItem {
@@ -409,7 +410,8 @@ export interface ListAssetsResult {
   items: AssetListItem[]
   nextCursor?: string
   hasMore: boolean
-  total: number
+  total: number | null
+  totalEstimated?: boolean
 }
 
 export interface SearchHistoryItem {
@@ -436,6 +438,7 @@ export const listAssets = async (params: ListAssetsParams): Promise<ListAssetsRe
   if (params.sortOrder) queryParams.set('sortOrder', params.sortOrder)
   if (params.limit) queryParams.set('limit', params.limit.toString())
   if (params.cursor) queryParams.set('cursor', params.cursor)
+  if (params.count) queryParams.set('count', params.count)
   if (params.sizeMin) queryParams.set('sizeMin', params.sizeMin.toString())
   if (params.sizeMax) queryParams.set('sizeMax', params.sizeMax.toString())
   if (params.ratingMin !== undefined) queryParams.set('ratingMin', params.ratingMin.toString())
@@ -7,6 +7,7 @@ import (
 	"media-assistant-os/internal/db"
 	"media-assistant-os/internal/infra"
 	"media-assistant-os/internal/processor"
+	parsersaudio "media-assistant-os/internal/processor/parsers/audio"
 	parsersfallback "media-assistant-os/internal/processor/parsers/fallback"
 	parsersimage "media-assistant-os/internal/processor/parsers/image"
 	parserspsd "media-assistant-os/internal/processor/parsers/psd"
@@ -36,6 +37,7 @@ type System struct {
 	LineageCandidateRepo     *repos.LineageCandidateRepo
 	ActivityRepo             *repos.ActivityRepo
 	MediaTaskRepo            *repos.MediaTaskRepo
+	TaskWorkerRepo           *repos.TaskWorkerRepo
 	EventLogRepo             *repos.EventLogRepo
 	ArtifactRepo             *repos.ProjectArtifactRepo
 	TagRepo                  *repos.TagRepo
@@ -46,24 +48,34 @@ type System struct {
 	ProjectNoteRepo          *repos.ProjectNoteRepo
 	PublishRepo              *repos.PublishRepo
 	MetricsRepo              *repos.MetricsRepo
+	AssetSearchRepo          *repos.AssetSearchRepo
+	SmartCollectionRepo      *repos.SmartCollectionRepo
+	ThumbnailRepo            *repos.ThumbnailRepo
+	ScanJobRepo              *repos.ScanJobRepo
+	ScanDirStateRepo         *repos.ScanDirStateRepo
 
 	// Services
-	AssetService          *services.AssetService
-	ScanService           *services.ScanService
-	PluginService         *services.PluginService
-	CapabilityService     *services.CapabilityService
-	ProjectService        *services.ProjectService
-	ArtifactService       *services.ArtifactService
-	EventHub              *services.EventHub
-	MediaQueue            *services.MediaQueue
-	ActivityService       *services.ActivityService
-	TaskService           *services.TaskService
-	SettingsService       *services.SettingsService
-	WatcherService        *services.WatcherService
-	LicenseService        *services.LicenseService
-	TagService            *services.TagService
-	WorkflowService       *services.WorkflowService
-	PublishMetricsService *services.PublishMetricsService
+	AssetService           *services.AssetService
+	ScanService            *services.ScanService
+	ScanIgnore             *services.ScanIgnore
+	VolumeService          *services.VolumeService
+	PluginService          *services.PluginService
+	CapabilityService      *services.CapabilityService
+	ProjectService         *services.ProjectService
+	ArtifactService        *services.ArtifactService
+	EventHub               *services.EventHub
+	MediaQueue             *services.MediaQueue
+	ActivityService        *services.ActivityService
+	TaskService            *services.TaskService
+	SettingsService        *services.SettingsService
+	WatcherService         *services.WatcherService
+	LicenseService         *services.LicenseService
+	TagService             *services.TagService
+	WorkflowService        *services.WorkflowService
+	PublishMetricsService  *services.PublishMetricsService
+	AssetSearchIndexer     *services.AssetSearchIndexer
+	SmartCollectionService *services.SmartCollectionService
+	ThumbnailService       *services.ThumbnailService
 }
 
 func NewSystem() *System {
@@ -112,6 +124,7 @@ func (s *System) Startup(ctx context.Context) error {
 	s.LineageCandidateRepo = repos.NewLineageCandidateRepo(d.ORM())
 	s.ActivityRepo = repos.NewActivityRepo(d)
 	s.MediaTaskRepo = repos.NewMediaTaskRepo(d.ORM())
+	s.TaskWorkerRepo = repos.NewTaskWorkerRepo(d.ORM())
 	s.EventLogRepo = repos.NewEventLogRepo(d.ORM())
 	s.ArtifactRepo = repos.NewProjectArtifactRepo(d.ORM())
 	s.TagRepo = repos.NewTagRepo(d.ORM())
@@ -122,6 +135,11 @@ func (s *System) Startup(ctx context.Context) error {
 	s.ProjectNoteRepo = repos.NewProjectNoteRepo(d.ORM())
 	s.PublishRepo = repos.NewPublishRepo(d.ORM())
 	s.MetricsRepo = repos.NewMetricsRepo(d.ORM())
+	s.AssetSearchRepo = repos.NewAssetSearchRepo(d.ORM())
+	s.SmartCollectionRepo = repos.NewSmartCollectionRepo(d.ORM())
+	s.ThumbnailRepo = repos.NewThumbnailRepo(d.ORM())
+	s.ScanJobRepo = repos.NewScanJobRepo(d.ORM())
+	s.ScanDirStateRepo = repos.NewScanDirStateRepo(d.ORM())
 
 	// Init Processors
 	procMgr := processor.GetManager()
@@ -129,14 +147,36 @@ func (s *System) Startup(ctx context.Context) error {
 	procMgr.Register(parsersvideo.New(), 20)
 	procMgr.Register(parserspsd.New(), 15)
 	procMgr.Register(parsersraw.New(), 15)
+	procMgr.Register(parsersaudio.New(), 20)
 	procMgr.Register(parsersfallback.New(), 0) // Low priority fallback
+	procMgr.RegisterThumbnailSource(parsersimage.New(), 20)
+	procMgr.RegisterThumbnailSource(parsersvideo.New(), 20)
+	procMgr.RegisterThumbnailSource(parserspsd.New(), 15)
+	procMgr.RegisterThumbnailSource(parsersraw.New(), 15)
 
 	// Init Services
 	s.EventHub = services.NewEventHub(s.EventLogRepo)
 	s.ActivityService = services.NewActivityService(s.ActivityRepo)
-	s.TaskService = services.NewTaskService(s.MediaTaskRepo, s.AssetRepo, s.EventHub)
+	s.TaskService = services.NewTaskService(s.MediaTaskRepo, s.AssetRepo, s.TaskWorkerRepo, s.EventHub)
 	s.AssetService = services.NewAssetService(s.AssetRepo, s.AssetHistoryEventRepo, s.SearchHistoryRepo, s.ProjectAssetRepo, s.ProjectRepo, s.AssetLineageRepo, s.LineageCandidateRepo, s.ActivityService, s.EventHub, s.TaskService)
 
+	s.ScanIgnore = services.NewScanIgnore(s.SettingsService, s.LibrarySourceRepo, s.ProjectSourceRepo)
+	s.AssetService.SetScanIgnore(s.ScanIgnore)
+
+	// 卷状态需在启动对账之前就绪，避免把未挂载卷上的资产标记为 MISSING
+	s.VolumeService = services.NewVolumeService(s.LibrarySourceRepo, s.AssetService, s.EventHub)
+	s.AssetService.SetVolumeService(s.VolumeService)
+	s.VolumeService.OnRelocate(func(oldRoot, newRoot string) {
+		_ = s.ScanIgnore.Reload(context.Background())
+	})
+	s.VolumeService.Start(ctx)
+
+	s.AssetSearchIndexer = services.NewAssetSearchIndexer(s.AssetSearchRepo)
+	s.AssetService.SetSearchIndexer(s.AssetSearchIndexer)
+	s.SmartCollectionService = services.NewSmartCollectionService(s.SmartCollectionRepo, s.AssetService, s.EventHub)
+	s.AssetSearchIndexer.Start()
+	s.SmartCollectionService.Start()
+
 	// Init License Service
 	s.LicenseService = services.NewLicenseService()
 
@@ -144,22 +184,42 @@ func (s *System) Startup(ctx context.Context) error {
 	if err := s.AssetService.InitBloomFilter(ctx); err != nil {
 		return fmt.Errorf("failed to init bloom filter: %w", err)
 	}
+	if err := s.AssetService.InitPerceptualIndex(ctx); err != nil {
+		return fmt.Errorf("failed to init perceptual hash index: %w", err)
+	}
 
 	// Reset any stuck tasks from previous run
 	if _, err := s.TaskService.ResetAllProcessingTasks(ctx); err != nil {
 		return fmt.Errorf("failed to reset processing tasks: %w", err)
 	}
 
+	s.ThumbnailService = services.NewThumbnailService(s.ThumbnailRepo, s.AssetRepo, s.DataDir)
+	s.ThumbnailService.Start()
 	s.MediaQueue = services.NewMediaQueue(s.AssetService, s.TaskService, s.EventHub, 16)
+	s.MediaQueue.SetThumbnailService(s.ThumbnailService)
 	s.MediaQueue.Start()
 	s.ScanService = services.NewScanService(s.AssetService, s.ProjectRepo, s.ProjectSourceRepo, s.EventHub)
+	s.MediaQueue.SetHeavyTaskGate(s.ScanService.HeavyTasksAllowed)
+	s.ScanService.SetCheckpointRepos(s.ScanJobRepo, s.ScanDirStateRepo)
+	s.ScanService.SetLibrarySourceRepo(s.LibrarySourceRepo)
+	s.ScanIgnore.OnChange(func(root string) {
+		_ = s.ScanService.InvalidateCheckpoints(context.Background(), root)
+	})
+	if err := s.ScanService.ResumeScanJobs(ctx); err != nil {
+		return fmt.Errorf("failed to resume scan jobs: %w", err)
+	}
 	s.ScanService.StartStartupScan(ctx) // 启动自动对账扫描
 
 	// 初始化实时文件监控
 	watcher, err := services.NewWatcherService(s.AssetService, s.ProjectRepo, s.ProjectSourceRepo)
 	if err == nil {
 		s.WatcherService = watcher
+		s.WatcherService.SetScanService(s.ScanService)
 		s.WatcherService.Start(ctx)
+		// 卷换挂载点后把旧根下的监控迁到新根；此前发生的迁移已写入库，Start 读到的就是新路径
+		s.VolumeService.OnRelocate(func(oldRoot, newRoot string) {
+			watcher.Relocate(context.Background(), oldRoot, newRoot)
+		})
 	} else {
 		// Log warning but don't fail startup
 		fmt.Printf("Warning: Failed to start file watcher: %v\n", err)
@@ -176,11 +236,14 @@ func (s *System) Startup(ctx context.Context) error {
 		s.ScanService,
 		s.LicenseService,
 	)
+	s.ProjectService.SetVolumeService(s.VolumeService)
 	if err := s.ProjectService.ResumeSourceBindJobs(ctx); err != nil {
 		return fmt.Errorf("failed to resume source bind jobs: %w", err)
 	}
 	s.ArtifactService = services.NewArtifactService(s.ProjectRepo, s.ArtifactRepo)
 	s.PluginService = services.NewPluginService(s.PluginRuntimeRepo)
+	s.PluginService.SetTaskGraph(s.TaskService.TaskGraph())
+	s.PluginService.SetTaskResultContracts(s.TaskService.ResultContracts())
 	if err := s.PluginService.Restore(ctx); err != nil {
 		return fmt.Errorf("failed to restore plugin runtime: %w", err)
 	}
@@ -193,17 +256,23 @@ func (s *System) Startup(ctx context.Context) error {
 		s.ProjectWorkflowStepRepo,
 		s.ProjectRoadmapRepo,
 		s.ProjectNoteRepo,
+		s.ProjectAssetRepo,
+		s.ArtifactRepo,
+		s.PublishRepo,
 	)
 	s.PublishMetricsService = services.NewPublishMetricsService(
 		s.ProjectRepo,
 		s.ProjectWorkflowRepo,
 		s.ProjectRoadmapRepo,
+		s.ArtifactRepo,
 		s.PublishRepo,
-		s.MetricsRepo,
+		s.EventHub,
 	)
+	s.PublishMetricsService.SetMetricsRepo(s.MetricsRepo)
 	if err := s.WorkflowService.EnsureSystemTemplates(ctx); err != nil {
 		return fmt.Errorf("failed to ensure workflow templates: %w", err)
 	}
+	s.PublishMetricsService.Start()
 
 	return nil
 }
@@ -213,12 +282,27 @@ func (s *System) Shutdown() {
 	if s.MediaQueue != nil {
 		s.MediaQueue.Stop()
 	}
+	if s.ThumbnailService != nil {
+		s.ThumbnailService.Stop()
+	}
 	if s.ScanService != nil {
 		s.ScanService.Stop()
 	}
+	if s.VolumeService != nil {
+		s.VolumeService.Stop()
+	}
 	if s.WatcherService != nil {
 		s.WatcherService.Stop()
 	}
+	if s.PublishMetricsService != nil {
+		s.PublishMetricsService.Stop()
+	}
+	if s.SmartCollectionService != nil {
+		s.SmartCollectionService.Stop()
+	}
+	if s.AssetSearchIndexer != nil {
+		s.AssetSearchIndexer.Stop()
+	}
 	if s.DB != nil {
 		s.DB.Close()
 	}
@@ -44,6 +44,25 @@ func Migrate(ctx context.Context, d *DB) error {
 		{Version: 24, Up: migrateV24},
 		{Version: 25, Up: migrateV25},
 		{Version: 26, Up: migrateV26},
+		{Version: 27, Up: migrateV27},
+		{Version: 28, Up: migrateV28},
+		{Version: 29, Up: migrateV29},
+		{Version: 30, Up: migrateV30},
+		{Version: 31, Up: migrateV31},
+		{Version: 32, Up: migrateV32},
+		{Version: 33, Up: migrateV33},
+		{Version: 34, Up: migrateV34},
+		{Version: 35, Up: migrateV35},
+		{Version: 36, Up: migrateV36},
+		{Version: 37, Up: migrateV37},
+		{Version: 38, Up: migrateV38},
+		{Version: 39, Up: migrateV39},
+		{Version: 40, Up: migrateV40},
+		{Version: 41, Up: migrateV41},
+		{Version: 42, Up: migrateV42},
+		{Version: 43, Up: migrateV43},
+		{Version: 44, Up: migrateV44},
+		{Version: 45, Up: migrateV45},
 	}
 
 	applied, err := appliedVersions(ctx, d.sql)
@@ -911,3 +930,456 @@ func migrateV26(ctx context.Context, tx *sql.Tx) error {
 	}
 	return nil
 }
+
+func migrateV27(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE publish_jobs ADD COLUMN attempt_count INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE publish_jobs ADD COLUMN max_attempts INTEGER NOT NULL DEFAULT 3;`,
+		`CREATE INDEX IF NOT EXISTS idx_publish_records_project_platform ON publish_records(project_id, platform);`,
+	}
+	for _, s := range stmts {
+		// Ignore duplicate-column errors for upgrade idempotency on partially migrated DBs.
+		_, _ = tx.ExecContext(ctx, s)
+	}
+	return nil
+}
+
+func migrateV28(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// Drop duplicated metric points before enforcing uniqueness.
+		`DELETE FROM metrics_events
+		WHERE rowid NOT IN (
+			SELECT MIN(rowid) FROM metrics_events
+			GROUP BY project_id, platform, external_post_id, metric_type, occurred_at
+		);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_metrics_events_dedupe ON metrics_events(project_id, platform, external_post_id, metric_type, occurred_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_metrics_events_created ON metrics_events(created_at);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV29(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 64-bit dHash stored as signed INTEGER (bit-cast); backfilled from media_meta at startup.
+		`ALTER TABLE assets ADD COLUMN phash INTEGER;`,
+		`CREATE INDEX IF NOT EXISTS idx_assets_phash ON assets(phash);`,
+	}
+	for _, s := range stmts {
+		// Ignore duplicate-column errors for upgrade idempotency on partially migrated DBs.
+		_, _ = tx.ExecContext(ctx, s)
+	}
+	return nil
+}
+
+func migrateV30(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 全文索引：文本由应用层分词（CJK 单字 + 二元组）后写入，rowid 对应 asset_search_docs.doc_id
+		`CREATE VIRTUAL TABLE IF NOT EXISTS assets_fts USING fts5(
+			name, dirs, tags, projects, notes, meta,
+			tokenize = 'unicode61 remove_diacritics 2',
+			prefix = '2 3'
+		);`,
+		// asset_id → 稳定的整数 doc_id（assets 的隐式 rowid 在 VACUUM 后可能变化）
+		`CREATE TABLE IF NOT EXISTS asset_search_docs (
+			doc_id INTEGER PRIMARY KEY AUTOINCREMENT,
+			asset_id TEXT NOT NULL UNIQUE
+		);`,
+		// 待重建索引的资产队列，由触发器写入、索引器消费
+		`CREATE TABLE IF NOT EXISTS asset_search_dirty (
+			asset_id TEXT PRIMARY KEY,
+			queued_at INTEGER NOT NULL
+		);`,
+		`CREATE TRIGGER IF NOT EXISTS trg_assets_search_ins AFTER INSERT ON assets BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (new.id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_assets_search_upd AFTER UPDATE OF path, media_meta ON assets BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (new.id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_assets_search_del AFTER DELETE ON assets BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (old.id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_asset_tags_search_ins AFTER INSERT ON asset_tags BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (new.asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_asset_tags_search_del AFTER DELETE ON asset_tags BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (old.asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_tags_search_upd AFTER UPDATE OF name ON tags BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at)
+			SELECT asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000 FROM asset_tags WHERE tag_id = new.id;
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_tags_search_del AFTER DELETE ON tags BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at)
+			SELECT asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000 FROM asset_tags WHERE tag_id = old.id;
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_project_assets_search_ins AFTER INSERT ON project_assets BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (new.asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_project_assets_search_del AFTER DELETE ON project_assets BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (old.asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_projects_search_upd AFTER UPDATE OF name ON projects BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at)
+			SELECT asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000 FROM project_assets WHERE project_id = new.id;
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_project_notes_search_ins AFTER INSERT ON project_notes WHEN new.source_asset_id IS NOT NULL BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (new.source_asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_project_notes_search_upd AFTER UPDATE ON project_notes BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at)
+			SELECT id, CAST(strftime('%s','now') AS INTEGER) * 1000 FROM assets WHERE id IN (old.source_asset_id, new.source_asset_id);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_project_notes_search_del AFTER DELETE ON project_notes WHEN old.source_asset_id IS NOT NULL BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (old.source_asset_id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		// 首次建立索引：全部资产入队，由后台索引器回填
+		`INSERT OR IGNORE INTO asset_search_dirty(asset_id, queued_at)
+		SELECT id, CAST(strftime('%s','now') AS INTEGER) * 1000 FROM assets;`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV31(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		`CREATE TABLE IF NOT EXISTS smart_collections (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			icon TEXT,
+			pin_order INTEGER NOT NULL DEFAULT 0,
+			query TEXT NOT NULL DEFAULT '',
+			filters_json TEXT NOT NULL DEFAULT '{}',
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		);`,
+		// 上次评估的命中集合，用于计算“新命中/不再命中”事件
+		`CREATE TABLE IF NOT EXISTS smart_collection_assets (
+			collection_id TEXT NOT NULL,
+			asset_id TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY(collection_id, asset_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_smart_collection_assets_asset ON smart_collection_assets(asset_id);`,
+		// 评分、状态、尺寸等变化也会影响智能集合命中，一并进入索引队列
+		`DROP TRIGGER IF EXISTS trg_assets_search_upd;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_assets_search_upd
+		AFTER UPDATE OF path, media_meta, status, size, mtime, shape, user_rating, suggested_rating ON assets BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (new.id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV32(ctx context.Context, tx *sql.Tx) error {
+	// 资产列表键集分页：(排序键, id) 复合索引，翻页直接定位而非扫描 OFFSET
+	stmts := []string{
+		`CREATE INDEX IF NOT EXISTS idx_assets_list_path ON assets(LOWER(path), id);`,
+		`CREATE INDEX IF NOT EXISTS idx_assets_list_size ON assets(size, id);`,
+		`CREATE INDEX IF NOT EXISTS idx_assets_list_mtime ON assets(mtime, id);`,
+		`CREATE INDEX IF NOT EXISTS idx_assets_list_created ON assets(created_at, id);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV33(ctx context.Context, tx *sql.Tx) error {
+	// 死信查询：按时间倒序、按任务类型筛选
+	stmts := []string{
+		`CREATE INDEX IF NOT EXISTS idx_media_task_dlq_created ON media_task_dlq(created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_media_task_dlq_type_created ON media_task_dlq(task_type, created_at);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV34(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 任务依赖图：父任务完成前子任务不可被领取，父任务失败 / 取消时级联
+		`CREATE TABLE IF NOT EXISTS media_task_deps (
+			task_id TEXT NOT NULL,
+			depends_on_task_id TEXT NOT NULL,
+			PRIMARY KEY(task_id, depends_on_task_id)
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_media_task_deps_parent ON media_task_deps(depends_on_task_id);`,
+		// 已有资产的核心任务补齐依赖：metadata / thumbnail 等待同资产的 fingerprint
+		`INSERT OR IGNORE INTO media_task_deps(task_id, depends_on_task_id)
+		SELECT c.id, p.id FROM media_tasks c
+		JOIN media_tasks p ON p.asset_id = c.asset_id AND p.task_type = 'fingerprint'
+		WHERE c.task_type IN ('metadata', 'thumbnail') AND c.status = 'pending' AND p.status IN ('pending', 'processing');`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV35(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// worker 注册表：声明支持的任务类型、并发上限与心跳
+		`CREATE TABLE IF NOT EXISTS task_workers (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT '',
+			kind TEXT NOT NULL DEFAULT 'external',
+			task_types_json TEXT NOT NULL DEFAULT '[]',
+			max_concurrency INTEGER NOT NULL DEFAULT 0,
+			completed_count INTEGER NOT NULL DEFAULT 0,
+			failed_count INTEGER NOT NULL DEFAULT 0,
+			registered_at INTEGER NOT NULL,
+			last_seen_at INTEGER NOT NULL
+		);`,
+		// 按任务类型（可限定扩展名）的全局并发上限
+		`CREATE TABLE IF NOT EXISTS task_concurrency_limits (
+			id TEXT PRIMARY KEY,
+			task_type TEXT NOT NULL,
+			extensions_json TEXT NOT NULL DEFAULT '[]',
+			max_concurrent INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_media_tasks_worker ON media_tasks(worker_id, status);`,
+		// 默认：RAW 缩略图同一时间只跑一个，避免 dcraw 占满 CPU
+		`INSERT OR IGNORE INTO task_concurrency_limits(id, task_type, extensions_json, max_concurrent, created_at, updated_at)
+		VALUES ('thumbnail_raw', 'thumbnail', '["cr2","cr3","nef","arw","dng","raf","orf","rw2","pef","srw","3fr"]', 1,
+			CAST(strftime('%s','now') AS INTEGER), CAST(strftime('%s','now') AS INTEGER));`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV36(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 处理中任务的协作式取消标记：内部 worker 取消 context，外部 worker 在下次心跳时得知
+		`ALTER TABLE media_tasks ADD COLUMN cancel_requested INTEGER NOT NULL DEFAULT 0;`,
+		// 按资产提升优先级时定位待处理任务
+		`CREATE INDEX IF NOT EXISTS idx_media_tasks_asset_status ON media_tasks(asset_id, status);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV37(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 插件任务结果写入记录（标签 / 项目笔记 / media_meta.extra）
+		`CREATE TABLE IF NOT EXISTS task_result_provenance (
+			id TEXT PRIMARY KEY,
+			task_id TEXT NOT NULL,
+			asset_id TEXT NOT NULL,
+			task_type TEXT NOT NULL,
+			plugin_id TEXT NOT NULL,
+			worker_id TEXT NOT NULL DEFAULT '',
+			source TEXT NOT NULL,
+			target_kind TEXT NOT NULL,
+			target_id TEXT NOT NULL DEFAULT '',
+			value_json TEXT NOT NULL DEFAULT 'null',
+			created_at INTEGER NOT NULL
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_task_result_provenance_asset ON task_result_provenance(asset_id, created_at);`,
+		`CREATE INDEX IF NOT EXISTS idx_task_result_provenance_task ON task_result_provenance(task_id);`,
+		`CREATE INDEX IF NOT EXISTS idx_project_notes_source_asset ON project_notes(source_asset_id, note_type);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV38(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 多规格缩略图，按内容指纹寻址
+		`CREATE TABLE IF NOT EXISTS thumbnail_renditions (
+			content_key TEXT NOT NULL,
+			rendition TEXT NOT NULL,
+			format TEXT NOT NULL,
+			rel_path TEXT NOT NULL,
+			width INTEGER NOT NULL DEFAULT 0,
+			height INTEGER NOT NULL DEFAULT 0,
+			bytes INTEGER NOT NULL DEFAULT 0,
+			etag TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (content_key, rendition)
+		);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV39(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 故事板等规格的附加索引
+		`ALTER TABLE thumbnail_renditions ADD COLUMN meta_json TEXT NOT NULL DEFAULT '';`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV40(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 库来源级扫描忽略规则（gitignore 语法）
+		`ALTER TABLE library_sources ADD COLUMN ignore_rules TEXT NOT NULL DEFAULT '';`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV41(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 可恢复的扫描任务：每个根目录一行，同批次共享 batch_id
+		`CREATE TABLE IF NOT EXISTS scan_jobs (
+			id TEXT PRIMARY KEY,
+			batch_id TEXT NOT NULL,
+			project_id TEXT NOT NULL DEFAULT '',
+			root_path TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			strict INTEGER NOT NULL DEFAULT 0,
+			full_scan INTEGER NOT NULL DEFAULT 0,
+			status TEXT NOT NULL,
+			scanned_dirs INTEGER NOT NULL DEFAULT 0,
+			skipped_dirs INTEGER NOT NULL DEFAULT 0,
+			indexed_files INTEGER NOT NULL DEFAULT 0,
+			current_dir TEXT NOT NULL DEFAULT '',
+			error_message TEXT NOT NULL DEFAULT '',
+			created_at INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL,
+			finished_at INTEGER NOT NULL DEFAULT 0
+		);`,
+		`CREATE INDEX IF NOT EXISTS idx_scan_jobs_status ON scan_jobs(status, created_at);`,
+		// 目录级检查点
+		`CREATE TABLE IF NOT EXISTS scan_dir_states (
+			project_id TEXT NOT NULL DEFAULT '',
+			dir_path TEXT NOT NULL,
+			mod_time INTEGER NOT NULL DEFAULT 0,
+			entry_count INTEGER NOT NULL DEFAULT 0,
+			job_id TEXT NOT NULL DEFAULT '',
+			completed_at INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (project_id, dir_path)
+		);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV42(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 库来源级扫描并发上限，0 表示按设备自适应
+		`ALTER TABLE library_sources ADD COLUMN scan_concurrency INTEGER NOT NULL DEFAULT 0;`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV43(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		// 库来源绑定的卷标识与在线状态，卷离线时不再把资产标记为 MISSING
+		`ALTER TABLE library_sources ADD COLUMN volume_id TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE library_sources ADD COLUMN volume_label TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE library_sources ADD COLUMN volume_path TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE library_sources ADD COLUMN volume_state TEXT NOT NULL DEFAULT '';`,
+		`ALTER TABLE library_sources ADD COLUMN volume_seen_at INTEGER NOT NULL DEFAULT 0;`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrateV44(ctx context.Context, tx *sql.Tx) error {
+	const mark = `INSERT INTO smart_collection_dirty(asset_id, queued_at, version) VALUES (%s, CAST(strftime('%%s','now') AS INTEGER) * 1000, 1)
+			ON CONFLICT(asset_id) DO UPDATE SET queued_at = excluded.queued_at, version = version + 1;`
+	stmts := []string{
+		// 全文索引只跟随 path / media_meta，状态与评分变化不再重建 FTS 文档
+		`DROP TRIGGER IF EXISTS trg_assets_search_upd;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_assets_search_upd AFTER UPDATE OF path, media_meta ON assets BEGIN
+			INSERT OR REPLACE INTO asset_search_dirty(asset_id, queued_at) VALUES (new.id, CAST(strftime('%s','now') AS INTEGER) * 1000);
+		END;`,
+		// 智能集合待重新评估队列；version 每次入队递增，评估完成后按版本确认，期间再次变化的不会被误删
+		`CREATE TABLE IF NOT EXISTS smart_collection_dirty (
+			asset_id TEXT PRIMARY KEY,
+			queued_at INTEGER NOT NULL,
+			version INTEGER NOT NULL DEFAULT 1
+		);`,
+		// 影响全文检索的变化同样影响集合的关键词命中
+		`CREATE TRIGGER IF NOT EXISTS trg_asset_search_dirty_smart AFTER INSERT ON asset_search_dirty BEGIN
+			` + fmt.Sprintf(mark, "new.asset_id") + `
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS trg_assets_smart_upd
+		AFTER UPDATE OF status, size, mtime, shape, user_rating, suggested_rating ON assets BEGIN
+			` + fmt.Sprintf(mark, "new.id") + `
+		END;`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateV45 死信记录保存原任务优先级，原任务被清理后重放仍按原优先级重建
+func migrateV45(ctx context.Context, tx *sql.Tx) error {
+	stmts := []string{
+		`ALTER TABLE media_task_dlq ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;`,
+		`UPDATE media_task_dlq SET priority = COALESCE((SELECT mt.priority FROM media_tasks mt WHERE mt.id = media_task_dlq.task_id), 0);`,
+	}
+	for _, s := range stmts {
+		if _, err := tx.ExecContext(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
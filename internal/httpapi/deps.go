@@ -44,6 +44,7 @@ type Deps struct {
 	GetProjectFiles              func(ctx context.Context, projectID string) (any, error)
 	ListAssets                   func(ctx context.Context, req services.ListAssetsRequest) (any, error)
 	ListAssetHistory             func(ctx context.Context, assetID string, limit int) (any, error)
+	FindSimilarAssets            func(ctx context.Context, assetID string, maxDistance int, limit int) (any, error)
 	GetSearchHistory             func(ctx context.Context, limit int) (any, error)
 	ClearSearchHistory           func(ctx context.Context) error
 	GetAsset                     func(ctx context.Context, id string) (any, error)
@@ -94,4 +95,10 @@ type Deps struct {
 	TagService                   *services.TagService // 标签服务
 	WorkflowService              *services.WorkflowService
 	PublishMetricsService        *services.PublishMetricsService
+	SmartCollectionService       *services.SmartCollectionService
+	TaskService                  *services.TaskService
+	ThumbnailService             *services.ThumbnailService
+	ScanIgnore                   *services.ScanIgnore
+	ScanService                  *services.ScanService
+	VolumeService                *services.VolumeService
 }
@@ -43,6 +43,7 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	// System & Health
 	mux.HandleFunc("/api/health", h.handleHealth)
 	mux.HandleFunc("/api/metrics", h.handleMetrics)
+	mux.HandleFunc("/metrics", h.handlePrometheus)
 	mux.HandleFunc("/api/system/info", h.handleSystemInfo)
 	mux.HandleFunc("/api/capabilities", h.handleGetCapabilities)
 	mux.HandleFunc("/api/extensions/slots", h.handleListExtensionSlots)
@@ -82,6 +83,7 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/assets/get", h.withAuth(h.handleGetAsset))
 	mux.HandleFunc("/api/assets", h.handleListAssets)
 	mux.HandleFunc("/api/assets/history", h.handleListAssetHistory)
+	mux.HandleFunc("/api/assets/similar", h.handleFindSimilarAssets)
 	mux.HandleFunc("/api/files", h.handleListFiles)
 	mux.HandleFunc("/api/assets/delete", h.withIdempotency(h.withAuth(h.handleDeleteAsset)))
 	mux.HandleFunc("/api/assets/batch-delete", h.withIdempotency(h.withAuth(h.handleBatchDeleteAssets)))
@@ -89,6 +91,12 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/open_in_folder", h.handleOpenInFolder)
 	mux.HandleFunc("/api/search/history", h.handleGetSearchHistory)
 	mux.HandleFunc("/api/search/history/clear", h.withIdempotency(h.handleClearSearchHistory))
+	mux.HandleFunc("/api/smart-collections", h.handleListSmartCollections)
+	mux.HandleFunc("/api/smart-collections/get", h.handleGetSmartCollection)
+	mux.HandleFunc("/api/smart-collections/create", h.withIdempotency(h.handleCreateSmartCollection))
+	mux.HandleFunc("/api/smart-collections/update", h.withIdempotency(h.handleUpdateSmartCollection))
+	mux.HandleFunc("/api/smart-collections/delete", h.withIdempotency(h.handleDeleteSmartCollection))
+	mux.HandleFunc("/api/smart-collections/assets", h.handleListSmartCollectionAssets)
 
 	// Lineage
 	mux.HandleFunc("/api/lineage/create", h.withIdempotency(h.handleCreateLineage))
@@ -130,7 +138,9 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 		mux.HandleFunc("/api/workflow/templates/steps/create", h.withIdempotency(h.handleCreateWorkflowTemplateStep))
 		mux.HandleFunc("/api/workflow/project/init", h.withIdempotency(h.handleInitProjectWorkflow))
 		mux.HandleFunc("/api/workflow/project/snapshot", h.handleGetProjectWorkflowSnapshot)
+		mux.HandleFunc("/api/workflow/project/advance", h.withIdempotency(h.handleAdvanceProjectWorkflow))
 		mux.HandleFunc("/api/workflow/project/steps/list", h.handleListProjectWorkflowSteps)
+		mux.HandleFunc("/api/workflow/project/steps/update", h.withIdempotency(h.handleUpdateProjectWorkflowStep))
 		mux.HandleFunc("/api/workflow/project/steps/evaluate-kpi", h.withIdempotency(h.handleEvaluateWorkflowStepKPI))
 		mux.HandleFunc("/api/workflow/roadmap/list", h.handleListRoadmapItems)
 		mux.HandleFunc("/api/workflow/roadmap/create", h.withIdempotency(h.handleCreateRoadmapItem))
@@ -150,6 +160,8 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 		mux.HandleFunc("/api/metrics/events/ingest", h.withIdempotency(h.handleIngestMetricsEvent))
 		mux.HandleFunc("/api/metrics/snapshots/daily/list", h.handleListMetricsDailySnapshots)
 		mux.HandleFunc("/api/metrics/snapshots/daily/upsert", h.withIdempotency(h.handleUpsertMetricsDailySnapshot))
+		mux.HandleFunc("/api/metrics/snapshots/daily/rollup", h.withIdempotency(h.handleRollupMetricsDaily))
+		mux.HandleFunc("/api/metrics/trend", h.handleGetMetricsTrend)
 	}
 
 	// Tasks
@@ -157,12 +169,33 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/tasks/active", h.handleGetActiveTasks)
 	mux.HandleFunc("/api/tasks/enqueue", h.withIdempotency(h.handleEnqueueTask))
 	mux.HandleFunc("/api/tasks/claim", h.withIdempotency(h.handleClaimTask))
+	mux.HandleFunc("/api/tasks/claim-next", h.withIdempotency(h.handleClaimNextTasks))
 	mux.HandleFunc("/api/tasks/heartbeat", h.withIdempotency(h.handleHeartbeatTask))
 	mux.HandleFunc("/api/tasks/report", h.withIdempotency(h.handleReportTaskProgress))
+	mux.HandleFunc("/api/tasks/cancel", h.withIdempotency(h.handleCancelTasks))
+	mux.HandleFunc("/api/tasks/boost", h.withIdempotency(h.handleBoostTasks))
+	mux.HandleFunc("/api/tasks/graph", h.handleGetTaskGraph)
+	mux.HandleFunc("/api/tasks/results/provenance", h.handleListTaskResultProvenance)
+	mux.HandleFunc("/api/tasks/dlq", h.handleListTaskDLQ)
+	mux.HandleFunc("/api/tasks/dlq/get", h.handleGetTaskDLQ)
+	mux.HandleFunc("/api/tasks/dlq/groups", h.handleGroupTaskDLQ)
+	mux.HandleFunc("/api/tasks/dlq/replay", h.withIdempotency(h.handleReplayTaskDLQ))
+	mux.HandleFunc("/api/tasks/dlq/purge", h.withIdempotency(h.handlePurgeTaskDLQ))
+	mux.HandleFunc("/api/tasks/workers", h.handleListTaskWorkers)
+	mux.HandleFunc("/api/tasks/workers/register", h.withIdempotency(h.handleRegisterTaskWorker))
+	mux.HandleFunc("/api/tasks/workers/heartbeat", h.handleTaskWorkerHeartbeat)
+	mux.HandleFunc("/api/tasks/workers/unregister", h.withIdempotency(h.handleUnregisterTaskWorker))
+	mux.HandleFunc("/api/tasks/limits", h.handleListTaskLimits)
+	mux.HandleFunc("/api/tasks/limits/set", h.withIdempotency(h.handleSetTaskLimit))
+	mux.HandleFunc("/api/tasks/limits/delete", h.withIdempotency(h.handleDeleteTaskLimit))
 
 	// Thumbnails
 	mux.HandleFunc("/api/thumbnails/", h.handleGetThumbnail)
 	mux.HandleFunc("/api/thumbnails/generate", h.handleGenerateThumbnail)
+	mux.HandleFunc("/api/thumbnails/gc", h.withIdempotency(h.handleThumbnailGC))
+	mux.HandleFunc("/api/thumbnails/storyboard/", h.handleStoryboard)
+	mux.HandleFunc("/api/thumbnails/storyboard/generate", h.withIdempotency(h.handleGenerateStoryboard))
+	mux.HandleFunc("/api/thumbnails/waveform/", h.handleWaveform)
 
 	// Asset File Serving
 	mux.HandleFunc("/api/assets/file", h.handleServeAssetFile)
@@ -194,6 +227,11 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/library/sources/add", h.withIdempotency(h.handleAddLibrarySource))
 	mux.HandleFunc("/api/library/sources/remove", h.withIdempotency(h.handleRemoveLibrarySource))
 	mux.HandleFunc("/api/library/directories/children", h.handleListLibraryDirectoryChildren)
+	mux.HandleFunc("/api/library/sources/ignore", h.withIdempotency(h.handleSetSourceIgnoreRules))
+	mux.HandleFunc("/api/library/sources/scan-concurrency", h.withIdempotency(h.handleSetSourceScanConcurrency))
+	mux.HandleFunc("/api/library/volumes", h.withIdempotency(h.handleLibraryVolumes))
+	mux.HandleFunc("/api/library/ignore/global", h.withIdempotency(h.handleGlobalIgnoreRules))
+	mux.HandleFunc("/api/library/ignore/preview", h.handlePreviewIgnoreRules)
 
 	// UI Interaction APIs
 	mux.HandleFunc("/api/ui/notification", h.withIdempotency(h.handleUINotification))
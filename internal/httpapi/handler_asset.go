@@ -2,6 +2,7 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -209,8 +210,8 @@ func (h *Handler) handleListAssets(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 	cursor := strings.TrimSpace(q.Get("cursor"))
 	if cursor != "" {
-		if n, err := strconv.Atoi(cursor); err != nil || n < 0 {
-			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid cursor"})
+		if _, err := services.DecodeAssetCursor(cursor); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
 			return
 		}
 	}
@@ -235,11 +236,15 @@ func (h *Handler) handleListAssets(w http.ResponseWriter, r *http.Request) {
 		SortBy:      strings.TrimSpace(q.Get("sortBy")),
 		SortOrder:   strings.TrimSpace(q.Get("sortOrder")),
 		Cursor:      cursor,
+		CountMode:   strings.TrimSpace(q.Get("count")),
 		QuickFilter: strings.TrimSpace(q.Get("quickFilter")),
 		DatePreset:  strings.TrimSpace(q.Get("datePreset")),
 	}
 	if req.SortBy == "" {
 		req.SortBy = "name"
+		if req.Query != "" {
+			req.SortBy = "relevance"
+		}
 	}
 	if req.SortOrder == "" {
 		req.SortOrder = "desc"
@@ -263,12 +268,22 @@ func (h *Handler) handleListAssets(w http.ResponseWriter, r *http.Request) {
 
 	res, err := h.deps.ListAssets(r.Context(), req)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		writeAssetQueryError(w, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
 }
 
+// writeAssetQueryError 查询语法错误附带字符偏移（前端据此高亮），其余按普通错误返回
+func writeAssetQueryError(w http.ResponseWriter, err error) {
+	var qe *services.AssetQueryError
+	if errors.As(err, &qe) {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error(), Data: qe})
+		return
+	}
+	writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+}
+
 func (h *Handler) handleListAssetHistory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -292,6 +307,31 @@ func (h *Handler) handleListAssetHistory(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
 }
 
+// handleFindSimilarAssets 按感知哈希（dHash）查找画面相似的资产
+func (h *Handler) handleFindSimilarAssets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.deps.FindSimilarAssets == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "not implemented"})
+		return
+	}
+	assetID := strings.TrimSpace(firstNonEmpty(r.URL.Query().Get("assetId"), r.URL.Query().Get("id")))
+	if assetID == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "assetId is required"})
+		return
+	}
+	maxDistance := parseIntWithDefault(r.URL.Query().Get("maxDistance"), 10)
+	limit := parseIntWithDefault(r.URL.Query().Get("limit"), 50)
+	res, err := h.deps.FindSimilarAssets(r.Context(), assetID, maxDistance, limit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
 func (h *Handler) handleOpenFile(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -125,3 +125,57 @@ func (h *Handler) handleListLibraryDirectoryChildren(w http.ResponseWriter, r *h
 
 	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: children})
 }
+
+// handleSetSourceScanConcurrency 设置库来源的扫描并发上限（0 为按设备自适应）
+func (h *Handler) handleSetSourceScanConcurrency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		RootPath    string `json:"rootPath"`
+		Concurrency int    `json:"concurrency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if strings.TrimSpace(req.RootPath) == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "rootPath is required"})
+		return
+	}
+	if h.deps.ScanService == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "scan service not ready"})
+		return
+	}
+	src, err := h.deps.ScanService.SetSourceConcurrency(r.Context(), req.RootPath, req.Concurrency)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: src})
+}
+
+// handleLibraryVolumes GET 列出库来源的卷绑定与在线状态；POST 立即重新检测挂载
+func (h *Handler) handleLibraryVolumes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.deps.VolumeService == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "volume service not ready"})
+		return
+	}
+	if r.Method == http.MethodPost {
+		if err := h.deps.VolumeService.Refresh(r.Context()); err != nil {
+			writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+	}
+	items, err := h.deps.VolumeService.ListSources(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: items})
+}
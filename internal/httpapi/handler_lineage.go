@@ -143,8 +143,8 @@ func (h *Handler) handleListLineageCandidates(w http.ResponseWriter, r *http.Req
 			limit = n
 		}
 	}
-	if assetID == "" {
-		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "assetId is required"})
+	if assetID == "" && projectID == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "assetId or projectId is required"})
 		return
 	}
 	res, err := h.deps.ListLineageCandidates(r.Context(), assetID, projectID, status, limit)
@@ -0,0 +1,277 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"media-assistant-os/internal/services"
+)
+
+func (h *Handler) publishServiceReady(w http.ResponseWriter) bool {
+	if h.deps.PublishMetricsService == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "not implemented"})
+		return false
+	}
+	return true
+}
+
+// handleListPublishChannels 获取项目发布渠道
+func (h *Handler) handleListPublishChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	res, err := h.deps.PublishMetricsService.ListChannels(r.Context(), projectID)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleUpsertPublishChannel 创建或更新发布渠道
+func (h *Handler) handleUpsertPublishChannel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.UpsertPublishChannelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	res, err := h.deps.PublishMetricsService.UpsertChannel(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListPublishJobs 获取发布任务
+func (h *Handler) handleListPublishJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	res, err := h.deps.PublishMetricsService.ListJobs(r.Context(), projectID, status, queryLimit(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleCreatePublishJob 创建发布任务（到期后由调度器分发）
+func (h *Handler) handleCreatePublishJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CreatePublishJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	res, err := h.deps.PublishMetricsService.CreateJob(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleUpdatePublishJobStatus 手动变更发布任务状态（重试 / 取消 / 标记完成）
+func (h *Handler) handleUpdatePublishJobStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.UpdatePublishJobStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	res, err := h.deps.PublishMetricsService.UpdateJobStatus(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListPublishRecords 获取发布记录
+func (h *Handler) handleListPublishRecords(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	jobID := strings.TrimSpace(r.URL.Query().Get("job_id"))
+	platform := strings.TrimSpace(r.URL.Query().Get("platform"))
+	res, err := h.deps.PublishMetricsService.ListRecords(r.Context(), projectID, jobID, platform, queryLimit(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleCreatePublishRecord 手动登记发布结果
+func (h *Handler) handleCreatePublishRecord(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CreatePublishRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	res, err := h.deps.PublishMetricsService.CreateRecord(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleIngestMetricsEvent 写入平台指标点（单条或 events 批量），按帖子/指标/时间去重
+func (h *Handler) handleIngestMetricsEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.IngestMetricsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	res, err := h.deps.PublishMetricsService.IngestMetricsEvents(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListMetricsDailySnapshots 获取按帖子按天的指标快照
+func (h *Handler) handleListMetricsDailySnapshots(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	q := r.URL.Query()
+	res, err := h.deps.PublishMetricsService.ListDailySnapshots(r.Context(), services.ListMetricsSnapshotsRequest{
+		ProjectID:      strings.TrimSpace(q.Get("project_id")),
+		Platform:       strings.TrimSpace(q.Get("platform")),
+		ExternalPostID: strings.TrimSpace(q.Get("external_post_id")),
+		From:           strings.TrimSpace(q.Get("from")),
+		To:             strings.TrimSpace(q.Get("to")),
+		Limit:          queryLimit(r),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleUpsertMetricsDailySnapshot 手动写入某帖子某天的指标快照
+func (h *Handler) handleUpsertMetricsDailySnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.UpsertMetricsSnapshotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	res, err := h.deps.PublishMetricsService.UpsertDailySnapshot(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleRollupMetricsDaily 立即重算项目在日期区间内的日快照
+func (h *Handler) handleRollupMetricsDaily(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.RollupMetricsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	res, err := h.deps.PublishMetricsService.RollupDaily(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleGetMetricsTrend 获取指标趋势（按天增量 + 与上一周期对比）
+func (h *Handler) handleGetMetricsTrend(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.publishServiceReady(w) {
+		return
+	}
+	q := r.URL.Query()
+	res, err := h.deps.PublishMetricsService.GetMetricsTrend(r.Context(), services.MetricsTrendRequest{
+		ProjectID:      strings.TrimSpace(q.Get("project_id")),
+		Platform:       strings.TrimSpace(q.Get("platform")),
+		ExternalPostID: strings.TrimSpace(q.Get("external_post_id")),
+		From:           strings.TrimSpace(q.Get("from")),
+		To:             strings.TrimSpace(q.Get("to")),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
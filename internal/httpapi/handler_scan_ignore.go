@@ -0,0 +1,92 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"media-assistant-os/internal/services"
+)
+
+// handleGlobalIgnoreRules GET 返回全局忽略规则，POST 保存
+func (h *Handler) handleGlobalIgnoreRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.deps.ScanIgnore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "scan ignore not ready"})
+		return
+	}
+	if r.Method == http.MethodPost {
+		var req struct {
+			Rules string `json:"rules"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+			return
+		}
+		if err := h.deps.ScanIgnore.SetGlobalRules(r.Context(), req.Rules); err != nil {
+			writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]any{"rules": h.deps.ScanIgnore.GlobalRules()}})
+}
+
+// handleSetSourceIgnoreRules 保存库来源级忽略规则
+func (h *Handler) handleSetSourceIgnoreRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		RootPath string `json:"rootPath"`
+		Rules    string `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if strings.TrimSpace(req.RootPath) == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "rootPath is required"})
+		return
+	}
+	if h.deps.ScanIgnore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "scan ignore not ready"})
+		return
+	}
+	src, err := h.deps.ScanIgnore.SetSourceRules(r.Context(), req.RootPath, req.Rules)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: src})
+}
+
+// handlePreviewIgnoreRules 预览目录下会被跳过的路径，可附带未保存的规则试用
+func (h *Handler) handlePreviewIgnoreRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.IgnorePreviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if strings.TrimSpace(req.Root) == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "root is required"})
+		return
+	}
+	if h.deps.ScanIgnore == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "scan ignore not ready"})
+		return
+	}
+	res, err := h.deps.ScanIgnore.Preview(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
@@ -0,0 +1,151 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"media-assistant-os/internal/services"
+)
+
+func (h *Handler) smartCollectionServiceReady(w http.ResponseWriter) bool {
+	if h.deps.SmartCollectionService == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "not implemented"})
+		return false
+	}
+	return true
+}
+
+// handleListSmartCollections 获取智能集合（含实时计数）
+func (h *Handler) handleListSmartCollections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.smartCollectionServiceReady(w) {
+		return
+	}
+	res, err := h.deps.SmartCollectionService.List(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleGetSmartCollection 获取单个智能集合
+func (h *Handler) handleGetSmartCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.smartCollectionServiceReady(w) {
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "id is required"})
+		return
+	}
+	res, err := h.deps.SmartCollectionService.Get(r.Context(), id)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleCreateSmartCollection 创建智能集合
+func (h *Handler) handleCreateSmartCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CreateSmartCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.smartCollectionServiceReady(w) {
+		return
+	}
+	res, err := h.deps.SmartCollectionService.Create(r.Context(), req)
+	if err != nil {
+		writeAssetQueryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleUpdateSmartCollection 更新智能集合（名称、图标、置顶顺序或筛选条件）
+func (h *Handler) handleUpdateSmartCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.UpdateSmartCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.smartCollectionServiceReady(w) {
+		return
+	}
+	res, err := h.deps.SmartCollectionService.Update(r.Context(), req)
+	if err != nil {
+		writeAssetQueryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleDeleteSmartCollection 删除智能集合
+func (h *Handler) handleDeleteSmartCollection(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.smartCollectionServiceReady(w) {
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("id"))
+	if id == "" && r.Body != nil {
+		var body struct {
+			ID string `json:"id"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		id = strings.TrimSpace(body.ID)
+	}
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "id is required"})
+		return
+	}
+	if err := h.deps.SmartCollectionService.Delete(r.Context(), id); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]any{"deleted": true}})
+}
+
+// handleListSmartCollectionAssets 按智能集合条件分页列出资产
+func (h *Handler) handleListSmartCollectionAssets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.smartCollectionServiceReady(w) {
+		return
+	}
+	q := r.URL.Query()
+	id := strings.TrimSpace(q.Get("id"))
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "id is required"})
+		return
+	}
+	res, err := h.deps.SmartCollectionService.ListAssets(r.Context(), id, strings.TrimSpace(q.Get("cursor")), parseIntWithDefault(q.Get("limit"), 50))
+	if err != nil {
+		writeAssetQueryError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
@@ -0,0 +1,122 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/services"
+)
+
+// storyboardTaskPriority 手动请求的故事板优先于后台批量生成
+const storyboardTaskPriority = 60
+
+// handleStoryboard 视频滚动预览：
+// GET /api/thumbnails/storyboard/{id}           索引 JSON
+// GET /api/thumbnails/storyboard/{id}/sprite    雪碧图
+// GET /api/thumbnails/storyboard/{id}/index.vtt WebVTT 缩略图轨道
+func (h *Handler) handleStoryboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/thumbnails/storyboard/"), "/")
+	id, part, _ := strings.Cut(rest, "/")
+	id = strings.TrimSpace(id)
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "missing id"})
+		return
+	}
+	if h.deps.ThumbnailService == nil || h.deps.GetAsset == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "thumbnail service not ready"})
+		return
+	}
+	assetAny, err := h.deps.GetAsset(r.Context(), id)
+	asset, ok := assetAny.(*models.Asset)
+	if err != nil || !ok || asset == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "asset not found"})
+		return
+	}
+	sb, err := h.deps.ThumbnailService.GetStoryboard(r.Context(), asset)
+	if errors.Is(err, services.ErrThumbnailNotFound) {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "storyboard not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	base := "/api/thumbnails/storyboard/" + id
+	switch part {
+	case "":
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]any{
+			"index":      sb.Index,
+			"width":      sb.Width,
+			"height":     sb.Height,
+			"etag":       sb.ETag,
+			"sprite_url": base + "/sprite",
+			"vtt_url":    base + "/index.vtt",
+		}})
+	case "sprite":
+		h.writeThumbnail(w, r, sb.Path, sb.ContentType, sb.ETag, true)
+	case "index.vtt":
+		tag := `"` + sb.ETag + `-vtt"`
+		w.Header().Set("ETag", tag)
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		if etagMatches(r.Header.Get("If-None-Match"), tag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(sb.WebVTT(base + "/sprite")))
+	default:
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "not found"})
+	}
+}
+
+// handleGenerateStoryboard 手动为视频入队故事板任务（仍受重负载闸门约束）
+func (h *Handler) handleGenerateStoryboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ID    string `json:"id"`
+		Force bool   `json:"force"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	req.ID = strings.TrimSpace(req.ID)
+	if req.ID == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "missing id"})
+		return
+	}
+	if h.deps.TaskService == nil || h.deps.ThumbnailService == nil || h.deps.GetAsset == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "thumbnail service not ready"})
+		return
+	}
+	assetAny, err := h.deps.GetAsset(r.Context(), req.ID)
+	asset, ok := assetAny.(*models.Asset)
+	if err != nil || !ok || asset == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "asset not found"})
+		return
+	}
+	if req.Force {
+		if err := h.deps.ThumbnailService.InvalidateStoryboard(r.Context(), asset); err != nil {
+			writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+	}
+	task, err := h.deps.TaskService.EnqueueTask(r.Context(), req.ID, "storyboard", storyboardTaskPriority, req.Force)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: task})
+}
@@ -27,6 +27,14 @@ func (h *Handler) handleMetrics(w http.ResponseWriter, r *http.Request) {
 		}
 		payload["runtime"] = extra
 	}
+	if h.deps.TaskService != nil {
+		queue, err := h.deps.TaskService.QueueMetrics(r.Context())
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+		payload["queue"] = queue
+	}
 	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: payload})
 }
 
@@ -2,8 +2,12 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
+
+	"media-assistant-os/internal/services"
 )
 
 func (h *Handler) handleListPendingTasks(w http.ResponseWriter, r *http.Request) {
@@ -102,6 +106,57 @@ func (h *Handler) handleClaimTask(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]any{"success": success}})
 }
 
+// maxClaimWait 长轮询最长等待，需小于 http.Server 的 WriteTimeout
+const maxClaimWait = 25 * time.Second
+
+// handleClaimNextTasks 原子领取下一批任务（可长轮询），替代 pending + claim 两步
+func (h *Handler) handleClaimNextTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		WorkerID     string   `json:"worker_id"`
+		TaskTypes    []string `json:"task_types"`
+		LeaseSeconds int      `json:"lease_seconds"`
+		Max          int      `json:"max"`
+		WaitSeconds  int      `json:"wait_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	if strings.TrimSpace(req.WorkerID) == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "worker_id is required"})
+		return
+	}
+	wait := time.Duration(req.WaitSeconds) * time.Second
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxClaimWait {
+		wait = maxClaimWait
+	}
+	tasks, err := h.deps.TaskService.ClaimNextTasks(r.Context(), services.ClaimNextRequest{
+		WorkerID:  req.WorkerID,
+		TaskTypes: req.TaskTypes,
+		Lease:     time.Duration(req.LeaseSeconds) * time.Second,
+		Max:       req.Max,
+		Wait:      wait,
+	})
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]any{"tasks": tasks}})
+}
+
 func (h *Handler) handleReportTaskProgress(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		w.WriteHeader(http.StatusMethodNotAllowed)
@@ -156,9 +211,88 @@ func (h *Handler) handleHeartbeatTask(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	ok, err := h.deps.HeartbeatTask(r.Context(), req.TaskID, req.WorkerID)
+	if errors.Is(err, services.ErrTaskCancelled) {
+		// 任务已被取消：worker 应停止处理并丢弃结果
+		writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]any{"success": false, "cancelled": true}})
+		return
+	}
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
 		return
 	}
 	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: map[string]any{"success": ok}})
 }
+
+// handleCancelTasks 取消任务：待处理的立即取消（级联下游），处理中的由 worker 协作退出
+func (h *Handler) handleCancelTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CancelTasksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.CancelTasks(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleBoostTasks 提升一组资产或目录下资产的待处理任务优先级
+func (h *Handler) handleBoostTasks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.BoostTasksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.BoostTasks(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListTaskResultProvenance 插件任务结果写入来源（按资产或任务）
+func (h *Handler) handleListTaskResultProvenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	q := r.URL.Query()
+	res, err := h.deps.TaskService.ListResultProvenance(r.Context(), q.Get("asset_id"), q.Get("task_id"), parseIntWithDefault(q.Get("limit"), 200))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleGetTaskGraph 任务类型依赖声明（核心 + 插件）
+func (h *Handler) handleGetTaskGraph(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: h.deps.TaskService.TaskGraph().Snapshot()})
+}
@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"media-assistant-os/internal/services"
+)
+
+func (h *Handler) taskServiceReady(w http.ResponseWriter) bool {
+	if h.deps.TaskService == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "not implemented"})
+		return false
+	}
+	return true
+}
+
+func dlqFilterFromQuery(r *http.Request) services.DLQFilterRequest {
+	q := r.URL.Query()
+	return services.DLQFilterRequest{
+		TaskType: strings.TrimSpace(firstNonEmpty(q.Get("task_type"), q.Get("type"))),
+		Error:    strings.TrimSpace(q.Get("error")),
+		Group:    strings.TrimSpace(q.Get("group")),
+		From:     strings.TrimSpace(q.Get("from")),
+		To:       strings.TrimSpace(q.Get("to")),
+	}
+}
+
+// handleListTaskDLQ 列出死信任务（按类型 / 错误关键字 / 归一化分组 / 时间筛选）
+func (h *Handler) handleListTaskDLQ(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	q := r.URL.Query()
+	res, err := h.deps.TaskService.ListDLQ(r.Context(), services.ListDLQRequest{
+		DLQFilterRequest: dlqFilterFromQuery(r),
+		Limit:            parseIntWithDefault(q.Get("limit"), 100),
+		Offset:           parseIntWithDefault(q.Get("offset"), 0),
+	})
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleGetTaskDLQ 查看单条死信
+func (h *Handler) handleGetTaskDLQ(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.GetDLQEntry(r.Context(), r.URL.Query().Get("id"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleGroupTaskDLQ 按归一化错误信息聚合死信
+func (h *Handler) handleGroupTaskDLQ(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.GroupDLQErrors(r.Context(), dlqFilterFromQuery(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleReplayTaskDLQ 批量重放死信（重置重试次数并重新入队）
+func (h *Handler) handleReplayTaskDLQ(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.DLQSelection
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.ReplayDLQ(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handlePurgeTaskDLQ 批量清除死信
+func (h *Handler) handlePurgeTaskDLQ(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.DLQSelection
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.PurgeDLQ(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
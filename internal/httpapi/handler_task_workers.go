@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"media-assistant-os/internal/services"
+)
+
+type taskWorkerIDRequest struct {
+	WorkerID string `json:"worker_id"`
+}
+
+type taskLimitIDRequest struct {
+	ID string `json:"id"`
+}
+
+// handleListTaskWorkers 列出 worker：进行中的租约、最近一小时吞吐、在线状态
+func (h *Handler) handleListTaskWorkers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.ListWorkers(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleRegisterTaskWorker 注册 worker（声明可处理的任务类型与并发上限）
+func (h *Handler) handleRegisterTaskWorker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.RegisterWorkerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.RegisterWorker(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+func (h *Handler) handleTaskWorkerHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req taskWorkerIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	if err := h.deps.TaskService.WorkerHeartbeat(r.Context(), req.WorkerID); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
+
+func (h *Handler) handleUnregisterTaskWorker(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req taskWorkerIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	if err := h.deps.TaskService.UnregisterWorker(r.Context(), req.WorkerID); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
+
+// handleListTaskLimits 列出按任务类型（可选扩展名）的全局并发上限
+func (h *Handler) handleListTaskLimits(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.ListTaskLimits(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+func (h *Handler) handleSetTaskLimit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.SetTaskLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	res, err := h.deps.TaskService.SetTaskLimit(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+func (h *Handler) handleDeleteTaskLimit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req taskLimitIDRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.taskServiceReady(w) {
+		return
+	}
+	if err := h.deps.TaskService.DeleteTaskLimit(r.Context(), req.ID); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true})
+}
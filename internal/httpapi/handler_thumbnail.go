@@ -3,13 +3,17 @@ package httpapi
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"media-assistant-os/internal/infra"
 	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/services"
 )
 
 func (h *Handler) handleGetThumbnail(w http.ResponseWriter, r *http.Request) {
@@ -42,6 +46,26 @@ func (h *Handler) handleGetThumbnail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// ?size= 时直接返回图片字节；不带 size 保持旧行为（JSON data URL，grid 规格）
+	size := strings.TrimSpace(r.URL.Query().Get("size"))
+	raw := size != ""
+
+	if h.deps.ThumbnailService != nil {
+		file, err := h.deps.ThumbnailService.Resolve(r.Context(), asset, size)
+		switch {
+		case err == nil:
+			h.writeThumbnail(w, r, file.Path, file.ContentType, file.ETag, raw)
+			return
+		case errors.Is(err, services.ErrInvalidThumbnailSize):
+			writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+			return
+		case !errors.Is(err, services.ErrThumbnailNotFound):
+			writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+			return
+		}
+	}
+
+	// 尚未按新管线生成的资产：旧版单张 JPEG
 	dataDir, err := infra.ResolveDataDir()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: "failed to resolve data dir"})
@@ -55,17 +79,65 @@ func (h *Handler) handleGetThumbnail(w http.ResponseWriter, r *http.Request) {
 	if thumbPath == "" {
 		thumbPath = filepath.Join(dataDir, "cache", "thumbnails", asset.ID+".jpg")
 	}
+	h.writeThumbnail(w, r, thumbPath, "image/jpeg", "", raw)
+}
 
-	data, err := os.ReadFile(thumbPath)
-	if err != nil {
+// writeThumbnail 输出缩略图；etag 为空时按文件修改时间与大小生成弱校验值
+func (h *Handler) writeThumbnail(w http.ResponseWriter, r *http.Request, path, contentType, etag string, raw bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
 		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "thumbnail not found"})
 		return
 	}
+	tag := `"` + etag + `"`
+	if etag == "" {
+		tag = fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+	}
+	if !raw {
+		// JSON 包装与图片字节的校验值需区分
+		tag = strings.TrimSuffix(tag, `"`) + `-json"`
+	}
+	w.Header().Set("ETag", tag)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if etagMatches(r.Header.Get("If-None-Match"), tag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-	encoded := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "thumbnail not found"})
+		return
+	}
+	if raw {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(data)
+		return
+	}
+	encoded := "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data)
 	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: encoded})
 }
 
+// etagMatches If-None-Match 比较（弱比较，支持逗号分隔与 *）
+func etagMatches(header, tag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	want := strings.TrimPrefix(tag, "W/")
+	for _, part := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(part), "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
 func (h *Handler) handleGenerateThumbnail(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeJSON(w, http.StatusMethodNotAllowed, APIResponse{Success: false, Error: "method not allowed"})
@@ -118,3 +190,21 @@ func extractThumbnailPath(mediaMeta string) string {
 	}
 	return ""
 }
+
+// handleThumbnailGC 立即清理孤儿缩略图（后台也会定期执行）
+func (h *Handler) handleThumbnailGC(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.deps.ThumbnailService == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "thumbnail service not ready"})
+		return
+	}
+	res, err := h.deps.ThumbnailService.CollectGarbage(r.Context())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
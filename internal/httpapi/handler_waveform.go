@@ -0,0 +1,43 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/services"
+)
+
+// handleWaveform GET /api/thumbnails/waveform/{id}：音频峰值 JSON（audiowaveform 格式）
+func (h *Handler) handleWaveform(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimSpace(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/thumbnails/waveform/"), "/"))
+	if id == "" {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "missing id"})
+		return
+	}
+	if h.deps.ThumbnailService == nil || h.deps.GetAsset == nil {
+		writeJSON(w, http.StatusServiceUnavailable, APIResponse{Success: false, Error: "thumbnail service not ready"})
+		return
+	}
+	assetAny, err := h.deps.GetAsset(r.Context(), id)
+	asset, ok := assetAny.(*models.Asset)
+	if err != nil || !ok || asset == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "asset not found"})
+		return
+	}
+	wf, err := h.deps.ThumbnailService.GetWaveform(r.Context(), asset)
+	if errors.Is(err, services.ErrThumbnailNotFound) {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "waveform not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	h.writeThumbnail(w, r, wf.Path, "application/json", wf.ETag, true)
+}
@@ -0,0 +1,361 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"media-assistant-os/internal/services"
+)
+
+func queryLimit(r *http.Request) int {
+	limit := 0
+	if v := strings.TrimSpace(r.URL.Query().Get("limit")); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			limit = n
+		}
+	}
+	return limit
+}
+
+func (h *Handler) workflowServiceReady(w http.ResponseWriter) bool {
+	if h.deps.WorkflowService == nil {
+		writeJSON(w, http.StatusNotFound, APIResponse{Success: false, Error: "not implemented"})
+		return false
+	}
+	return true
+}
+
+// handleListWorkflowTemplates 获取工作流模板列表
+func (h *Handler) handleListWorkflowTemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	domain := strings.TrimSpace(r.URL.Query().Get("domain"))
+	includeInactive := r.URL.Query().Get("include_inactive") == "true"
+	res, err := h.deps.WorkflowService.ListTemplates(r.Context(), domain, includeInactive)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleCreateWorkflowTemplate 创建自定义工作流模板
+func (h *Handler) handleCreateWorkflowTemplate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CreateWorkflowTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.CreateTemplate(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListWorkflowTemplateSteps 获取模板步骤
+func (h *Handler) handleListWorkflowTemplateSteps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	templateID := strings.TrimSpace(r.URL.Query().Get("template_id"))
+	res, err := h.deps.WorkflowService.ListTemplateSteps(r.Context(), templateID)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleCreateWorkflowTemplateStep 为自定义模板追加步骤
+func (h *Handler) handleCreateWorkflowTemplateStep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CreateWorkflowTemplateStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.CreateTemplateStep(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleInitProjectWorkflow 按模板初始化项目工作流
+func (h *Handler) handleInitProjectWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.InitProjectWorkflowRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.InitProjectWorkflow(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleGetProjectWorkflowSnapshot 获取项目工作流快照
+func (h *Handler) handleGetProjectWorkflowSnapshot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	res, err := h.deps.WorkflowService.GetProjectWorkflowSnapshot(r.Context(), projectID)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleAdvanceProjectWorkflow 完成当前步骤并进入下一步
+func (h *Handler) handleAdvanceProjectWorkflow(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ProjectID string `json:"project_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.AdvanceProjectWorkflow(r.Context(), strings.TrimSpace(req.ProjectID))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListProjectWorkflowSteps 获取项目工作流步骤
+func (h *Handler) handleListProjectWorkflowSteps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	res, err := h.deps.WorkflowService.ListProjectWorkflowSteps(r.Context(), projectID)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleUpdateProjectWorkflowStep 更新步骤状态，返回最新快照
+func (h *Handler) handleUpdateProjectWorkflowStep(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.UpdateProjectWorkflowStepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.UpdateProjectWorkflowStep(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleEvaluateWorkflowStepKPI 评估步骤 KPI 规则并写回结果，可选自动完成步骤
+func (h *Handler) handleEvaluateWorkflowStepKPI(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.EvaluateWorkflowStepKPIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.EvaluateWorkflowStepKPI(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListRoadmapItems 获取项目路线图
+func (h *Handler) handleListRoadmapItems(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	status := strings.TrimSpace(r.URL.Query().Get("status"))
+	res, err := h.deps.WorkflowService.ListRoadmapItems(r.Context(), projectID, status, queryLimit(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleCreateRoadmapItem 创建路线图条目
+func (h *Handler) handleCreateRoadmapItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CreateRoadmapItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.CreateRoadmapItem(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleUpdateRoadmapItem 更新路线图条目
+func (h *Handler) handleUpdateRoadmapItem(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.UpdateRoadmapItemRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.UpdateRoadmapItem(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleListProjectNotes 获取项目笔记
+func (h *Handler) handleListProjectNotes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	projectID := strings.TrimSpace(r.URL.Query().Get("project_id"))
+	noteType := strings.TrimSpace(r.URL.Query().Get("note_type"))
+	res, err := h.deps.WorkflowService.ListProjectNotes(r.Context(), projectID, noteType, queryLimit(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleCreateProjectNote 创建项目笔记
+func (h *Handler) handleCreateProjectNote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.CreateProjectNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.CreateProjectNote(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
+
+// handleUpdateProjectNote 更新项目笔记
+func (h *Handler) handleUpdateProjectNote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var req services.UpdateProjectNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: "invalid json"})
+		return
+	}
+	if !h.workflowServiceReady(w) {
+		return
+	}
+	res, err := h.deps.WorkflowService.UpdateProjectNote(r.Context(), req)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, APIResponse{Success: false, Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, APIResponse{Success: true, Data: res})
+}
@@ -0,0 +1,176 @@
+package httpapi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/services"
+)
+
+// promContentType Prometheus 文本暴露格式
+const promContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+type promWriter struct {
+	w *bufio.Writer
+}
+
+func (p *promWriter) header(name, typ, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample 输出一行样本；labels 按 key, value 成对传入，保持传入顺序
+func (p *promWriter) sample(name string, value float64, labels ...string) {
+	p.w.WriteString(name)
+	if len(labels) > 0 {
+		p.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				p.w.WriteByte(',')
+			}
+			fmt.Fprintf(p.w, "%s=\"%s\"", labels[i], promEscape(labels[i+1]))
+		}
+		p.w.WriteByte('}')
+	}
+	p.w.WriteByte(' ')
+	p.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	p.w.WriteByte('\n')
+}
+
+func (p *promWriter) histogram(name string, h services.HistogramSnapshot, labels ...string) {
+	for _, b := range h.Buckets {
+		p.sample(name+"_bucket", float64(b.Count), append(labels, "le", strconv.FormatFloat(b.Le, 'g', -1, 64))...)
+	}
+	p.sample(name+"_bucket", float64(h.Count), append(labels, "le", "+Inf")...)
+	p.sample(name+"_sum", h.Sum, labels...)
+	p.sample(name+"_count", float64(h.Count), labels...)
+}
+
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promEscape(v string) string {
+	return promLabelEscaper.Replace(v)
+}
+
+func writePromHTTP(p *promWriter, m map[string]any) {
+	p.header("orbit_uptime_seconds", "gauge", "Seconds since the core HTTP server started.")
+	p.sample("orbit_uptime_seconds", toFloat(m["uptime_sec"]))
+	p.header("orbit_http_requests_total", "counter", "HTTP requests handled.")
+	p.sample("orbit_http_requests_total", toFloat(m["http_requests_total"]))
+	p.header("orbit_http_responses_4xx_total", "counter", "HTTP responses with 4xx status.")
+	p.sample("orbit_http_responses_4xx_total", toFloat(m["http_4xx_total"]))
+	p.header("orbit_http_responses_5xx_total", "counter", "HTTP responses with 5xx status.")
+	p.sample("orbit_http_responses_5xx_total", toFloat(m["http_5xx_total"]))
+	p.header("orbit_http_inflight", "gauge", "HTTP requests currently in flight.")
+	p.sample("orbit_http_inflight", toFloat(m["http_inflight"]))
+}
+
+func writePromQueue(p *promWriter, q *services.QueueMetrics) {
+	type gauge struct {
+		name, typ, help string
+		value           func(*services.TaskTypeMetrics) float64
+	}
+	series := []gauge{
+		{"orbit_task_queue_pending", "gauge", "Pending tasks by type.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Pending) }},
+		{"orbit_task_queue_processing", "gauge", "Tasks currently processing by type.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Processing) }},
+		{"orbit_task_queue_oldest_pending_age_seconds", "gauge", "Age of the oldest pending task by type.", func(t *services.TaskTypeMetrics) float64 { return t.OldestPendingAgeSec }},
+		{"orbit_task_dlq_size", "gauge", "Dead-lettered tasks by type.", func(t *services.TaskTypeMetrics) float64 { return float64(t.DLQ) }},
+		{"orbit_task_completed_last_hour", "gauge", "Tasks completed in the last hour by type.", func(t *services.TaskTypeMetrics) float64 { return float64(t.CompletedLastHour) }},
+		{"orbit_tasks_enqueued_total", "counter", "Tasks enqueued since start.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Enqueued) }},
+		{"orbit_tasks_claimed_total", "counter", "Tasks claimed by workers since start.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Claimed) }},
+		{"orbit_tasks_completed_total", "counter", "Task attempts completed successfully since start.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Completed) }},
+		{"orbit_tasks_failed_total", "counter", "Task attempts failed without retry since start.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Failed) }},
+		{"orbit_tasks_retried_total", "counter", "Task attempts failed and requeued for retry since start.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Retried) }},
+		{"orbit_tasks_cancelled_total", "counter", "Tasks cancelled since start.", func(t *services.TaskTypeMetrics) float64 { return float64(t.Cancelled) }},
+	}
+	for _, g := range series {
+		p.header(g.name, g.typ, g.help)
+		for i := range q.Types {
+			p.sample(g.name, g.value(&q.Types[i]), "task_type", q.Types[i].TaskType)
+		}
+	}
+
+	p.header("orbit_task_wait_seconds", "histogram", "Time from enqueue to first claim.")
+	for i := range q.Types {
+		p.histogram("orbit_task_wait_seconds", q.Types[i].WaitSeconds, "task_type", q.Types[i].TaskType)
+	}
+	p.header("orbit_task_processing_seconds", "histogram", "Time from claim to report, by outcome.")
+	for i := range q.Types {
+		outcomes := make([]string, 0, len(q.Types[i].ProcessingSeconds))
+		for outcome := range q.Types[i].ProcessingSeconds {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+		for _, outcome := range outcomes {
+			p.histogram("orbit_task_processing_seconds", q.Types[i].ProcessingSeconds[outcome], "task_type", q.Types[i].TaskType, "outcome", outcome)
+		}
+	}
+}
+
+func toFloat(v any) float64 {
+	switch n := v.(type) {
+	case int64:
+		return float64(n)
+	case int:
+		return float64(n)
+	case float64:
+		return n
+	}
+	return 0
+}
+
+// handlePrometheus 以 Prometheus 文本格式暴露 HTTP 与任务队列指标，供外部抓取
+func (h *Handler) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var queue *services.QueueMetrics
+	if h.deps.TaskService != nil {
+		q, err := h.deps.TaskService.QueueMetrics(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		queue = q
+	}
+	w.Header().Set("Content-Type", promContentType)
+	w.WriteHeader(http.StatusOK)
+	writePrometheus(w, h.metrics.snapshot(), queue)
+}
+
+func writePrometheus(out io.Writer, httpMetrics map[string]any, queue *services.QueueMetrics) {
+	p := &promWriter{w: bufio.NewWriter(out)}
+	writePromHTTP(p, httpMetrics)
+	if queue != nil {
+		writePromQueue(p, queue)
+	}
+	_ = p.w.Flush()
+}
+
+// StartMetricsListener 在额外地址上仅暴露 /metrics：核心 API 只监听本机回环，远程 Prometheus 抓取需单独开放
+func (s *Server) StartMetricsListener(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.h.handlePrometheus)
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	s.metricsSrv = srv
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	return nil
+}
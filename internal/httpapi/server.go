@@ -12,6 +12,9 @@ type Server struct {
 	port int
 	srv  *http.Server
 	ln   net.Listener
+	h    *Handler
+	// metricsSrv 可选的独立 /metrics 监听
+	metricsSrv *http.Server
 }
 
 func Start(ctx context.Context, basePort int, tryCount int, deps Deps) (*Server, error) {
@@ -54,6 +57,7 @@ func Start(ctx context.Context, basePort int, tryCount int, deps Deps) (*Server,
 		port: port,
 		srv:  s,
 		ln:   ln,
+		h:    h,
 	}, nil
 }
 
@@ -69,5 +73,8 @@ func (s *Server) Close(ctx context.Context) error {
 	if s == nil {
 		return nil
 	}
+	if s.metricsSrv != nil {
+		_ = s.metricsSrv.Shutdown(ctx)
+	}
 	return s.srv.Shutdown(ctx)
 }
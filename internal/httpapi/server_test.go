@@ -13,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"media-assistant-os/internal/repos"
 	"media-assistant-os/internal/services"
 )
 
@@ -219,6 +220,19 @@ func TestServer_Metrics(t *testing.T) {
 		t.Fatalf("metrics status: %d", resp.StatusCode)
 	}
 	_ = resp.Body.Close()
+
+	resp, err = http.Get(srv.BaseURL() + "/metrics")
+	if err != nil {
+		t.Fatalf("prometheus metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("prometheus content type: %s", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "# TYPE orbit_http_requests_total counter") {
+		t.Fatalf("prometheus body: %s", body)
+	}
 }
 
 func TestServer_ArtifactsCRUD(t *testing.T) {
@@ -459,6 +473,11 @@ func TestServer_ListAssetsQueryParsing(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	cursor, err := services.EncodeAssetCursor(&repos.AssetListCursor{Sort: "path", Desc: true, Key: "/data/assets/a.jpg", ID: "a1"})
+	if err != nil {
+		t.Fatalf("encode cursor: %v", err)
+	}
+
 	var captured services.ListAssetsRequest
 	srv, err := Start(ctx, 0, 1, Deps{
 		ListAssets: func(ctx context.Context, req services.ListAssetsRequest) (any, error) {
@@ -467,7 +486,7 @@ func TestServer_ListAssetsQueryParsing(t *testing.T) {
 				"items":      []any{},
 				"nextCursor": nil,
 				"hasMore":    false,
-				"total":      0,
+				"total":      nil,
 			}, nil
 		},
 	})
@@ -480,7 +499,7 @@ func TestServer_ListAssetsQueryParsing(t *testing.T) {
 		"?projectId=p1&directory=/data/assets&search=cover%20draft" +
 		"&tagIds=t1,t2&types=jpg,png&shapes=landscape,square&sortBy=name&sortOrder=desc" +
 		"&ratingMin=3&ratingMax=5" +
-		"&cursor=100&limit=50&sizeMin=10&sizeMax=999" +
+		"&cursor=" + cursor + "&count=estimate&limit=50&sizeMin=10&sizeMax=999" +
 		"&mtimeFrom=1700000000&mtimeTo=1709999999" +
 		"&widthMin=100&widthMax=4000&heightMin=100&heightMax=3000"
 
@@ -517,8 +536,8 @@ func TestServer_ListAssetsQueryParsing(t *testing.T) {
 	if captured.SortBy != "name" || captured.SortOrder != "desc" {
 		t.Fatalf("sort parse failed: %q %q", captured.SortBy, captured.SortOrder)
 	}
-	if captured.Cursor != "100" || captured.Limit != 50 {
-		t.Fatalf("pagination parse failed: cursor=%q limit=%d", captured.Cursor, captured.Limit)
+	if captured.Cursor != cursor || captured.CountMode != "estimate" || captured.Limit != 50 {
+		t.Fatalf("pagination parse failed: cursor=%q count=%q limit=%d", captured.Cursor, captured.CountMode, captured.Limit)
 	}
 	if captured.SizeMin != 10 || captured.SizeMax != 999 {
 		t.Fatalf("size parse failed: %d %d", captured.SizeMin, captured.SizeMax)
@@ -531,6 +550,45 @@ func TestServer_ListAssetsQueryParsing(t *testing.T) {
 	}
 }
 
+func TestServer_FindSimilarAssetsQueryParsing(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var gotID string
+	var gotDistance, gotLimit int
+	srv, err := Start(ctx, 0, 1, Deps{
+		FindSimilarAssets: func(ctx context.Context, assetID string, maxDistance int, limit int) (any, error) {
+			gotID, gotDistance, gotLimit = assetID, maxDistance, limit
+			return []any{}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer srv.Close(context.Background())
+
+	resp, err := http.Get(srv.BaseURL() + "/api/assets/similar?assetId=a1&maxDistance=6&limit=20")
+	if err != nil {
+		t.Fatalf("similar: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("similar status: %d", resp.StatusCode)
+	}
+	_ = resp.Body.Close()
+	if gotID != "a1" || gotDistance != 6 || gotLimit != 20 {
+		t.Fatalf("similar parse failed: id=%q maxDistance=%d limit=%d", gotID, gotDistance, gotLimit)
+	}
+
+	resp, err = http.Get(srv.BaseURL() + "/api/assets/similar")
+	if err != nil {
+		t.Fatalf("similar without id: %v", err)
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400 without assetId, got %d", resp.StatusCode)
+	}
+	_ = resp.Body.Close()
+}
+
 func TestServer_UpdateAssetMeta_WithUserRating(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -603,6 +661,41 @@ func TestServer_ListAssets_InvalidCursor(t *testing.T) {
 	_ = resp.Body.Close()
 }
 
+func TestServer_ListAssets_QuerySyntaxError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srv, err := Start(ctx, 0, 1, Deps{
+		ListAssets: func(ctx context.Context, req services.ListAssetsRequest) (any, error) {
+			_, err := services.ParseAssetQuery(req.Query, time.Now())
+			return nil, err
+		},
+	})
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	defer srv.Close(context.Background())
+
+	resp, err := http.Get(srv.BaseURL() + "/api/assets?search=type:video%20rating>=9")
+	if err != nil {
+		t.Fatalf("list assets: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+	var body struct {
+		Success bool                     `json:"success"`
+		Data    services.AssetQueryError `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Success || body.Data.Offset != 19 || body.Data.End != 20 {
+		t.Fatalf("unexpected error payload: %+v", body)
+	}
+}
+
 func TestServer_ProjectSourcesAndHealthRoutes(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
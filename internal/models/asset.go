@@ -18,6 +18,7 @@ type Asset struct {
 	Status    string `bun:"status,notnull,default:'PENDING'" json:"status"` // PENDING, READY, INDEXED, MISSING, IGNORED, ERROR
 	MediaMeta string `bun:"media_meta" json:"media_meta"`                   // JSON string for extra metadata (width, height, duration)
 	Shape     string `bun:"shape,notnull,default:'unknown'" json:"shape"`
+	PHash     *int64 `bun:"phash" json:"-"` // 64 位 dHash（按位存为有符号整数），用于相似图检索
 
 	// Rating model:
 	// - suggested_rating is generated once during first successful metadata extraction.
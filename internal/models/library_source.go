@@ -5,9 +5,16 @@ import "github.com/uptrace/bun"
 type LibrarySource struct {
 	bun.BaseModel `bun:"table:library_sources"`
 
-	ID           string `bun:",pk" json:"id"`
-	RootPath     string `bun:"root_path" json:"root_path"`
-	WatchEnabled bool   `bun:"watch_enabled" json:"watch_enabled"`
-	CreatedAt    int64  `bun:"created_at" json:"created_at"`
-	UpdatedAt    int64  `bun:"updated_at" json:"updated_at"`
+	ID              string `bun:",pk" json:"id"`
+	RootPath        string `bun:"root_path" json:"root_path"`
+	WatchEnabled    bool   `bun:"watch_enabled" json:"watch_enabled"`
+	IgnoreRules     string `bun:"ignore_rules" json:"ignore_rules"`         // gitignore 语法，相对 RootPath
+	ScanConcurrency int    `bun:"scan_concurrency" json:"scan_concurrency"` // 扫描索引并发上限，0 为按设备自适应
+	VolumeID        string `bun:"volume_id" json:"volume_id,omitempty"`     // 所在卷的稳定标识（uuid:/label:/net:）
+	VolumeLabel     string `bun:"volume_label" json:"volume_label,omitempty"`
+	VolumePath      string `bun:"volume_path" json:"volume_path,omitempty"`   // 卷内路径，换挂载点后据此重新定位
+	VolumeState     string `bun:"volume_state" json:"volume_state,omitempty"` // online / offline
+	VolumeSeenAt    int64  `bun:"volume_seen_at" json:"volume_seen_at,omitempty"`
+	CreatedAt       int64  `bun:"created_at" json:"created_at"`
+	UpdatedAt       int64  `bun:"updated_at" json:"updated_at"`
 }
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	LineageCandidatePending   = "pending"
+	LineageCandidateConfirmed = "confirmed"
+	LineageCandidateRejected  = "rejected"
+)
+
+// LineageCandidate 自动检测出的血缘候选（祖先 → 后代），需用户确认后才写入 asset_lineage
+type LineageCandidate struct {
+	bun.BaseModel `bun:"table:lineage_candidates"`
+
+	ID           string          `bun:",pk" json:"id"`
+	AncestorID   string          `bun:"ancestor_id" json:"ancestor_id"`
+	DescendantID string          `bun:"descendant_id" json:"descendant_id"`
+	ProjectID    *string         `bun:"project_id" json:"project_id,omitempty"`
+	RuleType     string          `bun:"rule_type" json:"rule_type"`
+	Score        float64         `bun:"score" json:"score"`
+	Confidence   string          `bun:"confidence" json:"confidence"` // HIGH, MEDIUM, LOW
+	Status       string          `bun:"status" json:"status"`
+	Reason       string          `bun:"reason" json:"reason"`
+	EvidenceJSON string          `bun:"evidence_json" json:"-"`
+	Evidence     json.RawMessage `bun:"-" json:"evidence,omitempty"`
+	CreatedAt    int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt    int64           `bun:"updated_at" json:"updated_at"`
+}
@@ -13,6 +13,7 @@ const (
 	TaskStatusProcessing TaskStatus = "processing"
 	TaskStatusCompleted  TaskStatus = "completed"
 	TaskStatusFailed     TaskStatus = "failed"
+	TaskStatusCancelled  TaskStatus = "cancelled"
 )
 
 type MediaTask struct {
@@ -36,7 +37,31 @@ type MediaTask struct {
 	Progress         int       `bun:"progress,notnull,default:0" json:"progress"`
 	NextRetryAt      time.Time `bun:"next_retry_at" json:"next_retry_at,omitempty"`
 	DeadLetterReason string    `bun:"dead_letter_reason" json:"dead_letter_reason,omitempty"`
+	CancelRequested  bool      `bun:"cancel_requested,notnull,default:false" json:"cancel_requested,omitempty"`
 
 	CreatedAt time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 }
+
+// MediaTaskDLQEntry 超过重试次数后进入死信队列的任务快照
+type MediaTaskDLQEntry struct {
+	bun.BaseModel `bun:"table:media_task_dlq,alias:dlq"`
+
+	ID           string    `bun:"id,pk" json:"id"`
+	TaskID       string    `bun:"task_id,notnull" json:"task_id"`
+	AssetID      string    `bun:"asset_id,notnull" json:"asset_id"`
+	TaskType     string    `bun:"task_type,notnull" json:"task_type"`
+	ErrorMessage string    `bun:"error_message,notnull" json:"error_message"`
+	RetryCount   int       `bun:"retry_count,notnull" json:"retry_count"`
+	MaxRetries   int       `bun:"max_retries,notnull" json:"max_retries"`
+	Priority     int       `bun:"priority,notnull,default:0" json:"priority"`
+	CreatedAt    time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// MediaTaskDep 任务依赖：task_id 需等待 depends_on_task_id 完成后才可被领取
+type MediaTaskDep struct {
+	bun.BaseModel `bun:"table:media_task_deps,alias:mtd"`
+
+	TaskID          string `bun:"task_id,pk" json:"task_id"`
+	DependsOnTaskID string `bun:"depends_on_task_id,pk" json:"depends_on_task_id"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+// MetricsEvent 平台侧的原始指标点（某帖子某时刻的播放/点赞等）
+type MetricsEvent struct {
+	bun.BaseModel `bun:"table:metrics_events"`
+
+	ID             string          `bun:",pk" json:"id"`
+	ProjectID      string          `bun:"project_id" json:"project_id"`
+	RecordID       *string         `bun:"record_id" json:"record_id,omitempty"`
+	Platform       string          `bun:"platform" json:"platform"`
+	AccountRef     string          `bun:"account_ref" json:"account_ref"`
+	ExternalPostID string          `bun:"external_post_id" json:"external_post_id"`
+	MetricType     string          `bun:"metric_type" json:"metric_type"`
+	MetricValue    float64         `bun:"metric_value" json:"metric_value"`
+	OccurredAt     int64           `bun:"occurred_at" json:"occurred_at"`
+	Source         string          `bun:"source" json:"source"`
+	MetaJSON       string          `bun:"meta_json" json:"-"`
+	Meta           json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt      int64           `bun:"created_at" json:"created_at"`
+}
+
+// MetricsSnapshotDaily 按帖子按天汇总的指标；计数类字段为当日收盘累计值，followers_delta 为当日增量
+type MetricsSnapshotDaily struct {
+	bun.BaseModel `bun:"table:metrics_snapshots_daily"`
+
+	ID             string          `bun:",pk" json:"id"`
+	ProjectID      string          `bun:"project_id" json:"project_id"`
+	RecordID       *string         `bun:"record_id" json:"record_id,omitempty"`
+	Platform       string          `bun:"platform" json:"platform"`
+	ExternalPostID string          `bun:"external_post_id" json:"external_post_id"`
+	SnapshotDate   string          `bun:"snapshot_date" json:"snapshot_date"`
+	Views          float64         `bun:"views" json:"views"`
+	Likes          float64         `bun:"likes" json:"likes"`
+	Comments       float64         `bun:"comments" json:"comments"`
+	Shares         float64         `bun:"shares" json:"shares"`
+	Favorites      float64         `bun:"favorites" json:"favorites"`
+	FollowersDelta float64         `bun:"followers_delta" json:"followers_delta"`
+	MetaJSON       string          `bun:"meta_json" json:"-"`
+	Meta           json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt      int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt      int64           `bun:"updated_at" json:"updated_at"`
+}
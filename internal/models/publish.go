@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	PublishJobPending   = "pending"
+	PublishJobRunning   = "running"
+	PublishJobSucceeded = "succeeded"
+	PublishJobFailed    = "failed"
+	PublishJobCancelled = "cancelled"
+)
+
+// PublishChannel 项目的发布渠道（平台账号 + 适配器配置）
+type PublishChannel struct {
+	bun.BaseModel `bun:"table:publish_channels"`
+
+	ID          string          `bun:",pk" json:"id"`
+	ProjectID   string          `bun:"project_id" json:"project_id"`
+	Platform    string          `bun:"platform" json:"platform"`
+	AccountRef  string          `bun:"account_ref" json:"account_ref"`
+	DisplayName string          `bun:"display_name" json:"display_name"`
+	IsEnabled   bool            `bun:"is_enabled" json:"is_enabled"`
+	ConfigJSON  string          `bun:"config_json" json:"-"`
+	Config      json.RawMessage `bun:"-" json:"config,omitempty"`
+	CreatedAt   int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt   int64           `bun:"updated_at" json:"updated_at"`
+}
+
+// PublishJob 一次发布任务，由调度器在 schedule_at 到期后分发给渠道适配器
+type PublishJob struct {
+	bun.BaseModel `bun:"table:publish_jobs"`
+
+	ID             string          `bun:",pk" json:"id"`
+	ProjectID      string          `bun:"project_id" json:"project_id"`
+	WorkflowID     *string         `bun:"workflow_id" json:"workflow_id,omitempty"`
+	RoadmapItemID  *string         `bun:"roadmap_item_id" json:"roadmap_item_id,omitempty"`
+	ArtifactID     string          `bun:"artifact_id" json:"artifact_id"`
+	ChannelID      string          `bun:"channel_id" json:"channel_id"`
+	Status         string          `bun:"status" json:"status"`
+	ScheduleAt     int64           `bun:"schedule_at" json:"schedule_at"`
+	StartedAt      int64           `bun:"started_at" json:"started_at"`
+	FinishedAt     int64           `bun:"finished_at" json:"finished_at"`
+	AttemptCount   int             `bun:"attempt_count" json:"attempt_count"`
+	MaxAttempts    int             `bun:"max_attempts" json:"max_attempts"`
+	IdempotencyKey string          `bun:"idempotency_key" json:"idempotency_key"`
+	PayloadJSON    string          `bun:"payload_json" json:"-"`
+	Payload        json.RawMessage `bun:"-" json:"payload,omitempty"`
+	ErrorMessage   string          `bun:"error_message" json:"error_message,omitempty"`
+	CreatedAt      int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt      int64           `bun:"updated_at" json:"updated_at"`
+}
+
+// PublishRecord 发布成功后的平台侧记录（帖子 ID / 链接）
+type PublishRecord struct {
+	bun.BaseModel `bun:"table:publish_records"`
+
+	ID              string          `bun:",pk" json:"id"`
+	JobID           string          `bun:"job_id" json:"job_id"`
+	ProjectID       string          `bun:"project_id" json:"project_id"`
+	Platform        string          `bun:"platform" json:"platform"`
+	AccountRef      string          `bun:"account_ref" json:"account_ref"`
+	ExternalPostID  string          `bun:"external_post_id" json:"external_post_id"`
+	PostURL         string          `bun:"post_url" json:"post_url"`
+	PublishedAt     int64           `bun:"published_at" json:"published_at"`
+	RawResponseJSON string          `bun:"raw_response_json" json:"-"`
+	RawResponse     json.RawMessage `bun:"-" json:"raw_response,omitempty"`
+	CreatedAt       int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt       int64           `bun:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "github.com/uptrace/bun"
+
+const (
+	ScanJobPending   = "pending"
+	ScanJobRunning   = "running"
+	ScanJobSucceeded = "succeeded"
+	ScanJobFailed    = "failed"
+)
+
+// ScanJob 一次批量扫描中的单个根目录；未结束的任务在重启后按批次恢复
+type ScanJob struct {
+	bun.BaseModel `bun:"table:scan_jobs"`
+
+	ID           string `bun:",pk" json:"id"`
+	BatchID      string `bun:"batch_id" json:"batch_id"`
+	ProjectID    string `bun:"project_id" json:"project_id"`
+	RootPath     string `bun:"root_path" json:"root_path"`
+	Name         string `bun:"name" json:"name"`
+	Strict       bool   `bun:"strict" json:"strict"`
+	Full         bool   `bun:"full_scan" json:"full_scan"`
+	Status       string `bun:"status" json:"status"`
+	ScannedDirs  int    `bun:"scanned_dirs" json:"scanned_dirs"`
+	SkippedDirs  int    `bun:"skipped_dirs" json:"skipped_dirs"`
+	IndexedFiles int    `bun:"indexed_files" json:"indexed_files"`
+	CurrentDir   string `bun:"current_dir" json:"current_dir,omitempty"`
+	ErrorMessage string `bun:"error_message" json:"error_message,omitempty"`
+	CreatedAt    int64  `bun:"created_at" json:"created_at"`
+	UpdatedAt    int64  `bun:"updated_at" json:"updated_at"`
+	FinishedAt   int64  `bun:"finished_at" json:"finished_at,omitempty"`
+}
+
+// ScanDirState 目录级扫描检查点：目录 mtime 与条目数未变且已完成时，重扫跳过其中文件
+type ScanDirState struct {
+	bun.BaseModel `bun:"table:scan_dir_states"`
+
+	ProjectID   string `bun:"project_id,pk" json:"project_id"`
+	DirPath     string `bun:"dir_path,pk" json:"dir_path"`
+	ModTime     int64  `bun:"mod_time" json:"mod_time"`
+	EntryCount  int    `bun:"entry_count" json:"entry_count"`
+	JobID       string `bun:"job_id" json:"job_id"`
+	CompletedAt int64  `bun:"completed_at" json:"completed_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+// SmartCollection 智能集合：命名保存的资产筛选条件（结构化查询 + 过滤项）
+type SmartCollection struct {
+	bun.BaseModel `bun:"table:smart_collections"`
+
+	ID          string          `bun:",pk" json:"id"`
+	Name        string          `bun:"name" json:"name"`
+	Icon        *string         `bun:"icon" json:"icon,omitempty"`
+	PinOrder    int             `bun:"pin_order" json:"pin_order"` // >0 表示置顶，数值越小越靠前
+	Query       string          `bun:"query" json:"query"`
+	FiltersJSON string          `bun:"filters_json" json:"-"`
+	Filters     json.RawMessage `bun:"-" json:"filters,omitempty"`
+	CreatedAt   int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt   int64           `bun:"updated_at" json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+// TaskResultProvenance 插件任务结果写入核心数据的来源记录：哪个任务 / 插件 / worker 按哪条映射写了什么
+type TaskResultProvenance struct {
+	bun.BaseModel `bun:"table:task_result_provenance,alias:trp"`
+
+	ID         string          `bun:"id,pk" json:"id"`
+	TaskID     string          `bun:"task_id,notnull" json:"task_id"`
+	AssetID    string          `bun:"asset_id,notnull" json:"asset_id"`
+	TaskType   string          `bun:"task_type,notnull" json:"task_type"`
+	PluginID   string          `bun:"plugin_id,notnull" json:"plugin_id"`
+	WorkerID   string          `bun:"worker_id" json:"worker_id"`
+	Source     string          `bun:"source,notnull" json:"source"`           // 结果中的字段路径，如 tags[]
+	TargetKind string          `bun:"target_kind,notnull" json:"target_kind"` // asset_tag / project_note / media_meta
+	TargetID   string          `bun:"target_id" json:"target_id"`
+	ValueJSON  string          `bun:"value_json" json:"-"`
+	Value      json.RawMessage `bun:"-" json:"value,omitempty"`
+	CreatedAt  int64           `bun:"created_at,notnull" json:"created_at"`
+}
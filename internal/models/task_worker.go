@@ -0,0 +1,36 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+// TaskWorker 任务执行者（内置 worker 或外部 worker）的注册信息
+type TaskWorker struct {
+	bun.BaseModel `bun:"table:task_workers,alias:tw"`
+
+	ID             string          `bun:"id,pk" json:"id"`
+	Name           string          `bun:"name" json:"name"`
+	Kind           string          `bun:"kind,notnull" json:"kind"` // internal / external
+	TaskTypesJSON  string          `bun:"task_types_json" json:"-"`
+	TaskTypes      json.RawMessage `bun:"-" json:"task_types"`
+	MaxConcurrency int             `bun:"max_concurrency,notnull" json:"max_concurrency"` // 0 表示不限
+	CompletedCount int64           `bun:"completed_count,notnull" json:"completed_count"`
+	FailedCount    int64           `bun:"failed_count,notnull" json:"failed_count"`
+	RegisteredAt   int64           `bun:"registered_at,notnull" json:"registered_at"`
+	LastSeenAt     int64           `bun:"last_seen_at,notnull" json:"last_seen_at"`
+}
+
+// TaskConcurrencyLimit 按任务类型（可选限定扩展名）的全局并发上限
+type TaskConcurrencyLimit struct {
+	bun.BaseModel `bun:"table:task_concurrency_limits,alias:tcl"`
+
+	ID             string          `bun:"id,pk" json:"id"`
+	TaskType       string          `bun:"task_type,notnull" json:"task_type"`
+	ExtensionsJSON string          `bun:"extensions_json" json:"-"`
+	Extensions     json.RawMessage `bun:"-" json:"extensions"`
+	MaxConcurrent  int             `bun:"max_concurrent,notnull" json:"max_concurrent"`
+	CreatedAt      int64           `bun:"created_at,notnull" json:"created_at"`
+	UpdatedAt      int64           `bun:"updated_at,notnull" json:"updated_at"`
+}
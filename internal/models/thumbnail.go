@@ -0,0 +1,19 @@
+package models
+
+import "github.com/uptrace/bun"
+
+// ThumbnailRendition 缩略图规格文件：按内容指纹寻址，相同内容的资产（重复文件、移动后重新关联的资产）共用同一份
+type ThumbnailRendition struct {
+	bun.BaseModel `bun:"table:thumbnail_renditions,alias:thr"`
+
+	ContentKey string `bun:"content_key,pk" json:"content_key"` // 资产指纹
+	Rendition  string `bun:"rendition,pk" json:"rendition"`     // grid / preview / poster / storyboard
+	Format     string `bun:"format,notnull" json:"format"`      // webp / jpeg
+	RelPath    string `bun:"rel_path,notnull" json:"rel_path"`  // 相对数据目录
+	Width      int    `bun:"width,notnull" json:"width"`
+	Height     int    `bun:"height,notnull" json:"height"`
+	Bytes      int64  `bun:"bytes,notnull" json:"bytes"`
+	ETag       string `bun:"etag,notnull" json:"etag"`
+	MetaJSON   string `bun:"meta_json" json:"-"` // 附加索引（如故事板的帧位置）
+	CreatedAt  int64  `bun:"created_at,notnull" json:"created_at"`
+}
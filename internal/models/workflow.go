@@ -0,0 +1,137 @@
+package models
+
+import (
+	"encoding/json"
+
+	"github.com/uptrace/bun"
+)
+
+const (
+	ProjectWorkflowActive    = "active"
+	ProjectWorkflowCompleted = "completed"
+	ProjectWorkflowArchived  = "archived"
+
+	WorkflowStepTodo       = "todo"
+	WorkflowStepInProgress = "in_progress"
+	WorkflowStepDone       = "done"
+	WorkflowStepSkipped    = "skipped"
+)
+
+// WorkflowTemplate 工作流模板（系统内置或用户自定义）
+type WorkflowTemplate struct {
+	bun.BaseModel `bun:"table:workflow_templates"`
+
+	ID          string          `bun:",pk" json:"id"`
+	Name        string          `bun:"name" json:"name"`
+	Domain      string          `bun:"domain" json:"domain"`
+	Description string          `bun:"description" json:"description"`
+	IsSystem    bool            `bun:"is_system" json:"is_system"`
+	IsActive    bool            `bun:"is_active" json:"is_active"`
+	MetaJSON    string          `bun:"meta_json" json:"-"`
+	Meta        json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt   int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt   int64           `bun:"updated_at" json:"updated_at"`
+}
+
+// WorkflowTemplateStep 模板中的一个步骤
+type WorkflowTemplateStep struct {
+	bun.BaseModel `bun:"table:workflow_template_steps"`
+
+	ID            string          `bun:",pk" json:"id"`
+	TemplateID    string          `bun:"template_id" json:"template_id"`
+	Title         string          `bun:"title" json:"title"`
+	StepType      string          `bun:"step_type" json:"step_type"`
+	OrderIndex    int             `bun:"order_index" json:"order_index"`
+	IsRequired    bool            `bun:"is_required" json:"is_required"`
+	SuggestedDays int             `bun:"suggested_days" json:"suggested_days"`
+	ChecklistJSON string          `bun:"checklist_json" json:"-"`
+	Checklist     json.RawMessage `bun:"-" json:"checklist,omitempty"`
+	MetaJSON      string          `bun:"meta_json" json:"-"`
+	Meta          json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt     int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt     int64           `bun:"updated_at" json:"updated_at"`
+}
+
+// ProjectWorkflow 项目实例化后的工作流，每个项目最多一个
+type ProjectWorkflow struct {
+	bun.BaseModel `bun:"table:project_workflows"`
+
+	ID               string          `bun:",pk" json:"id"`
+	ProjectID        string          `bun:"project_id" json:"project_id"`
+	TemplateID       string          `bun:"template_id" json:"template_id"`
+	Name             string          `bun:"name" json:"name"`
+	Status           string          `bun:"status" json:"status"`
+	CurrentStepIndex int             `bun:"current_step_index" json:"current_step_index"`
+	StartAt          int64           `bun:"start_at" json:"start_at"`
+	TargetEndAt      int64           `bun:"target_end_at" json:"target_end_at"`
+	MetaJSON         string          `bun:"meta_json" json:"-"`
+	Meta             json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt        int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt        int64           `bun:"updated_at" json:"updated_at"`
+}
+
+// ProjectWorkflowStep 项目工作流中的步骤，order_index 从 0 连续编号
+type ProjectWorkflowStep struct {
+	bun.BaseModel `bun:"table:project_workflow_steps"`
+
+	ID             string          `bun:",pk" json:"id"`
+	WorkflowID     string          `bun:"workflow_id" json:"workflow_id"`
+	TemplateStepID *string         `bun:"template_step_id" json:"template_step_id,omitempty"`
+	Title          string          `bun:"title" json:"title"`
+	StepType       string          `bun:"step_type" json:"step_type"`
+	OrderIndex     int             `bun:"order_index" json:"order_index"`
+	Status         string          `bun:"status" json:"status"`
+	IsRequired     bool            `bun:"is_required" json:"is_required"`
+	StartedAt      int64           `bun:"started_at" json:"started_at"`
+	CompletedAt    int64           `bun:"completed_at" json:"completed_at"`
+	KPIRulesJSON   string          `bun:"kpi_rules_json" json:"-"`
+	KPIRules       json.RawMessage `bun:"-" json:"kpi_rules,omitempty"`
+	KPIResultJSON  string          `bun:"kpi_result_json" json:"-"`
+	KPIResult      json.RawMessage `bun:"-" json:"kpi_result,omitempty"`
+	Note           string          `bun:"note" json:"note"`
+	MetaJSON       string          `bun:"meta_json" json:"-"`
+	Meta           json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt      int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt      int64           `bun:"updated_at" json:"updated_at"`
+}
+
+// ProjectRoadmapItem 项目路线图条目（里程碑 / 任务）
+type ProjectRoadmapItem struct {
+	bun.BaseModel `bun:"table:project_roadmap_items"`
+
+	ID         string          `bun:",pk" json:"id"`
+	ProjectID  string          `bun:"project_id" json:"project_id"`
+	WorkflowID string          `bun:"workflow_id" json:"workflow_id"`
+	ParentID   *string         `bun:"parent_id" json:"parent_id,omitempty"`
+	Title      string          `bun:"title" json:"title"`
+	ItemType   string          `bun:"item_type" json:"item_type"`
+	Status     string          `bun:"status" json:"status"`
+	Priority   int             `bun:"priority" json:"priority"`
+	OrderIndex int             `bun:"order_index" json:"order_index"`
+	StartAt    int64           `bun:"start_at" json:"start_at"`
+	DueAt      int64           `bun:"due_at" json:"due_at"`
+	Note       string          `bun:"note" json:"note"`
+	MetaJSON   string          `bun:"meta_json" json:"-"`
+	Meta       json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt  int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt  int64           `bun:"updated_at" json:"updated_at"`
+}
+
+// ProjectNote 项目笔记（灵感、复盘、脚本片段等）
+type ProjectNote struct {
+	bun.BaseModel `bun:"table:project_notes"`
+
+	ID            string          `bun:",pk" json:"id"`
+	ProjectID     string          `bun:"project_id" json:"project_id"`
+	WorkflowID    *string         `bun:"workflow_id" json:"workflow_id,omitempty"`
+	NoteType      string          `bun:"note_type" json:"note_type"`
+	Title         string          `bun:"title" json:"title"`
+	Content       string          `bun:"content" json:"content"`
+	SourceAssetID *string         `bun:"source_asset_id" json:"source_asset_id,omitempty"`
+	Status        string          `bun:"status" json:"status"`
+	IsPinned      bool            `bun:"is_pinned" json:"is_pinned"`
+	MetaJSON      string          `bun:"meta_json" json:"-"`
+	Meta          json.RawMessage `bun:"-" json:"meta,omitempty"`
+	CreatedAt     int64           `bun:"created_at" json:"created_at"`
+	UpdatedAt     int64           `bun:"updated_at" json:"updated_at"`
+}
@@ -42,6 +42,7 @@ type parserEntry struct {
 // Manager manages the registration and selection of parsers
 type Manager struct {
 	parsers []parserEntry
+	sources []thumbnailSourceEntry
 	mu      sync.RWMutex
 }
 
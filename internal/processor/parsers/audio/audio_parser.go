@@ -0,0 +1,195 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"media-assistant-os/internal/processor"
+)
+
+type Parser struct{}
+
+func New() *Parser {
+	return &Parser{}
+}
+
+func (p *Parser) Name() string {
+	return "native.audio"
+}
+
+func (p *Parser) CanHandle(ext string) bool {
+	ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+	supported := map[string]bool{
+		"mp3":  true,
+		"wav":  true,
+		"bwf":  true,
+		"flac": true,
+		"m4a":  true,
+		"aac":  true,
+		"ogg":  true,
+		"opus": true,
+		"aif":  true,
+		"aiff": true,
+	}
+	return supported[ext]
+}
+
+// audioInfo 各格式解析的公共结果；Tags 的键与 media_meta.extra 一致（title / artist / album ...）
+type audioInfo struct {
+	Duration      float64
+	Codec         string
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	Bitrate       int // bit/s
+	Tags          map[string]string
+}
+
+func (p *Parser) Parse(ctx context.Context, path string) (*processor.Result, error) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	res := &processor.Result{
+		Metadata: &processor.Metadata{
+			Format: strings.ToUpper(ext),
+			Extra:  map[string]any{},
+		},
+	}
+
+	info, err := readNative(path, ext)
+	if err != nil {
+		// 原生解析不支持的格式（ogg / opus / aac 裸流等）或文件异常时交给 ffprobe
+		if probed, perr := probeAudio(ctx, path); perr == nil {
+			info = probed
+		} else {
+			res.Metadata.Extra["probe_error"] = err.Error()
+		}
+	}
+	if info == nil {
+		return res, nil
+	}
+
+	res.Metadata.Duration = info.Duration
+	res.Metadata.Codec = info.Codec
+	if info.SampleRate > 0 {
+		res.Metadata.Extra["sample_rate"] = info.SampleRate
+	}
+	if info.Channels > 0 {
+		res.Metadata.Extra["channels"] = info.Channels
+	}
+	if info.BitsPerSample > 0 {
+		res.Metadata.Extra["bits_per_sample"] = info.BitsPerSample
+	}
+	if info.Bitrate > 0 {
+		res.Metadata.Extra["bitrate"] = info.Bitrate
+	}
+	for k, v := range info.Tags {
+		if v = strings.TrimSpace(v); v != "" {
+			res.Metadata.Extra[k] = v
+		}
+	}
+	return res, nil
+}
+
+var errUnsupported = errors.New("unsupported audio container")
+
+func readNative(path, ext string) (*audioInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var info *audioInfo
+	switch ext {
+	case "wav", "bwf":
+		info, err = readWAV(f, st.Size())
+	case "aif", "aiff":
+		info, err = readAIFF(f, st.Size())
+	case "mp3":
+		info, err = readMP3(f, st.Size())
+	case "flac":
+		info, err = readFLAC(f, st.Size())
+	case "m4a":
+		info, err = readM4A(f, st.Size())
+	default:
+		return nil, errUnsupported
+	}
+	if err != nil {
+		return nil, err
+	}
+	if info.Bitrate <= 0 && info.Duration > 0 {
+		info.Bitrate = int(float64(st.Size()) * 8 / info.Duration)
+	}
+	return info, nil
+}
+
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecName     string `json:"codec_name"`
+		SampleRate    string `json:"sample_rate"`
+		Channels      int    `json:"channels"`
+		BitsPerSample string `json:"bits_per_raw_sample"`
+		BitRate       string `json:"bit_rate"`
+	} `json:"streams"`
+	Format struct {
+		Duration string            `json:"duration"`
+		BitRate  string            `json:"bit_rate"`
+		Tags     map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+func probeAudio(ctx context.Context, path string) (*audioInfo, error) {
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return nil, errors.New("ffprobe not found")
+	}
+	cmd := exec.CommandContext(ctx,
+		ffprobePath,
+		"-v", "error",
+		"-select_streams", "a:0",
+		"-show_entries", "stream=codec_name,sample_rate,channels,bits_per_raw_sample,bit_rate",
+		"-show_entries", "format=duration,bit_rate:format_tags",
+		"-of", "json",
+		path,
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	var parsed ffprobeOutput
+	if err := json.Unmarshal(out.Bytes(), &parsed); err != nil {
+		return nil, err
+	}
+	if len(parsed.Streams) == 0 {
+		return nil, errors.New("no audio stream")
+	}
+	s := parsed.Streams[0]
+	info := &audioInfo{
+		Codec:    s.CodecName,
+		Channels: s.Channels,
+		Tags:     map[string]string{},
+	}
+	info.SampleRate, _ = strconv.Atoi(s.SampleRate)
+	info.BitsPerSample, _ = strconv.Atoi(s.BitsPerSample)
+	info.Duration, _ = strconv.ParseFloat(parsed.Format.Duration, 64)
+	if info.Bitrate, _ = strconv.Atoi(s.BitRate); info.Bitrate == 0 {
+		info.Bitrate, _ = strconv.Atoi(parsed.Format.BitRate)
+	}
+	for k, v := range parsed.Format.Tags {
+		if key, ok := vorbisTagKeys[strings.ToUpper(k)]; ok {
+			info.Tags[key] = v
+		}
+	}
+	return info, nil
+}
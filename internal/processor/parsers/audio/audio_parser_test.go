@@ -0,0 +1,400 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 测试用的最小容器构造器，只写解析器实际读取的字段
+
+func chunkLE(id string, body []byte) []byte {
+	b := append([]byte(id), binary.LittleEndian.AppendUint32(nil, uint32(len(body)))...)
+	b = append(b, body...)
+	if len(body)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func chunkBE(id string, body []byte) []byte {
+	b := append([]byte(id), binary.BigEndian.AppendUint32(nil, uint32(len(body)))...)
+	b = append(b, body...)
+	if len(body)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func box(typ string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	return append(append(binary.BigEndian.AppendUint32(nil, uint32(8+len(body))), typ...), body...)
+}
+
+func buildWAV(format uint16, channels, rate, bits, dataLen int, extra ...[]byte) []byte {
+	align := channels * bits / 8
+	f := binary.LittleEndian.AppendUint16(nil, format)
+	f = binary.LittleEndian.AppendUint16(f, uint16(channels))
+	f = binary.LittleEndian.AppendUint32(f, uint32(rate))
+	f = binary.LittleEndian.AppendUint32(f, uint32(rate*align))
+	f = binary.LittleEndian.AppendUint16(f, uint16(align))
+	f = binary.LittleEndian.AppendUint16(f, uint16(bits))
+	body := append([]byte("WAVE"), chunkLE("fmt ", f)...)
+	for _, e := range extra {
+		body = append(body, e...)
+	}
+	body = append(body, chunkLE("data", make([]byte, dataLen))...)
+	return chunkLE("RIFF", body)
+}
+
+func riffInfo(kv ...string) []byte {
+	body := []byte("INFO")
+	for i := 0; i+1 < len(kv); i += 2 {
+		body = append(body, chunkLE(kv[i], append([]byte(kv[i+1]), 0))...)
+	}
+	return chunkLE("LIST", body)
+}
+
+// extended80 把整数采样率编码为 AIFF 的 80 位扩展精度
+func extended80(v uint64) []byte {
+	exp := 16383 + 63
+	for v&(1<<63) == 0 {
+		v <<= 1
+		exp--
+	}
+	b := binary.BigEndian.AppendUint16(nil, uint16(exp))
+	return binary.BigEndian.AppendUint64(b, v)
+}
+
+func buildAIFF(form string, channels int, frames uint32, bits int, rate uint64, compression string, extra ...[]byte) []byte {
+	c := binary.BigEndian.AppendUint16(nil, uint16(channels))
+	c = binary.BigEndian.AppendUint32(c, frames)
+	c = binary.BigEndian.AppendUint16(c, uint16(bits))
+	c = append(c, extended80(rate)...)
+	c = append(c, compression...)
+	body := append([]byte(form), chunkBE("COMM", c)...)
+	for _, e := range extra {
+		body = append(body, e...)
+	}
+	return chunkBE("FORM", body)
+}
+
+// id3v23 ID3v2.3 标签，帧为 latin1 文本
+func id3v23(kv ...string) []byte {
+	var frames []byte
+	for i := 0; i+1 < len(kv); i += 2 {
+		data := append([]byte{0}, kv[i+1]...)
+		frames = append(frames, kv[i]...)
+		frames = binary.BigEndian.AppendUint32(frames, uint32(len(data)))
+		frames = append(frames, 0, 0)
+		frames = append(frames, data...)
+	}
+	n := len(frames)
+	hdr := []byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+	return append(hdr, frames...)
+}
+
+func id3v1(title, artist string, track, genre byte) []byte {
+	b := make([]byte, 128)
+	copy(b, "TAG")
+	copy(b[3:33], title)
+	copy(b[33:63], artist)
+	b[126] = track
+	b[127] = genre
+	return b
+}
+
+// mp3Frames MPEG-1 Layer III 128kbit/s 44.1kHz 立体声，每帧 417 字节
+func mp3Frames(n int, first []byte) []byte {
+	const frameLen = 417
+	var out []byte
+	for i := 0; i < n; i++ {
+		f := make([]byte, frameLen)
+		copy(f, []byte{0xFF, 0xFB, 0x90, 0x00})
+		if i == 0 && first != nil {
+			copy(f[4:], first)
+		}
+		out = append(out, f...)
+	}
+	return out
+}
+
+func xingHeader(frames, total uint32) []byte {
+	b := make([]byte, 32) // 立体声 MPEG-1 的 side info
+	b = append(b, "Xing"...)
+	b = binary.BigEndian.AppendUint32(b, 0x3)
+	b = binary.BigEndian.AppendUint32(b, frames)
+	return binary.BigEndian.AppendUint32(b, total)
+}
+
+func buildFLAC(rate, channels, bits int, samples uint64, comments ...string) []byte {
+	si := make([]byte, 34)
+	v := uint64(rate)<<44 | uint64(channels-1)<<41 | uint64(bits-1)<<36 | samples
+	binary.BigEndian.PutUint64(si[10:18], v)
+	vc := binary.LittleEndian.AppendUint32(nil, 3)
+	vc = append(vc, "enc"...)
+	vc = binary.LittleEndian.AppendUint32(vc, uint32(len(comments)))
+	for _, c := range comments {
+		vc = binary.LittleEndian.AppendUint32(vc, uint32(len(c)))
+		vc = append(vc, c...)
+	}
+	b := []byte("fLaC")
+	b = append(b, 0, 0, 0, 34)
+	b = append(b, si...)
+	b = append(b, 0x80|flacBlockVorbisComment, byte(len(vc)>>16), byte(len(vc)>>8), byte(len(vc)))
+	b = append(b, vc...)
+	return append(b, make([]byte, 1000)...)
+}
+
+func buildM4A(fourcc string, channels, rate int, timescale, duration uint32, ilst []byte) []byte {
+	mvhd := make([]byte, 20)
+	binary.BigEndian.PutUint32(mvhd[12:16], 1000)
+	binary.BigEndian.PutUint32(mvhd[16:20], 1) // 故意与 mdhd 不同，以音轨为准
+	mdhd := make([]byte, 20)
+	binary.BigEndian.PutUint32(mdhd[12:16], timescale)
+	binary.BigEndian.PutUint32(mdhd[16:20], duration)
+	hdlr := make([]byte, 24)
+	copy(hdlr[8:12], "soun")
+	entry := make([]byte, 28)
+	binary.BigEndian.PutUint16(entry[16:18], uint16(channels))
+	binary.BigEndian.PutUint16(entry[18:20], 16)
+	binary.BigEndian.PutUint32(entry[24:28], uint32(rate)<<16)
+	stsd := box("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, box(fourcc, entry))
+	trak := box("trak", box("mdia", box("mdhd", mdhd), box("hdlr", hdlr), box("minf", box("stbl", stsd))))
+	moov := box("moov", box("mvhd", mvhd), trak)
+	if ilst != nil {
+		metaHdlr := make([]byte, 24)
+		copy(metaHdlr[8:12], "mdir")
+		moov = box("moov", box("mvhd", mvhd), trak, box("udta", box("meta", []byte{0, 0, 0, 0}, box("hdlr", metaHdlr), ilst)))
+	}
+	return append(append(box("ftyp", []byte("M4A \x00\x00\x00\x00")), box("mdat", make([]byte, 64))...), moov...)
+}
+
+func ilstItem(typ string, val []byte) []byte {
+	return box(typ, box("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, val))
+}
+
+func TestReaders(t *testing.T) {
+	mp3Body := mp3Frames(100, nil)
+	vbrBody := mp3Frames(10, xingHeader(1000, 500000))
+	tagged := append(append(id3v23("TIT2", "Song", "TCON", "(17)"), mp3Body...), id3v1("Old", "Band", 7, 0)...)
+
+	tests := []struct {
+		name  string
+		read  func(io.ReaderAt, int64) (*audioInfo, error)
+		data  []byte
+		want  audioInfo
+		wantE bool
+	}{
+		{
+			name: "wav pcm16 stereo",
+			read: readWAV,
+			data: buildWAV(wavFormatPCM, 2, 48000, 16, 192000, riffInfo("INAM", "Take 1", "IART", "Crew")),
+			want: audioInfo{Duration: 1, Codec: "pcm_s16le", SampleRate: 48000, Channels: 2, BitsPerSample: 16, Bitrate: 1536000,
+				Tags: map[string]string{"title": "Take 1", "artist": "Crew"}},
+		},
+		{
+			name: "wav float mono",
+			read: readWAV,
+			data: buildWAV(wavFormatFloat, 1, 44100, 32, 44100*4*2),
+			want: audioInfo{Duration: 2, Codec: "pcm_f32le", SampleRate: 44100, Channels: 1, BitsPerSample: 32, Bitrate: 44100 * 32},
+		},
+		{
+			name:  "wav missing data",
+			read:  readWAV,
+			data:  chunkLE("RIFF", append([]byte("WAVE"), chunkLE("fmt ", make([]byte, 16))...)),
+			wantE: true,
+		},
+		{
+			name: "aiff",
+			read: readAIFF,
+			data: buildAIFF("AIFF", 2, 88200, 24, 44100, "", chunkBE("NAME", []byte("Room tone"))),
+			want: audioInfo{Duration: 2, Codec: "pcm_s24be", SampleRate: 44100, Channels: 2, BitsPerSample: 24, Bitrate: 44100 * 2 * 24,
+				Tags: map[string]string{"title": "Room tone"}},
+		},
+		{
+			name: "aifc compressed",
+			read: readAIFF,
+			data: buildAIFF("AIFC", 1, 48000, 16, 48000, "sowt"),
+			want: audioInfo{Duration: 1, Codec: "sowt", SampleRate: 48000, Channels: 1, BitsPerSample: 16, Bitrate: 48000 * 16},
+		},
+		{
+			name: "mp3 cbr",
+			read: readMP3,
+			data: mp3Body,
+			want: audioInfo{Duration: float64(len(mp3Body)) * 8 / 128000, Codec: "mp3", SampleRate: 44100, Channels: 2, Bitrate: 128000},
+		},
+		{
+			name: "mp3 xing vbr",
+			read: readMP3,
+			data: vbrBody,
+			want: audioInfo{Duration: 1000 * 1152 / 44100.0, Codec: "mp3", SampleRate: 44100, Channels: 2, Bitrate: int(500000 * 8 / (1000 * 1152 / 44100.0))},
+		},
+		{
+			name: "mp3 id3v2 wins over id3v1",
+			read: readMP3,
+			data: tagged,
+			want: audioInfo{Duration: float64(len(mp3Body)) * 8 / 128000, Codec: "mp3", SampleRate: 44100, Channels: 2, Bitrate: 128000,
+				Tags: map[string]string{"title": "Song", "genre": "Rock", "artist": "Band", "track": "7"}},
+		},
+		{
+			name:  "mp3 garbage",
+			read:  readMP3,
+			data:  bytes.Repeat([]byte{0xFF, 0x00}, 2000),
+			wantE: true,
+		},
+		{
+			name: "flac",
+			read: readFLAC,
+			data: buildFLAC(96000, 2, 24, 96000*3, "TITLE=Stem", "tracknumber=4", "ARTIST=A", "ARTIST=B"),
+			want: audioInfo{Duration: 3, Codec: "flac", SampleRate: 96000, Channels: 2, BitsPerSample: 24, Bitrate: 1000 * 8 / 3,
+				Tags: map[string]string{"title": "Stem", "track": "4", "artist": "A"}},
+		},
+		{
+			name: "flac behind id3v2",
+			read: readFLAC,
+			data: append(id3v23("TIT2", "x"), buildFLAC(44100, 1, 16, 44100)...),
+			want: audioInfo{Duration: 1, Codec: "flac", SampleRate: 44100, Channels: 1, BitsPerSample: 16, Bitrate: 8000},
+		},
+		{
+			name: "m4a aac with moov after mdat",
+			read: readM4A,
+			data: buildM4A("mp4a", 2, 44100, 44100, 44100*5,
+				box("ilst", ilstItem("\xa9nam", []byte("Mix")), ilstItem("trkn", []byte{0, 0, 0, 3, 0, 10, 0, 0}), ilstItem("gnre", []byte{0, 18}))),
+			want: audioInfo{Duration: 5, Codec: "aac", SampleRate: 44100, Channels: 2,
+				Tags: map[string]string{"title": "Mix", "track": "3", "genre": "Rock"}},
+		},
+		{
+			name: "m4a alac keeps bit depth",
+			read: readM4A,
+			data: buildM4A("alac", 1, 48000, 48000, 24000, nil),
+			want: audioInfo{Duration: 0.5, Codec: "alac", SampleRate: 48000, Channels: 1, BitsPerSample: 16},
+		},
+		{
+			name:  "m4a without ftyp",
+			read:  readM4A,
+			data:  box("moov", box("mvhd", make([]byte, 20))),
+			wantE: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.read(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if tc.wantE {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(got.Duration-tc.want.Duration) > 1e-6 {
+				t.Errorf("duration = %v, want %v", got.Duration, tc.want.Duration)
+			}
+			if got.Codec != tc.want.Codec || got.SampleRate != tc.want.SampleRate || got.Channels != tc.want.Channels ||
+				got.BitsPerSample != tc.want.BitsPerSample || got.Bitrate != tc.want.Bitrate {
+				t.Errorf("got %+v, want %+v", *got, tc.want)
+			}
+			if len(got.Tags) != len(tc.want.Tags) {
+				t.Errorf("tags = %v, want %v", got.Tags, tc.want.Tags)
+			}
+			for k, v := range tc.want.Tags {
+				if got.Tags[k] != v {
+					t.Errorf("tag %s = %q, want %q", k, got.Tags[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeID3Genre(t *testing.T) {
+	tests := map[string]string{
+		"(17)":         "Rock",
+		"17":           "Rock",
+		"(17)Britrock": "Britrock",
+		"Shoegaze":     "Shoegaze",
+		"(999)":        "(999)",
+	}
+	for in, want := range tests {
+		if got := normalizeID3Genre(in); got != want {
+			t.Errorf("normalizeID3Genre(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDecodeID3String(t *testing.T) {
+	tests := []struct {
+		enc  byte
+		data []byte
+		want string
+	}{
+		{0, []byte{'c', 0xE9}, "cé"},
+		{1, []byte{0xFF, 0xFE, 'h', 0, 'i', 0}, "hi"},
+		{1, []byte{0xFE, 0xFF, 0, 'h', 0, 'i'}, "hi"},
+		{2, []byte{0x4E, 0x2D}, "中"},
+		{3, []byte("中文"), "中文"},
+	}
+	for _, tc := range tests {
+		if got := decodeID3String(tc.enc, tc.data); got != tc.want {
+			t.Errorf("decodeID3String(%d, %v) = %q, want %q", tc.enc, tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestComputePeaks_WAV(t *testing.T) {
+	// 16 位立体声，8 个采样点每 4 个一个峰值；取绝对值较大的声道
+	samples := [][2]int16{{100, -200}, {300, 0}, {-16384, 0}, {0, 0}, {0, 32767}, {0, 0}, {0, 0}, {-5, 5}}
+	var pcm []byte
+	for _, s := range samples {
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(s[0]))
+		pcm = binary.LittleEndian.AppendUint16(pcm, uint16(s[1]))
+	}
+	data := buildWAV(wavFormatPCM, 2, 8, 16, 0)
+	data = append(data[:len(data)-8], chunkLE("data", pcm)...)
+	binary.LittleEndian.PutUint32(data[4:8], uint32(len(data)-8))
+
+	path := filepath.Join(t.TempDir(), "a.wav")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := ComputePeaks(context.Background(), path, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.SamplesPerPeak != 4 || len(p.Min) != 2 || len(p.Max) != 2 {
+		t.Fatalf("peaks = %+v", p)
+	}
+	want := [][2]float32{{-0.5, 300.0 / 32768}, {-5.0 / 32768, 32767.0 / 32768}}
+	for i, w := range want {
+		if p.Min[i] != w[0] || p.Max[i] != w[1] {
+			t.Errorf("peak %d = [%v, %v], want %v", i, p.Min[i], p.Max[i], w)
+		}
+	}
+}
+
+func TestParse_WAVExtra(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clip.wav")
+	if err := os.WriteFile(path, buildWAV(wavFormatPCM, 1, 16000, 16, 32000, riffInfo("ICMT", "  ")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := New().Parse(context.Background(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := res.Metadata
+	if m.Format != "WAV" || m.Codec != "pcm_s16le" || m.Duration != 1 {
+		t.Fatalf("metadata = %+v", m)
+	}
+	if m.Extra["sample_rate"] != 16000 || m.Extra["channels"] != 1 || m.Extra["bitrate"] != 256000 {
+		t.Errorf("extra = %v", m.Extra)
+	}
+	if _, ok := m.Extra["comment"]; ok {
+		t.Errorf("blank comment should be dropped: %v", m.Extra)
+	}
+}
@@ -0,0 +1,128 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+// vorbisTagKeys Vorbis comment（FLAC / Ogg）及 ffprobe 标签名到标签键的映射
+var vorbisTagKeys = map[string]string{
+	"TITLE":        "title",
+	"ARTIST":       "artist",
+	"ALBUM":        "album",
+	"ALBUMARTIST":  "album_artist",
+	"ALBUM_ARTIST": "album_artist",
+	"GENRE":        "genre",
+	"DATE":         "year",
+	"YEAR":         "year",
+	"TRACKNUMBER":  "track",
+	"TRACK":        "track",
+	"COMPOSER":     "composer",
+	"COMMENT":      "comment",
+	"DESCRIPTION":  "comment",
+}
+
+const (
+	flacBlockStreamInfo    = 0
+	flacBlockVorbisComment = 4
+)
+
+func readFLAC(r io.ReaderAt, size int64) (*audioInfo, error) {
+	var hdr [10]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	// 部分工具会在 FLAC 前写 ID3v2
+	off := id3v2Size(hdr[:])
+	var magic [4]byte
+	if _, err := r.ReadAt(magic[:], off); err != nil {
+		return nil, err
+	}
+	if string(magic[:]) != "fLaC" {
+		return nil, errors.New("not a FLAC file")
+	}
+	off += 4
+
+	info := &audioInfo{Codec: "flac", Tags: map[string]string{}}
+	var totalSamples uint64
+	haveStreamInfo := false
+	for {
+		var bh [4]byte
+		if _, err := r.ReadAt(bh[:], off); err != nil {
+			return nil, err
+		}
+		last := bh[0]&0x80 != 0
+		typ := bh[0] & 0x7F
+		n := int64(bh[1])<<16 | int64(bh[2])<<8 | int64(bh[3])
+		body := off + 4
+		switch typ {
+		case flacBlockStreamInfo:
+			if n < 34 {
+				return nil, errors.New("short STREAMINFO block")
+			}
+			var si [34]byte
+			if _, err := r.ReadAt(si[:], body); err != nil {
+				return nil, err
+			}
+			// 字节 10..17：采样率 20 位 | 声道数-1 3 位 | 位深-1 5 位 | 总采样数 36 位
+			v := binary.BigEndian.Uint64(si[10:18])
+			info.SampleRate = int(v >> 44)
+			info.Channels = int((v>>41)&0x7) + 1
+			info.BitsPerSample = int((v>>36)&0x1F) + 1
+			totalSamples = v & 0xFFFFFFFFF
+			haveStreamInfo = true
+		case flacBlockVorbisComment:
+			if n <= 16<<20 {
+				buf := make([]byte, n)
+				if _, err := r.ReadAt(buf, body); err == nil {
+					parseVorbisComment(buf, info.Tags)
+				}
+			}
+		}
+		off = body + n
+		if last || off >= size {
+			break
+		}
+	}
+	if !haveStreamInfo {
+		return nil, errors.New("missing STREAMINFO block")
+	}
+	if info.SampleRate > 0 && totalSamples > 0 {
+		info.Duration = float64(totalSamples) / float64(info.SampleRate)
+		info.Bitrate = int(float64(size-off) * 8 / info.Duration)
+	}
+	return info, nil
+}
+
+// parseVorbisComment 小端长度前缀：vendor，条目数，各条目 "KEY=value"
+func parseVorbisComment(buf []byte, tags map[string]string) {
+	if len(buf) < 8 {
+		return
+	}
+	vendor := int(binary.LittleEndian.Uint32(buf[0:4]))
+	if 4+vendor+4 > len(buf) {
+		return
+	}
+	p := 4 + vendor
+	count := int(binary.LittleEndian.Uint32(buf[p : p+4]))
+	p += 4
+	for i := 0; i < count && p+4 <= len(buf); i++ {
+		n := int(binary.LittleEndian.Uint32(buf[p : p+4]))
+		p += 4
+		if n < 0 || p+n > len(buf) {
+			return
+		}
+		k, v, ok := strings.Cut(string(buf[p:p+n]), "=")
+		p += n
+		if !ok {
+			continue
+		}
+		if key, ok := vorbisTagKeys[strings.ToUpper(k)]; ok {
+			if _, seen := tags[key]; !seen {
+				tags[key] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ilstTags iTunes 元数据（moov/udta/meta/ilst）条目
+var ilstTags = map[string]string{
+	"\xa9nam": "title",
+	"\xa9ART": "artist",
+	"\xa9alb": "album",
+	"aART":    "album_artist",
+	"\xa9gen": "genre",
+	"\xa9day": "year",
+	"\xa9wrt": "composer",
+	"\xa9cmt": "comment",
+	"trkn":    "track",
+	"gnre":    "genre",
+}
+
+// maxMoovSize moov 读入内存解析；异常大小按损坏处理
+const maxMoovSize = 64 << 20
+
+type mp4Box struct {
+	typ  string
+	body []byte
+}
+
+// mp4Boxes 拆分一层 box（body 不含头）
+func mp4Boxes(buf []byte) []mp4Box {
+	var out []mp4Box
+	for len(buf) >= 8 {
+		n := int64(binary.BigEndian.Uint32(buf[0:4]))
+		typ := string(buf[4:8])
+		hdr := int64(8)
+		switch n {
+		case 0:
+			n = int64(len(buf))
+		case 1:
+			if len(buf) < 16 {
+				return out
+			}
+			n = int64(binary.BigEndian.Uint64(buf[8:16]))
+			hdr = 16
+		}
+		if n < hdr || n > int64(len(buf)) {
+			return out
+		}
+		out = append(out, mp4Box{typ: typ, body: buf[hdr:n]})
+		buf = buf[n:]
+	}
+	return out
+}
+
+func findBox(boxes []mp4Box, typ string) *mp4Box {
+	for i := range boxes {
+		if boxes[i].typ == typ {
+			return &boxes[i]
+		}
+	}
+	return nil
+}
+
+// findMoov 在文件顶层定位 moov（可能在 mdat 之后）
+func findMoov(r io.ReaderAt, size int64) ([]byte, error) {
+	for off := int64(0); off+8 <= size; {
+		var h [16]byte
+		if _, err := r.ReadAt(h[:8], off); err != nil {
+			return nil, err
+		}
+		n := int64(binary.BigEndian.Uint32(h[0:4]))
+		typ := string(h[4:8])
+		hdr := int64(8)
+		switch n {
+		case 0:
+			n = size - off
+		case 1:
+			if _, err := r.ReadAt(h[8:16], off+8); err != nil {
+				return nil, err
+			}
+			n = int64(binary.BigEndian.Uint64(h[8:16]))
+			hdr = 16
+		}
+		if n < hdr || off+n > size {
+			return nil, errors.New("invalid mp4 box")
+		}
+		if off == 0 && typ != "ftyp" {
+			return nil, errors.New("not an MP4 file")
+		}
+		if typ == "moov" {
+			if n-hdr > maxMoovSize {
+				return nil, errors.New("moov box too large")
+			}
+			buf := make([]byte, n-hdr)
+			if _, err := r.ReadAt(buf, off+hdr); err != nil {
+				return nil, err
+			}
+			return buf, nil
+		}
+		off += n
+	}
+	return nil, errors.New("moov box not found")
+}
+
+// fullBoxTimes mvhd / mdhd：version 0 为 32 位时间字段，version 1 为 64 位
+func fullBoxTimes(b []byte) (timescale uint32, duration uint64, ok bool) {
+	if len(b) < 4 {
+		return 0, 0, false
+	}
+	if b[0] == 1 {
+		if len(b) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(b[20:24]), binary.BigEndian.Uint64(b[24:32]), true
+	}
+	if len(b) < 20 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(b[12:16]), uint64(binary.BigEndian.Uint32(b[16:20])), true
+}
+
+func readM4A(r io.ReaderAt, size int64) (*audioInfo, error) {
+	moov, err := findMoov(r, size)
+	if err != nil {
+		return nil, err
+	}
+	boxes := mp4Boxes(moov)
+	info := &audioInfo{Tags: map[string]string{}}
+
+	if mvhd := findBox(boxes, "mvhd"); mvhd != nil {
+		if ts, d, ok := fullBoxTimes(mvhd.body); ok && ts > 0 {
+			info.Duration = float64(d) / float64(ts)
+		}
+	}
+
+	haveAudio := false
+	for _, trak := range boxes {
+		if trak.typ != "trak" {
+			continue
+		}
+		mdia := findBox(mp4Boxes(trak.body), "mdia")
+		if mdia == nil {
+			continue
+		}
+		mdiaBoxes := mp4Boxes(mdia.body)
+		hdlr := findBox(mdiaBoxes, "hdlr")
+		if hdlr == nil || len(hdlr.body) < 12 || string(hdlr.body[8:12]) != "soun" {
+			continue
+		}
+		if mdhd := findBox(mdiaBoxes, "mdhd"); mdhd != nil {
+			if ts, d, ok := fullBoxTimes(mdhd.body); ok && ts > 0 && d > 0 {
+				info.Duration = float64(d) / float64(ts)
+			}
+		}
+		minf := findBox(mdiaBoxes, "minf")
+		if minf == nil {
+			continue
+		}
+		stbl := findBox(mp4Boxes(minf.body), "stbl")
+		if stbl == nil {
+			continue
+		}
+		stsd := findBox(mp4Boxes(stbl.body), "stsd")
+		if stsd == nil || len(stsd.body) < 8 {
+			continue
+		}
+		// stsd：version/flags 4 + 条目数 4，之后是音频 sample entry
+		entries := mp4Boxes(stsd.body[8:])
+		if len(entries) == 0 {
+			continue
+		}
+		e := entries[0]
+		info.Codec = mp4AudioCodec(e.typ)
+		if len(e.body) >= 28 {
+			info.Channels = int(binary.BigEndian.Uint16(e.body[16:18]))
+			info.BitsPerSample = int(binary.BigEndian.Uint16(e.body[18:20]))
+			info.SampleRate = int(binary.BigEndian.Uint32(e.body[24:28]) >> 16)
+		}
+		if info.Codec == "aac" {
+			// AAC 的 sample size 字段固定为 16，并非真实位深
+			info.BitsPerSample = 0
+		}
+		haveAudio = true
+		break
+	}
+	if !haveAudio {
+		return nil, errors.New("no audio track")
+	}
+
+	if udta := findBox(boxes, "udta"); udta != nil {
+		if meta := findBox(mp4Boxes(udta.body), "meta"); meta != nil {
+			body := meta.body
+			// ISO 的 meta 是 full box；QuickTime 写法没有 version/flags
+			if len(body) >= 8 && string(body[4:8]) != "hdlr" {
+				body = body[4:]
+			}
+			if ilst := findBox(mp4Boxes(body), "ilst"); ilst != nil {
+				parseILST(ilst.body, info.Tags)
+			}
+		}
+	}
+	return info, nil
+}
+
+func mp4AudioCodec(fourcc string) string {
+	switch fourcc {
+	case "mp4a":
+		return "aac"
+	case "alac":
+		return "alac"
+	case "ac-3":
+		return "ac3"
+	case "ec-3":
+		return "eac3"
+	case "Opus":
+		return "opus"
+	case "fLaC":
+		return "flac"
+	default:
+		return strings.TrimSpace(fourcc)
+	}
+}
+
+// parseILST 每个条目内含 data box：类型 4 字节 + locale 4 字节 + 值
+func parseILST(buf []byte, tags map[string]string) {
+	for _, item := range mp4Boxes(buf) {
+		key, ok := ilstTags[item.typ]
+		if !ok {
+			continue
+		}
+		data := findBox(mp4Boxes(item.body), "data")
+		if data == nil || len(data.body) < 8 {
+			continue
+		}
+		val := data.body[8:]
+		var v string
+		switch item.typ {
+		case "trkn":
+			if len(val) >= 4 {
+				v = strconv.Itoa(int(binary.BigEndian.Uint16(val[2:4])))
+			}
+		case "gnre":
+			if len(val) >= 2 {
+				if g := int(binary.BigEndian.Uint16(val[0:2])) - 1; g >= 0 && g < len(id3v1Genres) {
+					v = id3v1Genres[g]
+				}
+			}
+		default:
+			v = string(val)
+		}
+		if v != "" && v != "0" {
+			if _, seen := tags[key]; !seen {
+				tags[key] = v
+			}
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// id3FrameTags ID3v2.3/2.4 与 v2.2（三字符）帧到标签键的映射
+var id3FrameTags = map[string]string{
+	"TIT2": "title", "TT2": "title",
+	"TPE1": "artist", "TP1": "artist",
+	"TALB": "album", "TAL": "album",
+	"TPE2": "album_artist", "TP2": "album_artist",
+	"TCON": "genre", "TCO": "genre",
+	"TYER": "year", "TYE": "year", "TDRC": "year",
+	"TRCK": "track", "TRK": "track",
+	"TCOM": "composer", "TCM": "composer",
+	"COMM": "comment", "COM": "comment",
+}
+
+// id3v2Size 返回文件开头 ID3v2 标签的总长度（含头与 footer），没有时为 0
+func id3v2Size(hdr []byte) int64 {
+	if len(hdr) < 10 || string(hdr[0:3]) != "ID3" {
+		return 0
+	}
+	n := int64(syncsafe(hdr[6:10])) + 10
+	if hdr[5]&0x10 != 0 {
+		n += 10
+	}
+	return n
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7F)<<21 | uint32(b[1]&0x7F)<<14 | uint32(b[2]&0x7F)<<7 | uint32(b[3]&0x7F)
+}
+
+// parseID3v2 解析完整的 ID3v2 标签（buf 从 "ID3" 开始），返回标签与标签总长度
+func parseID3v2(buf []byte) (map[string]string, int64, error) {
+	size := id3v2Size(buf)
+	if size == 0 {
+		return nil, 0, errors.New("no id3v2 header")
+	}
+	major := buf[3]
+	flags := buf[5]
+	end := int(min(size, int64(len(buf))))
+	body := buf[10:end]
+	if flags&0x80 != 0 && major < 4 {
+		// v2.3 及更早的整体反同步
+		body = bytes.ReplaceAll(body, []byte{0xFF, 0x00}, []byte{0xFF})
+	}
+	if flags&0x40 != 0 && len(body) >= 4 {
+		// 跳过扩展头
+		var ext int
+		if major >= 4 {
+			ext = int(syncsafe(body[0:4]))
+		} else {
+			ext = int(binary.BigEndian.Uint32(body[0:4])) + 4
+		}
+		if ext > len(body) {
+			return nil, size, errors.New("invalid id3v2 extended header")
+		}
+		body = body[ext:]
+	}
+
+	tags := map[string]string{}
+	idLen, hdrLen := 4, 10
+	if major == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	for len(body) >= hdrLen && body[0] != 0 {
+		id := string(body[0:idLen])
+		var n int
+		switch {
+		case major == 2:
+			n = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+		case major >= 4:
+			n = int(syncsafe(body[4:8]))
+		default:
+			n = int(binary.BigEndian.Uint32(body[4:8]))
+		}
+		if n <= 0 || hdrLen+n > len(body) {
+			break
+		}
+		data := body[hdrLen : hdrLen+n]
+		if key, ok := id3FrameTags[id]; ok {
+			if _, seen := tags[key]; !seen {
+				var v string
+				if key == "comment" {
+					v = decodeID3Comment(data)
+				} else {
+					v = decodeID3Text(data)
+				}
+				if key == "genre" {
+					v = normalizeID3Genre(v)
+				}
+				if v != "" {
+					tags[key] = v
+				}
+			}
+		}
+		body = body[hdrLen+n:]
+	}
+	return tags, size, nil
+}
+
+// decodeID3Text 文本帧：首字节为编码，多值以 NUL 分隔时取第一个
+func decodeID3Text(data []byte) string {
+	if len(data) < 1 {
+		return ""
+	}
+	s := decodeID3String(data[0], data[1:])
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSpace(s)
+}
+
+// decodeID3Comment COMM：编码 + 3 字节语言 + 描述（NUL 结尾）+ 正文
+func decodeID3Comment(data []byte) string {
+	if len(data) < 4 {
+		return ""
+	}
+	s := decodeID3String(data[0], data[4:])
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[i+1:]
+	}
+	return strings.TrimSpace(strings.TrimRight(s, "\x00"))
+}
+
+func decodeID3String(enc byte, b []byte) string {
+	switch enc {
+	case 1, 2:
+		bigEndian := enc == 2
+		if len(b) >= 2 {
+			if b[0] == 0xFF && b[1] == 0xFE {
+				bigEndian, b = false, b[2:]
+			} else if b[0] == 0xFE && b[1] == 0xFF {
+				bigEndian, b = true, b[2:]
+			}
+		}
+		u := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			if bigEndian {
+				u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+			} else {
+				u = append(u, uint16(b[i+1])<<8|uint16(b[i]))
+			}
+		}
+		return string(utf16.Decode(u))
+	case 3:
+		return string(b)
+	default:
+		return latin1(b)
+	}
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// normalizeID3Genre 处理 "(17)" / "17" 形式的 ID3v1 流派编号
+func normalizeID3Genre(v string) string {
+	ref := v
+	if strings.HasPrefix(v, "(") {
+		if i := strings.IndexByte(v, ')'); i > 0 {
+			if rest := strings.TrimSpace(v[i+1:]); rest != "" {
+				return rest
+			}
+			ref = v[1:i]
+		}
+	}
+	if n, err := strconv.Atoi(ref); err == nil && n >= 0 && n < len(id3v1Genres) {
+		return id3v1Genres[n]
+	}
+	return v
+}
+
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop",
+	"Jazz", "Metal", "New Age", "Oldies", "Other", "Pop", "R&B", "Rap",
+	"Reggae", "Rock", "Techno", "Industrial", "Alternative", "Ska", "Death Metal", "Pranks",
+	"Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk", "Fusion", "Trance",
+	"Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock",
+	"Ethnic", "Gothic", "Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream",
+	"Southern Rock", "Comedy", "Cult", "Gangsta", "Top 40", "Christian Rap", "Pop/Funk", "Jungle",
+	"Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes", "Trailer", "Lo-Fi",
+	"Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// parseID3v1 文件末尾 128 字节的 ID3v1 标签
+func parseID3v1(b []byte) map[string]string {
+	if len(b) != 128 || string(b[0:3]) != "TAG" {
+		return nil
+	}
+	field := func(s []byte) string {
+		if i := bytes.IndexByte(s, 0); i >= 0 {
+			s = s[:i]
+		}
+		return strings.TrimSpace(latin1(s))
+	}
+	tags := map[string]string{
+		"title":   field(b[3:33]),
+		"artist":  field(b[33:63]),
+		"album":   field(b[63:93]),
+		"year":    field(b[93:97]),
+		"comment": field(b[97:127]),
+	}
+	// ID3v1.1：注释第 29 字节为 0 时第 30 字节是音轨号
+	if b[125] == 0 && b[126] != 0 {
+		tags["comment"] = field(b[97:125])
+		tags["track"] = strconv.Itoa(int(b[126]))
+	}
+	if g := int(b[127]); g < len(id3v1Genres) {
+		tags["genre"] = id3v1Genres[g]
+	}
+	return tags
+}
+
+// mpegFrame MPEG 音频帧头
+type mpegFrame struct {
+	Version    int // 1, 2, 25（2.5）
+	Layer      int
+	Bitrate    int // kbit/s
+	SampleRate int
+	Padding    int
+	Channels   int
+}
+
+var mpegBitrates = map[[2]int][16]int{
+	{1, 1}: {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+	{1, 2}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+	{1, 3}: {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	{2, 1}: {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+	{2, 2}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	{2, 3}: {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+}
+
+var mpegSampleRates = map[int][3]int{
+	1:  {44100, 48000, 32000},
+	2:  {22050, 24000, 16000},
+	25: {11025, 12000, 8000},
+}
+
+func parseMPEGHeader(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mpegFrame{}, false
+	}
+	var f mpegFrame
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		f.Version = 25
+	case 2:
+		f.Version = 2
+	case 3:
+		f.Version = 1
+	default:
+		return f, false
+	}
+	switch (b[1] >> 1) & 0x03 {
+	case 1:
+		f.Layer = 3
+	case 2:
+		f.Layer = 2
+	case 3:
+		f.Layer = 1
+	default:
+		return f, false
+	}
+	bi := int(b[2] >> 4)
+	si := int((b[2] >> 2) & 0x03)
+	if bi == 0 || bi == 15 || si == 3 {
+		return f, false
+	}
+	tableVer := f.Version
+	if tableVer == 25 {
+		tableVer = 2
+	}
+	f.Bitrate = mpegBitrates[[2]int{tableVer, f.Layer}][bi]
+	f.SampleRate = mpegSampleRates[f.Version][si]
+	f.Padding = int((b[2] >> 1) & 0x01)
+	f.Channels = 2
+	if (b[3]>>6)&0x03 == 3 {
+		f.Channels = 1
+	}
+	return f, true
+}
+
+func (f mpegFrame) samplesPerFrame() int {
+	switch {
+	case f.Layer == 1:
+		return 384
+	case f.Layer == 2 || f.Version == 1:
+		return 1152
+	default:
+		return 576
+	}
+}
+
+func (f mpegFrame) length() int {
+	if f.Layer == 1 {
+		return (12*f.Bitrate*1000/f.SampleRate + f.Padding) * 4
+	}
+	return f.samplesPerFrame()/8*f.Bitrate*1000/f.SampleRate + f.Padding
+}
+
+// sideInfoSize Xing 头位于帧头 + side info 之后
+func (f mpegFrame) sideInfoSize() int {
+	if f.Version == 1 {
+		if f.Channels == 1 {
+			return 17
+		}
+		return 32
+	}
+	if f.Channels == 1 {
+		return 9
+	}
+	return 17
+}
+
+const mp3SyncSearch = 256 << 10
+
+func readMP3(r io.ReaderAt, size int64) (*audioInfo, error) {
+	info := &audioInfo{Codec: "mp3", Tags: map[string]string{}}
+
+	var hdr [10]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	start := id3v2Size(hdr[:])
+	if start > 0 && start <= 16<<20 && start <= size {
+		buf := make([]byte, start)
+		if _, err := r.ReadAt(buf, 0); err == nil {
+			if tags, _, err := parseID3v2(buf); err == nil {
+				info.Tags = tags
+			}
+		}
+	}
+	end := size
+	if size >= 128 {
+		tail := make([]byte, 128)
+		if _, err := r.ReadAt(tail, size-128); err == nil {
+			if tags := parseID3v1(tail); tags != nil {
+				mergeTags(info.Tags, tags)
+				end -= 128
+			}
+		}
+	}
+
+	// 找第一个有效帧：下一帧头也需有效，避免把数据中的 0xFFE 误认为同步字
+	buf := make([]byte, min(int64(mp3SyncSearch), max(end-start, 0)))
+	n, _ := r.ReadAt(buf, start)
+	buf = buf[:n]
+	pos := -1
+	var frame mpegFrame
+	for i := 0; i+4 <= len(buf); i++ {
+		f, ok := parseMPEGHeader(buf[i:])
+		if !ok {
+			continue
+		}
+		next := i + f.length()
+		if next+4 <= len(buf) {
+			if _, ok := parseMPEGHeader(buf[next:]); !ok {
+				continue
+			}
+		}
+		pos, frame = i, f
+		break
+	}
+	if pos < 0 {
+		return nil, errors.New("no mpeg audio frame found")
+	}
+	switch frame.Layer {
+	case 1:
+		info.Codec = "mp1"
+	case 2:
+		info.Codec = "mp2"
+	}
+	info.SampleRate = frame.SampleRate
+	info.Channels = frame.Channels
+
+	audioBytes := end - start - int64(pos)
+	// VBR：Xing / Info 头或 VBRI 头给出总帧数
+	frameBuf := buf[pos:]
+	var frames, bytesTotal int64
+	if x := 4 + frame.sideInfoSize(); x+16 <= len(frameBuf) {
+		if tag := string(frameBuf[x : x+4]); tag == "Xing" || tag == "Info" {
+			flags := binary.BigEndian.Uint32(frameBuf[x+4 : x+8])
+			p := x + 8
+			if flags&0x1 != 0 {
+				frames = int64(binary.BigEndian.Uint32(frameBuf[p : p+4]))
+				p += 4
+			}
+			if flags&0x2 != 0 && p+4 <= len(frameBuf) {
+				bytesTotal = int64(binary.BigEndian.Uint32(frameBuf[p : p+4]))
+			}
+		}
+	}
+	if frames == 0 && 36+18 <= len(frameBuf) && string(frameBuf[36:40]) == "VBRI" {
+		bytesTotal = int64(binary.BigEndian.Uint32(frameBuf[46:50]))
+		frames = int64(binary.BigEndian.Uint32(frameBuf[50:54]))
+	}
+	if frames > 0 {
+		info.Duration = float64(frames) * float64(frame.samplesPerFrame()) / float64(frame.SampleRate)
+		if bytesTotal <= 0 {
+			bytesTotal = audioBytes
+		}
+		if info.Duration > 0 {
+			info.Bitrate = int(float64(bytesTotal) * 8 / info.Duration)
+		}
+		return info, nil
+	}
+	// CBR：按首帧码率估算
+	info.Bitrate = frame.Bitrate * 1000
+	if info.Bitrate > 0 {
+		info.Duration = float64(audioBytes) * 8 / float64(info.Bitrate)
+	}
+	return info, nil
+}
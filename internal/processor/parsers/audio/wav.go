@@ -0,0 +1,265 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+// wavLayout fmt 与 data 块的位置，波形计算直接按此读取 PCM
+type wavLayout struct {
+	Format        uint16
+	Channels      int
+	SampleRate    int
+	ByteRate      int
+	BlockAlign    int
+	BitsPerSample int
+	DataOffset    int64
+	DataSize      int64
+	Tags          map[string]string
+}
+
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavInfoTags RIFF LIST/INFO 子块
+var wavInfoTags = map[string]string{
+	"INAM": "title",
+	"IART": "artist",
+	"IPRD": "album",
+	"IGNR": "genre",
+	"ICRD": "year",
+	"ITRK": "track",
+	"ICMT": "comment",
+	"IMUS": "composer",
+}
+
+func readWAVLayout(r io.ReaderAt, size int64) (*wavLayout, error) {
+	var hdr [12]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	if string(hdr[0:4]) != "RIFF" || string(hdr[8:12]) != "WAVE" {
+		return nil, errors.New("not a RIFF/WAVE file")
+	}
+	l := &wavLayout{Tags: map[string]string{}}
+	haveFmt := false
+	for off := int64(12); off+8 <= size; {
+		var ch [8]byte
+		if _, err := r.ReadAt(ch[:], off); err != nil {
+			return nil, err
+		}
+		id := string(ch[0:4])
+		n := int64(binary.LittleEndian.Uint32(ch[4:8]))
+		body := off + 8
+		switch id {
+		case "fmt ":
+			var f [16]byte
+			if n < 16 {
+				return nil, errors.New("short fmt chunk")
+			}
+			if _, err := r.ReadAt(f[:], body); err != nil {
+				return nil, err
+			}
+			l.Format = binary.LittleEndian.Uint16(f[0:2])
+			l.Channels = int(binary.LittleEndian.Uint16(f[2:4]))
+			l.SampleRate = int(binary.LittleEndian.Uint32(f[4:8]))
+			l.ByteRate = int(binary.LittleEndian.Uint32(f[8:12]))
+			l.BlockAlign = int(binary.LittleEndian.Uint16(f[12:14]))
+			l.BitsPerSample = int(binary.LittleEndian.Uint16(f[14:16]))
+			if l.Format == wavFormatExtensible && n >= 26 {
+				// WAVE_FORMAT_EXTENSIBLE：真实格式在 SubFormat GUID 的前两个字节
+				var sub [2]byte
+				if _, err := r.ReadAt(sub[:], body+24); err == nil {
+					l.Format = binary.LittleEndian.Uint16(sub[:])
+				}
+			}
+			haveFmt = true
+		case "data":
+			l.DataOffset = body
+			l.DataSize = n
+			// 流式写入的文件 data 大小可能为 0 或超出文件
+			if l.DataSize == 0 || body+l.DataSize > size {
+				l.DataSize = size - body
+			}
+		case "LIST":
+			if n >= 4 && n <= 1<<20 {
+				buf := make([]byte, n)
+				if _, err := r.ReadAt(buf, body); err == nil && string(buf[0:4]) == "INFO" {
+					parseRIFFInfo(buf[4:], l.Tags)
+				}
+			}
+		case "id3 ", "ID3 ":
+			if n <= 16<<20 {
+				buf := make([]byte, n)
+				if _, err := r.ReadAt(buf, body); err == nil {
+					if tags, _, err := parseID3v2(buf); err == nil {
+						mergeTags(l.Tags, tags)
+					}
+				}
+			}
+		}
+		off = body + n + n%2
+	}
+	if !haveFmt || l.DataOffset == 0 {
+		return nil, errors.New("missing fmt or data chunk")
+	}
+	return l, nil
+}
+
+func parseRIFFInfo(buf []byte, tags map[string]string) {
+	for len(buf) >= 8 {
+		id := string(buf[0:4])
+		n := int(binary.LittleEndian.Uint32(buf[4:8]))
+		if 8+n > len(buf) {
+			return
+		}
+		if key, ok := wavInfoTags[id]; ok {
+			tags[key] = strings.TrimRight(string(buf[8:8+n]), "\x00 ")
+		}
+		n += n % 2
+		if 8+n > len(buf) {
+			return
+		}
+		buf = buf[8+n:]
+	}
+}
+
+func readWAV(r io.ReaderAt, size int64) (*audioInfo, error) {
+	l, err := readWAVLayout(r, size)
+	if err != nil {
+		return nil, err
+	}
+	info := &audioInfo{
+		Codec:         wavCodec(l.Format, l.BitsPerSample),
+		SampleRate:    l.SampleRate,
+		Channels:      l.Channels,
+		BitsPerSample: l.BitsPerSample,
+		Bitrate:       l.ByteRate * 8,
+		Tags:          l.Tags,
+	}
+	if l.ByteRate > 0 {
+		info.Duration = float64(l.DataSize) / float64(l.ByteRate)
+	}
+	return info, nil
+}
+
+func wavCodec(format uint16, bits int) string {
+	switch format {
+	case wavFormatPCM:
+		if bits == 8 {
+			return "pcm_u8"
+		}
+		return fmt.Sprintf("pcm_s%dle", bits)
+	case wavFormatFloat:
+		return fmt.Sprintf("pcm_f%dle", bits)
+	case 0x0006:
+		return "pcm_alaw"
+	case 0x0007:
+		return "pcm_mulaw"
+	case 0x0055:
+		return "mp3"
+	default:
+		return fmt.Sprintf("wav_0x%04x", format)
+	}
+}
+
+// readAIFF 解析 AIFF / AIFF-C：COMM 块给出声道、帧数、位深与 80 位扩展精度采样率（大端）
+func readAIFF(r io.ReaderAt, size int64) (*audioInfo, error) {
+	var hdr [12]byte
+	if _, err := r.ReadAt(hdr[:], 0); err != nil {
+		return nil, err
+	}
+	form := string(hdr[8:12])
+	if string(hdr[0:4]) != "FORM" || (form != "AIFF" && form != "AIFC") {
+		return nil, errors.New("not an AIFF file")
+	}
+	info := &audioInfo{Codec: "pcm_s16be", Tags: map[string]string{}}
+	var frames uint32
+	haveComm := false
+	for off := int64(12); off+8 <= size; {
+		var ch [8]byte
+		if _, err := r.ReadAt(ch[:], off); err != nil {
+			return nil, err
+		}
+		id := string(ch[0:4])
+		n := int64(binary.BigEndian.Uint32(ch[4:8]))
+		body := off + 8
+		switch id {
+		case "COMM":
+			var c [22]byte
+			if n < 18 {
+				return nil, errors.New("short COMM chunk")
+			}
+			if _, err := r.ReadAt(c[:min(int(n), 22)], body); err != nil {
+				return nil, err
+			}
+			info.Channels = int(binary.BigEndian.Uint16(c[0:2]))
+			frames = binary.BigEndian.Uint32(c[2:6])
+			info.BitsPerSample = int(binary.BigEndian.Uint16(c[6:8]))
+			info.SampleRate = int(extendedToFloat(c[8:18]))
+			info.Codec = fmt.Sprintf("pcm_s%dbe", info.BitsPerSample)
+			if form == "AIFC" && n >= 22 {
+				if ct := strings.ToLower(strings.TrimSpace(string(c[18:22]))); ct != "none" {
+					info.Codec = ct
+				}
+			}
+			haveComm = true
+		case "NAME", "AUTH", "(c) ", "ANNO":
+			if n <= 1<<16 {
+				buf := make([]byte, n)
+				if _, err := r.ReadAt(buf, body); err == nil {
+					key := map[string]string{"NAME": "title", "AUTH": "artist", "(c) ": "copyright", "ANNO": "comment"}[id]
+					info.Tags[key] = strings.TrimRight(string(buf), "\x00 ")
+				}
+			}
+		case "ID3 ", "id3 ":
+			if n <= 16<<20 {
+				buf := make([]byte, n)
+				if _, err := r.ReadAt(buf, body); err == nil {
+					if tags, _, err := parseID3v2(buf); err == nil {
+						mergeTags(info.Tags, tags)
+					}
+				}
+			}
+		}
+		off = body + n + n%2
+	}
+	if !haveComm {
+		return nil, errors.New("missing COMM chunk")
+	}
+	if info.SampleRate > 0 {
+		info.Duration = float64(frames) / float64(info.SampleRate)
+		info.Bitrate = info.SampleRate * info.Channels * info.BitsPerSample
+	}
+	return info, nil
+}
+
+// extendedToFloat IEEE 754 80 位扩展精度（AIFF 采样率）
+func extendedToFloat(b []byte) float64 {
+	exp := int(binary.BigEndian.Uint16(b[0:2]) & 0x7FFF)
+	mant := binary.BigEndian.Uint64(b[2:10])
+	if exp == 0 && mant == 0 {
+		return 0
+	}
+	v := float64(mant) * math.Pow(2, float64(exp-16383-63))
+	if b[0]&0x80 != 0 {
+		v = -v
+	}
+	return v
+}
+
+// mergeTags 只补充缺失的键，容器自身的标签优先
+func mergeTags(dst, src map[string]string) {
+	for k, v := range src {
+		if _, ok := dst[k]; !ok && strings.TrimSpace(v) != "" {
+			dst[k] = v
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// waveformDecodeRate ffmpeg 解码波形时重采样到的采样率，峰值精度足够且解码开销小
+const waveformDecodeRate = 8000
+
+// Peaks 单声道峰值：每 SamplesPerPeak 个采样一对 (min, max)，取值 [-1, 1]
+type Peaks struct {
+	SampleRate     int
+	SamplesPerPeak int
+	Min            []float32
+	Max            []float32
+}
+
+// ComputePeaks 计算音频的峰值序列，每秒约 peaksPerSecond 对。
+// PCM WAV 直接读取，其余格式经 ffmpeg 解码为单声道 s16le
+func ComputePeaks(ctx context.Context, path string, peaksPerSecond int) (*Peaks, error) {
+	if peaksPerSecond <= 0 {
+		peaksPerSecond = 100
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	if ext == "wav" || ext == "bwf" {
+		peaks, err := wavPeaks(ctx, path, peaksPerSecond)
+		if err == nil {
+			return peaks, nil
+		}
+		if !errors.Is(err, errUnsupported) {
+			return nil, err
+		}
+	}
+	return ffmpegPeaks(ctx, path, peaksPerSecond)
+}
+
+type peakAccumulator struct {
+	peaks    *Peaks
+	n        int
+	min, max float32
+}
+
+func newPeakAccumulator(sampleRate, peaksPerSecond int) *peakAccumulator {
+	spp := sampleRate / peaksPerSecond
+	if spp < 1 {
+		spp = 1
+	}
+	return &peakAccumulator{peaks: &Peaks{SampleRate: sampleRate, SamplesPerPeak: spp}}
+}
+
+func (a *peakAccumulator) add(v float32) {
+	if a.n == 0 || v < a.min {
+		a.min = v
+	}
+	if a.n == 0 || v > a.max {
+		a.max = v
+	}
+	a.n++
+	if a.n == a.peaks.SamplesPerPeak {
+		a.flush()
+	}
+}
+
+func (a *peakAccumulator) flush() {
+	if a.n == 0 {
+		return
+	}
+	a.peaks.Min = append(a.peaks.Min, a.min)
+	a.peaks.Max = append(a.peaks.Max, a.max)
+	a.n = 0
+}
+
+func (a *peakAccumulator) result() *Peaks {
+	a.flush()
+	return a.peaks
+}
+
+func wavPeaks(ctx context.Context, path string, peaksPerSecond int) (*Peaks, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	l, err := readWAVLayout(f, st.Size())
+	if err != nil {
+		return nil, err
+	}
+	bytesPerSample := l.BitsPerSample / 8
+	if l.Channels <= 0 || l.SampleRate <= 0 || bytesPerSample <= 0 || l.BlockAlign != bytesPerSample*l.Channels {
+		return nil, errUnsupported
+	}
+	var sample func(b []byte) float32
+	switch {
+	case l.Format == wavFormatPCM && bytesPerSample == 1:
+		sample = func(b []byte) float32 { return (float32(b[0]) - 128) / 128 }
+	case l.Format == wavFormatPCM && bytesPerSample == 2:
+		sample = func(b []byte) float32 { return float32(int16(binary.LittleEndian.Uint16(b))) / 32768 }
+	case l.Format == wavFormatPCM && bytesPerSample == 3:
+		sample = func(b []byte) float32 {
+			v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+			return float32(v) / 8388608
+		}
+	case l.Format == wavFormatPCM && bytesPerSample == 4:
+		sample = func(b []byte) float32 { return float32(int32(binary.LittleEndian.Uint32(b))) / 2147483648 }
+	case l.Format == wavFormatFloat && bytesPerSample == 4:
+		sample = func(b []byte) float32 { return math.Float32frombits(binary.LittleEndian.Uint32(b)) }
+	default:
+		return nil, errUnsupported
+	}
+
+	acc := newPeakAccumulator(l.SampleRate, peaksPerSecond)
+	r := bufio.NewReaderSize(io.NewSectionReader(f, l.DataOffset, l.DataSize), 256<<10)
+	frame := make([]byte, l.BlockAlign)
+	for i := 0; ; i++ {
+		if i%(1<<16) == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if _, err := io.ReadFull(r, frame); err != nil {
+			break
+		}
+		// 多声道取绝对值最大的声道，保留峰值
+		var v float32
+		for c := 0; c < l.Channels; c++ {
+			s := sample(frame[c*bytesPerSample:])
+			if abs32(s) > abs32(v) {
+				v = s
+			}
+		}
+		acc.add(v)
+	}
+	return acc.result(), nil
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func ffmpegPeaks(ctx context.Context, path string, peaksPerSecond int) (*Peaks, error) {
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, errors.New("ffmpeg not found")
+	}
+	cmd := exec.CommandContext(ctx,
+		ffmpegPath,
+		"-hide_banner", "-loglevel", "error",
+		"-i", path,
+		"-vn", "-sn",
+		"-ac", "1",
+		"-ar", strconv.Itoa(waveformDecodeRate),
+		"-f", "s16le",
+		"pipe:1",
+	)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	acc := newPeakAccumulator(waveformDecodeRate, peaksPerSecond)
+	r := bufio.NewReaderSize(out, 256<<10)
+	var b [2]byte
+	for {
+		if _, err := io.ReadFull(r, b[:]); err != nil {
+			break
+		}
+		acc.add(float32(int16(binary.LittleEndian.Uint16(b[:]))) / 32768)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, err
+	}
+	peaks := acc.result()
+	if len(peaks.Max) == 0 {
+		return nil, errors.New("no audio decoded")
+	}
+	return peaks, nil
+}
@@ -108,5 +108,21 @@ func (p *Parser) Parse(ctx context.Context, path string) (*processor.Result, err
 	return res, nil
 }
 
+// RenderSource 直接解码原图，缩放交给缩略图管线
+func (p *Parser) RenderSource(ctx context.Context, path string, maxEdge int) (image.Image, error) {
+	_ = ctx
+	_ = maxEdge
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	return img, err
+}
+
 // Ensure interface is implemented
-var _ processor.Parser = (*Parser)(nil)
+var (
+	_ processor.Parser          = (*Parser)(nil)
+	_ processor.ThumbnailSource = (*Parser)(nil)
+)
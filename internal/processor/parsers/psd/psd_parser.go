@@ -1,8 +1,11 @@
 package psd
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"image"
+	_ "image/jpeg"
 	"os"
 	"os/exec"
 	"strconv"
@@ -41,7 +44,7 @@ func (p *Parser) Parse(ctx context.Context, path string) (*processor.Result, err
 		res.Metadata.Extra["size_error"] = err.Error()
 	}
 
-	if thumb, err := renderThumbnail(ctx, path); err == nil {
+	if thumb, err := renderThumbnail(ctx, path, 300); err == nil {
 		res.Thumbnail = thumb
 	} else {
 		res.Metadata.Extra["thumbnail_error"] = err.Error()
@@ -92,7 +95,19 @@ func readImageSize(ctx context.Context, path string) (int, int, error) {
 	return 0, 0, errors.New("no available tool to read PSD size")
 }
 
-func renderThumbnail(ctx context.Context, path string) ([]byte, error) {
+// RenderSource 渲染合成图，长边不超过 maxEdge
+func (p *Parser) RenderSource(ctx context.Context, path string, maxEdge int) (image.Image, error) {
+	data, err := renderThumbnail(ctx, path, maxEdge)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+func renderThumbnail(ctx context.Context, path string, size int) ([]byte, error) {
+	sizeArg := strconv.Itoa(size)
+	box := sizeArg + "x" + sizeArg
 	tmpFile, err := os.CreateTemp("", "psd-thumb-*.jpg")
 	if err != nil {
 		return nil, err
@@ -102,21 +117,21 @@ func renderThumbnail(ctx context.Context, path string) ([]byte, error) {
 	defer os.Remove(tmpPath)
 
 	if sipsPath, err := exec.LookPath("sips"); err == nil {
-		cmd := exec.CommandContext(ctx, sipsPath, "-s", "format", "jpeg", "-Z", "300", path, "--out", tmpPath)
+		cmd := exec.CommandContext(ctx, sipsPath, "-s", "format", "jpeg", "-Z", sizeArg, path, "--out", tmpPath)
 		if err := cmd.Run(); err == nil {
 			return os.ReadFile(tmpPath)
 		}
 	}
 
 	if magickPath, err := exec.LookPath("magick"); err == nil {
-		cmd := exec.CommandContext(ctx, magickPath, path+"[0]", "-thumbnail", "300x300", "jpeg:"+tmpPath)
+		cmd := exec.CommandContext(ctx, magickPath, path+"[0]", "-thumbnail", box, "jpeg:"+tmpPath)
 		if err := cmd.Run(); err == nil {
 			return os.ReadFile(tmpPath)
 		}
 	}
 
 	if convertPath, err := exec.LookPath("convert"); err == nil {
-		cmd := exec.CommandContext(ctx, convertPath, path+"[0]", "-thumbnail", "300x300", "jpeg:"+tmpPath)
+		cmd := exec.CommandContext(ctx, convertPath, path+"[0]", "-thumbnail", box, "jpeg:"+tmpPath)
 		if err := cmd.Run(); err == nil {
 			return os.ReadFile(tmpPath)
 		}
@@ -149,4 +164,7 @@ func parseSipsSize(out string) (int, int, error) {
 	return 0, 0, errors.New("failed to parse size from sips output")
 }
 
-var _ processor.Parser = (*Parser)(nil)
+var (
+	_ processor.Parser          = (*Parser)(nil)
+	_ processor.ThumbnailSource = (*Parser)(nil)
+)
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"image"
+	_ "image/jpeg"
 	"os"
 	"os/exec"
 	"strconv"
@@ -55,7 +57,7 @@ func (p *Parser) Parse(ctx context.Context, path string) (*processor.Result, err
 		res.Metadata.Extra["size_error"] = err.Error()
 	}
 
-	if thumb, err := renderRawThumbnail(ctx, path); err == nil {
+	if thumb, err := renderRawThumbnail(ctx, path, 300); err == nil {
 		res.Thumbnail = thumb
 	} else {
 		res.Metadata.Extra["thumbnail_error"] = err.Error()
@@ -84,7 +86,17 @@ func readRawSize(ctx context.Context, path string) (int, int, error) {
 	return 0, 0, errors.New("no available tool to read RAW size")
 }
 
-func renderRawThumbnail(ctx context.Context, path string) ([]byte, error) {
+// RenderSource 渲染 RAW 预览（sips 按 maxEdge 缩放；dcraw 取内嵌预览图）
+func (p *Parser) RenderSource(ctx context.Context, path string, maxEdge int) (image.Image, error) {
+	data, err := renderRawThumbnail(ctx, path, maxEdge)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+func renderRawThumbnail(ctx context.Context, path string, size int) ([]byte, error) {
 	tmpFile, err := os.CreateTemp("", "raw-thumb-*.jpg")
 	if err != nil {
 		return nil, err
@@ -94,7 +106,7 @@ func renderRawThumbnail(ctx context.Context, path string) ([]byte, error) {
 	defer os.Remove(tmpPath)
 
 	if sipsPath, err := exec.LookPath("sips"); err == nil {
-		cmd := exec.CommandContext(ctx, sipsPath, "-s", "format", "jpeg", "-Z", "300", path, "--out", tmpPath)
+		cmd := exec.CommandContext(ctx, sipsPath, "-s", "format", "jpeg", "-Z", strconv.Itoa(size), path, "--out", tmpPath)
 		if err := cmd.Run(); err == nil {
 			return os.ReadFile(tmpPath)
 		}
@@ -155,4 +167,7 @@ func parseDcrawSize(out string) (int, int, error) {
 	return 0, 0, errors.New("failed to parse size from dcraw output")
 }
 
-var _ processor.Parser = (*Parser)(nil)
+var (
+	_ processor.Parser          = (*Parser)(nil)
+	_ processor.ThumbnailSource = (*Parser)(nil)
+)
@@ -5,6 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
 	"os/exec"
 	"path/filepath"
 	"strconv"
@@ -54,7 +57,7 @@ func (p *Parser) Parse(ctx context.Context, path string) (*processor.Result, err
 		res.Metadata.Extra["probe_error"] = err.Error()
 	}
 
-	if thumb, err := captureThumbnail(ctx, path); err == nil {
+	if thumb, err := captureThumbnail(ctx, path, "scale=300:-1"); err == nil {
 		res.Thumbnail = thumb
 	} else {
 		res.Metadata.Extra["thumbnail_error"] = err.Error()
@@ -125,7 +128,18 @@ func probeVideo(ctx context.Context, path string) (videoMeta, error) {
 	return meta, nil
 }
 
-func captureThumbnail(ctx context.Context, path string) ([]byte, error) {
+// RenderSource 截取一帧作为海报帧，长边不超过 maxEdge（不放大）
+func (p *Parser) RenderSource(ctx context.Context, path string, maxEdge int) (image.Image, error) {
+	filter := fmt.Sprintf("scale='min(%d,iw)':'min(%d,ih)':force_original_aspect_ratio=decrease", maxEdge, maxEdge)
+	data, err := captureThumbnail(ctx, path, filter)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+func captureThumbnail(ctx context.Context, path string, filter string) ([]byte, error) {
 	ffmpegPath, err := exec.LookPath("ffmpeg")
 	if err != nil {
 		return nil, errors.New("ffmpeg not found")
@@ -136,7 +150,7 @@ func captureThumbnail(ctx context.Context, path string) ([]byte, error) {
 		"-ss", "0.5",
 		"-i", path,
 		"-frames:v", "1",
-		"-vf", "scale=300:-1",
+		"-vf", filter,
 		"-f", "image2pipe",
 		"-vcodec", "mjpeg",
 		"-an",
@@ -156,4 +170,7 @@ func captureThumbnail(ctx context.Context, path string) ([]byte, error) {
 	return out.Bytes(), nil
 }
 
-var _ processor.Parser = (*Parser)(nil)
+var (
+	_ processor.Parser          = (*Parser)(nil)
+	_ processor.ThumbnailSource = (*Parser)(nil)
+)
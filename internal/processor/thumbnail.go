@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+)
+
+// ErrNoThumbnailSource 没有可用的缩略图源（格式不支持或外部工具缺失）
+var ErrNoThumbnailSource = errors.New("no thumbnail source available")
+
+// ThumbnailSource 缩略图源：把文件渲染为一张长边不小于 maxEdge 的图像（原图更小时返回原图），
+// 缩放与编码由缩略图管线统一处理
+type ThumbnailSource interface {
+	Name() string
+	CanHandle(ext string) bool
+	RenderSource(ctx context.Context, path string, maxEdge int) (image.Image, error)
+}
+
+type thumbnailSourceEntry struct {
+	source   ThumbnailSource
+	priority int
+}
+
+// RegisterThumbnailSource 注册缩略图源；同名覆盖，按优先级降序尝试
+func (m *Manager) RegisterThumbnailSource(src ThumbnailSource, priority int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry := thumbnailSourceEntry{source: src, priority: priority}
+	for i, existing := range m.sources {
+		if existing.source.Name() == src.Name() {
+			m.sources[i] = entry
+			return
+		}
+	}
+	m.sources = append(m.sources, entry)
+	sort.SliceStable(m.sources, func(i, j int) bool {
+		return m.sources[i].priority > m.sources[j].priority
+	})
+}
+
+// RenderThumbnailSource 依次尝试可处理该扩展名的缩略图源，返回第一个成功的结果
+func (m *Manager) RenderThumbnailSource(ctx context.Context, path string, ext string, maxEdge int) (image.Image, error) {
+	m.mu.RLock()
+	sources := make([]ThumbnailSource, 0, len(m.sources))
+	for _, entry := range m.sources {
+		if entry.source.CanHandle(ext) {
+			sources = append(sources, entry.source)
+		}
+	}
+	m.mu.RUnlock()
+
+	if len(sources) == 0 {
+		return nil, ErrNoThumbnailSource
+	}
+	var errs []string
+	for _, src := range sources {
+		img, err := src.RenderSource(ctx, path, maxEdge)
+		if err == nil && img != nil {
+			return img, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", src.Name(), err))
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoThumbnailSource, strings.Join(errs, "; "))
+}
@@ -0,0 +1,156 @@
+package repos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"media-assistant-os/internal/models"
+)
+
+// ErrAssetCursorMismatch 游标与当前排序不一致（排序字段或方向改变后需从第一页重新开始）
+var ErrAssetCursorMismatch = errors.New("cursor does not match current sort")
+
+// AssetListCursor 键集分页游标：上一页最后一条的排序键 + id
+type AssetListCursor struct {
+	Sort string `json:"s"`
+	Desc bool   `json:"d,omitempty"`
+	Key  any    `json:"k"`
+	ID   string `json:"i"`
+}
+
+// AssetListPage 一页资产及下一页游标
+type AssetListPage struct {
+	Assets     []models.Asset
+	NextCursor *AssetListCursor
+	HasMore    bool
+}
+
+type assetSortKind int
+
+const (
+	assetSortInt assetSortKind = iota
+	assetSortString
+	assetSortFloat
+)
+
+// assetListSort 规范化后的排序：名称、SQL 表达式、键类型与方向
+type assetListSort struct {
+	name string
+	expr string
+	kind assetSortKind
+	desc bool
+}
+
+type assetListRow struct {
+	ID       string  `bun:"id"`
+	KeyInt   int64   `bun:"key_int"`
+	KeyStr   string  `bun:"key_str"`
+	KeyFloat float64 `bun:"key_float"`
+}
+
+// resolveAssetListSort 将请求的排序字段映射为规范排序；ranked 时按 BM25 分值升序（越小越相关）
+func resolveAssetListSort(sortBy, sortOrder string, ranked bool) assetListSort {
+	if ranked {
+		return assetListSort{name: "relevance", expr: "fts.rank", kind: assetSortFloat}
+	}
+	desc := !strings.EqualFold(strings.TrimSpace(sortOrder), "asc")
+	switch strings.ToLower(strings.TrimSpace(sortBy)) {
+	case "size":
+		return assetListSort{name: "size", expr: "asset.size", kind: assetSortInt, desc: desc}
+	case "date", "mtime", "modified_at", "import_time":
+		return assetListSort{name: "mtime", expr: "asset.mtime", kind: assetSortInt, desc: desc}
+	case "created", "created_at":
+		return assetListSort{name: "created", expr: "asset.created_at", kind: assetSortInt, desc: desc}
+	default:
+		// Use normalized path for deterministic "name/path/type" sorting.
+		return assetListSort{name: "path", expr: "LOWER(asset.path)", kind: assetSortString, desc: desc}
+	}
+}
+
+func (s assetListSort) column() string {
+	switch s.kind {
+	case assetSortString:
+		return "key_str"
+	case assetSortFloat:
+		return "key_float"
+	default:
+		return "key_int"
+	}
+}
+
+func (s assetListSort) direction() string {
+	if s.desc {
+		return "DESC"
+	}
+	return "ASC"
+}
+
+func (s assetListSort) cursor(row assetListRow) *AssetListCursor {
+	c := &AssetListCursor{Sort: s.name, Desc: s.desc, ID: row.ID}
+	switch s.kind {
+	case assetSortString:
+		c.Key = row.KeyStr
+	case assetSortFloat:
+		c.Key = row.KeyFloat
+	default:
+		c.Key = row.KeyInt
+	}
+	return c
+}
+
+// keyset 生成"排在游标之后"的条件；同键时以 id 升序作为稳定次序
+func (s assetListSort) keyset(c *AssetListCursor) (string, []any, error) {
+	if c == nil || c.Sort != s.name || c.Desc != s.desc || c.ID == "" {
+		return "", nil, ErrAssetCursorMismatch
+	}
+	key, err := s.cursorKey(c.Key)
+	if err != nil {
+		return "", nil, err
+	}
+	cmp := ">"
+	if s.desc {
+		cmp = "<"
+	}
+	sql := fmt.Sprintf("(%s %s ? OR (%s = ? AND asset.id > ?))", s.expr, cmp, s.expr)
+	return sql, []any{key, key, c.ID}, nil
+}
+
+func (s assetListSort) cursorKey(v any) (any, error) {
+	invalid := errors.New("invalid cursor key")
+	switch s.kind {
+	case assetSortString:
+		str, ok := v.(string)
+		if !ok {
+			return nil, invalid
+		}
+		return str, nil
+	case assetSortFloat:
+		switch n := v.(type) {
+		case json.Number:
+			f, err := n.Float64()
+			if err != nil {
+				return nil, invalid
+			}
+			return f, nil
+		case float64:
+			return n, nil
+		}
+		return nil, invalid
+	default:
+		switch n := v.(type) {
+		case json.Number:
+			i, err := n.Int64()
+			if err != nil {
+				return nil, invalid
+			}
+			return i, nil
+		case int64:
+			return n, nil
+		case float64:
+			return int64(n), nil
+		}
+		return nil, invalid
+	}
+}
@@ -0,0 +1,191 @@
+package repos
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// AssetQueryNode 结构化资产查询的表达式树节点（由 services.ParseAssetQuery 生成）
+type AssetQueryNode struct {
+	Op       string            `json:"op"` // and / or / not / cond
+	Children []*AssetQueryNode `json:"children,omitempty"`
+
+	// 以下字段仅 cond 节点使用
+	Field  string   `json:"field,omitempty"`
+	Cmp    string   `json:"cmp,omitempty"` // = > >= < <=
+	Text   string   `json:"text,omitempty"`
+	Values []string `json:"values,omitempty"`
+	Num    float64  `json:"num,omitempty"`
+}
+
+const (
+	AssetQueryAnd  = "and"
+	AssetQueryOr   = "or"
+	AssetQueryNot  = "not"
+	AssetQueryCond = "cond"
+)
+
+// 可用于 cond 节点的字段
+const (
+	AssetFieldText     = "text"     // 全文索引（全部列）
+	AssetFieldName     = "name"     // 全文索引 name 列
+	AssetFieldExt      = "ext"      // Values: 扩展名列表
+	AssetFieldTag      = "tag"      // 标签名（不区分大小写）
+	AssetFieldProject  = "project"  // 项目名或 ID
+	AssetFieldShape    = "shape"    // landscape / portrait / ...
+	AssetFieldStatus   = "status"   // 资产状态
+	AssetFieldPath     = "path"     // 路径子串
+	AssetFieldDir      = "dir"      // 目录前缀
+	AssetFieldRating   = "rating"   // Num；Text=none 表示未评分
+	AssetFieldWidth    = "width"    // 像素
+	AssetFieldHeight   = "height"   // 像素
+	AssetFieldDuration = "duration" // 秒
+	AssetFieldSize     = "size"     // 字节
+	AssetFieldMtime    = "mtime"    // Unix 秒
+	AssetFieldCreated  = "created"  // Unix 秒
+	AssetFieldCodec    = "codec"    // 元数据子串
+	AssetFieldFormat   = "format"
+	AssetFieldCamera   = "camera"
+	AssetFieldLens     = "lens"
+)
+
+var assetQueryCmps = map[string]bool{"=": true, ">": true, ">=": true, "<": true, "<=": true}
+
+// assetMetaExpr 读取 media_meta 中的字段；media_meta 可能为空串，需先校验 JSON
+func assetMetaExpr(path string) string {
+	return "(CASE WHEN json_valid(asset.media_meta) THEN json_extract(asset.media_meta, '" + path + "') END)"
+}
+
+var assetNumericColumns = map[string]string{
+	AssetFieldRating:   "COALESCE(asset.user_rating, asset.suggested_rating, 0)",
+	AssetFieldWidth:    "CAST(" + assetMetaExpr("$.width") + " AS INTEGER)",
+	AssetFieldHeight:   "CAST(" + assetMetaExpr("$.height") + " AS INTEGER)",
+	AssetFieldDuration: "CAST(" + assetMetaExpr("$.duration") + " AS REAL)",
+	AssetFieldSize:     "asset.size",
+	AssetFieldMtime:    "asset.mtime",
+	AssetFieldCreated:  "asset.created_at",
+}
+
+var assetMetaTextColumns = map[string]string{
+	AssetFieldCodec:  "LOWER(" + assetMetaExpr("$.codec") + ")",
+	AssetFieldFormat: "LOWER(" + assetMetaExpr("$.format") + ")",
+	AssetFieldCamera: "LOWER(" + assetMetaExpr("$.extra.camera_model") + ")",
+	AssetFieldLens:   "LOWER(" + assetMetaExpr("$.extra.lens_model") + ")",
+}
+
+// compileAssetQuery 将表达式树编译为 SQL 条件
+func compileAssetQuery(n *AssetQueryNode) (string, []any, error) {
+	if n == nil {
+		return "1=1", nil, nil
+	}
+	switch n.Op {
+	case AssetQueryAnd, AssetQueryOr:
+		if len(n.Children) == 0 {
+			return "1=1", nil, nil
+		}
+		joiner := " AND "
+		if n.Op == AssetQueryOr {
+			joiner = " OR "
+		}
+		parts := make([]string, 0, len(n.Children))
+		var args []any
+		for _, c := range n.Children {
+			sql, a, err := compileAssetQuery(c)
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, sql)
+			args = append(args, a...)
+		}
+		return "(" + strings.Join(parts, joiner) + ")", args, nil
+	case AssetQueryNot:
+		if len(n.Children) != 1 {
+			return "", nil, fmt.Errorf("not node requires exactly one child")
+		}
+		sql, args, err := compileAssetQuery(n.Children[0])
+		if err != nil {
+			return "", nil, err
+		}
+		// 条件结果为 NULL（如元数据缺失）时 NOT 也应命中
+		return "((" + sql + ") IS NOT TRUE)", args, nil
+	case AssetQueryCond:
+		return compileAssetCond(n)
+	default:
+		return "", nil, fmt.Errorf("unknown query node: %s", n.Op)
+	}
+}
+
+func compileAssetCond(n *AssetQueryNode) (string, []any, error) {
+	cmp := n.Cmp
+	if cmp == "" {
+		cmp = "="
+	}
+	if !assetQueryCmps[cmp] {
+		return "", nil, fmt.Errorf("invalid comparison %q for %s", cmp, n.Field)
+	}
+
+	if col, ok := assetNumericColumns[n.Field]; ok {
+		if n.Field == AssetFieldRating && n.Text == "none" {
+			// 与 rating 比较使用同一有效评分：人工评分优先，其次建议评分，都没有视为 0
+			return "(" + col + " = 0)", nil, nil
+		}
+		return "(" + col + " " + cmp + " ?)", []any{n.Num}, nil
+	}
+	if col, ok := assetMetaTextColumns[n.Field]; ok {
+		return "(" + col + " LIKE ? ESCAPE '\\')", []any{"%" + escapeLike(strings.ToLower(n.Text)) + "%"}, nil
+	}
+
+	switch n.Field {
+	case AssetFieldText, AssetFieldName:
+		match := BuildAssetFTSMatch(n.Text)
+		if match == "" {
+			return "(LOWER(asset.path) LIKE ? ESCAPE '\\')", []any{"%" + escapeLike(strings.ToLower(n.Text)) + "%"}, nil
+		}
+		if n.Field == AssetFieldName {
+			match = "name : (" + match + ")"
+		}
+		return `(asset.id IN (
+			SELECT sd.asset_id
+			FROM asset_search_docs sd
+			WHERE sd.doc_id IN (SELECT rowid FROM assets_fts WHERE assets_fts MATCH ?)
+		))`, []any{match}, nil
+	case AssetFieldExt:
+		if len(n.Values) == 0 {
+			return "1=0", nil, nil
+		}
+		ors := make([]string, 0, len(n.Values))
+		args := make([]any, 0, len(n.Values))
+		for _, ext := range n.Values {
+			ors = append(ors, "LOWER(asset.path) LIKE ? ESCAPE '\\'")
+			args = append(args, "%."+escapeLike(strings.ToLower(ext)))
+		}
+		return "(" + strings.Join(ors, " OR ") + ")", args, nil
+	case AssetFieldTag:
+		return `(EXISTS (
+			SELECT 1
+			FROM asset_tags at
+			JOIN tags t ON t.id = at.tag_id
+			WHERE at.asset_id = asset.id
+			  AND (at.tag_id = ? OR LOWER(t.name) = LOWER(?))
+		))`, []any{n.Text, n.Text}, nil
+	case AssetFieldProject:
+		return `(EXISTS (
+			SELECT 1
+			FROM project_assets pa
+			JOIN projects p ON p.id = pa.project_id
+			WHERE pa.asset_id = asset.id
+			  AND (pa.project_id = ? OR LOWER(p.name) = LOWER(?))
+		))`, []any{n.Text, n.Text}, nil
+	case AssetFieldShape:
+		return "(LOWER(asset.shape) = ?)", []any{strings.ToLower(n.Text)}, nil
+	case AssetFieldStatus:
+		return "(UPPER(asset.status) = ?)", []any{strings.ToUpper(n.Text)}, nil
+	case AssetFieldPath:
+		return "(LOWER(asset.path) LIKE ? ESCAPE '\\')", []any{"%" + escapeLike(strings.ToLower(n.Text)) + "%"}, nil
+	case AssetFieldDir:
+		clean := filepath.Clean(n.Text)
+		return "(asset.path = ? OR asset.path LIKE ? ESCAPE '\\')", []any{clean, escapeLike(clean+string(filepath.Separator)) + "%"}, nil
+	}
+	return "", nil, fmt.Errorf("unknown query field: %s", n.Field)
+}
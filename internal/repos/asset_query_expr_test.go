@@ -0,0 +1,127 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func cond(field, cmp, text string) *AssetQueryNode {
+	return &AssetQueryNode{Op: AssetQueryCond, Field: field, Cmp: cmp, Text: text}
+}
+
+func numCond(field, cmp string, num float64) *AssetQueryNode {
+	return &AssetQueryNode{Op: AssetQueryCond, Field: field, Cmp: cmp, Num: num}
+}
+
+func TestCompileAssetQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		node    *AssetQueryNode
+		sql     string
+		args    []any
+		wantErr bool
+	}{
+		{name: "nil", node: nil, sql: "1=1"},
+		{name: "empty and", node: &AssetQueryNode{Op: AssetQueryAnd}, sql: "1=1"},
+		{name: "status", node: cond(AssetFieldStatus, "", "ready"), sql: "(UPPER(asset.status) = ?)", args: []any{"READY"}},
+		{name: "rating", node: numCond(AssetFieldRating, ">=", 4), sql: "(COALESCE(asset.user_rating, asset.suggested_rating, 0) >= ?)", args: []any{4.0}},
+		{name: "rating none", node: cond(AssetFieldRating, "=", "none"), sql: "(COALESCE(asset.user_rating, asset.suggested_rating, 0) = 0)"},
+		{name: "not", node: &AssetQueryNode{Op: AssetQueryNot, Children: []*AssetQueryNode{cond(AssetFieldShape, "=", "Portrait")}},
+			sql: "(((LOWER(asset.shape) = ?)) IS NOT TRUE)", args: []any{"portrait"}},
+		{name: "or", node: &AssetQueryNode{Op: AssetQueryOr, Children: []*AssetQueryNode{cond(AssetFieldShape, "=", "square"), cond(AssetFieldStatus, "=", "missing")}},
+			sql: "((LOWER(asset.shape) = ?) OR (UPPER(asset.status) = ?))", args: []any{"square", "MISSING"}},
+		{name: "path escapes like", node: cond(AssetFieldPath, "=", `50%_off\`), sql: `(LOWER(asset.path) LIKE ? ESCAPE '\')`, args: []any{`%50\%\_off\\%`}},
+		{name: "ext escapes like", node: &AssetQueryNode{Op: AssetQueryCond, Field: AssetFieldExt, Values: []string{"MOV", "m_v"}},
+			sql: `(LOWER(asset.path) LIKE ? ESCAPE '\' OR LOWER(asset.path) LIKE ? ESCAPE '\')`, args: []any{"%.mov", `%.m\_v`}},
+		{name: "ext empty", node: &AssetQueryNode{Op: AssetQueryCond, Field: AssetFieldExt}, sql: "1=0"},
+		{name: "dir escapes like", node: cond(AssetFieldDir, "=", "/media/a_b/"), sql: `(asset.path = ? OR asset.path LIKE ? ESCAPE '\')`, args: []any{"/media/a_b", `/media/a\_b/%`}},
+		{name: "codec escapes like", node: cond(AssetFieldCodec, "=", "H_264"), args: []any{`%h\_264%`}},
+		{name: "text without tokens falls back to path", node: cond(AssetFieldText, "=", "%"), sql: `(LOWER(asset.path) LIKE ? ESCAPE '\')`, args: []any{`%\%%`}},
+		{name: "bad cmp", node: numCond(AssetFieldSize, "!=", 1), wantErr: true},
+		{name: "unknown field", node: cond("colour", "=", "red"), wantErr: true},
+		{name: "unknown op", node: &AssetQueryNode{Op: "xor"}, wantErr: true},
+		{name: "not arity", node: &AssetQueryNode{Op: AssetQueryNot}, wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sql, args, err := compileAssetQuery(tc.node)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", sql)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.sql != "" && sql != tc.sql {
+				t.Errorf("sql = %q, want %q", sql, tc.sql)
+			}
+			if fmt.Sprint(args) != fmt.Sprint(tc.args) {
+				t.Errorf("args = %v, want %v", args, tc.args)
+			}
+		})
+	}
+}
+
+func TestCompileAssetQuery_AgainstDB(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	assets := NewAssetRepo(orm)
+
+	ids := map[string]string{}
+	for _, p := range []string{"/m/50%_off.mov", "/m/500.mov", "/m/rated.jpg", "/m/suggested.jpg"} {
+		a, err := assets.Create(ctx, p, 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[a.ID] = p
+	}
+	mustExec := func(q string, args ...any) {
+		if _, err := orm.ExecContext(ctx, q, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustExec("UPDATE assets SET media_meta = '' WHERE path LIKE '%.mov'")
+	mustExec("UPDATE assets SET user_rating = 5 WHERE path = '/m/rated.jpg'")
+	mustExec("UPDATE assets SET suggested_rating = 3 WHERE path = '/m/suggested.jpg'")
+
+	run := func(n *AssetQueryNode) string {
+		t.Helper()
+		got, err := assets.ListIDsByQuery(ctx, AssetListQuery{Expr: n})
+		if err != nil {
+			t.Fatal(err)
+		}
+		paths := make([]string, 0, len(got))
+		for _, id := range got {
+			paths = append(paths, ids[id])
+		}
+		sort.Strings(paths)
+		return strings.Join(paths, " ")
+	}
+	not := func(c *AssetQueryNode) *AssetQueryNode {
+		return &AssetQueryNode{Op: AssetQueryNot, Children: []*AssetQueryNode{c}}
+	}
+
+	tests := []struct {
+		name string
+		node *AssetQueryNode
+		want string
+	}{
+		{"like wildcards are literal", cond(AssetFieldPath, "=", "50%_"), "/m/50%_off.mov"},
+		{"not over null metadata matches", not(numCond(AssetFieldWidth, ">=", 3840)), "/m/50%_off.mov /m/500.mov /m/rated.jpg /m/suggested.jpg"},
+		{"rating none excludes suggested", cond(AssetFieldRating, "=", "none"), "/m/50%_off.mov /m/500.mov"},
+		{"rating uses suggested", numCond(AssetFieldRating, ">=", 3), "/m/rated.jpg /m/suggested.jpg"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := run(tc.node); got != tc.want {
+				t.Fatalf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"media-assistant-os/internal/models"
 	"media-assistant-os/internal/utils"
@@ -40,11 +41,19 @@ type AssetListQuery struct {
 	HeightMin int
 	HeightMax int
 
+	// Expr 结构化查询表达式，与其余条件为 AND 关系
+	Expr *AssetQueryNode
+	// IDs 非空时仅在这些资产内筛选
+	IDs []string
+	// RankText 用于相关度排序的关键词（结构化查询中的正向全文词）；为空时使用 Query
+	RankText string
+
 	SortBy    string
 	SortOrder string
 
-	Limit  int
-	Offset int
+	Limit int
+	// After 键集分页游标（上一页最后一条），为空表示第一页
+	After *AssetListCursor
 }
 
 func NewAssetRepo(db *bun.DB) *AssetRepo {
@@ -89,6 +98,18 @@ func (r *AssetRepo) GetByPaths(ctx context.Context, paths []string) ([]models.As
 	return out, err
 }
 
+func (r *AssetRepo) GetByIDs(ctx context.Context, ids []string) ([]models.Asset, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var out []models.Asset
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx)
+	return out, err
+}
+
 func (r *AssetRepo) FindMissingAssetsBySize(ctx context.Context, size int64) ([]models.Asset, error) {
 	var out []models.Asset
 	err := r.db.NewSelect().
@@ -226,11 +247,17 @@ func (r *AssetRepo) UpdateFingerprint(ctx context.Context, id string, fingerprin
 	return err
 }
 
+// mediaMetaSetExpr 覆盖 media_meta 时保留插件结果写入的 extra 字段
+const mediaMetaSetExpr = `media_meta = CASE
+	WHEN json_valid(media_meta) AND json_type(media_meta, '$.extra') = 'object' AND json_valid(?) AND json_type(?, '$.extra') IS NULL
+	THEN json_set(?, '$.extra', json_extract(media_meta, '$.extra'))
+	ELSE ? END`
+
 func (r *AssetRepo) UpdateMediaMeta(ctx context.Context, id string, mediaMeta string) error {
 	now := time.Now().Unix()
 	_, err := r.db.NewUpdate().
 		Model((*models.Asset)(nil)).
-		Set("media_meta = ?", mediaMeta).
+		Set(mediaMetaSetExpr, mediaMeta, mediaMeta, mediaMeta, mediaMeta).
 		Set("updated_at = ?", now).
 		Where("id = ?", id).
 		Exec(ctx)
@@ -241,7 +268,7 @@ func (r *AssetRepo) UpdateDerivedMetadata(ctx context.Context, id string, mediaM
 	now := time.Now().Unix()
 	q := r.db.NewUpdate().
 		Model((*models.Asset)(nil)).
-		Set("media_meta = ?", mediaMeta).
+		Set(mediaMetaSetExpr, mediaMeta, mediaMeta, mediaMeta, mediaMeta).
 		Set("shape = ?", shape).
 		Set("updated_at = ?", now).
 		Where("id = ?", id)
@@ -258,6 +285,50 @@ func (r *AssetRepo) UpdateDerivedMetadata(ctx context.Context, id string, mediaM
 	return err
 }
 
+// UpdatePHash 写入感知哈希；phash 为 nil 时清空
+func (r *AssetRepo) UpdatePHash(ctx context.Context, id string, phash *int64) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.Asset)(nil)).
+		Set("phash = ?", phash).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+type AssetPHash struct {
+	ID    string `bun:"id"`
+	PHash int64  `bun:"phash"`
+}
+
+// ListPHashes 分页读取已有感知哈希的资产（按 id 升序，afterID 之后）
+func (r *AssetRepo) ListPHashes(ctx context.Context, afterID string, limit int) ([]AssetPHash, error) {
+	var out []AssetPHash
+	err := r.db.NewSelect().
+		Model((*models.Asset)(nil)).
+		Column("id", "phash").
+		Where("phash IS NOT NULL").
+		Where("id > ?", afterID).
+		OrderExpr("id ASC").
+		Limit(limit).
+		Scan(ctx, &out)
+	return out, err
+}
+
+// ListPHashBackfill 返回 media_meta 中带 phash 但尚未写入 phash 列的资产
+func (r *AssetRepo) ListPHashBackfill(ctx context.Context, afterID string, limit int) ([]models.Asset, error) {
+	var out []models.Asset
+	err := r.db.NewSelect().
+		Model(&out).
+		Column("id", "media_meta").
+		Where("phash IS NULL").
+		Where("media_meta LIKE ?", `%"phash"%`).
+		Where("id > ?", afterID).
+		OrderExpr("id ASC").
+		Limit(limit).
+		Scan(ctx)
+	return out, err
+}
+
 func (r *AssetRepo) UpdateUserRating(ctx context.Context, id string, userRating *int) error {
 	now := time.Now().Unix()
 	_, err := r.db.NewUpdate().
@@ -374,6 +445,38 @@ func (r *AssetRepo) ListIDsByDirectory(ctx context.Context, dir string, limit in
 	return ids, err
 }
 
+// MoveDirectory 目录整体重命名/移动后迁移其下资产路径，返回迁移前的资产（旧路径）
+func (r *AssetRepo) MoveDirectory(ctx context.Context, oldDir string, newDir string) ([]models.Asset, error) {
+	oldDir = filepath.Clean(strings.TrimSpace(oldDir))
+	newDir = filepath.Clean(strings.TrimSpace(newDir))
+	if oldDir == "." || newDir == "." || oldDir == newDir {
+		return nil, nil
+	}
+	prefix := escapeLike(oldDir+string(filepath.Separator)) + "%"
+	// SQLite 的 substr 按字符计数
+	cut := utf8.RuneCountInString(oldDir) + 1
+	var moved []models.Asset
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().
+			Model(&moved).
+			Where("path LIKE ? ESCAPE '\\'", prefix).
+			Scan(ctx); err != nil {
+			return err
+		}
+		if len(moved) == 0 {
+			return nil
+		}
+		_, err := tx.NewUpdate().
+			Model((*models.Asset)(nil)).
+			Set("path = ? || substr(path, ?)", newDir, cut).
+			Set("updated_at = ?", time.Now().Unix()).
+			Where("path LIKE ? ESCAPE '\\'", prefix).
+			Exec(ctx)
+		return err
+	})
+	return moved, err
+}
+
 func (r *AssetRepo) GetStats(ctx context.Context) (totalSize int64, count int64, err error) {
 	err = r.db.NewSelect().
 		Model((*models.Asset)(nil)).
@@ -453,7 +556,7 @@ func (r *AssetRepo) validateStatusTransition(ctx context.Context, id string, to
 	return fmt.Errorf("invalid asset status transition: %s -> %s", from, to)
 }
 
-func (r *AssetRepo) ListByQuery(ctx context.Context, req AssetListQuery) ([]models.Asset, int, error) {
+func (r *AssetRepo) ListByQuery(ctx context.Context, req AssetListQuery) (*AssetListPage, error) {
 	limit := req.Limit
 	if limit <= 0 {
 		limit = 50
@@ -461,42 +564,81 @@ func (r *AssetRepo) ListByQuery(ctx context.Context, req AssetListQuery) ([]mode
 	if limit > 200 {
 		limit = 200
 	}
-	offset := req.Offset
-	if offset < 0 {
-		offset = 0
-	}
 
-	// 1) Count with the same filter set.
-	var total int
-	countQ := r.db.NewSelect().
-		TableExpr("assets AS asset").
-		ColumnExpr("COUNT(DISTINCT asset.id)")
-	countQ = r.applyListFilters(countQ, req)
-	if err := countQ.Scan(ctx, &total); err != nil {
-		return nil, 0, err
+	exprSQL, exprArgs, err := compileAssetQuery(req.Expr)
+	if err != nil {
+		return nil, err
 	}
-	if total == 0 {
-		return []models.Asset{}, 0, nil
+
+	match := BuildAssetFTSMatch(req.Query)
+	if strings.TrimSpace(req.RankText) != "" {
+		match = BuildAssetFTSMatch(req.RankText)
 	}
+	sortBy := strings.ToLower(strings.TrimSpace(req.SortBy))
+	ranked := match != "" && (sortBy == "" || sortBy == "relevance")
+	sort := resolveAssetListSort(req.SortBy, req.SortOrder, ranked)
 
-	// 2) Fetch ids first for stable pagination on filtered result set.
-	var ids []string
+	// 1) Fetch one extra id (with its sort key) to detect the next page.
+	var rows []assetListRow
 	idQ := r.db.NewSelect().
 		TableExpr("assets AS asset").
-		Column("asset.id")
-	idQ = r.applyListFilters(idQ, req).
-		GroupExpr("asset.id")
-	idQ = r.applyListSort(idQ, req.SortBy, req.SortOrder).
-		Limit(limit).
-		Offset(offset)
-	if err := idQ.Scan(ctx, &ids); err != nil {
-		return nil, 0, err
+		Column("asset.id").
+		ColumnExpr(sort.expr + " AS " + sort.column())
+	filterReq := req
+	if ranked {
+		// 按相关度排序：JOIN 全文索引取 BM25 分值（越小越相关），MATCH 已承担关键词过滤
+		filterReq.Query = ""
+		idQ = idQ.
+			Join("JOIN asset_search_docs AS sd ON sd.asset_id = asset.id").
+			Join("JOIN (SELECT rowid AS doc_id, rank FROM assets_fts WHERE assets_fts MATCH ? AND rank MATCH ?) AS fts ON fts.doc_id = sd.doc_id", match, assetFTSRank)
+	}
+	idQ = r.applyListFilters(idQ, filterReq).
+		Where(exprSQL, exprArgs...)
+	if req.After != nil {
+		after := req.After
+		if ranked {
+			// BM25 分值随语料统计变化，新资产入库后旧游标里的分值会漂移；改用锚点资产当前的分值
+			if rank, ok, err := r.currentFTSRank(ctx, match, after.ID); err != nil {
+				return nil, err
+			} else if ok {
+				anchored := *after
+				anchored.Key = rank
+				after = &anchored
+			}
+		}
+		keysetSQL, keysetArgs, err := sort.keyset(after)
+		if err != nil {
+			return nil, err
+		}
+		idQ = idQ.Where(keysetSQL, keysetArgs...)
+	}
+	idQ = idQ.
+		GroupExpr("asset.id").
+		OrderExpr(sort.expr + " " + sort.direction()).
+		OrderExpr("asset.id ASC").
+		Limit(limit + 1)
+	if err := idQ.Scan(ctx, &rows); err != nil {
+		return nil, err
 	}
-	if len(ids) == 0 {
-		return []models.Asset{}, total, nil
+
+	page := &AssetListPage{Assets: []models.Asset{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		page.HasMore = true
+	}
+	if len(rows) == 0 {
+		return page, nil
+	}
+	if page.HasMore {
+		page.NextCursor = sort.cursor(rows[len(rows)-1])
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
 	}
 
-	// 3) Fetch full rows and preserve id order.
+	// 2) Fetch full rows and preserve id order.
 	var assets []models.Asset
 	q := r.db.NewSelect().
 		Model(&assets).
@@ -512,12 +654,90 @@ func (r *AssetRepo) ListByQuery(ctx context.Context, req AssetListQuery) ([]mode
 	q = q.OrderExpr(caseSQL, args...)
 
 	if err := q.Scan(ctx); err != nil {
-		return nil, 0, err
+		return nil, err
+	}
+	page.Assets = assets
+	return page, nil
+}
+
+// CountByQuery 统计满足筛选条件的资产数
+func (r *AssetRepo) CountByQuery(ctx context.Context, req AssetListQuery) (int, error) {
+	exprSQL, exprArgs, err := compileAssetQuery(req.Expr)
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	q := r.db.NewSelect().
+		TableExpr("assets AS asset").
+		ColumnExpr("COUNT(DISTINCT asset.id)")
+	q = r.applyListFilters(q, req).
+		Where(exprSQL, exprArgs...)
+	err = q.Scan(ctx, &total)
+	return total, err
+}
+
+// currentFTSRank 返回资产在当前全文匹配下的分值；不再命中时 ok 为 false
+func (r *AssetRepo) currentFTSRank(ctx context.Context, match string, assetID string) (float64, bool, error) {
+	var ranks []float64
+	err := r.db.NewSelect().
+		TableExpr("asset_search_docs AS sd").
+		Join("JOIN (SELECT rowid AS doc_id, rank FROM assets_fts WHERE assets_fts MATCH ? AND rank MATCH ?) AS fts ON fts.doc_id = sd.doc_id", match, assetFTSRank).
+		ColumnExpr("fts.rank").
+		Where("sd.asset_id = ?", assetID).
+		Limit(1).
+		Scan(ctx, &ranks)
+	if err != nil || len(ranks) == 0 {
+		return 0, false, err
+	}
+	return ranks[0], true, nil
+}
+
+// CountByQueryCapped 最多数到 max 条，返回计数以及是否达到上限（达到时为估计下限）
+func (r *AssetRepo) CountByQueryCapped(ctx context.Context, req AssetListQuery, max int) (int, bool, error) {
+	exprSQL, exprArgs, err := compileAssetQuery(req.Expr)
+	if err != nil {
+		return 0, false, err
+	}
+	inner := r.db.NewSelect().
+		TableExpr("assets AS asset").
+		ColumnExpr("DISTINCT asset.id")
+	inner = r.applyListFilters(inner, req).
+		Where(exprSQL, exprArgs...).
+		Limit(max + 1)
+	var n int
+	if err := r.db.NewSelect().
+		TableExpr("(?) AS capped", inner).
+		ColumnExpr("COUNT(*)").
+		Scan(ctx, &n); err != nil {
+		return 0, false, err
+	}
+	if n > max {
+		return max, true, nil
 	}
-	return assets, total, nil
+	return n, false, nil
+}
+
+// ListIDsByQuery 返回满足筛选条件的全部资产 ID（不分页、不排序）
+func (r *AssetRepo) ListIDsByQuery(ctx context.Context, req AssetListQuery) ([]string, error) {
+	exprSQL, exprArgs, err := compileAssetQuery(req.Expr)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	q := r.db.NewSelect().
+		TableExpr("assets AS asset").
+		ColumnExpr("DISTINCT asset.id")
+	q = r.applyListFilters(q, req).
+		Where(exprSQL, exprArgs...)
+	err = q.Scan(ctx, &ids)
+	return ids, err
 }
 
 func (r *AssetRepo) applyListFilters(q *bun.SelectQuery, req AssetListQuery) *bun.SelectQuery {
+	if len(req.IDs) > 0 {
+		q = q.Where("asset.id IN (?)", bun.In(req.IDs))
+	}
+
 	if v := strings.TrimSpace(req.ProjectID); v != "" {
 		q = q.Where(
 			`EXISTS (
@@ -537,33 +757,19 @@ func (r *AssetRepo) applyListFilters(q *bun.SelectQuery, req AssetListQuery) *bu
 	}
 
 	if v := strings.TrimSpace(req.Query); v != "" {
-		// Search tokens are AND-ed (no cross-token OR).
-		terms := strings.Fields(strings.ToLower(v))
-		for _, term := range terms {
-			if term == "" {
-				continue
-			}
-			pattern := "%" + term + "%"
+		if match := BuildAssetFTSMatch(v); match != "" {
+			// 关键词走 assets_fts 全文索引（文件名、目录、标签、项目、笔记、元数据）
 			q = q.Where(
-				`(
-					LOWER(asset.path) LIKE ?
-					OR EXISTS (
-						SELECT 1
-						FROM asset_tags at
-						JOIN tags t ON t.id = at.tag_id
-						WHERE at.asset_id = asset.id
-						  AND LOWER(t.name) LIKE ?
-					)
-					OR EXISTS (
-						SELECT 1
-						FROM project_assets pa
-						JOIN projects p ON p.id = pa.project_id
-						WHERE pa.asset_id = asset.id
-						  AND LOWER(p.name) LIKE ?
-					)
+				`asset.id IN (
+					SELECT sd.asset_id
+					FROM asset_search_docs sd
+					WHERE sd.doc_id IN (SELECT rowid FROM assets_fts WHERE assets_fts MATCH ?)
 				)`,
-				pattern, pattern, pattern,
+				match,
 			)
+		} else {
+			// 纯符号输入没有可索引的词元，退回路径子串匹配
+			q = q.Where("LOWER(asset.path) LIKE ?", "%"+strings.ToLower(v)+"%")
 		}
 	}
 
@@ -635,39 +841,17 @@ func (r *AssetRepo) applyListFilters(q *bun.SelectQuery, req AssetListQuery) *bu
 	}
 
 	if req.WidthMin > 0 {
-		q = q.Where("CAST(json_extract(asset.media_meta, '$.width') AS INTEGER) >= ?", req.WidthMin)
+		q = q.Where(assetNumericColumns[AssetFieldWidth]+" >= ?", req.WidthMin)
 	}
 	if req.WidthMax > 0 {
-		q = q.Where("CAST(json_extract(asset.media_meta, '$.width') AS INTEGER) <= ?", req.WidthMax)
+		q = q.Where(assetNumericColumns[AssetFieldWidth]+" <= ?", req.WidthMax)
 	}
 	if req.HeightMin > 0 {
-		q = q.Where("CAST(json_extract(asset.media_meta, '$.height') AS INTEGER) >= ?", req.HeightMin)
+		q = q.Where(assetNumericColumns[AssetFieldHeight]+" >= ?", req.HeightMin)
 	}
 	if req.HeightMax > 0 {
-		q = q.Where("CAST(json_extract(asset.media_meta, '$.height') AS INTEGER) <= ?", req.HeightMax)
+		q = q.Where(assetNumericColumns[AssetFieldHeight]+" <= ?", req.HeightMax)
 	}
 
 	return q
 }
-
-func (r *AssetRepo) applyListSort(q *bun.SelectQuery, sortBy, sortOrder string) *bun.SelectQuery {
-	dir := "DESC"
-	if strings.EqualFold(strings.TrimSpace(sortOrder), "asc") {
-		dir = "ASC"
-	}
-
-	switch strings.ToLower(strings.TrimSpace(sortBy)) {
-	case "size":
-		q = q.OrderExpr("asset.size " + dir)
-	case "date", "mtime", "modified_at", "import_time":
-		q = q.OrderExpr("asset.mtime " + dir)
-	case "created", "created_at":
-		q = q.OrderExpr("asset.created_at " + dir)
-	case "type":
-		q = q.OrderExpr("LOWER(asset.path) " + dir)
-	default:
-		// Use normalized path for deterministic "name/path" sorting.
-		q = q.OrderExpr("LOWER(asset.path) " + dir)
-	}
-	return q.OrderExpr("asset.id ASC")
-}
@@ -0,0 +1,230 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"media-assistant-os/internal/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// AssetSearchRepo 维护 assets_fts 全文索引及其待更新队列
+type AssetSearchRepo struct {
+	db *bun.DB
+}
+
+// AssetSearchSource 构建索引文档所需的原始字段
+type AssetSearchSource struct {
+	AssetID   string
+	Path      string
+	MediaMeta string
+	Tags      []string
+	Projects  []string
+	Notes     []string
+}
+
+// AssetSearchDoc 已分词的索引文档，各字段为空格分隔的词元
+type AssetSearchDoc struct {
+	AssetID  string
+	Name     string
+	Dirs     string
+	Tags     string
+	Projects string
+	Notes    string
+	Meta     string
+}
+
+// assetFTSRank 通过 FTS5 内置 rank 列指定各列 BM25 权重，顺序与 assets_fts 列一致：name, dirs, tags, projects, notes, meta。
+// 不直接调用 bm25()：子查询被 JOIN 展平后辅助函数无法使用。
+const assetFTSRank = "bm25(10.0, 2.0, 5.0, 3.0, 1.0, 1.0)"
+
+func NewAssetSearchRepo(db *bun.DB) *AssetSearchRepo {
+	return &AssetSearchRepo{db: db}
+}
+
+// BuildAssetFTSMatch 将用户输入转换为 FTS5 MATCH 表达式（词元之间为 AND）；无可用词元时返回空串
+func BuildAssetFTSMatch(query string) string {
+	tokens := utils.SearchQueryTokens(query)
+	if len(tokens) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(tokens))
+	for _, tok := range tokens {
+		term := `"` + strings.ReplaceAll(tok.Text, `"`, `""`) + `"`
+		if tok.Prefix {
+			term += "*"
+		}
+		parts = append(parts, term)
+	}
+	return strings.Join(parts, " ")
+}
+
+// AssetSearchBuildFunc 由待索引资产的源数据构建索引文档；ids 中不在 sources 里的资产已被删除
+type AssetSearchBuildFunc func(ids []string, sources map[string]*AssetSearchSource) (docs []AssetSearchDoc, removed []string)
+
+// IndexDirty 在同一事务内读取一批待索引资产、写入索引并只移出已写入的队列项。
+// 任一步失败则整体回滚，队列保持原样；读取后有其他写入修改了队列时提交失败，下一轮重试
+func (r *AssetSearchRepo) IndexDirty(ctx context.Context, limit int, build AssetSearchBuildFunc) ([]string, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	var ids []string
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().
+			TableExpr("asset_search_dirty").
+			Column("asset_id").
+			OrderExpr("queued_at ASC").
+			Limit(limit).
+			Scan(ctx, &ids); err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		sources, err := loadSearchSources(ctx, tx, ids)
+		if err != nil {
+			return err
+		}
+		docs, removed := build(ids, sources)
+		if err := replaceSearchDocs(ctx, tx, docs, removed); err != nil {
+			return err
+		}
+		_, err = tx.NewDelete().
+			TableExpr("asset_search_dirty").
+			Where("asset_id IN (?)", bun.In(ids)).
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+func (r *AssetSearchRepo) CountDirty(ctx context.Context) (int, error) {
+	return r.db.NewSelect().TableExpr("asset_search_dirty").Count(ctx)
+}
+
+// loadSearchSources 加载资产及其标签、项目、笔记文本；已不存在的资产不在返回结果中
+func loadSearchSources(ctx context.Context, db bun.IDB, ids []string) (map[string]*AssetSearchSource, error) {
+	out := make(map[string]*AssetSearchSource, len(ids))
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	var rows []struct {
+		ID        string `bun:"id"`
+		Path      string `bun:"path"`
+		MediaMeta string `bun:"media_meta"`
+	}
+	if err := db.NewSelect().
+		TableExpr("assets").
+		Column("id", "path", "media_meta").
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx, &rows); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		out[row.ID] = &AssetSearchSource{AssetID: row.ID, Path: row.Path, MediaMeta: row.MediaMeta}
+	}
+	if len(out) == 0 {
+		return out, nil
+	}
+
+	type pair struct {
+		AssetID string `bun:"asset_id"`
+		Text    string `bun:"text"`
+	}
+	var tags []pair
+	if err := db.NewSelect().
+		TableExpr("asset_tags AS at").
+		Join("JOIN tags AS t ON t.id = at.tag_id").
+		ColumnExpr("at.asset_id, t.name AS text").
+		Where("at.asset_id IN (?)", bun.In(ids)).
+		Scan(ctx, &tags); err != nil {
+		return nil, err
+	}
+	for _, p := range tags {
+		if src := out[p.AssetID]; src != nil {
+			src.Tags = append(src.Tags, p.Text)
+		}
+	}
+
+	var projects []pair
+	if err := db.NewSelect().
+		TableExpr("project_assets AS pa").
+		Join("JOIN projects AS p ON p.id = pa.project_id").
+		ColumnExpr("pa.asset_id, p.name AS text").
+		Where("pa.asset_id IN (?)", bun.In(ids)).
+		Scan(ctx, &projects); err != nil {
+		return nil, err
+	}
+	for _, p := range projects {
+		if src := out[p.AssetID]; src != nil {
+			src.Projects = append(src.Projects, p.Text)
+		}
+	}
+
+	var notes []pair
+	if err := db.NewSelect().
+		TableExpr("project_notes").
+		ColumnExpr("source_asset_id AS asset_id, title || ' ' || content AS text").
+		Where("source_asset_id IN (?)", bun.In(ids)).
+		Scan(ctx, &notes); err != nil {
+		return nil, err
+	}
+	for _, p := range notes {
+		if src := out[p.AssetID]; src != nil {
+			src.Notes = append(src.Notes, p.Text)
+		}
+	}
+	return out, nil
+}
+
+// replaceSearchDocs 覆盖写入索引文档，并删除 removed 中资产的索引
+func replaceSearchDocs(ctx context.Context, tx bun.Tx, docs []AssetSearchDoc, removed []string) error {
+	for _, d := range docs {
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO asset_search_docs(asset_id) VALUES (?) ON CONFLICT(asset_id) DO NOTHING",
+			d.AssetID,
+		); err != nil {
+			return err
+		}
+		var docID int64
+		if err := tx.QueryRowContext(ctx,
+			"SELECT doc_id FROM asset_search_docs WHERE asset_id = ?", d.AssetID,
+		).Scan(&docID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM assets_fts WHERE rowid = ?", docID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO assets_fts(rowid, name, dirs, tags, projects, notes, meta) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			docID, d.Name, d.Dirs, d.Tags, d.Projects, d.Notes, d.Meta,
+		); err != nil {
+			return err
+		}
+	}
+	for _, id := range removed {
+		var docID int64
+		err := tx.QueryRowContext(ctx,
+			"SELECT doc_id FROM asset_search_docs WHERE asset_id = ?", id,
+		).Scan(&docID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM assets_fts WHERE rowid = ?", docID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM asset_search_docs WHERE doc_id = ?", docID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repos
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"media-assistant-os/internal/db"
+)
+
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "db"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d, err := db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = d.Close() })
+	if err := db.Migrate(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestAssetSearchRepo_IndexDirtyKeepsQueueOnFailure(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	assets := NewAssetRepo(d.ORM())
+	search := NewAssetSearchRepo(d.ORM())
+
+	a, err := assets.Create(ctx, "/photos/beach_sunset.jpg", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := search.IndexDirty(canceled, 10, func(ids []string, sources map[string]*AssetSearchSource) ([]AssetSearchDoc, []string) {
+		return nil, nil
+	}); err == nil {
+		t.Fatalf("expected error with canceled context")
+	}
+	if n, _ := search.CountDirty(ctx); n != 1 {
+		t.Fatalf("dirty count after failure = %d, want 1", n)
+	}
+
+	ids, err := search.IndexDirty(ctx, 10, func(ids []string, sources map[string]*AssetSearchSource) ([]AssetSearchDoc, []string) {
+		var docs []AssetSearchDoc
+		for _, id := range ids {
+			if src, ok := sources[id]; ok {
+				docs = append(docs, AssetSearchDoc{AssetID: id, Name: filepath.Base(src.Path)})
+			}
+		}
+		return docs, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != a.ID {
+		t.Fatalf("indexed ids = %v, want [%s]", ids, a.ID)
+	}
+	if n, _ := search.CountDirty(ctx); n != 0 {
+		t.Fatalf("dirty count after success = %d, want 0", n)
+	}
+	var hits int
+	if err := d.SQL().QueryRowContext(ctx, "SELECT COUNT(*) FROM assets_fts WHERE assets_fts MATCH ?", `"beach_sunset.jpg"`).Scan(&hits); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 1 {
+		t.Fatalf("fts hits = %d, want 1", hits)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"media-assistant-os/internal/models"
 	"media-assistant-os/internal/utils"
@@ -79,6 +80,118 @@ func (r *LibrarySourceRepo) List(ctx context.Context) ([]models.LibrarySource, e
 	return out, err
 }
 
+// SetIgnoreRules 更新来源级忽略规则；来源不存在时返回 nil
+func (r *LibrarySourceRepo) SetIgnoreRules(ctx context.Context, rootPath string, rules string) (*models.LibrarySource, error) {
+	rootPath = normalizeLibraryRootPath(rootPath)
+	if rootPath == "" {
+		return nil, nil
+	}
+	_, err := r.db.NewUpdate().
+		Model((*models.LibrarySource)(nil)).
+		Set("ignore_rules = ?", rules).
+		Set("updated_at = ?", time.Now().Unix()).
+		Where("root_path = ?", rootPath).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByPath(ctx, rootPath)
+}
+
+// SetScanConcurrency 更新来源的扫描并发上限；来源不存在时返回 nil
+func (r *LibrarySourceRepo) SetScanConcurrency(ctx context.Context, rootPath string, concurrency int) (*models.LibrarySource, error) {
+	rootPath = normalizeLibraryRootPath(rootPath)
+	if rootPath == "" {
+		return nil, nil
+	}
+	_, err := r.db.NewUpdate().
+		Model((*models.LibrarySource)(nil)).
+		Set("scan_concurrency = ?", concurrency).
+		Set("updated_at = ?", time.Now().Unix()).
+		Where("root_path = ?", rootPath).
+		Exec(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByPath(ctx, rootPath)
+}
+
+// BindVolume 记录来源所在卷
+func (r *LibrarySourceRepo) BindVolume(ctx context.Context, id string, volumeID string, label string, volumePath string) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.LibrarySource)(nil)).
+		Set("volume_id = ?", volumeID).
+		Set("volume_label = ?", label).
+		Set("volume_path = ?", volumePath).
+		Set("updated_at = ?", time.Now().Unix()).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+// SetVolumeState 更新卷在线状态；seenAt 为 0 时保留上次在线时间
+func (r *LibrarySourceRepo) SetVolumeState(ctx context.Context, id string, state string, seenAt int64) error {
+	q := r.db.NewUpdate().
+		Model((*models.LibrarySource)(nil)).
+		Set("volume_state = ?", state).
+		Where("id = ?", id)
+	if seenAt > 0 {
+		q = q.Set("volume_seen_at = ?", seenAt)
+	}
+	_, err := q.Exec(ctx)
+	return err
+}
+
+// RelocateRoot 卷换挂载点后，把来源根目录及其下的项目来源、项目路径、资产路径、扫描检查点整体迁移到新根目录
+func (r *LibrarySourceRepo) RelocateRoot(ctx context.Context, oldRoot string, newRoot string) (int64, error) {
+	oldRoot = normalizeLibraryRootPath(oldRoot)
+	newRoot = normalizeLibraryRootPath(newRoot)
+	if oldRoot == "" || newRoot == "" || oldRoot == newRoot {
+		return 0, nil
+	}
+	prefix := escapeLike(strings.TrimSuffix(oldRoot, string(filepath.Separator))+string(filepath.Separator)) + "%"
+	// SQLite 的 substr 按字符计数
+	cut := utf8.RuneCountInString(oldRoot) + 1
+	var moved int64
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		now := time.Now().Unix()
+		if _, err := tx.NewUpdate().
+			Model((*models.LibrarySource)(nil)).
+			Set("root_path = ?", newRoot).
+			Set("updated_at = ?", now).
+			Where("root_path = ?", oldRoot).
+			Exec(ctx); err != nil {
+			return err
+		}
+		moves := []struct {
+			table, column string
+		}{
+			{"project_sources", "root_path"},
+			{"projects", "path"},
+			{"scan_dir_states", "dir_path"},
+		}
+		for _, m := range moves {
+			if _, err := tx.ExecContext(ctx,
+				"UPDATE "+m.table+" SET "+m.column+" = ? || substr("+m.column+", ?) WHERE "+m.column+" = ? OR "+m.column+" LIKE ? ESCAPE '\\'",
+				newRoot, cut, oldRoot, prefix); err != nil {
+				return err
+			}
+		}
+		res, err := tx.NewUpdate().
+			Model((*models.Asset)(nil)).
+			Set("path = ? || substr(path, ?)", newRoot, cut).
+			Set("updated_at = ?", now).
+			Where("path LIKE ? ESCAPE '\\'", prefix).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		moved, _ = res.RowsAffected()
+		return nil
+	})
+	return moved, err
+}
+
 func (r *LibrarySourceRepo) Remove(ctx context.Context, rootPath string) error {
 	rootPath = normalizeLibraryRootPath(rootPath)
 	if rootPath == "" {
@@ -0,0 +1,96 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type LineageCandidateRepo struct {
+	db *bun.DB
+}
+
+func NewLineageCandidateRepo(db *bun.DB) *LineageCandidateRepo {
+	return &LineageCandidateRepo{db: db}
+}
+
+// Upsert 写入候选；同一 (祖先, 后代, 规则) 已存在时仅刷新仍为 pending 的记录，已确认/拒绝的保持不变
+func (r *LineageCandidateRepo) Upsert(ctx context.Context, c *models.LineageCandidate) (bool, error) {
+	res, err := r.db.NewInsert().
+		Model(c).
+		On("CONFLICT (ancestor_id, descendant_id, rule_type) DO UPDATE").
+		Set("project_id = EXCLUDED.project_id").
+		Set("score = EXCLUDED.score").
+		Set("confidence = EXCLUDED.confidence").
+		Set("reason = EXCLUDED.reason").
+		Set("evidence_json = EXCLUDED.evidence_json").
+		Set("updated_at = EXCLUDED.updated_at").
+		Where("lineage_candidate.status = ?", models.LineageCandidatePending).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (r *LineageCandidateRepo) Get(ctx context.Context, id string) (*models.LineageCandidate, error) {
+	var out models.LineageCandidate
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+// List 按资产（祖先或后代）和/或项目筛选候选，按分数降序
+func (r *LineageCandidateRepo) List(ctx context.Context, assetID string, projectID string, status string, limit int) ([]models.LineageCandidate, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var out []models.LineageCandidate
+	q := r.db.NewSelect().
+		Model(&out).
+		OrderExpr("score DESC, updated_at DESC").
+		Limit(limit)
+	if assetID != "" {
+		q = q.Where("(ancestor_id = ? OR descendant_id = ?)", assetID, assetID)
+	}
+	if projectID != "" {
+		q = q.Where("project_id = ?", projectID)
+	}
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Scan(ctx)
+	return out, err
+}
+
+// UpdatePairStatus 将同一资产对（任意规则）的 pending 候选统一置为 status
+func (r *LineageCandidateRepo) UpdatePairStatus(ctx context.Context, ancestorID string, descendantID string, status string) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.LineageCandidate)(nil)).
+		Set("status = ?", status).
+		Set("updated_at = ?", time.Now().Unix()).
+		Where("ancestor_id = ?", ancestorID).
+		Where("descendant_id = ?", descendantID).
+		Where("status = ?", models.LineageCandidatePending).
+		Exec(ctx)
+	return err
+}
+
+func (r *LineageCandidateRepo) Update(ctx context.Context, c *models.LineageCandidate) error {
+	_, err := r.db.NewUpdate().
+		Model(c).
+		WherePK().
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,152 @@
+package repos
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+// taskCancelledReason 取消写入 error_message 的说明
+const taskCancelledReason = "cancelled by user"
+
+// ListActiveByAssets 返回资产的待处理 / 处理中任务
+func (r *MediaTaskRepo) ListActiveByAssets(ctx context.Context, assetIDs []string) ([]models.MediaTask, error) {
+	var tasks []models.MediaTask
+	if len(assetIDs) == 0 {
+		return tasks, nil
+	}
+	err := r.db.NewSelect().
+		Model(&tasks).
+		Where("mt.asset_id IN (?)", bun.In(assetIDs)).
+		Where("mt.status IN (?)", bun.In([]models.TaskStatus{models.TaskStatusPending, models.TaskStatusProcessing})).
+		Scan(ctx)
+	return tasks, err
+}
+
+// CancelPending 将待处理任务直接置为 cancelled，返回实际被取消的任务
+func (r *MediaTaskRepo) CancelPending(ctx context.Context, ids []string) ([]models.MediaTask, error) {
+	var tasks []models.MediaTask
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	now := time.Now()
+	_, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("status = ?", models.TaskStatusCancelled).
+		Set("error_message = ?", taskCancelledReason).
+		Set("next_retry_at = NULL").
+		Set("finished_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("id IN (?)", bun.In(ids)).
+		Where("status = ?", models.TaskStatusPending).
+		Returning("*").
+		Exec(ctx, &tasks)
+	return tasks, err
+}
+
+// RequestCancel 为处理中的任务打上取消标记，返回被标记的任务
+func (r *MediaTaskRepo) RequestCancel(ctx context.Context, ids []string) ([]models.MediaTask, error) {
+	var tasks []models.MediaTask
+	if len(ids) == 0 {
+		return tasks, nil
+	}
+	_, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("cancel_requested = 1").
+		Set("updated_at = ?", time.Now()).
+		Where("id IN (?)", bun.In(ids)).
+		Where("status = ?", models.TaskStatusProcessing).
+		Returning("*").
+		Exec(ctx, &tasks)
+	return tasks, err
+}
+
+// FinishCancelled worker 确认放弃已标记取消的任务后，将其置为 cancelled
+func (r *MediaTaskRepo) FinishCancelled(ctx context.Context, taskID, workerID string) (bool, error) {
+	now := time.Now()
+	res, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("status = ?", models.TaskStatusCancelled).
+		Set("error_message = ?", taskCancelledReason).
+		Set("lease_until = NULL").
+		Set("finished_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("id = ?", taskID).
+		Where("status = ?", models.TaskStatusProcessing).
+		Where("worker_id = ?", workerID).
+		Where("cancel_requested = 1").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	rows, _ := res.RowsAffected()
+	return rows > 0, nil
+}
+
+// CancelExpiredLeases 已标记取消且租约过期（worker 失联）的任务直接置为 cancelled，而不是重新入队
+func (r *MediaTaskRepo) CancelExpiredLeases(ctx context.Context) ([]models.MediaTask, error) {
+	var tasks []models.MediaTask
+	now := time.Now()
+	_, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("status = ?", models.TaskStatusCancelled).
+		Set("error_message = ?", taskCancelledReason).
+		Set("lease_until = NULL").
+		Set("finished_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("status = ?", models.TaskStatusProcessing).
+		Where("cancel_requested = 1").
+		Where("lease_until IS NOT NULL AND lease_until <= CURRENT_TIMESTAMP").
+		Returning("*").
+		Exec(ctx, &tasks)
+	return tasks, err
+}
+
+// BoostPending 将资产的待处理任务优先级整体抬高 delta（已提升过的不再叠加），返回受影响任务数
+func (r *MediaTaskRepo) BoostPending(ctx context.Context, assetIDs []string, delta int) (int64, error) {
+	if len(assetIDs) == 0 {
+		return 0, nil
+	}
+	res, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("priority = priority + ?", delta).
+		Set("updated_at = ?", time.Now()).
+		Where("asset_id IN (?)", bun.In(assetIDs)).
+		Where("status = ?", models.TaskStatusPending).
+		Where("priority < ?", delta).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// BoostPendingInDirectory 提升目录下资产的待处理任务优先级；recursive 为 false 时仅直接子文件
+func (r *MediaTaskRepo) BoostPendingInDirectory(ctx context.Context, dir string, recursive bool, delta int) (int64, error) {
+	sep := string(filepath.Separator)
+	prefix := strings.TrimRight(filepath.Clean(dir), sep) + sep
+	sub := r.db.NewSelect().
+		Table("assets").
+		Column("id").
+		Where("path LIKE ? ESCAPE '\\'", escapeLike(prefix)+"%")
+	if !recursive {
+		sub = sub.Where("path NOT LIKE ? ESCAPE '\\'", escapeLike(prefix)+"%"+escapeLike(sep)+"%")
+	}
+	res, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("priority = priority + ?", delta).
+		Set("updated_at = ?", time.Now()).
+		Where("asset_id IN (?)", sub).
+		Where("status = ?", models.TaskStatusPending).
+		Where("priority < ?", delta).
+		Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,210 @@
+package repos
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+func TestMediaTaskRepo_Cancel(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	tasks := NewMediaTaskRepo(orm)
+	assets := NewAssetRepo(orm)
+
+	a, err := assets.Create(ctx, "/media/clip.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, task := range []*models.MediaTask{
+		{ID: "pending", AssetID: a.ID, TaskType: "thumbnail", Status: models.TaskStatusPending, Priority: 5},
+		{ID: "running", AssetID: a.ID, TaskType: "metadata", Status: models.TaskStatusProcessing, WorkerID: "w1", Priority: 5},
+		{ID: "done", AssetID: a.ID, TaskType: "waveform", Status: models.TaskStatusCompleted, Priority: 5},
+	} {
+		task.CreatedAt, task.UpdatedAt = now, now
+		if _, err := orm.NewInsert().Model(task).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(id string) models.MediaTask {
+		task, err := tasks.GetByID(ctx, id)
+		if err != nil || task == nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		return *task
+	}
+	ids := []string{"pending", "running", "done"}
+
+	// 待处理任务直接取消，处理中 / 已完成的不受影响
+	cancelled, err := tasks.CancelPending(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 1 || cancelled[0].ID != "pending" || cancelled[0].Status != models.TaskStatusCancelled {
+		t.Fatalf("CancelPending = %+v", cancelled)
+	}
+	if task := get("pending"); task.ErrorMessage != taskCancelledReason || task.FinishedAt.IsZero() {
+		t.Fatalf("cancelled task = %+v", task)
+	}
+
+	// 处理中的任务只打标记，状态不变
+	flagged, err := tasks.RequestCancel(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(flagged) != 1 || flagged[0].ID != "running" || !flagged[0].CancelRequested || flagged[0].Status != models.TaskStatusProcessing {
+		t.Fatalf("RequestCancel = %+v", flagged)
+	}
+	if task := get("done"); task.Status != models.TaskStatusCompleted || task.CancelRequested {
+		t.Fatalf("completed task touched: %+v", task)
+	}
+
+	// 只有持有任务的 worker 能确认取消
+	if ok, err := tasks.FinishCancelled(ctx, "running", "w2"); err != nil || ok {
+		t.Fatalf("FinishCancelled by other worker = %v, %v", ok, err)
+	}
+	if ok, err := tasks.FinishCancelled(ctx, "running", "w1"); err != nil || !ok {
+		t.Fatalf("FinishCancelled = %v, %v", ok, err)
+	}
+	if task := get("running"); task.Status != models.TaskStatusCancelled || !task.LeaseUntil.IsZero() {
+		t.Fatalf("finished task = %+v", task)
+	}
+	if ok, err := tasks.FinishCancelled(ctx, "running", "w1"); err != nil || ok {
+		t.Fatalf("second FinishCancelled = %v, %v", ok, err)
+	}
+}
+
+func TestMediaTaskRepo_CancelExpiredLeases(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	tasks := NewMediaTaskRepo(orm)
+	assets := NewAssetRepo(orm)
+
+	a, err := assets.Create(ctx, "/media/clip.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		id       string
+		taskType string
+		flagged  bool
+		leaseSQL string
+	}{
+		{"flagged-expired", "thumbnail", true, "datetime('now', '-1 minute')"},
+		{"flagged-live", "metadata", true, "datetime('now', '+1 hour')"},
+		{"expired", "waveform", false, "datetime('now', '-1 minute')"},
+	} {
+		if _, err := orm.ExecContext(ctx, `INSERT INTO media_tasks (id, asset_id, task_type, status, worker_id, priority, cancel_requested, lease_until, created_at, updated_at)
+			VALUES (?, ?, ?, ?, 'w1', 5, ?, `+tc.leaseSQL+`, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
+			tc.id, a.ID, tc.taskType, models.TaskStatusProcessing, tc.flagged); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 失联 worker 上已请求取消的任务直接取消，其余保持原样等待重新入队
+	cancelled, err := tasks.CancelExpiredLeases(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cancelled) != 1 || cancelled[0].ID != "flagged-expired" || cancelled[0].Status != models.TaskStatusCancelled {
+		t.Fatalf("CancelExpiredLeases = %+v", cancelled)
+	}
+	for _, id := range []string{"flagged-live", "expired"} {
+		if task, err := tasks.GetByID(ctx, id); err != nil || task.Status != models.TaskStatusProcessing {
+			t.Fatalf("%s = %+v, %v", id, task, err)
+		}
+	}
+}
+
+func TestMediaTaskRepo_BoostOrdering(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	tasks := NewMediaTaskRepo(orm)
+	assets := NewAssetRepo(orm)
+
+	paths := []string{"/media/a.mov", "/media/shoot/b.mov", "/media/shoot/day1/c.mov", "/media/other/d.mov"}
+	assetIDs := map[string]string{}
+	for _, p := range paths {
+		a, err := assets.Create(ctx, p, 10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assetIDs[p] = a.ID
+	}
+	// 资产 a 上有两个不同优先级的任务，其余资产各一个；创建时间依次递增
+	base := time.Now().Add(-time.Hour)
+	seed := []struct {
+		id       string
+		path     string
+		taskType string
+		priority int
+	}{
+		{"a-high", "/media/a.mov", "thumbnail", 50},
+		{"a-low", "/media/a.mov", "metadata", 10},
+		{"b", "/media/shoot/b.mov", "thumbnail", 30},
+		{"c", "/media/shoot/day1/c.mov", "thumbnail", 30},
+		{"d", "/media/other/d.mov", "thumbnail", 90},
+	}
+	for i, s := range seed {
+		at := base.Add(time.Duration(i) * time.Second)
+		task := &models.MediaTask{ID: s.id, AssetID: assetIDs[s.path], TaskType: s.taskType, Status: models.TaskStatusPending, Priority: s.priority, CreatedAt: at, UpdatedAt: at}
+		if _, err := orm.NewInsert().Model(task).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	claimOrder := func() []string {
+		claimed, err := tasks.ClaimNext(ctx, nil, "w1", time.Now().Add(time.Minute), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(claimed))
+		for _, task := range claimed {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	n, err := tasks.BoostPending(ctx, []string{assetIDs["/media/a.mov"]}, 10000)
+	if err != nil || n != 2 {
+		t.Fatalf("BoostPending = %d, %v", n, err)
+	}
+	// 已提升过的不再叠加
+	if n, err := tasks.BoostPending(ctx, []string{assetIDs["/media/a.mov"]}, 10000); err != nil || n != 0 {
+		t.Fatalf("second BoostPending = %d, %v", n, err)
+	}
+	// 非递归只提升目录的直接子文件
+	if n, err := tasks.BoostPendingInDirectory(ctx, "/media/shoot/", false, 10000); err != nil || n != 1 {
+		t.Fatalf("BoostPendingInDirectory = %d, %v", n, err)
+	}
+	if n, err := tasks.BoostPendingInDirectory(ctx, "/media/shoot", true, 10000); err != nil || n != 1 {
+		t.Fatalf("recursive BoostPendingInDirectory = %d, %v", n, err)
+	}
+
+	// 提升的任务整体排在前面，组内保持原有优先级与创建次序
+	want := []string{"a-high", "b", "c", "a-low", "d"}
+	got := claimOrder()
+	if len(got) != len(want) {
+		t.Fatalf("claimed %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("claim order = %v, want %v", got, want)
+		}
+	}
+
+	var priorities []int
+	if err := orm.NewSelect().Model((*models.MediaTask)(nil)).Column("priority").Scan(ctx, &priorities); err != nil {
+		t.Fatal(err)
+	}
+	sort.Ints(priorities)
+	if priorities[0] != 90 || priorities[len(priorities)-1] != 10050 {
+		t.Fatalf("priorities = %v", priorities)
+	}
+}
@@ -0,0 +1,108 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+// depsMetSQL 任务的所有前置任务均已完成；idExpr 为任务 id 的列或占位符
+func depsMetSQL(idExpr string) string {
+	return "NOT EXISTS (SELECT 1 FROM media_task_deps AS d JOIN media_tasks AS p ON p.id = d.depends_on_task_id" +
+		" WHERE d.task_id = " + idExpr + " AND p.status != '" + string(models.TaskStatusCompleted) + "')"
+}
+
+// dependentsCTE 递归收集某任务的全部下游任务 id
+const dependentsCTE = `WITH RECURSIVE dep(id) AS (
+	SELECT task_id FROM media_task_deps WHERE depends_on_task_id = ?
+	UNION
+	SELECT d.task_id FROM media_task_deps AS d JOIN dep ON d.depends_on_task_id = dep.id
+) SELECT id FROM dep`
+
+// dependencyFailedReason 级联失败写入 dead_letter_reason 的前缀，重放父任务时据此恢复
+const dependencyFailedReason = "dependency_failed:"
+
+// GetLatestByAssetAndType 返回资产某类型最近创建的任务（任意状态）
+func (r *MediaTaskRepo) GetLatestByAssetAndType(ctx context.Context, assetID, taskType string) (*models.MediaTask, error) {
+	var task models.MediaTask
+	err := r.db.NewSelect().
+		Model(&task).
+		Where("asset_id = ?", assetID).
+		Where("task_type = ?", taskType).
+		Order("created_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &task, err
+}
+
+// ListDependencies 返回任务的直接前置任务
+func (r *MediaTaskRepo) ListDependencies(ctx context.Context, taskID string) ([]models.MediaTask, error) {
+	var tasks []models.MediaTask
+	err := r.db.NewSelect().
+		Model(&tasks).
+		Where("mt.id IN (SELECT depends_on_task_id FROM media_task_deps WHERE task_id = ?)", taskID).
+		Order("mt.created_at ASC").
+		Scan(ctx)
+	return tasks, err
+}
+
+// CascadeDependents 将父任务的全部待处理下游任务置为 status（failed / cancelled），返回受影响的任务
+func (r *MediaTaskRepo) CascadeDependents(ctx context.Context, parentID string, status models.TaskStatus, reason string) ([]models.MediaTask, error) {
+	var tasks []models.MediaTask
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().
+			Model(&tasks).
+			Where("mt.id IN ("+dependentsCTE+")", parentID).
+			Where("mt.status = ?", models.TaskStatusPending).
+			Scan(ctx); err != nil {
+			return err
+		}
+		if len(tasks) == 0 {
+			return nil
+		}
+		ids := make([]string, 0, len(tasks))
+		for i := range tasks {
+			ids = append(ids, tasks[i].ID)
+		}
+		now := time.Now()
+		_, err := tx.NewUpdate().
+			Model((*models.MediaTask)(nil)).
+			Set("status = ?", status).
+			Set("error_message = ?", reason).
+			Set("dead_letter_reason = ?", dependencyFailedReason+parentID).
+			Set("next_retry_at = NULL").
+			Set("finished_at = ?", now).
+			Set("updated_at = ?", now).
+			Where("id IN (?)", bun.In(ids)).
+			Where("status = ?", models.TaskStatusPending).
+			Exec(ctx)
+		return err
+	})
+	return tasks, err
+}
+
+// reviveDependents 父任务重放时，把因它级联失败 / 取消的下游任务恢复为 pending
+func reviveDependents(ctx context.Context, tx bun.Tx, parentID string) error {
+	_, err := tx.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("status = ?", models.TaskStatusPending).
+		Set("retry_count = 0").
+		Set("error_message = ?", "").
+		Set("dead_letter_reason = ?", "").
+		Set("finished_at = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("id IN ("+dependentsCTE+")", parentID).
+		Where("status IN (?)", bun.In([]models.TaskStatus{models.TaskStatusFailed, models.TaskStatusCancelled})).
+		Where("dead_letter_reason LIKE ?", dependencyFailedReason+"%").
+		// 同资产同类型已有进行中的任务时跳过，避免违反唯一索引
+		Where("NOT EXISTS (SELECT 1 FROM media_tasks AS o WHERE o.asset_id = mt.asset_id AND o.task_type = mt.task_type AND o.status IN ('pending', 'processing'))").
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,152 @@
+package repos
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+func TestMediaTaskRepo_Dependencies(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	tasks := NewMediaTaskRepo(orm)
+	assets := NewAssetRepo(orm)
+
+	a, err := assets.Create(ctx, "/media/clip.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := tasks.CreateWithDeps(ctx, a.ID, "fingerprint", 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := tasks.CreateWithDeps(ctx, a.ID, "metadata", 5, []string{parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	grandchild, err := tasks.CreateWithDeps(ctx, a.ID, "thumbnail", 5, []string{child.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	setStatus := func(id string, status models.TaskStatus) {
+		if _, err := orm.ExecContext(ctx, "UPDATE media_tasks SET status = ? WHERE id = ?", status, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(id string) models.MediaTask {
+		var task models.MediaTask
+		if err := orm.NewSelect().Model(&task).Where("id = ?", id).Scan(ctx); err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		return task
+	}
+	claim := func() []string {
+		claimed, err := tasks.ClaimNext(ctx, []string{"metadata", "thumbnail"}, "w1", time.Now().Add(time.Minute), 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, 0, len(claimed))
+		for _, task := range claimed {
+			ids = append(ids, task.ID)
+		}
+		return ids
+	}
+
+	// 前置未完成时下游不可领取
+	if ids := claim(); len(ids) != 0 {
+		t.Fatalf("claimed %v before fingerprint completed", ids)
+	}
+
+	// 父任务进入死信后，全部待处理下游级联失败
+	setStatus(parent.ID, models.TaskStatusFailed)
+	cascaded, err := tasks.CascadeDependents(ctx, parent.ID, models.TaskStatusFailed, "dependency failed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cascaded) != 2 {
+		t.Fatalf("cascaded %d tasks, want 2", len(cascaded))
+	}
+	for _, id := range []string{child.ID, grandchild.ID} {
+		task := get(id)
+		if task.Status != models.TaskStatusFailed || task.DeadLetterReason != dependencyFailedReason+parent.ID || task.FinishedAt.IsZero() {
+			t.Errorf("%s after cascade: status=%s reason=%q", task.TaskType, task.Status, task.DeadLetterReason)
+		}
+	}
+
+	// 重放父任务时恢复级联失败的下游
+	entry := models.MediaTaskDLQEntry{ID: "d1", TaskID: parent.ID, AssetID: a.ID, TaskType: "fingerprint", ErrorMessage: "boom", MaxRetries: 3, Priority: 5, CreatedAt: time.Now()}
+	if _, err := orm.NewInsert().Model(&entry).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tasks.ReplayDLQ(ctx, []models.MediaTaskDLQEntry{entry}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{parent.ID, child.ID, grandchild.ID} {
+		task := get(id)
+		if task.Status != models.TaskStatusPending || task.DeadLetterReason != "" || !task.FinishedAt.IsZero() {
+			t.Errorf("%s after replay: status=%s reason=%q", task.TaskType, task.Status, task.DeadLetterReason)
+		}
+	}
+	if ids := claim(); len(ids) != 0 {
+		t.Fatalf("claimed %v while fingerprint is pending again", ids)
+	}
+
+	// 父任务完成后只放行直接下游，孙任务仍等待
+	setStatus(parent.ID, models.TaskStatusCompleted)
+	if ids := claim(); strings.Join(ids, ",") != child.ID {
+		t.Fatalf("claimed %v, want only %s", ids, child.ID)
+	}
+	setStatus(child.ID, models.TaskStatusCompleted)
+	if ids := claim(); strings.Join(ids, ",") != grandchild.ID {
+		t.Fatalf("claimed %v, want only %s", ids, grandchild.ID)
+	}
+}
+
+func TestMediaTaskRepo_ReviveDependentsSkipsInflightDuplicate(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	tasks := NewMediaTaskRepo(orm)
+	assets := NewAssetRepo(orm)
+
+	a, err := assets.Create(ctx, "/media/clip.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := tasks.CreateWithDeps(ctx, a.ID, "fingerprint", 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := tasks.CreateWithDeps(ctx, a.ID, "metadata", 5, []string{parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orm.ExecContext(ctx, "UPDATE media_tasks SET status = 'failed' WHERE id = ?", parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tasks.CascadeDependents(ctx, parent.ID, models.TaskStatusCancelled, "dependency failed"); err != nil {
+		t.Fatal(err)
+	}
+	// 期间已重新入队同类型任务，重放时不得恢复出第二个进行中的任务
+	if _, err := tasks.CreateWithDeps(ctx, a.ID, "metadata", 5, nil); err != nil {
+		t.Fatal(err)
+	}
+	entry := models.MediaTaskDLQEntry{ID: "d1", TaskID: parent.ID, AssetID: a.ID, TaskType: "fingerprint", ErrorMessage: "boom", MaxRetries: 3, Priority: 5, CreatedAt: time.Now()}
+	if _, err := orm.NewInsert().Model(&entry).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tasks.ReplayDLQ(ctx, []models.MediaTaskDLQEntry{entry}); err != nil {
+		t.Fatal(err)
+	}
+	var status string
+	if err := orm.QueryRowContext(ctx, "SELECT status FROM media_tasks WHERE id = ?", child.ID).Scan(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status != string(models.TaskStatusCancelled) {
+		t.Fatalf("child status = %s, want cancelled", status)
+	}
+}
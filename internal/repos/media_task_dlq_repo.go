@@ -0,0 +1,199 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+// MediaTaskDLQFilter 死信筛选条件；零值字段不参与过滤
+type MediaTaskDLQFilter struct {
+	IDs           []string
+	TaskType      string
+	ErrorContains string
+	From          time.Time
+	To            time.Time
+}
+
+// MediaTaskDLQMessageCount 按原始错误信息聚合的死信数量
+type MediaTaskDLQMessageCount struct {
+	TaskType     string    `bun:"task_type"`
+	ErrorMessage string    `bun:"error_message"`
+	Count        int       `bun:"cnt"`
+	FirstAt      time.Time `bun:"first_at"`
+	LastAt       time.Time `bun:"last_at"`
+}
+
+func applyDLQFilter(q *bun.SelectQuery, f MediaTaskDLQFilter) *bun.SelectQuery {
+	if len(f.IDs) > 0 {
+		q = q.Where("dlq.id IN (?)", bun.In(f.IDs))
+	}
+	if f.TaskType != "" {
+		q = q.Where("dlq.task_type = ?", f.TaskType)
+	}
+	if f.ErrorContains != "" {
+		q = q.Where("LOWER(dlq.error_message) LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(f.ErrorContains))+"%")
+	}
+	if !f.From.IsZero() {
+		q = q.Where("dlq.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("dlq.created_at < ?", f.To)
+	}
+	return q
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// ListDLQ 分页列出死信（最新在前）；limit <= 0 表示不分页
+func (r *MediaTaskRepo) ListDLQ(ctx context.Context, f MediaTaskDLQFilter, limit, offset int) ([]models.MediaTaskDLQEntry, int, error) {
+	var entries []models.MediaTaskDLQEntry
+	q := applyDLQFilter(r.db.NewSelect().Model(&entries), f).
+		Order("dlq.created_at DESC", "dlq.id ASC")
+	if limit > 0 {
+		q = q.Limit(limit).Offset(offset)
+	}
+	total, err := q.ScanAndCount(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+func (r *MediaTaskRepo) GetDLQ(ctx context.Context, id string) (*models.MediaTaskDLQEntry, error) {
+	var entry models.MediaTaskDLQEntry
+	err := r.db.NewSelect().Model(&entry).Where("dlq.id = ?", id).Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &entry, err
+}
+
+// CountDLQMessages 按任务类型 + 原始错误信息聚合，供上层进一步归一化分组
+func (r *MediaTaskRepo) CountDLQMessages(ctx context.Context, f MediaTaskDLQFilter) ([]MediaTaskDLQMessageCount, error) {
+	var rows []MediaTaskDLQMessageCount
+	err := applyDLQFilter(r.db.NewSelect().Model((*models.MediaTaskDLQEntry)(nil)), f).
+		Column("dlq.task_type", "dlq.error_message").
+		ColumnExpr("COUNT(*) AS cnt").
+		ColumnExpr("MIN(dlq.created_at) AS first_at").
+		ColumnExpr("MAX(dlq.created_at) AS last_at").
+		GroupExpr("dlq.task_type, dlq.error_message").
+		Scan(ctx, &rows)
+	return rows, err
+}
+
+// ReplayDLQ 将死信对应的任务重置为 pending（清零重试次数）并移出死信队列，
+// 因其级联失败的下游任务一并恢复。同资产同类型已有进行中的任务时只移除死信；
+// 原任务已被删除时按原 id、优先级与重试上限重建。原任务已不在 failed 状态（已完成 / 已取消 / 已重新入队）
+// 的死信视为过期，保持不动并在 stale 中返回
+func (r *MediaTaskRepo) ReplayDLQ(ctx context.Context, entries []models.MediaTaskDLQEntry) (replayed []models.MediaTaskDLQEntry, stale []models.MediaTaskDLQEntry, err error) {
+	replayed = make([]models.MediaTaskDLQEntry, 0, len(entries))
+	for i := range entries {
+		entry := entries[i]
+		isStale := false
+		err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			now := time.Now()
+			var task models.MediaTask
+			err := tx.NewSelect().Model(&task).Where("id = ?", entry.TaskID).Scan(ctx)
+			exists := err == nil
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+			if exists && task.Status != models.TaskStatusFailed {
+				isStale = true
+				return nil
+			}
+
+			inflight, err := tx.NewSelect().
+				Model((*models.MediaTask)(nil)).
+				Where("asset_id = ?", entry.AssetID).
+				Where("task_type = ?", entry.TaskType).
+				Where("status IN (?)", bun.In([]models.TaskStatus{models.TaskStatusPending, models.TaskStatusProcessing})).
+				Count(ctx)
+			if err != nil {
+				return err
+			}
+			switch {
+			case inflight > 0:
+			case exists:
+				if _, err := tx.NewUpdate().
+					Model((*models.MediaTask)(nil)).
+					Set("status = ?", models.TaskStatusPending).
+					Set("retry_count = 0").
+					Set("error_message = ?", "").
+					Set("dead_letter_reason = ?", "").
+					Set("progress = 0").
+					Set("worker_id = ?", "").
+					Set("next_retry_at = NULL").
+					Set("started_at = NULL").
+					Set("finished_at = NULL").
+					Set("lease_until = NULL").
+					Set("updated_at = ?", now).
+					Where("id = ?", entry.TaskID).
+					Where("status = ?", models.TaskStatusFailed).
+					Exec(ctx); err != nil {
+					return err
+				}
+				if err := reviveDependents(ctx, tx, entry.TaskID); err != nil {
+					return err
+				}
+			default:
+				task := &models.MediaTask{
+					ID:         entry.TaskID,
+					AssetID:    entry.AssetID,
+					TaskType:   entry.TaskType,
+					Status:     models.TaskStatusPending,
+					Priority:   entry.Priority,
+					MaxRetries: entry.MaxRetries,
+					CreatedAt:  now,
+					UpdatedAt:  now,
+				}
+				if _, err := tx.NewInsert().Model(task).Exec(ctx); err != nil {
+					return err
+				}
+			}
+			_, err = tx.NewDelete().
+				Model((*models.MediaTaskDLQEntry)(nil)).
+				Where("id = ?", entry.ID).
+				Exec(ctx)
+			return err
+		})
+		if err != nil {
+			return replayed, stale, err
+		}
+		if isStale {
+			stale = append(stale, entry)
+			continue
+		}
+		replayed = append(replayed, entry)
+	}
+	return replayed, stale, nil
+}
+
+// DeleteDLQ 按 id 删除死信（分批，避免超出 SQLite 参数上限）
+func (r *MediaTaskRepo) DeleteDLQ(ctx context.Context, ids []string) (int64, error) {
+	var total int64
+	for start := 0; start < len(ids); start += 500 {
+		end := start + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+		res, err := r.db.NewDelete().
+			Model((*models.MediaTaskDLQEntry)(nil)).
+			Where("id IN (?)", bun.In(ids[start:end])).
+			Exec(ctx)
+		if err != nil {
+			return total, err
+		}
+		n, _ := res.RowsAffected()
+		total += n
+	}
+	return total, nil
+}
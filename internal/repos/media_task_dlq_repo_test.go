@@ -0,0 +1,89 @@
+package repos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+func TestMediaTaskRepo_ReplayDLQ(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	tasks := NewMediaTaskRepo(orm)
+	assets := NewAssetRepo(orm)
+
+	a, err := assets.Create(ctx, "/media/clip.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, task := range []*models.MediaTask{
+		{ID: "failed", AssetID: a.ID, TaskType: "thumbnail", Status: models.TaskStatusFailed, Priority: 5, MaxRetries: 3},
+		{ID: "done", AssetID: a.ID, TaskType: "metadata", Status: models.TaskStatusCompleted, Priority: 5, MaxRetries: 3},
+	} {
+		task.CreatedAt, task.UpdatedAt = now, now
+		if _, err := orm.NewInsert().Model(task).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries := []models.MediaTaskDLQEntry{
+		{ID: "d1", TaskID: "failed", AssetID: a.ID, TaskType: "thumbnail", ErrorMessage: "boom", MaxRetries: 3, Priority: 5},
+		{ID: "d2", TaskID: "done", AssetID: a.ID, TaskType: "metadata", ErrorMessage: "boom", MaxRetries: 3, Priority: 5},
+		{ID: "d3", TaskID: "gone", AssetID: a.ID, TaskType: "waveform", ErrorMessage: "boom", MaxRetries: 7, Priority: 80},
+	}
+	for i := range entries {
+		entries[i].CreatedAt = now
+		if _, err := orm.NewInsert().Model(&entries[i]).Exec(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	replayed, stale, err := tasks.ReplayDLQ(ctx, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 2 || len(stale) != 1 || stale[0].ID != "d2" {
+		t.Fatalf("replayed=%d stale=%+v", len(replayed), stale)
+	}
+
+	for _, tc := range []struct {
+		id       string
+		status   models.TaskStatus
+		priority int
+		retries  int
+	}{
+		{"failed", models.TaskStatusPending, 5, 3},
+		{"done", models.TaskStatusCompleted, 5, 3},
+		{"gone", models.TaskStatusPending, 80, 7},
+	} {
+		var task models.MediaTask
+		if err := orm.NewSelect().Model(&task).Where("id = ?", tc.id).Scan(ctx); err != nil {
+			t.Fatalf("%s: %v", tc.id, err)
+		}
+		if task.Status != tc.status || task.Priority != tc.priority || task.MaxRetries != tc.retries {
+			t.Errorf("%s: got status=%s priority=%d max_retries=%d", tc.id, task.Status, task.Priority, task.MaxRetries)
+		}
+	}
+	n, err := orm.NewSelect().Model((*models.MediaTaskDLQEntry)(nil)).Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("remaining dlq entries = %d, want 1 (the stale one)", n)
+	}
+
+	// 再次重放同一批不会插入重复任务
+	if _, _, err := tasks.ReplayDLQ(ctx, entries[2:]); err != nil {
+		t.Fatal(err)
+	}
+	cnt, err := orm.NewSelect().Model((*models.MediaTask)(nil)).Where("task_type = ?", "waveform").Count(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cnt != 1 {
+		t.Fatalf("waveform tasks = %d, want 1", cnt)
+	}
+}
@@ -3,6 +3,7 @@ package repos
 import (
 	"context"
 	"database/sql"
+	"sort"
 	"time"
 
 	"media-assistant-os/internal/models"
@@ -26,6 +27,12 @@ func NewMediaTaskRepo(db *bun.DB) *MediaTaskRepo {
 }
 
 func (r *MediaTaskRepo) Create(ctx context.Context, assetID, taskType string, priority int) (*models.MediaTask, error) {
+	return r.CreateWithDeps(ctx, assetID, taskType, priority, nil)
+}
+
+// CreateWithDeps 创建任务并在同一事务内写入依赖，避免任务在依赖落库前被领取。
+// 同资产同类型已有进行中的任务时直接返回该任务。
+func (r *MediaTaskRepo) CreateWithDeps(ctx context.Context, assetID, taskType string, priority int, dependsOn []string) (*models.MediaTask, error) {
 	existing, err := r.GetInflightByAssetAndType(ctx, assetID, taskType)
 	if err != nil {
 		return nil, err
@@ -44,7 +51,18 @@ func (r *MediaTaskRepo) Create(ctx context.Context, assetID, taskType string, pr
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
-	_, err = r.db.NewInsert().Model(task).Exec(ctx)
+	err = r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(task).Exec(ctx); err != nil {
+			return err
+		}
+		for _, parentID := range dependsOn {
+			dep := &models.MediaTaskDep{TaskID: task.ID, DependsOnTaskID: parentID}
+			if _, err := tx.NewInsert().Model(dep).Ignore().Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		existing, findErr := r.GetInflightByAssetAndType(ctx, assetID, taskType)
 		if findErr == nil && existing != nil {
@@ -60,7 +78,8 @@ func (r *MediaTaskRepo) GetNextPending(ctx context.Context, taskTypes []string)
 	q := r.db.NewSelect().
 		Model(&task).
 		Where("status = ?", models.TaskStatusPending).
-		Where("(next_retry_at IS NULL OR next_retry_at <= CURRENT_TIMESTAMP)")
+		Where("(next_retry_at IS NULL OR next_retry_at <= CURRENT_TIMESTAMP)").
+		Where(depsMetSQL("mt.id"))
 
 	if len(taskTypes) > 0 {
 		q = q.Where("task_type IN (?)", bun.In(taskTypes))
@@ -90,6 +109,7 @@ func (r *MediaTaskRepo) Claim(ctx context.Context, taskID, workerID string, leas
 		Where("id = ?", taskID).
 		Where("status = ?", models.TaskStatusPending).
 		Where("(next_retry_at IS NULL OR next_retry_at <= CURRENT_TIMESTAMP)").
+		Where(depsMetSQL("?"), taskID).
 		Exec(ctx)
 
 	if err != nil {
@@ -99,6 +119,82 @@ func (r *MediaTaskRepo) Claim(ctx context.Context, taskID, workerID string, leas
 	return rows > 0, nil
 }
 
+// ClaimNext 原子领取至多 max 个可执行任务（按优先级、创建时间），单条 UPDATE ... RETURNING 完成选择与加锁
+func (r *MediaTaskRepo) ClaimNext(ctx context.Context, taskTypes []string, workerID string, leaseUntil time.Time, max int) ([]models.MediaTask, error) {
+	if max <= 0 {
+		max = 1
+	}
+	candidates := r.db.NewSelect().
+		Model((*models.MediaTask)(nil)).
+		Column("mt.id").
+		Where("mt.status = ?", models.TaskStatusPending).
+		Where("(mt.next_retry_at IS NULL OR mt.next_retry_at <= CURRENT_TIMESTAMP)").
+		Where(depsMetSQL("mt.id"))
+	if len(taskTypes) > 0 {
+		candidates = candidates.Where("mt.task_type IN (?)", bun.In(taskTypes))
+	}
+	candidates = candidates.
+		Order("mt.priority DESC", "mt.created_at ASC").
+		Limit(max)
+
+	now := time.Now()
+	var tasks []models.MediaTask
+	_, err := r.db.NewUpdate().
+		Model(&tasks).
+		Set("status = ?", models.TaskStatusProcessing).
+		Set("worker_id = ?", workerID).
+		Set("progress = ?", 0).
+		Set("next_retry_at = NULL").
+		Set("started_at = ?", now).
+		Set("updated_at = ?", now).
+		Set("lease_until = ?", leaseUntil).
+		Where("id IN (?)", candidates).
+		Where("status = ?", models.TaskStatusPending).
+		Returning("*").
+		Exec(ctx, &tasks)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		if tasks[i].Priority != tasks[j].Priority {
+			return tasks[i].Priority > tasks[j].Priority
+		}
+		return tasks[i].CreatedAt.Before(tasks[j].CreatedAt)
+	})
+	return tasks, nil
+}
+
+// ClaimByIDs 领取指定的一批待处理任务（调用方已按并发上限筛选），仍为 pending 的才会被领取
+func (r *MediaTaskRepo) ClaimByIDs(ctx context.Context, ids []string, workerID string, leaseUntil time.Time) ([]models.MediaTask, error) {
+	if len(ids) == 0 {
+		return []models.MediaTask{}, nil
+	}
+	now := time.Now()
+	var tasks []models.MediaTask
+	_, err := r.db.NewUpdate().
+		Model(&tasks).
+		Set("status = ?", models.TaskStatusProcessing).
+		Set("worker_id = ?", workerID).
+		Set("progress = ?", 0).
+		Set("next_retry_at = NULL").
+		Set("started_at = ?", now).
+		Set("updated_at = ?", now).
+		Set("lease_until = ?", leaseUntil).
+		Where("id IN (?)", bun.In(ids)).
+		Where("status = ?", models.TaskStatusPending).
+		Returning("*").
+		Exec(ctx, &tasks)
+	if err != nil {
+		return nil, err
+	}
+	order := make(map[string]int, len(ids))
+	for i, id := range ids {
+		order[id] = i
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return order[tasks[i].ID] < order[tasks[j].ID] })
+	return tasks, nil
+}
+
 func (r *MediaTaskRepo) RenewLease(ctx context.Context, taskID, workerID string, leaseUntil time.Time) (bool, error) {
 	res, err := r.db.NewUpdate().
 		Model((*models.MediaTask)(nil)).
@@ -126,7 +222,13 @@ func (r *MediaTaskRepo) UpdateProgress(ctx context.Context, taskID string, progr
 }
 
 func (r *MediaTaskRepo) Complete(ctx context.Context, taskID string, workerID string) error {
-	_, err := r.db.NewUpdate().
+	_, err := completeTask(ctx, r.db, taskID, workerID)
+	return err
+}
+
+// completeTask 将处理中的任务置为完成，返回受影响行数（0 表示任务已不属于该 worker）
+func completeTask(ctx context.Context, db bun.IDB, taskID string, workerID string) (int64, error) {
+	res, err := db.NewUpdate().
 		Model((*models.MediaTask)(nil)).
 		Set("status = ?", models.TaskStatusCompleted).
 		Set("progress = ?", 100).
@@ -139,7 +241,10 @@ func (r *MediaTaskRepo) Complete(ctx context.Context, taskID string, workerID st
 		Where("status = ?", models.TaskStatusProcessing).
 		Where("worker_id = ?", workerID).
 		Exec(ctx)
-	return err
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
 }
 
 func (r *MediaTaskRepo) Fail(ctx context.Context, taskID, workerID, errMsg string) (TaskFailOutcome, error) {
@@ -201,17 +306,17 @@ func (r *MediaTaskRepo) Fail(ctx context.Context, taskID, workerID, errMsg strin
 	}
 
 	_, err = r.db.NewInsert().
-		Model(map[string]any{
-			"id":            utils.NewID(),
-			"task_id":       taskID,
-			"asset_id":      task.AssetID,
-			"task_type":     task.TaskType,
-			"error_message": errMsg,
-			"retry_count":   nextRetryCount,
-			"max_retries":   task.MaxRetries,
-			"created_at":    now,
+		Model(&models.MediaTaskDLQEntry{
+			ID:           utils.NewID(),
+			TaskID:       taskID,
+			AssetID:      task.AssetID,
+			TaskType:     task.TaskType,
+			ErrorMessage: errMsg,
+			RetryCount:   nextRetryCount,
+			MaxRetries:   task.MaxRetries,
+			Priority:     task.Priority,
+			CreatedAt:    now,
 		}).
-		Table("media_task_dlq").
 		Exec(ctx)
 	if err != nil {
 		return TaskFailOutcome{}, err
@@ -232,6 +337,7 @@ func (r *MediaTaskRepo) RequeueExpiredLeases(ctx context.Context) (int64, error)
 		Set("lease_until = NULL").
 		Set("updated_at = ?", time.Now()).
 		Where("status = ?", models.TaskStatusProcessing).
+		Where("cancel_requested = 0").
 		Where("lease_until IS NOT NULL AND lease_until <= CURRENT_TIMESTAMP").
 		Exec(ctx)
 	if err != nil {
@@ -240,6 +346,28 @@ func (r *MediaTaskRepo) RequeueExpiredLeases(ctx context.Context) (int64, error)
 	return res.RowsAffected()
 }
 
+// Postpone 处理中的任务放回待处理并推迟到 until，不消耗重试次数（如源文件所在卷离线）
+func (r *MediaTaskRepo) Postpone(ctx context.Context, taskID, workerID string, until time.Time, reason string) (bool, error) {
+	res, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("status = ?", models.TaskStatusPending).
+		Set("error_message = ?", reason).
+		Set("next_retry_at = ?", until).
+		Set("worker_id = ?", "").
+		Set("started_at = NULL").
+		Set("lease_until = NULL").
+		Set("updated_at = ?", time.Now()).
+		Where("id = ?", taskID).
+		Where("worker_id = ?", workerID).
+		Where("status = ?", models.TaskStatusProcessing).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
 func (r *MediaTaskRepo) GetActiveTasks(ctx context.Context) ([]models.MediaTask, error) {
 	var tasks []models.MediaTask
 	err := r.db.NewSelect().
@@ -321,6 +449,19 @@ func (r *MediaTaskRepo) CountDLQ(ctx context.Context) (int, error) {
 }
 
 func (r *MediaTaskRepo) ResetProcessingTasks(ctx context.Context) (int64, error) {
+	// 重启前已标记取消的任务不再重跑
+	if _, err := r.db.NewUpdate().
+		Model((*models.MediaTask)(nil)).
+		Set("status = ?", models.TaskStatusCancelled).
+		Set("error_message = ?", taskCancelledReason).
+		Set("lease_until = NULL").
+		Set("finished_at = ?", time.Now()).
+		Set("updated_at = ?", time.Now()).
+		Where("status = ?", models.TaskStatusProcessing).
+		Where("cancel_requested = 1").
+		Exec(ctx); err != nil {
+		return 0, err
+	}
 	res, err := r.db.NewUpdate().
 		Model((*models.MediaTask)(nil)).
 		Set("status = ?", models.TaskStatusPending).
@@ -0,0 +1,303 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/utils"
+
+	"github.com/uptrace/bun"
+)
+
+// ErrTaskNotOwned 任务已不处于该 worker 的处理中状态（租约过期被回收、已取消等）
+var ErrTaskNotOwned = errors.New("task is no longer processing by this worker")
+
+// TaskResultTags 写入插件命名空间下的标签；Namespace 为根标签名，子标签名为 "<namespace>:<tag>"
+type TaskResultTags struct {
+	Source    string
+	Namespace string
+	Names     []string
+}
+
+// TaskResultNote 写入资产所属全部项目的笔记；同一项目 / 资产 / note_type 只保留一条，重跑时更新
+type TaskResultNote struct {
+	Source   string
+	NoteType string
+	Title    string
+	Content  string
+}
+
+// TaskResultMeta 写入 media_meta.extra.<Key>，整体替换该键
+type TaskResultMeta struct {
+	Source string
+	Key    string
+	Value  json.RawMessage
+}
+
+// TaskResultApply 一次任务结果的全部写入，与任务完成在同一事务内
+type TaskResultApply struct {
+	TaskID   string
+	WorkerID string
+	AssetID  string
+	TaskType string
+	PluginID string
+	Tags     []TaskResultTags
+	Notes    []TaskResultNote
+	Meta     []TaskResultMeta
+}
+
+type taskResultTx struct {
+	tx    bun.Tx
+	apply *TaskResultApply
+	now   int64
+}
+
+// CompleteWithResult 在一个事务内完成任务并应用结果映射、记录来源；任务已不属于该 worker 时整体回滚
+func (r *MediaTaskRepo) CompleteWithResult(ctx context.Context, apply *TaskResultApply) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := completeTask(ctx, tx, apply.TaskID, apply.WorkerID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return ErrTaskNotOwned
+		}
+		t := &taskResultTx{tx: tx, apply: apply, now: time.Now().Unix()}
+		for i := range apply.Tags {
+			if err := t.applyTags(ctx, apply.Tags[i]); err != nil {
+				return err
+			}
+		}
+		for i := range apply.Notes {
+			if err := t.applyNote(ctx, apply.Notes[i]); err != nil {
+				return err
+			}
+		}
+		for i := range apply.Meta {
+			if err := t.applyMeta(ctx, apply.Meta[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (t *taskResultTx) record(ctx context.Context, source, kind, targetID string, value any) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = t.tx.NewInsert().Model(&models.TaskResultProvenance{
+		ID:         utils.NewID(),
+		TaskID:     t.apply.TaskID,
+		AssetID:    t.apply.AssetID,
+		TaskType:   t.apply.TaskType,
+		PluginID:   t.apply.PluginID,
+		WorkerID:   t.apply.WorkerID,
+		Source:     source,
+		TargetKind: kind,
+		TargetID:   targetID,
+		ValueJSON:  string(raw),
+		CreatedAt:  t.now,
+	}).Exec(ctx)
+	return err
+}
+
+// ensureTag 按名称查找标签，不存在时创建。
+// tags.name 全局唯一（与 TagRepo.GetByName 一致），命名空间已编码在名称中（"namespace:name"），
+// 因此按名称查找即按命名空间查找；同名的用户标签会被复用而不是重复创建
+func (t *taskResultTx) ensureTag(ctx context.Context, name string, parentID *string) (string, error) {
+	var tag models.Tag
+	err := t.tx.NewSelect().Model(&tag).Where("name = ?", name).Limit(1).Scan(ctx)
+	if err == nil {
+		return tag.ID, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", err
+	}
+	tag = models.Tag{ID: utils.NewID(), Name: name, ParentID: parentID, CreatedAt: t.now, UpdatedAt: t.now}
+	if _, err := t.tx.NewInsert().Model(&tag).Exec(ctx); err != nil {
+		return "", err
+	}
+	return tag.ID, nil
+}
+
+// applyTags 以结果为准替换资产在该命名空间下的标签（用户自己的标签不受影响）
+func (t *taskResultTx) applyTags(ctx context.Context, in TaskResultTags) error {
+	rootID, err := t.ensureTag(ctx, in.Namespace, nil)
+	if err != nil {
+		return err
+	}
+	tagIDs := make([]string, 0, len(in.Names))
+	for _, name := range in.Names {
+		id, err := t.ensureTag(ctx, in.Namespace+":"+name, &rootID)
+		if err != nil {
+			return err
+		}
+		tagIDs = append(tagIDs, id)
+	}
+
+	del := t.tx.NewDelete().
+		Model((*models.AssetTag)(nil)).
+		Where("asset_id = ?", t.apply.AssetID).
+		Where("tag_id IN (SELECT id FROM tags WHERE parent_id = ?)", rootID)
+	if len(tagIDs) > 0 {
+		del = del.Where("tag_id NOT IN (?)", bun.In(tagIDs))
+	}
+	if _, err := del.Exec(ctx); err != nil {
+		return err
+	}
+	for i, id := range tagIDs {
+		if _, err := t.tx.NewInsert().
+			Model(&models.AssetTag{AssetID: t.apply.AssetID, TagID: id, CreatedAt: t.now}).
+			On("CONFLICT DO NOTHING").
+			Exec(ctx); err != nil {
+			return err
+		}
+		if err := t.record(ctx, in.Source, "asset_tag", id, in.Names[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyNote 资产不属于任何项目时只记录来源、不写笔记
+func (t *taskResultTx) applyNote(ctx context.Context, in TaskResultNote) error {
+	var projectIDs []string
+	if err := t.tx.NewSelect().
+		Table("project_assets").
+		Column("project_id").
+		Where("asset_id = ?", t.apply.AssetID).
+		Scan(ctx, &projectIDs); err != nil {
+		return err
+	}
+	meta, err := json.Marshal(map[string]string{
+		"plugin_id": t.apply.PluginID,
+		"task_id":   t.apply.TaskID,
+		"task_type": t.apply.TaskType,
+	})
+	if err != nil {
+		return err
+	}
+	if len(projectIDs) == 0 {
+		return t.record(ctx, in.Source, "project_note", "", in.Content)
+	}
+	for _, projectID := range projectIDs {
+		var existing models.ProjectNote
+		err := t.tx.NewSelect().
+			Model(&existing).
+			Where("project_id = ?", projectID).
+			Where("source_asset_id = ?", t.apply.AssetID).
+			Where("note_type = ?", in.NoteType).
+			Limit(1).
+			Scan(ctx)
+		noteID := existing.ID
+		switch {
+		case err == nil:
+			if _, err := t.tx.NewUpdate().
+				Model((*models.ProjectNote)(nil)).
+				Set("title = ?", in.Title).
+				Set("content = ?", in.Content).
+				Set("meta_json = ?", string(meta)).
+				Set("updated_at = ?", t.now).
+				Where("id = ?", noteID).
+				Exec(ctx); err != nil {
+				return err
+			}
+		case err == sql.ErrNoRows:
+			var workflowIDs []string
+			if err := t.tx.NewSelect().
+				Table("project_workflows").
+				Column("id").
+				Where("project_id = ?", projectID).
+				Limit(1).
+				Scan(ctx, &workflowIDs); err != nil {
+				return err
+			}
+			note := &models.ProjectNote{
+				ID:            utils.NewID(),
+				ProjectID:     projectID,
+				NoteType:      in.NoteType,
+				Title:         in.Title,
+				Content:       in.Content,
+				SourceAssetID: &t.apply.AssetID,
+				Status:        "active",
+				MetaJSON:      string(meta),
+				CreatedAt:     t.now,
+				UpdatedAt:     t.now,
+			}
+			if len(workflowIDs) > 0 {
+				note.WorkflowID = &workflowIDs[0]
+			}
+			if _, err := t.tx.NewInsert().Model(note).Exec(ctx); err != nil {
+				return err
+			}
+			noteID = note.ID
+		default:
+			return err
+		}
+		if err := t.record(ctx, in.Source, "project_note", noteID, in.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *taskResultTx) applyMeta(ctx context.Context, in TaskResultMeta) error {
+	var metaRaw []string
+	if err := t.tx.NewSelect().
+		Table("assets").
+		Column("media_meta").
+		Where("id = ?", t.apply.AssetID).
+		Scan(ctx, &metaRaw); err != nil {
+		return err
+	}
+	meta := map[string]any{}
+	if len(metaRaw) > 0 && strings.TrimSpace(metaRaw[0]) != "" {
+		_ = json.Unmarshal([]byte(metaRaw[0]), &meta)
+	}
+	extra, _ := meta["extra"].(map[string]any)
+	if extra == nil {
+		extra = map[string]any{}
+	}
+	extra[in.Key] = in.Value
+	meta["extra"] = extra
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if _, err := t.tx.NewUpdate().
+		Table("assets").
+		Set("media_meta = ?", string(raw)).
+		Set("updated_at = ?", t.now).
+		Where("id = ?", t.apply.AssetID).
+		Exec(ctx); err != nil {
+		return err
+	}
+	return t.record(ctx, in.Source, "media_meta", "extra."+in.Key, in.Value)
+}
+
+// ListResultProvenance 按资产 / 任务查询结果来源记录（最新在前）
+func (r *MediaTaskRepo) ListResultProvenance(ctx context.Context, assetID, taskID string, limit int) ([]models.TaskResultProvenance, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 200
+	}
+	var out []models.TaskResultProvenance
+	q := r.db.NewSelect().
+		Model(&out).
+		Order("trp.created_at DESC", "trp.id ASC").
+		Limit(limit)
+	if assetID != "" {
+		q = q.Where("trp.asset_id = ?", assetID)
+	}
+	if taskID != "" {
+		q = q.Where("trp.task_id = ?", taskID)
+	}
+	err := q.Scan(ctx)
+	return out, err
+}
@@ -0,0 +1,113 @@
+package repos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMediaTaskRepo_CompleteWithResult(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	tasks := NewMediaTaskRepo(orm)
+	assets := NewAssetRepo(orm)
+
+	a, err := assets.Create(ctx, "/lib/cat.jpg", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orm.ExecContext(ctx,
+		"INSERT INTO media_tasks (id, asset_id, task_type, status, worker_id) VALUES ('t1', ?, 'ai_tags', 'processing', 'w1')", a.ID); err != nil {
+		t.Fatal(err)
+	}
+	// 来源为 boom 的写入在记录来源时失败，模拟映射中途出错
+	if _, err := orm.ExecContext(ctx, `CREATE TRIGGER test_fail_provenance BEFORE INSERT ON task_result_provenance
+		WHEN NEW.source = 'boom' BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	apply := func(metaSource string) *TaskResultApply {
+		return &TaskResultApply{
+			TaskID:   "t1",
+			WorkerID: "w1",
+			AssetID:  a.ID,
+			TaskType: "ai_tags",
+			PluginID: "tagger",
+			Tags:     []TaskResultTags{{Source: "tags[]", Namespace: "tagger", Names: []string{"cat", "pet"}}},
+			Meta:     []TaskResultMeta{{Source: metaSource, Key: "tagger", Value: json.RawMessage(`{"score":1}`)}},
+		}
+	}
+	count := func(query string, args ...any) int {
+		var n int
+		if err := orm.QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	status := func() string {
+		var s string
+		if err := orm.QueryRowContext(ctx, "SELECT status FROM media_tasks WHERE id = 't1'").Scan(&s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	if err := tasks.CompleteWithResult(ctx, apply("boom")); err == nil {
+		t.Fatal("expected apply failure")
+	}
+	if got := status(); got != "processing" {
+		t.Fatalf("status after failed apply = %s, want processing", got)
+	}
+	if n := count("SELECT COUNT(*) FROM tags"); n != 0 {
+		t.Fatalf("tags after rollback = %d", n)
+	}
+	if n := count("SELECT COUNT(*) FROM asset_tags"); n != 0 {
+		t.Fatalf("asset_tags after rollback = %d", n)
+	}
+	if n := count("SELECT COUNT(*) FROM task_result_provenance"); n != 0 {
+		t.Fatalf("provenance after rollback = %d", n)
+	}
+
+	wrongWorker := apply("fields")
+	wrongWorker.WorkerID = "w2"
+	if err := tasks.CompleteWithResult(ctx, wrongWorker); !errors.Is(err, ErrTaskNotOwned) {
+		t.Fatalf("foreign worker error = %v, want ErrTaskNotOwned", err)
+	}
+
+	if err := tasks.CompleteWithResult(ctx, apply("fields")); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got != "completed" {
+		t.Fatalf("status = %s, want completed", got)
+	}
+	if n := count("SELECT COUNT(*) FROM asset_tags WHERE asset_id = ?", a.ID); n != 2 {
+		t.Fatalf("asset_tags = %d, want 2", n)
+	}
+	if n := count("SELECT COUNT(*) FROM tags WHERE name IN ('tagger', 'tagger:cat', 'tagger:pet')"); n != 3 {
+		t.Fatalf("namespace tags = %d, want 3", n)
+	}
+	var meta string
+	if err := orm.QueryRowContext(ctx, "SELECT media_meta FROM assets WHERE id = ?", a.ID).Scan(&meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta != `{"extra":{"tagger":{"score":1}}}` {
+		t.Fatalf("media_meta = %s", meta)
+	}
+
+	prov, err := tasks.ListResultProvenance(ctx, a.ID, "t1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := map[string]int{}
+	for _, p := range prov {
+		if p.PluginID != "tagger" || p.WorkerID != "w1" || p.TaskType != "ai_tags" {
+			t.Errorf("provenance = %+v", p)
+		}
+		kinds[p.TargetKind+":"+p.Source]++
+	}
+	if kinds["asset_tag:tags[]"] != 2 || kinds["media_meta:fields"] != 1 || len(prov) != 3 {
+		t.Fatalf("provenance kinds = %v", kinds)
+	}
+}
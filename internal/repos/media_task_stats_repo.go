@@ -0,0 +1,64 @@
+package repos
+
+import (
+	"context"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+// TaskQueueStat 某任务类型在某状态下的任务数与最早创建时间
+type TaskQueueStat struct {
+	TaskType      string    `bun:"task_type"`
+	Status        string    `bun:"status"`
+	Count         int       `bun:"cnt"`
+	OldestCreated time.Time `bun:"oldest_created"`
+}
+
+// TaskTypeCount 按任务类型的计数
+type TaskTypeCount struct {
+	TaskType string `bun:"task_type"`
+	Count    int    `bun:"cnt"`
+}
+
+// QueueStats 待处理 / 处理中任务按类型的数量与最早创建时间
+func (r *MediaTaskRepo) QueueStats(ctx context.Context) ([]TaskQueueStat, error) {
+	var rows []TaskQueueStat
+	err := r.db.NewSelect().
+		Model((*models.MediaTask)(nil)).
+		Column("mt.task_type", "mt.status").
+		ColumnExpr("COUNT(*) AS cnt").
+		ColumnExpr("MIN(mt.created_at) AS oldest_created").
+		Where("mt.status IN (?)", bun.In([]models.TaskStatus{models.TaskStatusPending, models.TaskStatusProcessing})).
+		GroupExpr("mt.task_type, mt.status").
+		Scan(ctx, &rows)
+	return rows, err
+}
+
+// CountDLQByType 死信按任务类型计数
+func (r *MediaTaskRepo) CountDLQByType(ctx context.Context) ([]TaskTypeCount, error) {
+	var rows []TaskTypeCount
+	err := r.db.NewSelect().
+		Model((*models.MediaTaskDLQEntry)(nil)).
+		Column("dlq.task_type").
+		ColumnExpr("COUNT(*) AS cnt").
+		GroupExpr("dlq.task_type").
+		Scan(ctx, &rows)
+	return rows, err
+}
+
+// CountCompletedByTypeSince 自 since 起按任务类型统计完成数（用于吞吐）
+func (r *MediaTaskRepo) CountCompletedByTypeSince(ctx context.Context, since time.Time) ([]TaskTypeCount, error) {
+	var rows []TaskTypeCount
+	err := r.db.NewSelect().
+		Model((*models.MediaTask)(nil)).
+		Column("mt.task_type").
+		ColumnExpr("COUNT(*) AS cnt").
+		Where("mt.status = ?", models.TaskStatusCompleted).
+		Where("mt.finished_at >= ?", since).
+		GroupExpr("mt.task_type").
+		Scan(ctx, &rows)
+	return rows, err
+}
@@ -0,0 +1,201 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type MetricsRepo struct {
+	db *bun.DB
+}
+
+// MetricsPostRef 标识一个帖子的指标序列
+type MetricsPostRef struct {
+	ProjectID      string `bun:"project_id"`
+	Platform       string `bun:"platform"`
+	ExternalPostID string `bun:"external_post_id"`
+	OccurredAt     int64  `bun:"occurred_at"`
+}
+
+type MetricsSnapshotQuery struct {
+	ProjectID      string
+	Platform       string
+	ExternalPostID string
+	DateFrom       string // YYYY-MM-DD，含
+	DateTo         string // YYYY-MM-DD，含
+	Limit          int
+}
+
+func NewMetricsRepo(db *bun.DB) *MetricsRepo {
+	return &MetricsRepo{db: db}
+}
+
+// InsertEvent 写入指标点；命中去重键时返回 false
+func (r *MetricsRepo) InsertEvent(ctx context.Context, e *models.MetricsEvent) (bool, error) {
+	res, err := r.db.NewInsert().
+		Model(e).
+		On("CONFLICT (project_id, platform, external_post_id, metric_type, occurred_at) DO NOTHING").
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ListEventRefsCreatedAfter 返回 created_at 之后写入的指标点所属帖子与时间，用于增量汇总
+func (r *MetricsRepo) ListEventRefsCreatedAfter(ctx context.Context, createdAfter int64) ([]MetricsPostRef, error) {
+	var out []MetricsPostRef
+	err := r.db.NewSelect().
+		Model((*models.MetricsEvent)(nil)).
+		Column("project_id", "platform", "external_post_id", "occurred_at").
+		Where("created_at > ?", createdAfter).
+		Scan(ctx, &out)
+	return out, err
+}
+
+// ListEventRefsInRange 返回项目在 occurred_at 区间内有指标点的帖子
+func (r *MetricsRepo) ListEventRefsInRange(ctx context.Context, projectID string, from int64, to int64) ([]MetricsPostRef, error) {
+	var out []MetricsPostRef
+	q := r.db.NewSelect().
+		Model((*models.MetricsEvent)(nil)).
+		Column("project_id", "platform", "external_post_id", "occurred_at").
+		Where("occurred_at >= ?", from).
+		Where("occurred_at < ?", to)
+	if projectID != "" {
+		q = q.Where("project_id = ?", projectID)
+	}
+	err := q.Scan(ctx, &out)
+	return out, err
+}
+
+// ListPostEvents 返回某帖子在 [from, to) 内的指标点，按时间升序
+func (r *MetricsRepo) ListPostEvents(ctx context.Context, projectID string, platform string, externalPostID string, from int64, to int64) ([]models.MetricsEvent, error) {
+	var out []models.MetricsEvent
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		Where("platform = ?", platform).
+		Where("external_post_id = ?", externalPostID).
+		Where("occurred_at >= ?", from).
+		Where("occurred_at < ?", to).
+		OrderExpr("occurred_at ASC").
+		Scan(ctx)
+	return out, err
+}
+
+func (r *MetricsRepo) GetSnapshot(ctx context.Context, projectID string, externalPostID string, date string) (*models.MetricsSnapshotDaily, error) {
+	var out models.MetricsSnapshotDaily
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		Where("external_post_id = ?", externalPostID).
+		Where("snapshot_date = ?", date).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+// GetLatestSnapshotBefore 返回某帖子在 date 之前最近的一条快照
+func (r *MetricsRepo) GetLatestSnapshotBefore(ctx context.Context, projectID string, externalPostID string, date string) (*models.MetricsSnapshotDaily, error) {
+	var out models.MetricsSnapshotDaily
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		Where("external_post_id = ?", externalPostID).
+		Where("snapshot_date < ?", date).
+		OrderExpr("snapshot_date DESC").
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+// ListSnapshotDatesAfter 返回某帖子在 date 之后已有快照的日期（升序）
+func (r *MetricsRepo) ListSnapshotDatesAfter(ctx context.Context, projectID string, externalPostID string, date string) ([]string, error) {
+	var out []string
+	err := r.db.NewSelect().
+		Model((*models.MetricsSnapshotDaily)(nil)).
+		Column("snapshot_date").
+		Where("project_id = ?", projectID).
+		Where("external_post_id = ?", externalPostID).
+		Where("snapshot_date > ?", date).
+		OrderExpr("snapshot_date ASC").
+		Scan(ctx, &out)
+	return out, err
+}
+
+func (r *MetricsRepo) UpsertSnapshot(ctx context.Context, s *models.MetricsSnapshotDaily) error {
+	_, err := r.db.NewInsert().
+		Model(s).
+		On("CONFLICT (project_id, external_post_id, snapshot_date) DO UPDATE").
+		Set("record_id = EXCLUDED.record_id").
+		Set("platform = EXCLUDED.platform").
+		Set("views = EXCLUDED.views").
+		Set("likes = EXCLUDED.likes").
+		Set("comments = EXCLUDED.comments").
+		Set("shares = EXCLUDED.shares").
+		Set("favorites = EXCLUDED.favorites").
+		Set("followers_delta = EXCLUDED.followers_delta").
+		Set("meta_json = EXCLUDED.meta_json").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	return err
+}
+
+func (r *MetricsRepo) ListSnapshots(ctx context.Context, q MetricsSnapshotQuery) ([]models.MetricsSnapshotDaily, error) {
+	limit := q.Limit
+	if limit <= 0 || limit > 2000 {
+		limit = 500
+	}
+	var out []models.MetricsSnapshotDaily
+	sel := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", q.ProjectID).
+		OrderExpr("snapshot_date DESC, external_post_id ASC").
+		Limit(limit)
+	sel = applySnapshotFilters(sel, q)
+	err := sel.Scan(ctx)
+	return out, err
+}
+
+// ListSnapshotsUpTo 返回截至 DateTo 的全部快照（不限条数），用于计算累计值的逐日增量
+func (r *MetricsRepo) ListSnapshotsUpTo(ctx context.Context, q MetricsSnapshotQuery) ([]models.MetricsSnapshotDaily, error) {
+	var out []models.MetricsSnapshotDaily
+	sel := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", q.ProjectID).
+		OrderExpr("external_post_id ASC, snapshot_date ASC")
+	sel = applySnapshotFilters(sel, MetricsSnapshotQuery{
+		Platform:       q.Platform,
+		ExternalPostID: q.ExternalPostID,
+		DateTo:         q.DateTo,
+	})
+	err := sel.Scan(ctx)
+	return out, err
+}
+
+func applySnapshotFilters(sel *bun.SelectQuery, q MetricsSnapshotQuery) *bun.SelectQuery {
+	if q.Platform != "" {
+		sel = sel.Where("platform = ?", q.Platform)
+	}
+	if q.ExternalPostID != "" {
+		sel = sel.Where("external_post_id = ?", q.ExternalPostID)
+	}
+	if q.DateFrom != "" {
+		sel = sel.Where("snapshot_date >= ?", q.DateFrom)
+	}
+	if q.DateTo != "" {
+		sel = sel.Where("snapshot_date <= ?", q.DateTo)
+	}
+	return sel
+}
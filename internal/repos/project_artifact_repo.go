@@ -67,3 +67,13 @@ func (r *ProjectArtifactRepo) Delete(ctx context.Context, id string) error {
 		Exec(ctx)
 	return err
 }
+
+func (r *ProjectArtifactRepo) CountByProject(ctx context.Context, projectID string, kind string) (int, error) {
+	q := r.db.NewSelect().
+		Model((*models.ProjectArtifact)(nil)).
+		Where("project_id = ?", projectID)
+	if kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+	return q.Count(ctx)
+}
@@ -0,0 +1,61 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type ProjectNoteRepo struct {
+	db *bun.DB
+}
+
+func NewProjectNoteRepo(db *bun.DB) *ProjectNoteRepo {
+	return &ProjectNoteRepo{db: db}
+}
+
+func (r *ProjectNoteRepo) ListByProject(ctx context.Context, projectID string, noteType string, limit int) ([]models.ProjectNote, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 200
+	}
+	var out []models.ProjectNote
+	q := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		OrderExpr("is_pinned DESC, updated_at DESC").
+		Limit(limit)
+	if noteType != "" {
+		q = q.Where("note_type = ?", noteType)
+	}
+	err := q.Scan(ctx)
+	return out, err
+}
+
+func (r *ProjectNoteRepo) Get(ctx context.Context, id string) (*models.ProjectNote, error) {
+	var out models.ProjectNote
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *ProjectNoteRepo) Create(ctx context.Context, n *models.ProjectNote) error {
+	_, err := r.db.NewInsert().Model(n).Exec(ctx)
+	return err
+}
+
+func (r *ProjectNoteRepo) Update(ctx context.Context, n *models.ProjectNote) error {
+	_, err := r.db.NewUpdate().
+		Model(n).
+		Where("id = ?", n.ID).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,61 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type ProjectRoadmapRepo struct {
+	db *bun.DB
+}
+
+func NewProjectRoadmapRepo(db *bun.DB) *ProjectRoadmapRepo {
+	return &ProjectRoadmapRepo{db: db}
+}
+
+func (r *ProjectRoadmapRepo) ListByProject(ctx context.Context, projectID string, status string, limit int) ([]models.ProjectRoadmapItem, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 200
+	}
+	var out []models.ProjectRoadmapItem
+	q := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		OrderExpr("order_index ASC, created_at ASC").
+		Limit(limit)
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Scan(ctx)
+	return out, err
+}
+
+func (r *ProjectRoadmapRepo) Get(ctx context.Context, id string) (*models.ProjectRoadmapItem, error) {
+	var out models.ProjectRoadmapItem
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *ProjectRoadmapRepo) Create(ctx context.Context, item *models.ProjectRoadmapItem) error {
+	_, err := r.db.NewInsert().Model(item).Exec(ctx)
+	return err
+}
+
+func (r *ProjectRoadmapRepo) Update(ctx context.Context, item *models.ProjectRoadmapItem) error {
+	_, err := r.db.NewUpdate().
+		Model(item).
+		Where("id = ?", item.ID).
+		Exec(ctx)
+	return err
+}
@@ -133,6 +133,16 @@ func (r *ProjectSourceRepo) ListByProject(ctx context.Context, projectID string)
 	return out, err
 }
 
+// ListRootPaths 返回所有项目来源的根目录（去重）
+func (r *ProjectSourceRepo) ListRootPaths(ctx context.Context) ([]string, error) {
+	var out []string
+	err := r.db.NewSelect().
+		Model((*models.ProjectSource)(nil)).
+		ColumnExpr("DISTINCT root_path").
+		Scan(ctx, &out)
+	return out, err
+}
+
 func (r *ProjectSourceRepo) ListWatchEnabledByProject(ctx context.Context, projectID string) ([]models.ProjectSource, error) {
 	projectID = strings.TrimSpace(projectID)
 	if projectID == "" {
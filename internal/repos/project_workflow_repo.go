@@ -0,0 +1,119 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type ProjectWorkflowRepo struct {
+	db *bun.DB
+}
+
+func NewProjectWorkflowRepo(db *bun.DB) *ProjectWorkflowRepo {
+	return &ProjectWorkflowRepo{db: db}
+}
+
+func (r *ProjectWorkflowRepo) Get(ctx context.Context, id string) (*models.ProjectWorkflow, error) {
+	var out models.ProjectWorkflow
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *ProjectWorkflowRepo) GetByProject(ctx context.Context, projectID string) (*models.ProjectWorkflow, error) {
+	var out models.ProjectWorkflow
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+// CreateWithSteps 在同一事务中写入项目工作流及其步骤
+func (r *ProjectWorkflowRepo) CreateWithSteps(ctx context.Context, wf *models.ProjectWorkflow, steps []models.ProjectWorkflowStep) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(wf).Exec(ctx); err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return nil
+		}
+		_, err := tx.NewInsert().Model(&steps).Exec(ctx)
+		return err
+	})
+}
+
+func (r *ProjectWorkflowRepo) Update(ctx context.Context, wf *models.ProjectWorkflow) error {
+	_, err := r.db.NewUpdate().
+		Model(wf).
+		Where("id = ?", wf.ID).
+		Exec(ctx)
+	return err
+}
+
+// Delete 删除工作流及其步骤（SQLite 未开启外键时不会级联）
+func (r *ProjectWorkflowRepo) Delete(ctx context.Context, id string) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		return deleteWorkflowTx(ctx, tx, id)
+	})
+}
+
+// Reset 在同一事务中删除旧工作流、写入新工作流及步骤，并把项目的路线图条目与工作流笔记迁到新工作流；
+// 任一步失败整体回滚，项目不会落在没有工作流的中间状态
+func (r *ProjectWorkflowRepo) Reset(ctx context.Context, oldID string, wf *models.ProjectWorkflow, steps []models.ProjectWorkflowStep) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := deleteWorkflowTx(ctx, tx, oldID); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(wf).Exec(ctx); err != nil {
+			return err
+		}
+		if len(steps) > 0 {
+			if _, err := tx.NewInsert().Model(&steps).Exec(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewUpdate().
+			Model((*models.ProjectRoadmapItem)(nil)).
+			Set("workflow_id = ?", wf.ID).
+			Where("project_id = ?", wf.ProjectID).
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewUpdate().
+			Model((*models.ProjectNote)(nil)).
+			Set("workflow_id = ?", wf.ID).
+			Where("project_id = ?", wf.ProjectID).
+			Where("workflow_id IS NOT NULL").
+			Exec(ctx)
+		return err
+	})
+}
+
+func deleteWorkflowTx(ctx context.Context, tx bun.Tx, id string) error {
+	if _, err := tx.NewDelete().
+		Model((*models.ProjectWorkflowStep)(nil)).
+		Where("workflow_id = ?", id).
+		Exec(ctx); err != nil {
+		return err
+	}
+	_, err := tx.NewDelete().
+		Model((*models.ProjectWorkflow)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
@@ -0,0 +1,62 @@
+package repos
+
+import (
+	"context"
+	"testing"
+
+	"media-assistant-os/internal/models"
+)
+
+func TestProjectWorkflowRepo_ResetIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	workflows := NewProjectWorkflowRepo(orm)
+
+	old := &models.ProjectWorkflow{ID: "wf-old", ProjectID: "p1", TemplateID: "t1", MetaJSON: "{}", CreatedAt: 1, UpdatedAt: 1}
+	oldSteps := []models.ProjectWorkflowStep{{ID: "s-old", WorkflowID: "wf-old", Title: "Shoot", KPIRulesJSON: "[]", KPIResultJSON: "{}", MetaJSON: "{}", CreatedAt: 1, UpdatedAt: 1}}
+	if err := workflows.CreateWithSteps(ctx, old, oldSteps); err != nil {
+		t.Fatal(err)
+	}
+	wfID := "wf-old"
+	if _, err := orm.NewInsert().Model(&models.ProjectRoadmapItem{ID: "r1", ProjectID: "p1", WorkflowID: "wf-old", Title: "Cut", MetaJSON: "{}"}).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := orm.NewInsert().Model(&models.ProjectNote{ID: "n1", ProjectID: "p1", WorkflowID: &wfID, Title: "idea", MetaJSON: "{}"}).Exec(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新步骤 id 重复导致插入失败：整体回滚，旧工作流保持不变
+	failing := &models.ProjectWorkflow{ID: "wf-bad", ProjectID: "p1", TemplateID: "t2", MetaJSON: "{}", CreatedAt: 2, UpdatedAt: 2}
+	dup := []models.ProjectWorkflowStep{
+		{ID: "dup", WorkflowID: "wf-bad", Title: "a", KPIRulesJSON: "[]", KPIResultJSON: "{}", MetaJSON: "{}", CreatedAt: 2, UpdatedAt: 2},
+		{ID: "dup", WorkflowID: "wf-bad", Title: "b", KPIRulesJSON: "[]", KPIResultJSON: "{}", MetaJSON: "{}", CreatedAt: 2, UpdatedAt: 2},
+	}
+	if err := workflows.Reset(ctx, "wf-old", failing, dup); err == nil {
+		t.Fatal("expected reset to fail on duplicate step id")
+	}
+	if wf, err := workflows.GetByProject(ctx, "p1"); err != nil || wf == nil || wf.ID != "wf-old" {
+		t.Fatalf("old workflow should survive a failed reset, got %+v, %v", wf, err)
+	}
+
+	fresh := &models.ProjectWorkflow{ID: "wf-new", ProjectID: "p1", TemplateID: "t2", MetaJSON: "{}", CreatedAt: 3, UpdatedAt: 3}
+	steps := []models.ProjectWorkflowStep{{ID: "s-new", WorkflowID: "wf-new", Title: "Edit", KPIRulesJSON: "[]", KPIResultJSON: "{}", MetaJSON: "{}", CreatedAt: 3, UpdatedAt: 3}}
+	if err := workflows.Reset(ctx, "wf-old", fresh, steps); err != nil {
+		t.Fatal(err)
+	}
+	if wf, err := workflows.GetByProject(ctx, "p1"); err != nil || wf == nil || wf.ID != "wf-new" {
+		t.Fatalf("expected new workflow, got %+v, %v", wf, err)
+	}
+	n, err := orm.NewSelect().Model((*models.ProjectWorkflowStep)(nil)).Where("workflow_id = ?", "wf-old").Count(ctx)
+	if err != nil || n != 0 {
+		t.Fatalf("old steps left = %d, %v", n, err)
+	}
+	var item models.ProjectRoadmapItem
+	if err := orm.NewSelect().Model(&item).Where("id = ?", "r1").Scan(ctx); err != nil || item.WorkflowID != "wf-new" {
+		t.Fatalf("roadmap item workflow = %q, %v", item.WorkflowID, err)
+	}
+	var note models.ProjectNote
+	if err := orm.NewSelect().Model(&note).Where("id = ?", "n1").Scan(ctx); err != nil || note.WorkflowID == nil || *note.WorkflowID != "wf-new" {
+		t.Fatalf("note workflow = %v, %v", note.WorkflowID, err)
+	}
+}
@@ -0,0 +1,49 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type ProjectWorkflowStepRepo struct {
+	db *bun.DB
+}
+
+func NewProjectWorkflowStepRepo(db *bun.DB) *ProjectWorkflowStepRepo {
+	return &ProjectWorkflowStepRepo{db: db}
+}
+
+func (r *ProjectWorkflowStepRepo) ListByWorkflow(ctx context.Context, workflowID string) ([]models.ProjectWorkflowStep, error) {
+	var out []models.ProjectWorkflowStep
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("workflow_id = ?", workflowID).
+		OrderExpr("order_index ASC").
+		Scan(ctx)
+	return out, err
+}
+
+func (r *ProjectWorkflowStepRepo) Get(ctx context.Context, id string) (*models.ProjectWorkflowStep, error) {
+	var out models.ProjectWorkflowStep
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *ProjectWorkflowStepRepo) Update(ctx context.Context, s *models.ProjectWorkflowStep) error {
+	_, err := r.db.NewUpdate().
+		Model(s).
+		Where("id = ?", s.ID).
+		Exec(ctx)
+	return err
+}
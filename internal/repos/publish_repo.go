@@ -0,0 +1,254 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type PublishRepo struct {
+	db *bun.DB
+}
+
+func NewPublishRepo(db *bun.DB) *PublishRepo {
+	return &PublishRepo{db: db}
+}
+
+// ---- Channels ----
+
+func (r *PublishRepo) ListChannels(ctx context.Context, projectID string) ([]models.PublishChannel, error) {
+	var out []models.PublishChannel
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		OrderExpr("created_at ASC").
+		Scan(ctx)
+	return out, err
+}
+
+func (r *PublishRepo) GetChannel(ctx context.Context, id string) (*models.PublishChannel, error) {
+	var out models.PublishChannel
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *PublishRepo) CreateChannel(ctx context.Context, c *models.PublishChannel) error {
+	_, err := r.db.NewInsert().Model(c).Exec(ctx)
+	return err
+}
+
+func (r *PublishRepo) UpdateChannel(ctx context.Context, c *models.PublishChannel) error {
+	_, err := r.db.NewUpdate().
+		Model(c).
+		Where("id = ?", c.ID).
+		Exec(ctx)
+	return err
+}
+
+// ---- Jobs ----
+
+func (r *PublishRepo) ListJobs(ctx context.Context, projectID string, status string, limit int) ([]models.PublishJob, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 200
+	}
+	var out []models.PublishJob
+	q := r.db.NewSelect().
+		Model(&out).
+		OrderExpr("schedule_at DESC, created_at DESC").
+		Limit(limit)
+	if projectID != "" {
+		q = q.Where("project_id = ?", projectID)
+	}
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+	err := q.Scan(ctx)
+	return out, err
+}
+
+func (r *PublishRepo) GetJob(ctx context.Context, id string) (*models.PublishJob, error) {
+	var out models.PublishJob
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *PublishRepo) GetJobByIdempotencyKey(ctx context.Context, key string) (*models.PublishJob, error) {
+	var out models.PublishJob
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("idempotency_key = ?", key).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *PublishRepo) CreateJob(ctx context.Context, j *models.PublishJob) error {
+	_, err := r.db.NewInsert().Model(j).Exec(ctx)
+	return err
+}
+
+func (r *PublishRepo) UpdateJob(ctx context.Context, j *models.PublishJob) error {
+	_, err := r.db.NewUpdate().
+		Model(j).
+		Where("id = ?", j.ID).
+		Exec(ctx)
+	return err
+}
+
+// ListDueJobIDs 返回 schedule_at 已到期的 pending 任务
+func (r *PublishRepo) ListDueJobIDs(ctx context.Context, now int64, limit int) ([]string, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	var ids []string
+	err := r.db.NewSelect().
+		Model((*models.PublishJob)(nil)).
+		Column("id").
+		Where("status = ?", models.PublishJobPending).
+		Where("schedule_at <= ?", now).
+		OrderExpr("schedule_at ASC, created_at ASC").
+		Limit(limit).
+		Scan(ctx, &ids)
+	return ids, err
+}
+
+// ClaimJob 以 CAS 方式将 pending 任务置为 running，返回是否抢占成功
+func (r *PublishRepo) ClaimJob(ctx context.Context, id string) (bool, error) {
+	now := time.Now().Unix()
+	res, err := r.db.NewUpdate().
+		Model((*models.PublishJob)(nil)).
+		Set("status = ?", models.PublishJobRunning).
+		Set("started_at = ?", now).
+		Set("attempt_count = attempt_count + 1").
+		Set("updated_at = ?", now).
+		Where("id = ?", id).
+		Where("status = ?", models.PublishJobPending).
+		Exec(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// RequeueRunningJobs 将遗留的 running 任务放回队列；startedBefore > 0 时只处理租约已过期
+// （started_at 早于该时间）的任务，用于回收调度中途失败的任务
+func (r *PublishRepo) RequeueRunningJobs(ctx context.Context, startedBefore int64) (int64, error) {
+	q := r.db.NewUpdate().
+		Model((*models.PublishJob)(nil)).
+		Set("status = ?", models.PublishJobPending).
+		Set("updated_at = ?", time.Now().Unix()).
+		Where("status = ?", models.PublishJobRunning)
+	if startedBefore > 0 {
+		q = q.Where("started_at < ?", startedBefore)
+	}
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ReleaseJob 将已领取但未能执行的任务放回队列，不计入失败
+func (r *PublishRepo) ReleaseJob(ctx context.Context, id string) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.PublishJob)(nil)).
+		Set("status = ?", models.PublishJobPending).
+		Set("attempt_count = MAX(attempt_count - 1, 0)").
+		Set("updated_at = ?", time.Now().Unix()).
+		Where("id = ?", id).
+		Where("status = ?", models.PublishJobRunning).
+		Exec(ctx)
+	return err
+}
+
+// ---- Records ----
+
+func (r *PublishRepo) ListRecords(ctx context.Context, projectID string, jobID string, platform string, limit int) ([]models.PublishRecord, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 200
+	}
+	var out []models.PublishRecord
+	q := r.db.NewSelect().
+		Model(&out).
+		OrderExpr("published_at DESC, created_at DESC").
+		Limit(limit)
+	if projectID != "" {
+		q = q.Where("project_id = ?", projectID)
+	}
+	if jobID != "" {
+		q = q.Where("job_id = ?", jobID)
+	}
+	if platform != "" {
+		q = q.Where("platform = ?", platform)
+	}
+	err := q.Scan(ctx)
+	return out, err
+}
+
+// GetRecordByJob 任务已有的发布记录；存在即说明平台侧已发布，重试时只补记账
+func (r *PublishRepo) GetRecordByJob(ctx context.Context, jobID string) (*models.PublishRecord, error) {
+	var out models.PublishRecord
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("job_id = ?", jobID).
+		OrderExpr("created_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *PublishRepo) GetRecordByExternalPost(ctx context.Context, platform string, externalPostID string) (*models.PublishRecord, error) {
+	var out models.PublishRecord
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("platform = ?", platform).
+		Where("external_post_id = ?", externalPostID).
+		OrderExpr("published_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *PublishRepo) CreateRecord(ctx context.Context, rec *models.PublishRecord) error {
+	_, err := r.db.NewInsert().Model(rec).Exec(ctx)
+	return err
+}
+
+// CountRecords 统计项目的发布记录数，platform 为空时不过滤平台
+func (r *PublishRepo) CountRecords(ctx context.Context, projectID string, platform string) (int, error) {
+	q := r.db.NewSelect().
+		Model((*models.PublishRecord)(nil)).
+		Where("project_id = ?", projectID)
+	if platform != "" {
+		q = q.Where("platform = ?", platform)
+	}
+	return q.Count(ctx)
+}
@@ -0,0 +1,52 @@
+package repos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+func TestPublishRepo_RequeueRunningJobsHonoursLease(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	publish := NewPublishRepo(d.ORM())
+
+	now := time.Now().Unix()
+	for _, j := range []*models.PublishJob{
+		{ID: "stale", IdempotencyKey: "k-stale", ProjectID: "p", ChannelID: "c", Status: models.PublishJobRunning, StartedAt: now - 3600, CreatedAt: now, UpdatedAt: now},
+		{ID: "fresh", IdempotencyKey: "k-fresh", ProjectID: "p", ChannelID: "c", Status: models.PublishJobRunning, StartedAt: now, CreatedAt: now, UpdatedAt: now},
+	} {
+		if err := publish.CreateJob(ctx, j); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := publish.RequeueRunningJobs(ctx, now-600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("requeued %d jobs, want 1", n)
+	}
+	for id, want := range map[string]string{"stale": models.PublishJobPending, "fresh": models.PublishJobRunning} {
+		j, err := publish.GetJob(ctx, id)
+		if err != nil || j == nil {
+			t.Fatalf("get %s: %v", id, err)
+		}
+		if j.Status != want {
+			t.Errorf("%s status = %s, want %s", id, j.Status, want)
+		}
+	}
+
+	if rec, err := publish.GetRecordByJob(ctx, "stale"); err != nil || rec != nil {
+		t.Fatalf("expected no record, got %+v, %v", rec, err)
+	}
+	if err := publish.CreateRecord(ctx, &models.PublishRecord{ID: "r1", JobID: "stale", ProjectID: "p", ExternalPostID: "x1", CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := publish.GetRecordByJob(ctx, "stale"); err != nil || rec == nil || rec.ExternalPostID != "x1" {
+		t.Fatalf("expected saved record, got %+v, %v", rec, err)
+	}
+}
@@ -0,0 +1,197 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/utils"
+
+	"github.com/uptrace/bun"
+)
+
+type ScanJobRepo struct {
+	db *bun.DB
+}
+
+func NewScanJobRepo(db *bun.DB) *ScanJobRepo {
+	return &ScanJobRepo{db: db}
+}
+
+func (r *ScanJobRepo) Create(ctx context.Context, job *models.ScanJob) error {
+	now := time.Now().Unix()
+	if job.ID == "" {
+		job.ID = utils.NewID()
+	}
+	if job.Status == "" {
+		job.Status = models.ScanJobPending
+	}
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	_, err := r.db.NewInsert().Model(job).Exec(ctx)
+	return err
+}
+
+func (r *ScanJobRepo) Get(ctx context.Context, id string) (*models.ScanJob, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, nil
+	}
+	var out models.ScanJob
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListInflight 未结束的任务（按创建顺序），用于重启后恢复
+func (r *ScanJobRepo) ListInflight(ctx context.Context, limit int) ([]models.ScanJob, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	var out []models.ScanJob
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("status IN (?)", bun.In([]string{
+			models.ScanJobPending,
+			models.ScanJobRunning,
+		})).
+		OrderExpr("created_at ASC, id ASC").
+		Limit(limit).
+		Scan(ctx)
+	return out, err
+}
+
+func (r *ScanJobRepo) ListRecent(ctx context.Context, limit int) ([]models.ScanJob, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	var out []models.ScanJob
+	err := r.db.NewSelect().
+		Model(&out).
+		OrderExpr("created_at DESC, id ASC").
+		Limit(limit).
+		Scan(ctx)
+	return out, err
+}
+
+func (r *ScanJobRepo) MarkRunning(ctx context.Context, id string) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.ScanJob)(nil)).
+		Set("status = ?", models.ScanJobRunning).
+		Set("updated_at = ?", time.Now().Unix()).
+		Where("id = ?", id).
+		Where("status IN (?)", bun.In([]string{
+			models.ScanJobPending,
+			models.ScanJobRunning,
+		})).
+		Exec(ctx)
+	return err
+}
+
+func (r *ScanJobRepo) UpdateProgress(ctx context.Context, job *models.ScanJob) error {
+	job.UpdatedAt = time.Now().Unix()
+	_, err := r.db.NewUpdate().
+		Model(job).
+		Column("scanned_dirs", "skipped_dirs", "indexed_files", "current_dir", "updated_at").
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+func (r *ScanJobRepo) MarkSucceeded(ctx context.Context, job *models.ScanJob) error {
+	now := time.Now().Unix()
+	job.Status = models.ScanJobSucceeded
+	job.CurrentDir = ""
+	job.ErrorMessage = ""
+	job.UpdatedAt = now
+	job.FinishedAt = now
+	_, err := r.db.NewUpdate().
+		Model(job).
+		Column("status", "scanned_dirs", "skipped_dirs", "indexed_files", "current_dir", "error_message", "updated_at", "finished_at").
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+func (r *ScanJobRepo) MarkFailed(ctx context.Context, job *models.ScanJob, errMsg string) error {
+	now := time.Now().Unix()
+	job.Status = models.ScanJobFailed
+	job.ErrorMessage = strings.TrimSpace(errMsg)
+	job.UpdatedAt = now
+	job.FinishedAt = now
+	_, err := r.db.NewUpdate().
+		Model(job).
+		Column("status", "scanned_dirs", "skipped_dirs", "indexed_files", "current_dir", "error_message", "updated_at", "finished_at").
+		WherePK().
+		Exec(ctx)
+	return err
+}
+
+type ScanDirStateRepo struct {
+	db *bun.DB
+}
+
+func NewScanDirStateRepo(db *bun.DB) *ScanDirStateRepo {
+	return &ScanDirStateRepo{db: db}
+}
+
+func (r *ScanDirStateRepo) Get(ctx context.Context, projectID string, dirPath string) (*models.ScanDirState, error) {
+	var out models.ScanDirState
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("project_id = ?", projectID).
+		Where("dir_path = ?", dirPath).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *ScanDirStateRepo) Upsert(ctx context.Context, state *models.ScanDirState) error {
+	_, err := r.db.NewInsert().
+		Model(state).
+		On("CONFLICT (project_id, dir_path) DO UPDATE").
+		Set("mod_time = EXCLUDED.mod_time").
+		Set("entry_count = EXCLUDED.entry_count").
+		Set("job_id = EXCLUDED.job_id").
+		Set("completed_at = EXCLUDED.completed_at").
+		Exec(ctx)
+	return err
+}
+
+// DeleteUnder 清除某目录（含子目录）的检查点；root 为空时清空全部
+func (r *ScanDirStateRepo) DeleteUnder(ctx context.Context, root string) (int64, error) {
+	q := r.db.NewDelete().Model((*models.ScanDirState)(nil))
+	root = strings.TrimSpace(root)
+	if root == "" {
+		q = q.Where("1 = 1")
+	} else {
+		clean := filepath.Clean(root)
+		prefix := strings.TrimSuffix(clean, string(filepath.Separator)) + string(filepath.Separator)
+		q = q.Where("(dir_path = ? OR dir_path LIKE ? ESCAPE '\\')", clean, escapeLike(prefix)+"%")
+	}
+	res, err := q.Exec(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, nil
+}
@@ -0,0 +1,171 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type SmartCollectionRepo struct {
+	db *bun.DB
+}
+
+func NewSmartCollectionRepo(db *bun.DB) *SmartCollectionRepo {
+	return &SmartCollectionRepo{db: db}
+}
+
+func (r *SmartCollectionRepo) Create(ctx context.Context, c *models.SmartCollection) error {
+	_, err := r.db.NewInsert().Model(c).Exec(ctx)
+	return err
+}
+
+func (r *SmartCollectionRepo) Update(ctx context.Context, c *models.SmartCollection) error {
+	_, err := r.db.NewUpdate().Model(c).WherePK().Exec(ctx)
+	return err
+}
+
+// Delete 删除集合及其命中记录
+func (r *SmartCollectionRepo) Delete(ctx context.Context, id string) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().
+			TableExpr("smart_collection_assets").
+			Where("collection_id = ?", id).
+			Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewDelete().
+			Model((*models.SmartCollection)(nil)).
+			Where("id = ?", id).
+			Exec(ctx)
+		return err
+	})
+}
+
+func (r *SmartCollectionRepo) Get(ctx context.Context, id string) (*models.SmartCollection, error) {
+	var out models.SmartCollection
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+// List 置顶集合按 pin_order 在前，其余按名称
+func (r *SmartCollectionRepo) List(ctx context.Context) ([]models.SmartCollection, error) {
+	var out []models.SmartCollection
+	err := r.db.NewSelect().
+		Model(&out).
+		OrderExpr("CASE WHEN pin_order > 0 THEN 0 ELSE 1 END ASC, pin_order ASC, name ASC").
+		Scan(ctx)
+	return out, err
+}
+
+// ReplaceMembers 用完整命中列表覆盖集合的命中记录
+func (r *SmartCollectionRepo) ReplaceMembers(ctx context.Context, collectionID string, assetIDs []string) error {
+	now := time.Now().Unix()
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().
+			TableExpr("smart_collection_assets").
+			Where("collection_id = ?", collectionID).
+			Exec(ctx); err != nil {
+			return err
+		}
+		for _, id := range assetIDs {
+			if _, err := tx.ExecContext(ctx,
+				"INSERT OR IGNORE INTO smart_collection_assets(collection_id, asset_id, created_at) VALUES (?, ?, ?)",
+				collectionID, id, now,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ListMemberIDs 返回 assetIDs 中当前记录为命中的资产
+func (r *SmartCollectionRepo) ListMemberIDs(ctx context.Context, collectionID string, assetIDs []string) ([]string, error) {
+	var out []string
+	if len(assetIDs) == 0 {
+		return out, nil
+	}
+	err := r.db.NewSelect().
+		TableExpr("smart_collection_assets").
+		Column("asset_id").
+		Where("collection_id = ?", collectionID).
+		Where("asset_id IN (?)", bun.In(assetIDs)).
+		Scan(ctx, &out)
+	return out, err
+}
+
+// ApplyMemberChanges 增量写入命中变化
+func (r *SmartCollectionRepo) ApplyMemberChanges(ctx context.Context, collectionID string, added []string, removed []string) error {
+	now := time.Now().Unix()
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, id := range added {
+			if _, err := tx.ExecContext(ctx,
+				"INSERT OR IGNORE INTO smart_collection_assets(collection_id, asset_id, created_at) VALUES (?, ?, ?)",
+				collectionID, id, now,
+			); err != nil {
+				return err
+			}
+		}
+		if len(removed) > 0 {
+			if _, err := tx.NewDelete().
+				TableExpr("smart_collection_assets").
+				Where("collection_id = ?", collectionID).
+				Where("asset_id IN (?)", bun.In(removed)).
+				Exec(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SmartCollectionDirty 待重新评估的资产及其入队版本
+type SmartCollectionDirty struct {
+	AssetID string `bun:"asset_id"`
+	Version int64  `bun:"version"`
+}
+
+// ListDirty 取一批待重新评估的资产；全文索引尚未更新的资产留到下一轮，保证关键词条件按最新内容判断
+func (r *SmartCollectionRepo) ListDirty(ctx context.Context, limit int) ([]SmartCollectionDirty, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	var out []SmartCollectionDirty
+	err := r.db.NewSelect().
+		TableExpr("smart_collection_dirty AS d").
+		Column("d.asset_id", "d.version").
+		Where("NOT EXISTS (SELECT 1 FROM asset_search_dirty AS s WHERE s.asset_id = d.asset_id)").
+		OrderExpr("d.queued_at ASC").
+		Limit(limit).
+		Scan(ctx, &out)
+	return out, err
+}
+
+// AckDirty 移出已评估的队列项；评估期间再次入队（版本已变）的保留
+func (r *SmartCollectionRepo) AckDirty(ctx context.Context, items []SmartCollectionDirty) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, it := range items {
+			if _, err := tx.ExecContext(ctx,
+				"DELETE FROM smart_collection_dirty WHERE asset_id = ? AND version = ?",
+				it.AssetID, it.Version,
+			); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
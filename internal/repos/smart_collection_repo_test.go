@@ -0,0 +1,64 @@
+package repos
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSmartCollectionRepo_DirtyQueue(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	assets := NewAssetRepo(d.ORM())
+	search := NewAssetSearchRepo(d.ORM())
+	repo := NewSmartCollectionRepo(d.ORM())
+
+	a, err := assets.Create(ctx, "/photos/a.jpg", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 全文索引未更新前不评估
+	items, err := repo.ListDirty(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("ListDirty before FTS sync = %v, want none", items)
+	}
+	if _, err := search.IndexDirty(ctx, 10, func(ids []string, _ map[string]*AssetSearchSource) ([]AssetSearchDoc, []string) {
+		return nil, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	items, err = repo.ListDirty(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].AssetID != a.ID {
+		t.Fatalf("ListDirty = %v, want [%s]", items, a.ID)
+	}
+
+	// 状态变化只进入集合队列，不重建全文索引；评估期间再次入队的不被确认掉
+	if _, err := d.SQL().ExecContext(ctx, "UPDATE assets SET status = 'READY' WHERE id = ?", a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := search.CountDirty(ctx); n != 0 {
+		t.Fatalf("search dirty after status change = %d, want 0", n)
+	}
+	if err := repo.AckDirty(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+	again, err := repo.ListDirty(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 1 || again[0].Version <= items[0].Version {
+		t.Fatalf("ListDirty after stale ack = %v, want newer version of %s", again, a.ID)
+	}
+	if err := repo.AckDirty(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if left, _ := repo.ListDirty(ctx, 10); len(left) != 0 {
+		t.Fatalf("ListDirty after ack = %v, want none", left)
+	}
+}
@@ -0,0 +1,185 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type TaskWorkerRepo struct {
+	db *bun.DB
+}
+
+// TaskLeaseRef 进行中 / 可领取任务的精简视图（含资产路径，用于按扩展名匹配并发上限）
+type TaskLeaseRef struct {
+	ID         string    `bun:"id" json:"task_id"`
+	AssetID    string    `bun:"asset_id" json:"asset_id"`
+	TaskType   string    `bun:"task_type" json:"task_type"`
+	WorkerID   string    `bun:"worker_id" json:"-"`
+	AssetPath  string    `bun:"asset_path" json:"asset_path"`
+	StartedAt  time.Time `bun:"started_at" json:"started_at"`
+	LeaseUntil time.Time `bun:"lease_until" json:"lease_until"`
+	// Priority / CreatedAt 仅 ListClaimable 填充，作为翻页游标；CreatedAt 保留库中原始文本，与排序比较一致
+	Priority  int    `bun:"priority" json:"-"`
+	CreatedAt string `bun:"created_at" json:"-"`
+}
+
+func NewTaskWorkerRepo(db *bun.DB) *TaskWorkerRepo {
+	return &TaskWorkerRepo{db: db}
+}
+
+// Upsert 注册或更新 worker（保留累计计数与首次注册时间）
+func (r *TaskWorkerRepo) Upsert(ctx context.Context, w *models.TaskWorker) error {
+	_, err := r.db.NewInsert().
+		Model(w).
+		On("CONFLICT (id) DO UPDATE").
+		Set("name = EXCLUDED.name").
+		Set("kind = EXCLUDED.kind").
+		Set("task_types_json = EXCLUDED.task_types_json").
+		Set("max_concurrency = EXCLUDED.max_concurrency").
+		Set("last_seen_at = EXCLUDED.last_seen_at").
+		Exec(ctx)
+	return err
+}
+
+// Touch 更新心跳时间；worker 未注册时以匿名外部 worker 身份登记
+func (r *TaskWorkerRepo) Touch(ctx context.Context, id string) error {
+	now := time.Now().Unix()
+	_, err := r.db.NewInsert().
+		Model(&models.TaskWorker{
+			ID:            id,
+			Kind:          "external",
+			TaskTypesJSON: "[]",
+			RegisteredAt:  now,
+			LastSeenAt:    now,
+		}).
+		On("CONFLICT (id) DO UPDATE").
+		Set("last_seen_at = EXCLUDED.last_seen_at").
+		Exec(ctx)
+	return err
+}
+
+// RecordResult 累计 worker 完成 / 失败次数
+func (r *TaskWorkerRepo) RecordResult(ctx context.Context, id string, success bool) error {
+	col := "failed_count"
+	if success {
+		col = "completed_count"
+	}
+	_, err := r.db.NewUpdate().
+		Model((*models.TaskWorker)(nil)).
+		Set(col+" = "+col+" + 1").
+		Set("last_seen_at = ?", time.Now().Unix()).
+		Where("id = ?", id).
+		Exec(ctx)
+	return err
+}
+
+func (r *TaskWorkerRepo) Get(ctx context.Context, id string) (*models.TaskWorker, error) {
+	var w models.TaskWorker
+	err := r.db.NewSelect().Model(&w).Where("tw.id = ?", id).Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &w, err
+}
+
+func (r *TaskWorkerRepo) List(ctx context.Context) ([]models.TaskWorker, error) {
+	var out []models.TaskWorker
+	err := r.db.NewSelect().Model(&out).Order("tw.kind ASC", "tw.id ASC").Scan(ctx)
+	return out, err
+}
+
+func (r *TaskWorkerRepo) Delete(ctx context.Context, id string) error {
+	_, err := r.db.NewDelete().Model((*models.TaskWorker)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
+
+// ListProcessing 列出全部进行中的任务租约
+func (r *TaskWorkerRepo) ListProcessing(ctx context.Context) ([]TaskLeaseRef, error) {
+	var out []TaskLeaseRef
+	err := r.db.NewSelect().
+		TableExpr("media_tasks AS mt").
+		Join("LEFT JOIN assets AS a ON a.id = mt.asset_id").
+		ColumnExpr("mt.id, mt.asset_id, mt.task_type, mt.worker_id, COALESCE(a.path, '') AS asset_path, mt.started_at, mt.lease_until").
+		Where("mt.status = ?", models.TaskStatusProcessing).
+		OrderExpr("mt.started_at ASC").
+		Scan(ctx, &out)
+	return out, err
+}
+
+// ListClaimable 按领取顺序分页列出可领取任务（前置任务已完成、退避已到期）；
+// excludeTypes 为已达全局并发上限的类型，直接在 SQL 中排除；after 非空时从该任务之后继续（keyset 翻页）
+func (r *TaskWorkerRepo) ListClaimable(ctx context.Context, taskTypes []string, excludeTypes []string, after *TaskLeaseRef, limit int) ([]TaskLeaseRef, error) {
+	var out []TaskLeaseRef
+	q := r.db.NewSelect().
+		TableExpr("media_tasks AS mt").
+		Join("LEFT JOIN assets AS a ON a.id = mt.asset_id").
+		ColumnExpr("mt.id, mt.asset_id, mt.task_type, COALESCE(a.path, '') AS asset_path, mt.priority, CAST(mt.created_at AS TEXT) AS created_at").
+		Where("mt.status = ?", models.TaskStatusPending).
+		Where("(mt.next_retry_at IS NULL OR mt.next_retry_at <= CURRENT_TIMESTAMP)").
+		Where(depsMetSQL("mt.id"))
+	if len(taskTypes) > 0 {
+		q = q.Where("mt.task_type IN (?)", bun.In(taskTypes))
+	}
+	if len(excludeTypes) > 0 {
+		q = q.Where("mt.task_type NOT IN (?)", bun.In(excludeTypes))
+	}
+	if after != nil {
+		q = q.Where("(mt.priority < ? OR (mt.priority = ? AND (mt.created_at > ? OR (mt.created_at = ? AND mt.id > ?))))",
+			after.Priority, after.Priority, after.CreatedAt, after.CreatedAt, after.ID)
+	}
+	err := q.OrderExpr("mt.priority DESC, mt.created_at ASC, mt.id ASC").
+		Limit(limit).
+		Scan(ctx, &out)
+	return out, err
+}
+
+// CountCompletedSince 按 worker 统计某时间后完成的任务数
+func (r *TaskWorkerRepo) CountCompletedSince(ctx context.Context, since time.Time) (map[string]int, error) {
+	var rows []struct {
+		WorkerID string `bun:"worker_id"`
+		Count    int    `bun:"cnt"`
+	}
+	err := r.db.NewSelect().
+		TableExpr("media_tasks AS mt").
+		ColumnExpr("mt.worker_id, COUNT(*) AS cnt").
+		Where("mt.status = ?", models.TaskStatusCompleted).
+		Where("mt.finished_at >= ?", since).
+		GroupExpr("mt.worker_id").
+		Scan(ctx, &rows)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]int, len(rows))
+	for _, row := range rows {
+		out[row.WorkerID] = row.Count
+	}
+	return out, nil
+}
+
+func (r *TaskWorkerRepo) ListLimits(ctx context.Context) ([]models.TaskConcurrencyLimit, error) {
+	var out []models.TaskConcurrencyLimit
+	err := r.db.NewSelect().Model(&out).Order("tcl.task_type ASC", "tcl.id ASC").Scan(ctx)
+	return out, err
+}
+
+func (r *TaskWorkerRepo) UpsertLimit(ctx context.Context, l *models.TaskConcurrencyLimit) error {
+	_, err := r.db.NewInsert().
+		Model(l).
+		On("CONFLICT (id) DO UPDATE").
+		Set("task_type = EXCLUDED.task_type").
+		Set("extensions_json = EXCLUDED.extensions_json").
+		Set("max_concurrent = EXCLUDED.max_concurrent").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	return err
+}
+
+func (r *TaskWorkerRepo) DeleteLimit(ctx context.Context, id string) error {
+	_, err := r.db.NewDelete().Model((*models.TaskConcurrencyLimit)(nil)).Where("id = ?", id).Exec(ctx)
+	return err
+}
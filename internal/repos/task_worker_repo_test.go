@@ -0,0 +1,55 @@
+package repos
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestTaskWorkerRepo_ListClaimablePagesAndExcludes(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	orm := d.ORM()
+	workers := NewTaskWorkerRepo(orm)
+
+	// 高优先级的 thumbnail 排在前面，metadata 在队尾
+	for i := 0; i < 5; i++ {
+		if _, err := orm.ExecContext(ctx,
+			"INSERT INTO media_tasks (id, asset_id, task_type, status, priority) VALUES (?, ?, 'thumbnail', 'pending', 10)",
+			fmt.Sprintf("t%d", i), fmt.Sprintf("a%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := orm.ExecContext(ctx,
+		"INSERT INTO media_tasks (id, asset_id, task_type, status, priority) VALUES ('m0', 'b0', 'metadata', 'pending', 0)"); err != nil {
+		t.Fatal(err)
+	}
+
+	var seen []string
+	var after *TaskLeaseRef
+	for {
+		page, err := workers.ListClaimable(ctx, nil, nil, after, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range page {
+			seen = append(seen, c.ID)
+		}
+		if len(page) < 2 {
+			break
+		}
+		after = &page[len(page)-1]
+	}
+	want := []string{"t0", "t1", "t2", "t3", "t4", "m0"}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		t.Fatalf("paged order = %v, want %v", seen, want)
+	}
+
+	page, err := workers.ListClaimable(ctx, nil, []string{"thumbnail"}, nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 1 || page[0].ID != "m0" {
+		t.Fatalf("excluding saturated type should surface metadata task, got %+v", page)
+	}
+}
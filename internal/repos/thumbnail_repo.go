@@ -0,0 +1,75 @@
+package repos
+
+import (
+	"context"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type ThumbnailRepo struct {
+	db *bun.DB
+}
+
+func NewThumbnailRepo(db *bun.DB) *ThumbnailRepo {
+	return &ThumbnailRepo{db: db}
+}
+
+// Upsert 写入或覆盖一个规格（重新生成时格式、尺寸可能变化）
+func (r *ThumbnailRepo) Upsert(ctx context.Context, rend *models.ThumbnailRendition) error {
+	_, err := r.db.NewInsert().
+		Model(rend).
+		On("CONFLICT (content_key, rendition) DO UPDATE").
+		Set("format = EXCLUDED.format").
+		Set("rel_path = EXCLUDED.rel_path").
+		Set("width = EXCLUDED.width").
+		Set("height = EXCLUDED.height").
+		Set("bytes = EXCLUDED.bytes").
+		Set("etag = EXCLUDED.etag").
+		Set("meta_json = EXCLUDED.meta_json").
+		Set("created_at = EXCLUDED.created_at").
+		Exec(ctx)
+	return err
+}
+
+func (r *ThumbnailRepo) ListByKey(ctx context.Context, key string) ([]models.ThumbnailRendition, error) {
+	var out []models.ThumbnailRendition
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("thr.content_key = ?", key).
+		Scan(ctx)
+	return out, err
+}
+
+// ListOrphans 返回已没有任何资产引用其指纹的规格
+func (r *ThumbnailRepo) ListOrphans(ctx context.Context, limit int) ([]models.ThumbnailRendition, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	var out []models.ThumbnailRendition
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("NOT EXISTS (SELECT 1 FROM assets a WHERE a.fingerprint = thr.content_key)").
+		OrderExpr("thr.content_key ASC, thr.rendition ASC").
+		Limit(limit).
+		Scan(ctx)
+	return out, err
+}
+
+// IsReferenced 是否仍有资产的指纹为 key
+func (r *ThumbnailRepo) IsReferenced(ctx context.Context, key string) (bool, error) {
+	return r.db.NewSelect().
+		Model((*models.Asset)(nil)).
+		Where("fingerprint = ?", key).
+		Exists(ctx)
+}
+
+func (r *ThumbnailRepo) Delete(ctx context.Context, key, rendition string) error {
+	_, err := r.db.NewDelete().
+		Model((*models.ThumbnailRendition)(nil)).
+		Where("content_key = ?", key).
+		Where("rendition = ?", rendition).
+		Exec(ctx)
+	return err
+}
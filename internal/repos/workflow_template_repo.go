@@ -0,0 +1,109 @@
+package repos
+
+import (
+	"context"
+	"database/sql"
+
+	"media-assistant-os/internal/models"
+
+	"github.com/uptrace/bun"
+)
+
+type WorkflowTemplateRepo struct {
+	db *bun.DB
+}
+
+func NewWorkflowTemplateRepo(db *bun.DB) *WorkflowTemplateRepo {
+	return &WorkflowTemplateRepo{db: db}
+}
+
+func (r *WorkflowTemplateRepo) List(ctx context.Context, domain string, includeInactive bool) ([]models.WorkflowTemplate, error) {
+	var out []models.WorkflowTemplate
+	q := r.db.NewSelect().
+		Model(&out).
+		OrderExpr("is_system DESC, name ASC")
+	if domain != "" {
+		q = q.Where("domain = ?", domain)
+	}
+	if !includeInactive {
+		q = q.Where("is_active = 1")
+	}
+	err := q.Scan(ctx)
+	return out, err
+}
+
+func (r *WorkflowTemplateRepo) Get(ctx context.Context, id string) (*models.WorkflowTemplate, error) {
+	var out models.WorkflowTemplate
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("id = ?", id).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *WorkflowTemplateRepo) GetByName(ctx context.Context, name string) (*models.WorkflowTemplate, error) {
+	var out models.WorkflowTemplate
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("name = ?", name).
+		Limit(1).
+		Scan(ctx)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return &out, err
+}
+
+func (r *WorkflowTemplateRepo) Create(ctx context.Context, t *models.WorkflowTemplate) error {
+	_, err := r.db.NewInsert().Model(t).Exec(ctx)
+	return err
+}
+
+// CreateWithSteps 在同一事务中写入模板及其步骤
+func (r *WorkflowTemplateRepo) CreateWithSteps(ctx context.Context, t *models.WorkflowTemplate, steps []models.WorkflowTemplateStep) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(t).Exec(ctx); err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return nil
+		}
+		_, err := tx.NewInsert().Model(&steps).Exec(ctx)
+		return err
+	})
+}
+
+func (r *WorkflowTemplateRepo) ListSteps(ctx context.Context, templateID string) ([]models.WorkflowTemplateStep, error) {
+	var out []models.WorkflowTemplateStep
+	err := r.db.NewSelect().
+		Model(&out).
+		Where("template_id = ?", templateID).
+		OrderExpr("order_index ASC").
+		Scan(ctx)
+	return out, err
+}
+
+func (r *WorkflowTemplateRepo) MaxStepOrder(ctx context.Context, templateID string) (int, error) {
+	var maxOrder sql.NullInt64
+	err := r.db.NewSelect().
+		Model((*models.WorkflowTemplateStep)(nil)).
+		ColumnExpr("MAX(order_index)").
+		Where("template_id = ?", templateID).
+		Scan(ctx, &maxOrder)
+	if err != nil {
+		return -1, err
+	}
+	if !maxOrder.Valid {
+		return -1, nil
+	}
+	return int(maxOrder.Int64), nil
+}
+
+func (r *WorkflowTemplateRepo) CreateStep(ctx context.Context, s *models.WorkflowTemplateStep) error {
+	_, err := r.db.NewInsert().Model(s).Exec(ctx)
+	return err
+}
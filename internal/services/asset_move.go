@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+// MoveAssetPath 监控配对到的文件重命名/移动：直接迁移资产路径并记录一条 moved/renamed 事件，
+// 不需要重新计算指纹。旧路径不是资产或新路径已被占用时按普通新文件索引
+func (s *AssetService) MoveAssetPath(ctx context.Context, oldPath string, newPath string, projectID string) (*IndexFileResult, error) {
+	oldPath = filepath.Clean(oldPath)
+	newPath = filepath.Clean(newPath)
+	fallback := func() (*IndexFileResult, error) {
+		return s.IndexFile(ctx, IndexFileRequest{Path: newPath, ProjectID: projectID, Trigger: "watcher"})
+	}
+
+	asset, err := s.assets.GetByPath(ctx, oldPath)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil || asset.Status == "IGNORED" {
+		return fallback()
+	}
+	if occupied, err := s.assets.GetByPath(ctx, newPath); err != nil || occupied != nil {
+		return fallback()
+	}
+	info, err := os.Stat(newPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() != asset.Size {
+		return fallback()
+	}
+
+	mtime := info.ModTime().Unix()
+	if err := s.assets.RelinkAsset(ctx, asset.ID, newPath, mtime); err != nil {
+		return nil, err
+	}
+	s.cache.Invalidate(asset.ID)
+	s.bloom.AddString(newPath)
+	asset.Path = newPath
+	asset.Mtime = mtime
+	asset.Status = "READY"
+	s.cache.Put(asset)
+
+	s.recordMove(ctx, asset.ID, projectID, oldPath, newPath, "rename paired from watcher events")
+	if s.activities != nil {
+		s.activities.LogEx(ctx, "INFO", "Asset moved/renamed: "+filepath.Base(newPath), asset.ID, projectID)
+	}
+	if projectID != "" {
+		_ = s.projectAssets.Link(ctx, projectID, asset.ID)
+	}
+	return &IndexFileResult{AssetID: asset.ID}, nil
+}
+
+// MoveAssetDirectory 目录整体重命名/移动：批量迁移其下资产路径，每个资产记录一条 moved/renamed 事件
+func (s *AssetService) MoveAssetDirectory(ctx context.Context, oldDir string, newDir string, projectID string) (int, error) {
+	oldDir = filepath.Clean(oldDir)
+	newDir = filepath.Clean(newDir)
+	moved, err := s.assets.MoveDirectory(ctx, oldDir, newDir)
+	if err != nil || len(moved) == 0 {
+		return 0, err
+	}
+	if err := s.ReloadPathIndex(ctx); err != nil {
+		return len(moved), err
+	}
+	for _, a := range moved {
+		rel, err := filepath.Rel(oldDir, a.Path)
+		if err != nil {
+			continue
+		}
+		s.recordMove(ctx, a.ID, projectID, a.Path, filepath.Join(newDir, rel), "directory rename paired from watcher events")
+	}
+	if s.activities != nil {
+		s.activities.LogEx(ctx, "INFO", "Directory moved/renamed: "+oldDir+" → "+newDir, "", projectID)
+	}
+	return len(moved), nil
+}
+
+func (s *AssetService) recordMove(ctx context.Context, assetID, projectID, oldPath, newPath, detail string) {
+	eventType := models.AssetHistoryEventMoved
+	if filepath.Dir(oldPath) == filepath.Dir(newPath) {
+		eventType = models.AssetHistoryEventRenamed
+	}
+	s.recordHistoryEvent(ctx, repos.CreateAssetHistoryEventInput{
+		AssetID:    assetID,
+		ProjectID:  projectID,
+		EventType:  eventType,
+		SourcePath: oldPath,
+		TargetPath: newPath,
+		Confidence: historyConfidence("watcher", false),
+		IsInferred: false,
+		Detail:     detail,
+	}, 8)
+}
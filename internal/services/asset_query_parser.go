@@ -0,0 +1,660 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"media-assistant-os/internal/repos"
+)
+
+// AssetQueryError 结构化查询解析错误；Offset/End 为字符（rune）偏移，前端据此高亮 [Offset, End)
+type AssetQueryError struct {
+	Message string `json:"message"`
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+}
+
+func (e *AssetQueryError) Error() string {
+	return fmt.Sprintf("query syntax error at %d: %s", e.Offset, e.Message)
+}
+
+// assetQueryFields 查询字段别名 → 规范字段名
+var assetQueryFields = map[string]string{
+	"tag":       "tag",
+	"tags":      "tag",
+	"project":   "project",
+	"proj":      "project",
+	"type":      "type",
+	"kind":      "type",
+	"ext":       "ext",
+	"extension": "ext",
+	"shape":     "shape",
+	"status":    "status",
+	"path":      "path",
+	"dir":       "dir",
+	"folder":    "dir",
+	"in":        "dir",
+	"name":      "name",
+	"filename":  "name",
+	"rating":    "rating",
+	"stars":     "rating",
+	"w":         "width",
+	"width":     "width",
+	"h":         "height",
+	"height":    "height",
+	"duration":  "duration",
+	"dur":       "duration",
+	"size":      "size",
+	"modified":  "modified",
+	"mtime":     "modified",
+	"date":      "modified",
+	"created":   "created",
+	"added":     "created",
+	"codec":     "codec",
+	"format":    "format",
+	"camera":    "camera",
+	"lens":      "lens",
+}
+
+// assetQueryTextFields 只支持等值/包含匹配的字段
+var assetQueryTextFields = map[string]string{
+	"tag":     repos.AssetFieldTag,
+	"project": repos.AssetFieldProject,
+	"status":  repos.AssetFieldStatus,
+	"path":    repos.AssetFieldPath,
+	"dir":     repos.AssetFieldDir,
+	"name":    repos.AssetFieldName,
+	"codec":   repos.AssetFieldCodec,
+	"format":  repos.AssetFieldFormat,
+	"camera":  repos.AssetFieldCamera,
+	"lens":    repos.AssetFieldLens,
+}
+
+var assetQueryShapes = map[string]string{
+	"landscape":  "landscape",
+	"horizontal": "landscape",
+	"portrait":   "portrait",
+	"vertical":   "portrait",
+	"square":     "square",
+	"panorama":   "panorama",
+	"unknown":    "unknown",
+}
+
+type queryTokenKind int
+
+const (
+	qtEOF queryTokenKind = iota
+	qtLParen
+	qtRParen
+	qtNot
+	qtAnd
+	qtOr
+	qtTerm
+)
+
+type queryToken struct {
+	kind  queryTokenKind
+	start int
+	end   int
+
+	// qtTerm
+	field      string // 规范字段名；空表示全文词
+	fieldStart int
+	cmp        string
+	value      string
+	valueStart int
+}
+
+type assetQueryParser struct {
+	tokens []queryToken
+	pos    int
+	now    time.Time
+}
+
+// ParseAssetQuery 解析搜索框中的结构化查询，例如：
+//
+//	tag:b-roll rating>=4 type:video duration>30s w>=3840 -tag:rejected project:"Vlog 12" modified:last-week
+//
+// 相邻条件默认 AND，支持 OR、NOT / -前缀 与括号分组；未识别的 key:value 按普通关键词处理。
+func ParseAssetQuery(input string, now time.Time) (*repos.AssetQueryNode, error) {
+	tokens, err := lexAssetQuery([]rune(input))
+	if err != nil {
+		return nil, err
+	}
+	p := &assetQueryParser{tokens: tokens, now: now}
+	if p.peek().kind == qtEOF {
+		return nil, nil
+	}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != qtEOF {
+		return nil, &AssetQueryError{Message: "unexpected ')'", Offset: tok.start, End: tok.end}
+	}
+	return node, nil
+}
+
+// assetQueryRankText 提取顶层 AND 链上的正向全文词，用于相关度排序
+func assetQueryRankText(n *repos.AssetQueryNode) string {
+	if n == nil {
+		return ""
+	}
+	nodes := []*repos.AssetQueryNode{n}
+	if n.Op == repos.AssetQueryAnd {
+		nodes = n.Children
+	}
+	var parts []string
+	for _, c := range nodes {
+		if c.Op == repos.AssetQueryCond && (c.Field == repos.AssetFieldText || c.Field == repos.AssetFieldName) {
+			parts = append(parts, c.Text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func isQueryOpRune(r rune) bool {
+	return r == ':' || r == '=' || r == '>' || r == '<' || r == '!'
+}
+
+func lexAssetQuery(in []rune) ([]queryToken, error) {
+	var out []queryToken
+	i := 0
+	for i < len(in) {
+		r := in[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			out = append(out, queryToken{kind: qtLParen, start: i, end: i + 1})
+			i++
+		case r == ')':
+			out = append(out, queryToken{kind: qtRParen, start: i, end: i + 1})
+			i++
+		case r == '-' && i+1 < len(in) && !unicode.IsSpace(in[i+1]) && in[i+1] != ')':
+			out = append(out, queryToken{kind: qtNot, start: i, end: i + 1})
+			i++
+		case r == '"':
+			text, end, err := lexQuoted(in, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, queryToken{kind: qtTerm, start: i, end: end, value: text, valueStart: i})
+			i = end
+		default:
+			tok, err := lexWord(in, i)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, tok)
+			i = tok.end
+		}
+	}
+	out = append(out, queryToken{kind: qtEOF, start: len(in), end: len(in)})
+	return out, nil
+}
+
+func lexQuoted(in []rune, start int) (string, int, error) {
+	var b strings.Builder
+	for i := start + 1; i < len(in); i++ {
+		switch in[i] {
+		case '\\':
+			if i+1 < len(in) {
+				i++
+				b.WriteRune(in[i])
+			}
+		case '"':
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(in[i])
+		}
+	}
+	return "", 0, &AssetQueryError{Message: "unterminated quote", Offset: start, End: len(in)}
+}
+
+func lexWord(in []rune, start int) (queryToken, error) {
+	// 尝试 field<op>value
+	j := start
+	for j < len(in) && (unicode.IsLetter(in[j]) || in[j] == '_') && in[j] < unicode.MaxASCII {
+		j++
+	}
+	if j > start && j < len(in) && isQueryOpRune(in[j]) {
+		if field, ok := assetQueryFields[strings.ToLower(string(in[start:j]))]; ok {
+			opStart := j
+			for j < len(in) && isQueryOpRune(in[j]) {
+				j++
+			}
+			cmp := string(in[opStart:j])
+			switch cmp {
+			case ":", "=", "!=", ">", ">=", "<", "<=":
+			default:
+				return queryToken{}, &AssetQueryError{Message: fmt.Sprintf("unknown operator %q", cmp), Offset: opStart, End: j}
+			}
+			tok := queryToken{kind: qtTerm, start: start, field: field, fieldStart: start, cmp: cmp, valueStart: j}
+			if j < len(in) && in[j] == '"' {
+				text, end, err := lexQuoted(in, j)
+				if err != nil {
+					return queryToken{}, err
+				}
+				tok.value, tok.end = text, end
+			} else {
+				k := j
+				for k < len(in) && !unicode.IsSpace(in[k]) && in[k] != '(' && in[k] != ')' {
+					k++
+				}
+				tok.value, tok.end = string(in[j:k]), k
+			}
+			if strings.TrimSpace(tok.value) == "" {
+				return queryToken{}, &AssetQueryError{Message: fmt.Sprintf("missing value for %s", field), Offset: start, End: tok.end}
+			}
+			return tok, nil
+		}
+	}
+
+	k := start
+	for k < len(in) && !unicode.IsSpace(in[k]) && in[k] != '(' && in[k] != ')' {
+		k++
+	}
+	word := string(in[start:k])
+	tok := queryToken{start: start, end: k}
+	switch word {
+	case "OR", "|", "||":
+		tok.kind = qtOr
+	case "AND", "&&":
+		tok.kind = qtAnd
+	case "NOT":
+		tok.kind = qtNot
+	default:
+		tok.kind = qtTerm
+		tok.value = word
+		tok.valueStart = start
+	}
+	return tok, nil
+}
+
+func (p *assetQueryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *assetQueryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != qtEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *assetQueryParser) expectOperand(after queryToken, what string) error {
+	switch tok := p.peek(); tok.kind {
+	case qtEOF, qtRParen, qtOr, qtAnd:
+		return &AssetQueryError{Message: "expected a search term after " + what, Offset: after.start, End: after.end}
+	}
+	return nil
+}
+
+func (p *assetQueryParser) parseOr() (*repos.AssetQueryNode, error) {
+	first, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*repos.AssetQueryNode{first}
+	for p.peek().kind == qtOr {
+		op := p.next()
+		if err := p.expectOperand(op, "OR"); err != nil {
+			return nil, err
+		}
+		next, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &repos.AssetQueryNode{Op: repos.AssetQueryOr, Children: children}, nil
+}
+
+func (p *assetQueryParser) parseAnd() (*repos.AssetQueryNode, error) {
+	var children []*repos.AssetQueryNode
+	for {
+		tok := p.peek()
+		if tok.kind == qtEOF || tok.kind == qtRParen || tok.kind == qtOr {
+			break
+		}
+		if tok.kind == qtAnd {
+			op := p.next()
+			if len(children) == 0 {
+				return nil, &AssetQueryError{Message: "AND needs a search term on both sides", Offset: op.start, End: op.end}
+			}
+			if err := p.expectOperand(op, "AND"); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		tok := p.peek()
+		return nil, &AssetQueryError{Message: "expected a search term", Offset: tok.start, End: tok.end}
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &repos.AssetQueryNode{Op: repos.AssetQueryAnd, Children: children}, nil
+}
+
+func (p *assetQueryParser) parseUnary() (*repos.AssetQueryNode, error) {
+	if p.peek().kind == qtNot {
+		op := p.next()
+		if err := p.expectOperand(op, "NOT"); err != nil {
+			return nil, err
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode(child), nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *assetQueryParser) parsePrimary() (*repos.AssetQueryNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case qtLParen:
+		if p.peek().kind == qtRParen {
+			closing := p.next()
+			return nil, &AssetQueryError{Message: "empty group", Offset: tok.start, End: closing.end}
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != qtRParen {
+			return nil, &AssetQueryError{Message: "unclosed '('", Offset: tok.start, End: tok.end}
+		}
+		p.next()
+		return node, nil
+	case qtTerm:
+		return p.buildCond(tok)
+	case qtRParen:
+		return nil, &AssetQueryError{Message: "unexpected ')'", Offset: tok.start, End: tok.end}
+	default:
+		return nil, &AssetQueryError{Message: "expected a search term", Offset: tok.start, End: tok.end}
+	}
+}
+
+func notNode(child *repos.AssetQueryNode) *repos.AssetQueryNode {
+	return &repos.AssetQueryNode{Op: repos.AssetQueryNot, Children: []*repos.AssetQueryNode{child}}
+}
+
+func andNode(children ...*repos.AssetQueryNode) *repos.AssetQueryNode {
+	return &repos.AssetQueryNode{Op: repos.AssetQueryAnd, Children: children}
+}
+
+func condNode(field string, cmp string) *repos.AssetQueryNode {
+	return &repos.AssetQueryNode{Op: repos.AssetQueryCond, Field: field, Cmp: cmp}
+}
+
+func (p *assetQueryParser) buildCond(tok queryToken) (*repos.AssetQueryNode, error) {
+	valueErr := func(format string, args ...any) error {
+		return &AssetQueryError{Message: fmt.Sprintf(format, args...), Offset: tok.valueStart, End: tok.end}
+	}
+	if tok.field == "" {
+		n := condNode(repos.AssetFieldText, "=")
+		n.Text = tok.value
+		return n, nil
+	}
+
+	value := strings.TrimSpace(tok.value)
+	cmp := tok.cmp
+	negate := cmp == "!="
+	if cmp == ":" || cmp == "!=" {
+		cmp = "="
+	}
+	wrap := func(n *repos.AssetQueryNode) (*repos.AssetQueryNode, error) {
+		if negate {
+			return notNode(n), nil
+		}
+		return n, nil
+	}
+	requireEq := func() error {
+		if cmp != "=" {
+			return &AssetQueryError{
+				Message: fmt.Sprintf("%s does not support %q", tok.field, tok.cmp),
+				Offset:  tok.fieldStart,
+				End:     tok.valueStart,
+			}
+		}
+		return nil
+	}
+
+	if field, ok := assetQueryTextFields[tok.field]; ok {
+		if err := requireEq(); err != nil {
+			return nil, err
+		}
+		n := condNode(field, cmp)
+		n.Text = value
+		return wrap(n)
+	}
+
+	switch tok.field {
+	case "type", "ext":
+		if err := requireEq(); err != nil {
+			return nil, err
+		}
+		var exts []string
+		for _, part := range strings.Split(strings.ToLower(value), ",") {
+			part = strings.TrimPrefix(strings.TrimSpace(part), ".")
+			if part == "" {
+				continue
+			}
+			if list, ok := assetFileTypeExts[part]; ok && tok.field == "type" {
+				exts = append(exts, list...)
+				continue
+			}
+			for _, r := range part {
+				if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					return nil, valueErr("unknown file type %q", part)
+				}
+			}
+			exts = append(exts, part)
+		}
+		if len(exts) == 0 {
+			return nil, valueErr("missing value for %s", tok.field)
+		}
+		n := condNode(repos.AssetFieldExt, cmp)
+		n.Values = exts
+		return wrap(n)
+	case "shape":
+		if err := requireEq(); err != nil {
+			return nil, err
+		}
+		shape, ok := assetQueryShapes[strings.ToLower(value)]
+		if !ok {
+			return nil, valueErr("unknown shape %q (landscape, portrait, square, panorama)", value)
+		}
+		n := condNode(repos.AssetFieldShape, cmp)
+		n.Text = shape
+		return wrap(n)
+	case "rating":
+		if v := strings.ToLower(value); v == "none" || v == "unrated" {
+			if err := requireEq(); err != nil {
+				return nil, err
+			}
+			n := condNode(repos.AssetFieldRating, cmp)
+			n.Text = "none"
+			return wrap(n)
+		}
+		num, err := strconv.Atoi(value)
+		if err != nil || num < 0 || num > 5 {
+			return nil, valueErr("rating must be 0-5 or none")
+		}
+		n := condNode(repos.AssetFieldRating, cmp)
+		n.Num = float64(num)
+		return wrap(n)
+	case "width", "height":
+		num, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(value), "px"))
+		if err != nil || num < 0 {
+			return nil, valueErr("invalid pixel value %q", value)
+		}
+		n := condNode(tok.field, cmp)
+		n.Num = float64(num)
+		return wrap(n)
+	case "duration":
+		secs, err := parseQueryDuration(value)
+		if err != nil {
+			return nil, valueErr("invalid duration %q (e.g. 30s, 2m, 1h30m)", value)
+		}
+		n := condNode(repos.AssetFieldDuration, cmp)
+		n.Num = secs
+		return wrap(n)
+	case "size":
+		bytes, err := parseQuerySize(value)
+		if err != nil {
+			return nil, valueErr("invalid size %q (e.g. 500kb, 100mb, 2gb)", value)
+		}
+		n := condNode(repos.AssetFieldSize, cmp)
+		n.Num = bytes
+		return wrap(n)
+	case "modified", "created":
+		field := repos.AssetFieldMtime
+		if tok.field == "created" {
+			field = repos.AssetFieldCreated
+		}
+		start, end, err := parseQueryDateRange(value, p.now)
+		if err != nil {
+			return nil, valueErr("invalid date %q (e.g. today, last-week, 7d, 2024-05, 2024-05-20)", value)
+		}
+		bound := func(cmp string, v int64) *repos.AssetQueryNode {
+			n := condNode(field, cmp)
+			n.Num = float64(v)
+			return n
+		}
+		switch cmp {
+		case ">":
+			return bound(">=", end), nil
+		case ">=":
+			return bound(">=", start), nil
+		case "<":
+			return bound("<", start), nil
+		case "<=":
+			return bound("<", end), nil
+		default:
+			return wrap(andNode(bound(">=", start), bound("<", end)))
+		}
+	}
+	return nil, &AssetQueryError{Message: "unknown field " + tok.field, Offset: tok.fieldStart, End: tok.valueStart}
+}
+
+// parseQueryDuration 解析时长为秒：纯数字按秒，其余按 Go duration 语法（30s / 2m / 1h30m）
+func parseQueryDuration(v string) (float64, error) {
+	if f, err := strconv.ParseFloat(v, 64); err == nil && f >= 0 {
+		return f, nil
+	}
+	d, err := time.ParseDuration(strings.ToLower(v))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration")
+	}
+	return d.Seconds(), nil
+}
+
+// parseQuerySize 解析文件大小为字节（1024 进制）：500kb / 100mb / 2g
+func parseQuerySize(v string) (float64, error) {
+	s := strings.ToLower(v)
+	units := []struct {
+		suffix string
+		mult   float64
+	}{
+		{"tb", math.Pow(1024, 4)}, {"t", math.Pow(1024, 4)},
+		{"gb", math.Pow(1024, 3)}, {"g", math.Pow(1024, 3)},
+		{"mb", math.Pow(1024, 2)}, {"m", math.Pow(1024, 2)},
+		{"kb", 1024}, {"k", 1024},
+		{"b", 1},
+	}
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			s = strings.TrimSuffix(s, u.suffix)
+			mult = u.mult
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("invalid size")
+	}
+	return f * mult, nil
+}
+
+// parseQueryDateRange 将日期表达式解析为 Unix 秒区间 [start, end)
+func parseQueryDateRange(v string, now time.Time) (int64, int64, error) {
+	s := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(v)), "_", "-")
+	loc := now.Location()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	weekday := int(now.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+	week := day.AddDate(0, 0, -weekday+1)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	year := time.Date(now.Year(), 1, 1, 0, 0, 0, 0, loc)
+	future := now.Add(time.Second).Unix()
+
+	switch s {
+	case "today":
+		return day.Unix(), future, nil
+	case "yesterday":
+		return day.AddDate(0, 0, -1).Unix(), day.Unix(), nil
+	case "this-week", "thisweek":
+		return week.Unix(), future, nil
+	case "last-week", "lastweek":
+		return week.AddDate(0, 0, -7).Unix(), week.Unix(), nil
+	case "this-month", "thismonth":
+		return month.Unix(), future, nil
+	case "last-month", "lastmonth":
+		return month.AddDate(0, -1, 0).Unix(), month.Unix(), nil
+	case "this-year", "thisyear":
+		return year.Unix(), future, nil
+	case "last-year", "lastyear":
+		return year.AddDate(-1, 0, 0).Unix(), year.Unix(), nil
+	}
+
+	// 相对时间：24h / 7d / 2w 表示最近一段时间
+	if n := len(s); n >= 2 {
+		if num, err := strconv.Atoi(s[:n-1]); err == nil && num > 0 {
+			var d time.Duration
+			switch s[n-1] {
+			case 'h':
+				d = time.Duration(num) * time.Hour
+			case 'd':
+				d = time.Duration(num) * 24 * time.Hour
+			case 'w':
+				d = time.Duration(num) * 7 * 24 * time.Hour
+			}
+			if d > 0 {
+				return now.Add(-d).Unix(), future, nil
+			}
+		}
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t.Unix(), t.AddDate(0, 0, 1).Unix(), nil
+	}
+	if t, err := time.ParseInLocation("2006-01", s, loc); err == nil {
+		return t.Unix(), t.AddDate(0, 1, 0).Unix(), nil
+	}
+	if t, err := time.ParseInLocation("2006", s, loc); err == nil {
+		return t.Unix(), t.AddDate(1, 0, 0).Unix(), nil
+	}
+	return 0, 0, fmt.Errorf("invalid date")
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/repos"
+)
+
+// formatQueryNode 表达式树的紧凑文本形式，便于表驱动比较
+func formatQueryNode(n *repos.AssetQueryNode) string {
+	if n == nil {
+		return "<nil>"
+	}
+	if n.Op != repos.AssetQueryCond {
+		parts := make([]string, 0, len(n.Children))
+		for _, c := range n.Children {
+			parts = append(parts, formatQueryNode(c))
+		}
+		return n.Op + "(" + strings.Join(parts, ",") + ")"
+	}
+	switch {
+	case len(n.Values) > 0:
+		return n.Field + n.Cmp + "[" + strings.Join(n.Values, "|") + "]"
+	case n.Text != "":
+		return n.Field + n.Cmp + n.Text
+	default:
+		return n.Field + n.Cmp + strconv.FormatFloat(n.Num, 'f', -1, 64)
+	}
+}
+
+func TestParseAssetQuery(t *testing.T) {
+	now := time.Date(2024, 5, 22, 15, 0, 0, 0, time.UTC) // 周三
+	day := func(y int, m time.Month, d int) int64 { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() }
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", "<nil>"},
+		{"   ", "<nil>"},
+		{"beach", "text=beach"},
+		{`"summer trip"`, "text=summer trip"},
+		{"beach sunset", "and(text=beach,text=sunset)"},
+		{"beach OR sunset", "or(text=beach,text=sunset)"},
+		{"a b OR c", "or(and(text=a,text=b),text=c)"},
+		{"a AND b", "and(text=a,text=b)"},
+		{"-tag:rejected", "not(tag=rejected)"},
+		{"NOT tag:rejected", "not(tag=rejected)"},
+		{"tag!=rejected", "not(tag=rejected)"},
+		{"(a OR b) c", "and(or(text=a,text=b),text=c)"},
+		{`project:"Vlog 12"`, "project=Vlog 12"},
+		{"ext:mov,.MP4", "ext=[mov|mp4]"},
+		{"shape:vertical", "shape=portrait"},
+		{"rating>=4", "rating>=4"},
+		{"rating:none", "rating=none"},
+		{"stars:unrated", "rating=none"},
+		{"w>=3840", "width>=3840"},
+		{"h<1080px", "height<1080"},
+		{"duration>30s", "duration>30"},
+		{"dur<=1h30m", "duration<=5400"},
+		{"size>2mb", fmt.Sprintf("size>%d", 2*1024*1024)},
+		{"modified:2024-05-20", fmt.Sprintf("and(mtime>=%d,mtime<%d)", day(2024, 5, 20), day(2024, 5, 21))},
+		{"modified>2024-05", fmt.Sprintf("mtime>=%d", day(2024, 6, 1))},
+		{"created<last-week", fmt.Sprintf("created<%d", day(2024, 5, 13))},
+		{"foo:bar", "text=foo:bar"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := ParseAssetQuery(tc.in, now)
+			if err != nil {
+				t.Fatalf("ParseAssetQuery(%q) error: %v", tc.in, err)
+			}
+			if s := formatQueryNode(got); s != tc.want {
+				t.Fatalf("ParseAssetQuery(%q) = %s, want %s", tc.in, s, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseAssetQuery_Errors(t *testing.T) {
+	tests := []struct {
+		in         string
+		offset     int
+		end        int
+		msgContain string
+	}{
+		{"type:video rating>=9", 19, 20, "rating must be"},
+		{`"unterminated`, 0, 13, "unterminated quote"},
+		{"(a OR b", 0, 1, "unclosed"},
+		{"a)", 1, 2, "unexpected ')'"},
+		{"()", 0, 2, "empty group"},
+		{"a OR", 2, 4, "after OR"},
+		{"AND a", 0, 3, "both sides"},
+		{"tag>=x", 0, 5, "does not support"},
+		{"shape:round", 6, 11, "unknown shape"},
+		{"size:lots", 5, 9, "invalid size"},
+		{"rating=>3", 6, 8, "unknown operator"},
+		{"tag:", 0, 4, "missing value"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			_, err := ParseAssetQuery(tc.in, time.Now())
+			var qe *AssetQueryError
+			if !errors.As(err, &qe) {
+				t.Fatalf("ParseAssetQuery(%q) error = %v, want AssetQueryError", tc.in, err)
+			}
+			if qe.Offset != tc.offset || qe.End != tc.end || !strings.Contains(qe.Message, tc.msgContain) {
+				t.Fatalf("ParseAssetQuery(%q) = %+v, want [%d,%d) containing %q", tc.in, qe, tc.offset, tc.end, tc.msgContain)
+			}
+		})
+	}
+}
+
+func TestBuildAssetListQuery_FallsBackToText(t *testing.T) {
+	q, qe := buildAssetListQuery(ListAssetsRequest{Query: `50% "off`})
+	if qe == nil {
+		t.Fatal("expected parse error to be reported")
+	}
+	if got := formatQueryNode(q.Expr); got != `text=50% "off` {
+		t.Fatalf("fallback expr = %s", got)
+	}
+
+	q, qe = buildAssetListQuery(ListAssetsRequest{Query: "tag:b-roll"})
+	if qe != nil || formatQueryNode(q.Expr) != "tag=b-roll" {
+		t.Fatalf("valid query: expr=%s err=%v", formatQueryNode(q.Expr), qe)
+	}
+}
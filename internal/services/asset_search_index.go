@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	searchIndexBatchSize = 200
+	// searchIndexSyncLimit 搜索前同步刷新的最大条数，超出部分由后台继续补齐
+	searchIndexSyncLimit = 200
+)
+
+// searchMetaKeys 写入 meta 列的媒体元数据字段（顶层与 extra 内）
+var searchMetaKeys = []string{"format", "codec", "camera_model", "lens_model", "title", "artist", "album", "album_artist", "genre", "composer"}
+
+// AssetSearchIndexer 消费 asset_search_dirty 队列，维护 assets_fts 全文索引
+type AssetSearchIndexer struct {
+	repo     *repos.AssetSearchRepo
+	mu       sync.Mutex
+	interval time.Duration
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewAssetSearchIndexer(repo *repos.AssetSearchRepo) *AssetSearchIndexer {
+	return &AssetSearchIndexer{
+		repo:     repo,
+		interval: 2 * time.Second,
+		stopChan: make(chan struct{}),
+	}
+}
+
+func (x *AssetSearchIndexer) Start() {
+	x.wg.Add(1)
+	go x.loop()
+}
+
+func (x *AssetSearchIndexer) Stop() {
+	close(x.stopChan)
+	x.wg.Wait()
+}
+
+func (x *AssetSearchIndexer) loop() {
+	defer x.wg.Done()
+	ticker := time.NewTicker(x.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := x.Flush(context.Background(), 0); err != nil {
+			logger.Warn("Asset search index refresh failed", zap.Error(err))
+		}
+		select {
+		case <-x.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush 处理待索引队列，max<=0 表示处理到队列为空；返回处理条数。
+// 锁只在单批内持有，批次之间让出给搜索请求
+func (x *AssetSearchIndexer) Flush(ctx context.Context, max int) (int, error) {
+	done := 0
+	for max <= 0 || done < max {
+		batch := searchIndexBatchSize
+		if max > 0 && max-done < batch {
+			batch = max - done
+		}
+		x.mu.Lock()
+		ids, err := x.indexBatch(ctx, batch)
+		x.mu.Unlock()
+		if err != nil {
+			return done, err
+		}
+		done += len(ids)
+		if len(ids) < batch {
+			return done, nil
+		}
+	}
+	return done, nil
+}
+
+// SyncRecent 搜索前补齐一批最近变更的索引；后台正在处理时不等待，直接使用现有索引
+func (x *AssetSearchIndexer) SyncRecent(ctx context.Context) error {
+	if !x.mu.TryLock() {
+		return nil
+	}
+	defer x.mu.Unlock()
+	_, err := x.indexBatch(ctx, searchIndexSyncLimit)
+	return err
+}
+
+// indexBatch 在一个事务内索引一批资产并移出队列；失败时队列保持不变
+func (x *AssetSearchIndexer) indexBatch(ctx context.Context, limit int) ([]string, error) {
+	ids, err := x.repo.IndexDirty(ctx, limit, buildAssetSearchDocs)
+	if err != nil {
+		return nil, fmt.Errorf("index search batch: %w", err)
+	}
+	return ids, nil
+}
+
+func buildAssetSearchDocs(ids []string, sources map[string]*repos.AssetSearchSource) ([]repos.AssetSearchDoc, []string) {
+	docs := make([]repos.AssetSearchDoc, 0, len(sources))
+	var removed []string
+	for _, id := range ids {
+		src, ok := sources[id]
+		if !ok {
+			removed = append(removed, id)
+			continue
+		}
+		docs = append(docs, buildAssetSearchDoc(src))
+	}
+	return docs, removed
+}
+
+func buildAssetSearchDoc(src *repos.AssetSearchSource) repos.AssetSearchDoc {
+	clean := filepath.Clean(src.Path)
+	base := filepath.Base(clean)
+	dir := filepath.Dir(clean)
+
+	var meta []string
+	if strings.TrimSpace(src.MediaMeta) != "" {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(src.MediaMeta), &m); err == nil {
+			extra, _ := m["extra"].(map[string]any)
+			for _, key := range searchMetaKeys {
+				if v, ok := m[key].(string); ok {
+					meta = append(meta, v)
+				}
+				if v, ok := extra[key].(string); ok {
+					meta = append(meta, v)
+				}
+			}
+		}
+	}
+
+	return repos.AssetSearchDoc{
+		AssetID:  src.AssetID,
+		Name:     searchField(base),
+		Dirs:     searchField(dir),
+		Tags:     searchField(src.Tags...),
+		Projects: searchField(src.Projects...),
+		Notes:    searchField(src.Notes...),
+		Meta:     searchField(meta...),
+	}
+}
+
+// searchField 分词并去重后拼成一列索引文本
+func searchField(values ...string) string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range values {
+		for _, tok := range utils.SearchIndexTokens(v) {
+			if !seen[tok] {
+				seen[tok] = true
+				out = append(out, tok)
+			}
+		}
+	}
+	return strings.Join(out, " ")
+}
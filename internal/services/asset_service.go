@@ -1,18 +1,22 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"time"
 
 	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
 	"media-assistant-os/internal/repos"
 	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
 )
 
 type AssetService struct {
@@ -28,6 +32,10 @@ type AssetService struct {
 	taskService       *TaskService
 	cache             *AssetCache
 	bloom             *utils.BloomFilter
+	phashIndex        *utils.BKTree
+	searchIndex       *AssetSearchIndexer
+	scanIgnore        *ScanIgnore
+	volumes           *VolumeService
 }
 
 // NewAssetService 创建资产服务实例
@@ -49,9 +57,31 @@ func NewAssetService(assets *repos.AssetRepo, historyEvents *repos.AssetHistoryE
 		taskService:       taskService,
 		cache:             NewAssetCache(200000),
 		bloom:             bf,
+		phashIndex:        utils.NewBKTree(),
 	}
 }
 
+// SetSearchIndexer 绑定全文索引器，ListAssets 在关键词搜索前同步刷新索引
+func (s *AssetService) SetSearchIndexer(x *AssetSearchIndexer) {
+	s.searchIndex = x
+}
+
+// SetScanIgnore 绑定扫描忽略规则，扫描、监控与 IndexFile 共用
+func (s *AssetService) SetScanIgnore(x *ScanIgnore) {
+	s.scanIgnore = x
+}
+
+// SetVolumeService 绑定卷状态，离线卷上的资产不会被标记为 MISSING
+func (s *AssetService) SetVolumeService(v *VolumeService) {
+	s.volumes = v
+}
+
+// ReloadPathIndex 资产路径批量变化（卷重新挂载迁移）后重建路径缓存与 Bloom Filter
+func (s *AssetService) ReloadPathIndex(ctx context.Context) error {
+	s.cache.Clear()
+	return s.InitBloomFilter(ctx)
+}
+
 // InitBloomFilter 初始化布隆过滤器（需在服务启动时调用）
 func (s *AssetService) InitBloomFilter(ctx context.Context) error {
 	paths, err := s.assets.GetAllPaths(ctx)
@@ -109,6 +139,8 @@ type ListAssetsRequest struct {
 
 	Limit  int
 	Cursor string
+	// CountMode 总数统计方式：exact / estimate / none；为空时首页精确计数、翻页不计数
+	CountMode string
 }
 
 type AssetListItem struct {
@@ -133,7 +165,66 @@ type ListAssetsResult struct {
 	Items      []AssetListItem `json:"items"`
 	NextCursor *string         `json:"nextCursor"`
 	HasMore    bool            `json:"hasMore"`
-	Total      int             `json:"total"`
+	// Total 为 nil 表示本次未计数；TotalEstimated 为 true 时 Total 是达到上限的下界
+	Total          *int `json:"total"`
+	TotalEstimated bool `json:"totalEstimated"`
+	// QueryError 搜索框内容不是合法的结构化查询时已按普通关键词搜索，附带解析错误供前端提示
+	QueryError *AssetQueryError `json:"queryError,omitempty"`
+}
+
+const (
+	AssetCountExact    = "exact"
+	AssetCountEstimate = "estimate"
+	AssetCountNone     = "none"
+
+	// assetCountEstimateCap estimate 模式最多数到的条数
+	assetCountEstimateCap = 10000
+)
+
+// EncodeAssetCursor 将键集游标编码为不透明字符串
+func EncodeAssetCursor(c *repos.AssetListCursor) (string, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeAssetCursor 解析不透明游标；空字符串返回 nil
+func DecodeAssetCursor(cursor string) (*repos.AssetListCursor, error) {
+	cursor = strings.TrimSpace(cursor)
+	if cursor == "" {
+		return nil, nil
+	}
+	invalid := errors.New("invalid cursor")
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var c repos.AssetListCursor
+	if err := dec.Decode(&c); err != nil || c.Sort == "" || c.ID == "" || c.Key == nil {
+		return nil, invalid
+	}
+	return &c, nil
+}
+
+func normalizeAssetCountMode(mode string, hasCursor bool) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "":
+		if hasCursor {
+			return AssetCountNone, nil
+		}
+		return AssetCountExact, nil
+	case AssetCountExact:
+		return AssetCountExact, nil
+	case AssetCountEstimate:
+		return AssetCountEstimate, nil
+	case AssetCountNone:
+		return AssetCountNone, nil
+	}
+	return "", errors.New("count must be one of exact, estimate, none")
 }
 
 // ListDirectory lists files in a directory and enriches them with asset status
@@ -184,6 +275,8 @@ func (s *AssetService) ListDirectory(ctx context.Context, path string) ([]FileEn
 		return nil, err
 	}
 
+	s.boostPendingAssets(assets)
+
 	// Create a map for quick lookup
 	assetMap := make(map[string]models.Asset)
 	for _, a := range assets {
@@ -215,6 +308,17 @@ func (s *AssetService) ListDirectory(ctx context.Context, path string) ([]FileEn
 	return fileEntries, nil
 }
 
+// boostPendingAssets 用户正在浏览的资产若仍在处理中，提升其任务优先级（后台执行）
+func (s *AssetService) boostPendingAssets(assets []models.Asset) {
+	ids := make([]string, 0, len(assets))
+	for i := range assets {
+		if assets[i].Status == "PENDING" {
+			ids = append(ids, assets[i].ID)
+		}
+	}
+	s.taskService.boostVisibleAssets(ids)
+}
+
 // GetCache 返回资产缓存（用于日志查看器）
 func (s *AssetService) GetCache() *AssetCache {
 	return s.cache
@@ -269,6 +373,10 @@ func (s *AssetService) IndexFile(ctx context.Context, req IndexFileRequest) (*In
 		return nil, err
 	}
 	trigger := normalizeIndexTrigger(req.Trigger)
+	// 扫描遍历时已逐级判断过忽略规则
+	if trigger != "scan" && s.scanIgnore.Match(abs, false, false).Ignored {
+		return nil, ErrPathIgnored
+	}
 
 	// 1. Check cache first
 	if cached, ok := s.cache.GetByPath(abs); ok {
@@ -558,12 +666,18 @@ func normalizeIndexTrigger(v string) string {
 
 // UpdateMediaMeta 更新资产的媒体元数据
 func (s *AssetService) UpdateMediaMeta(ctx context.Context, id string, mediaMeta string) error {
-	return s.assets.UpdateMediaMeta(ctx, id, mediaMeta)
+	if err := s.assets.UpdateMediaMeta(ctx, id, mediaMeta); err != nil {
+		return err
+	}
+	return s.syncPerceptualHash(ctx, id, mediaMeta)
 }
 
 func (s *AssetService) ApplyDerivedMetadata(ctx context.Context, id string, mediaMeta string, shape string, suggestedRating *int) error {
 	shape = normalizeShape(shape)
-	return s.assets.UpdateDerivedMetadata(ctx, id, mediaMeta, shape, suggestedRating)
+	if err := s.assets.UpdateDerivedMetadata(ctx, id, mediaMeta, shape, suggestedRating); err != nil {
+		return err
+	}
+	return s.syncPerceptualHash(ctx, id, mediaMeta)
 }
 
 // GetAsset 根据ID获取资产（委托给GetAssetCached）
@@ -685,7 +799,7 @@ func (s *AssetService) RestoreAsset(ctx context.Context, id string) error {
 
 	// 1. 检查物理文件是否存在，决定恢复到什么状态
 	newStatus := "READY"
-	if _, err := os.Stat(asset.Path); os.IsNotExist(err) {
+	if _, err := os.Stat(asset.Path); os.IsNotExist(err) && !s.volumes.IsOffline(asset.Path) {
 		newStatus = "MISSING"
 	}
 
@@ -743,6 +857,8 @@ func (s *AssetService) GetProjectFiles(ctx context.Context, projectID string) ([
 func (s *AssetService) ListAssets(ctx context.Context, req ListAssetsRequest) (*ListAssetsResult, error) {
 	req = preprocessListAssetFilters(req)
 
+	query, queryErr := buildAssetListQuery(req)
+
 	if strings.TrimSpace(req.Query) != "" && s.searchHistoryRepo != nil {
 		query := strings.TrimSpace(req.Query)
 		filters := map[string]any{
@@ -756,40 +872,33 @@ func (s *AssetService) ListAssets(ctx context.Context, req ListAssetsRequest) (*
 		}()
 	}
 
-	offset := 0
-	if strings.TrimSpace(req.Cursor) != "" {
-		v, err := strconv.Atoi(strings.TrimSpace(req.Cursor))
-		if err != nil || v < 0 {
-			return nil, errors.New("invalid cursor")
+	if strings.TrimSpace(req.Query) != "" && s.searchIndex != nil {
+		// 先补齐最近变更的索引，避免刚导入/改名的资产搜不到
+		if err := s.searchIndex.SyncRecent(ctx); err != nil {
+			logger.Warn("Asset search index flush failed", zap.Error(err))
 		}
-		offset = v
 	}
 
-	assets, total, err := s.assets.ListByQuery(ctx, repos.AssetListQuery{
-		ProjectID: req.ProjectID,
-		Directory: req.Directory,
-		Query:     req.Query,
-		TagIDs:    req.TagIDs,
-		Types:     req.Types,
-		Shapes:    req.Shapes,
-		SizeMin:   req.SizeMin,
-		SizeMax:   req.SizeMax,
-		RatingMin: req.RatingMin,
-		RatingMax: req.RatingMax,
-		MtimeFrom: req.MtimeFrom,
-		MtimeTo:   req.MtimeTo,
-		WidthMin:  req.WidthMin,
-		WidthMax:  req.WidthMax,
-		HeightMin: req.HeightMin,
-		HeightMax: req.HeightMax,
-		SortBy:    req.SortBy,
-		SortOrder: req.SortOrder,
-		Limit:     req.Limit,
-		Offset:    offset,
-	})
+	after, err := DecodeAssetCursor(req.Cursor)
 	if err != nil {
 		return nil, err
 	}
+	countMode, err := normalizeAssetCountMode(req.CountMode, after != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	query.Limit = req.Limit
+	query.After = after
+	page, err := s.assets.ListByQuery(ctx, query)
+	if err != nil {
+		if errors.Is(err, repos.ErrAssetCursorMismatch) {
+			return nil, errors.New("invalid cursor: sort changed, restart from the first page")
+		}
+		return nil, err
+	}
+	assets := page.Assets
+	s.boostPendingAssets(assets)
 
 	items := make([]AssetListItem, 0, len(assets))
 	for _, a := range assets {
@@ -826,19 +935,86 @@ func (s *AssetService) ListAssets(ctx context.Context, req ListAssetsRequest) (*
 		})
 	}
 
-	hasMore := offset+len(items) < total
-	var nextCursor *string
-	if hasMore {
-		next := strconv.Itoa(offset + len(items))
-		nextCursor = &next
+	res := &ListAssetsResult{
+		Items:      items,
+		HasMore:    page.HasMore,
+		QueryError: queryErr,
+	}
+	if page.NextCursor != nil {
+		next, err := EncodeAssetCursor(page.NextCursor)
+		if err != nil {
+			return nil, err
+		}
+		res.NextCursor = &next
 	}
 
-	return &ListAssetsResult{
-		Items:      items,
-		NextCursor: nextCursor,
-		HasMore:    hasMore,
-		Total:      total,
-	}, nil
+	switch countMode {
+	case AssetCountExact:
+		total, err := s.assets.CountByQuery(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		res.Total = &total
+	case AssetCountEstimate:
+		total, capped, err := s.assets.CountByQueryCapped(ctx, query, assetCountEstimateCap)
+		if err != nil {
+			return nil, err
+		}
+		res.Total = &total
+		res.TotalEstimated = capped
+	}
+	return res, nil
+}
+
+// buildAssetListQuery 将（已预处理的）列表请求转换为仓储查询；搜索框内容按结构化查询解析，
+// 解析失败时整段按普通关键词搜索，并返回解析错误
+func buildAssetListQuery(req ListAssetsRequest) (repos.AssetListQuery, *AssetQueryError) {
+	expr, err := ParseAssetQuery(req.Query, time.Now())
+	var queryErr *AssetQueryError
+	if err != nil {
+		if !errors.As(err, &queryErr) {
+			queryErr = &AssetQueryError{Message: err.Error(), End: len([]rune(req.Query))}
+		}
+		expr = &repos.AssetQueryNode{Op: repos.AssetQueryCond, Field: repos.AssetFieldText, Cmp: "=", Text: req.Query}
+	}
+	return repos.AssetListQuery{
+		ProjectID: req.ProjectID,
+		Directory: req.Directory,
+		Expr:      expr,
+		RankText:  assetQueryRankText(expr),
+		TagIDs:    req.TagIDs,
+		Types:     req.Types,
+		Shapes:    req.Shapes,
+		SizeMin:   req.SizeMin,
+		SizeMax:   req.SizeMax,
+		RatingMin: req.RatingMin,
+		RatingMax: req.RatingMax,
+		MtimeFrom: req.MtimeFrom,
+		MtimeTo:   req.MtimeTo,
+		WidthMin:  req.WidthMin,
+		WidthMax:  req.WidthMax,
+		HeightMin: req.HeightMin,
+		HeightMax: req.HeightMax,
+		SortBy:    req.SortBy,
+		SortOrder: req.SortOrder,
+	}, queryErr
+}
+
+// CountAssets 统计满足列表筛选条件的资产数
+func (s *AssetService) CountAssets(ctx context.Context, req ListAssetsRequest) (int, error) {
+	query, _ := buildAssetListQuery(preprocessListAssetFilters(req))
+	return s.assets.CountByQuery(ctx, query)
+}
+
+// MatchAssetIDs 返回满足筛选条件的资产 ID；ids 非空时只在这些资产内判断。
+// 智能集合的查询必须能解析，不做关键词回退
+func (s *AssetService) MatchAssetIDs(ctx context.Context, req ListAssetsRequest, ids []string) ([]string, error) {
+	query, queryErr := buildAssetListQuery(preprocessListAssetFilters(req))
+	if queryErr != nil {
+		return nil, queryErr
+	}
+	query.IDs = ids
+	return s.assets.ListIDsByQuery(ctx, query)
 }
 
 func (s *AssetService) GetSearchHistory(ctx context.Context, limit int) ([]models.SearchHistory, error) {
@@ -972,20 +1148,30 @@ func (s *AssetService) SetProjectAssetStatus(ctx context.Context, projectID stri
 	return s.projectAssets.UpdateStatus(ctx, projectID, assetID, status)
 }
 
+// assetFileTypeExts 文件类型 → 扩展名
+var assetFileTypeExts = map[string][]string{
+	"image":    {"jpg", "jpeg", "png", "gif", "webp", "bmp", "svg", "tif", "tiff", "psd", "psb", "exr"},
+	"video":    {"mp4", "avi", "mov", "mkv", "webm", "m4v", "flv", "wmv", "r3d", "braw"},
+	"audio":    {"mp3", "wav", "flac", "aac", "ogg", "m4a", "aif", "aiff", "bwf"},
+	"document": {"pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "txt", "md", "csv"},
+}
+
+var assetExtFileType = func() map[string]string {
+	m := make(map[string]string)
+	for fileType, exts := range assetFileTypeExts {
+		for _, ext := range exts {
+			m[ext] = fileType
+		}
+	}
+	return m
+}()
+
 func detectAssetFileType(name string) string {
 	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
-	switch ext {
-	case "jpg", "jpeg", "png", "gif", "webp", "bmp", "svg", "tif", "tiff", "psd", "psb", "exr":
-		return "image"
-	case "mp4", "avi", "mov", "mkv", "webm", "m4v", "flv", "wmv", "r3d", "braw":
-		return "video"
-	case "mp3", "wav", "flac", "aac", "ogg", "m4a", "aif", "aiff", "bwf":
-		return "audio"
-	case "pdf", "doc", "docx", "xls", "xlsx", "ppt", "pptx", "txt", "md", "csv":
-		return "document"
-	default:
-		return "file"
+	if fileType, ok := assetExtFileType[ext]; ok {
+		return fileType
 	}
+	return "file"
 }
 
 func normalizeShape(shape string) string {
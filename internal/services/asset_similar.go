@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const (
+	phashIndexPageSize     = 5000
+	similarDefaultDistance = 10
+	similarMaxDistance     = 20
+	similarDefaultLimit    = 50
+	similarMaxLimit        = 500
+)
+
+// InitPerceptualIndex 回填 phash 列并将全部感知哈希载入内存 BK 树（需在服务启动时调用）
+func (s *AssetService) InitPerceptualIndex(ctx context.Context) error {
+	backfilled := 0
+	afterID := ""
+	for {
+		list, err := s.assets.ListPHashBackfill(ctx, afterID, phashIndexPageSize)
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			afterID = list[i].ID
+			h, ok := dhashFromMediaMeta(list[i].MediaMeta)
+			if !ok {
+				continue
+			}
+			v := int64(h)
+			if err := s.assets.UpdatePHash(ctx, list[i].ID, &v); err != nil {
+				return err
+			}
+			backfilled++
+		}
+		if len(list) < phashIndexPageSize {
+			break
+		}
+	}
+
+	afterID = ""
+	for {
+		list, err := s.assets.ListPHashes(ctx, afterID, phashIndexPageSize)
+		if err != nil {
+			return err
+		}
+		for _, item := range list {
+			s.phashIndex.Add(uint64(item.PHash), item.ID)
+			afterID = item.ID
+		}
+		if len(list) < phashIndexPageSize {
+			break
+		}
+	}
+	logger.Info("Perceptual hash index loaded",
+		zap.Int("assets", s.phashIndex.Len()),
+		zap.Int("backfilled", backfilled))
+	return nil
+}
+
+// syncPerceptualHash 从新写入的 media_meta 中提取 dHash，同步 phash 列与 BK 树
+func (s *AssetService) syncPerceptualHash(ctx context.Context, id string, mediaMeta string) error {
+	h, ok := dhashFromMediaMeta(mediaMeta)
+	if !ok {
+		return nil
+	}
+	asset, err := s.assets.GetByID(ctx, id)
+	if err != nil || asset == nil {
+		return err
+	}
+	if asset.PHash != nil && uint64(*asset.PHash) == h {
+		return nil
+	}
+	v := int64(h)
+	if err := s.assets.UpdatePHash(ctx, id, &v); err != nil {
+		return err
+	}
+	if asset.PHash != nil {
+		s.phashIndex.Remove(uint64(*asset.PHash), id)
+	}
+	s.phashIndex.Add(h, id)
+	s.cache.Invalidate(id)
+	return nil
+}
+
+// SimilarAsset 相似检索结果
+type SimilarAsset struct {
+	models.Asset
+	Distance   int            `json:"distance"`
+	Similarity float64        `json:"similarity"` // 1 - distance/64
+	Level      DuplicateLevel `json:"level,omitempty"`
+}
+
+// similarLevel 指纹相同时按去重规则分类；否则仅在距离足够近时标记为画面近似
+func similarLevel(a *models.Asset, b *models.Asset, distance int) DuplicateLevel {
+	if a.Fingerprint != nil && b.Fingerprint != nil && *a.Fingerprint == *b.Fingerprint {
+		return classifyDuplicate(a, b)
+	}
+	if distance <= duplicateVisualMaxDistance {
+		return DuplicateVisual
+	}
+	return ""
+}
+
+// FindSimilarAssets 按 dHash 汉明距离查找画面相似的资产，按距离升序
+func (s *AssetService) FindSimilarAssets(ctx context.Context, assetID string, maxDistance int, limit int) ([]SimilarAsset, error) {
+	if assetID == "" {
+		return nil, errors.New("assetID is required")
+	}
+	if maxDistance <= 0 {
+		maxDistance = similarDefaultDistance
+	}
+	if maxDistance > similarMaxDistance {
+		maxDistance = similarMaxDistance
+	}
+	if limit <= 0 {
+		limit = similarDefaultLimit
+	}
+	if limit > similarMaxLimit {
+		limit = similarMaxLimit
+	}
+
+	asset, err := s.assets.GetByID(ctx, assetID)
+	if err != nil {
+		return nil, err
+	}
+	if asset == nil {
+		return nil, errors.New("asset not found")
+	}
+	hash, ok := assetPHash(asset)
+	if !ok {
+		return nil, errors.New("asset has no perceptual hash")
+	}
+
+	matches := s.phashIndex.Search(hash, maxDistance)
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Distance != matches[j].Distance {
+			return matches[i].Distance < matches[j].Distance
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	ids := make([]string, 0, len(matches))
+	distances := make(map[string]int, len(matches))
+	for _, m := range matches {
+		if m.ID == asset.ID {
+			continue
+		}
+		ids = append(ids, m.ID)
+		distances[m.ID] = m.Distance
+	}
+	// 多取一些以抵消被过滤掉的 IGNORED/MISSING 资产
+	if len(ids) > limit*2 {
+		ids = ids[:limit*2]
+	}
+	found, err := s.assets.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]SimilarAsset, 0, len(found))
+	for i := range found {
+		a := found[i]
+		if a.Status == "IGNORED" || a.Status == "MISSING" {
+			continue
+		}
+		d := distances[a.ID]
+		out = append(out, SimilarAsset{
+			Asset:      a,
+			Distance:   d,
+			Similarity: 1 - float64(d)/64,
+			Level:      similarLevel(asset, &a, d),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance < out[j].Distance
+		}
+		return out[i].ID < out[j].ID
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
@@ -3,9 +3,11 @@ package services
 import (
 	"encoding/json"
 	"math"
+	"strconv"
 	"strings"
 
 	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/utils"
 )
 
 type DuplicateLevel string
@@ -13,9 +15,13 @@ type DuplicateLevel string
 const (
 	DuplicateExact    DuplicateLevel = "EXACT_DUP"
 	DuplicateLikely   DuplicateLevel = "LIKELY_DUP"
+	DuplicateVisual   DuplicateLevel = "VISUAL_SIMILAR" // 内容不同但画面近似（缩放/重新压缩/轻微修图）
 	DuplicateConflict DuplicateLevel = "CONFLICT"
 )
 
+// duplicateVisualMaxDistance dHash 汉明距离不超过该值视为画面近似
+const duplicateVisualMaxDistance = 6
+
 type MediaMeta struct {
 	Width    int     `json:"width,omitempty"`
 	Height   int     `json:"height,omitempty"`
@@ -39,7 +45,8 @@ func chooseDuplicateParent(current *models.Asset, dupes []models.Asset) duplicat
 			continue
 		}
 		level := classifyDuplicate(current, &d)
-		if level == DuplicateConflict {
+		// 画面近似不是副本，不作为父资产（交由血缘候选处理）
+		if level == DuplicateConflict || level == DuplicateVisual {
 			continue
 		}
 		if best.Parent == nil || duplicateRank(level) > duplicateRank(best.Level) {
@@ -58,16 +65,34 @@ func chooseDuplicateParent(current *models.Asset, dupes []models.Asset) duplicat
 func duplicateRank(level DuplicateLevel) int {
 	switch level {
 	case DuplicateExact:
-		return 2
+		return 3
 	case DuplicateLikely:
+		return 2
+	case DuplicateVisual:
 		return 1
 	default:
 		return 0
 	}
 }
 
-// classifyDuplicate 对两个资产进行重复分类
+// classifyDuplicate 对两个资产进行重复分类；元数据冲突但 dHash 接近时判为画面近似
 func classifyDuplicate(a *models.Asset, b *models.Asset) DuplicateLevel {
+	level := classifyDuplicateMeta(a, b)
+	if level == DuplicateConflict && visuallySimilar(a, b) {
+		return DuplicateVisual
+	}
+	return level
+}
+
+// visuallySimilar 两个资产均有 dHash 且汉明距离在阈值内
+func visuallySimilar(a *models.Asset, b *models.Asset) bool {
+	ha, okA := assetPHash(a)
+	hb, okB := assetPHash(b)
+	return okA && okB && utils.HammingDistance(ha, hb) <= duplicateVisualMaxDistance
+}
+
+// classifyDuplicateMeta 基于大小与媒体元数据的重复分类
+func classifyDuplicateMeta(a *models.Asset, b *models.Asset) DuplicateLevel {
 	if a == nil || b == nil {
 		return DuplicateLikely
 	}
@@ -163,6 +188,36 @@ func parseMediaMeta(raw string) (MediaMeta, bool) {
 	return m, true
 }
 
+// assetPHash 返回资产的 dHash：优先 phash 列，其次 image_parser 写入的 media_meta.extra.phash
+func assetPHash(a *models.Asset) (uint64, bool) {
+	if a == nil {
+		return 0, false
+	}
+	if a.PHash != nil {
+		return uint64(*a.PHash), true
+	}
+	return dhashFromMediaMeta(a.MediaMeta)
+}
+
+func dhashFromMediaMeta(raw string) (uint64, bool) {
+	if strings.TrimSpace(raw) == "" {
+		return 0, false
+	}
+	var meta struct {
+		Extra struct {
+			PHash json.Number `json:"phash"`
+		} `json:"extra"`
+	}
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil || meta.Extra.PHash == "" {
+		return 0, false
+	}
+	h, err := strconv.ParseUint(meta.Extra.PHash.String(), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return h, true
+}
+
 // eqFold 比较两个字符串是否相等（忽略大小写和空格）
 func eqFold(a string, b string) bool {
 	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
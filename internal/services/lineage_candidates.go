@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// 血缘候选检测规则
+const (
+	LineageRuleVisualHash    = "VISUAL_DHASH"
+	LineageRuleFilenameStem  = "FILENAME_STEM"
+	LineageRuleExportFolder  = "EXPORT_FOLDER"
+	LineageRuleTemporalNear  = "TEMPORAL_PROXIMITY"
+	lineageCandidateRelation = "DERIVED"
+)
+
+const (
+	lineageNeighborLimit     = 200
+	lineageDHashMaxDistance  = 10
+	lineageMinStemLength     = 3
+	lineageTemporalWindow    = 30 * 60 // 秒
+	lineageExportFolderDelay = 2 * 60 * 60
+)
+
+// 工程/源文件格式：通常是导出文件的祖先
+var lineageSourceExts = map[string]bool{
+	".psd": true, ".psb": true, ".ai": true, ".sketch": true, ".fig": true, ".xd": true,
+	".afphoto": true, ".afdesign": true, ".kra": true, ".xcf": true, ".clip": true, ".procreate": true,
+	".blend": true, ".c4d": true, ".aep": true, ".prproj": true, ".drp": true, ".fcpxml": true, ".veg": true,
+	".cr2": true, ".cr3": true, ".nef": true, ".arw": true, ".dng": true, ".raf": true, ".orf": true, ".rw2": true,
+	".tif": true, ".tiff": true,
+}
+
+// 交付/导出格式
+var lineageExportExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".heic": true, ".bmp": true,
+	".mp4": true, ".mov": true, ".webm": true, ".m4v": true, ".mkv": true,
+	".mp3": true, ".wav": true, ".aac": true, ".m4a": true,
+	".pdf": true,
+}
+
+var lineageExportDirs = map[string]bool{
+	"export": true, "exports": true, "exported": true, "output": true, "outputs": true, "out": true,
+	"render": true, "renders": true, "final": true, "finals": true, "deliverables": true, "publish": true,
+	"导出": true, "成品": true, "输出": true, "渲染": true, "定稿": true,
+}
+
+var lineageDerivedSuffix = regexp.MustCompile(`^(final|fin|export|exported|output|render|edit|edited|retouch|web|hd|4k|1080p|720p|small|thumb|cover|copy|v\d+|\d+x|定稿|终稿|成品|导出|修图|副本)$`)
+
+func lineageExt(path string) string {
+	return strings.ToLower(filepath.Ext(path))
+}
+
+func lineageStem(path string) string {
+	base := filepath.Base(path)
+	return strings.ToLower(strings.TrimSuffix(base, filepath.Ext(base)))
+}
+
+// stemDerivation 判断 child 是否由 parent 文件名派生（parent + 分隔符 + 后缀），返回后缀
+func stemDerivation(parent string, child string) (string, bool) {
+	if len([]rune(parent)) < lineageMinStemLength || len(child) <= len(parent)+1 || !strings.HasPrefix(child, parent) {
+		return "", false
+	}
+	switch child[len(parent)] {
+	case '_', '-', '.', ' ':
+	default:
+		return "", false
+	}
+	return strings.Trim(child[len(parent)+1:], "_-. "), true
+}
+
+// knownDerivedSuffix 后缀中任一段命中常见导出/修订标记
+func knownDerivedSuffix(suffix string) bool {
+	for _, part := range strings.FieldsFunc(suffix, func(r rune) bool {
+		return r == '_' || r == '-' || r == '.' || r == ' '
+	}) {
+		if lineageDerivedSuffix.MatchString(part) {
+			return true
+		}
+	}
+	return false
+}
+
+func lineageConfidence(score float64) string {
+	switch {
+	case score >= 0.8:
+		return "HIGH"
+	case score >= 0.5:
+		return "MEDIUM"
+	default:
+		return "LOW"
+	}
+}
+
+type lineageSignal struct {
+	Ancestor   *models.Asset
+	Descendant *models.Asset
+	Rule       string
+	Score      float64
+	Reason     string
+	Evidence   map[string]any
+}
+
+// orderByAge 源文件格式优先作为祖先，否则按修改时间先后
+func orderByAge(a *models.Asset, b *models.Asset) (*models.Asset, *models.Asset) {
+	srcA, srcB := lineageSourceExts[lineageExt(a.Path)], lineageSourceExts[lineageExt(b.Path)]
+	if srcA != srcB {
+		if srcA {
+			return a, b
+		}
+		return b, a
+	}
+	if a.Mtime != b.Mtime {
+		if a.Mtime < b.Mtime {
+			return a, b
+		}
+		return b, a
+	}
+	if a.CreatedAt <= b.CreatedAt {
+		return a, b
+	}
+	return b, a
+}
+
+func detectVisualHashSignal(a *models.Asset, b *models.Asset) *lineageSignal {
+	if detectFileType(a.Path) != "image" || detectFileType(b.Path) != "image" {
+		return nil
+	}
+	// 指纹相同属于精确重复，由去重流程直接建立 COPY 血缘
+	if a.Fingerprint != nil && b.Fingerprint != nil && *a.Fingerprint == *b.Fingerprint {
+		return nil
+	}
+	ha, okA := assetPHash(a)
+	hb, okB := assetPHash(b)
+	if !okA || !okB {
+		return nil
+	}
+	dist := utils.HammingDistance(ha, hb)
+	if dist > lineageDHashMaxDistance {
+		return nil
+	}
+	anc, desc := orderByAge(a, b)
+	return &lineageSignal{
+		Ancestor:   anc,
+		Descendant: desc,
+		Rule:       LineageRuleVisualHash,
+		Score:      1 - float64(dist)/32,
+		Reason:     fmt.Sprintf("visually similar (dHash distance %d)", dist),
+		Evidence:   map[string]any{"hamming_distance": dist},
+	}
+}
+
+func detectFilenameStemSignal(a *models.Asset, b *models.Asset) *lineageSignal {
+	for _, pair := range [][2]*models.Asset{{a, b}, {b, a}} {
+		anc, desc := pair[0], pair[1]
+		ancStem, descStem := lineageStem(anc.Path), lineageStem(desc.Path)
+		ancExt, descExt := lineageExt(anc.Path), lineageExt(desc.Path)
+		srcToExport := lineageSourceExts[ancExt] && lineageExportExts[descExt]
+
+		if ancStem == descStem {
+			if !srcToExport || len([]rune(ancStem)) < lineageMinStemLength {
+				continue
+			}
+			return &lineageSignal{
+				Ancestor: anc, Descendant: desc, Rule: LineageRuleFilenameStem, Score: 0.9,
+				Reason:   fmt.Sprintf("same name %s → %s", ancExt, descExt),
+				Evidence: map[string]any{"stem": ancStem, "from_ext": ancExt, "to_ext": descExt},
+			}
+		}
+		suffix, ok := stemDerivation(ancStem, descStem)
+		if !ok {
+			continue
+		}
+		known := knownDerivedSuffix(suffix)
+		score := 0.6
+		switch {
+		case ancExt == descExt && !known:
+			// 同格式且后缀无导出标记（如 shot_1 / shot_1_2），噪音太大
+			continue
+		case ancExt == descExt:
+			score = 0.7
+		case srcToExport || known:
+			score = 0.8
+		}
+		return &lineageSignal{
+			Ancestor: anc, Descendant: desc, Rule: LineageRuleFilenameStem, Score: score,
+			Reason:   fmt.Sprintf("filename %q derived from %q", descStem, ancStem),
+			Evidence: map[string]any{"stem": ancStem, "suffix": suffix, "from_ext": ancExt, "to_ext": descExt},
+		}
+	}
+	return nil
+}
+
+func detectExportFolderSignal(a *models.Asset, b *models.Asset) *lineageSignal {
+	for _, pair := range [][2]*models.Asset{{a, b}, {b, a}} {
+		anc, desc := pair[0], pair[1]
+		descDir := filepath.Dir(desc.Path)
+		folder := strings.ToLower(filepath.Base(descDir))
+		if !lineageExportDirs[folder] || lineageExportDirs[strings.ToLower(filepath.Base(filepath.Dir(anc.Path)))] {
+			continue
+		}
+		ancDir := filepath.Dir(anc.Path)
+		// export/ 位于源文件目录下，或与源文件目录同级
+		if filepath.Dir(descDir) != ancDir && filepath.Dir(descDir) != filepath.Dir(ancDir) {
+			continue
+		}
+		ancStem, descStem := lineageStem(anc.Path), lineageStem(desc.Path)
+		_, derived := stemDerivation(ancStem, descStem)
+		evidence := map[string]any{"export_dir": descDir, "source_dir": ancDir}
+		switch {
+		case ancStem == descStem || derived:
+			return &lineageSignal{
+				Ancestor: anc, Descendant: desc, Rule: LineageRuleExportFolder, Score: 0.85,
+				Reason:   fmt.Sprintf("exported into %s/ with matching name", folder),
+				Evidence: evidence,
+			}
+		case lineageSourceExts[lineageExt(anc.Path)]:
+			dt := desc.Mtime - anc.Mtime
+			if dt < 0 || dt > lineageExportFolderDelay {
+				continue
+			}
+			evidence["mtime_delta_sec"] = dt
+			return &lineageSignal{
+				Ancestor: anc, Descendant: desc, Rule: LineageRuleExportFolder, Score: 0.5,
+				Reason:   fmt.Sprintf("exported into %s/ shortly after source was saved", folder),
+				Evidence: evidence,
+			}
+		}
+	}
+	return nil
+}
+
+// detectTemporalSignal 同一项目内，源文件保存后不久出现的导出文件
+func detectTemporalSignal(a *models.Asset, b *models.Asset) *lineageSignal {
+	for _, pair := range [][2]*models.Asset{{a, b}, {b, a}} {
+		anc, desc := pair[0], pair[1]
+		if !lineageSourceExts[lineageExt(anc.Path)] || !lineageExportExts[lineageExt(desc.Path)] {
+			continue
+		}
+		dt := desc.Mtime - anc.Mtime
+		if anc.Mtime <= 0 || dt < 0 || dt > lineageTemporalWindow {
+			continue
+		}
+		score := 0.3 + 0.3*(1-float64(dt)/lineageTemporalWindow)
+		return &lineageSignal{
+			Ancestor: anc, Descendant: desc, Rule: LineageRuleTemporalNear,
+			Score:    math.Round(score*100) / 100,
+			Reason:   fmt.Sprintf("exported %ds after source within project", dt),
+			Evidence: map[string]any{"mtime_delta_sec": dt},
+		}
+	}
+	return nil
+}
+
+type lineageNeighbor struct {
+	asset     models.Asset
+	projectID string // 来自项目邻域时非空
+}
+
+// lineageNeighbors 收集候选邻域：同目录（含子目录）、上级目录（export/ 约定）和所属项目内的最近资产
+func (s *AssetService) lineageNeighbors(ctx context.Context, asset *models.Asset, projectIDs []string) ([]lineageNeighbor, error) {
+	seen := map[string]int{asset.ID: -1}
+	var out []lineageNeighbor
+	add := func(list []models.Asset, projectID string) {
+		for _, n := range list {
+			if n.Status == "IGNORED" || n.Status == "MISSING" {
+				continue
+			}
+			if idx, ok := seen[n.ID]; ok {
+				if idx >= 0 && out[idx].projectID == "" {
+					out[idx].projectID = projectID
+				}
+				continue
+			}
+			seen[n.ID] = len(out)
+			out = append(out, lineageNeighbor{asset: n, projectID: projectID})
+		}
+	}
+
+	dir := filepath.Dir(asset.Path)
+	for _, d := range []string{dir, filepath.Dir(dir)} {
+		if d == "." || d == string(filepath.Separator) || d == filepath.VolumeName(d)+string(filepath.Separator) {
+			continue
+		}
+		list, err := s.assets.ListRecentByDirectory(ctx, d, asset.ID, 0, 0, lineageNeighborLimit)
+		if err != nil {
+			return nil, err
+		}
+		add(list, "")
+	}
+	if s.projectAssets != nil {
+		for _, pid := range projectIDs {
+			list, err := s.projectAssets.ListRecentAssetsByProject(ctx, pid, asset.ID, lineageNeighborLimit)
+			if err != nil {
+				return nil, err
+			}
+			add(list, pid)
+		}
+	}
+	return out, nil
+}
+
+// BuildLineageCandidates 以资产为中心检测血缘候选（dHash / 文件名 / 导出目录 / 时间邻近），写入 pending 候选
+func (s *AssetService) BuildLineageCandidates(ctx context.Context, assetID string) error {
+	if s.lineageCandidates == nil || assetID == "" {
+		return nil
+	}
+	asset, err := s.assets.GetByID(ctx, assetID)
+	if err != nil {
+		return err
+	}
+	if asset == nil || asset.Status == "IGNORED" || asset.Status == "MISSING" {
+		return nil
+	}
+
+	var projectIDs []string
+	if s.projectAssets != nil {
+		projectIDs, err = s.projectAssets.ListProjectIDsByAsset(ctx, asset.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if asset.ProjectID != nil && *asset.ProjectID != "" && !containsString(projectIDs, *asset.ProjectID) {
+		projectIDs = append(projectIDs, *asset.ProjectID)
+	}
+	neighbors, err := s.lineageNeighbors(ctx, asset, projectIDs)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	written := 0
+	for i := range neighbors {
+		n := &neighbors[i]
+		signals := []*lineageSignal{
+			detectVisualHashSignal(asset, &n.asset),
+			detectFilenameStemSignal(asset, &n.asset),
+			detectExportFolderSignal(asset, &n.asset),
+		}
+		if n.projectID != "" {
+			signals = append(signals, detectTemporalSignal(asset, &n.asset))
+		}
+
+		linked := false
+		checked := false
+		for _, sig := range signals {
+			if sig == nil {
+				continue
+			}
+			if !checked {
+				checked = true
+				if linked, err = s.lineage.ExistsBetween(ctx, asset.ID, n.asset.ID); err != nil {
+					return err
+				}
+			}
+			if linked {
+				break
+			}
+			var projectID *string
+			if n.projectID != "" {
+				projectID = &n.projectID
+			} else if len(projectIDs) > 0 {
+				projectID = &projectIDs[0]
+			}
+			evidenceJSON := "{}"
+			if b, err := json.Marshal(sig.Evidence); err == nil {
+				evidenceJSON = string(b)
+			}
+			ok, err := s.lineageCandidates.Upsert(ctx, &models.LineageCandidate{
+				ID:           utils.NewID(),
+				AncestorID:   sig.Ancestor.ID,
+				DescendantID: sig.Descendant.ID,
+				ProjectID:    projectID,
+				RuleType:     sig.Rule,
+				Score:        math.Round(sig.Score*1000) / 1000,
+				Confidence:   lineageConfidence(sig.Score),
+				Status:       models.LineageCandidatePending,
+				Reason:       sig.Reason,
+				EvidenceJSON: evidenceJSON,
+				CreatedAt:    now,
+				UpdatedAt:    now,
+			})
+			if err != nil {
+				return err
+			}
+			if ok {
+				written++
+			}
+		}
+	}
+
+	if written > 0 {
+		logger.Info("Lineage candidates detected", zap.String("asset_id", asset.ID), zap.Int("count", written))
+		if s.eventHub != nil {
+			s.eventHub.Broadcast(map[string]any{
+				"type": "lineage_candidates_updated",
+				"data": map[string]any{
+					"asset_id": asset.ID,
+					"count":    written,
+				},
+			})
+		}
+	}
+	return nil
+}
+
+func containsString(list []string, v string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// ListLineageCandidates 列出血缘候选；status 默认 pending，传 all 不过滤
+func (s *AssetService) ListLineageCandidates(ctx context.Context, assetID string, projectID string, status string, limit int) ([]models.LineageCandidate, error) {
+	if assetID == "" && projectID == "" {
+		return nil, errors.New("assetID or projectID is required")
+	}
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch status {
+	case "":
+		status = models.LineageCandidatePending
+	case "all":
+		status = ""
+	case models.LineageCandidatePending, models.LineageCandidateConfirmed, models.LineageCandidateRejected:
+	default:
+		return nil, errors.New("invalid status")
+	}
+	out, err := s.lineageCandidates.List(ctx, assetID, projectID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Evidence = decodeRawJSON(out[i].EvidenceJSON, "{}")
+	}
+	return out, nil
+}
+
+type LineageCandidateConfirmation struct {
+	Candidate *models.LineageCandidate `json:"candidate"`
+	Lineage   *models.AssetLineage     `json:"lineage"`
+}
+
+// ConfirmLineageCandidate 确认候选并写入 asset_lineage；同一资产对的其他规则候选一并确认，反向候选置为拒绝
+func (s *AssetService) ConfirmLineageCandidate(ctx context.Context, candidateID string) (*LineageCandidateConfirmation, error) {
+	if candidateID == "" {
+		return nil, errors.New("candidateID is required")
+	}
+	c, err := s.lineageCandidates.Get(ctx, candidateID)
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, errors.New("lineage candidate not found")
+	}
+	if c.Status == models.LineageCandidateRejected {
+		return nil, errors.New("lineage candidate already rejected")
+	}
+
+	lineage, err := s.CreateLineage(ctx, c.AncestorID, c.DescendantID, lineageCandidateRelation)
+	if err != nil {
+		return nil, err
+	}
+	if c.Status != models.LineageCandidateConfirmed {
+		c.Status = models.LineageCandidateConfirmed
+		c.UpdatedAt = time.Now().Unix()
+		if err := s.lineageCandidates.Update(ctx, c); err != nil {
+			return nil, err
+		}
+		if err := s.lineageCandidates.UpdatePairStatus(ctx, c.AncestorID, c.DescendantID, models.LineageCandidateConfirmed); err != nil {
+			return nil, err
+		}
+		if err := s.lineageCandidates.UpdatePairStatus(ctx, c.DescendantID, c.AncestorID, models.LineageCandidateRejected); err != nil {
+			return nil, err
+		}
+	}
+	c.Evidence = decodeRawJSON(c.EvidenceJSON, "{}")
+	return &LineageCandidateConfirmation{Candidate: c, Lineage: lineage}, nil
+}
+
+// RejectLineageCandidate 拒绝候选；同一资产对的其他规则候选一并拒绝，后续检测不会再次提出
+func (s *AssetService) RejectLineageCandidate(ctx context.Context, candidateID string, reason string) error {
+	if candidateID == "" {
+		return errors.New("candidateID is required")
+	}
+	c, err := s.lineageCandidates.Get(ctx, candidateID)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return errors.New("lineage candidate not found")
+	}
+	if c.Status == models.LineageCandidateConfirmed {
+		return errors.New("lineage candidate already confirmed")
+	}
+	c.Status = models.LineageCandidateRejected
+	if r := strings.TrimSpace(reason); r != "" {
+		c.Reason = r
+	}
+	c.UpdatedAt = time.Now().Unix()
+	if err := s.lineageCandidates.Update(ctx, c); err != nil {
+		return err
+	}
+	return s.lineageCandidates.UpdatePairStatus(ctx, c.AncestorID, c.DescendantID, models.LineageCandidateRejected)
+}
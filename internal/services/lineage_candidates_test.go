@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+
+	"media-assistant-os/internal/models"
+)
+
+func TestStemDerivation(t *testing.T) {
+	tests := []struct {
+		parent     string
+		child      string
+		wantSuffix string
+		wantOK     bool
+	}{
+		{"clip", "clip_v2", "v2", true},
+		{"clip", "clip_final", "final", true},
+		{"clip", "clip (1)", "(1)", true},
+		{"clip", "clip-edit_", "edit", true},
+		{"clip", "clip.web", "web", true},
+		{"clip", "clip_v2_final", "v2_final", true},
+		{"clip", "clip", "", false},
+		{"clip", "clip_", "", false},
+		{"clip", "clips", "", false},
+		{"clip", "clip2", "", false},
+		{"clip", "myclip_v2", "", false},
+		{"ab", "ab_v2", "", false},
+		{"海报", "海报_定稿", "", false},
+		{"海报图", "海报图_定稿", "定稿", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.parent+"→"+tc.child, func(t *testing.T) {
+			suffix, ok := stemDerivation(tc.parent, tc.child)
+			if suffix != tc.wantSuffix || ok != tc.wantOK {
+				t.Fatalf("stemDerivation(%q, %q) = %q, %v; want %q, %v", tc.parent, tc.child, suffix, ok, tc.wantSuffix, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestKnownDerivedSuffix(t *testing.T) {
+	tests := []struct {
+		suffix string
+		want   bool
+	}{
+		{"v2", true},
+		{"v10", true},
+		{"final", true},
+		{"FINAL", false},
+		{"v2_final", true},
+		{"draft-export", true},
+		{"2x", true},
+		{"1080p", true},
+		{"定稿", true},
+		{"(1)", false},
+		{"2", false},
+		{"v", false},
+		{"finals", false},
+		{"", false},
+	}
+	for _, tc := range tests {
+		if got := knownDerivedSuffix(tc.suffix); got != tc.want {
+			t.Errorf("knownDerivedSuffix(%q) = %v, want %v", tc.suffix, got, tc.want)
+		}
+	}
+}
+
+func TestDetectFilenameStemSignal(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      string
+		wantAnc   string
+		wantScore float64
+	}{
+		{"source to export same name", "/p/clip.psd", "/p/Clip.jpg", "/p/clip.psd", 0.9},
+		{"export listed first", "/p/clip.jpg", "/p/clip.psd", "/p/clip.psd", 0.9},
+		{"same name same format", "/p/clip.jpg", "/q/clip.jpg", "", 0},
+		{"short stem", "/p/ab.psd", "/p/ab.jpg", "", 0},
+		{"versioned same format", "/p/clip.mp4", "/p/clip_v2.mp4", "/p/clip.mp4", 0.7},
+		{"final same format", "/p/clip_final.mp4", "/p/clip.mp4", "/p/clip.mp4", 0.7},
+		{"copy suffix same format", "/p/clip.mp4", "/p/clip (1).mp4", "", 0},
+		{"copy suffix to export", "/p/clip.psd", "/p/clip (1).jpg", "/p/clip.psd", 0.8},
+		{"known suffix across formats", "/p/clip.png", "/p/clip_final.jpg", "/p/clip.png", 0.8},
+		{"unknown suffix across formats", "/p/clip.png", "/p/clip_alt.jpg", "/p/clip.png", 0.6},
+		{"unrelated", "/p/clip.psd", "/p/other.jpg", "", 0},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &models.Asset{ID: "a", Path: tc.a}
+			b := &models.Asset{ID: "b", Path: tc.b}
+			sig := detectFilenameStemSignal(a, b)
+			if tc.wantAnc == "" {
+				if sig != nil {
+					t.Fatalf("unexpected signal %s → %s (%v)", sig.Ancestor.Path, sig.Descendant.Path, sig.Score)
+				}
+				return
+			}
+			if sig == nil {
+				t.Fatal("expected a signal")
+			}
+			if sig.Ancestor.Path != tc.wantAnc || sig.Score != tc.wantScore || sig.Rule != LineageRuleFilenameStem {
+				t.Fatalf("signal %s → %s score=%v, want ancestor %s score=%v", sig.Ancestor.Path, sig.Descendant.Path, sig.Score, tc.wantAnc, tc.wantScore)
+			}
+		})
+	}
+}
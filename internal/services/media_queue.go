@@ -3,15 +3,14 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
 	"strings"
 	"sync"
 	"time"
 
-	"media-assistant-os/internal/infra"
 	"media-assistant-os/internal/models"
 	"media-assistant-os/internal/pkg/logger"
 	"media-assistant-os/internal/processor"
@@ -22,10 +21,12 @@ import (
 type MediaQueue struct {
 	assetService *AssetService
 	taskService  *TaskService
+	thumbnails   *ThumbnailService
 	eventHub     *EventHub
 	taskHandlers map[string]taskHandler
 	taskTypes    []string
 	taskTypesMu  sync.RWMutex
+	heavyGate    func() bool
 	stopChan     chan struct{}
 	wg           sync.WaitGroup
 	workerCount  int
@@ -59,6 +60,46 @@ func (q *MediaQueue) registerBuiltinHandlers() {
 	q.RegisterTaskHandler("thumbnail", func(ctx context.Context, asset *models.Asset, task *models.MediaTask) (map[string]any, error) {
 		return nil, q.generateThumbnail(ctx, asset, task)
 	})
+	q.RegisterTaskHandler(storyboardTaskType, func(ctx context.Context, asset *models.Asset, task *models.MediaTask) (map[string]any, error) {
+		return nil, q.generateStoryboard(ctx, asset, task)
+	})
+	q.RegisterTaskHandler(waveformTaskType, func(ctx context.Context, asset *models.Asset, task *models.MediaTask) (map[string]any, error) {
+		return nil, q.generateWaveform(ctx, asset, task)
+	})
+}
+
+// heavyTaskTypes 重负载任务类型：系统繁忙（扫描中、管线积压）时暂不领取
+var heavyTaskTypes = map[string]bool{
+	storyboardTaskType: true,
+	waveformTaskType:   true,
+}
+
+// SetHeavyTaskGate 设置重负载任务闸门，返回 false 时 worker 只领取轻量任务
+func (q *MediaQueue) SetHeavyTaskGate(gate func() bool) {
+	q.taskTypesMu.Lock()
+	defer q.taskTypesMu.Unlock()
+	q.heavyGate = gate
+}
+
+// claimableTaskTypes 按重负载闸门过滤可领取的任务类型
+func (q *MediaQueue) claimableTaskTypes(taskTypes []string) []string {
+	q.taskTypesMu.RLock()
+	gate := q.heavyGate
+	q.taskTypesMu.RUnlock()
+	if gate == nil || gate() {
+		return taskTypes
+	}
+	out := make([]string, 0, len(taskTypes))
+	for _, t := range taskTypes {
+		if !heavyTaskTypes[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (q *MediaQueue) SetThumbnailService(thumbnails *ThumbnailService) {
+	q.thumbnails = thumbnails
 }
 
 func (q *MediaQueue) RegisterTaskHandler(taskType string, handler taskHandler) {
@@ -105,80 +146,108 @@ func (q *MediaQueue) worker(id int) {
 	defer q.wg.Done()
 	logger.Info("Media worker started", zap.Int("worker_id", id))
 
-	// Initial check
-	if q.processNextTask(id) {
-		// If we found a task immediately, keep going
-	}
-
-	ticker := time.NewTicker(q.pollInterval)
-	defer ticker.Stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-q.stopChan
+		cancel()
+	}()
 
+	claimID := fmt.Sprintf("internal_worker_%d", id)
+	registered := ""
 	for {
 		select {
-		case <-ticker.C:
-			// Try to process as many as possible
-			for {
-				if !q.processNextTask(id) {
-					break
-				}
-				// Check for stop signal between tasks to be responsive
-				select {
-				case <-q.stopChan:
-					return
-				default:
-				}
-			}
 		case <-q.stopChan:
 			return
+		default:
 		}
-	}
-}
 
-func (q *MediaQueue) processNextTask(workerID int) bool {
-	ctx := context.Background()
+		taskTypes := q.SupportedTaskTypes()
+		if len(taskTypes) == 0 {
+			select {
+			case <-time.After(q.pollInterval):
+				continue
+			case <-q.stopChan:
+				return
+			}
+		}
 
-	// 1. Try to claim a task
-	taskTypes := q.SupportedTaskTypes()
-	if len(taskTypes) == 0 {
-		return false
-	}
-	task, err := q.taskService.ListPendingTasks(ctx, taskTypes)
-	if err != nil || task == nil {
-		return false
-	}
+		// 处理器集合变化（插件注册内部处理器）时同步 worker 注册信息
+		if key := strings.Join(taskTypes, ","); key != registered {
+			if _, err := q.taskService.RegisterWorker(ctx, RegisterWorkerRequest{
+				WorkerID:       claimID,
+				Name:           claimID,
+				Kind:           "internal",
+				TaskTypes:      taskTypes,
+				MaxConcurrency: 1,
+			}); err != nil {
+				logger.Warn("Register internal worker failed", zap.String("worker", claimID), zap.Error(err))
+			} else {
+				registered = key
+			}
+		}
 
-	claimID := fmt.Sprintf("internal_worker_%d", workerID)
-	success, err := q.taskService.ClaimTask(ctx, task.ID, claimID)
-	if err != nil {
-		// DB error, maybe backoff
-		return false
-	}
-	if !success {
-		// Task was claimed by someone else, but there are pending tasks.
-		// Return true to try fetching the next one immediately.
-		return true
+		// 长轮询领取：有新任务入队时立即唤醒，不再按固定间隔轮询
+		tasks, err := q.taskService.ClaimNextTasks(ctx, ClaimNextRequest{
+			WorkerID:  claimID,
+			TaskTypes: q.claimableTaskTypes(taskTypes),
+			Lease:     defaultTaskLease,
+			Max:       1,
+			Wait:      30 * time.Second,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			logger.Warn("Task claim failed", zap.String("worker", claimID), zap.Error(err))
+			select {
+			case <-time.After(q.pollInterval):
+			case <-q.stopChan:
+				return
+			}
+			continue
+		}
+		for i := range tasks {
+			q.runTask(context.Background(), claimID, &tasks[i])
+		}
 	}
+}
 
+// runTask 执行已领取的任务并上报结果
+func (q *MediaQueue) runTask(ctx context.Context, claimID string, task *models.MediaTask) {
 	logger.Debug("Task claimed", zap.String("task_id", task.ID), zap.String("worker", claimID))
 
-	// 2. Fetch asset info
+	// 1. Fetch asset info
 	asset, err := q.assetService.GetAssetCached(ctx, task.AssetID)
 	if err != nil || asset == nil {
 		_ = q.taskService.ReportTaskProgress(ctx, task.ID, claimID, false, "asset not found", nil)
-		return true
+		return
+	}
+	// 源文件所在卷离线：推迟而不是失败，避免耗尽重试进入死信
+	if q.assetService.volumes.IsOffline(asset.Path) {
+		_ = q.taskService.PostponeTask(ctx, task.ID, claimID, volumeOfflineTaskDelay, "volume offline")
+		return
 	}
 
-	// 3. Process based on type
+	// 2. Process based on type
 	q.taskTypesMu.RLock()
 	handler, ok := q.taskHandlers[task.TaskType]
 	q.taskTypesMu.RUnlock()
 	if !ok {
 		_ = q.taskService.ReportTaskProgress(ctx, task.ID, claimID, false, "unsupported task type for internal worker: "+task.TaskType, nil)
-		return true
+		return
+	}
+	// 取消任务时中断处理；登记后再查一次标记，覆盖领取与登记之间到达的取消请求
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	untrack := q.taskService.trackRunning(task.ID, cancel)
+	defer untrack()
+	if q.taskService.isCancelRequested(ctx, task.ID) {
+		cancel()
 	}
-	resultData, processErr := handler(ctx, asset, task)
+	resultData, processErr := handler(runCtx, asset, task)
 
-	// 4. Report results
+	// 3. Report results
 	if processErr != nil {
 		logger.Error("Task processing failed",
 			zap.String("task_id", task.ID),
@@ -191,8 +260,6 @@ func (q *MediaQueue) processNextTask(workerID int) bool {
 			zap.String("task_type", task.TaskType))
 		_ = q.taskService.ReportTaskProgress(ctx, task.ID, claimID, true, "", resultData)
 	}
-
-	return true
 }
 
 func (q *MediaQueue) processFingerprint(ctx context.Context, asset *models.Asset, task *models.MediaTask) error {
@@ -274,6 +341,11 @@ func (q *MediaQueue) extractMetadata(ctx context.Context, asset *models.Asset, t
 		_ = q.assetService.ApplyDerivedMetadata(ctx, asset.ID, string(b), shape, &suggestedRating)
 		asset.MediaMeta = string(b)
 		_ = q.assetService.BuildLineageCandidates(ctx, asset.ID)
+		if detectAssetFileType(asset.Path) == "audio" {
+			if _, err := q.taskService.EnqueueTask(ctx, asset.ID, waveformTaskType, waveformTaskPriority, false); err != nil {
+				logger.Warn("Enqueue waveform task failed", zap.String("asset_id", asset.ID), zap.Error(err))
+			}
+		}
 		return map[string]any{
 			"metadata": string(b),
 		}, nil
@@ -336,7 +408,8 @@ func deriveSuggestedRating(meta *processor.Metadata) int {
 		} else if shortSide < 480 {
 			score--
 		}
-	} else {
+	} else if meta.Duration <= 0 {
+		// 音频没有画面尺寸，只有时长也取不到时才视为解析不完整
 		score--
 	}
 
@@ -363,53 +436,87 @@ func deriveSuggestedRating(meta *processor.Metadata) int {
 }
 
 func (q *MediaQueue) generateThumbnail(ctx context.Context, asset *models.Asset, task *models.MediaTask) error {
+	if q.thumbnails == nil {
+		return errors.New("thumbnail service not configured")
+	}
 	_ = q.taskService.ReportProgress(ctx, task.ID, 10)
 
-	ext := filepath.Ext(asset.Path)
-	res, err := processor.GetManager().Process(ctx, asset.Path, ext)
-	if err != nil {
-		logger.Warn("Thumbnail parser failed, skip thumbnail generation",
-			zap.String("asset_id", asset.ID),
-			zap.String("path", asset.Path),
-			zap.Error(err))
-		return nil
-	}
-	if res != nil && res.Error != nil {
-		logger.Warn("Thumbnail parse returned parser error, skip thumbnail generation",
-			zap.String("asset_id", asset.ID),
-			zap.String("path", asset.Path),
-			zap.Error(res.Error))
-		return nil
-	}
-	if res == nil || len(res.Thumbnail) == 0 {
-		// No real thumbnail generated for this format/toolchain.
-		// Frontend should fall back to extension/category icon rules.
-		return nil
+	// 任务依赖 fingerprint，缓存中的资产可能还没有指纹，取最新记录
+	if asset.Fingerprint == nil {
+		fresh, err := q.assetService.assets.GetByID(ctx, asset.ID)
+		if err != nil {
+			return err
+		}
+		if fresh != nil {
+			asset = fresh
+		}
 	}
-
-	dataDir, err := infra.ResolveDataDir()
+	res, err := q.thumbnails.Generate(ctx, asset, false)
 	if err != nil {
 		return err
 	}
+	_ = q.taskService.ReportProgress(ctx, task.ID, 90)
 
-	thumbDir := filepath.Join(dataDir, "cache", "thumbnails")
-	if err := os.MkdirAll(thumbDir, 0o755); err != nil {
-		return err
+	// thumbnail_path 指向 grid 规格，兼容直接读取 media_meta 的前端
+	for _, r := range res.Renditions {
+		if r.Rendition != defaultThumbnailSpec {
+			continue
+		}
+		updatedMeta, err := mergeThumbnailMeta(asset.MediaMeta, filepath.Join(q.thumbnails.dataDir, r.RelPath))
+		if err != nil {
+			return err
+		}
+		if updatedMeta != "" {
+			_ = q.assetService.UpdateMediaMeta(ctx, asset.ID, updatedMeta)
+		}
 	}
 
-	thumbPath := filepath.Join(thumbDir, asset.ID+".jpg")
-	if err := os.WriteFile(thumbPath, res.Thumbnail, 0o644); err != nil {
-		return err
+	if detectAssetFileType(asset.Path) == "video" {
+		if _, err := q.taskService.EnqueueTask(ctx, asset.ID, storyboardTaskType, storyboardTaskPriority, false); err != nil {
+			logger.Warn("Enqueue storyboard task failed", zap.String("asset_id", asset.ID), zap.Error(err))
+		}
 	}
 
-	updatedMeta, err := mergeThumbnailMeta(asset.MediaMeta, thumbPath)
-	if err != nil {
-		return err
+	_ = q.taskService.ReportProgress(ctx, task.ID, 100)
+	return nil
+}
+
+func (q *MediaQueue) generateStoryboard(ctx context.Context, asset *models.Asset, task *models.MediaTask) error {
+	if q.thumbnails == nil {
+		return errors.New("thumbnail service not configured")
 	}
-	if updatedMeta != "" {
-		_ = q.assetService.UpdateMediaMeta(ctx, asset.ID, updatedMeta)
+	if asset.Fingerprint == nil {
+		fresh, err := q.assetService.assets.GetByID(ctx, asset.ID)
+		if err != nil {
+			return err
+		}
+		if fresh != nil {
+			asset = fresh
+		}
 	}
+	_, err := q.thumbnails.GenerateStoryboard(ctx, asset, func(p int) {
+		_ = q.taskService.ReportProgress(ctx, task.ID, p)
+	})
+	return err
+}
 
+func (q *MediaQueue) generateWaveform(ctx context.Context, asset *models.Asset, task *models.MediaTask) error {
+	if q.thumbnails == nil {
+		return errors.New("thumbnail service not configured")
+	}
+	_ = q.taskService.ReportProgress(ctx, task.ID, 10)
+	if asset.Fingerprint == nil {
+		fresh, err := q.assetService.assets.GetByID(ctx, asset.ID)
+		if err != nil {
+			return err
+		}
+		if fresh != nil {
+			asset = fresh
+		}
+	}
+	if _, err := q.thumbnails.GenerateWaveform(ctx, asset); err != nil {
+		return err
+	}
 	_ = q.taskService.ReportProgress(ctx, task.ID, 100)
 	return nil
 }
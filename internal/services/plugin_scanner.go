@@ -218,5 +218,6 @@ func (s *PluginScanner) PrintDiscoveryReport(manifests []PluginManifest) {
 		fmt.Println()
 	}
 
-	fmt.Println("=== 报告结束 ===\n")
+	fmt.Println("=== 报告结束 ===")
+	fmt.Println()
 }
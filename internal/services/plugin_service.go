@@ -11,9 +11,12 @@ import (
 	"time"
 
 	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
 	"media-assistant-os/internal/processor"
 	"media-assistant-os/internal/repos"
 	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
 )
 
 type registeredPlugin struct {
@@ -27,6 +30,8 @@ type PluginService struct {
 	tokenIndex  map[string]string // token -> pluginID
 	tokenTTL    time.Duration
 	onlineTTL   time.Duration
+	taskGraph   *TaskGraph
+	results     *TaskResultContracts
 	mu          sync.Mutex
 }
 
@@ -42,6 +47,30 @@ func NewPluginService(runtimeRepo *repos.PluginRuntimeRepo) *PluginService {
 	}
 }
 
+// SetTaskGraph 设置任务依赖图，插件注册时以声明的 task_dependencies 替换该插件先前的依赖（需在 Restore 前调用）
+func (s *PluginService) SetTaskGraph(g *TaskGraph) {
+	s.taskGraph = g
+}
+
+// SetTaskResultContracts 设置结果约定登记表，插件注册时声明的 result_contracts 会写入（需在 Restore 前调用）
+func (s *PluginService) SetTaskResultContracts(r *TaskResultContracts) {
+	s.results = r
+}
+
+// pluginTaskTypes 插件声明的全部任务类型（含 capabilities）
+func pluginTaskTypes(taskTypes []string, caps []PluginCapability) map[string]bool {
+	out := map[string]bool{}
+	for _, t := range taskTypes {
+		out[strings.TrimSpace(t)] = true
+	}
+	for _, c := range caps {
+		for _, t := range c.TaskTypes {
+			out[strings.TrimSpace(t)] = true
+		}
+	}
+	return out
+}
+
 // Restore rebuilds in-memory token and plugin indices from persisted runtime rows.
 func (s *PluginService) Restore(ctx context.Context) error {
 	if s.runtimeRepo == nil {
@@ -67,6 +96,16 @@ func (s *PluginService) Restore(ctx context.Context) error {
 			continue
 		}
 		plugin.Online = now.Sub(plugin.LastUsedAt) <= s.onlineTTL
+		if s.taskGraph != nil {
+			if err := s.taskGraph.Register(plugin.PluginID, plugin.TaskDependencies); err != nil {
+				logger.Warn("Ignoring plugin task dependencies", zap.String("plugin_id", plugin.PluginID), zap.Error(err))
+			}
+		}
+		if s.results != nil {
+			if err := s.results.Register(plugin.PluginID, plugin.ResultContracts); err != nil {
+				logger.Warn("Ignoring plugin result contracts", zap.String("plugin_id", plugin.PluginID), zap.Error(err))
+			}
+		}
 		nextPlugins[plugin.PluginID] = registeredPlugin{
 			PluginInfo: plugin,
 			Token:      row.Token,
@@ -137,10 +176,38 @@ func (s *PluginService) Register(ctx context.Context, req PluginRegistrationRequ
 	if req.Mode != PluginModeFrontend && len(req.TaskTypes) == 0 && len(req.Capabilities) == 0 {
 		return nil, errors.New("task_types or capabilities is required")
 	}
+	if len(req.TaskDependencies) > 0 {
+		own := pluginTaskTypes(req.TaskTypes, req.Capabilities)
+		for taskType := range req.TaskDependencies {
+			if !own[strings.TrimSpace(taskType)] {
+				return nil, fmt.Errorf("task_dependencies: %s is not a task type of this plugin", taskType)
+			}
+		}
+	}
+	if len(req.ResultContracts) > 0 {
+		own := pluginTaskTypes(req.TaskTypes, req.Capabilities)
+		for taskType := range req.ResultContracts {
+			if !own[strings.TrimSpace(taskType)] {
+				return nil, fmt.Errorf("result_contracts: %s is not a task type of this plugin", taskType)
+			}
+		}
+	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	// 依赖声明与结果约定在持久化成功后才生效；注册在 s.mu 内串行，校验通过后登记不会再冲突
+	if s.taskGraph != nil {
+		if err := s.taskGraph.Validate(req.PluginID, req.TaskDependencies); err != nil {
+			return nil, err
+		}
+	}
+	if s.results != nil {
+		if err := s.results.Validate(req.PluginID, req.ResultContracts); err != nil {
+			return nil, err
+		}
+	}
+
 	// local_process + extensions => register as parser directly in process manager
 	if req.Mode == PluginModeLocalProcess && req.Executable != "" && len(req.Extensions) > 0 {
 		extParser := processor.NewExternalCommandParser(req.PluginID, req.Executable, req.Extensions)
@@ -161,32 +228,44 @@ func (s *PluginService) Register(ctx context.Context, req PluginRegistrationRequ
 	registered := registeredPlugin{
 		Token: res.Token,
 		PluginInfo: PluginInfo{
-			PluginID:        req.PluginID,
-			ID:              req.PluginID,
-			Name:            req.Name,
-			Version:         req.Version,
-			Description:     req.Description,
-			Mode:            req.Mode,
-			Endpoint:        req.Endpoint,
-			Executable:      req.Executable,
-			Extensions:      req.Extensions,
-			TaskTypes:       req.TaskTypes,
-			Capabilities:    req.Capabilities,
-			ProtocolVersion: req.ProtocolVersion,
-			Permissions:     req.Permissions,
-			UI:              clonePluginUI(req.UI),
-			Mounts:          req.Mounts,
-			IssuedAt:        now,
-			LastUsedAt:      now,
-			ExpiresAt:       now.Add(s.tokenTTL),
-			Online:          true,
-			RegisteredAt:    now.Unix(),
-			LastHeartbeat:   now.Unix(),
+			PluginID:         req.PluginID,
+			ID:               req.PluginID,
+			Name:             req.Name,
+			Version:          req.Version,
+			Description:      req.Description,
+			Mode:             req.Mode,
+			Endpoint:         req.Endpoint,
+			Executable:       req.Executable,
+			Extensions:       req.Extensions,
+			TaskTypes:        req.TaskTypes,
+			Capabilities:     req.Capabilities,
+			ProtocolVersion:  req.ProtocolVersion,
+			Permissions:      req.Permissions,
+			UI:               clonePluginUI(req.UI),
+			Mounts:           req.Mounts,
+			TaskDependencies: req.TaskDependencies,
+			ResultContracts:  req.ResultContracts,
+			IssuedAt:         now,
+			LastUsedAt:       now,
+			ExpiresAt:        now.Add(s.tokenTTL),
+			Online:           true,
+			RegisteredAt:     now.Unix(),
+			LastHeartbeat:    now.Unix(),
 		},
 	}
 	if err := s.persistRegisteredPlugin(ctx, registered); err != nil {
 		return nil, err
 	}
+	if s.taskGraph != nil {
+		if err := s.taskGraph.Register(req.PluginID, req.TaskDependencies); err != nil {
+			logger.Warn("Ignoring plugin task dependencies", zap.String("plugin_id", req.PluginID), zap.Error(err))
+		}
+	}
+	if s.results != nil {
+		if err := s.results.Register(req.PluginID, req.ResultContracts); err != nil {
+			logger.Warn("Ignoring plugin result contracts", zap.String("plugin_id", req.PluginID), zap.Error(err))
+		}
+	}
 	s.plugins[req.PluginID] = registered
 	s.tokenIndex[res.Token] = req.PluginID
 	return &res, nil
@@ -51,6 +51,10 @@ type PluginRegistrationRequest struct {
 	Permissions     []string           `json:"permissions,omitempty"`
 	UI              *PluginUIConfig    `json:"ui,omitempty"`
 	Mounts          []PluginMount      `json:"mounts,omitempty"`
+	// TaskDependencies 插件任务类型的前置任务类型，如 {"ai_tags": ["thumbnail"]}
+	TaskDependencies map[string][]string `json:"task_dependencies,omitempty"`
+	// ResultContracts 插件任务类型的结果 schema 与写入映射，如 {"ai_tags": {"schema": {...}, "mappings": [{"source": "tags[]", "target": "asset_tags"}]}}
+	ResultContracts map[string]PluginResultContract `json:"result_contracts,omitempty"`
 }
 
 type PluginRegistrationResponse struct {
@@ -80,12 +84,16 @@ type PluginInfo struct {
 	Permissions     []string           `json:"permissions,omitempty"`
 	UI              *PluginUIConfig    `json:"ui,omitempty"`
 	Mounts          []PluginMount      `json:"mounts,omitempty"`
-	IssuedAt        time.Time          `json:"issued_at"`
-	LastUsedAt      time.Time          `json:"last_used_at"`
-	ExpiresAt       time.Time          `json:"expires_at"`
-	Online          bool               `json:"online"`
-	RegisteredAt    int64              `json:"registered_at"`  // Frontend compatibility.
-	LastHeartbeat   int64              `json:"last_heartbeat"` // Frontend compatibility.
+	// TaskDependencies 插件声明的任务依赖
+	TaskDependencies map[string][]string `json:"task_dependencies,omitempty"`
+	// ResultContracts 插件声明的结果约定
+	ResultContracts map[string]PluginResultContract `json:"result_contracts,omitempty"`
+	IssuedAt        time.Time                       `json:"issued_at"`
+	LastUsedAt      time.Time                       `json:"last_used_at"`
+	ExpiresAt       time.Time                       `json:"expires_at"`
+	Online          bool                            `json:"online"`
+	RegisteredAt    int64                           `json:"registered_at"`  // Frontend compatibility.
+	LastHeartbeat   int64                           `json:"last_heartbeat"` // Frontend compatibility.
 }
 
 type PluginMountedSlot struct {
@@ -24,6 +24,7 @@ type ProjectService struct {
 	activityRepo         *repos.ActivityRepo
 	scanService          *ScanService
 	licenseService       *LicenseService
+	volumes              *VolumeService
 	sourceBindWorkers    chan struct{}
 	mu                   sync.Mutex
 	HomeDir              string // For testing override
@@ -716,11 +717,17 @@ func (s *ProjectService) resolveProjectRootRefs(ctx context.Context, project mod
 	return roots, nil
 }
 
+// SetVolumeService 绑定卷状态，健康检查区分卷离线与文件丢失
+func (s *ProjectService) SetVolumeService(v *VolumeService) {
+	s.volumes = v
+}
+
 type ProjectHealthSource struct {
 	RootPath     string `json:"root_path"`
 	SourceType   string `json:"source_type"`
 	WatchEnabled bool   `json:"watch_enabled"`
 	Exists       bool   `json:"exists"`
+	Offline      bool   `json:"offline"` // 所在卷离线（未挂载），不视为丢失
 }
 
 type ProjectHealthIssue struct {
@@ -746,6 +753,8 @@ type ProjectHealthReport struct {
 	MissingEngines      int `json:"missing_engines"`
 	MissingDeliverables int `json:"missing_deliverables"`
 	MissingRoots        int `json:"missing_roots"`
+	OfflineRoots        int `json:"offline_roots"`
+	OfflineBindings     int `json:"offline_bindings"`
 
 	UnarchivedDeliverables bool `json:"unarchived_deliverables"`
 
@@ -781,13 +790,22 @@ func (s *ProjectService) GetProjectHealth(ctx context.Context, projectID string)
 		}
 		for _, src := range sources {
 			exists := pathExists(src.RootPath)
+			offline := !exists && s.volumes.IsOffline(src.RootPath)
 			report.Sources = append(report.Sources, ProjectHealthSource{
 				RootPath:     src.RootPath,
 				SourceType:   src.SourceType,
 				WatchEnabled: src.WatchEnabled,
 				Exists:       exists,
+				Offline:      offline,
 			})
-			if !exists {
+			if offline {
+				report.OfflineRoots++
+				report.Issues = append(report.Issues, ProjectHealthIssue{
+					Type:    "source_offline",
+					Path:    src.RootPath,
+					Message: "source volume is offline",
+				})
+			} else if !exists {
 				report.MissingRoots++
 				report.Issues = append(report.Issues, ProjectHealthIssue{
 					Type:    "source_root_missing",
@@ -823,6 +841,10 @@ func (s *ProjectService) GetProjectHealth(ctx context.Context, projectID string)
 		if !missing {
 			continue
 		}
+		if s.volumes.IsOffline(detail.Path) {
+			report.OfflineBindings++
+			continue
+		}
 
 		report.MissingBindings++
 		switch role {
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+// PublishDispatch 调度器交给渠道适配器的一次发布请求
+type PublishDispatch struct {
+	Job      *models.PublishJob
+	Channel  *models.PublishChannel
+	Artifact *models.ProjectArtifact // 未关联产物时为 nil
+}
+
+// PublishAdapterResult 适配器返回的平台侧结果，会写入 publish_records
+type PublishAdapterResult struct {
+	ExternalPostID string
+	PostURL        string
+	PublishedAt    int64
+	RawResponse    json.RawMessage
+}
+
+// PublishChannelAdapter 发布渠道适配器。
+// 返回 PermanentPublishError 包装的错误时不再重试，其余错误按退避策略重试。
+type PublishChannelAdapter interface {
+	Name() string
+	Publish(ctx context.Context, d PublishDispatch) (*PublishAdapterResult, error)
+}
+
+type permanentPublishError struct {
+	err error
+}
+
+func (e permanentPublishError) Error() string { return e.err.Error() }
+func (e permanentPublishError) Unwrap() error { return e.err }
+
+// PermanentPublishError 标记不可重试的发布错误（配置错误、平台拒绝等）
+func PermanentPublishError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentPublishError{err: err}
+}
+
+func isPermanentPublishError(err error) bool {
+	var p permanentPublishError
+	return errors.As(err, &p)
+}
+
+// localPublishConfig publish_channels.config_json 中 local 适配器的配置
+type localPublishConfig struct {
+	Dir        string            `json:"dir"`
+	WebhookURL string            `json:"webhook_url"`
+	Headers    map[string]string `json:"headers"`
+	CopyFile   *bool             `json:"copy_file"`
+}
+
+// LocalPublishAdapter 离线可测的发布适配器：
+// 将发布清单（及产物文件）写入本地目录，和/或以 JSON POST 到 webhook。
+type LocalPublishAdapter struct {
+	client *http.Client
+}
+
+func NewLocalPublishAdapter() *LocalPublishAdapter {
+	return &LocalPublishAdapter{client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (a *LocalPublishAdapter) Name() string {
+	return "local"
+}
+
+type localPublishManifest struct {
+	JobID      string                  `json:"job_id"`
+	ProjectID  string                  `json:"project_id"`
+	Platform   string                  `json:"platform"`
+	AccountRef string                  `json:"account_ref"`
+	Artifact   *models.ProjectArtifact `json:"artifact,omitempty"`
+	Payload    json.RawMessage         `json:"payload,omitempty"`
+	Attempt    int                     `json:"attempt"`
+	CreatedAt  int64                   `json:"created_at"`
+}
+
+func (a *LocalPublishAdapter) Publish(ctx context.Context, d PublishDispatch) (*PublishAdapterResult, error) {
+	if d.Job == nil || d.Channel == nil {
+		return nil, PermanentPublishError(errors.New("invalid dispatch"))
+	}
+	var cfg localPublishConfig
+	if strings.TrimSpace(d.Channel.ConfigJSON) != "" {
+		if err := json.Unmarshal([]byte(d.Channel.ConfigJSON), &cfg); err != nil {
+			return nil, PermanentPublishError(fmt.Errorf("invalid channel config: %w", err))
+		}
+	}
+	cfg.Dir = strings.TrimSpace(cfg.Dir)
+	cfg.WebhookURL = strings.TrimSpace(cfg.WebhookURL)
+	if cfg.Dir == "" && cfg.WebhookURL == "" {
+		return nil, PermanentPublishError(errors.New("local adapter requires config.dir or config.webhook_url"))
+	}
+
+	manifest := localPublishManifest{
+		JobID:      d.Job.ID,
+		ProjectID:  d.Job.ProjectID,
+		Platform:   d.Channel.Platform,
+		AccountRef: d.Channel.AccountRef,
+		Artifact:   d.Artifact,
+		Payload:    decodeRawJSON(d.Job.PayloadJSON, "{}"),
+		Attempt:    d.Job.AttemptCount,
+		CreatedAt:  time.Now().Unix(),
+	}
+	body, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, PermanentPublishError(err)
+	}
+
+	res := &PublishAdapterResult{
+		ExternalPostID: "local-" + d.Job.ID,
+		PublishedAt:    time.Now().Unix(),
+	}
+	if cfg.Dir != "" {
+		outDir := filepath.Join(cfg.Dir, d.Job.ID)
+		if err := a.writeFolder(outDir, body, d.Artifact, cfg.CopyFile == nil || *cfg.CopyFile); err != nil {
+			return nil, err
+		}
+		res.PostURL = "file://" + filepath.ToSlash(outDir)
+	}
+	if cfg.WebhookURL != "" {
+		if err := a.postWebhook(ctx, cfg, body, res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+func (a *LocalPublishAdapter) writeFolder(outDir string, manifest []byte, artifact *models.ProjectArtifact, copyFile bool) error {
+	if err := os.MkdirAll(outDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "manifest.json"), manifest, 0o644); err != nil {
+		return err
+	}
+	if !copyFile || artifact == nil || strings.TrimSpace(artifact.Path) == "" {
+		return nil
+	}
+	src, err := os.Open(artifact.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return PermanentPublishError(fmt.Errorf("artifact file not found: %s", artifact.Path))
+		}
+		return err
+	}
+	defer src.Close()
+	dst, err := os.Create(filepath.Join(outDir, filepath.Base(artifact.Path)))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+func (a *LocalPublishAdapter) postWebhook(ctx context.Context, cfg localPublishConfig, body []byte, res *PublishAdapterResult) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return PermanentPublishError(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("webhook returned %d", resp.StatusCode)
+		// 4xx（429 除外）视为请求本身有问题，重试无意义
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return PermanentPublishError(err)
+		}
+		return err
+	}
+
+	if len(respBody) > 0 && json.Valid(respBody) {
+		res.RawResponse = json.RawMessage(respBody)
+		var parsed struct {
+			ExternalPostID string `json:"external_post_id"`
+			PostURL        string `json:"post_url"`
+		}
+		if json.Unmarshal(respBody, &parsed) == nil {
+			if parsed.ExternalPostID != "" {
+				res.ExternalPostID = parsed.ExternalPostID
+			}
+			if parsed.PostURL != "" {
+				res.PostURL = parsed.PostURL
+			}
+		}
+	}
+	return nil
+}
+
+var _ PublishChannelAdapter = (*LocalPublishAdapter)(nil)
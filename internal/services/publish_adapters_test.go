@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"media-assistant-os/internal/models"
+)
+
+func localDispatch(config string, artifact *models.ProjectArtifact) PublishDispatch {
+	return PublishDispatch{
+		Job:      &models.PublishJob{ID: "job1", ProjectID: "p1", AttemptCount: 2, PayloadJSON: `{"title":"hello"}`},
+		Channel:  &models.PublishChannel{ID: "c1", Platform: "local", AccountRef: "acct", ConfigJSON: config},
+		Artifact: artifact,
+	}
+}
+
+func TestLocalPublishAdapter_Folder(t *testing.T) {
+	ctx := context.Background()
+	src := filepath.Join(t.TempDir(), "cut.mp4")
+	if err := os.WriteFile(src, []byte("video"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	artifact := &models.ProjectArtifact{ID: "a1", Kind: "export_bundle", Path: src}
+	a := NewLocalPublishAdapter()
+
+	t.Run("manifest and artifact", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg, _ := json.Marshal(map[string]any{"dir": dir})
+		res, err := a.Publish(ctx, localDispatch(string(cfg), artifact))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outDir := filepath.Join(dir, "job1")
+		if res.ExternalPostID != "local-job1" || res.PostURL != "file://"+filepath.ToSlash(outDir) || res.PublishedAt == 0 {
+			t.Fatalf("result = %+v", res)
+		}
+		raw, err := os.ReadFile(filepath.Join(outDir, "manifest.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var manifest localPublishManifest
+		if err := json.Unmarshal(raw, &manifest); err != nil {
+			t.Fatal(err)
+		}
+		var payload struct {
+			Title string `json:"title"`
+		}
+		_ = json.Unmarshal(manifest.Payload, &payload)
+		if manifest.JobID != "job1" || manifest.AccountRef != "acct" || manifest.Attempt != 2 || payload.Title != "hello" || manifest.Artifact == nil {
+			t.Fatalf("manifest = %s", raw)
+		}
+		if copied, err := os.ReadFile(filepath.Join(outDir, "cut.mp4")); err != nil || string(copied) != "video" {
+			t.Fatalf("copied artifact = %q, %v", copied, err)
+		}
+	})
+
+	t.Run("copy disabled", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg, _ := json.Marshal(map[string]any{"dir": dir, "copy_file": false})
+		if _, err := a.Publish(ctx, localDispatch(string(cfg), artifact)); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "job1", "cut.mp4")); !os.IsNotExist(err) {
+			t.Fatalf("artifact copied despite copy_file=false: %v", err)
+		}
+	})
+
+	t.Run("missing artifact file", func(t *testing.T) {
+		cfg, _ := json.Marshal(map[string]any{"dir": t.TempDir()})
+		missing := &models.ProjectArtifact{ID: "a2", Path: filepath.Join(t.TempDir(), "gone.mp4")}
+		if _, err := a.Publish(ctx, localDispatch(string(cfg), missing)); !isPermanentPublishError(err) {
+			t.Fatalf("error = %v, want permanent", err)
+		}
+	})
+}
+
+func TestLocalPublishAdapter_InvalidConfig(t *testing.T) {
+	a := NewLocalPublishAdapter()
+	for _, cfg := range []string{"", "{}", `{"dir":"  "}`, "{broken"} {
+		if _, err := a.Publish(context.Background(), localDispatch(cfg, nil)); !isPermanentPublishError(err) {
+			t.Errorf("config %q: error = %v, want permanent", cfg, err)
+		}
+	}
+	if _, err := a.Publish(context.Background(), PublishDispatch{}); !isPermanentPublishError(err) {
+		t.Errorf("empty dispatch: error = %v, want permanent", err)
+	}
+}
+
+func TestLocalPublishAdapter_Webhook(t *testing.T) {
+	var got struct {
+		contentType string
+		token       string
+		manifest    localPublishManifest
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.contentType = r.Header.Get("Content-Type")
+		got.token = r.Header.Get("X-Token")
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got.manifest)
+		_, _ = w.Write([]byte(`{"external_post_id":"post-42","post_url":"https://example.test/p/42"}`))
+	}))
+	defer srv.Close()
+
+	cfg, _ := json.Marshal(map[string]any{"webhook_url": srv.URL, "headers": map[string]string{"X-Token": "secret"}})
+	res, err := NewLocalPublishAdapter().Publish(context.Background(), localDispatch(string(cfg), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.contentType != "application/json" || got.token != "secret" || got.manifest.JobID != "job1" {
+		t.Fatalf("request = %+v", got)
+	}
+	if res.ExternalPostID != "post-42" || res.PostURL != "https://example.test/p/42" || !strings.Contains(string(res.RawResponse), "post-42") {
+		t.Fatalf("result = %+v", res)
+	}
+}
+
+func TestLocalPublishAdapter_WebhookStatus(t *testing.T) {
+	tests := []struct {
+		status        int
+		body          string
+		wantErr       bool
+		wantPermanent bool
+		wantPostID    string
+	}{
+		{http.StatusOK, "", false, false, "local-job1"},
+		{http.StatusAccepted, "not json", false, false, "local-job1"},
+		{http.StatusBadRequest, "", true, true, ""},
+		{http.StatusNotFound, "", true, true, ""},
+		{http.StatusTooManyRequests, "", true, false, ""},
+		{http.StatusInternalServerError, "", true, false, ""},
+		{http.StatusBadGateway, "", true, false, ""},
+	}
+	for _, tc := range tests {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			}))
+			defer srv.Close()
+			cfg, _ := json.Marshal(map[string]any{"webhook_url": srv.URL})
+			res, err := NewLocalPublishAdapter().Publish(context.Background(), localDispatch(string(cfg), nil))
+			if (err != nil) != tc.wantErr || isPermanentPublishError(err) != tc.wantPermanent {
+				t.Fatalf("error = %v, want err=%v permanent=%v", err, tc.wantErr, tc.wantPermanent)
+			}
+			if !tc.wantErr && res.ExternalPostID != tc.wantPostID {
+				t.Fatalf("external post id = %q, want %q", res.ExternalPostID, tc.wantPostID)
+			}
+		})
+	}
+
+	// 连接失败可重试
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+	cfg, _ := json.Marshal(map[string]any{"webhook_url": url})
+	if _, err := NewLocalPublishAdapter().Publish(context.Background(), localDispatch(string(cfg), nil)); err == nil || isPermanentPublishError(err) {
+		t.Fatalf("unreachable webhook error = %v, want retryable", err)
+	}
+}
@@ -0,0 +1,790 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	metricsRollupInterval  = time.Minute
+	metricsRollupLookback  = 24 * time.Hour
+	metricsIngestBatchMax  = 1000
+	metricsTrendMaxDays    = 366
+	metricsSnapshotDateFmt = "2006-01-02"
+)
+
+// 支持的指标类型；views/likes/comments/shares/favorites 为累计值，followers_delta 为增量
+const (
+	MetricViews          = "views"
+	MetricLikes          = "likes"
+	MetricComments       = "comments"
+	MetricShares         = "shares"
+	MetricFavorites      = "favorites"
+	MetricFollowersDelta = "followers_delta"
+)
+
+var errMetricsUnavailable = errors.New("metrics store is not configured")
+
+var metricTypeAliases = map[string]string{
+	MetricViews:          MetricViews,
+	"view":               MetricViews,
+	"play":               MetricViews,
+	"plays":              MetricViews,
+	MetricLikes:          MetricLikes,
+	"like":               MetricLikes,
+	MetricComments:       MetricComments,
+	"comment":            MetricComments,
+	MetricShares:         MetricShares,
+	"share":              MetricShares,
+	MetricFavorites:      MetricFavorites,
+	"favorite":           MetricFavorites,
+	"collect":            MetricFavorites,
+	"collects":           MetricFavorites,
+	MetricFollowersDelta: MetricFollowersDelta,
+	"follower_delta":     MetricFollowersDelta,
+}
+
+// SetMetricsRepo 指标点与日快照存储；未设置时指标接口返回错误、汇总循环不启动
+func (s *PublishMetricsService) SetMetricsRepo(metrics *repos.MetricsRepo) {
+	s.metrics = metrics
+}
+
+func normalizeMetricType(v string) (string, bool) {
+	t, ok := metricTypeAliases[strings.ToLower(strings.TrimSpace(v))]
+	return t, ok
+}
+
+type metricsRollupKey struct {
+	ProjectID      string
+	Platform       string
+	ExternalPostID string
+	Date           string
+}
+
+func metricsDate(unix int64) string {
+	return time.Unix(unix, 0).Format(metricsSnapshotDateFmt)
+}
+
+func parseMetricsDate(v string, field string) (time.Time, error) {
+	t, err := time.ParseInLocation(metricsSnapshotDateFmt, strings.TrimSpace(v), time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be YYYY-MM-DD", field)
+	}
+	return t, nil
+}
+
+// ---- Ingest ----
+
+type MetricsEventInput struct {
+	ProjectID      string          `json:"project_id"`
+	RecordID       string          `json:"record_id"`
+	Platform       string          `json:"platform"`
+	AccountRef     string          `json:"account_ref"`
+	ExternalPostID string          `json:"external_post_id"`
+	MetricType     string          `json:"metric_type"`
+	MetricValue    float64         `json:"metric_value"`
+	OccurredAt     int64           `json:"occurred_at"`
+	Source         string          `json:"source"`
+	Meta           json.RawMessage `json:"meta"`
+}
+
+// IngestMetricsRequest 支持单条（平铺字段）或批量（events）提交
+type IngestMetricsRequest struct {
+	MetricsEventInput
+	Events []MetricsEventInput `json:"events"`
+}
+
+type IngestMetricsResult struct {
+	Accepted   int `json:"accepted"`
+	Duplicates int `json:"duplicates"`
+}
+
+// IngestMetricsEvents 校验并写入指标点，按 (帖子, 指标, 时间) 去重，写入后标记对应日快照待汇总。
+// 批量中任意一条不合法则整体拒绝。
+func (s *PublishMetricsService) IngestMetricsEvents(ctx context.Context, req IngestMetricsRequest) (*IngestMetricsResult, error) {
+	if s.metrics == nil {
+		return nil, errMetricsUnavailable
+	}
+	inputs := req.Events
+	if len(inputs) == 0 {
+		inputs = []MetricsEventInput{req.MetricsEventInput}
+	}
+	if len(inputs) > metricsIngestBatchMax {
+		return nil, fmt.Errorf("too many events (max %d)", metricsIngestBatchMax)
+	}
+
+	now := time.Now().Unix()
+	events := make([]*models.MetricsEvent, 0, len(inputs))
+	checkedProjects := make(map[string]bool)
+	for i, in := range inputs {
+		e, err := s.buildMetricsEvent(ctx, in, now)
+		if err != nil {
+			if len(req.Events) > 0 {
+				return nil, fmt.Errorf("events[%d]: %w", i, err)
+			}
+			return nil, err
+		}
+		if !checkedProjects[e.ProjectID] {
+			if err := s.ensureProject(ctx, e.ProjectID); err != nil {
+				return nil, fmt.Errorf("events[%d]: %w", i, err)
+			}
+			checkedProjects[e.ProjectID] = true
+		}
+		events = append(events, e)
+	}
+
+	res := &IngestMetricsResult{}
+	for _, e := range events {
+		inserted, err := s.metrics.InsertEvent(ctx, e)
+		if err != nil {
+			return nil, err
+		}
+		if !inserted {
+			res.Duplicates++
+			continue
+		}
+		res.Accepted++
+		s.markRollupDirty(metricsRollupKey{
+			ProjectID:      e.ProjectID,
+			Platform:       e.Platform,
+			ExternalPostID: e.ExternalPostID,
+			Date:           metricsDate(e.OccurredAt),
+		})
+	}
+	return res, nil
+}
+
+func (s *PublishMetricsService) buildMetricsEvent(ctx context.Context, in MetricsEventInput, now int64) (*models.MetricsEvent, error) {
+	metricType, ok := normalizeMetricType(in.MetricType)
+	if !ok {
+		return nil, fmt.Errorf("invalid metric_type %q", in.MetricType)
+	}
+	if math.IsNaN(in.MetricValue) || math.IsInf(in.MetricValue, 0) {
+		return nil, errors.New("metric_value must be a finite number")
+	}
+	if metricType != MetricFollowersDelta && in.MetricValue < 0 {
+		return nil, errors.New("metric_value must be >= 0")
+	}
+
+	e := &models.MetricsEvent{
+		ID:             utils.NewID(),
+		ProjectID:      strings.TrimSpace(in.ProjectID),
+		Platform:       strings.ToLower(strings.TrimSpace(in.Platform)),
+		AccountRef:     strings.TrimSpace(in.AccountRef),
+		ExternalPostID: strings.TrimSpace(in.ExternalPostID),
+		MetricType:     metricType,
+		MetricValue:    in.MetricValue,
+		OccurredAt:     in.OccurredAt,
+		Source:         strings.TrimSpace(in.Source),
+		CreatedAt:      now,
+	}
+	if e.OccurredAt <= 0 {
+		e.OccurredAt = now
+	}
+	if e.Source == "" {
+		e.Source = "api"
+	}
+	metaJSON, err := encodeRawJSON(in.Meta, "{}", "meta")
+	if err != nil {
+		return nil, err
+	}
+	e.MetaJSON = metaJSON
+
+	// 通过发布记录补全项目/平台/帖子信息
+	recordID := strings.TrimSpace(in.RecordID)
+	if recordID != "" {
+		e.RecordID = &recordID
+	} else if e.Platform != "" && e.ExternalPostID != "" && s.publish != nil {
+		rec, err := s.publish.GetRecordByExternalPost(ctx, e.Platform, e.ExternalPostID)
+		if err != nil {
+			return nil, err
+		}
+		if rec != nil && (e.ProjectID == "" || e.ProjectID == rec.ProjectID) {
+			id := rec.ID
+			e.RecordID = &id
+			e.ProjectID = rec.ProjectID
+			if e.AccountRef == "" {
+				e.AccountRef = rec.AccountRef
+			}
+		}
+	}
+
+	if e.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	if e.Platform == "" {
+		return nil, errors.New("platform is required")
+	}
+	if e.ExternalPostID == "" {
+		return nil, errors.New("external_post_id is required")
+	}
+	return e, nil
+}
+
+// ---- Rollup ----
+
+func (s *PublishMetricsService) markRollupDirty(key metricsRollupKey) {
+	s.rollupMu.Lock()
+	s.rollupDirty[key] = struct{}{}
+	s.rollupMu.Unlock()
+}
+
+func (s *PublishMetricsService) takeRollupDirty() []metricsRollupKey {
+	s.rollupMu.Lock()
+	defer s.rollupMu.Unlock()
+	if len(s.rollupDirty) == 0 {
+		return nil
+	}
+	keys := make([]metricsRollupKey, 0, len(s.rollupDirty))
+	for k := range s.rollupDirty {
+		keys = append(keys, k)
+	}
+	s.rollupDirty = make(map[metricsRollupKey]struct{})
+	return keys
+}
+
+func (s *PublishMetricsService) metricsRollupLoop() {
+	defer s.wg.Done()
+	if s.metrics == nil {
+		return
+	}
+
+	// 启动时补汇总最近写入的指标点（上次退出前可能未来得及刷新）
+	since := time.Now().Add(-metricsRollupLookback).Unix()
+	if refs, err := s.metrics.ListEventRefsCreatedAfter(context.Background(), since); err != nil {
+		logger.Warn("Failed to load recent metrics events", zap.Error(err))
+	} else {
+		for _, ref := range refs {
+			s.markRollupDirty(metricsRollupKey{
+				ProjectID:      ref.ProjectID,
+				Platform:       ref.Platform,
+				ExternalPostID: ref.ExternalPostID,
+				Date:           metricsDate(ref.OccurredAt),
+			})
+		}
+	}
+
+	ticker := time.NewTicker(s.rollupInterval)
+	defer ticker.Stop()
+	for {
+		s.flushMetricsRollup()
+		select {
+		case <-ticker.C:
+		case <-s.stopChan:
+			s.flushMetricsRollup()
+			return
+		}
+	}
+}
+
+func (s *PublishMetricsService) flushMetricsRollup() int {
+	keys := s.takeRollupDirty()
+	if len(keys) == 0 {
+		return 0
+	}
+	// 同一帖子按日期升序汇总，保证累计值向后延续
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ProjectID != keys[j].ProjectID {
+			return keys[i].ProjectID < keys[j].ProjectID
+		}
+		if keys[i].ExternalPostID != keys[j].ExternalPostID {
+			return keys[i].ExternalPostID < keys[j].ExternalPostID
+		}
+		return keys[i].Date < keys[j].Date
+	})
+	keys, err := s.expandRollupKeys(context.Background(), keys)
+	if err != nil {
+		logger.Warn("Failed to load later metrics snapshots", zap.Error(err))
+	}
+	n := 0
+	for _, k := range keys {
+		if err := s.rollupPostDay(context.Background(), k); err != nil {
+			logger.Warn("Failed to roll up daily metrics",
+				zap.String("project_id", k.ProjectID),
+				zap.String("external_post_id", k.ExternalPostID),
+				zap.String("date", k.Date),
+				zap.Error(err))
+			s.markRollupDirty(k)
+			continue
+		}
+		n++
+	}
+	return n
+}
+
+// expandRollupKeys 补上每个帖子最早待汇总日期之后已有快照的日期：
+// 回填早期数据会改变沿用到后续快照的累计值，需要按日期顺序一并重算。keys 需已排序。
+func (s *PublishMetricsService) expandRollupKeys(ctx context.Context, keys []metricsRollupKey) ([]metricsRollupKey, error) {
+	out := make([]metricsRollupKey, 0, len(keys))
+	var firstErr error
+	for i := 0; i < len(keys); {
+		j := i
+		for j < len(keys) && keys[j].ProjectID == keys[i].ProjectID && keys[j].ExternalPostID == keys[i].ExternalPostID {
+			j++
+		}
+		group := append([]metricsRollupKey(nil), keys[i:j]...)
+		dates, err := s.metrics.ListSnapshotDatesAfter(ctx, group[0].ProjectID, group[0].ExternalPostID, group[0].Date)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		seen := make(map[string]bool, len(group)+len(dates))
+		for _, k := range group {
+			seen[k.Date] = true
+		}
+		for _, d := range dates {
+			if !seen[d] {
+				seen[d] = true
+				group = append(group, metricsRollupKey{
+					ProjectID:      group[0].ProjectID,
+					Platform:       group[0].Platform,
+					ExternalPostID: group[0].ExternalPostID,
+					Date:           d,
+				})
+			}
+		}
+		sort.SliceStable(group, func(a, b int) bool { return group[a].Date < group[b].Date })
+		out = append(out, group...)
+		i = j
+	}
+	return out, firstErr
+}
+
+// rollupPostDay 将某帖子某天的指标点汇总到日快照：
+// 累计类指标取当天最后一个值，followers_delta 求和；当天没有数据的累计指标沿用前一天快照。
+func (s *PublishMetricsService) rollupPostDay(ctx context.Context, k metricsRollupKey) error {
+	day, err := parseMetricsDate(k.Date, "date")
+	if err != nil {
+		return err
+	}
+	events, err := s.metrics.ListPostEvents(ctx, k.ProjectID, k.Platform, k.ExternalPostID, day.Unix(), day.AddDate(0, 0, 1).Unix())
+	if err != nil {
+		return err
+	}
+	if len(events) == 0 {
+		return nil
+	}
+
+	snap, err := s.metrics.GetSnapshot(ctx, k.ProjectID, k.ExternalPostID, k.Date)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	if snap == nil {
+		snap = &models.MetricsSnapshotDaily{
+			ID:             utils.NewID(),
+			ProjectID:      k.ProjectID,
+			ExternalPostID: k.ExternalPostID,
+			SnapshotDate:   k.Date,
+			MetaJSON:       "{}",
+			CreatedAt:      now,
+		}
+	}
+	// 每次都从前一天快照重新沿用，前一天被回填后本日的累计值随之更新
+	prev, err := s.metrics.GetLatestSnapshotBefore(ctx, k.ProjectID, k.ExternalPostID, k.Date)
+	if err != nil {
+		return err
+	}
+	snap.Views, snap.Likes, snap.Comments, snap.Shares, snap.Favorites = 0, 0, 0, 0, 0
+	if prev != nil {
+		snap.Views, snap.Likes, snap.Comments = prev.Views, prev.Likes, prev.Comments
+		snap.Shares, snap.Favorites = prev.Shares, prev.Favorites
+		if snap.RecordID == nil {
+			snap.RecordID = prev.RecordID
+		}
+	}
+	snap.Platform = k.Platform
+
+	var followers float64
+	hasFollowers := false
+	for _, e := range events {
+		if e.RecordID != nil && *e.RecordID != "" {
+			snap.RecordID = e.RecordID
+		}
+		switch e.MetricType {
+		case MetricViews:
+			snap.Views = e.MetricValue
+		case MetricLikes:
+			snap.Likes = e.MetricValue
+		case MetricComments:
+			snap.Comments = e.MetricValue
+		case MetricShares:
+			snap.Shares = e.MetricValue
+		case MetricFavorites:
+			snap.Favorites = e.MetricValue
+		case MetricFollowersDelta:
+			followers += e.MetricValue
+			hasFollowers = true
+		}
+	}
+	if hasFollowers {
+		snap.FollowersDelta = followers
+	}
+	snap.UpdatedAt = now
+	return s.metrics.UpsertSnapshot(ctx, snap)
+}
+
+type RollupMetricsRequest struct {
+	ProjectID string `json:"project_id"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+type RollupMetricsResult struct {
+	Snapshots int `json:"snapshots"`
+}
+
+// RollupDaily 立即重算项目在 [from, to] 内的日快照（默认为今天）
+func (s *PublishMetricsService) RollupDaily(ctx context.Context, req RollupMetricsRequest) (*RollupMetricsResult, error) {
+	if s.metrics == nil {
+		return nil, errMetricsUnavailable
+	}
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	from, to, err := resolveMetricsRange(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := s.metrics.ListEventRefsInRange(ctx, req.ProjectID, from.Unix(), to.AddDate(0, 0, 1).Unix())
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range refs {
+		s.markRollupDirty(metricsRollupKey{
+			ProjectID:      ref.ProjectID,
+			Platform:       ref.Platform,
+			ExternalPostID: ref.ExternalPostID,
+			Date:           metricsDate(ref.OccurredAt),
+		})
+	}
+	return &RollupMetricsResult{Snapshots: s.flushMetricsRollup()}, nil
+}
+
+func resolveMetricsRange(fromStr string, toStr string) (time.Time, time.Time, error) {
+	today, _ := parseMetricsDate(time.Now().Format(metricsSnapshotDateFmt), "date")
+	to := today
+	if strings.TrimSpace(toStr) != "" {
+		t, err := parseMetricsDate(toStr, "to")
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t
+	}
+	from := to
+	if strings.TrimSpace(fromStr) != "" {
+		t, err := parseMetricsDate(fromStr, "from")
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = t
+	}
+	if from.After(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// ---- Daily snapshots ----
+
+type ListMetricsSnapshotsRequest struct {
+	ProjectID      string
+	Platform       string
+	ExternalPostID string
+	From           string
+	To             string
+	Limit          int
+}
+
+func (s *PublishMetricsService) ListDailySnapshots(ctx context.Context, req ListMetricsSnapshotsRequest) ([]models.MetricsSnapshotDaily, error) {
+	if s.metrics == nil {
+		return nil, errMetricsUnavailable
+	}
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	for _, d := range []struct{ v, field string }{{req.From, "from"}, {req.To, "to"}} {
+		if d.v == "" {
+			continue
+		}
+		if _, err := parseMetricsDate(d.v, d.field); err != nil {
+			return nil, err
+		}
+	}
+	out, err := s.metrics.ListSnapshots(ctx, repos.MetricsSnapshotQuery{
+		ProjectID:      req.ProjectID,
+		Platform:       strings.ToLower(strings.TrimSpace(req.Platform)),
+		ExternalPostID: req.ExternalPostID,
+		DateFrom:       req.From,
+		DateTo:         req.To,
+		Limit:          req.Limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Meta = decodeRawJSON(out[i].MetaJSON, "{}")
+	}
+	return out, nil
+}
+
+type UpsertMetricsSnapshotRequest struct {
+	ProjectID      string          `json:"project_id"`
+	RecordID       string          `json:"record_id"`
+	Platform       string          `json:"platform"`
+	ExternalPostID string          `json:"external_post_id"`
+	SnapshotDate   string          `json:"snapshot_date"`
+	Views          float64         `json:"views"`
+	Likes          float64         `json:"likes"`
+	Comments       float64         `json:"comments"`
+	Shares         float64         `json:"shares"`
+	Favorites      float64         `json:"favorites"`
+	FollowersDelta float64         `json:"followers_delta"`
+	Meta           json.RawMessage `json:"meta"`
+}
+
+// UpsertDailySnapshot 手动写入/覆盖某帖子某天的快照（如从平台后台导出的数据）
+func (s *PublishMetricsService) UpsertDailySnapshot(ctx context.Context, req UpsertMetricsSnapshotRequest) (*models.MetricsSnapshotDaily, error) {
+	if s.metrics == nil {
+		return nil, errMetricsUnavailable
+	}
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	platform := strings.ToLower(strings.TrimSpace(req.Platform))
+	if platform == "" {
+		return nil, errors.New("platform is required")
+	}
+	postID := strings.TrimSpace(req.ExternalPostID)
+	if postID == "" {
+		return nil, errors.New("external_post_id is required")
+	}
+	if _, err := parseMetricsDate(req.SnapshotDate, "snapshot_date"); err != nil {
+		return nil, err
+	}
+	for _, v := range []float64{req.Views, req.Likes, req.Comments, req.Shares, req.Favorites} {
+		if v < 0 {
+			return nil, errors.New("metric values must be >= 0")
+		}
+	}
+	if err := s.ensureProject(ctx, req.ProjectID); err != nil {
+		return nil, err
+	}
+	metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+	if err != nil {
+		return nil, err
+	}
+
+	date := strings.TrimSpace(req.SnapshotDate)
+	snap, err := s.metrics.GetSnapshot(ctx, req.ProjectID, postID, date)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if snap == nil {
+		snap = &models.MetricsSnapshotDaily{
+			ID:             utils.NewID(),
+			ProjectID:      req.ProjectID,
+			ExternalPostID: postID,
+			SnapshotDate:   date,
+			CreatedAt:      now,
+		}
+	}
+	if recordID := strings.TrimSpace(req.RecordID); recordID != "" {
+		snap.RecordID = &recordID
+	}
+	snap.Platform = platform
+	snap.Views = req.Views
+	snap.Likes = req.Likes
+	snap.Comments = req.Comments
+	snap.Shares = req.Shares
+	snap.Favorites = req.Favorites
+	snap.FollowersDelta = req.FollowersDelta
+	snap.MetaJSON = metaJSON
+	snap.UpdatedAt = now
+	if err := s.metrics.UpsertSnapshot(ctx, snap); err != nil {
+		return nil, err
+	}
+	snap.Meta = decodeRawJSON(snap.MetaJSON, "{}")
+	return snap, nil
+}
+
+// ---- Trend ----
+
+type MetricsTrendRequest struct {
+	ProjectID      string `json:"project_id"`
+	Platform       string `json:"platform"`
+	ExternalPostID string `json:"external_post_id"`
+	From           string `json:"from"`
+	To             string `json:"to"`
+}
+
+// MetricsTotals 一段时间内的指标增量
+type MetricsTotals struct {
+	Views          float64 `json:"views"`
+	Likes          float64 `json:"likes"`
+	Comments       float64 `json:"comments"`
+	Shares         float64 `json:"shares"`
+	Favorites      float64 `json:"favorites"`
+	FollowersDelta float64 `json:"followers_delta"`
+}
+
+func (t *MetricsTotals) add(o MetricsTotals) {
+	t.Views += o.Views
+	t.Likes += o.Likes
+	t.Comments += o.Comments
+	t.Shares += o.Shares
+	t.Favorites += o.Favorites
+	t.FollowersDelta += o.FollowersDelta
+}
+
+func (t MetricsTotals) sub(o MetricsTotals) MetricsTotals {
+	return MetricsTotals{
+		Views:          t.Views - o.Views,
+		Likes:          t.Likes - o.Likes,
+		Comments:       t.Comments - o.Comments,
+		Shares:         t.Shares - o.Shares,
+		Favorites:      t.Favorites - o.Favorites,
+		FollowersDelta: t.FollowersDelta - o.FollowersDelta,
+	}
+}
+
+type MetricsTrendPoint struct {
+	Date string `json:"date"`
+	MetricsTotals
+}
+
+type MetricsTrend struct {
+	ProjectID    string              `json:"project_id"`
+	Platform     string              `json:"platform,omitempty"`
+	From         string              `json:"from"`
+	To           string              `json:"to"`
+	PreviousFrom string              `json:"previous_from"`
+	PreviousTo   string              `json:"previous_to"`
+	Series       []MetricsTrendPoint `json:"series"`
+	Totals       MetricsTotals       `json:"totals"`
+	Previous     MetricsTotals       `json:"previous"`
+	Delta        MetricsTotals       `json:"delta"`
+	// DeltaPct 相对上一周期的变化百分比；上一周期为 0 时为 null
+	DeltaPct map[string]*float64 `json:"delta_pct"`
+}
+
+// GetMetricsTrend 按天返回区间内的指标增量，并与等长的上一周期对比。
+// 累计类指标由相邻快照相减得到日增量（帖子首次出现的当天计入全部累计值）。
+func (s *PublishMetricsService) GetMetricsTrend(ctx context.Context, req MetricsTrendRequest) (*MetricsTrend, error) {
+	if s.metrics == nil {
+		return nil, errMetricsUnavailable
+	}
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	today := time.Now().Format(metricsSnapshotDateFmt)
+	if strings.TrimSpace(req.From) == "" {
+		t, _ := parseMetricsDate(today, "date")
+		req.From = t.AddDate(0, 0, -6).Format(metricsSnapshotDateFmt)
+	}
+	from, to, err := resolveMetricsRange(req.From, req.To)
+	if err != nil {
+		return nil, err
+	}
+	days := int(to.Sub(from).Hours()/24+0.5) + 1
+	if days > metricsTrendMaxDays {
+		return nil, fmt.Errorf("date range exceeds %d days", metricsTrendMaxDays)
+	}
+	prevTo := from.AddDate(0, 0, -1)
+	prevFrom := from.AddDate(0, 0, -days)
+
+	platform := strings.ToLower(strings.TrimSpace(req.Platform))
+	snaps, err := s.metrics.ListSnapshotsUpTo(ctx, repos.MetricsSnapshotQuery{
+		ProjectID:      req.ProjectID,
+		Platform:       platform,
+		ExternalPostID: strings.TrimSpace(req.ExternalPostID),
+		DateTo:         to.Format(metricsSnapshotDateFmt),
+	})
+	if err != nil {
+		return nil, err
+	}
+	daily := metricsDailyGains(snaps)
+
+	out := &MetricsTrend{
+		ProjectID:    req.ProjectID,
+		Platform:     platform,
+		From:         from.Format(metricsSnapshotDateFmt),
+		To:           to.Format(metricsSnapshotDateFmt),
+		PreviousFrom: prevFrom.Format(metricsSnapshotDateFmt),
+		PreviousTo:   prevTo.Format(metricsSnapshotDateFmt),
+		Series:       make([]MetricsTrendPoint, 0, days),
+	}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		date := d.Format(metricsSnapshotDateFmt)
+		point := MetricsTrendPoint{Date: date, MetricsTotals: daily[date]}
+		out.Series = append(out.Series, point)
+		out.Totals.add(point.MetricsTotals)
+	}
+	for d := prevFrom; !d.After(prevTo); d = d.AddDate(0, 0, 1) {
+		out.Previous.add(daily[d.Format(metricsSnapshotDateFmt)])
+	}
+	out.Delta = out.Totals.sub(out.Previous)
+	out.DeltaPct = map[string]*float64{
+		MetricViews:          deltaPct(out.Totals.Views, out.Previous.Views),
+		MetricLikes:          deltaPct(out.Totals.Likes, out.Previous.Likes),
+		MetricComments:       deltaPct(out.Totals.Comments, out.Previous.Comments),
+		MetricShares:         deltaPct(out.Totals.Shares, out.Previous.Shares),
+		MetricFavorites:      deltaPct(out.Totals.Favorites, out.Previous.Favorites),
+		MetricFollowersDelta: deltaPct(out.Totals.FollowersDelta, out.Previous.FollowersDelta),
+	}
+	return out, nil
+}
+
+// metricsDailyGains 将每个帖子的累计快照转换为逐日增量并按日期汇总；
+// snaps 需按 (external_post_id, snapshot_date) 升序。
+func metricsDailyGains(snaps []models.MetricsSnapshotDaily) map[string]MetricsTotals {
+	out := make(map[string]MetricsTotals)
+	var last *models.MetricsSnapshotDaily
+	for i := range snaps {
+		cur := &snaps[i]
+		if last != nil && last.ExternalPostID != cur.ExternalPostID {
+			last = nil
+		}
+		gain := MetricsTotals{
+			Views:          cur.Views,
+			Likes:          cur.Likes,
+			Comments:       cur.Comments,
+			Shares:         cur.Shares,
+			Favorites:      cur.Favorites,
+			FollowersDelta: cur.FollowersDelta,
+		}
+		if last != nil {
+			gain.Views = cur.Views - last.Views
+			gain.Likes = cur.Likes - last.Likes
+			gain.Comments = cur.Comments - last.Comments
+			gain.Shares = cur.Shares - last.Shares
+			gain.Favorites = cur.Favorites - last.Favorites
+		}
+		t := out[cur.SnapshotDate]
+		t.add(gain)
+		out[cur.SnapshotDate] = t
+		last = cur
+	}
+	return out
+}
+
+func deltaPct(cur float64, prev float64) *float64 {
+	if prev == 0 {
+		return nil
+	}
+	v := math.Round((cur-prev)/math.Abs(prev)*10000) / 100
+	return &v
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+func newTestMetricsService(t *testing.T) (*PublishMetricsService, string) {
+	t.Helper()
+	d := newTestDB(t)
+	projects := repos.NewProjectRepo(d.ORM())
+	p, err := projects.Create(context.Background(), "Launch", "video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewPublishMetricsService(projects, nil, nil, nil, nil, nil)
+	s.SetMetricsRepo(repos.NewMetricsRepo(d.ORM()))
+	return s, p.ID
+}
+
+func metricsAt(day int) int64 {
+	return time.Date(2026, 10, day, 12, 0, 0, 0, time.Local).Unix()
+}
+
+func TestPublishMetricsService_IngestDedup(t *testing.T) {
+	ctx := context.Background()
+	s, projectID := newTestMetricsService(t)
+
+	event := MetricsEventInput{ProjectID: projectID, Platform: "Douyin", ExternalPostID: "v1", MetricType: "plays", MetricValue: 10, OccurredAt: metricsAt(1)}
+	res, err := s.IngestMetricsEvents(ctx, IngestMetricsRequest{Events: []MetricsEventInput{event, event}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Accepted != 1 || res.Duplicates != 1 {
+		t.Fatalf("first batch = %+v, want 1 accepted / 1 duplicate", res)
+	}
+	// 别名归一后仍视为同一指标
+	event.MetricType = "views"
+	res, err = s.IngestMetricsEvents(ctx, IngestMetricsRequest{MetricsEventInput: event})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Accepted != 0 || res.Duplicates != 1 {
+		t.Fatalf("alias resend = %+v, want duplicate", res)
+	}
+	event.OccurredAt = metricsAt(2)
+	if res, err = s.IngestMetricsEvents(ctx, IngestMetricsRequest{MetricsEventInput: event}); err != nil || res.Accepted != 1 {
+		t.Fatalf("later point = %+v, %v", res, err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		mutate  func(e *MetricsEventInput)
+		wantErr string
+	}{
+		{"followers is not a delta", func(e *MetricsEventInput) { e.MetricType = "followers" }, `invalid metric_type "followers"`},
+		{"negative cumulative", func(e *MetricsEventInput) { e.MetricValue = -1 }, "metric_value must be >= 0"},
+		{"missing post", func(e *MetricsEventInput) { e.ExternalPostID = " " }, "external_post_id is required"},
+		{"unknown project", func(e *MetricsEventInput) { e.ProjectID = "nope" }, "project not found"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := event
+			tc.mutate(&e)
+			_, err := s.IngestMetricsEvents(ctx, IngestMetricsRequest{Events: []MetricsEventInput{event, e}})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) || !strings.HasPrefix(err.Error(), "events[1]: ") {
+				t.Fatalf("error = %v, want events[1]: %s", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublishMetricsService_RollupCarryForward(t *testing.T) {
+	ctx := context.Background()
+	s, projectID := newTestMetricsService(t)
+	// 每个点错开一秒，避免同一时刻的点被去重
+	seq := int64(0)
+	ingest := func(day int, metric string, value float64) {
+		t.Helper()
+		seq++
+		if _, err := s.IngestMetricsEvents(ctx, IngestMetricsRequest{MetricsEventInput: MetricsEventInput{
+			ProjectID: projectID, Platform: "douyin", ExternalPostID: "v1", MetricType: metric, MetricValue: value, OccurredAt: metricsAt(day) + seq,
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	snapshot := func(day int) *models.MetricsSnapshotDaily {
+		t.Helper()
+		snap, err := s.metrics.GetSnapshot(ctx, projectID, "v1", metricsDate(metricsAt(day)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if snap == nil {
+			t.Fatalf("no snapshot for day %d", day)
+		}
+		return snap
+	}
+
+	ingest(1, MetricViews, 100)
+	ingest(1, MetricLikes, 5)
+	ingest(1, MetricFollowersDelta, 2)
+	ingest(1, MetricFollowersDelta, 3)
+	ingest(3, MetricViews, 300)
+	if n := s.flushMetricsRollup(); n != 2 {
+		t.Fatalf("rolled %d days, want 2", n)
+	}
+	if snap := snapshot(1); snap.Views != 100 || snap.Likes != 5 || snap.FollowersDelta != 5 {
+		t.Fatalf("day 1 = %+v", snap)
+	}
+	if snap := snapshot(3); snap.Views != 300 || snap.Likes != 5 || snap.FollowersDelta != 0 {
+		t.Fatalf("day 3 should carry likes from day 1: %+v", snap)
+	}
+
+	// 回填第 2 天后，第 3 天沿用的累计值随之更新
+	ingest(2, MetricLikes, 20)
+	if n := s.flushMetricsRollup(); n != 2 {
+		t.Fatalf("rolled %d days after backfill, want 2", n)
+	}
+	if snap := snapshot(2); snap.Views != 100 || snap.Likes != 20 {
+		t.Fatalf("day 2 = %+v", snap)
+	}
+	if snap := snapshot(3); snap.Views != 300 || snap.Likes != 20 {
+		t.Fatalf("day 3 after backfill = %+v", snap)
+	}
+}
+
+func TestPublishMetricsService_Trend(t *testing.T) {
+	ctx := context.Background()
+	s, projectID := newTestMetricsService(t)
+	for _, e := range []struct {
+		post   string
+		day    int
+		metric string
+		value  float64
+	}{
+		{"v1", 1, MetricViews, 100},
+		{"v1", 3, MetricViews, 250},
+		{"v1", 4, MetricViews, 400},
+		{"v1", 4, MetricFollowersDelta, 7},
+		{"v2", 4, MetricViews, 50},
+	} {
+		if _, err := s.IngestMetricsEvents(ctx, IngestMetricsRequest{MetricsEventInput: MetricsEventInput{
+			ProjectID: projectID, Platform: "douyin", ExternalPostID: e.post, MetricType: e.metric, MetricValue: e.value, OccurredAt: metricsAt(e.day),
+		}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.flushMetricsRollup()
+
+	trend, err := s.GetMetricsTrend(ctx, MetricsTrendRequest{ProjectID: projectID, From: "2026-10-03", To: "2026-10-04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trend.PreviousFrom != "2026-10-01" || trend.PreviousTo != "2026-10-02" {
+		t.Fatalf("previous period = %s..%s", trend.PreviousFrom, trend.PreviousTo)
+	}
+	if len(trend.Series) != 2 {
+		t.Fatalf("series = %+v", trend.Series)
+	}
+	// v1 第 3 天增量 150，第 4 天增量 150；v2 首次出现计入全部累计值
+	if got := trend.Series[0]; got.Date != "2026-10-03" || got.Views != 150 {
+		t.Errorf("day 3 = %+v", got)
+	}
+	if got := trend.Series[1]; got.Views != 200 || got.FollowersDelta != 7 {
+		t.Errorf("day 4 = %+v", got)
+	}
+	if trend.Totals.Views != 350 || trend.Previous.Views != 100 || trend.Delta.Views != 250 {
+		t.Errorf("totals=%+v previous=%+v delta=%+v", trend.Totals, trend.Previous, trend.Delta)
+	}
+	if p := trend.DeltaPct[MetricViews]; p == nil || *p != 250 {
+		t.Errorf("views delta pct = %v, want 250", p)
+	}
+	if p := trend.DeltaPct[MetricFollowersDelta]; p != nil {
+		t.Errorf("followers delta pct = %v, want nil for an empty previous period", *p)
+	}
+
+	post, err := s.GetMetricsTrend(ctx, MetricsTrendRequest{ProjectID: projectID, ExternalPostID: "v2", From: "2026-10-03", To: "2026-10-04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if post.Totals.Views != 50 {
+		t.Errorf("v2 totals = %+v", post.Totals)
+	}
+
+	for _, req := range []MetricsTrendRequest{
+		{ProjectID: projectID, From: "2026-10-05", To: "2026-10-04"},
+		{ProjectID: projectID, From: "2025-01-01", To: "2026-10-04"},
+		{ProjectID: projectID, From: "10/04/2026"},
+	} {
+		if _, err := s.GetMetricsTrend(ctx, req); err == nil {
+			t.Errorf("%+v: expected error", req)
+		}
+	}
+}
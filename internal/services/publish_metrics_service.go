@@ -0,0 +1,782 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	publishPollInterval    = 5 * time.Second
+	publishDispatchTimeout = 2 * time.Minute
+	publishBatchSize       = 10
+	publishRetryBase       = 30 * time.Second
+	publishRetryMax        = 30 * time.Minute
+	defaultPublishAttempts = 3
+	// publishJobLease running 超过该时长的任务视为调度中途失败，放回队列
+	publishJobLease = 10 * time.Minute
+	// publishRecordAttempts 平台已发布后写入发布记录的重试次数；记录落库前绝不重新发布
+	publishRecordAttempts = 3
+)
+
+// PublishMetricsService 负责发布渠道/任务/记录以及发布后的数据指标。
+// 后台调度器按 schedule_at 拾取到期任务，交给渠道适配器执行并记录结果。
+type PublishMetricsService struct {
+	projects  *repos.ProjectRepo
+	workflows *repos.ProjectWorkflowRepo
+	roadmap   *repos.ProjectRoadmapRepo
+	artifacts *repos.ProjectArtifactRepo
+	publish   *repos.PublishRepo
+	metrics   *repos.MetricsRepo
+	eventHub  *EventHub
+
+	adaptersMu sync.RWMutex
+	adapters   map[string]PublishChannelAdapter
+
+	stopChan     chan struct{}
+	wakeChan     chan struct{}
+	wg           sync.WaitGroup
+	pollInterval time.Duration
+
+	rollupMu       sync.Mutex
+	rollupDirty    map[metricsRollupKey]struct{}
+	rollupInterval time.Duration
+}
+
+func NewPublishMetricsService(
+	projects *repos.ProjectRepo,
+	workflows *repos.ProjectWorkflowRepo,
+	roadmap *repos.ProjectRoadmapRepo,
+	artifacts *repos.ProjectArtifactRepo,
+	publish *repos.PublishRepo,
+	eventHub *EventHub,
+) *PublishMetricsService {
+	s := &PublishMetricsService{
+		projects:     projects,
+		workflows:    workflows,
+		roadmap:      roadmap,
+		artifacts:    artifacts,
+		publish:      publish,
+		eventHub:     eventHub,
+		adapters:     make(map[string]PublishChannelAdapter),
+		stopChan:     make(chan struct{}),
+		wakeChan:     make(chan struct{}, 1),
+		pollInterval: publishPollInterval,
+
+		rollupDirty:    make(map[metricsRollupKey]struct{}),
+		rollupInterval: metricsRollupInterval,
+	}
+	s.RegisterPublishAdapter(NewLocalPublishAdapter())
+	return s
+}
+
+// RegisterPublishAdapter 注册渠道适配器，渠道通过 config.adapter 或 platform 匹配适配器名
+func (s *PublishMetricsService) RegisterPublishAdapter(adapter PublishChannelAdapter) {
+	if adapter == nil || strings.TrimSpace(adapter.Name()) == "" {
+		return
+	}
+	s.adaptersMu.Lock()
+	defer s.adaptersMu.Unlock()
+	s.adapters[strings.ToLower(strings.TrimSpace(adapter.Name()))] = adapter
+}
+
+func (s *PublishMetricsService) resolveAdapter(ch *models.PublishChannel) PublishChannelAdapter {
+	name := strings.ToLower(strings.TrimSpace(ch.Platform))
+	var cfg struct {
+		Adapter string `json:"adapter"`
+	}
+	if json.Unmarshal([]byte(ch.ConfigJSON), &cfg) == nil && strings.TrimSpace(cfg.Adapter) != "" {
+		name = strings.ToLower(strings.TrimSpace(cfg.Adapter))
+	}
+	s.adaptersMu.RLock()
+	defer s.adaptersMu.RUnlock()
+	return s.adapters[name]
+}
+
+// ---- Scheduler ----
+
+func (s *PublishMetricsService) Start() {
+	s.wg.Add(2)
+	go s.publishLoop()
+	go s.metricsRollupLoop()
+}
+
+func (s *PublishMetricsService) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *PublishMetricsService) wake() {
+	select {
+	case s.wakeChan <- struct{}{}:
+	default:
+	}
+}
+
+func (s *PublishMetricsService) publishLoop() {
+	defer s.wg.Done()
+
+	if n, err := s.publish.RequeueRunningJobs(context.Background(), 0); err != nil {
+		logger.Warn("Failed to requeue running publish jobs", zap.Error(err))
+	} else if n > 0 {
+		logger.Info("Requeued interrupted publish jobs", zap.Int64("count", n))
+	}
+
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		s.runDueJobs()
+		select {
+		case <-ticker.C:
+		case <-s.wakeChan:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *PublishMetricsService) runDueJobs() {
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		default:
+		}
+		if n, err := s.publish.RequeueRunningJobs(context.Background(), time.Now().Add(-publishJobLease).Unix()); err != nil {
+			logger.Warn("Failed to requeue stale publish jobs", zap.Error(err))
+		} else if n > 0 {
+			logger.Warn("Requeued stale running publish jobs", zap.Int64("count", n))
+		}
+		ids, err := s.publish.ListDueJobIDs(context.Background(), time.Now().Unix(), publishBatchSize)
+		if err != nil {
+			logger.Warn("Failed to list due publish jobs", zap.Error(err))
+			return
+		}
+		if len(ids) == 0 {
+			return
+		}
+		for _, id := range ids {
+			claimed, err := s.publish.ClaimJob(context.Background(), id)
+			if err != nil {
+				logger.Warn("Failed to claim publish job", zap.String("job_id", id), zap.Error(err))
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			s.dispatchJob(id)
+		}
+		if len(ids) < publishBatchSize {
+			return
+		}
+	}
+}
+
+func (s *PublishMetricsService) dispatchJob(jobID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), publishDispatchTimeout)
+	defer cancel()
+
+	job, err := s.publish.GetJob(ctx, jobID)
+	if err != nil {
+		logger.Warn("Failed to load claimed publish job", zap.String("job_id", jobID), zap.Error(err))
+		if err := s.publish.ReleaseJob(context.Background(), jobID); err != nil {
+			// 放回失败时由租约回收兜底
+			logger.Error("Failed to release publish job", zap.String("job_id", jobID), zap.Error(err))
+		}
+		return
+	}
+	if job == nil {
+		return
+	}
+	s.broadcastJob(job, "publish_job_running")
+
+	// 上一次已在平台发布并写入记录、但标记成功失败：只补记账，不重复发布
+	if rec, err := s.publish.GetRecordByJob(ctx, job.ID); err != nil {
+		s.finishJobWithError(ctx, job, err)
+		return
+	} else if rec != nil {
+		if err := s.markPublishSucceeded(ctx, job); err != nil {
+			logger.Error("Failed to mark published job succeeded", zap.String("job_id", job.ID), zap.Error(err))
+		}
+		return
+	}
+
+	ch, err := s.publish.GetChannel(ctx, job.ChannelID)
+	if err != nil {
+		s.finishJobWithError(ctx, job, err)
+		return
+	}
+	if ch == nil {
+		s.finishJobWithError(ctx, job, PermanentPublishError(errors.New("publish channel not found")))
+		return
+	}
+	if !ch.IsEnabled {
+		s.finishJobWithError(ctx, job, PermanentPublishError(errors.New("publish channel is disabled")))
+		return
+	}
+	adapter := s.resolveAdapter(ch)
+	if adapter == nil {
+		s.finishJobWithError(ctx, job, PermanentPublishError(fmt.Errorf("no publish adapter for platform %q", ch.Platform)))
+		return
+	}
+
+	var artifact *models.ProjectArtifact
+	if job.ArtifactID != "" && s.artifacts != nil {
+		artifact, err = s.artifacts.Get(ctx, job.ArtifactID)
+		if err != nil {
+			s.finishJobWithError(ctx, job, err)
+			return
+		}
+		if artifact == nil {
+			s.finishJobWithError(ctx, job, PermanentPublishError(errors.New("artifact not found")))
+			return
+		}
+		attachArtifactMeta(artifact)
+	}
+
+	res, err := adapter.Publish(ctx, PublishDispatch{Job: job, Channel: ch, Artifact: artifact})
+	if err != nil {
+		s.finishJobWithError(ctx, job, err)
+		return
+	}
+	if res == nil {
+		res = &PublishAdapterResult{}
+	}
+	s.persistPublishResult(job, ch, res)
+}
+
+// persistPublishResult 平台已发布成功：先落库外部帖子 ID / 链接，再标记任务成功。
+// 记录写入失败时只重试记账；仍失败则任务置为 failed 而不是重新排期，避免重复发帖。
+// 记录已写入但标记失败时任务保持 running，由租约回收后走“已有记录只补记账”的分支
+func (s *PublishMetricsService) persistPublishResult(job *models.PublishJob, ch *models.PublishChannel, res *PublishAdapterResult) {
+	var err error
+	saved := false
+	for attempt := 1; attempt <= publishRecordAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), publishDispatchTimeout)
+		if saved {
+			err = s.markPublishSucceeded(ctx, job)
+		} else if _, err = s.recordPublishSuccess(ctx, job, ch, res); errors.Is(err, errPublishJobNotMarked) {
+			saved = true
+		}
+		cancel()
+		if err == nil || attempt == publishRecordAttempts {
+			break
+		}
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-s.stopChan:
+			attempt = publishRecordAttempts
+		}
+	}
+	if err == nil {
+		return
+	}
+	logger.Error("Failed to record publish result",
+		zap.String("job_id", job.ID),
+		zap.String("external_post_id", res.ExternalPostID),
+		zap.String("post_url", res.PostURL),
+		zap.Error(err))
+	if saved {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishDispatchTimeout)
+	defer cancel()
+	s.finishJobWithError(ctx, job, PermanentPublishError(fmt.Errorf(
+		"published (post %q %s) but failed to save publish record: %w", res.ExternalPostID, res.PostURL, err)))
+}
+
+// finishJobWithError 可重试错误按指数退避重新排期，超过次数或永久错误则置为 failed
+func (s *PublishMetricsService) finishJobWithError(ctx context.Context, job *models.PublishJob, cause error) {
+	now := time.Now().Unix()
+	job.ErrorMessage = cause.Error()
+	job.UpdatedAt = now
+	eventType := "publish_job_failed"
+	maxAttempts := job.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultPublishAttempts
+	}
+	if !isPermanentPublishError(cause) && job.AttemptCount < maxAttempts {
+		job.Status = models.PublishJobPending
+		job.ScheduleAt = now + int64(publishRetryDelay(job.AttemptCount).Seconds())
+		eventType = "publish_job_retry"
+	} else {
+		job.Status = models.PublishJobFailed
+		job.FinishedAt = now
+	}
+	if err := s.publish.UpdateJob(ctx, job); err != nil {
+		logger.Error("Failed to update publish job", zap.String("job_id", job.ID), zap.Error(err))
+		return
+	}
+	logger.Warn("Publish job attempt failed",
+		zap.String("job_id", job.ID),
+		zap.Int("attempt", job.AttemptCount),
+		zap.String("status", job.Status),
+		zap.Error(cause))
+	s.broadcastJob(job, eventType)
+}
+
+func publishRetryDelay(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := publishRetryBase
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= publishRetryMax {
+			return publishRetryMax
+		}
+	}
+	return d
+}
+
+// recordPublishSuccess 写入发布记录、标记任务成功，并完成关联的路线图条目
+func (s *PublishMetricsService) recordPublishSuccess(ctx context.Context, job *models.PublishJob, ch *models.PublishChannel, res *PublishAdapterResult) (*models.PublishRecord, error) {
+	now := time.Now().Unix()
+	publishedAt := res.PublishedAt
+	if publishedAt <= 0 {
+		publishedAt = now
+	}
+	rawJSON, err := encodeRawJSON(res.RawResponse, "{}", "raw_response")
+	if err != nil {
+		rawJSON = "{}"
+	}
+	rec := &models.PublishRecord{
+		ID:              utils.NewID(),
+		JobID:           job.ID,
+		ProjectID:       job.ProjectID,
+		Platform:        ch.Platform,
+		AccountRef:      ch.AccountRef,
+		ExternalPostID:  res.ExternalPostID,
+		PostURL:         res.PostURL,
+		PublishedAt:     publishedAt,
+		RawResponseJSON: rawJSON,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.publish.CreateRecord(ctx, rec); err != nil {
+		return nil, err
+	}
+	if err := s.markPublishSucceeded(ctx, job); err != nil {
+		return nil, fmt.Errorf("%w: %v", errPublishJobNotMarked, err)
+	}
+	rec.RawResponse = decodeRawJSON(rec.RawResponseJSON, "{}")
+	return rec, nil
+}
+
+// errPublishJobNotMarked 发布记录已写入但任务状态未更新
+var errPublishJobNotMarked = errors.New("publish record saved but job not marked succeeded")
+
+// markPublishSucceeded 标记任务成功并完成关联的路线图条目
+func (s *PublishMetricsService) markPublishSucceeded(ctx context.Context, job *models.PublishJob) error {
+	now := time.Now().Unix()
+	job.Status = models.PublishJobSucceeded
+	job.ErrorMessage = ""
+	job.FinishedAt = now
+	job.UpdatedAt = now
+	if err := s.publish.UpdateJob(ctx, job); err != nil {
+		return err
+	}
+	s.completeRoadmapItem(ctx, job)
+	s.broadcastJob(job, "publish_job_succeeded")
+	return nil
+}
+
+func (s *PublishMetricsService) completeRoadmapItem(ctx context.Context, job *models.PublishJob) {
+	if s.roadmap == nil || job.RoadmapItemID == nil || *job.RoadmapItemID == "" {
+		return
+	}
+	item, err := s.roadmap.Get(ctx, *job.RoadmapItemID)
+	if err != nil || item == nil || item.Status == "done" {
+		return
+	}
+	item.Status = "done"
+	item.UpdatedAt = time.Now().Unix()
+	if err := s.roadmap.Update(ctx, item); err != nil {
+		logger.Warn("Failed to complete roadmap item", zap.String("item_id", item.ID), zap.Error(err))
+	}
+}
+
+func (s *PublishMetricsService) broadcastJob(job *models.PublishJob, eventType string) {
+	if s.eventHub == nil || job == nil {
+		return
+	}
+	out := *job
+	out.Payload = decodeRawJSON(out.PayloadJSON, "{}")
+	s.eventHub.Broadcast(map[string]any{
+		"type": eventType,
+		"data": out,
+	})
+}
+
+// ---- Channels ----
+
+func (s *PublishMetricsService) ListChannels(ctx context.Context, projectID string) ([]models.PublishChannel, error) {
+	if projectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	out, err := s.publish.ListChannels(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Config = decodeRawJSON(out[i].ConfigJSON, "{}")
+	}
+	return out, nil
+}
+
+type UpsertPublishChannelRequest struct {
+	ID          string          `json:"id"`
+	ProjectID   string          `json:"project_id"`
+	Platform    string          `json:"platform"`
+	AccountRef  string          `json:"account_ref"`
+	DisplayName string          `json:"display_name"`
+	IsEnabled   *bool           `json:"is_enabled,omitempty"`
+	Config      json.RawMessage `json:"config"`
+}
+
+// UpsertChannel 无 id 时创建渠道，有 id 时更新
+func (s *PublishMetricsService) UpsertChannel(ctx context.Context, req UpsertPublishChannelRequest) (*models.PublishChannel, error) {
+	now := time.Now().Unix()
+	var ch *models.PublishChannel
+	if req.ID != "" {
+		existing, err := s.publish.GetChannel(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, errors.New("publish channel not found")
+		}
+		ch = existing
+	} else {
+		if req.ProjectID == "" {
+			return nil, errors.New("project_id is required")
+		}
+		if err := s.ensureProject(ctx, req.ProjectID); err != nil {
+			return nil, err
+		}
+		ch = &models.PublishChannel{
+			ID:         utils.NewID(),
+			ProjectID:  req.ProjectID,
+			IsEnabled:  true,
+			ConfigJSON: "{}",
+			CreatedAt:  now,
+		}
+	}
+
+	if platform := strings.ToLower(strings.TrimSpace(req.Platform)); platform != "" {
+		ch.Platform = platform
+	}
+	if ch.Platform == "" {
+		return nil, errors.New("platform is required")
+	}
+	if req.AccountRef != "" {
+		ch.AccountRef = strings.TrimSpace(req.AccountRef)
+	}
+	if req.DisplayName != "" {
+		ch.DisplayName = strings.TrimSpace(req.DisplayName)
+	}
+	if req.IsEnabled != nil {
+		ch.IsEnabled = *req.IsEnabled
+	}
+	if len(req.Config) > 0 {
+		configJSON, err := encodeRawJSON(req.Config, "{}", "config")
+		if err != nil {
+			return nil, err
+		}
+		ch.ConfigJSON = configJSON
+	}
+	ch.UpdatedAt = now
+
+	var err error
+	if req.ID != "" {
+		err = s.publish.UpdateChannel(ctx, ch)
+	} else {
+		err = s.publish.CreateChannel(ctx, ch)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ch.Config = decodeRawJSON(ch.ConfigJSON, "{}")
+	return ch, nil
+}
+
+// ---- Jobs ----
+
+func (s *PublishMetricsService) ListJobs(ctx context.Context, projectID string, status string, limit int) ([]models.PublishJob, error) {
+	out, err := s.publish.ListJobs(ctx, projectID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Payload = decodeRawJSON(out[i].PayloadJSON, "{}")
+	}
+	return out, nil
+}
+
+type CreatePublishJobRequest struct {
+	ProjectID      string          `json:"project_id"`
+	ChannelID      string          `json:"channel_id"`
+	ArtifactID     string          `json:"artifact_id"`
+	RoadmapItemID  string          `json:"roadmap_item_id"`
+	ScheduleAt     int64           `json:"schedule_at"`
+	MaxAttempts    int             `json:"max_attempts"`
+	IdempotencyKey string          `json:"idempotency_key"`
+	Payload        json.RawMessage `json:"payload"`
+}
+
+// CreateJob 创建发布任务；相同 idempotency_key 重复提交时返回已有任务
+func (s *PublishMetricsService) CreateJob(ctx context.Context, req CreatePublishJobRequest) (*models.PublishJob, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	if req.ChannelID == "" {
+		return nil, errors.New("channel_id is required")
+	}
+	key := strings.TrimSpace(req.IdempotencyKey)
+	if key != "" {
+		existing, err := s.publish.GetJobByIdempotencyKey(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			existing.Payload = decodeRawJSON(existing.PayloadJSON, "{}")
+			return existing, nil
+		}
+	} else {
+		key = utils.NewID()
+	}
+
+	if err := s.ensureProject(ctx, req.ProjectID); err != nil {
+		return nil, err
+	}
+	ch, err := s.publish.GetChannel(ctx, req.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil || ch.ProjectID != req.ProjectID {
+		return nil, errors.New("publish channel not found")
+	}
+	if !ch.IsEnabled {
+		return nil, errors.New("publish channel is disabled")
+	}
+	if req.ArtifactID != "" && s.artifacts != nil {
+		a, err := s.artifacts.Get(ctx, req.ArtifactID)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil || a.ProjectID != req.ProjectID {
+			return nil, errors.New("artifact not found")
+		}
+	}
+	var roadmapItemID *string
+	if req.RoadmapItemID != "" && s.roadmap != nil {
+		item, err := s.roadmap.Get(ctx, req.RoadmapItemID)
+		if err != nil {
+			return nil, err
+		}
+		if item == nil || item.ProjectID != req.ProjectID {
+			return nil, errors.New("roadmap item not found")
+		}
+		id := item.ID
+		roadmapItemID = &id
+	}
+	var workflowID *string
+	if s.workflows != nil {
+		wf, err := s.workflows.GetByProject(ctx, req.ProjectID)
+		if err != nil {
+			return nil, err
+		}
+		if wf != nil {
+			id := wf.ID
+			workflowID = &id
+		}
+	}
+	payloadJSON, err := encodeRawJSON(req.Payload, "{}", "payload")
+	if err != nil {
+		return nil, err
+	}
+	maxAttempts := req.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultPublishAttempts
+	}
+
+	now := time.Now().Unix()
+	scheduleAt := req.ScheduleAt
+	if scheduleAt <= 0 {
+		scheduleAt = now
+	}
+	job := &models.PublishJob{
+		ID:             utils.NewID(),
+		ProjectID:      req.ProjectID,
+		WorkflowID:     workflowID,
+		RoadmapItemID:  roadmapItemID,
+		ArtifactID:     req.ArtifactID,
+		ChannelID:      ch.ID,
+		Status:         models.PublishJobPending,
+		ScheduleAt:     scheduleAt,
+		MaxAttempts:    maxAttempts,
+		IdempotencyKey: key,
+		PayloadJSON:    payloadJSON,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.publish.CreateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	job.Payload = decodeRawJSON(job.PayloadJSON, "{}")
+	s.broadcastJob(job, "publish_job_created")
+	if scheduleAt <= now {
+		s.wake()
+	}
+	return job, nil
+}
+
+type UpdatePublishJobStatusRequest struct {
+	ID           string `json:"id"`
+	Status       string `json:"status"`
+	ScheduleAt   int64  `json:"schedule_at"`
+	ErrorMessage string `json:"error_message"`
+}
+
+// UpdateJobStatus 手动变更任务状态：
+// pending（重试/改期）、cancelled、failed、succeeded（已在平台手动发布）。运行中的任务不可修改。
+func (s *PublishMetricsService) UpdateJobStatus(ctx context.Context, req UpdatePublishJobStatusRequest) (*models.PublishJob, error) {
+	if req.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	job, err := s.publish.GetJob(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New("publish job not found")
+	}
+	if job.Status == models.PublishJobRunning {
+		return nil, errors.New("publish job is running")
+	}
+
+	now := time.Now().Unix()
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	switch status {
+	case models.PublishJobPending:
+		if job.Status == models.PublishJobSucceeded {
+			return nil, errors.New("publish job already succeeded")
+		}
+		job.AttemptCount = 0
+		job.ErrorMessage = ""
+		job.FinishedAt = 0
+		job.ScheduleAt = now
+		if req.ScheduleAt > now {
+			job.ScheduleAt = req.ScheduleAt
+		}
+	case models.PublishJobCancelled, models.PublishJobFailed:
+		if job.Status == models.PublishJobSucceeded {
+			return nil, errors.New("publish job already succeeded")
+		}
+		job.FinishedAt = now
+		if req.ErrorMessage != "" {
+			job.ErrorMessage = req.ErrorMessage
+		}
+	case models.PublishJobSucceeded:
+		job.ErrorMessage = ""
+		job.FinishedAt = now
+	default:
+		return nil, errors.New("invalid publish job status")
+	}
+	job.Status = status
+	job.UpdatedAt = now
+	if err := s.publish.UpdateJob(ctx, job); err != nil {
+		return nil, err
+	}
+	if status == models.PublishJobSucceeded {
+		s.completeRoadmapItem(ctx, job)
+	}
+	s.broadcastJob(job, "publish_job_"+status)
+	if status == models.PublishJobPending && job.ScheduleAt <= now {
+		s.wake()
+	}
+	job.Payload = decodeRawJSON(job.PayloadJSON, "{}")
+	return job, nil
+}
+
+// ---- Records ----
+
+func (s *PublishMetricsService) ListRecords(ctx context.Context, projectID string, jobID string, platform string, limit int) ([]models.PublishRecord, error) {
+	if projectID == "" && jobID == "" {
+		return nil, errors.New("project_id or job_id is required")
+	}
+	out, err := s.publish.ListRecords(ctx, projectID, jobID, platform, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].RawResponse = decodeRawJSON(out[i].RawResponseJSON, "{}")
+	}
+	return out, nil
+}
+
+type CreatePublishRecordRequest struct {
+	JobID          string          `json:"job_id"`
+	ExternalPostID string          `json:"external_post_id"`
+	PostURL        string          `json:"post_url"`
+	PublishedAt    int64           `json:"published_at"`
+	RawResponse    json.RawMessage `json:"raw_response"`
+}
+
+// CreateRecord 手动登记发布结果（例如在平台后台手动发布），同时将任务标记为成功
+func (s *PublishMetricsService) CreateRecord(ctx context.Context, req CreatePublishRecordRequest) (*models.PublishRecord, error) {
+	if req.JobID == "" {
+		return nil, errors.New("job_id is required")
+	}
+	if len(req.RawResponse) > 0 && !json.Valid(req.RawResponse) {
+		return nil, errors.New("raw_response must be valid json")
+	}
+	job, err := s.publish.GetJob(ctx, req.JobID)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, errors.New("publish job not found")
+	}
+	if job.Status == models.PublishJobRunning {
+		return nil, errors.New("publish job is running")
+	}
+	ch, err := s.publish.GetChannel(ctx, job.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, errors.New("publish channel not found")
+	}
+	return s.recordPublishSuccess(ctx, job, ch, &PublishAdapterResult{
+		ExternalPostID: strings.TrimSpace(req.ExternalPostID),
+		PostURL:        strings.TrimSpace(req.PostURL),
+		PublishedAt:    req.PublishedAt,
+		RawResponse:    req.RawResponse,
+	})
+}
+
+func (s *PublishMetricsService) ensureProject(ctx context.Context, projectID string) error {
+	if s.projects == nil {
+		return nil
+	}
+	p, err := s.projects.Get(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return errors.New("project not found")
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/db"
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+// testPublishWebhook 记录调用次数，按 status 返回（默认 200）
+type testPublishWebhook struct {
+	*httptest.Server
+	hits   atomic.Int32
+	status atomic.Int32
+}
+
+func newTestPublishWebhook(t *testing.T) *testPublishWebhook {
+	t.Helper()
+	h := &testPublishWebhook{}
+	h.status.Store(http.StatusOK)
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := h.hits.Add(1)
+		w.WriteHeader(int(h.status.Load()))
+		fmt.Fprintf(w, `{"external_post_id":"post-%d"}`, n)
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func newTestPublishService(t *testing.T, webhookURL string) (*PublishMetricsService, *repos.PublishRepo, *db.DB) {
+	t.Helper()
+	d := newTestDB(t)
+	publish := repos.NewPublishRepo(d.ORM())
+	s := NewPublishMetricsService(repos.NewProjectRepo(d.ORM()), nil, nil, nil, publish, nil)
+	cfg, _ := json.Marshal(map[string]any{"webhook_url": webhookURL})
+	now := time.Now().Unix()
+	if err := publish.CreateChannel(context.Background(), &models.PublishChannel{
+		ID: "c1", ProjectID: "p1", Platform: "local", IsEnabled: true, ConfigJSON: string(cfg), CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return s, publish, d
+}
+
+func createDuePublishJob(t *testing.T, publish *repos.PublishRepo, id string) {
+	t.Helper()
+	now := time.Now().Unix()
+	if err := publish.CreateJob(context.Background(), &models.PublishJob{
+		ID: id, IdempotencyKey: "k-" + id, ProjectID: "p1", ChannelID: "c1", Status: models.PublishJobPending,
+		ScheduleAt: now - 1, MaxAttempts: 3, PayloadJSON: "{}", CreatedAt: now, UpdatedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func getPublishJob(t *testing.T, publish *repos.PublishRepo, id string) *models.PublishJob {
+	t.Helper()
+	job, err := publish.GetJob(context.Background(), id)
+	if err != nil || job == nil {
+		t.Fatalf("get job %s: %v", id, err)
+	}
+	return job
+}
+
+func TestPublishRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{7, publishRetryMax},
+		{50, publishRetryMax},
+	}
+	for _, tc := range tests {
+		if got := publishRetryDelay(tc.attempt); got != tc.want {
+			t.Errorf("publishRetryDelay(%d) = %v, want %v", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestPublishMetricsService_FinishJobWithError(t *testing.T) {
+	ctx := context.Background()
+	s, publish, _ := newTestPublishService(t, "http://127.0.0.1:0")
+	tests := []struct {
+		name        string
+		attempt     int
+		maxAttempts int
+		cause       error
+		wantStatus  string
+		wantDelay   time.Duration
+	}{
+		{"retryable first attempt", 1, 3, errors.New("timeout"), models.PublishJobPending, publishRetryBase},
+		{"retryable backs off", 2, 3, errors.New("timeout"), models.PublishJobPending, 2 * publishRetryBase},
+		{"attempts exhausted", 3, 3, errors.New("timeout"), models.PublishJobFailed, 0},
+		{"default max attempts", 2, 0, errors.New("timeout"), models.PublishJobPending, 2 * publishRetryBase},
+		{"default max exhausted", defaultPublishAttempts, 0, errors.New("timeout"), models.PublishJobFailed, 0},
+		{"permanent", 1, 3, PermanentPublishError(errors.New("rejected")), models.PublishJobFailed, 0},
+		{"wrapped permanent", 1, 3, fmt.Errorf("upload: %w", PermanentPublishError(errors.New("rejected"))), models.PublishJobFailed, 0},
+	}
+	for i, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			id := fmt.Sprintf("job%d", i)
+			createDuePublishJob(t, publish, id)
+			job := getPublishJob(t, publish, id)
+			job.Status = models.PublishJobRunning
+			job.AttemptCount = tc.attempt
+			job.MaxAttempts = tc.maxAttempts
+
+			before := time.Now().Unix()
+			s.finishJobWithError(ctx, job, tc.cause)
+			got := getPublishJob(t, publish, id)
+			if got.Status != tc.wantStatus || got.ErrorMessage != tc.cause.Error() {
+				t.Fatalf("job = %s %q, want %s", got.Status, got.ErrorMessage, tc.wantStatus)
+			}
+			if tc.wantStatus == models.PublishJobPending {
+				want := before + int64(tc.wantDelay.Seconds())
+				if got.ScheduleAt < want || got.ScheduleAt > want+1 || got.FinishedAt != 0 {
+					t.Fatalf("schedule_at = %d (finished %d), want ~%d", got.ScheduleAt, got.FinishedAt, want)
+				}
+			} else if got.FinishedAt == 0 {
+				t.Fatal("failed job has no finished_at")
+			}
+		})
+	}
+}
+
+func TestPublishMetricsService_DispatchRetries(t *testing.T) {
+	ctx := context.Background()
+	hook := newTestPublishWebhook(t)
+	s, publish, _ := newTestPublishService(t, hook.URL)
+	createDuePublishJob(t, publish, "job1")
+	hook.status.Store(http.StatusInternalServerError)
+
+	// makeDue 跳过退避等待
+	makeDue := func(id string) {
+		job := getPublishJob(t, publish, id)
+		job.ScheduleAt = time.Now().Unix() - 1
+		if err := publish.UpdateJob(ctx, job); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for attempt := 1; attempt <= 2; attempt++ {
+		before := time.Now().Unix()
+		s.runDueJobs()
+		job := getPublishJob(t, publish, "job1")
+		want := before + int64(publishRetryDelay(attempt).Seconds())
+		if job.Status != models.PublishJobPending || job.AttemptCount != attempt || job.ScheduleAt < want || job.ScheduleAt > want+1 {
+			t.Fatalf("attempt %d: job = %+v, want pending until ~%d", attempt, job, want)
+		}
+		// 退避期内不会再次分发
+		s.runDueJobs()
+		if n := hook.hits.Load(); n != int32(attempt) {
+			t.Fatalf("attempt %d: webhook hit %d times", attempt, n)
+		}
+		makeDue("job1")
+	}
+
+	// 第三次成功：写入记录并标记成功
+	hook.status.Store(http.StatusOK)
+	s.runDueJobs()
+	job := getPublishJob(t, publish, "job1")
+	if job.Status != models.PublishJobSucceeded || job.AttemptCount != 3 || job.ErrorMessage != "" {
+		t.Fatalf("job = %+v", job)
+	}
+	rec, err := publish.GetRecordByJob(ctx, "job1")
+	if err != nil || rec == nil || rec.ExternalPostID != "post-3" {
+		t.Fatalf("record = %+v, %v", rec, err)
+	}
+
+	// 次数耗尽后置为 failed
+	createDuePublishJob(t, publish, "job2")
+	hook.status.Store(http.StatusServiceUnavailable)
+	for i := 0; i < 3; i++ {
+		s.runDueJobs()
+		makeDue("job2")
+	}
+	s.runDueJobs()
+	if job := getPublishJob(t, publish, "job2"); job.Status != models.PublishJobFailed || job.AttemptCount != 3 {
+		t.Fatalf("exhausted job = %+v", job)
+	}
+	if n := hook.hits.Load(); n != 6 {
+		t.Fatalf("webhook hit %d times, want 6", n)
+	}
+}
+
+func TestPublishMetricsService_NeverRepublish(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("record save fails", func(t *testing.T) {
+		hook := newTestPublishWebhook(t)
+		s, publish, d := newTestPublishService(t, hook.URL)
+		createDuePublishJob(t, publish, "job1")
+		if _, err := d.SQL().Exec(`CREATE TRIGGER test_fail_record BEFORE INSERT ON publish_records
+			BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+			t.Fatal(err)
+		}
+		// 关闭 stopChan 跳过记账重试之间的等待
+		close(s.stopChan)
+		if ok, err := publish.ClaimJob(ctx, "job1"); err != nil || !ok {
+			t.Fatalf("claim = %v, %v", ok, err)
+		}
+		s.dispatchJob("job1")
+
+		// 已在平台发布：置为 failed 而不是重新排期，错误里保留帖子信息
+		job := getPublishJob(t, publish, "job1")
+		if job.Status != models.PublishJobFailed || !strings.Contains(job.ErrorMessage, `"post-1"`) {
+			t.Fatalf("job = %s %q", job.Status, job.ErrorMessage)
+		}
+		if ids, err := publish.ListDueJobIDs(ctx, time.Now().Add(publishRetryMax).Unix(), 10); err != nil || len(ids) != 0 {
+			t.Fatalf("due jobs = %v, %v", ids, err)
+		}
+		if n := hook.hits.Load(); n != 1 {
+			t.Fatalf("webhook hit %d times, want 1", n)
+		}
+	})
+
+	t.Run("job not marked after record saved", func(t *testing.T) {
+		hook := newTestPublishWebhook(t)
+		s, publish, d := newTestPublishService(t, hook.URL)
+		createDuePublishJob(t, publish, "job1")
+		if _, err := d.SQL().Exec(`CREATE TRIGGER test_fail_mark BEFORE UPDATE ON publish_jobs
+			WHEN NEW.status = 'succeeded' BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+			t.Fatal(err)
+		}
+		stop := s.stopChan
+		s.stopChan = make(chan struct{})
+		close(s.stopChan)
+		if ok, err := publish.ClaimJob(ctx, "job1"); err != nil || !ok {
+			t.Fatalf("claim = %v, %v", ok, err)
+		}
+		s.dispatchJob("job1")
+		if job := getPublishJob(t, publish, "job1"); job.Status != models.PublishJobRunning {
+			t.Fatalf("job = %s, want running until the lease is reclaimed", job.Status)
+		}
+
+		// 租约回收后再次分发：已有记录，只补记账
+		if _, err := d.SQL().Exec("DROP TRIGGER test_fail_mark"); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := publish.RequeueRunningJobs(ctx, 0); err != nil || n != 1 {
+			t.Fatalf("requeue = %d, %v", n, err)
+		}
+		s.stopChan = stop
+		s.runDueJobs()
+		if job := getPublishJob(t, publish, "job1"); job.Status != models.PublishJobSucceeded {
+			t.Fatalf("job = %s, want succeeded", job.Status)
+		}
+		if n := hook.hits.Load(); n != 1 {
+			t.Fatalf("webhook hit %d times, want 1", n)
+		}
+		var records int
+		if err := d.SQL().QueryRow("SELECT COUNT(*) FROM publish_records WHERE job_id = ?", "job1").Scan(&records); err != nil || records != 1 {
+			t.Fatalf("records = %d, %v", records, err)
+		}
+	})
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+)
+
+const (
+	// scanJobFlushInterval 扫描进度写回数据库的最小间隔
+	scanJobFlushInterval = 2 * time.Second
+	// fullRescanInterval 周期重扫中忽略检查点的间隔：目录 mtime 不反映文件原地修改，需定期全量核对
+	fullRescanInterval = 24 * time.Hour
+)
+
+// errScanInterrupted 服务停止时中断扫描；任务保持 running，下次启动恢复
+var errScanInterrupted = errors.New("scan interrupted")
+
+// SetCheckpointRepos 启用扫描任务持久化与目录检查点；未设置时退化为每次全量扫描
+func (s *ScanService) SetCheckpointRepos(jobs *repos.ScanJobRepo, dirStates *repos.ScanDirStateRepo) {
+	s.scanJobs = jobs
+	s.dirStates = dirStates
+}
+
+// ResumeScanJobs 将上次未完成的扫描任务按原批次重新入队，已完成的目录由检查点跳过
+func (s *ScanService) ResumeScanJobs(ctx context.Context) error {
+	if s.scanJobs == nil {
+		return nil
+	}
+	jobs, err := s.scanJobs.ListInflight(ctx, 500)
+	if err != nil {
+		return err
+	}
+	var order []string
+	batches := map[string][]ScanTask{}
+	for _, job := range jobs {
+		if _, ok := batches[job.BatchID]; !ok {
+			order = append(order, job.BatchID)
+		}
+		batches[job.BatchID] = append(batches[job.BatchID], ScanTask{
+			ProjectID: job.ProjectID,
+			Path:      job.RootPath,
+			Name:      job.Name,
+			Strict:    job.Strict,
+			Full:      job.Full,
+			JobID:     job.ID,
+		})
+	}
+	for _, batchID := range order {
+		select {
+		case s.scanQueue <- batches[batchID]:
+		default:
+			// 队列已满时保留未完成状态，下次启动再恢复
+			return nil
+		}
+	}
+	return nil
+}
+
+// InvalidateCheckpoints 清除 root 下的目录检查点（root 为空表示全部），下次扫描重新索引这些目录。
+// 忽略规则变化后调用
+func (s *ScanService) InvalidateCheckpoints(ctx context.Context, root string) error {
+	if s.dirStates == nil {
+		return nil
+	}
+	_, err := s.dirStates.DeleteUnder(ctx, root)
+	return err
+}
+
+// persistScanJobs 为尚无任务记录的扫描项创建同批次任务
+func (s *ScanService) persistScanJobs(ctx context.Context, tasks []ScanTask) []ScanTask {
+	if s.scanJobs == nil {
+		return tasks
+	}
+	batchID := utils.NewID()
+	for i := range tasks {
+		if tasks[i].JobID != "" {
+			continue
+		}
+		job := &models.ScanJob{
+			BatchID:   batchID,
+			ProjectID: tasks[i].ProjectID,
+			RootPath:  strings.TrimSpace(tasks[i].Path),
+			Name:      tasks[i].Name,
+			Strict:    tasks[i].Strict,
+			Full:      tasks[i].Full,
+		}
+		if err := s.scanJobs.Create(ctx, job); err == nil {
+			tasks[i].JobID = job.ID
+		}
+	}
+	return tasks
+}
+
+func (s *ScanService) failScanJobs(ctx context.Context, tasks []ScanTask, msg string) {
+	if s.scanJobs == nil {
+		return
+	}
+	for _, t := range tasks {
+		if job, err := s.scanJobs.Get(ctx, t.JobID); err == nil && job != nil {
+			_ = s.scanJobs.MarkFailed(ctx, job, msg)
+		}
+	}
+}
+
+// inflightScanRoots 有未完成任务的 (项目, 根目录)，避免与恢复的任务重复入队
+func (s *ScanService) inflightScanRoots(ctx context.Context) map[string]struct{} {
+	out := map[string]struct{}{}
+	if s.scanJobs == nil {
+		return out
+	}
+	jobs, err := s.scanJobs.ListInflight(ctx, 500)
+	if err != nil {
+		return out
+	}
+	for _, job := range jobs {
+		out[job.ProjectID+"\x00"+filepath.Clean(job.RootPath)] = struct{}{}
+	}
+	return out
+}
+
+func (s *ScanService) stopping() bool {
+	select {
+	case <-s.stopChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// canSkipDir 目录自上次完成扫描后 mtime 与条目数均未变化。
+// 全量扫描只跳过本任务已完成的目录（即中断后恢复的部分）
+func (s *ScanService) canSkipDir(ctx context.Context, task ScanTask, dir string, modTime int64, entries int) bool {
+	if s.dirStates == nil {
+		return false
+	}
+	st, err := s.dirStates.Get(ctx, task.ProjectID, dir)
+	if err != nil || st == nil {
+		return false
+	}
+	if st.CompletedAt == 0 || st.ModTime != modTime || st.EntryCount != entries {
+		return false
+	}
+	return !task.Full || (task.JobID != "" && st.JobID == task.JobID)
+}
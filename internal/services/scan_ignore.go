@@ -0,0 +1,619 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+const (
+	// ignoreFileName 目录级忽略规则文件，作用于所在目录及其子目录
+	ignoreFileName = ".orbitignore"
+	// scanIgnoreSettingKey 全局规则存于 system_settings
+	scanIgnoreSettingKey = "scan_ignore_rules"
+	// ignoreDirCacheTTL 目录级规则文件的缓存时间，过期后按 mtime/size 判断是否重新解析
+	ignoreDirCacheTTL = 10 * time.Second
+	// ignoreDirCacheMax 目录规则缓存的条目上限，超出时先清过期项，仍超出则随机淘汰一半
+	ignoreDirCacheMax = 8192
+	// ignoreRootsTTL 来源根目录列表的刷新间隔
+	ignoreRootsTTL    = 30 * time.Second
+	maxIgnoreFileSize = 1 << 20
+)
+
+// ErrPathIgnored 路径命中扫描忽略规则
+var ErrPathIgnored = errors.New("path is excluded by ignore rules")
+
+// 内置规则：原 shouldSkipFile / shouldSkipDir 的硬编码列表，可被全局、来源或目录规则用 "!" 取消
+const defaultIgnoreRules = `
+.*
+node_modules/
+target/
+dist/
+build/
+*.exe
+*.msi
+*.dll
+*.sys
+*.zip
+*.rar
+*.7z
+*.tar
+*.gz
+*.tmp
+*.log
+*.part
+*.bak
+*.iso
+*.dmg
+*.pkg
+*.ini
+*.db
+*.dat
+`
+
+// 严格模式（首次导入）额外排除
+const strictIgnoreRules = `
+*.lnk
+*.url
+*.crdownload
+*.download
+*.partial
+thumbs.db
+desktop.ini
+temp/
+tmp/
+cache/
+$RECYCLE.BIN/
+System Volume Information/
+`
+
+var (
+	defaultIgnoreLayer = parseIgnoreRules(defaultIgnoreRules, "", "default")
+	strictIgnoreLayer  = parseIgnoreRules(strictIgnoreRules, "", "default:strict")
+)
+
+// ignoreRule 一条 gitignore 规则；base 为空时对任意位置的路径生效
+type ignoreRule struct {
+	Pattern string
+	Source  string
+	base    string
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// parseIgnoreRules 解析 gitignore 语法：# 注释、! 取反、结尾 / 仅匹配目录、含 / 时相对 base 锚定、* ? [..] ** 通配。
+// 匹配不区分大小写（素材库多在大小写不敏感的文件系统上）
+func parseIgnoreRules(text string, base string, source string) []ignoreRule {
+	var rules []ignoreRule
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " \t")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{Pattern: line, Source: source, base: base}
+		p := line
+		if strings.HasPrefix(p, "!") {
+			rule.negate = true
+			p = p[1:]
+		} else if strings.HasPrefix(p, `\!`) || strings.HasPrefix(p, `\#`) {
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			rule.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+		if p == "" {
+			continue
+		}
+		anchored := strings.Contains(p, "/")
+		p = strings.TrimPrefix(p, "/")
+		if !anchored {
+			p = "**/" + p
+		}
+		re, err := regexp.Compile("(?i)^" + ignoreGlobToRegexp(p) + "$")
+		if err != nil {
+			continue
+		}
+		rule.re = re
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+func ignoreGlobToRegexp(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case strings.HasPrefix(p[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(p[i:], "/**") && i+3 == len(p):
+			b.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(p[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(p[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := p[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(p):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(p[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match 判断规则是否命中；rel 为相对 base 的 / 分隔路径
+func (r *ignoreRule) match(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel := filepath.ToSlash(path)
+	if r.base != "" {
+		var ok bool
+		if rel, ok = relWithin(r.base, path); !ok {
+			return false
+		}
+	}
+	return r.re.MatchString(strings.TrimPrefix(rel, "/"))
+}
+
+func relWithin(base string, path string) (string, bool) {
+	rel, err := filepath.Rel(base, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// IgnoreMatch 路径的判定结果；Rule 为最后命中的规则（gitignore 语义：后者优先）
+type IgnoreMatch struct {
+	Ignored bool   `json:"ignored"`
+	Rule    string `json:"rule,omitempty"`
+	Source  string `json:"source,omitempty"`
+	Path    string `json:"path,omitempty"` // 命中的是上级目录时为该目录
+}
+
+type ignoreDirRules struct {
+	rules     []ignoreRule
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// ScanIgnore 统一的扫描忽略规则：内置 < 全局（设置）< 库来源 < 各级目录 .orbitignore，后者可用 "!" 覆盖前者。
+// ScanService、WatcherService 与 IndexFile 共用同一实例
+type ScanIgnore struct {
+	settings       *SettingsService
+	librarySources *repos.LibrarySourceRepo
+	projectSources *repos.ProjectSourceRepo
+
+	mu          sync.RWMutex
+	global      []ignoreRule
+	sourceRules map[string][]ignoreRule
+	roots       []string
+	rootsAt     time.Time
+	dirs        map[string]*ignoreDirRules
+	onChange    []func(root string)
+}
+
+func NewScanIgnore(settings *SettingsService, librarySources *repos.LibrarySourceRepo, projectSources *repos.ProjectSourceRepo) *ScanIgnore {
+	x := &ScanIgnore{
+		settings:       settings,
+		librarySources: librarySources,
+		projectSources: projectSources,
+		sourceRules:    map[string][]ignoreRule{},
+		dirs:           map[string]*ignoreDirRules{},
+	}
+	_ = x.Reload(context.Background())
+	return x
+}
+
+// Reload 重新读取全局与来源规则、来源根目录
+func (x *ScanIgnore) Reload(ctx context.Context) error {
+	var global []ignoreRule
+	if x.settings != nil {
+		x.settings.mu.RLock()
+		text := x.settings.Settings.Custom[scanIgnoreSettingKey]
+		x.settings.mu.RUnlock()
+		global = parseIgnoreRules(text, "", "global")
+	}
+
+	sourceRules := map[string][]ignoreRule{}
+	var roots []string
+	if x.librarySources != nil {
+		sources, err := x.librarySources.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, src := range sources {
+			root := filepath.Clean(src.RootPath)
+			roots = append(roots, root)
+			if strings.TrimSpace(src.IgnoreRules) != "" {
+				sourceRules[root] = parseIgnoreRules(src.IgnoreRules, root, "source:"+root)
+			}
+		}
+	}
+	if x.projectSources != nil {
+		paths, err := x.projectSources.ListRootPaths(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			if p = strings.TrimSpace(p); p != "" {
+				roots = append(roots, filepath.Clean(p))
+			}
+		}
+	}
+
+	x.mu.Lock()
+	x.global = global
+	x.sourceRules = sourceRules
+	x.roots = roots
+	x.rootsAt = time.Now()
+	x.mu.Unlock()
+	return nil
+}
+
+// GlobalRules 返回全局规则原文
+func (x *ScanIgnore) GlobalRules() string {
+	if x == nil || x.settings == nil {
+		return ""
+	}
+	x.settings.mu.RLock()
+	defer x.settings.mu.RUnlock()
+	return x.settings.Settings.Custom[scanIgnoreSettingKey]
+}
+
+// SetGlobalRules 保存全局规则并立即生效
+func (x *ScanIgnore) SetGlobalRules(ctx context.Context, text string) error {
+	if x.settings == nil {
+		return errors.New("settings service not available")
+	}
+	if err := x.settings.Update(func(s *SystemSettings) {
+		if s.Custom == nil {
+			s.Custom = map[string]string{}
+		}
+		s.Custom[scanIgnoreSettingKey] = text
+	}); err != nil {
+		return err
+	}
+	if err := x.Reload(ctx); err != nil {
+		return err
+	}
+	x.notifyChange("")
+	return nil
+}
+
+// SetSourceRules 保存库来源级规则并立即生效
+func (x *ScanIgnore) SetSourceRules(ctx context.Context, rootPath string, text string) (*models.LibrarySource, error) {
+	if x.librarySources == nil {
+		return nil, errors.New("library source repo is not available")
+	}
+	src, err := x.librarySources.SetIgnoreRules(ctx, rootPath, text)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, errors.New("library source not found")
+	}
+	if err := x.Reload(ctx); err != nil {
+		return nil, err
+	}
+	x.notifyChange(src.RootPath)
+	return src, nil
+}
+
+// OnChange 注册规则变更回调，root 为受影响的来源根目录（全局规则变更时为空）
+func (x *ScanIgnore) OnChange(fn func(root string)) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.onChange = append(x.onChange, fn)
+}
+
+func (x *ScanIgnore) notifyChange(root string) {
+	x.mu.RLock()
+	fns := append([]func(string){}, x.onChange...)
+	x.mu.RUnlock()
+	for _, fn := range fns {
+		fn(root)
+	}
+}
+
+// refreshRoots 来源增删不经过本服务，按间隔刷新
+func (x *ScanIgnore) refreshRoots() {
+	x.mu.RLock()
+	stale := time.Since(x.rootsAt) > ignoreRootsTTL
+	x.mu.RUnlock()
+	if stale {
+		_ = x.Reload(context.Background())
+	}
+}
+
+// containingRoot 返回包含 path 的最深来源根目录
+func (x *ScanIgnore) containingRoot(path string) string {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	best := ""
+	for _, root := range x.roots {
+		if (path == root || pathWithinRoot(path, root)) && len(root) > len(best) {
+			best = root
+		}
+	}
+	return best
+}
+
+// dirRules 读取（缓存）目录下的 .orbitignore
+func (x *ScanIgnore) dirRules(dir string) []ignoreRule {
+	now := time.Now()
+	x.mu.RLock()
+	cached := x.dirs[dir]
+	x.mu.RUnlock()
+	if cached != nil && now.Sub(cached.checkedAt) < ignoreDirCacheTTL {
+		return cached.rules
+	}
+
+	file := filepath.Join(dir, ignoreFileName)
+	info, err := os.Stat(file)
+	entry := &ignoreDirRules{checkedAt: now}
+	if err == nil && !info.IsDir() && info.Size() <= maxIgnoreFileSize {
+		entry.modTime = info.ModTime()
+		entry.size = info.Size()
+		if cached != nil && cached.modTime.Equal(entry.modTime) && cached.size == entry.size {
+			entry.rules = cached.rules
+		} else if data, err := os.ReadFile(file); err == nil {
+			entry.rules = parseIgnoreRules(string(data), dir, file)
+		}
+	}
+	x.mu.Lock()
+	if _, ok := x.dirs[dir]; !ok && len(x.dirs) >= ignoreDirCacheMax {
+		x.evictDirsLocked(now)
+	}
+	x.dirs[dir] = entry
+	x.mu.Unlock()
+	return entry.rules
+}
+
+// evictDirsLocked 缓存已满：丢弃过期项；全部未过期（大范围遍历中）时丢弃约一半
+func (x *ScanIgnore) evictDirsLocked(now time.Time) {
+	for dir, e := range x.dirs {
+		if now.Sub(e.checkedAt) >= ignoreDirCacheTTL {
+			delete(x.dirs, dir)
+		}
+	}
+	if len(x.dirs) < ignoreDirCacheMax {
+		return
+	}
+	drop := len(x.dirs) / 2
+	for dir := range x.dirs {
+		if drop == 0 {
+			break
+		}
+		delete(x.dirs, dir)
+		drop--
+	}
+}
+
+// layers 按优先级从低到高返回作用于 path 的规则；extra 作为额外的来源级规则（预览用）
+func (x *ScanIgnore) layers(path string, strict bool, extra []ignoreRule) [][]ignoreRule {
+	out := [][]ignoreRule{defaultIgnoreLayer}
+	if strict {
+		out = append(out, strictIgnoreLayer)
+	}
+	if x == nil {
+		return append(out, extra)
+	}
+	x.mu.RLock()
+	out = append(out, x.global)
+	roots := make([]string, 0, 2)
+	for root := range x.sourceRules {
+		if pathWithinRoot(path, root) {
+			roots = append(roots, root)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return len(roots[i]) < len(roots[j]) })
+	for _, root := range roots {
+		out = append(out, x.sourceRules[root])
+	}
+	x.mu.RUnlock()
+	out = append(out, extra)
+
+	// 目录级规则：从文件系统根到父目录逐级叠加（有来源时从来源根开始）
+	var dirs []string
+	top := x.containingRoot(path)
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if top != "" && !pathWithinRoot(dir, top) {
+			break
+		}
+		dirs = append(dirs, dir)
+		if dir == top || filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if rules := x.dirRules(dirs[i]); len(rules) > 0 {
+			out = append(out, rules)
+		}
+	}
+	return out
+}
+
+func (x *ScanIgnore) matchEntry(path string, isDir bool, strict bool, extra []ignoreRule) IgnoreMatch {
+	var m IgnoreMatch
+	for _, layer := range x.layers(path, strict, extra) {
+		for i := range layer {
+			if layer[i].match(path, isDir) {
+				m = IgnoreMatch{Ignored: !layer[i].negate, Rule: layer[i].Pattern, Source: layer[i].Source}
+			}
+		}
+	}
+	if m.Ignored {
+		m.Path = path
+	}
+	return m
+}
+
+// ShouldSkip 只判断路径本身（遍历时上级目录已判断过），用于 WalkDir 回调
+func (x *ScanIgnore) ShouldSkip(path string, isDir bool, strict bool) bool {
+	if x != nil {
+		x.refreshRoots()
+	}
+	return x.matchEntry(filepath.Clean(path), isDir, strict, nil).Ignored
+}
+
+// Match 完整判断：来源根目录以下的各级上级目录被忽略时，其中的文件同样被忽略
+func (x *ScanIgnore) Match(path string, isDir bool, strict bool) IgnoreMatch {
+	path = filepath.Clean(path)
+	if x != nil {
+		x.refreshRoots()
+	}
+	top := ""
+	if x != nil {
+		top = x.containingRoot(path)
+	}
+	if top != "" && top != path {
+		rel, _ := relWithin(top, path)
+		parts := strings.Split(rel, "/")
+		dir := top
+		for _, part := range parts[:len(parts)-1] {
+			dir = filepath.Join(dir, part)
+			if m := x.matchEntry(dir, true, strict, nil); m.Ignored {
+				return m
+			}
+		}
+	}
+	return x.matchEntry(path, isDir, strict, nil)
+}
+
+// IgnorePreview 规则预览结果
+type IgnorePreview struct {
+	Root         string        `json:"root"`
+	ScannedFiles int           `json:"scanned_files"`
+	ScannedDirs  int           `json:"scanned_dirs"`
+	IgnoredFiles int           `json:"ignored_files"`
+	IgnoredDirs  int           `json:"ignored_dirs"`
+	Entries      []IgnoreMatch `json:"entries"`
+	Truncated    bool          `json:"truncated"`
+}
+
+type IgnorePreviewRequest struct {
+	Root   string `json:"root"`
+	Rules  string `json:"rules"` // 作为该目录的来源级规则试用；为空时按当前生效规则预览
+	Strict bool   `json:"strict"`
+	Limit  int    `json:"limit"`
+}
+
+const (
+	defaultIgnorePreviewLimit = 500
+	maxIgnorePreviewLimit     = 5000
+	// ignorePreviewMaxEntries 遍历的条目上限，避免对整盘预览
+	ignorePreviewMaxEntries = 200000
+)
+
+// Preview 遍历 root，列出规则会跳过的路径（被跳过的目录不再深入）
+func (x *ScanIgnore) Preview(ctx context.Context, req IgnorePreviewRequest) (*IgnorePreview, error) {
+	root := strings.TrimSpace(req.Root)
+	if root == "" {
+		return nil, errors.New("root is required")
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	root = filepath.Clean(root)
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New("root is not a directory")
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultIgnorePreviewLimit
+	}
+	if limit > maxIgnorePreviewLimit {
+		limit = maxIgnorePreviewLimit
+	}
+	extra := parseIgnoreRules(req.Rules, root, "preview")
+
+	res := &IgnorePreview{Root: root, Entries: []IgnoreMatch{}}
+	seen := 0
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		seen++
+		if seen > ignorePreviewMaxEntries {
+			res.Truncated = true
+			return fs.SkipAll
+		}
+		isDir := d.IsDir()
+		if isDir {
+			res.ScannedDirs++
+		} else {
+			res.ScannedFiles++
+		}
+		m := x.matchEntry(path, isDir, req.Strict, extra)
+		if !m.Ignored {
+			return nil
+		}
+		if isDir {
+			res.IgnoredDirs++
+		} else {
+			res.IgnoredFiles++
+		}
+		if len(res.Entries) < limit {
+			res.Entries = append(res.Entries, m)
+		} else {
+			res.Truncated = true
+		}
+		if isDir {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRules_Match(t *testing.T) {
+	base := filepath.FromSlash("/lib")
+	tests := []struct {
+		name  string
+		rules string
+		path  string // 相对 base
+		isDir bool
+		want  bool
+	}{
+		{"basename anywhere", "*.tmp", "a/b/c.tmp", false, true},
+		{"case insensitive", "*.TMP", "c.tmp", false, true},
+		{"no match", "*.tmp", "c.mov", false, false},
+		{"dir only skips files", "cache/", "cache", false, false},
+		{"dir only matches dirs", "cache/", "x/cache", true, true},
+		{"anchored", "/renders", "renders", true, true},
+		{"anchored not nested", "/renders", "a/renders", true, false},
+		{"slash anchors to base", "a/*.mov", "a/x.mov", false, true},
+		{"slash anchors not nested", "a/*.mov", "b/a/x.mov", false, false},
+		{"single star stays in segment", "a/*.mov", "a/b/x.mov", false, false},
+		{"double star any depth", "a/**/x.mov", "a/b/c/x.mov", false, true},
+		{"double star zero depth", "a/**/x.mov", "a/x.mov", false, true},
+		{"trailing double star", "proxy/**", "proxy/a/b.mov", false, true},
+		{"question mark", "take?.wav", "take1.wav", false, true},
+		{"char class", "take[0-2].wav", "take3.wav", false, false},
+		{"negated class", "take[!0-2].wav", "take3.wav", false, true},
+		{"negation wins when later", "*.mov\n!keep.mov", "keep.mov", false, false},
+		{"earlier negation overridden", "!keep.mov\n*.mov", "keep.mov", false, true},
+		{"comment and blank", "# *.mov\n\n", "x.mov", false, false},
+		{"escaped hash", `\#notes.txt`, "#notes.txt", false, true},
+		{"escaped bang", `\!important.txt`, "!important.txt", false, true},
+		{"outside base", "*.mov", "../x.mov", false, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules := parseIgnoreRules(tc.rules, base, "test")
+			path := filepath.Join(base, filepath.FromSlash(tc.path))
+			got := false
+			for i := range rules {
+				if rules[i].match(path, tc.isDir) {
+					got = !rules[i].negate
+				}
+			}
+			if got != tc.want {
+				t.Fatalf("rules %q on %s (dir=%v) = %v, want %v", tc.rules, tc.path, tc.isDir, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestScanIgnore_Layers(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(rel string) string {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(p, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	write := func(rel, content string) {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(rel)), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mkdir("shoot/proxy")
+	mkdir("shoot/logs")
+	write(".orbitignore", "proxy/\n")
+	write("shoot/.orbitignore", "!*.log\n")
+
+	x := NewScanIgnore(nil, nil, nil)
+	x.roots = []string{root}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"shoot/a.mov", false, false},
+		{"shoot/a.tmp", false, true},              // 内置规则
+		{"shoot/proxy", true, true},               // 根目录 .orbitignore
+		{"shoot/proxy/a.mov", false, true},        // 上级目录被忽略
+		{"shoot/render.log", false, false},        // 子目录用 ! 取消内置 *.log
+		{"other.log", false, true},                // 取消只作用于子目录
+		{"shoot/.hidden", false, true},            // 内置 .*
+		{"shoot/logs/cam.log", false, false},      // 规则继承到更深目录
+		{"shoot/proxy/sub/deep.wav", false, true}, // 多级
+		{"shoot/thumbs.db", false, true},          // 内置 *.db
+		{"shoot/desktop.ini.mov", false, false},   // 扩展名需完整匹配
+		{"shoot/temp", true, false},               // 仅严格模式
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			m := x.Match(filepath.Join(root, filepath.FromSlash(tc.path)), tc.isDir, false)
+			if m.Ignored != tc.want {
+				t.Fatalf("Match(%s) = %+v, want ignored=%v", tc.path, m, tc.want)
+			}
+		})
+	}
+	if !x.Match(filepath.Join(root, "shoot", "temp"), true, true).Ignored {
+		t.Fatal("strict mode should ignore temp/")
+	}
+}
+
+func TestScanIgnore_DirCacheBounded(t *testing.T) {
+	x := NewScanIgnore(nil, nil, nil)
+	base := t.TempDir()
+	for i := 0; i < ignoreDirCacheMax+100; i++ {
+		x.dirRules(filepath.Join(base, fmt.Sprintf("d%d", i)))
+	}
+	if n := len(x.dirs); n > ignoreDirCacheMax {
+		t.Fatalf("dir cache holds %d entries, limit %d", n, ignoreDirCacheMax)
+	}
+}
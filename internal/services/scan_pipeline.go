@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+const (
+	// scanWalkWorkers 目录枚举并发，同时受设备并发上限约束
+	scanWalkWorkers = 4
+	// scanFilterWorkers stat / 忽略规则过滤并发
+	scanFilterWorkers = 2
+)
+
+// scanDirQueue 无界目录队列；pending 归零（所有目录都已处理）时 pop 返回 false
+type scanDirQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []string
+	pending int
+}
+
+func newScanDirQueue() *scanDirQueue {
+	q := &scanDirQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+func (q *scanDirQueue) push(dir string) {
+	q.mu.Lock()
+	q.items = append(q.items, dir)
+	q.pending++
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+func (q *scanDirQueue) pop() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && q.pending > 0 {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		return "", false
+	}
+	dir := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return dir, true
+}
+
+func (q *scanDirQueue) done() {
+	q.mu.Lock()
+	q.pending--
+	last := q.pending == 0
+	q.mu.Unlock()
+	if last {
+		q.cond.Broadcast()
+	}
+}
+
+// scanDirNode 一个目录的待处理文件计数；归零后写检查点
+type scanDirNode struct {
+	dir     string
+	modTime int64
+	entries int
+	skipped bool
+	pending atomic.Int64
+	// failed 有文件索引失败：目录照常完成计数，但不写检查点，下次扫描重新处理
+	failed atomic.Bool
+}
+
+type scanFileItem struct {
+	path string
+	node *scanDirNode
+}
+
+// scanTree 以流水线扫描 task.Path：目录枚举 → stat/过滤 → 索引。
+// 索引并发由所在设备的 deviceThrottle 自适应控制；目录内文件全部索引完成后才写检查点，
+// 中断时未完成的目录不落检查点，恢复后重新处理
+func (s *ScanService) scanTree(ctx context.Context, idx int, task ScanTask, job *models.ScanJob) (int, error) {
+	maxWorkers := s.resolveScanConcurrency(ctx, task.Path)
+	throttle := s.throttleFor(task.Path, maxWorkers)
+
+	var (
+		statsMu     sync.Mutex
+		count       int
+		lastFlush   = time.Now()
+		interrupted atomic.Bool
+	)
+	s.updateDir(idx, func(p *ImportDirectoryProgress) {
+		p.Workers = throttle.current()
+	})
+
+	completeDir := func(node *scanDirNode) {
+		if !node.skipped && !node.failed.Load() && s.dirStates != nil {
+			jobID := ""
+			if job != nil {
+				jobID = job.ID
+			}
+			_ = s.dirStates.Upsert(ctx, &models.ScanDirState{
+				ProjectID:   task.ProjectID,
+				DirPath:     node.dir,
+				ModTime:     node.modTime,
+				EntryCount:  node.entries,
+				JobID:       jobID,
+				CompletedAt: time.Now().Unix(),
+			})
+		}
+		if job == nil || s.scanJobs == nil {
+			return
+		}
+		statsMu.Lock()
+		defer statsMu.Unlock()
+		if node.skipped {
+			job.SkippedDirs++
+		} else {
+			job.ScannedDirs++
+		}
+		job.CurrentDir = node.dir
+		if time.Since(lastFlush) >= scanJobFlushInterval {
+			lastFlush = time.Now()
+			_ = s.scanJobs.UpdateProgress(ctx, job)
+		}
+	}
+	finish := func(node *scanDirNode) {
+		if node.pending.Add(-1) == 0 && !interrupted.Load() {
+			completeDir(node)
+		}
+	}
+
+	queue := newScanDirQueue()
+	queue.push(task.Path)
+	files := make(chan scanFileItem, 256)
+	toIndex := make(chan scanFileItem, 64)
+
+	// 1. 目录枚举
+	var walkWG sync.WaitGroup
+	for i := 0; i < min(scanWalkWorkers, maxWorkers); i++ {
+		walkWG.Add(1)
+		go func() {
+			defer walkWG.Done()
+			for {
+				dir, ok := queue.pop()
+				if !ok {
+					return
+				}
+				if s.stopping() {
+					interrupted.Store(true)
+				} else {
+					s.enumerateDir(ctx, task, dir, queue, files, finish)
+				}
+				queue.done()
+			}
+		}()
+	}
+
+	// 2. stat / 过滤
+	var filterWG sync.WaitGroup
+	for i := 0; i < scanFilterWorkers; i++ {
+		filterWG.Add(1)
+		go func() {
+			defer filterWG.Done()
+			for item := range files {
+				if interrupted.Load() || s.stopping() {
+					// 丢弃且不计数：所在目录不会写检查点
+					interrupted.Store(true)
+					continue
+				}
+				info, err := os.Lstat(item.path)
+				if err != nil || info.IsDir() || s.shouldSkipFile(item.path, task.Strict) {
+					finish(item.node)
+					continue
+				}
+				toIndex <- item
+			}
+		}()
+	}
+
+	// 3. 索引
+	var indexWG sync.WaitGroup
+	for i := 0; i < maxWorkers; i++ {
+		indexWG.Add(1)
+		go func() {
+			defer indexWG.Done()
+			for item := range toIndex {
+				if interrupted.Load() {
+					continue
+				}
+				throttle.acquire()
+				start := time.Now()
+				_, err := s.assetService.IndexFile(ctx, IndexFileRequest{
+					Path:      item.path,
+					ProjectID: task.ProjectID,
+					Trigger:   "scan",
+				})
+				throttle.release(time.Since(start))
+				if err != nil && !errors.Is(err, ErrPathIgnored) {
+					item.node.failed.Store(true)
+				}
+
+				statsMu.Lock()
+				count++
+				n := count
+				if job != nil {
+					job.IndexedFiles++
+				}
+				statsMu.Unlock()
+				if n%10 == 0 {
+					workers := throttle.current()
+					s.updateDir(idx, func(p *ImportDirectoryProgress) {
+						p.Progress = n
+						p.Workers = workers
+					})
+				}
+				finish(item.node)
+			}
+		}()
+	}
+
+	walkWG.Wait()
+	close(files)
+	filterWG.Wait()
+	close(toIndex)
+	indexWG.Wait()
+
+	if interrupted.Load() {
+		return count, errScanInterrupted
+	}
+	return count, nil
+}
+
+// enumerateDir 读取目录：子目录入队，文件（目录未变化时跳过）送入过滤阶段
+func (s *ScanService) enumerateDir(ctx context.Context, task ScanTask, dir string, queue *scanDirQueue, files chan<- scanFileItem, finish func(*scanDirNode)) {
+	// mtime 取在读目录之前：扫描过程中目录再有变化，下次会因 mtime 不同而重扫
+	info, err := os.Stat(dir)
+	if err != nil {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	node := &scanDirNode{
+		dir:     dir,
+		modTime: info.ModTime().UnixNano(),
+		entries: len(entries),
+	}
+	node.skipped = s.canSkipDir(ctx, task, dir, node.modTime, node.entries)
+	// 枚举期间持有一个计数，防止文件先于枚举结束全部完成
+	node.pending.Store(1)
+	for _, e := range entries {
+		p := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			if !s.shouldSkipDir(p, task.Strict) {
+				queue.push(p)
+			}
+			continue
+		}
+		if node.skipped {
+			continue
+		}
+		node.pending.Add(1)
+		files <- scanFileItem{path: p, node: node}
+	}
+	finish(node)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/db"
+	"media-assistant-os/internal/repos"
+)
+
+func TestScanDirQueue_LastDoneWakesBlockedPops(t *testing.T) {
+	q := newScanDirQueue()
+	q.push("/root")
+	if dir, ok := q.pop(); !ok || dir != "/root" {
+		t.Fatalf("pop = %q, %v", dir, ok)
+	}
+
+	// 队列已空但 /root 仍在处理：其余 pop 必须等待而不是提前退出
+	const waiters = 4
+	results := make(chan bool, waiters)
+	for i := 0; i < waiters; i++ {
+		go func() {
+			_, ok := q.pop()
+			results <- ok
+		}()
+	}
+	select {
+	case <-results:
+		t.Fatal("pop returned while a directory was still pending")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	q.done()
+	for i := 0; i < waiters; i++ {
+		select {
+		case ok := <-results:
+			if ok {
+				t.Fatal("pop returned an item from an empty queue")
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("only %d of %d blocked pops returned after the last done", i, waiters)
+		}
+	}
+}
+
+func TestScanDirQueue_Tree(t *testing.T) {
+	// 每个目录产生 fanout 个子目录，直到 depth 层
+	const fanout, depth = 3, 4
+	q := newScanDirQueue()
+	q.push("0")
+	var processed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				dir, ok := q.pop()
+				if !ok {
+					return
+				}
+				processed.Add(1)
+				if len(dir) < depth {
+					for c := 0; c < fanout; c++ {
+						q.push(dir + fmt.Sprint(c))
+					}
+				}
+				q.done()
+			}
+		}()
+	}
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("workers did not terminate")
+	}
+	// 1 + 3 + 9 + 27
+	if got := processed.Load(); got != 40 {
+		t.Fatalf("processed %d dirs, want 40", got)
+	}
+}
+
+func newTestScanService(t *testing.T) (*ScanService, *db.DB) {
+	t.Helper()
+	d := newTestDB(t)
+	orm := d.ORM()
+	assets := NewAssetService(repos.NewAssetRepo(orm), nil, nil, repos.NewProjectAssetRepo(orm), nil, nil, nil, nil, nil, nil)
+	return &ScanService{
+		assetService: assets,
+		stopChan:     make(chan struct{}),
+		dirStates:    repos.NewScanDirStateRepo(orm),
+	}, d
+}
+
+// writeScanTree 在 root 下创建文件（含中间目录）
+func writeScanTree(t *testing.T, root string, files []string) {
+	t.Helper()
+	for i, rel := range files {
+		p := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(fmt.Sprintf("file %d", i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func scanCheckpoints(t *testing.T, d *db.DB) map[string]bool {
+	t.Helper()
+	rows, err := d.SQL().Query("SELECT dir_path FROM scan_dir_states WHERE completed_at > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	out := map[string]bool{}
+	for rows.Next() {
+		var dir string
+		if err := rows.Scan(&dir); err != nil {
+			t.Fatal(err)
+		}
+		out[dir] = true
+	}
+	return out
+}
+
+func TestScanService_ScanTree(t *testing.T) {
+	ctx := context.Background()
+	s, d := newTestScanService(t)
+	root := t.TempDir()
+	writeScanTree(t, root, []string{
+		"a.jpg", "b.png",
+		"sub/c.jpg",
+		"sub/deep/d.jpg", "sub/deep/e.jpg",
+		"bad/f.jpg",
+		"empty/.keep",
+	})
+	// bad/ 下的文件写入失败：该目录不能落检查点
+	if _, err := d.SQL().Exec(`CREATE TRIGGER test_fail_bad BEFORE INSERT ON assets
+		WHEN NEW.path LIKE '%/bad/%' BEGIN SELECT RAISE(ABORT, 'boom'); END`); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := s.scanTree(ctx, -1, ScanTask{Path: root}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths, distinct int
+	if err := d.SQL().QueryRow("SELECT COUNT(*), COUNT(DISTINCT path) FROM assets").Scan(&paths, &distinct); err != nil {
+		t.Fatal(err)
+	}
+	// empty/.keep 被内置规则忽略
+	if paths != distinct || paths != 5 {
+		t.Fatalf("assets = %d (%d distinct), want every file indexed exactly once", paths, distinct)
+	}
+	if n != paths+1 {
+		t.Fatalf("scanTree indexed %d files, want %d (including the failed one)", n, paths+1)
+	}
+
+	got := scanCheckpoints(t, d)
+	for _, dir := range []string{root, filepath.Join(root, "sub"), filepath.Join(root, "sub", "deep"), filepath.Join(root, "empty")} {
+		if !got[dir] {
+			t.Errorf("missing checkpoint for %s", dir)
+		}
+	}
+	if got[filepath.Join(root, "bad")] {
+		t.Error("directory with a failed file was checkpointed")
+	}
+
+	// 再次扫描只重新处理没有检查点的目录
+	n, err = s.scanTree(ctx, -1, ScanTask{Path: root}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("rescan indexed %d files, want only bad/f.jpg", n)
+	}
+}
+
+func TestScanService_ScanTreeInterrupted(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stopped before start", func(t *testing.T) {
+		s, d := newTestScanService(t)
+		root := t.TempDir()
+		writeScanTree(t, root, []string{"a.jpg", "sub/b.jpg"})
+		close(s.stopChan)
+		n, err := s.scanTree(ctx, -1, ScanTask{Path: root}, nil)
+		if !errors.Is(err, errScanInterrupted) || n != 0 {
+			t.Fatalf("scanTree = %d, %v; want 0, errScanInterrupted", n, err)
+		}
+		if got := scanCheckpoints(t, d); len(got) != 0 {
+			t.Fatalf("interrupted scan wrote checkpoints: %v", got)
+		}
+	})
+
+	t.Run("stopped mid scan", func(t *testing.T) {
+		s, d := newTestScanService(t)
+		root := t.TempDir()
+		var files []string
+		for dir := 0; dir < 20; dir++ {
+			for f := 0; f < 20; f++ {
+				files = append(files, fmt.Sprintf("d%02d/f%02d.jpg", dir, f))
+			}
+		}
+		writeScanTree(t, root, files)
+		go func() {
+			for {
+				var n int
+				err := d.SQL().QueryRow("SELECT COUNT(*) FROM assets").Scan(&n)
+				if err != nil || n > 0 {
+					close(s.stopChan)
+					return
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}()
+		if _, err := s.scanTree(ctx, -1, ScanTask{Path: root}, nil); err != nil && !errors.Is(err, errScanInterrupted) {
+			t.Fatal(err)
+		}
+		// 被中断时写下的检查点只能属于已全部索引的目录
+		for dir := range scanCheckpoints(t, d) {
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range entries {
+				if e.IsDir() {
+					continue
+				}
+				var n int
+				if err := d.SQL().QueryRow("SELECT COUNT(*) FROM assets WHERE path = ?", filepath.Join(dir, e.Name())).Scan(&n); err != nil {
+					t.Fatal(err)
+				}
+				if n != 1 {
+					t.Fatalf("checkpointed %s but %s was not indexed", dir, e.Name())
+				}
+			}
+		}
+	})
+}
@@ -126,3 +126,8 @@ func (s *ScanService) shouldRunHeavyTasks() bool {
 	}
 	return s.policy.shouldRunHeavyTasks()
 }
+
+// HeavyTasksAllowed 当前是否允许运行重负载任务（供任务队列闸门使用）
+func (s *ScanService) HeavyTasksAllowed() bool {
+	return s.shouldRunHeavyTasks()
+}
@@ -50,7 +50,11 @@ func (s *ScanService) runPeriodicRescan() {
 			if isRunning {
 				continue
 			}
-			s.rescanAllProjects(context.Background())
+			full := time.Since(s.lastFullRescan) >= fullRescanInterval
+			if full {
+				s.lastFullRescan = time.Now()
+			}
+			s.rescanAllProjects(context.Background(), full)
 		case <-s.stopChan:
 			return
 		}
@@ -80,6 +84,10 @@ func (s *ScanService) reconcileProject(ctx context.Context, project models.Proje
 		}
 		_, statErr := os.Stat(asset.Path)
 		if statErr != nil {
+			// 卷离线：保留现有状态，重新挂载后即可恢复
+			if s.assetService.volumes.IsOffline(asset.Path) {
+				continue
+			}
 			if os.IsNotExist(statErr) && asset.Status != "MISSING" {
 				_ = s.assetService.assets.UpdateStatusWithLog(ctx, asset.ID, "MISSING", utils.FormatNow()+": File not found on disk")
 				s.assetService.recordHistoryEvent(ctx, repos.CreateAssetHistoryEventInput{
@@ -104,11 +112,14 @@ func (s *ScanService) reconcileProject(ctx context.Context, project models.Proje
 	return nil
 }
 
-func (s *ScanService) rescanAllProjects(ctx context.Context) {
+// rescanAllProjects 重扫全部项目根目录；full 为 false 时按目录检查点增量扫描，
+// 已有未完成任务（待恢复）的根目录不重复入队
+func (s *ScanService) rescanAllProjects(ctx context.Context, full bool) {
 	projects, err := s.projectRepo.ListByActivity(ctx)
 	if err != nil {
 		return
 	}
+	inflight := s.inflightScanRoots(ctx)
 	tasks := make([]ScanTask, 0, len(projects))
 	for _, project := range projects {
 		roots, _ := s.resolveProjectRoots(ctx, project)
@@ -116,11 +127,15 @@ func (s *ScanService) rescanAllProjects(ctx context.Context) {
 			continue
 		}
 		for _, root := range roots {
+			if _, ok := inflight[project.ID+"\x00"+filepath.Clean(root)]; ok {
+				continue
+			}
 			tasks = append(tasks, ScanTask{
 				ProjectID: project.ID,
 				Path:      root,
 				Name:      project.Name + ":" + filepath.Base(root),
 				Strict:    false,
+				Full:      full,
 			})
 		}
 	}
@@ -18,11 +18,16 @@ const (
 	periodicRescanInterval = 6 * time.Hour
 )
 
+// ErrScanQueueFull 扫描队列已满，调用方可稍后重试
+var ErrScanQueueFull = errors.New("scan queue is full")
+
 type ScanTask struct {
 	ProjectID string
 	Path      string
 	Name      string
 	Strict    bool
+	Full      bool   // 忽略目录检查点，重新索引全部文件
+	JobID     string // 持久化的扫描任务，恢复时沿用
 }
 
 type ImportDirectoryProgress struct {
@@ -30,6 +35,7 @@ type ImportDirectoryProgress struct {
 	Path     string `json:"path"`
 	Progress int    `json:"progress"`
 	Status   string `json:"status"`
+	Workers  int    `json:"workers,omitempty"` // 当前自适应索引并发
 }
 
 type ImportProgress struct {
@@ -54,6 +60,12 @@ type ScanService struct {
 	mu                sync.Mutex
 	progress          ImportProgress
 	policy            *scanSystemPolicy
+	scanJobs          *repos.ScanJobRepo
+	dirStates         *repos.ScanDirStateRepo
+	lastFullRescan    time.Time
+	librarySources    *repos.LibrarySourceRepo
+	throttleMu        sync.Mutex
+	throttles         map[string]*deviceThrottle
 }
 
 func NewScanService(assetService *AssetService, projectRepo *repos.ProjectRepo, projectSourceRepo *repos.ProjectSourceRepo, eventHub *EventHub) *ScanService {
@@ -65,6 +77,7 @@ func NewScanService(assetService *AssetService, projectRepo *repos.ProjectRepo,
 		eventHub:          eventHub,
 		stopChan:          make(chan struct{}),
 		policy:            newScanSystemPolicy(),
+		lastFullRescan:    time.Now(),
 	}
 	go s.processQueue()
 
@@ -79,7 +92,7 @@ func (s *ScanService) StartStartupScan(ctx context.Context) {
 	go func() {
 		// Wait for system to settle down
 		time.Sleep(3 * time.Second)
-		s.rescanAllProjects(ctx)
+		s.rescanAllProjects(ctx, false)
 	}()
 }
 
@@ -126,11 +139,13 @@ func (s *ScanService) processQueue() {
 }
 
 func (s *ScanService) ScanMultipleProjects(ctx context.Context, tasks []ScanTask) error {
+	tasks = s.persistScanJobs(ctx, tasks)
 	select {
 	case s.scanQueue <- tasks:
 		return nil
 	default:
-		return errors.New("scan queue is full")
+		s.failScanJobs(ctx, tasks, ErrScanQueueFull.Error())
+		return ErrScanQueueFull
 	}
 }
 
@@ -146,11 +161,28 @@ func (s *ScanService) runBatchScan(ctx context.Context, tasks []ScanTask) {
 
 	for idx, task := range tasks {
 		task.Path = strings.TrimSpace(task.Path)
+		var job *models.ScanJob
+		if s.scanJobs != nil && task.JobID != "" {
+			job, _ = s.scanJobs.Get(ctx, task.JobID)
+		}
 		if task.Path == "" {
 			s.updateDir(idx, func(p *ImportDirectoryProgress) {
 				p.Status = "跳过"
 				p.Progress = 0
 			})
+			if job != nil {
+				_ = s.scanJobs.MarkFailed(ctx, job, "path is empty")
+			}
+			continue
+		}
+		if s.assetService.volumes.IsOffline(task.Path) {
+			s.updateDir(idx, func(p *ImportDirectoryProgress) {
+				p.Status = "卷离线"
+				p.Progress = 0
+			})
+			if job != nil {
+				_ = s.scanJobs.MarkFailed(ctx, job, "volume offline")
+			}
 			continue
 		}
 		if info, err := os.Stat(task.Path); err != nil || !info.IsDir() {
@@ -158,46 +190,31 @@ func (s *ScanService) runBatchScan(ctx context.Context, tasks []ScanTask) {
 				p.Status = "路径不可用"
 				p.Progress = 0
 			})
+			if job != nil {
+				_ = s.scanJobs.MarkFailed(ctx, job, "path unavailable")
+			}
 			continue
 		}
+		task.Path = filepath.Clean(task.Path)
 
 		s.updateDir(idx, func(p *ImportDirectoryProgress) {
 			p.Status = "扫描中"
 			p.Progress = 0
 		})
+		if job != nil {
+			_ = s.scanJobs.MarkRunning(ctx, job.ID)
+		}
 
-		count := 0
-		_ = filepath.WalkDir(task.Path, func(filePath string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return nil
-			}
-			if d.IsDir() {
-				name := d.Name()
-				if s.shouldSkipDir(name, task.Strict) {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-
-			if s.shouldSkipFileWithPolicy(d.Name(), task.Strict) {
-				return nil
-			}
-
-			// Use IndexFile instead of full IndexFile
-			_, _ = s.assetService.IndexFile(ctx, IndexFileRequest{
-				Path:      filePath,
-				ProjectID: task.ProjectID,
-				Trigger:   "scan",
-			})
-			count++
-
-			if count%10 == 0 {
-				s.updateDir(idx, func(p *ImportDirectoryProgress) {
-					p.Progress = count
-				})
+		count, err := s.scanTree(ctx, idx, task, job)
+		if errors.Is(err, errScanInterrupted) {
+			if job != nil {
+				_ = s.scanJobs.UpdateProgress(ctx, job)
 			}
-			return nil
-		})
+			return
+		}
+		if job != nil {
+			_ = s.scanJobs.MarkSucceeded(ctx, job)
+		}
 
 		s.updateDir(idx, func(p *ImportDirectoryProgress) {
 			p.Status = "完成"
@@ -247,12 +264,7 @@ func (s *ScanService) ScanProject(ctx context.Context, projectID string, path st
 		}
 	}
 
-	select {
-	case s.scanQueue <- tasks:
-		return nil
-	default:
-		return errors.New("scan queue is full")
-	}
+	return s.ScanMultipleProjects(ctx, tasks)
 }
 
 func (s *ScanService) resolveProjectRoots(ctx context.Context, project models.Project) ([]string, error) {
@@ -315,14 +327,13 @@ func (s *ScanService) runInitialImport(ctx context.Context, directories []string
 				return nil
 			}
 			if d.IsDir() {
-				name := d.Name()
-				if s.shouldSkipDir(name, strict) {
+				if path != dir && s.shouldSkipDir(path, strict) {
 					return filepath.SkipDir
 				}
 				return nil
 			}
 
-			if s.shouldSkipFileWithPolicy(d.Name(), strict) {
+			if s.shouldSkipFile(path, strict) {
 				return nil
 			}
 
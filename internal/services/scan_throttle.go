@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+)
+
+const (
+	maxScanConcurrency = 32
+	// scanThrottleAdjustEvery 每完成这么多次索引调整一次并发
+	scanThrottleAdjustEvery = 32
+	// scanThrottleSlowFactor 平均延迟超过基线该倍数视为设备过载，并发减半
+	scanThrottleSlowFactor = 2.0
+	// scanThrottleMaxLatencyMs 绝对延迟上限，超过同样退避（NAS / 休眠唤醒的机械盘）
+	scanThrottleMaxLatencyMs = 500.0
+)
+
+// defaultScanConcurrency 未配置时的设备并发上限
+func defaultScanConcurrency() int {
+	return min(8, max(2, runtime.NumCPU()))
+}
+
+// deviceThrottle 单个设备上的索引并发闸门：加性增、乘性减（AIMD），
+// 依据 IndexFile 延迟的指数滑动平均相对基线的变化调整
+type deviceThrottle struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	max      int
+	limit    int
+	inflight int
+	samples  int
+	ewma     float64 // ms
+	baseline float64 // ms
+}
+
+func newDeviceThrottle(maxWorkers int) *deviceThrottle {
+	t := &deviceThrottle{max: maxWorkers, limit: max(1, maxWorkers/2)}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+func (t *deviceThrottle) setMax(n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.max = n
+	t.limit = max(1, min(t.limit, n))
+	t.cond.Broadcast()
+}
+
+func (t *deviceThrottle) acquire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.inflight >= t.limit {
+		t.cond.Wait()
+	}
+	t.inflight++
+}
+
+func (t *deviceThrottle) release(latency time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inflight--
+	ms := float64(latency) / float64(time.Millisecond)
+	if t.samples == 0 {
+		t.ewma = ms
+	} else {
+		t.ewma = 0.8*t.ewma + 0.2*ms
+	}
+	t.samples++
+	// 基线取历史最低平均延迟，并缓慢上浮以适应设备本身较慢的情况
+	if t.baseline == 0 || t.ewma < t.baseline {
+		t.baseline = t.ewma
+	} else {
+		t.baseline += (t.ewma - t.baseline) * 0.01
+	}
+	if t.samples%scanThrottleAdjustEvery == 0 {
+		slow := t.ewma > t.baseline*scanThrottleSlowFactor || t.ewma > scanThrottleMaxLatencyMs
+		if slow && t.limit > 1 {
+			t.limit = max(1, t.limit/2)
+		} else if !slow && t.limit < t.max {
+			t.limit++
+		}
+	}
+	t.cond.Broadcast()
+}
+
+func (t *deviceThrottle) current() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.limit
+}
+
+// SetLibrarySourceRepo 用于读取来源级扫描并发配置
+func (s *ScanService) SetLibrarySourceRepo(r *repos.LibrarySourceRepo) {
+	s.librarySources = r
+}
+
+// SetSourceConcurrency 设置库来源的扫描并发上限，0 表示按设备自适应
+func (s *ScanService) SetSourceConcurrency(ctx context.Context, rootPath string, concurrency int) (*models.LibrarySource, error) {
+	if s.librarySources == nil {
+		return nil, errors.New("library source repo is not available")
+	}
+	if concurrency < 0 || concurrency > maxScanConcurrency {
+		return nil, errors.New("concurrency must be between 0 and 32")
+	}
+	src, err := s.librarySources.SetScanConcurrency(ctx, rootPath, concurrency)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, errors.New("library source not found")
+	}
+	return src, nil
+}
+
+// resolveScanConcurrency 取包含 path 的最内层库来源的配置，未配置时用默认值
+func (s *ScanService) resolveScanConcurrency(ctx context.Context, path string) int {
+	n := defaultScanConcurrency()
+	if s.librarySources == nil {
+		return n
+	}
+	sources, err := s.librarySources.List(ctx)
+	if err != nil {
+		return n
+	}
+	best := ""
+	for _, src := range sources {
+		root := filepath.Clean(src.RootPath)
+		if src.ScanConcurrency <= 0 || !pathWithinRoot(path, root) || len(root) <= len(best) {
+			continue
+		}
+		best = root
+		n = min(src.ScanConcurrency, maxScanConcurrency)
+	}
+	return n
+}
+
+// throttleFor 返回 path 所在设备的闸门，同一设备上的扫描共享并发额度
+func (s *ScanService) throttleFor(path string, maxWorkers int) *deviceThrottle {
+	key, ok := utils.DeviceID(path)
+	if !ok {
+		key = "path:" + strings.ToLower(filepath.Clean(path))
+	}
+	s.throttleMu.Lock()
+	defer s.throttleMu.Unlock()
+	if s.throttles == nil {
+		s.throttles = map[string]*deviceThrottle{}
+	}
+	t, ok := s.throttles[key]
+	if !ok {
+		t = newDeviceThrottle(maxWorkers)
+		s.throttles[key] = t
+		return t
+	}
+	t.setMax(maxWorkers)
+	return t
+}
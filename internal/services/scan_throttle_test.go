@@ -0,0 +1,105 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+// feedThrottle 按 acquire/release 配对喂入 n 次相同延迟
+func feedThrottle(t *deviceThrottle, n int, latency time.Duration) {
+	for i := 0; i < n; i++ {
+		t.acquire()
+		t.release(latency)
+	}
+}
+
+func TestDeviceThrottle_AIMD(t *testing.T) {
+	th := newDeviceThrottle(8)
+	if got := th.current(); got != 4 {
+		t.Fatalf("initial limit = %d, want 4", got)
+	}
+
+	// 延迟平稳：每轮加 1，直到上限
+	feedThrottle(th, scanThrottleAdjustEvery, 10*time.Millisecond)
+	if got := th.current(); got != 5 {
+		t.Fatalf("limit after a fast round = %d, want 5", got)
+	}
+	feedThrottle(th, 10*scanThrottleAdjustEvery, 10*time.Millisecond)
+	if got := th.current(); got != 8 {
+		t.Fatalf("limit should stop at max, got %d", got)
+	}
+
+	// 延迟相对基线翻倍：减半
+	feedThrottle(th, scanThrottleAdjustEvery, 100*time.Millisecond)
+	if got := th.current(); got != 4 {
+		t.Fatalf("limit after a slow round = %d, want 4", got)
+	}
+	// 持续同样慢：基线上浮适应设备，重新加性增长
+	feedThrottle(th, scanThrottleAdjustEvery, 100*time.Millisecond)
+	if got := th.current(); got != 5 {
+		t.Fatalf("limit after the baseline adapted = %d, want 5", got)
+	}
+	feedThrottle(th, scanThrottleAdjustEvery, 400*time.Millisecond)
+	if got := th.current(); got != 2 {
+		t.Fatalf("limit after a further slowdown = %d, want 2", got)
+	}
+
+	// 绝对延迟超过上限时即使基线同样慢也退避
+	slow := newDeviceThrottle(8)
+	feedThrottle(slow, scanThrottleAdjustEvery, 600*time.Millisecond)
+	if got := slow.current(); got != 2 {
+		t.Fatalf("limit on a uniformly slow device = %d, want 2", got)
+	}
+}
+
+func TestDeviceThrottle_SetMax(t *testing.T) {
+	tests := []struct {
+		name      string
+		initial   int
+		setMax    int
+		wantLimit int
+	}{
+		{"lower clamps limit", 8, 3, 3},
+		{"raise keeps limit", 8, 16, 4},
+		{"zero keeps one slot", 8, 0, 1},
+		{"single worker", 1, 1, 1},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			th := newDeviceThrottle(tc.initial)
+			th.setMax(tc.setMax)
+			if got := th.current(); got != tc.wantLimit {
+				t.Fatalf("limit = %d, want %d", got, tc.wantLimit)
+			}
+		})
+	}
+
+	// 提高上限后可以继续加性增长
+	th := newDeviceThrottle(2)
+	th.setMax(4)
+	feedThrottle(th, 4*scanThrottleAdjustEvery, time.Millisecond)
+	if got := th.current(); got != 4 {
+		t.Fatalf("limit after raising max = %d, want 4", got)
+	}
+}
+
+func TestDeviceThrottle_AcquireBlocksAtLimit(t *testing.T) {
+	th := newDeviceThrottle(1)
+	th.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		th.acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquire should block while the only slot is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	th.release(time.Millisecond)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("release did not wake the waiting acquire")
+	}
+}
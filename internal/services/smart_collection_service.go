@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+// SmartCollectionService 智能集合：保存的筛选条件、实时计数与命中变化通知
+type SmartCollectionService struct {
+	repo     *repos.SmartCollectionRepo
+	assets   *AssetService
+	eventHub *EventHub
+	evalMu   sync.Mutex
+	interval time.Duration
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// smartCollectionEvalBatch 每批重新评估的资产数
+const smartCollectionEvalBatch = 200
+
+// SmartCollectionFilters 与 ListAssetsRequest 对应的可持久化筛选项
+type SmartCollectionFilters struct {
+	ProjectID   string   `json:"project_id,omitempty"`
+	Directory   string   `json:"directory,omitempty"`
+	TagIDs      []string `json:"tag_ids,omitempty"`
+	Types       []string `json:"types,omitempty"`
+	Shapes      []string `json:"shapes,omitempty"`
+	QuickFilter string   `json:"quick_filter,omitempty"`
+	DatePreset  string   `json:"date_preset,omitempty"`
+	SizeMin     int64    `json:"size_min,omitempty"`
+	SizeMax     int64    `json:"size_max,omitempty"`
+	RatingMin   int      `json:"rating_min,omitempty"`
+	RatingMax   int      `json:"rating_max,omitempty"`
+	MtimeFrom   int64    `json:"mtime_from,omitempty"`
+	MtimeTo     int64    `json:"mtime_to,omitempty"`
+	WidthMin    int      `json:"width_min,omitempty"`
+	WidthMax    int      `json:"width_max,omitempty"`
+	HeightMin   int      `json:"height_min,omitempty"`
+	HeightMax   int      `json:"height_max,omitempty"`
+	SortBy      string   `json:"sort_by,omitempty"`
+	SortOrder   string   `json:"sort_order,omitempty"`
+}
+
+type SmartCollectionView struct {
+	models.SmartCollection
+	Count int `json:"count"`
+}
+
+type CreateSmartCollectionRequest struct {
+	Name     string          `json:"name"`
+	Icon     *string         `json:"icon"`
+	PinOrder int             `json:"pin_order"`
+	Query    string          `json:"query"`
+	Filters  json.RawMessage `json:"filters"`
+}
+
+// UpdateSmartCollectionRequest 仅更新非空字段；pin_order 设为 0 表示取消置顶
+type UpdateSmartCollectionRequest struct {
+	ID       string          `json:"id"`
+	Name     *string         `json:"name"`
+	Icon     *string         `json:"icon"`
+	PinOrder *int            `json:"pin_order"`
+	Query    *string         `json:"query"`
+	Filters  json.RawMessage `json:"filters"`
+}
+
+func NewSmartCollectionService(repo *repos.SmartCollectionRepo, assets *AssetService, eventHub *EventHub) *SmartCollectionService {
+	return &SmartCollectionService{
+		repo:     repo,
+		assets:   assets,
+		eventHub: eventHub,
+		interval: 2 * time.Second,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start 后台消费 smart_collection_dirty 队列；评估失败的批次不出队，下一轮重试
+func (s *SmartCollectionService) Start() {
+	s.wg.Add(1)
+	go s.loop()
+}
+
+func (s *SmartCollectionService) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *SmartCollectionService) loop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := s.ProcessDirty(context.Background())
+			if err != nil {
+				logger.Warn("Smart collection re-evaluation failed", zap.Error(err))
+				break
+			}
+			if n < smartCollectionEvalBatch {
+				break
+			}
+		}
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProcessDirty 重新评估一批变更过的资产，全部集合评估成功后才确认出队；返回处理条数
+func (s *SmartCollectionService) ProcessDirty(ctx context.Context) (int, error) {
+	items, err := s.repo.ListDirty(ctx, smartCollectionEvalBatch)
+	if err != nil || len(items) == 0 {
+		return 0, err
+	}
+	ids := make([]string, 0, len(items))
+	for _, it := range items {
+		ids = append(ids, it.AssetID)
+	}
+	if err := s.evaluateAssets(ctx, ids); err != nil {
+		return 0, err
+	}
+	if err := s.repo.AckDirty(ctx, items); err != nil {
+		return 0, err
+	}
+	return len(items), nil
+}
+
+func (f SmartCollectionFilters) listRequest(query string) ListAssetsRequest {
+	return ListAssetsRequest{
+		ProjectID:   f.ProjectID,
+		Directory:   f.Directory,
+		Query:       query,
+		TagIDs:      f.TagIDs,
+		Types:       f.Types,
+		Shapes:      f.Shapes,
+		QuickFilter: f.QuickFilter,
+		DatePreset:  f.DatePreset,
+		SizeMin:     f.SizeMin,
+		SizeMax:     f.SizeMax,
+		RatingMin:   f.RatingMin,
+		RatingMax:   f.RatingMax,
+		MtimeFrom:   f.MtimeFrom,
+		MtimeTo:     f.MtimeTo,
+		WidthMin:    f.WidthMin,
+		WidthMax:    f.WidthMax,
+		HeightMin:   f.HeightMin,
+		HeightMax:   f.HeightMax,
+		SortBy:      f.SortBy,
+		SortOrder:   f.SortOrder,
+	}
+}
+
+// normalizeSmartCollectionFilters 校验并规范化筛选项 JSON
+func normalizeSmartCollectionFilters(raw json.RawMessage) (string, error) {
+	var f SmartCollectionFilters
+	if len(raw) > 0 && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return "", errors.New("filters must be a valid filter object")
+		}
+	}
+	b, err := json.Marshal(f)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func collectionListRequest(c *models.SmartCollection) (ListAssetsRequest, error) {
+	var f SmartCollectionFilters
+	if strings.TrimSpace(c.FiltersJSON) != "" {
+		if err := json.Unmarshal([]byte(c.FiltersJSON), &f); err != nil {
+			return ListAssetsRequest{}, err
+		}
+	}
+	return f.listRequest(c.Query), nil
+}
+
+func (s *SmartCollectionService) view(ctx context.Context, c *models.SmartCollection) (*SmartCollectionView, error) {
+	req, err := collectionListRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.assets.CountAssets(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	c.Filters = decodeRawJSON(c.FiltersJSON, "{}")
+	return &SmartCollectionView{SmartCollection: *c, Count: count}, nil
+}
+
+// rebuildMembers 全量重算集合命中，作为后续增量通知的基线
+func (s *SmartCollectionService) rebuildMembers(ctx context.Context, c *models.SmartCollection) error {
+	req, err := collectionListRequest(c)
+	if err != nil {
+		return err
+	}
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+	ids, err := s.assets.MatchAssetIDs(ctx, req, nil)
+	if err != nil {
+		return err
+	}
+	return s.repo.ReplaceMembers(ctx, c.ID, ids)
+}
+
+func (s *SmartCollectionService) Create(ctx context.Context, req CreateSmartCollectionRequest) (*SmartCollectionView, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	query := strings.TrimSpace(req.Query)
+	if _, err := ParseAssetQuery(query, time.Now()); err != nil {
+		return nil, err
+	}
+	filters, err := normalizeSmartCollectionFilters(req.Filters)
+	if err != nil {
+		return nil, err
+	}
+	if req.PinOrder < 0 {
+		return nil, errors.New("pin_order must be >= 0")
+	}
+	now := time.Now().Unix()
+	c := &models.SmartCollection{
+		ID:          utils.NewID(),
+		Name:        name,
+		Icon:        trimOptionalID(req.Icon),
+		PinOrder:    req.PinOrder,
+		Query:       query,
+		FiltersJSON: filters,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.Create(ctx, c); err != nil {
+		return nil, err
+	}
+	if err := s.rebuildMembers(ctx, c); err != nil {
+		return nil, err
+	}
+	return s.view(ctx, c)
+}
+
+func (s *SmartCollectionService) Update(ctx context.Context, req UpdateSmartCollectionRequest) (*SmartCollectionView, error) {
+	c, err := s.repo.Get(ctx, strings.TrimSpace(req.ID))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, errors.New("smart collection not found")
+	}
+	criteriaChanged := false
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, errors.New("name cannot be empty")
+		}
+		c.Name = name
+	}
+	if req.Icon != nil {
+		c.Icon = trimOptionalID(req.Icon)
+	}
+	if req.PinOrder != nil {
+		if *req.PinOrder < 0 {
+			return nil, errors.New("pin_order must be >= 0")
+		}
+		c.PinOrder = *req.PinOrder
+	}
+	if req.Query != nil {
+		query := strings.TrimSpace(*req.Query)
+		if _, err := ParseAssetQuery(query, time.Now()); err != nil {
+			return nil, err
+		}
+		criteriaChanged = criteriaChanged || query != c.Query
+		c.Query = query
+	}
+	if len(req.Filters) > 0 {
+		filters, err := normalizeSmartCollectionFilters(req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		criteriaChanged = criteriaChanged || filters != c.FiltersJSON
+		c.FiltersJSON = filters
+	}
+	c.UpdatedAt = time.Now().Unix()
+	if err := s.repo.Update(ctx, c); err != nil {
+		return nil, err
+	}
+	if criteriaChanged {
+		if err := s.rebuildMembers(ctx, c); err != nil {
+			return nil, err
+		}
+	}
+	return s.view(ctx, c)
+}
+
+func (s *SmartCollectionService) Delete(ctx context.Context, id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return errors.New("id is required")
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *SmartCollectionService) Get(ctx context.Context, id string) (*SmartCollectionView, error) {
+	c, err := s.repo.Get(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, errors.New("smart collection not found")
+	}
+	return s.view(ctx, c)
+}
+
+// List 返回全部集合及其实时计数
+func (s *SmartCollectionService) List(ctx context.Context) ([]SmartCollectionView, error) {
+	items, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]SmartCollectionView, 0, len(items))
+	for i := range items {
+		v, err := s.view(ctx, &items[i])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *v)
+	}
+	return out, nil
+}
+
+// ListAssets 按集合条件分页列出资产
+func (s *SmartCollectionService) ListAssets(ctx context.Context, id string, cursor string, limit int) (*ListAssetsResult, error) {
+	c, err := s.repo.Get(ctx, strings.TrimSpace(id))
+	if err != nil {
+		return nil, err
+	}
+	if c == nil {
+		return nil, errors.New("smart collection not found")
+	}
+	req, err := collectionListRequest(c)
+	if err != nil {
+		return nil, err
+	}
+	req.Cursor = cursor
+	req.Limit = limit
+	if req.SortBy == "" {
+		req.SortBy = "name"
+		if req.Query != "" {
+			req.SortBy = "relevance"
+		}
+	}
+	return s.assets.ListAssets(ctx, req)
+}
+
+// evaluateAssets 重新判断 ids 在各集合中的命中，写入变化并广播新命中/不再命中的资产。
+// 命中变化增量写入，重试时已写入的集合不会重复产生事件
+func (s *SmartCollectionService) evaluateAssets(ctx context.Context, ids []string) error {
+	collections, err := s.repo.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.evalMu.Lock()
+	defer s.evalMu.Unlock()
+	var firstErr error
+	for i := range collections {
+		c := &collections[i]
+		req, err := collectionListRequest(c)
+		if err != nil {
+			continue
+		}
+		if err := s.evaluateCollection(ctx, c, req, ids); err != nil {
+			logger.Warn("Failed to evaluate smart collection", zap.String("collection_id", c.ID), zap.Error(err))
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (s *SmartCollectionService) evaluateCollection(ctx context.Context, c *models.SmartCollection, req ListAssetsRequest, ids []string) error {
+	matched, err := s.assets.MatchAssetIDs(ctx, req, ids)
+	if err != nil {
+		return err
+	}
+	previous, err := s.repo.ListMemberIDs(ctx, c.ID, ids)
+	if err != nil {
+		return err
+	}
+	added := diffStrings(matched, previous)
+	removed := diffStrings(previous, matched)
+	if len(added) == 0 && len(removed) == 0 {
+		return nil
+	}
+	if err := s.repo.ApplyMemberChanges(ctx, c.ID, added, removed); err != nil {
+		return err
+	}
+	if s.eventHub != nil {
+		s.eventHub.Broadcast(map[string]any{
+			"type": "smart_collection_membership_changed",
+			"data": map[string]any{
+				"collection_id": c.ID,
+				"name":          c.Name,
+				"added":         added,
+				"removed":       removed,
+			},
+		})
+	}
+	return nil
+}
+
+// diffStrings 返回在 a 中但不在 b 中的元素
+func diffStrings(a []string, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, v := range b {
+		seen[v] = true
+	}
+	out := []string{}
+	for _, v := range a {
+		if !seen[v] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
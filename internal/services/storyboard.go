@@ -0,0 +1,380 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+
+	"github.com/nfnt/resize"
+	"go.uber.org/zap"
+)
+
+const (
+	storyboardTaskType     = "storyboard"
+	storyboardTaskPriority = 10
+	storyboardRendition    = "storyboard"
+	storyboardTileWidth    = 160
+	storyboardColumns      = 10
+	// storyboardFrames 等间隔模式的帧数；场景模式检测到的切换数在 [storyboardMinScenes, storyboardMaxFrames] 内时改用场景帧
+	storyboardFrames         = 40
+	storyboardMinScenes      = 4
+	storyboardMaxFrames      = 100
+	storyboardSceneThreshold = 0.3
+	// storyboardMinSceneGap 过近的场景切换（闪烁、转场）合并
+	storyboardMinSceneGap = 1.0
+)
+
+// StoryboardFrame 一帧在雪碧图中的位置
+type StoryboardFrame struct {
+	Index int     `json:"index"`
+	Time  float64 `json:"time"`
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+}
+
+// StoryboardIndex 雪碧图索引，存于规格的 meta_json
+type StoryboardIndex struct {
+	Mode       string            `json:"mode"` // interval / scene
+	Duration   float64           `json:"duration"`
+	TileWidth  int               `json:"tile_width"`
+	TileHeight int               `json:"tile_height"`
+	Columns    int               `json:"columns"`
+	Rows       int               `json:"rows"`
+	Frames     []StoryboardFrame `json:"frames"`
+}
+
+type Storyboard struct {
+	models.ThumbnailRendition
+	Index       StoryboardIndex
+	Path        string
+	ContentType string
+}
+
+// WebVTT 生成缩略图轨道：每个 cue 指向雪碧图中的一格（spriteURL#xywh=）
+func (sb *Storyboard) WebVTT(spriteURL string) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	frames := sb.Index.Frames
+	for i, f := range frames {
+		end := sb.Index.Duration
+		if i+1 < len(frames) {
+			end = frames[i+1].Time
+		}
+		start := f.Time
+		if i == 0 {
+			start = 0
+		}
+		if end <= start {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s --> %s\n%s#xywh=%d,%d,%d,%d\n",
+			vttTimestamp(start), vttTimestamp(end), spriteURL,
+			f.X, f.Y, sb.Index.TileWidth, sb.Index.TileHeight)
+	}
+	return b.String()
+}
+
+func vttTimestamp(sec float64) string {
+	ms := int64(sec*1000 + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// GetStoryboard 返回资产当前内容已生成的故事板
+func (s *ThumbnailService) GetStoryboard(ctx context.Context, asset *models.Asset) (*Storyboard, error) {
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		return nil, ErrThumbnailNotFound
+	}
+	rends, err := s.thumbs.ListByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rends {
+		if r.Rendition != storyboardRendition || !s.exists(r.RelPath) {
+			continue
+		}
+		sb := &Storyboard{
+			ThumbnailRendition: r,
+			Path:               filepath.Join(s.dataDir, r.RelPath),
+			ContentType:        "image/" + r.Format,
+		}
+		if err := json.Unmarshal([]byte(r.MetaJSON), &sb.Index); err != nil {
+			return nil, fmt.Errorf("invalid storyboard index: %w", err)
+		}
+		return sb, nil
+	}
+	return nil, ErrThumbnailNotFound
+}
+
+// InvalidateStoryboard 删除已生成的故事板，下次任务重新生成
+func (s *ThumbnailService) InvalidateStoryboard(ctx context.Context, asset *models.Asset) error {
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		return nil
+	}
+	unlock := s.lockKey(key)
+	defer unlock()
+	rends, err := s.thumbs.ListByKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	for _, r := range rends {
+		if r.Rendition != storyboardRendition {
+			continue
+		}
+		_ = os.Remove(filepath.Join(s.dataDir, r.RelPath))
+		return s.thumbs.Delete(ctx, r.ContentKey, r.Rendition)
+	}
+	return nil
+}
+
+// GenerateStoryboard 为视频生成滚动预览雪碧图：场景切换足够多时每个场景一帧，否则等间隔取帧。
+// 非视频或缺少 ffmpeg / ffprobe 时跳过（返回 nil）；已存在时直接复用
+func (s *ThumbnailService) GenerateStoryboard(ctx context.Context, asset *models.Asset, progress func(int)) (*Storyboard, error) {
+	if detectAssetFileType(asset.Path) != "video" {
+		return nil, nil
+	}
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		return nil, err
+	}
+	unlock := s.lockKey(key)
+	existing, err := s.GetStoryboard(ctx, asset)
+	if err == nil {
+		unlock()
+		return existing, nil
+	}
+	defer unlock()
+	if !errors.Is(err, ErrThumbnailNotFound) {
+		return nil, err
+	}
+	if progress == nil {
+		progress = func(int) {}
+	}
+
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		logger.Warn("ffmpeg not found, skip storyboard", zap.String("asset_id", asset.ID))
+		return nil, nil
+	}
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		logger.Warn("ffprobe not found, skip storyboard", zap.String("asset_id", asset.ID))
+		return nil, nil
+	}
+	duration, err := probeDuration(ctx, ffprobe, asset.Path)
+	if err != nil || duration <= 0 {
+		logger.Warn("Video duration unavailable, skip storyboard", zap.String("asset_id", asset.ID), zap.Error(err))
+		return nil, nil
+	}
+	progress(10)
+
+	mode := "interval"
+	times := intervalTimes(duration, storyboardFrames)
+	if scenes, err := detectSceneChanges(ctx, ffmpeg, asset.Path); err == nil {
+		if scenes = mergeSceneTimes(scenes, duration); len(scenes) >= storyboardMinScenes && len(scenes) <= storyboardMaxFrames {
+			mode = "scene"
+			times = scenes
+		}
+	} else if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	progress(30)
+
+	var tiles []image.Image
+	var tileTimes []float64
+	for i, t := range times {
+		img, err := extractFrame(ctx, ffmpeg, asset.Path, t)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue
+		}
+		tiles = append(tiles, img)
+		tileTimes = append(tileTimes, t)
+		progress(30 + 60*(i+1)/len(times))
+	}
+	if len(tiles) == 0 {
+		return nil, errors.New("no storyboard frames extracted")
+	}
+
+	sprite, index := composeStoryboard(tiles, tileTimes)
+	index.Mode = mode
+	index.Duration = duration
+	data, format, err := s.encoder.encode(ctx, sprite)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := json.Marshal(index)
+	if err != nil {
+		return nil, err
+	}
+	relDir := thumbnailRelDir(key)
+	if err := os.MkdirAll(filepath.Join(s.dataDir, relDir), 0o755); err != nil {
+		return nil, err
+	}
+	rel := filepath.Join(relDir, storyboardRendition+"."+format)
+	if err := writeFileAtomic(filepath.Join(s.dataDir, rel), data); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	bounds := sprite.Bounds()
+	rend := models.ThumbnailRendition{
+		ContentKey: key,
+		Rendition:  storyboardRendition,
+		Format:     format,
+		RelPath:    rel,
+		Width:      bounds.Dx(),
+		Height:     bounds.Dy(),
+		Bytes:      int64(len(data)),
+		ETag:       hex.EncodeToString(sum[:8]),
+		MetaJSON:   string(meta),
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := s.thumbs.Upsert(ctx, &rend); err != nil {
+		return nil, err
+	}
+	progress(100)
+	return &Storyboard{
+		ThumbnailRendition: rend,
+		Index:              index,
+		Path:               filepath.Join(s.dataDir, rel),
+		ContentType:        "image/" + format,
+	}, nil
+}
+
+func intervalTimes(duration float64, n int) []float64 {
+	times := make([]float64, n)
+	for i := range times {
+		times[i] = duration * (float64(i) + 0.5) / float64(n)
+	}
+	return times
+}
+
+// mergeSceneTimes 以 0 秒开头，合并间隔过近的切换点
+func mergeSceneTimes(scenes []float64, duration float64) []float64 {
+	sort.Float64s(scenes)
+	out := []float64{0}
+	for _, t := range scenes {
+		if t <= 0 || t >= duration {
+			continue
+		}
+		if t-out[len(out)-1] < storyboardMinSceneGap {
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func probeDuration(ctx context.Context, ffprobe, path string) (float64, error) {
+	out, err := exec.CommandContext(ctx, ffprobe,
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+}
+
+// detectSceneChanges 低分辨率解码一遍，用 select=scene 过滤 + showinfo 输出切换点时间
+func detectSceneChanges(ctx context.Context, ffmpeg, path string) ([]float64, error) {
+	cmd := exec.CommandContext(ctx, ffmpeg,
+		"-hide_banner", "-nostats",
+		"-i", path,
+		"-an", "-sn",
+		"-vf", fmt.Sprintf("scale=320:-2,select='gt(scene,%g)',showinfo", storyboardSceneThreshold),
+		"-f", "null", "-",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	var times []float64
+	sc := bufio.NewScanner(&stderr)
+	for sc.Scan() {
+		line := sc.Text()
+		i := strings.Index(line, "pts_time:")
+		if i < 0 || !strings.Contains(line, "Parsed_showinfo") {
+			continue
+		}
+		field := strings.Fields(line[i+len("pts_time:"):])
+		if len(field) == 0 {
+			continue
+		}
+		if t, err := strconv.ParseFloat(field[0], 64); err == nil {
+			times = append(times, t)
+		}
+	}
+	return times, nil
+}
+
+func extractFrame(ctx context.Context, ffmpeg, path string, at float64) (image.Image, error) {
+	cmd := exec.CommandContext(ctx, ffmpeg,
+		"-hide_banner", "-loglevel", "error",
+		"-ss", strconv.FormatFloat(at, 'f', 3, 64),
+		"-i", path,
+		"-frames:v", "1",
+		"-vf", fmt.Sprintf("scale=%d:-2", storyboardTileWidth),
+		"-f", "image2pipe",
+		"-vcodec", "mjpeg",
+		"-an", "-sn",
+		"pipe:1",
+	)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	if out.Len() == 0 {
+		return nil, errors.New("ffmpeg returned empty frame")
+	}
+	img, _, err := image.Decode(&out)
+	return img, err
+}
+
+// composeStoryboard 按行拼接成雪碧图；格子尺寸取第一帧，其余帧缩放到同一尺寸
+func composeStoryboard(tiles []image.Image, times []float64) (image.Image, StoryboardIndex) {
+	tw := tiles[0].Bounds().Dx()
+	th := tiles[0].Bounds().Dy()
+	cols := storyboardColumns
+	if len(tiles) < cols {
+		cols = len(tiles)
+	}
+	rows := (len(tiles) + cols - 1) / cols
+	sprite := image.NewRGBA(image.Rect(0, 0, tw*cols, th*rows))
+	index := StoryboardIndex{TileWidth: tw, TileHeight: th, Columns: cols, Rows: rows}
+	for i, tile := range tiles {
+		if b := tile.Bounds(); b.Dx() != tw || b.Dy() != th {
+			tile = resize.Resize(uint(tw), uint(th), tile, resize.Bilinear)
+		}
+		x := (i % cols) * tw
+		y := (i / cols) * th
+		draw.Draw(sprite, image.Rect(x, y, x+tw, y+th), tile, tile.Bounds().Min, draw.Src)
+		index.Frames = append(index.Frames, StoryboardFrame{Index: i, Time: times[i], X: x, Y: y})
+	}
+	return sprite, index
+}
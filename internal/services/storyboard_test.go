@@ -0,0 +1,166 @@
+package services
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIntervalTimes(t *testing.T) {
+	tests := []struct {
+		duration float64
+		n        int
+		want     []float64
+	}{
+		{10, 1, []float64{5}},
+		{10, 4, []float64{1.25, 3.75, 6.25, 8.75}},
+		{0, 2, []float64{0, 0}},
+		{10, 0, []float64{}},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v/%d", tc.duration, tc.n), func(t *testing.T) {
+			if got := intervalTimes(tc.duration, tc.n); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("intervalTimes = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMergeSceneTimes(t *testing.T) {
+	tests := []struct {
+		name     string
+		scenes   []float64
+		duration float64
+		want     []float64
+	}{
+		{"no scenes", nil, 30, []float64{0}},
+		{"unsorted", []float64{20, 5, 12}, 30, []float64{0, 5, 12, 20}},
+		{"too close to start", []float64{0.4, 3}, 30, []float64{0, 3}},
+		{"flicker merged", []float64{5, 5.3, 5.9, 6.1}, 30, []float64{0, 5, 6.1}},
+		{"exact gap kept", []float64{2, 3}, 30, []float64{0, 2, 3}},
+		{"out of bounds", []float64{-1, 0, 30, 31, 10}, 30, []float64{0, 10}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := mergeSceneTimes(tc.scenes, tc.duration); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("mergeSceneTimes = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestVTTTimestamp(t *testing.T) {
+	tests := []struct {
+		sec  float64
+		want string
+	}{
+		{0, "00:00:00.000"},
+		{1.5, "00:00:01.500"},
+		{59.9996, "00:01:00.000"},
+		{61.25, "00:01:01.250"},
+		{3600 + 2*60 + 3.004, "01:02:03.004"},
+		{100 * 3600, "100:00:00.000"},
+	}
+	for _, tc := range tests {
+		if got := vttTimestamp(tc.sec); got != tc.want {
+			t.Errorf("vttTimestamp(%v) = %s, want %s", tc.sec, got, tc.want)
+		}
+	}
+}
+
+func TestStoryboard_WebVTT(t *testing.T) {
+	sb := &Storyboard{Index: StoryboardIndex{
+		Duration:   12,
+		TileWidth:  160,
+		TileHeight: 90,
+		Frames: []StoryboardFrame{
+			{Index: 0, Time: 1.5, X: 0, Y: 0},
+			{Index: 1, Time: 5, X: 160, Y: 0},
+			{Index: 2, Time: 5, X: 320, Y: 0},
+			{Index: 3, Time: 12, X: 480, Y: 0},
+		},
+	}}
+	want := "WEBVTT\n" +
+		"\n00:00:00.000 --> 00:00:05.000\nsb.jpeg#xywh=0,0,160,90\n" +
+		"\n00:00:05.000 --> 00:00:12.000\nsb.jpeg#xywh=320,0,160,90\n"
+	if got := sb.WebVTT("sb.jpeg"); got != want {
+		t.Fatalf("WebVTT =\n%s\nwant\n%s", got, want)
+	}
+
+	empty := &Storyboard{}
+	if got := empty.WebVTT("sb.jpeg"); got != "WEBVTT\n" {
+		t.Fatalf("empty WebVTT = %q", got)
+	}
+	single := &Storyboard{Index: StoryboardIndex{Duration: 3, TileWidth: 10, TileHeight: 5, Frames: []StoryboardFrame{{Time: 2}}}}
+	if got := single.WebVTT("x"); !strings.Contains(got, "00:00:00.000 --> 00:00:03.000") {
+		t.Fatalf("single frame should span the whole video: %q", got)
+	}
+}
+
+func solidTile(w, h int, c color.Color) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestComposeStoryboard(t *testing.T) {
+	tests := []struct {
+		name      string
+		tiles     int
+		wantCols  int
+		wantRows  int
+		wantLastX int
+		wantLastY int
+	}{
+		{"single", 1, 1, 1, 0, 0},
+		{"partial row", 3, 3, 1, 2 * 16, 0},
+		{"full row", storyboardColumns, storyboardColumns, 1, (storyboardColumns - 1) * 16, 0},
+		{"wraps", storyboardColumns + 2, storyboardColumns, 2, 16, 9},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tiles := make([]image.Image, tc.tiles)
+			times := make([]float64, tc.tiles)
+			for i := range tiles {
+				tiles[i] = solidTile(16, 9, color.White)
+				times[i] = float64(i) * 2
+			}
+			sprite, index := composeStoryboard(tiles, times)
+			if index.Columns != tc.wantCols || index.Rows != tc.wantRows || index.TileWidth != 16 || index.TileHeight != 9 {
+				t.Fatalf("index = %+v", index)
+			}
+			if b := sprite.Bounds(); b.Dx() != 16*tc.wantCols || b.Dy() != 9*tc.wantRows {
+				t.Fatalf("sprite = %v", b)
+			}
+			last := index.Frames[len(index.Frames)-1]
+			if len(index.Frames) != tc.tiles || last.X != tc.wantLastX || last.Y != tc.wantLastY || last.Index != tc.tiles-1 || last.Time != times[tc.tiles-1] {
+				t.Fatalf("last frame = %+v", last)
+			}
+		})
+	}
+
+	// 尺寸不同的帧缩放到首帧大小后放入对应格子
+	red := color.RGBA{R: 255, A: 255}
+	sprite, index := composeStoryboard([]image.Image{solidTile(16, 9, color.White), solidTile(32, 20, red)}, []float64{0, 1})
+	if b := sprite.Bounds(); b.Dx() != 32 || b.Dy() != 9 {
+		t.Fatalf("sprite = %v", b)
+	}
+	if f := index.Frames[1]; f.X != 16 || f.Y != 0 {
+		t.Fatalf("second frame = %+v", f)
+	}
+	for _, p := range []image.Point{{16, 0}, {31, 8}} {
+		if r, g, _, _ := sprite.At(p.X, p.Y).RGBA(); r>>8 != 255 || g>>8 != 0 {
+			t.Errorf("pixel %v = %v, want red", p, sprite.At(p.X, p.Y))
+		}
+	}
+	if r, g, _, _ := sprite.At(15, 8).RGBA(); r>>8 != 255 || g>>8 != 255 {
+		t.Errorf("first tile overwritten: %v", sprite.At(15, 8))
+	}
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// ErrTaskCancelled 任务已被取消，worker 应停止处理
+var ErrTaskCancelled = errors.New("task cancelled")
+
+const (
+	// taskBoostPriority 提升后的优先级增量；远高于核心任务优先级，原有相对次序保持不变
+	taskBoostPriority = 10000
+	// autoBoostTimeout 浏览触发的自动提升在后台执行的超时
+	autoBoostTimeout = 5 * time.Second
+)
+
+type CancelTasksRequest struct {
+	TaskIDs  []string `json:"task_ids"`
+	AssetIDs []string `json:"asset_ids"`
+}
+
+type CancelTasksResult struct {
+	// Cancelled 待处理任务，已直接取消（含级联取消的下游任务）
+	Cancelled []string `json:"cancelled"`
+	// CancelRequested 处理中任务，等待 worker 协作退出
+	CancelRequested []string `json:"cancel_requested"`
+}
+
+type BoostTasksRequest struct {
+	AssetIDs  []string `json:"asset_ids"`
+	Directory string   `json:"directory"`
+	Recursive bool     `json:"recursive"`
+}
+
+type BoostTasksResult struct {
+	Boosted int64 `json:"boosted"`
+}
+
+// trackRunning 登记内部 worker 正在执行的任务，取消时通过 cancel 中断；返回的函数在任务结束时调用
+func (s *TaskService) trackRunning(taskID string, cancel context.CancelFunc) func() {
+	s.running.Store(taskID, cancel)
+	return func() { s.running.Delete(taskID) }
+}
+
+func (s *TaskService) isCancelRequested(ctx context.Context, taskID string) bool {
+	task, err := s.taskRepo.GetByID(ctx, taskID)
+	return err == nil && task != nil && task.CancelRequested
+}
+
+// CancelTasks 取消任务：待处理的直接取消并级联下游；处理中的打上标记，内部 worker 立即中断，外部 worker 在下次心跳时得知
+func (s *TaskService) CancelTasks(ctx context.Context, req CancelTasksRequest) (*CancelTasksResult, error) {
+	taskIDs := normalizeNonEmptyStrings(req.TaskIDs)
+	assetIDs := normalizeNonEmptyStrings(req.AssetIDs)
+	if len(taskIDs) == 0 && len(assetIDs) == 0 {
+		return nil, errors.New("task_ids or asset_ids is required")
+	}
+	if len(assetIDs) > 0 {
+		active, err := s.taskRepo.ListActiveByAssets(ctx, assetIDs)
+		if err != nil {
+			return nil, err
+		}
+		for i := range active {
+			if !containsString(taskIDs, active[i].ID) {
+				taskIDs = append(taskIDs, active[i].ID)
+			}
+		}
+	}
+
+	res := &CancelTasksResult{Cancelled: []string{}, CancelRequested: []string{}}
+	cancelled, err := s.taskRepo.CancelPending(ctx, taskIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range cancelled {
+		res.Cancelled = append(res.Cancelled, cancelled[i].ID)
+		s.metrics.cancelled(cancelled[i].TaskType, 1)
+		s.broadcastTask(&cancelled[i], "task_cancelled")
+		res.Cancelled = append(res.Cancelled, s.cascadeCancelled(ctx, &cancelled[i])...)
+	}
+
+	flagged, err := s.taskRepo.RequestCancel(ctx, taskIDs)
+	if err != nil {
+		return nil, err
+	}
+	for i := range flagged {
+		res.CancelRequested = append(res.CancelRequested, flagged[i].ID)
+		if cancel, ok := s.running.Load(flagged[i].ID); ok {
+			cancel.(context.CancelFunc)()
+		}
+		s.broadcastTask(&flagged[i], "task_cancel_requested")
+	}
+	return res, nil
+}
+
+// cascadeCancelled 取消下游任务，返回被级联取消的任务 id
+func (s *TaskService) cascadeCancelled(ctx context.Context, parent *models.MediaTask) []string {
+	affected, err := s.taskRepo.CascadeDependents(ctx, parent.ID, models.TaskStatusCancelled, fmt.Sprintf("prerequisite %s cancelled", parent.TaskType))
+	if err != nil {
+		logger.Warn("Cascade task cancel failed", zap.String("task_id", parent.ID), zap.Error(err))
+		return nil
+	}
+	ids := make([]string, 0, len(affected))
+	for i := range affected {
+		ids = append(ids, affected[i].ID)
+		s.metrics.cancelled(affected[i].TaskType, 1)
+		s.broadcastTask(&affected[i], "task_cancelled")
+	}
+	return ids
+}
+
+// finishCancelled worker 放弃已标记取消的任务后落库为 cancelled 并级联下游
+func (s *TaskService) finishCancelled(ctx context.Context, task *models.MediaTask, workerID string) error {
+	ok, err := s.taskRepo.FinishCancelled(ctx, task.ID, workerID)
+	if err != nil || !ok {
+		return err
+	}
+	s.metrics.cancelled(task.TaskType, 1)
+	// 释放的并发名额可供其他任务使用
+	s.notifier.Notify()
+	s.broadcastTaskUpdate(ctx, task.ID, "task_cancelled")
+	s.cascadeCancelled(ctx, task)
+	return nil
+}
+
+// cancelExpiredLeases 已标记取消但 worker 失联（租约过期）的任务直接取消
+func (s *TaskService) cancelExpiredLeases(ctx context.Context) {
+	tasks, err := s.taskRepo.CancelExpiredLeases(ctx)
+	if err != nil {
+		logger.Warn("Cancel expired leases failed", zap.Error(err))
+		return
+	}
+	for i := range tasks {
+		s.metrics.cancelled(tasks[i].TaskType, 1)
+		s.broadcastTask(&tasks[i], "task_cancelled")
+		s.cascadeCancelled(ctx, &tasks[i])
+	}
+	if len(tasks) > 0 {
+		s.notifier.Notify()
+	}
+}
+
+// BoostTasks 提升一组资产或一个目录下资产的待处理任务优先级（含其前置任务，依赖门控下才能真正插队）
+func (s *TaskService) BoostTasks(ctx context.Context, req BoostTasksRequest) (*BoostTasksResult, error) {
+	assetIDs := normalizeNonEmptyStrings(req.AssetIDs)
+	dir := strings.TrimSpace(req.Directory)
+	if len(assetIDs) == 0 && dir == "" {
+		return nil, errors.New("asset_ids or directory is required")
+	}
+	res := &BoostTasksResult{}
+	if len(assetIDs) > 0 {
+		n, err := s.taskRepo.BoostPending(ctx, assetIDs, taskBoostPriority)
+		if err != nil {
+			return nil, err
+		}
+		res.Boosted += n
+	}
+	if dir != "" {
+		n, err := s.taskRepo.BoostPendingInDirectory(ctx, dir, req.Recursive, taskBoostPriority)
+		if err != nil {
+			return nil, err
+		}
+		res.Boosted += n
+	}
+	return res, nil
+}
+
+// boostVisibleAssets 列表 / 目录浏览返回后在后台提升当前页资产，不阻塞响应
+func (s *TaskService) boostVisibleAssets(assetIDs []string) {
+	if s == nil || len(assetIDs) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), autoBoostTimeout)
+		defer cancel()
+		if _, err := s.taskRepo.BoostPending(ctx, assetIDs, taskBoostPriority); err != nil {
+			logger.Warn("Auto boost tasks failed", zap.Int("assets", len(assetIDs)), zap.Error(err))
+		}
+	}()
+}
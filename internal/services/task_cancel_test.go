@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"media-assistant-os/internal/models"
+)
+
+func TestTaskService_CancelTasks(t *testing.T) {
+	ctx := context.Background()
+	s, assets := newTestTaskService(t)
+	pendingAsset, err := assets.Create(ctx, "/media/pending.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runningAsset, err := assets.Create(ctx, "/media/running.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// thumbnail 依赖 fingerprint，入队时一并创建前置任务
+	thumb, err := s.EnqueueTask(ctx, pendingAsset.ID, "thumbnail", 50, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fp, err := s.taskRepo.GetLatestByAssetAndType(ctx, pendingAsset.ID, "fingerprint")
+	if err != nil || fp == nil {
+		t.Fatalf("fingerprint prerequisite = %v, %v", fp, err)
+	}
+	ocr, err := s.EnqueueTask(ctx, runningAsset.ID, "ocr", 50, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claimed, err := s.ClaimNextTasks(ctx, ClaimNextRequest{WorkerID: "w1", TaskTypes: []string{"ocr"}}); err != nil || len(claimed) != 1 {
+		t.Fatalf("claim = %v, %v", claimed, err)
+	}
+	workCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	untrack := s.trackRunning(ocr.ID, stop)
+	defer untrack()
+
+	if _, err := s.CancelTasks(ctx, CancelTasksRequest{TaskIDs: []string{" "}}); err == nil {
+		t.Fatal("expected task_ids or asset_ids error")
+	}
+
+	// 待处理的前置任务直接取消并级联下游；处理中的只打标记并中断内部 worker
+	res, err := s.CancelTasks(ctx, CancelTasksRequest{TaskIDs: []string{fp.ID, ocr.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{fp.ID, thumb.ID}; !reflect.DeepEqual(res.Cancelled, want) {
+		t.Fatalf("cancelled = %v, want %v", res.Cancelled, want)
+	}
+	if want := []string{ocr.ID}; !reflect.DeepEqual(res.CancelRequested, want) {
+		t.Fatalf("cancel requested = %v, want %v", res.CancelRequested, want)
+	}
+	select {
+	case <-workCtx.Done():
+	default:
+		t.Fatal("running task context was not cancelled")
+	}
+	for _, id := range []string{fp.ID, thumb.ID} {
+		if task, err := s.taskRepo.GetByID(ctx, id); err != nil || task.Status != models.TaskStatusCancelled {
+			t.Fatalf("%s = %+v, %v", id, task, err)
+		}
+	}
+	if task, err := s.taskRepo.GetByID(ctx, ocr.ID); err != nil || task.Status != models.TaskStatusProcessing || !task.CancelRequested {
+		t.Fatalf("running task = %+v, %v", task, err)
+	}
+
+	// 外部 worker 在下次心跳时得知取消，任务落为 cancelled
+	if ok, err := s.HeartbeatTask(ctx, ocr.ID, "w1"); ok || !errors.Is(err, ErrTaskCancelled) {
+		t.Fatalf("heartbeat = %v, %v", ok, err)
+	}
+	if task, err := s.taskRepo.GetByID(ctx, ocr.ID); err != nil || task.Status != models.TaskStatusCancelled {
+		t.Fatalf("running task after heartbeat = %+v, %v", task, err)
+	}
+
+	// 再次取消已结束的任务不产生任何效果
+	res, err = s.CancelTasks(ctx, CancelTasksRequest{AssetIDs: []string{pendingAsset.ID, runningAsset.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Cancelled) != 0 || len(res.CancelRequested) != 0 {
+		t.Fatalf("repeat cancel = %+v", res)
+	}
+}
+
+func TestTaskService_CancelRequestedReport(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		success bool
+		want    models.TaskStatus
+	}{
+		// 失败（通常是 context 被取消）按取消处理
+		{"failure becomes cancelled", false, models.TaskStatusCancelled},
+		// 已完成的结果不浪费
+		{"success still completes", true, models.TaskStatusCompleted},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, assets := newTestTaskService(t)
+			a, err := assets.Create(ctx, "/media/clip.mov", 10, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			task, err := s.EnqueueTask(ctx, a.ID, "ocr", 50, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claimed, err := s.ClaimNextTasks(ctx, ClaimNextRequest{WorkerID: "w1", TaskTypes: []string{"ocr"}}); err != nil || len(claimed) != 1 {
+				t.Fatalf("claim = %v, %v", claimed, err)
+			}
+			if res, err := s.CancelTasks(ctx, CancelTasksRequest{AssetIDs: []string{a.ID}}); err != nil || len(res.CancelRequested) != 1 {
+				t.Fatalf("cancel = %+v, %v", res, err)
+			}
+			if err := s.ReportTaskProgress(ctx, task.ID, "w1", tc.success, "context canceled", nil); err != nil {
+				t.Fatal(err)
+			}
+			if got, err := s.taskRepo.GetByID(ctx, task.ID); err != nil || got.Status != tc.want {
+				t.Fatalf("status = %+v, %v; want %s", got, err, tc.want)
+			}
+		})
+	}
+}
+
+func TestTaskService_BoostTasks(t *testing.T) {
+	ctx := context.Background()
+	s, assets := newTestTaskService(t)
+	paths := map[string]string{}
+	var b string
+	for _, p := range []string{"/media/a.mov", "/media/b.mov", "/media/shoot/c.mov", "/media/shoot/day1/d.mov"} {
+		a, err := assets.Create(ctx, p, 10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.EnqueueTask(ctx, a.ID, "ocr", 50, false); err != nil {
+			t.Fatal(err)
+		}
+		paths[a.ID] = p
+		if p == "/media/b.mov" {
+			b = a.ID
+		}
+	}
+
+	if _, err := s.BoostTasks(ctx, BoostTasksRequest{Directory: " "}); err == nil {
+		t.Fatal("expected asset_ids or directory error")
+	}
+	res, err := s.BoostTasks(ctx, BoostTasksRequest{AssetIDs: []string{b}, Directory: "/media/shoot", Recursive: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Boosted != 2 {
+		t.Fatalf("boosted = %d, want 2", res.Boosted)
+	}
+	// 重复提升不叠加
+	if res, err := s.BoostTasks(ctx, BoostTasksRequest{AssetIDs: []string{b}}); err != nil || res.Boosted != 0 {
+		t.Fatalf("repeat boost = %+v, %v", res, err)
+	}
+
+	// 提升的任务先于更早入队的同优先级任务被领取
+	var order []string
+	for {
+		claimed, err := s.ClaimNextTasks(ctx, ClaimNextRequest{WorkerID: "w1", TaskTypes: []string{"ocr"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(claimed) == 0 {
+			break
+		}
+		order = append(order, paths[claimed[0].AssetID])
+	}
+	want := []string{"/media/b.mov", "/media/shoot/c.mov", "/media/a.mov", "/media/shoot/day1/d.mov"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("claim order = %v, want %v", order, want)
+	}
+}
@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/repos"
+
+	"go.uber.org/zap"
+)
+
+// DLQFilterRequest 死信筛选参数；From/To 支持 2006-01-02、RFC3339 或 Unix 秒
+type DLQFilterRequest struct {
+	TaskType string `json:"task_type"`
+	Error    string `json:"error"`
+	Group    string `json:"group"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+type ListDLQRequest struct {
+	DLQFilterRequest
+	Limit  int
+	Offset int
+}
+
+// DLQSelection 批量重放 / 清除的目标：指定 ids，或按筛选条件（需至少一个条件，或 all=true）
+type DLQSelection struct {
+	DLQFilterRequest
+	IDs []string `json:"ids"`
+	All bool     `json:"all"`
+}
+
+type DLQEntryView struct {
+	models.MediaTaskDLQEntry
+	ErrorGroup string `json:"error_group"`
+}
+
+type ListDLQResult struct {
+	Items []DLQEntryView `json:"items"`
+	Total int            `json:"total"`
+}
+
+// DLQErrorGroup 按归一化错误信息聚合的死信
+type DLQErrorGroup struct {
+	Group     string         `json:"group"`
+	Sample    string         `json:"sample"`
+	Count     int            `json:"count"`
+	TaskTypes map[string]int `json:"task_types"`
+	FirstAt   time.Time      `json:"first_at"`
+	LastAt    time.Time      `json:"last_at"`
+}
+
+type DLQBulkResult struct {
+	Matched  int `json:"matched"`
+	Affected int `json:"affected"`
+	// Stale 重放时原任务已不在 failed 状态（已完成 / 已取消 / 已重新入队）而被跳过的死信数
+	Stale int `json:"stale,omitempty"`
+}
+
+var (
+	dlqQuotedPathRe = regexp.MustCompile(`"[^"]*[/\\][^"]*"|'[^']*[/\\][^']*'`)
+	dlqPathRe       = regexp.MustCompile(`(^|[\s:="'(\[,;])(?:[A-Za-z]:)?[\\/][^\s:"',;()\[\]]+`) // 须在词首，避免误伤 i/o 之类
+	dlqPathJoinRe   = regexp.MustCompile(`<path>(?: [^\s"',;()\[\]<]*[\\/][^\s:"',;()\[\]]*)+`)   // 含空格的路径会被切成多段
+	dlqUUIDRe       = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	dlqHexRe        = regexp.MustCompile(`(?i)\b(?:0x[0-9a-f]+|[0-9a-f]{12,})\b`)
+	dlqNumberRe     = regexp.MustCompile(`\d+(?:\.\d+)?`)
+	dlqSpaceRe      = regexp.MustCompile(`\s+`)
+)
+
+const dlqGroupMaxLen = 200
+
+// NormalizeTaskError 把错误信息中的路径、ID、数字等易变部分替换为占位符，用于聚合同类失败
+func NormalizeTaskError(msg string) string {
+	s := strings.TrimSpace(msg)
+	if s == "" {
+		return "(empty)"
+	}
+	s = dlqQuotedPathRe.ReplaceAllString(s, "<path>")
+	s = dlqPathRe.ReplaceAllString(s, "${1}<path>")
+	s = dlqPathJoinRe.ReplaceAllString(s, "<path>")
+	s = dlqUUIDRe.ReplaceAllString(s, "<id>")
+	s = dlqHexRe.ReplaceAllString(s, "<id>")
+	s = dlqNumberRe.ReplaceAllString(s, "<n>")
+	s = strings.ToLower(dlqSpaceRe.ReplaceAllString(s, " "))
+	if r := []rune(s); len(r) > dlqGroupMaxLen {
+		s = string(r[:dlqGroupMaxLen])
+	}
+	return s
+}
+
+// parseDLQTime 解析时间参数；日期格式的 to 取当天结束
+func parseDLQTime(v string, end bool) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, errors.New("invalid time: " + v)
+}
+
+func (f DLQFilterRequest) repoFilter() (repos.MediaTaskDLQFilter, error) {
+	from, err := parseDLQTime(f.From, false)
+	if err != nil {
+		return repos.MediaTaskDLQFilter{}, err
+	}
+	to, err := parseDLQTime(f.To, true)
+	if err != nil {
+		return repos.MediaTaskDLQFilter{}, err
+	}
+	return repos.MediaTaskDLQFilter{
+		TaskType:      strings.TrimSpace(f.TaskType),
+		ErrorContains: strings.TrimSpace(f.Error),
+		From:          from,
+		To:            to,
+	}, nil
+}
+
+func (f DLQFilterRequest) empty() bool {
+	return strings.TrimSpace(f.TaskType) == "" && strings.TrimSpace(f.Error) == "" &&
+		strings.TrimSpace(f.Group) == "" && strings.TrimSpace(f.From) == "" && strings.TrimSpace(f.To) == ""
+}
+
+// ListDLQ 列出死信；指定 group 时在归一化后再过滤分页
+func (s *TaskService) ListDLQ(ctx context.Context, req ListDLQRequest) (*ListDLQResult, error) {
+	filter, err := req.repoFilter()
+	if err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	offset := req.Offset
+	if offset < 0 {
+		offset = 0
+	}
+
+	group := strings.TrimSpace(req.Group)
+	var entries []models.MediaTaskDLQEntry
+	total := 0
+	if group == "" {
+		entries, total, err = s.taskRepo.ListDLQ(ctx, filter, limit, offset)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		all, _, err := s.taskRepo.ListDLQ(ctx, filter, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		matched := filterDLQByGroup(all, group)
+		total = len(matched)
+		if offset < len(matched) {
+			end := offset + limit
+			if end > len(matched) {
+				end = len(matched)
+			}
+			entries = matched[offset:end]
+		}
+	}
+
+	items := make([]DLQEntryView, 0, len(entries))
+	for i := range entries {
+		items = append(items, DLQEntryView{MediaTaskDLQEntry: entries[i], ErrorGroup: NormalizeTaskError(entries[i].ErrorMessage)})
+	}
+	return &ListDLQResult{Items: items, Total: total}, nil
+}
+
+func (s *TaskService) GetDLQEntry(ctx context.Context, id string) (*DLQEntryView, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return nil, errors.New("id is required")
+	}
+	entry, err := s.taskRepo.GetDLQ(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, errors.New("dlq entry not found")
+	}
+	return &DLQEntryView{MediaTaskDLQEntry: *entry, ErrorGroup: NormalizeTaskError(entry.ErrorMessage)}, nil
+}
+
+// GroupDLQErrors 按归一化错误信息聚合死信，数量多的在前
+func (s *TaskService) GroupDLQErrors(ctx context.Context, req DLQFilterRequest) ([]DLQErrorGroup, error) {
+	filter, err := req.repoFilter()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.taskRepo.CountDLQMessages(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	groups := map[string]*DLQErrorGroup{}
+	sampleCount := map[string]int{}
+	for _, row := range rows {
+		key := NormalizeTaskError(row.ErrorMessage)
+		if req.Group != "" && key != strings.TrimSpace(req.Group) {
+			continue
+		}
+		g := groups[key]
+		if g == nil {
+			g = &DLQErrorGroup{Group: key, TaskTypes: map[string]int{}, FirstAt: row.FirstAt, LastAt: row.LastAt}
+			groups[key] = g
+		}
+		g.Count += row.Count
+		g.TaskTypes[row.TaskType] += row.Count
+		if row.FirstAt.Before(g.FirstAt) {
+			g.FirstAt = row.FirstAt
+		}
+		if row.LastAt.After(g.LastAt) {
+			g.LastAt = row.LastAt
+		}
+		// 用出现次数最多的原始信息作为示例
+		if row.Count > sampleCount[key] {
+			sampleCount[key] = row.Count
+			g.Sample = row.ErrorMessage
+		}
+	}
+	out := make([]DLQErrorGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, *g)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Group < out[j].Group
+	})
+	return out, nil
+}
+
+func filterDLQByGroup(entries []models.MediaTaskDLQEntry, group string) []models.MediaTaskDLQEntry {
+	out := make([]models.MediaTaskDLQEntry, 0, len(entries))
+	for i := range entries {
+		if NormalizeTaskError(entries[i].ErrorMessage) == group {
+			out = append(out, entries[i])
+		}
+	}
+	return out
+}
+
+// selectDLQ 解析批量操作目标
+func (s *TaskService) selectDLQ(ctx context.Context, sel DLQSelection) ([]models.MediaTaskDLQEntry, error) {
+	ids := make([]string, 0, len(sel.IDs))
+	for _, id := range sel.IDs {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 && sel.empty() && !sel.All {
+		return nil, errors.New("ids or filters are required (use all=true to target every entry)")
+	}
+	filter, err := sel.repoFilter()
+	if err != nil {
+		return nil, err
+	}
+	filter.IDs = ids
+	entries, _, err := s.taskRepo.ListDLQ(ctx, filter, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	if group := strings.TrimSpace(sel.Group); group != "" {
+		entries = filterDLQByGroup(entries, group)
+	}
+	return entries, nil
+}
+
+// ReplayDLQ 重放死信：任务重置为 pending 并清零重试次数，资产从 ERROR 恢复为 PENDING
+func (s *TaskService) ReplayDLQ(ctx context.Context, sel DLQSelection) (*DLQBulkResult, error) {
+	entries, err := s.selectDLQ(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	replayed, stale, err := s.taskRepo.ReplayDLQ(ctx, entries)
+	if err != nil {
+		logger.Warn("DLQ replay interrupted", zap.Int("replayed", len(replayed)), zap.Error(err))
+		return nil, err
+	}
+
+	assetIDs := map[string]bool{}
+	for i := range replayed {
+		assetIDs[replayed[i].AssetID] = true
+	}
+	for assetID := range assetIDs {
+		asset, err := s.assetRepo.GetByID(ctx, assetID)
+		if err != nil || asset == nil || asset.Status != "ERROR" {
+			continue
+		}
+		if err := s.assetRepo.UpdateStatus(ctx, assetID, "PENDING"); err != nil {
+			logger.Warn("Failed to reset asset status after DLQ replay", zap.String("asset_id", assetID), zap.Error(err))
+		}
+	}
+
+	if len(replayed) > 0 {
+		s.notifier.Notify()
+	}
+	if s.eventHub != nil && len(replayed) > 0 {
+		s.eventHub.Broadcast(map[string]any{
+			"type": "task_dlq_replayed",
+			"data": map[string]any{
+				"count":     len(replayed),
+				"asset_ids": len(assetIDs),
+			},
+		})
+	}
+	return &DLQBulkResult{Matched: len(entries), Affected: len(replayed), Stale: len(stale)}, nil
+}
+
+// PurgeDLQ 永久删除死信记录（不影响任务本身，资产保持 ERROR）
+func (s *TaskService) PurgeDLQ(ctx context.Context, sel DLQSelection) (*DLQBulkResult, error) {
+	entries, err := s.selectDLQ(ctx, sel)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for i := range entries {
+		ids = append(ids, entries[i].ID)
+	}
+	n, err := s.taskRepo.DeleteDLQ(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if s.eventHub != nil && n > 0 {
+		s.eventHub.Broadcast(map[string]any{
+			"type": "task_dlq_purged",
+			"data": map[string]any{"count": n},
+		})
+	}
+	return &DLQBulkResult{Matched: len(entries), Affected: int(n)}, nil
+}
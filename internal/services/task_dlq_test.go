@@ -0,0 +1,51 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTaskError(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"empty", "   ", "(empty)"},
+		{"unix path", "open /media/clips/a.mov: no such file or directory", "open <path>: no such file or directory"},
+		{"windows path", `open D:\Footage\day1\a.mov: access is denied`, "open <path>: access is denied"},
+		{"quoted path with spaces", `ffprobe failed for "/Volumes/My Drive/clip 01.mov"`, "ffprobe failed for <path>"},
+		{"unquoted path with spaces", "read /Volumes/My Drive/clip.mov: i/o error", "read <path>: i/o error"},
+		{"uuid", "asset 3f2504e0-4f89-11d3-9a0c-0305e82c3301 not found", "asset <id> not found"},
+		{"hex id", "hash 0x1f3a mismatch for 9b1deb4d3b7d4bad", "hash <id> mismatch for <id>"},
+		{"numbers", "exit status 137 after 12.5s", "exit status <n> after <n>s"},
+		{"case and spaces", "Timeout\n\twaiting   FOR worker", "timeout waiting for worker"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := NormalizeTaskError(tc.msg); got != tc.want {
+				t.Fatalf("NormalizeTaskError(%q) = %q, want %q", tc.msg, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeTaskError_Groups(t *testing.T) {
+	same := [][2]string{
+		{"open /a/b.mov: permission denied", `open C:\x y\z.mov: permission denied`},
+		{"ffmpeg exited with code 1 on frame 200", "ffmpeg exited with code 234 on frame 7"},
+		{"task 550e8400-e29b-41d4-a716-446655440000 timed out", "task 6ba7b810-9dad-11d1-80b4-00c04fd430c8 timed out"},
+	}
+	for _, pair := range same {
+		if a, b := NormalizeTaskError(pair[0]), NormalizeTaskError(pair[1]); a != b {
+			t.Errorf("%q and %q grouped apart: %q vs %q", pair[0], pair[1], a, b)
+		}
+	}
+	if a, b := NormalizeTaskError("disk full"), NormalizeTaskError("permission denied"); a == b {
+		t.Errorf("distinct errors share group %q", a)
+	}
+	long := NormalizeTaskError(strings.Repeat("错", dlqGroupMaxLen+50))
+	if n := len([]rune(long)); n != dlqGroupMaxLen {
+		t.Errorf("long group has %d runes, want %d", n, dlqGroupMaxLen)
+	}
+}
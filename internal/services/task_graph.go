@@ -0,0 +1,150 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TaskGraph 任务类型级别的依赖声明（如 metadata 依赖 fingerprint），入队时据此为具体任务建立依赖。
+// 声明按来源（插件 id，内置为 taskGraphCoreOwner）分别保存，deps 为合并结果
+type TaskGraph struct {
+	mu     sync.RWMutex
+	owners map[string]map[string][]string
+	deps   map[string][]string
+}
+
+// taskGraphCoreOwner 内置任务依赖的声明方；插件 id 不能为空，不会与之冲突
+const taskGraphCoreOwner = ""
+
+func NewTaskGraph() *TaskGraph {
+	return &TaskGraph{owners: map[string]map[string][]string{}, deps: map[string][]string{}}
+}
+
+// Register 以 decl（task_type -> 前置 task_types）替换 owner 先前的全部声明，decl 为空时移除；
+// 合并后出现环时整体拒绝
+func (g *TaskGraph) Register(owner string, decl map[string][]string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	owners, deps, err := g.mergeLocked(owner, decl)
+	if err != nil {
+		return err
+	}
+	g.owners, g.deps = owners, deps
+	return nil
+}
+
+// Validate 检查 owner 的声明能否登记，不修改依赖图
+func (g *TaskGraph) Validate(owner string, decl map[string][]string) error {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	_, _, err := g.mergeLocked(owner, decl)
+	return err
+}
+
+func (g *TaskGraph) mergeLocked(owner string, decl map[string][]string) (map[string]map[string][]string, map[string][]string, error) {
+	own := make(map[string][]string, len(decl))
+	for taskType, prereqs := range decl {
+		taskType = strings.TrimSpace(taskType)
+		if taskType == "" {
+			return nil, nil, fmt.Errorf("task type is required in dependency declaration")
+		}
+		for _, p := range normalizeNonEmptyStrings(prereqs) {
+			if p == taskType {
+				return nil, nil, fmt.Errorf("task type %s cannot depend on itself", taskType)
+			}
+			if !containsString(own[taskType], p) {
+				own[taskType] = append(own[taskType], p)
+			}
+		}
+	}
+
+	owners := make(map[string]map[string][]string, len(g.owners)+1)
+	for k, v := range g.owners {
+		if k != owner {
+			owners[k] = v
+		}
+	}
+	if len(own) > 0 {
+		owners[owner] = own
+	}
+	deps := map[string][]string{}
+	for _, set := range owners {
+		for taskType, prereqs := range set {
+			for _, p := range prereqs {
+				if !containsString(deps[taskType], p) {
+					deps[taskType] = append(deps[taskType], p)
+				}
+			}
+		}
+	}
+	for taskType := range deps {
+		sort.Strings(deps[taskType])
+	}
+	if cycle := findTaskGraphCycle(deps); cycle != "" {
+		return nil, nil, fmt.Errorf("task dependency cycle: %s", cycle)
+	}
+	return owners, deps, nil
+}
+
+// Prerequisites 返回任务类型的直接前置类型
+func (g *TaskGraph) Prerequisites(taskType string) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]string{}, g.deps[taskType]...)
+}
+
+// Snapshot 返回完整依赖声明的副本
+func (g *TaskGraph) Snapshot() map[string][]string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	out := make(map[string][]string, len(g.deps))
+	for k, v := range g.deps {
+		out[k] = append([]string{}, v...)
+	}
+	return out
+}
+
+func findTaskGraphCycle(deps map[string][]string) string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var stack []string
+	var visit func(n string) string
+	visit = func(n string) string {
+		switch state[n] {
+		case visiting:
+			for i, s := range stack {
+				if s == n {
+					return strings.Join(append(append([]string{}, stack[i:]...), n), " -> ")
+				}
+			}
+		case done:
+			return ""
+		}
+		state[n] = visiting
+		stack = append(stack, n)
+		for _, p := range deps[n] {
+			if c := visit(p); c != "" {
+				return c
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[n] = done
+		return ""
+	}
+	keys := make([]string, 0, len(deps))
+	for k := range deps {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if c := visit(k); c != "" {
+			return c
+		}
+	}
+	return ""
+}
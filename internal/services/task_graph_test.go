@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"media-assistant-os/internal/repos"
+)
+
+func TestTaskGraph_Register(t *testing.T) {
+	tests := []struct {
+		name    string
+		decl    map[string][]string
+		wantErr string
+	}{
+		{"acyclic", map[string][]string{"ocr": {"thumbnail"}}, ""},
+		{"self dependency", map[string][]string{"ocr": {"ocr"}}, "cannot depend on itself"},
+		{"empty task type", map[string][]string{" ": {"thumbnail"}}, "task type is required"},
+		{"cycle through core", map[string][]string{"fingerprint": {"metadata"}}, "task dependency cycle: "},
+		{"cycle within plugin", map[string][]string{"a": {"b"}, "b": {"a"}}, "task dependency cycle: "},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g := NewTaskGraph()
+			if err := g.Register(taskGraphCoreOwner, map[string][]string{"metadata": {"fingerprint"}}); err != nil {
+				t.Fatal(err)
+			}
+			err := g.Register("p1", tc.decl)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tc.wantErr)
+			}
+			// 被拒绝的声明不得改动依赖图
+			if got := g.Snapshot(); len(got) != 1 || strings.Join(got["metadata"], ",") != "fingerprint" {
+				t.Fatalf("graph changed after rejected registration: %v", got)
+			}
+		})
+	}
+}
+
+func TestTaskGraph_RegisterReplacesOwnerSet(t *testing.T) {
+	g := NewTaskGraph()
+	if err := g.Register("p1", map[string][]string{"ocr": {"thumbnail", "metadata"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Register("p2", map[string][]string{"ocr": {"metadata"}, "caption": {"ocr"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(g.Prerequisites("ocr"), ","); got != "metadata,thumbnail" {
+		t.Fatalf("merged prerequisites = %s", got)
+	}
+
+	// p1 不再声明 thumbnail，p2 的同名依赖保留
+	if err := g.Register("p1", map[string][]string{"ocr": {"metadata"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(g.Prerequisites("ocr"), ","); got != "metadata" {
+		t.Fatalf("prerequisites after re-registration = %s", got)
+	}
+	if err := g.Register("p2", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Prerequisites("caption"); len(got) != 0 {
+		t.Fatalf("caption still gated by %v", got)
+	}
+	if got := strings.Join(g.Prerequisites("ocr"), ","); got != "metadata" {
+		t.Fatalf("clearing p2 removed p1's edge: %s", got)
+	}
+
+	// 仅校验不改变依赖图
+	if err := g.Validate("p2", map[string][]string{"metadata": {"ocr"}}); err == nil {
+		t.Fatal("Validate should reject a cycle")
+	}
+	if err := g.Validate("p1", map[string][]string{"caption": {"ocr"}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := g.Prerequisites("caption"); len(got) != 0 {
+		t.Fatalf("Validate registered %v", got)
+	}
+}
+
+func TestPluginService_RegisterTaskDependencies(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	graph := NewTaskGraph()
+	svc := NewPluginService(repos.NewPluginRuntimeRepo(d.ORM()))
+	svc.SetTaskGraph(graph)
+
+	req := PluginRegistrationRequest{
+		PluginID:         "ocr",
+		Name:             "OCR",
+		Mode:             PluginModeNetworkWorker,
+		Endpoint:         "http://127.0.0.1:1",
+		TaskTypes:        []string{"ocr"},
+		TaskDependencies: map[string][]string{"ocr": {"thumbnail"}},
+	}
+	if _, err := svc.Register(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(graph.Prerequisites("ocr"), ","); got != "thumbnail" {
+		t.Fatalf("prerequisites = %s", got)
+	}
+
+	// 新版本清单去掉了依赖
+	req.TaskDependencies = nil
+	if _, err := svc.Register(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if got := graph.Prerequisites("ocr"); len(got) != 0 {
+		t.Fatalf("dropped dependency still gates ocr: %v", got)
+	}
+
+	// 持久化失败时不登记依赖
+	_ = d.Close()
+	req.TaskDependencies = map[string][]string{"ocr": {"thumbnail"}}
+	if _, err := svc.Register(ctx, req); err == nil {
+		t.Fatal("expected persist error")
+	}
+	if got := graph.Prerequisites("ocr"); len(got) != 0 {
+		t.Fatalf("dependency registered although the plugin was not persisted: %v", got)
+	}
+}
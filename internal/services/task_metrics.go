@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+// 直方图分桶（秒）：等待时间跨度大（秒级到小时级），处理时长集中在秒级
+var (
+	taskWaitBuckets     = []float64{0.1, 0.5, 1, 5, 15, 60, 300, 900, 3600, 14400}
+	taskDurationBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+)
+
+// 处理结果，用作处理时长直方图的维度
+const (
+	TaskOutcomeSuccess = "success"
+	TaskOutcomeFailure = "failure"
+)
+
+type taskHistogram struct {
+	bounds []float64
+	counts []uint64 // 每桶（非累计），最后一个为 +Inf
+	sum    float64
+	count  uint64
+}
+
+func newTaskHistogram(bounds []float64) *taskHistogram {
+	return &taskHistogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+func (h *taskHistogram) observe(v float64) {
+	if v < 0 {
+		v = 0
+	}
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// HistogramBucket 累计桶：值 <= Le 的观测数（+Inf 桶即 Count，不单独列出）
+type HistogramBucket struct {
+	Le    float64 `json:"le"`
+	Count uint64  `json:"count"`
+}
+
+type HistogramSnapshot struct {
+	Buckets []HistogramBucket `json:"buckets"`
+	// Count 为含 +Inf 桶的总观测数
+	Sum   float64 `json:"sum"`
+	Count uint64  `json:"count"`
+	// P50 / P95 按分桶上界估算
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+}
+
+func (h *taskHistogram) snapshot() HistogramSnapshot {
+	out := HistogramSnapshot{Buckets: make([]HistogramBucket, 0, len(h.bounds)), Sum: h.sum, Count: h.count}
+	var cum uint64
+	for i, le := range h.bounds {
+		cum += h.counts[i]
+		out.Buckets = append(out.Buckets, HistogramBucket{Le: le, Count: cum})
+	}
+	out.P50 = h.quantile(0.5)
+	out.P95 = h.quantile(0.95)
+	return out
+}
+
+func (h *taskHistogram) quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	target := uint64(float64(h.count)*q + 0.5)
+	if target == 0 {
+		target = 1
+	}
+	var cum uint64
+	for i, le := range h.bounds {
+		cum += h.counts[i]
+		if cum >= target {
+			return le
+		}
+	}
+	// 落在 +Inf 桶：以最大上界代替
+	return h.bounds[len(h.bounds)-1]
+}
+
+type taskTypeStats struct {
+	enqueued  uint64
+	claimed   uint64
+	completed uint64
+	failed    uint64
+	retried   uint64
+	cancelled uint64
+	wait      *taskHistogram
+	duration  map[string]*taskHistogram
+}
+
+// TaskMetrics 进程内任务队列指标（自启动起累计），供 /api/metrics 与 Prometheus 抓取
+type TaskMetrics struct {
+	mu     sync.Mutex
+	byType map[string]*taskTypeStats
+}
+
+func NewTaskMetrics() *TaskMetrics {
+	return &TaskMetrics{byType: map[string]*taskTypeStats{}}
+}
+
+func (m *TaskMetrics) stats(taskType string) *taskTypeStats {
+	st, ok := m.byType[taskType]
+	if !ok {
+		st = &taskTypeStats{
+			wait: newTaskHistogram(taskWaitBuckets),
+			duration: map[string]*taskHistogram{
+				TaskOutcomeSuccess: newTaskHistogram(taskDurationBuckets),
+				TaskOutcomeFailure: newTaskHistogram(taskDurationBuckets),
+			},
+		}
+		m.byType[taskType] = st
+	}
+	return st
+}
+
+func (m *TaskMetrics) enqueued(taskType string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats(taskType).enqueued++
+}
+
+// claimed 记录领取；入队到领取的等待时间只统计首次尝试（重试的等待包含退避，不代表排队）
+func (m *TaskMetrics) claimed(task *models.MediaTask, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.stats(task.TaskType)
+	st.claimed++
+	if task.RetryCount == 0 && !task.CreatedAt.IsZero() {
+		st.wait.observe(now.Sub(task.CreatedAt).Seconds())
+	}
+}
+
+// finished 记录一次处理结束：outcome 为 success 时计完成，否则按是否重试计重试 / 失败
+func (m *TaskMetrics) finished(task *models.MediaTask, outcome string, retried bool, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st := m.stats(task.TaskType)
+	switch {
+	case outcome == TaskOutcomeSuccess:
+		st.completed++
+	case retried:
+		st.retried++
+	default:
+		st.failed++
+	}
+	if !task.StartedAt.IsZero() {
+		st.duration[outcome].observe(now.Sub(task.StartedAt).Seconds())
+	}
+}
+
+func (m *TaskMetrics) cancelled(taskType string, n int) {
+	if n <= 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stats(taskType).cancelled += uint64(n)
+}
+
+// TaskTypeMetrics 单个任务类型的队列指标
+type TaskTypeMetrics struct {
+	TaskType string `json:"task_type"`
+
+	// 积压（来自数据库，实时）
+	Pending             int     `json:"pending"`
+	Processing          int     `json:"processing"`
+	OldestPendingAgeSec float64 `json:"oldest_pending_age_sec"`
+	DLQ                 int     `json:"dlq"`
+	CompletedLastHour   int     `json:"completed_last_hour"`
+	ThroughputPerMin    float64 `json:"throughput_per_min"`
+
+	// 自进程启动起的累计计数
+	Enqueued  uint64 `json:"enqueued_total"`
+	Claimed   uint64 `json:"claimed_total"`
+	Completed uint64 `json:"completed_total"`
+	Failed    uint64 `json:"failed_total"`
+	Retried   uint64 `json:"retried_total"`
+	Cancelled uint64 `json:"cancelled_total"`
+	// 比率：分母为已结束的处理次数（完成 + 失败 + 重试）
+	SuccessRate float64 `json:"success_rate"`
+	FailureRate float64 `json:"failure_rate"`
+	RetryRate   float64 `json:"retry_rate"`
+
+	WaitSeconds       HistogramSnapshot            `json:"wait_seconds"`
+	ProcessingSeconds map[string]HistogramSnapshot `json:"processing_seconds"`
+}
+
+type QueueMetrics struct {
+	CollectedAt int64             `json:"collected_at"`
+	Types       []TaskTypeMetrics `json:"types"`
+}
+
+// QueueMetrics 汇总队列指标：进程内计数 / 直方图 + 数据库中的积压与吞吐
+func (s *TaskService) QueueMetrics(ctx context.Context) (*QueueMetrics, error) {
+	now := time.Now()
+	stats, err := s.taskRepo.QueueStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	dlq, err := s.taskRepo.CountDLQByType(ctx)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := s.taskRepo.CountCompletedByTypeSince(ctx, now.Add(-time.Hour))
+	if err != nil {
+		return nil, err
+	}
+
+	byType := map[string]*TaskTypeMetrics{}
+	get := func(taskType string) *TaskTypeMetrics {
+		m, ok := byType[taskType]
+		if !ok {
+			m = &TaskTypeMetrics{TaskType: taskType}
+			byType[taskType] = m
+		}
+		return m
+	}
+	for _, st := range stats {
+		m := get(st.TaskType)
+		switch models.TaskStatus(st.Status) {
+		case models.TaskStatusPending:
+			m.Pending = st.Count
+			if !st.OldestCreated.IsZero() {
+				m.OldestPendingAgeSec = now.Sub(st.OldestCreated).Seconds()
+			}
+		case models.TaskStatusProcessing:
+			m.Processing = st.Count
+		}
+	}
+	for _, c := range dlq {
+		get(c.TaskType).DLQ = c.Count
+	}
+	for _, c := range completed {
+		m := get(c.TaskType)
+		m.CompletedLastHour = c.Count
+		m.ThroughputPerMin = float64(c.Count) / 60
+	}
+
+	s.metrics.mu.Lock()
+	for taskType, st := range s.metrics.byType {
+		m := get(taskType)
+		m.Enqueued = st.enqueued
+		m.Claimed = st.claimed
+		m.Completed = st.completed
+		m.Failed = st.failed
+		m.Retried = st.retried
+		m.Cancelled = st.cancelled
+		if attempts := st.completed + st.failed + st.retried; attempts > 0 {
+			m.SuccessRate = float64(st.completed) / float64(attempts)
+			m.FailureRate = float64(st.failed) / float64(attempts)
+			m.RetryRate = float64(st.retried) / float64(attempts)
+		}
+		m.WaitSeconds = st.wait.snapshot()
+		m.ProcessingSeconds = map[string]HistogramSnapshot{}
+		for outcome, h := range st.duration {
+			m.ProcessingSeconds[outcome] = h.snapshot()
+		}
+	}
+	s.metrics.mu.Unlock()
+
+	out := &QueueMetrics{CollectedAt: now.Unix(), Types: make([]TaskTypeMetrics, 0, len(byType))}
+	for _, m := range byType {
+		if m.ProcessingSeconds == nil {
+			m.WaitSeconds = newTaskHistogram(taskWaitBuckets).snapshot()
+			m.ProcessingSeconds = map[string]HistogramSnapshot{
+				TaskOutcomeSuccess: newTaskHistogram(taskDurationBuckets).snapshot(),
+				TaskOutcomeFailure: newTaskHistogram(taskDurationBuckets).snapshot(),
+			}
+		}
+		out.Types = append(out.Types, *m)
+	}
+	sort.Slice(out.Types, func(i, j int) bool { return out.Types[i].TaskType < out.Types[j].TaskType })
+	return out, nil
+}
@@ -0,0 +1,28 @@
+package services
+
+import "sync"
+
+// TaskNotifier 任务可领取信号：有新任务入队、任务完成（解锁下游）或被重放时唤醒等待中的领取者
+type TaskNotifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func NewTaskNotifier() *TaskNotifier {
+	return &TaskNotifier{ch: make(chan struct{})}
+}
+
+// Wait 返回在下一次 Notify 时关闭的通道；需在检查队列之前获取，避免错过信号
+func (n *TaskNotifier) Wait() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ch
+}
+
+// Notify 唤醒所有等待者
+func (n *TaskNotifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	close(n.ch)
+	n.ch = make(chan struct{})
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+func newTestTaskService(t *testing.T) (*TaskService, *repos.AssetRepo) {
+	t.Helper()
+	d := newTestDB(t)
+	orm := d.ORM()
+	assets := repos.NewAssetRepo(orm)
+	return NewTaskService(repos.NewMediaTaskRepo(orm), assets, repos.NewTaskWorkerRepo(orm), nil), assets
+}
+
+func TestTaskNotifier(t *testing.T) {
+	n := NewTaskNotifier()
+	before := n.Wait()
+	n.Notify()
+	select {
+	case <-before:
+	default:
+		t.Fatal("waiter registered before Notify was not woken")
+	}
+	after := n.Wait()
+	select {
+	case <-after:
+		t.Fatal("waiter registered after Notify woke without a new signal")
+	default:
+	}
+}
+
+func TestTaskService_ClaimNextTasksBatchIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	s, assets := newTestTaskService(t)
+	const total = 60
+	for i := 0; i < total; i++ {
+		a, err := assets.Create(ctx, fmt.Sprintf("/media/clip%02d.mov", i), 10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.EnqueueTask(ctx, a.ID, "ocr", 50, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		mu      sync.Mutex
+		claimed = map[string]string{}
+		wg      sync.WaitGroup
+	)
+	errs := make(chan error, 6)
+	for w := 0; w < 6; w++ {
+		workerID := fmt.Sprintf("w%d", w)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				tasks, err := s.ClaimNextTasks(ctx, ClaimNextRequest{WorkerID: workerID, TaskTypes: []string{"ocr"}, Max: 7})
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(tasks) == 0 {
+					return
+				}
+				mu.Lock()
+				for _, task := range tasks {
+					if prev, ok := claimed[task.ID]; ok {
+						mu.Unlock()
+						t.Errorf("task %s claimed by %s and %s", task.ID, prev, workerID)
+						return
+					}
+					if task.WorkerID != workerID || task.Status != models.TaskStatusProcessing {
+						t.Errorf("claimed task %+v", task)
+					}
+					claimed[task.ID] = workerID
+				}
+				mu.Unlock()
+				if len(tasks) > 7 {
+					t.Errorf("batch of %d exceeds max 7", len(tasks))
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if len(claimed) != total {
+		t.Fatalf("claimed %d distinct tasks, want %d", len(claimed), total)
+	}
+}
+
+func TestTaskService_ClaimNextTasksLongPoll(t *testing.T) {
+	ctx := context.Background()
+	s, assets := newTestTaskService(t)
+	a, err := assets.Create(ctx, "/media/clip.mov", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 队列为空：等待到超时后返回空结果
+	start := time.Now()
+	tasks, err := s.ClaimNextTasks(ctx, ClaimNextRequest{WorkerID: "w1", TaskTypes: []string{"ocr"}, Wait: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 0 || tasks == nil {
+		t.Fatalf("tasks = %v, want an empty batch", tasks)
+	}
+	if waited := time.Since(start); waited < 100*time.Millisecond || waited > claimRecheckInterval {
+		t.Fatalf("empty claim returned after %v", waited)
+	}
+
+	// 等待期间入队立即唤醒，而不是等到兜底重查
+	type result struct {
+		n   int
+		err error
+		at  time.Time
+	}
+	done := make(chan result, 1)
+	go func() {
+		tasks, err := s.ClaimNextTasks(ctx, ClaimNextRequest{WorkerID: "w1", TaskTypes: []string{"ocr"}, Wait: 10 * time.Second})
+		done <- result{len(tasks), err, time.Now()}
+	}()
+	time.Sleep(50 * time.Millisecond)
+	enqueued := time.Now()
+	if _, err := s.EnqueueTask(ctx, a.ID, "ocr", 50, false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-done:
+		if r.err != nil || r.n != 1 {
+			t.Fatalf("woken claim = %d tasks, %v", r.n, r.err)
+		}
+		if r.at.Sub(enqueued) >= claimRecheckInterval {
+			t.Fatalf("claim woke %v after enqueue, not by notification", r.at.Sub(enqueued))
+		}
+	case <-time.After(claimRecheckInterval):
+		t.Fatal("enqueue did not wake the long poll")
+	}
+
+	// ctx 取消时提前返回
+	cctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	tasks, err = s.ClaimNextTasks(cctx, ClaimNextRequest{WorkerID: "w1", TaskTypes: []string{"ocr"}, Wait: 10 * time.Second})
+	if err != context.DeadlineExceeded || len(tasks) != 0 {
+		t.Fatalf("cancelled claim = %v, %v", tasks, err)
+	}
+
+	if _, err := s.ClaimNextTasks(ctx, ClaimNextRequest{WorkerID: " "}); err == nil {
+		t.Fatal("expected worker_id error")
+	}
+}
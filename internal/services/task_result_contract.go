@@ -0,0 +1,436 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+// 结果映射的写入目标
+const (
+	ResultTargetAssetTags    = "asset_tags"
+	ResultTargetProjectNotes = "project_notes"
+	ResultTargetMediaMeta    = "media_meta"
+)
+
+const (
+	maxResultTags       = 200
+	maxResultTagLength  = 64
+	maxResultNoteLength = 20000
+)
+
+// PluginResultContract 插件任务类型的结果约定：JSON Schema（子集）+ 写入映射
+type PluginResultContract struct {
+	Schema   json.RawMessage       `json:"schema,omitempty"`
+	Mappings []PluginResultMapping `json:"mappings"`
+}
+
+// PluginResultMapping 将结果中的字段写入核心数据
+//   - asset_tags:    source 为字符串数组（如 "tags[]"），写入命名空间 namespace（默认插件 id）下的标签
+//   - project_notes: source 为字符串（如 "caption"），写入资产所属项目的笔记，类型为 note_type
+//   - media_meta:    source 为对象（如 "fields"），写入 media_meta.extra.<plugin_id>
+type PluginResultMapping struct {
+	Source    string `json:"source"`
+	Target    string `json:"target"`
+	Namespace string `json:"namespace,omitempty"`
+	NoteType  string `json:"note_type,omitempty"`
+	Title     string `json:"title,omitempty"`
+}
+
+// resultSchema 支持的 JSON Schema 子集：type / properties / required / items / enum / additionalProperties
+type resultSchema struct {
+	Type                 string                   `json:"type"`
+	Properties           map[string]*resultSchema `json:"properties"`
+	Required             []string                 `json:"required"`
+	Items                *resultSchema            `json:"items"`
+	Enum                 []any                    `json:"enum"`
+	AdditionalProperties *bool                    `json:"additionalProperties"`
+}
+
+var resultSchemaTypes = map[string]bool{
+	"": true, "object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true,
+}
+
+func parseResultSchema(raw json.RawMessage) (*resultSchema, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var s resultSchema
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.check("$"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *resultSchema) check(path string) error {
+	if !resultSchemaTypes[s.Type] {
+		return fmt.Errorf("schema %s: unsupported type %q", path, s.Type)
+	}
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("schema %s.%s: empty schema", path, name)
+		}
+		if err := p.check(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// validate 校验值是否符合 schema，错误信息带字段路径
+func (s *resultSchema) validate(v any, path string) error {
+	if s == nil {
+		return nil
+	}
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+	switch s.Type {
+	case "":
+		return nil
+	case "null":
+		if v != nil {
+			return fmt.Errorf("%s: expected null", path)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: expected string", path)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: expected boolean", path)
+		}
+	case "number", "integer":
+		n, ok := v.(float64)
+		if !ok {
+			return fmt.Errorf("%s: expected %s", path, s.Type)
+		}
+		if s.Type == "integer" && n != math.Trunc(n) {
+			return fmt.Errorf("%s: expected integer", path)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected array", path)
+		}
+		for i, item := range arr {
+			if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s: required", path, name)
+			}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prop, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s: unexpected property", path, k)
+				}
+				continue
+			}
+			if err := prop.validate(obj[k], path+"."+k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resultSourcePath 解析映射的 source："result.tags[]" / "tags[]" / "caption" / "fields.extra"
+func resultSourcePath(source string) ([]string, bool, error) {
+	src := strings.TrimPrefix(strings.TrimSpace(source), "result.")
+	if src == "result" {
+		return nil, false, nil
+	}
+	array := strings.HasSuffix(src, "[]")
+	src = strings.TrimSuffix(src, "[]")
+	if src == "" {
+		return nil, false, errors.New("source is required")
+	}
+	parts := strings.Split(src, ".")
+	for _, p := range parts {
+		if p == "" || strings.ContainsAny(p, "[] ") {
+			return nil, false, fmt.Errorf("invalid source path %q", source)
+		}
+	}
+	return parts, array, nil
+}
+
+func lookupResultPath(data map[string]any, parts []string) (any, bool) {
+	var cur any = data
+	for _, p := range parts {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = obj[p]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// compiledResultContract 注册时校验并解析后的约定
+type compiledResultContract struct {
+	pluginID string
+	taskType string
+	schema   *resultSchema
+	mappings []compiledResultMapping
+}
+
+type compiledResultMapping struct {
+	PluginResultMapping
+	path  []string
+	array bool
+}
+
+func compileResultContract(pluginID, taskType string, c PluginResultContract) (*compiledResultContract, error) {
+	schema, err := parseResultSchema(c.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.Mappings) == 0 {
+		return nil, errors.New("at least one mapping is required")
+	}
+	out := &compiledResultContract{pluginID: pluginID, taskType: taskType, schema: schema}
+	hasMeta := false
+	for i, m := range c.Mappings {
+		m.Source = strings.TrimSpace(m.Source)
+		m.Target = strings.TrimSpace(m.Target)
+		path, array, err := resultSourcePath(m.Source)
+		if err != nil {
+			return nil, fmt.Errorf("mappings[%d]: %w", i, err)
+		}
+		switch m.Target {
+		case ResultTargetAssetTags:
+			if !array {
+				return nil, fmt.Errorf("mappings[%d]: asset_tags source must be an array (e.g. tags[])", i)
+			}
+			m.Namespace = strings.TrimSpace(m.Namespace)
+			if m.Namespace == "" {
+				m.Namespace = pluginID
+			}
+			if strings.Contains(m.Namespace, ":") {
+				return nil, fmt.Errorf("mappings[%d]: namespace must not contain ':'", i)
+			}
+		case ResultTargetProjectNotes:
+			if array || path == nil {
+				return nil, fmt.Errorf("mappings[%d]: project_notes source must be a string field", i)
+			}
+			m.NoteType = strings.TrimSpace(m.NoteType)
+			if m.NoteType == "" {
+				m.NoteType = pluginID + "." + taskType
+			}
+			m.Title = strings.TrimSpace(m.Title)
+		case ResultTargetMediaMeta:
+			if array {
+				return nil, fmt.Errorf("mappings[%d]: media_meta source must be an object", i)
+			}
+			if hasMeta {
+				return nil, fmt.Errorf("mappings[%d]: only one media_meta mapping is allowed", i)
+			}
+			hasMeta = true
+		default:
+			return nil, fmt.Errorf("mappings[%d]: unsupported target %q", i, m.Target)
+		}
+		out.mappings = append(out.mappings, compiledResultMapping{PluginResultMapping: m, path: path, array: array})
+	}
+	return out, nil
+}
+
+// build 校验结果并转换为一次事务写入；结果不符合约定时返回错误
+func (c *compiledResultContract) build(task *models.MediaTask, workerID string, result map[string]any) (*repos.TaskResultApply, error) {
+	// 统一为 JSON 语义的值（数字为 float64），与 schema 校验一致
+	raw, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data = map[string]any{}
+	}
+	if err := c.schema.validate(data, "result"); err != nil {
+		return nil, err
+	}
+
+	apply := &repos.TaskResultApply{
+		TaskID:   task.ID,
+		WorkerID: workerID,
+		AssetID:  task.AssetID,
+		TaskType: task.TaskType,
+		PluginID: c.pluginID,
+	}
+	for _, m := range c.mappings {
+		var v any = data
+		if m.path != nil {
+			var ok bool
+			if v, ok = lookupResultPath(data, m.path); !ok || v == nil {
+				// 可选字段缺失时跳过该映射
+				continue
+			}
+		}
+		switch m.Target {
+		case ResultTargetAssetTags:
+			arr, ok := v.([]any)
+			if !ok {
+				return nil, fmt.Errorf("result.%s: expected array of strings", m.Source)
+			}
+			if len(arr) > maxResultTags {
+				return nil, fmt.Errorf("result.%s: at most %d tags", m.Source, maxResultTags)
+			}
+			names := make([]string, 0, len(arr))
+			for i, item := range arr {
+				name, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("result.%s[%d]: expected string", strings.TrimSuffix(m.Source, "[]"), i)
+				}
+				name = strings.TrimSpace(name)
+				if name == "" || containsString(names, name) {
+					continue
+				}
+				if len([]rune(name)) > maxResultTagLength {
+					return nil, fmt.Errorf("result.%s[%d]: tag longer than %d characters", strings.TrimSuffix(m.Source, "[]"), i, maxResultTagLength)
+				}
+				names = append(names, name)
+			}
+			apply.Tags = append(apply.Tags, repos.TaskResultTags{Source: m.Source, Namespace: m.Namespace, Names: names})
+		case ResultTargetProjectNotes:
+			text, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("result.%s: expected string", m.Source)
+			}
+			if len(text) > maxResultNoteLength {
+				return nil, fmt.Errorf("result.%s: longer than %d bytes", m.Source, maxResultNoteLength)
+			}
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			apply.Notes = append(apply.Notes, repos.TaskResultNote{Source: m.Source, NoteType: m.NoteType, Title: m.Title, Content: text})
+		case ResultTargetMediaMeta:
+			if _, ok := v.(map[string]any); !ok {
+				return nil, fmt.Errorf("result.%s: expected object", m.Source)
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			apply.Meta = append(apply.Meta, repos.TaskResultMeta{Source: m.Source, Key: c.pluginID, Value: b})
+		}
+	}
+	return apply, nil
+}
+
+// TaskResultContracts 任务类型 -> 结果约定；插件注册时写入，任务上报结果时查询
+type TaskResultContracts struct {
+	mu        sync.RWMutex
+	contracts map[string]*compiledResultContract
+}
+
+func NewTaskResultContracts() *TaskResultContracts {
+	return &TaskResultContracts{contracts: map[string]*compiledResultContract{}}
+}
+
+// Validate 检查插件的结果约定能否登记，不修改登记表
+func (r *TaskResultContracts) Validate(pluginID string, decl map[string]PluginResultContract) error {
+	compiled, err := compileResultContracts(pluginID, decl)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.conflictLocked(pluginID, compiled)
+}
+
+// Register 以 decl 替换插件已登记的全部结果约定（decl 为空时清除）；同一任务类型已被其他插件声明时拒绝
+func (r *TaskResultContracts) Register(pluginID string, decl map[string]PluginResultContract) error {
+	compiled, err := compileResultContracts(pluginID, decl)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.conflictLocked(pluginID, compiled); err != nil {
+		return err
+	}
+	for taskType, c := range r.contracts {
+		if c.pluginID == pluginID {
+			delete(r.contracts, taskType)
+		}
+	}
+	for taskType, c := range compiled {
+		r.contracts[taskType] = c
+	}
+	return nil
+}
+
+func compileResultContracts(pluginID string, decl map[string]PluginResultContract) (map[string]*compiledResultContract, error) {
+	compiled := make(map[string]*compiledResultContract, len(decl))
+	for taskType, c := range decl {
+		taskType = strings.TrimSpace(taskType)
+		if taskType == "" {
+			return nil, errors.New("result_contracts: task type is required")
+		}
+		cc, err := compileResultContract(pluginID, taskType, c)
+		if err != nil {
+			return nil, fmt.Errorf("result_contracts.%s: %w", taskType, err)
+		}
+		compiled[taskType] = cc
+	}
+	return compiled, nil
+}
+
+func (r *TaskResultContracts) conflictLocked(pluginID string, compiled map[string]*compiledResultContract) error {
+	for taskType := range compiled {
+		if existing, ok := r.contracts[taskType]; ok && existing.pluginID != pluginID {
+			return fmt.Errorf("result_contracts.%s: already declared by plugin %s", taskType, existing.pluginID)
+		}
+	}
+	return nil
+}
+
+func (r *TaskResultContracts) get(taskType string) (*compiledResultContract, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.contracts[taskType]
+	return c, ok
+}
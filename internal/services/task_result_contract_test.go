@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+func TestResultSchema_Validate(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"required": ["tags", "kind"],
+		"additionalProperties": false,
+		"properties": {
+			"tags": {"type": "array", "items": {"type": "string"}},
+			"kind": {"type": "string", "enum": ["photo", "video"]},
+			"score": {"type": "number"},
+			"count": {"type": "integer"},
+			"nsfw": {"type": "boolean"},
+			"extra": {"type": "object", "required": ["model"], "properties": {"model": {"type": "string"}, "layers": {"type": "array", "items": {"type": "integer"}}}},
+			"none": {"type": "null"},
+			"any": {}
+		}
+	}`
+	s, err := parseResultSchema(json.RawMessage(schema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{"minimal", `{"tags":[],"kind":"photo"}`, ""},
+		{"all fields", `{"tags":["a"],"kind":"video","score":0.5,"count":3,"nsfw":false,"extra":{"model":"m","layers":[1,2]},"none":null,"any":[1,"x"]}`, ""},
+		{"missing required", `{"tags":[]}`, "result.kind: required"},
+		{"wrong type", `{"tags":"a","kind":"photo"}`, "result.tags: expected array"},
+		{"wrong item type", `{"tags":["a",1],"kind":"photo"}`, "result.tags[1]: expected string"},
+		{"not in enum", `{"tags":[],"kind":"audio"}`, "result.kind: value not in enum"},
+		{"integer with fraction", `{"tags":[],"kind":"photo","count":1.5}`, "result.count: expected integer"},
+		{"number as string", `{"tags":[],"kind":"photo","score":"1"}`, "result.score: expected number"},
+		{"boolean", `{"tags":[],"kind":"photo","nsfw":"yes"}`, "result.nsfw: expected boolean"},
+		{"null", `{"tags":[],"kind":"photo","none":0}`, "result.none: expected null"},
+		{"nested required", `{"tags":[],"kind":"photo","extra":{}}`, "result.extra.model: required"},
+		{"nested item", `{"tags":[],"kind":"photo","extra":{"model":"m","layers":[1,2.5]}}`, "result.extra.layers[1]: expected integer"},
+		{"additional property", `{"tags":[],"kind":"photo","other":1}`, "result.other: unexpected property"},
+		{"not an object", `[]`, "result: expected object"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var v any
+			if err := json.Unmarshal([]byte(tc.value), &v); err != nil {
+				t.Fatal(err)
+			}
+			err := s.validate(v, "result")
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.wantErr {
+				t.Fatalf("error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestCompileResultContract(t *testing.T) {
+	tests := []struct {
+		name     string
+		contract string
+		wantErr  string
+		check    func(t *testing.T, c *compiledResultContract)
+	}{
+		{
+			name:     "defaults namespace and note type",
+			contract: `{"mappings":[{"source":"result.tags[]","target":"asset_tags"},{"source":"caption","target":"project_notes"},{"source":"fields","target":"media_meta"}]}`,
+			check: func(t *testing.T, c *compiledResultContract) {
+				if len(c.mappings) != 3 {
+					t.Fatalf("mappings = %+v", c.mappings)
+				}
+				if m := c.mappings[0]; m.Namespace != "tagger" || !m.array || strings.Join(m.path, ".") != "tags" {
+					t.Errorf("tags mapping = %+v", m)
+				}
+				if m := c.mappings[1]; m.NoteType != "tagger.ai_tags" || m.array {
+					t.Errorf("note mapping = %+v", m)
+				}
+			},
+		},
+		{
+			name:     "whole result as media meta",
+			contract: `{"mappings":[{"source":"result","target":"media_meta"}]}`,
+			check: func(t *testing.T, c *compiledResultContract) {
+				if c.mappings[0].path != nil {
+					t.Errorf("path = %v, want nil", c.mappings[0].path)
+				}
+			},
+		},
+		{name: "no mappings", contract: `{"mappings":[]}`, wantErr: "at least one mapping is required"},
+		{name: "bad schema type", contract: `{"schema":{"type":"date"},"mappings":[{"source":"x","target":"media_meta"}]}`, wantErr: `unsupported type "date"`},
+		{name: "tags need array source", contract: `{"mappings":[{"source":"tags","target":"asset_tags"}]}`, wantErr: "asset_tags source must be an array"},
+		{name: "namespace without colon", contract: `{"mappings":[{"source":"tags[]","target":"asset_tags","namespace":"a:b"}]}`, wantErr: "namespace must not contain ':'"},
+		{name: "notes need string field", contract: `{"mappings":[{"source":"result","target":"project_notes"}]}`, wantErr: "project_notes source must be a string field"},
+		{name: "meta needs object", contract: `{"mappings":[{"source":"x[]","target":"media_meta"}]}`, wantErr: "media_meta source must be an object"},
+		{name: "single meta mapping", contract: `{"mappings":[{"source":"a","target":"media_meta"},{"source":"b","target":"media_meta"}]}`, wantErr: "only one media_meta mapping"},
+		{name: "unknown target", contract: `{"mappings":[{"source":"a","target":"assets"}]}`, wantErr: `unsupported target "assets"`},
+		{name: "invalid path", contract: `{"mappings":[{"source":"a..b","target":"media_meta"}]}`, wantErr: "invalid source path"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var decl PluginResultContract
+			if err := json.Unmarshal([]byte(tc.contract), &decl); err != nil {
+				t.Fatal(err)
+			}
+			c, err := compileResultContract("tagger", "ai_tags", decl)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, c)
+		})
+	}
+}
+
+func TestCompiledResultContract_Build(t *testing.T) {
+	var decl PluginResultContract
+	_ = json.Unmarshal([]byte(`{
+		"schema": {"type":"object","required":["tags"]},
+		"mappings": [{"source":"tags[]","target":"asset_tags"},{"source":"caption","target":"project_notes"},{"source":"fields","target":"media_meta"}]
+	}`), &decl)
+	c, err := compileResultContract("tagger", "ai_tags", decl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := &models.MediaTask{ID: "t1", AssetID: "a1", TaskType: "ai_tags"}
+
+	apply, err := c.build(task, "w1", map[string]any{"tags": []string{" cat ", "cat", "", "dog"}, "fields": map[string]any{"n": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apply.Tags) != 1 || strings.Join(apply.Tags[0].Names, ",") != "cat,dog" {
+		t.Errorf("tags = %+v", apply.Tags)
+	}
+	if len(apply.Notes) != 0 {
+		t.Errorf("missing optional caption should be skipped: %+v", apply.Notes)
+	}
+	if len(apply.Meta) != 1 || apply.Meta[0].Key != "tagger" || string(apply.Meta[0].Value) != `{"n":1}` {
+		t.Errorf("meta = %+v", apply.Meta)
+	}
+
+	if _, err := c.build(task, "w1", map[string]any{"caption": "x"}); err == nil || !strings.Contains(err.Error(), "result.tags: required") {
+		t.Errorf("schema error = %v", err)
+	}
+	if _, err := c.build(task, "w1", map[string]any{"tags": []any{"a", 1}}); err == nil {
+		t.Error("non-string tag should be rejected")
+	}
+}
+
+func TestTaskResultContracts_RegisterReplaces(t *testing.T) {
+	contract := PluginResultContract{Mappings: []PluginResultMapping{{Source: "tags[]", Target: ResultTargetAssetTags}}}
+	r := NewTaskResultContracts()
+	if err := r.Register("p1", map[string]PluginResultContract{"a": contract, "b": contract}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register("p2", map[string]PluginResultContract{"a": contract}); err == nil {
+		t.Fatal("another plugin must not take over a declared task type")
+	}
+	if err := r.Validate("p2", map[string]PluginResultContract{"a": contract}); err == nil {
+		t.Fatal("Validate should report the same conflict")
+	}
+
+	if err := r.Register("p1", map[string]PluginResultContract{"b": contract}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.get("a"); ok {
+		t.Fatal("contract dropped from the manifest is still active")
+	}
+	if err := r.Register("p1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.get("b"); ok {
+		t.Fatal("registering without contracts must clear the plugin's set")
+	}
+	if err := r.Register("p2", map[string]PluginResultContract{"a": contract}); err != nil {
+		t.Fatalf("freed task type should be available: %v", err)
+	}
+}
+
+func TestPluginService_RegisterResultContracts(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+	results := NewTaskResultContracts()
+	svc := NewPluginService(repos.NewPluginRuntimeRepo(d.ORM()))
+	svc.SetTaskResultContracts(results)
+
+	contract := PluginResultContract{Mappings: []PluginResultMapping{{Source: "tags[]", Target: ResultTargetAssetTags}}}
+	req := PluginRegistrationRequest{
+		PluginID:        "tagger",
+		Name:            "Tagger",
+		Mode:            PluginModeNetworkWorker,
+		Endpoint:        "http://127.0.0.1:1",
+		TaskTypes:       []string{"ai_tags"},
+		ResultContracts: map[string]PluginResultContract{"ai_tags": contract},
+	}
+	if _, err := svc.Register(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := results.get("ai_tags"); !ok {
+		t.Fatal("contract not registered")
+	}
+
+	// 新版本清单不再声明 result_contracts
+	req.ResultContracts = nil
+	if _, err := svc.Register(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := results.get("ai_tags"); ok {
+		t.Fatal("stale contract still enforced after re-registration")
+	}
+
+	// 持久化失败时不登记约定
+	_ = d.Close()
+	req.ResultContracts = map[string]PluginResultContract{"ai_tags": contract}
+	if _, err := svc.Register(ctx, req); err == nil {
+		t.Fatal("expected persist error")
+	}
+	if _, ok := results.get("ai_tags"); ok {
+		t.Fatal("contract registered although the plugin was not persisted")
+	}
+}
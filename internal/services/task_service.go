@@ -9,6 +9,7 @@ import (
 	"media-assistant-os/internal/pkg/logger"
 	"media-assistant-os/internal/repos"
 	"strings"
+	"sync"
 	"time"
 
 	"go.uber.org/zap"
@@ -17,21 +18,41 @@ import (
 type TaskService struct {
 	taskRepo  *repos.MediaTaskRepo
 	assetRepo *repos.AssetRepo
+	workers   *repos.TaskWorkerRepo
 	eventHub  *EventHub
+	graph     *TaskGraph
+	results   *TaskResultContracts
+	notifier  *TaskNotifier
+	metrics   *TaskMetrics
+	sweepMu   sync.Mutex
+	lastSweep time.Time
+	// running 内部 worker 正在执行的任务 -> context.CancelFunc
+	running sync.Map
+	// claimMu 串行化领取决策，保证并发上限在多个 worker 同时领取时仍然成立
+	claimMu sync.Mutex
 	// We might need to call assetService to update asset status
 }
 
 const (
 	defaultTaskLease = 30 * time.Minute
+	minTaskLease     = 10 * time.Second
+	maxTaskLease     = 24 * time.Hour
+	// maxClaimBatch 单次批量领取上限
+	maxClaimBatch = 50
+	// claimRecheckInterval 长轮询期间的兜底重查间隔（重试退避到期、租约过期不会触发通知）
+	claimRecheckInterval = 2 * time.Second
+	// leaseSweepInterval 过期租约回收的最小间隔，避免每次领取都写库
+	leaseSweepInterval = 5 * time.Second
 )
 
 var coreTaskPlan = []struct {
-	taskType string
-	priority int
+	taskType  string
+	priority  int
+	dependsOn []string
 }{
 	{taskType: "fingerprint", priority: 200},
-	{taskType: "metadata", priority: 100},
-	{taskType: "thumbnail", priority: 50},
+	{taskType: "metadata", priority: 100, dependsOn: []string{"fingerprint"}},
+	{taskType: "thumbnail", priority: 50, dependsOn: []string{"fingerprint"}},
 }
 
 func coreTaskTypes() []string {
@@ -42,12 +63,100 @@ func coreTaskTypes() []string {
 	return taskTypes
 }
 
-func NewTaskService(taskRepo *repos.MediaTaskRepo, assetRepo *repos.AssetRepo, eventHub *EventHub) *TaskService {
+func NewTaskService(taskRepo *repos.MediaTaskRepo, assetRepo *repos.AssetRepo, workerRepo *repos.TaskWorkerRepo, eventHub *EventHub) *TaskService {
+	graph := NewTaskGraph()
+	core := map[string][]string{}
+	for i := range coreTaskPlan {
+		core[coreTaskPlan[i].taskType] = coreTaskPlan[i].dependsOn
+	}
+	// storyboard / waveform 不在初始计划内：按文件类型在前置任务完成后入队
+	core[storyboardTaskType] = []string{"fingerprint"}
+	core[waveformTaskType] = []string{"fingerprint"}
+	_ = graph.Register(taskGraphCoreOwner, core)
 	return &TaskService{
 		taskRepo:  taskRepo,
 		assetRepo: assetRepo,
+		workers:   workerRepo,
 		eventHub:  eventHub,
+		graph:     graph,
+		results:   NewTaskResultContracts(),
+		notifier:  NewTaskNotifier(),
+		metrics:   NewTaskMetrics(),
+	}
+}
+
+// TaskGraph 返回任务类型依赖声明，插件注册时按插件替换各自的声明
+func (s *TaskService) TaskGraph() *TaskGraph {
+	return s.graph
+}
+
+// ResultContracts 返回插件任务类型的结果约定，插件注册时写入
+func (s *TaskService) ResultContracts() *TaskResultContracts {
+	return s.results
+}
+
+// ListResultProvenance 查询插件结果写入来源
+func (s *TaskService) ListResultProvenance(ctx context.Context, assetID, taskID string, limit int) ([]models.TaskResultProvenance, error) {
+	assetID = strings.TrimSpace(assetID)
+	taskID = strings.TrimSpace(taskID)
+	if assetID == "" && taskID == "" {
+		return nil, errors.New("asset_id or task_id is required")
+	}
+	rows, err := s.taskRepo.ListResultProvenance(ctx, assetID, taskID, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Value = decodeRawJSON(rows[i].ValueJSON, "null")
+	}
+	return rows, nil
+}
+
+func coreTaskPriority(taskType string) (int, bool) {
+	for i := range coreTaskPlan {
+		if coreTaskPlan[i].taskType == taskType {
+			return coreTaskPlan[i].priority, true
+		}
+	}
+	return 0, false
+}
+
+// createTask 按依赖声明创建任务：前置任务缺失、失败或已取消时先补建，再连同依赖一起落库
+func (s *TaskService) createTask(ctx context.Context, assetID, taskType string, priority int, path map[string]bool) (*models.MediaTask, error) {
+	if path[taskType] {
+		return nil, fmt.Errorf("task dependency cycle at %s", taskType)
+	}
+	path[taskType] = true
+	defer delete(path, taskType)
+
+	var parents []string
+	for _, prereq := range s.graph.Prerequisites(taskType) {
+		parent, err := s.taskRepo.GetLatestByAssetAndType(ctx, assetID, prereq)
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil || parent.Status == models.TaskStatusFailed || parent.Status == models.TaskStatusCancelled {
+			prereqPriority, ok := coreTaskPriority(prereq)
+			if !ok || prereqPriority < priority {
+				prereqPriority = priority
+			}
+			parent, err = s.createTask(ctx, assetID, prereq, prereqPriority, path)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if parent.Status != models.TaskStatusCompleted {
+			parents = append(parents, parent.ID)
+		}
 	}
+	task, err := s.taskRepo.CreateWithDeps(ctx, assetID, taskType, priority, parents)
+	if err == nil {
+		s.metrics.enqueued(taskType)
+	}
+	if err == nil && task.Status == models.TaskStatusPending {
+		s.notifier.Notify()
+	}
+	return task, err
 }
 
 // ResetAllProcessingTasks resets all tasks in processing state to pending.
@@ -59,7 +168,7 @@ func (s *TaskService) ResetAllProcessingTasks(ctx context.Context) (int64, error
 // CreateInitialTasks generates the necessary tasks for a new asset
 func (s *TaskService) CreateInitialTasks(ctx context.Context, assetID string) error {
 	for i := range coreTaskPlan {
-		_, err := s.taskRepo.Create(ctx, assetID, coreTaskPlan[i].taskType, coreTaskPlan[i].priority)
+		_, err := s.createTask(ctx, assetID, coreTaskPlan[i].taskType, coreTaskPlan[i].priority, map[string]bool{})
 		if err != nil {
 			return err
 		}
@@ -90,7 +199,7 @@ func (s *TaskService) EnqueueTask(ctx context.Context, assetID, taskType string,
 			}
 		}
 	}
-	return s.taskRepo.Create(ctx, assetID, taskType, priority)
+	return s.createTask(ctx, assetID, taskType, priority, map[string]bool{})
 }
 
 // ListPendingTasks for external workers
@@ -99,17 +208,117 @@ func (s *TaskService) ListPendingTasks(ctx context.Context, taskTypes []string)
 	return s.taskRepo.GetNextPending(ctx, taskTypes)
 }
 
+// ClaimNextRequest 原子领取：按类型领取至多 Max 个任务；队列为空时最多等待 Wait
+type ClaimNextRequest struct {
+	WorkerID  string
+	TaskTypes []string
+	Lease     time.Duration
+	Max       int
+	Wait      time.Duration
+}
+
+// ClaimNextTasks 领取下一批任务（长轮询）：无可领取任务时阻塞，直到有任务入队 / 解锁、超时或 ctx 取消
+func (s *TaskService) ClaimNextTasks(ctx context.Context, req ClaimNextRequest) ([]models.MediaTask, error) {
+	workerID := strings.TrimSpace(req.WorkerID)
+	if workerID == "" {
+		return nil, errors.New("worker_id is required")
+	}
+	lease := req.Lease
+	if lease <= 0 {
+		lease = defaultTaskLease
+	}
+	if lease < minTaskLease {
+		lease = minTaskLease
+	}
+	if lease > maxTaskLease {
+		lease = maxTaskLease
+	}
+	max := req.Max
+	if max <= 0 {
+		max = 1
+	}
+	if max > maxClaimBatch {
+		max = maxClaimBatch
+	}
+	taskTypes := normalizeNonEmptyStrings(req.TaskTypes)
+
+	deadline := time.Now().Add(req.Wait)
+	for {
+		wake := s.notifier.Wait()
+		s.sweepExpiredLeases(ctx)
+		tasks, err := s.claimWithLimits(ctx, workerID, taskTypes, time.Now().Add(lease), max)
+		if err != nil {
+			return nil, err
+		}
+		if len(tasks) > 0 {
+			now := time.Now()
+			for i := range tasks {
+				s.metrics.claimed(&tasks[i], now)
+				s.broadcastTask(&tasks[i], "task_claimed")
+			}
+			return tasks, nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return []models.MediaTask{}, nil
+		}
+		if remaining > claimRecheckInterval {
+			remaining = claimRecheckInterval
+		}
+		timer := time.NewTimer(remaining)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return []models.MediaTask{}, ctx.Err()
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// sweepExpiredLeases 回收租约过期的任务（限频）
+func (s *TaskService) sweepExpiredLeases(ctx context.Context) {
+	s.sweepMu.Lock()
+	if time.Since(s.lastSweep) < leaseSweepInterval {
+		s.sweepMu.Unlock()
+		return
+	}
+	s.lastSweep = time.Now()
+	s.sweepMu.Unlock()
+	s.cancelExpiredLeases(ctx)
+	if n, err := s.taskRepo.RequeueExpiredLeases(ctx); err == nil && n > 0 {
+		s.notifier.Notify()
+	}
+}
+
 // ClaimTask for workers
 func (s *TaskService) ClaimTask(ctx context.Context, taskID, workerID string) (bool, error) {
-	ok, err := s.taskRepo.Claim(ctx, taskID, workerID, time.Now().Add(defaultTaskLease))
+	ok, err := s.claimByIDWithLimits(ctx, taskID, workerID, time.Now().Add(defaultTaskLease))
+	if ok {
+		if task, err := s.taskRepo.GetByID(ctx, taskID); err == nil && task != nil {
+			s.metrics.claimed(task, time.Now())
+		}
+	}
 	if ok && s.eventHub != nil {
 		s.broadcastTaskUpdate(ctx, taskID, "task_claimed")
 	}
 	return ok, err
 }
 
+// HeartbeatTask 续约；任务已被请求取消时将其落为 cancelled 并返回 ErrTaskCancelled，worker 应停止处理
 func (s *TaskService) HeartbeatTask(ctx context.Context, taskID, workerID string) (bool, error) {
+	if task, err := s.taskRepo.GetByID(ctx, taskID); err == nil && task != nil && task.CancelRequested && task.WorkerID == workerID {
+		if err := s.finishCancelled(ctx, task, workerID); err != nil {
+			return false, err
+		}
+		return false, ErrTaskCancelled
+	}
 	ok, err := s.taskRepo.RenewLease(ctx, taskID, workerID, time.Now().Add(defaultTaskLease))
+	if ok {
+		_ = s.workers.Touch(ctx, workerID)
+	}
 	if ok && s.eventHub != nil {
 		s.broadcastTaskUpdate(ctx, taskID, "task_heartbeat")
 	}
@@ -139,15 +348,35 @@ func (s *TaskService) ReportTaskProgress(ctx context.Context, taskID string, wor
 		return errors.New("worker mismatch for task report")
 	}
 
+	// 声明了结果约定的任务类型：先校验结果，不符合约定按失败处理（进入重试 / 死信）
+	var resultApply *repos.TaskResultApply
+	if contract, ok := s.results.get(task.TaskType); ok && success {
+		resultApply, err = contract.build(task, workerID, resultData)
+		if err != nil {
+			success = false
+			errMsg = "invalid result: " + err.Error()
+		}
+	}
+
+	// 已请求取消：失败（通常是 context 被取消）按取消处理；成功则照常完成，结果不浪费
+	if !success && task.CancelRequested {
+		return s.finishCancelled(ctx, task, workerID)
+	}
+
 	if !success {
 		outcome, err := s.taskRepo.Fail(ctx, taskID, workerID, errMsg)
 		if err != nil {
 			return err
 		}
+		_ = s.workers.RecordResult(ctx, workerID, false)
+		s.metrics.finished(task, TaskOutcomeFailure, outcome.Retried, time.Now())
+		// 释放的并发名额可供其他任务使用
+		s.notifier.Notify()
 		if outcome.Retried {
 			s.broadcastTaskUpdate(ctx, taskID, "task_requeued")
 		} else if outcome.DeadLettered {
 			s.broadcastTaskUpdate(ctx, taskID, "task_dead_letter")
+			s.cascadeDependents(ctx, task, models.TaskStatusFailed, fmt.Sprintf("prerequisite %s failed", task.TaskType))
 			_ = s.assetRepo.UpdateStatus(ctx, task.AssetID, "ERROR")
 			if s.eventHub != nil {
 				s.eventHub.Broadcast(map[string]any{
@@ -165,9 +394,29 @@ func (s *TaskService) ReportTaskProgress(ctx context.Context, taskID string, wor
 	}
 
 	// Handle successful completion
-	if err := s.taskRepo.Complete(ctx, taskID, workerID); err != nil {
+	if resultApply != nil {
+		// 完成与结果写入同一事务
+		if err := s.taskRepo.CompleteWithResult(ctx, resultApply); err != nil {
+			return err
+		}
+		if s.eventHub != nil {
+			s.eventHub.Broadcast(map[string]any{
+				"type": "task_result_applied",
+				"data": map[string]any{
+					"task_id":   task.ID,
+					"asset_id":  task.AssetID,
+					"task_type": task.TaskType,
+					"plugin_id": resultApply.PluginID,
+				},
+			})
+		}
+	} else if err := s.taskRepo.Complete(ctx, taskID, workerID); err != nil {
 		return err
 	}
+	_ = s.workers.RecordResult(ctx, workerID, true)
+	s.metrics.finished(task, TaskOutcomeSuccess, false, time.Now())
+	// 下游任务可能因此解锁
+	s.notifier.Notify()
 	s.broadcastTaskUpdate(ctx, taskID, "task_completed")
 
 	// Process resultData (e.g., update asset metadata in DB)
@@ -185,6 +434,17 @@ func (s *TaskService) ReportTaskProgress(ctx context.Context, taskID string, wor
 	return s.HandleTaskCompletion(ctx, task.AssetID)
 }
 
+// PostponeTask 把已领取的任务放回队列，delay 之后再领取；不计入失败与重试
+func (s *TaskService) PostponeTask(ctx context.Context, taskID, workerID string, delay time.Duration, reason string) error {
+	ok, err := s.taskRepo.Postpone(ctx, taskID, workerID, time.Now().Add(delay), reason)
+	if err != nil || !ok {
+		return err
+	}
+	s.notifier.Notify()
+	s.broadcastTaskUpdate(ctx, taskID, "task_postponed")
+	return nil
+}
+
 func (s *TaskService) EnqueueThumbnailTask(ctx context.Context, assetID string, force bool) (*models.MediaTask, error) {
 	return s.EnqueueTask(ctx, assetID, "thumbnail", 50, force)
 }
@@ -193,6 +453,40 @@ func (s *TaskService) GetActiveTasks(ctx context.Context) ([]models.MediaTask, e
 	return s.taskRepo.GetActiveTasks(ctx)
 }
 
+// cascadeDependents 父任务最终失败或取消时，其下游待处理任务随之失败 / 取消
+func (s *TaskService) cascadeDependents(ctx context.Context, parent *models.MediaTask, status models.TaskStatus, reason string) {
+	affected, err := s.taskRepo.CascadeDependents(ctx, parent.ID, status, reason)
+	if err != nil {
+		logger.Warn("Failed to cascade task dependents", zap.String("task_id", parent.ID), zap.Error(err))
+		return
+	}
+	if len(affected) == 0 || s.eventHub == nil {
+		return
+	}
+	ids := make([]string, 0, len(affected))
+	for i := range affected {
+		ids = append(ids, affected[i].ID)
+	}
+	s.eventHub.Broadcast(map[string]any{
+		"type": "task_dependents_" + string(status),
+		"data": map[string]any{
+			"parent_task_id": parent.ID,
+			"asset_id":       parent.AssetID,
+			"task_ids":       ids,
+		},
+	})
+}
+
+func (s *TaskService) broadcastTask(task *models.MediaTask, eventType string) {
+	if s.eventHub == nil {
+		return
+	}
+	s.eventHub.Broadcast(map[string]any{
+		"type": eventType,
+		"data": task,
+	})
+}
+
 func (s *TaskService) broadcastTaskUpdate(ctx context.Context, taskID string, eventType string) {
 	if s.eventHub == nil {
 		return
@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+)
+
+const (
+	// workerOnlineTTL 超过该时间未心跳视为离线
+	workerOnlineTTL = 2 * time.Minute
+	// claimCandidateFactor 受并发上限约束时每页多取候选；跳过已满额的任务后继续翻页，直到凑满批次或队列见底
+	claimCandidateFactor = 10
+)
+
+type RegisterWorkerRequest struct {
+	WorkerID       string   `json:"worker_id"`
+	Name           string   `json:"name"`
+	Kind           string   `json:"kind"`
+	TaskTypes      []string `json:"task_types"`
+	MaxConcurrency int      `json:"max_concurrency"`
+}
+
+type SetTaskLimitRequest struct {
+	ID            string   `json:"id"`
+	TaskType      string   `json:"task_type"`
+	Extensions    []string `json:"extensions"`
+	MaxConcurrent int      `json:"max_concurrent"`
+}
+
+// TaskWorkerView worker 注册信息 + 当前租约与吞吐
+type TaskWorkerView struct {
+	models.TaskWorker
+	Online            bool                 `json:"online"`
+	InFlight          []repos.TaskLeaseRef `json:"in_flight"`
+	InFlightCount     int                  `json:"in_flight_count"`
+	CompletedLastHour int                  `json:"completed_last_hour"`
+}
+
+// taskLimit 解析后的并发上限
+type taskLimit struct {
+	id       string
+	taskType string
+	exts     map[string]bool
+	max      int
+}
+
+func (l taskLimit) matches(taskType, assetPath string) bool {
+	if l.taskType != taskType {
+		return false
+	}
+	if len(l.exts) == 0 {
+		return true
+	}
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(assetPath), "."))
+	return l.exts[ext]
+}
+
+func normalizeExtensions(exts []string) []string {
+	out := make([]string, 0, len(exts))
+	seen := map[string]bool{}
+	for _, e := range exts {
+		e = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(e), "."))
+		if e != "" && !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func (s *TaskService) loadLimits(ctx context.Context) ([]taskLimit, error) {
+	rows, err := s.workers.ListLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]taskLimit, 0, len(rows))
+	for _, row := range rows {
+		var exts []string
+		decodeJSONStringList(row.ExtensionsJSON, &exts)
+		l := taskLimit{id: row.ID, taskType: row.TaskType, exts: map[string]bool{}, max: row.MaxConcurrent}
+		for _, e := range exts {
+			l.exts[e] = true
+		}
+		out = append(out, l)
+	}
+	return out, nil
+}
+
+func encodeJSONStringList(list []string) string {
+	if len(list) == 0 {
+		return "[]"
+	}
+	b, _ := json.Marshal(list)
+	return string(b)
+}
+
+func decodeJSONStringList(raw string, out *[]string) {
+	if strings.TrimSpace(raw) == "" {
+		return
+	}
+	_ = json.Unmarshal([]byte(raw), out)
+}
+
+// workerClaimScope 根据 worker 注册信息收窄任务类型并计算剩余容量；容量 <= 0 表示已满
+func (s *TaskService) workerClaimScope(ctx context.Context, workerID string, taskTypes []string, max int, processing []repos.TaskLeaseRef) ([]string, int, error) {
+	worker, err := s.workers.Get(ctx, workerID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if worker == nil {
+		if err := s.workers.Touch(ctx, workerID); err != nil {
+			return nil, 0, err
+		}
+		return taskTypes, max, nil
+	}
+	_ = s.workers.Touch(ctx, workerID)
+
+	var declared []string
+	decodeJSONStringList(worker.TaskTypesJSON, &declared)
+	if len(declared) > 0 {
+		if len(taskTypes) == 0 {
+			taskTypes = declared
+		} else {
+			narrowed := make([]string, 0, len(taskTypes))
+			for _, t := range taskTypes {
+				if containsString(declared, t) {
+					narrowed = append(narrowed, t)
+				}
+			}
+			if len(narrowed) == 0 {
+				return nil, 0, nil
+			}
+			taskTypes = narrowed
+		}
+	}
+	if worker.MaxConcurrency > 0 {
+		inflight := 0
+		for i := range processing {
+			if processing[i].WorkerID == workerID {
+				inflight++
+			}
+		}
+		if remaining := worker.MaxConcurrency - inflight; remaining < max {
+			max = remaining
+		}
+	}
+	return taskTypes, max, nil
+}
+
+// claimWithLimits 在 claimMu 保护下领取：遵守 worker 并发上限与按任务类型的全局上限
+func (s *TaskService) claimWithLimits(ctx context.Context, workerID string, taskTypes []string, leaseUntil time.Time, max int) ([]models.MediaTask, error) {
+	s.claimMu.Lock()
+	defer s.claimMu.Unlock()
+
+	processing, err := s.workers.ListProcessing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	taskTypes, max, err = s.workerClaimScope(ctx, workerID, taskTypes, max, processing)
+	if err != nil || max <= 0 {
+		return []models.MediaTask{}, err
+	}
+
+	limits, err := s.loadLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	relevant := limits[:0]
+	for _, l := range limits {
+		if len(taskTypes) == 0 || containsString(taskTypes, l.taskType) {
+			relevant = append(relevant, l)
+		}
+	}
+	if len(relevant) == 0 {
+		return s.taskRepo.ClaimNext(ctx, taskTypes, workerID, leaseUntil, max)
+	}
+
+	usage := make(map[string]int, len(relevant))
+	for _, p := range processing {
+		for _, l := range relevant {
+			if l.matches(p.TaskType, p.AssetPath) {
+				usage[l.id]++
+			}
+		}
+	}
+	picked := make([]string, 0, max)
+	pageSize := max * claimCandidateFactor
+	var after *repos.TaskLeaseRef
+	for len(picked) < max {
+		candidates, err := s.workers.ListClaimable(ctx, taskTypes, saturatedTypes(relevant, usage), after, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range candidates {
+			if len(picked) >= max {
+				break
+			}
+			allowed := true
+			for _, l := range relevant {
+				if l.matches(c.TaskType, c.AssetPath) && usage[l.id] >= l.max {
+					allowed = false
+					break
+				}
+			}
+			if !allowed {
+				continue
+			}
+			for _, l := range relevant {
+				if l.matches(c.TaskType, c.AssetPath) {
+					usage[l.id]++
+				}
+			}
+			picked = append(picked, c.ID)
+		}
+		if len(candidates) < pageSize {
+			break
+		}
+		after = &candidates[len(candidates)-1]
+	}
+	return s.taskRepo.ClaimByIDs(ctx, picked, workerID, leaseUntil)
+}
+
+// saturatedTypes 不限扩展名且已满额的上限所对应的任务类型，可整类在 SQL 中排除
+func saturatedTypes(limits []taskLimit, usage map[string]int) []string {
+	var out []string
+	for _, l := range limits {
+		if len(l.exts) == 0 && usage[l.id] >= l.max && !containsString(out, l.taskType) {
+			out = append(out, l.taskType)
+		}
+	}
+	return out
+}
+
+// claimByIDWithLimits 按 id 领取单个任务（旧的两步领取接口），同样受并发上限约束
+func (s *TaskService) claimByIDWithLimits(ctx context.Context, taskID, workerID string, leaseUntil time.Time) (bool, error) {
+	s.claimMu.Lock()
+	defer s.claimMu.Unlock()
+
+	task, err := s.taskRepo.GetByID(ctx, taskID)
+	if err != nil || task == nil {
+		return false, err
+	}
+	processing, err := s.workers.ListProcessing(ctx)
+	if err != nil {
+		return false, err
+	}
+	taskTypes, capacity, err := s.workerClaimScope(ctx, workerID, []string{task.TaskType}, 1, processing)
+	if err != nil || capacity <= 0 || len(taskTypes) == 0 {
+		return false, err
+	}
+	limits, err := s.loadLimits(ctx)
+	if err != nil {
+		return false, err
+	}
+	assetPath := ""
+	if asset, err := s.assetRepo.GetByID(ctx, task.AssetID); err == nil && asset != nil {
+		assetPath = asset.Path
+	}
+	for _, l := range limits {
+		if !l.matches(task.TaskType, assetPath) {
+			continue
+		}
+		used := 0
+		for _, p := range processing {
+			if l.matches(p.TaskType, p.AssetPath) {
+				used++
+			}
+		}
+		if used >= l.max {
+			return false, nil
+		}
+	}
+	return s.taskRepo.Claim(ctx, taskID, workerID, leaseUntil)
+}
+
+// RegisterWorker 注册 / 更新 worker；未指定 worker_id 时生成
+func (s *TaskService) RegisterWorker(ctx context.Context, req RegisterWorkerRequest) (*models.TaskWorker, error) {
+	id := strings.TrimSpace(req.WorkerID)
+	if id == "" {
+		id = utils.NewID()
+	}
+	if req.MaxConcurrency < 0 {
+		return nil, errors.New("max_concurrency must be >= 0")
+	}
+	kind := strings.TrimSpace(req.Kind)
+	if kind == "" {
+		kind = "external"
+	}
+	if kind != "internal" && kind != "external" {
+		return nil, errors.New("kind must be internal or external")
+	}
+	types := encodeJSONStringList(normalizeNonEmptyStrings(req.TaskTypes))
+	now := time.Now().Unix()
+	w := &models.TaskWorker{
+		ID:             id,
+		Name:           strings.TrimSpace(req.Name),
+		Kind:           kind,
+		TaskTypesJSON:  types,
+		MaxConcurrency: req.MaxConcurrency,
+		RegisteredAt:   now,
+		LastSeenAt:     now,
+	}
+	if err := s.workers.Upsert(ctx, w); err != nil {
+		return nil, err
+	}
+	s.notifier.Notify()
+	stored, err := s.workers.Get(ctx, id)
+	if err != nil || stored == nil {
+		return nil, err
+	}
+	stored.TaskTypes = json.RawMessage(nonEmptyJSON(stored.TaskTypesJSON, "[]"))
+	return stored, nil
+}
+
+func (s *TaskService) WorkerHeartbeat(ctx context.Context, workerID string) error {
+	workerID = strings.TrimSpace(workerID)
+	if workerID == "" {
+		return errors.New("worker_id is required")
+	}
+	return s.workers.Touch(ctx, workerID)
+}
+
+func (s *TaskService) UnregisterWorker(ctx context.Context, workerID string) error {
+	workerID = strings.TrimSpace(workerID)
+	if workerID == "" {
+		return errors.New("worker_id is required")
+	}
+	return s.workers.Delete(ctx, workerID)
+}
+
+// ListWorkers 返回 worker 列表及其进行中的租约、最近一小时完成数与在线状态
+func (s *TaskService) ListWorkers(ctx context.Context) ([]TaskWorkerView, error) {
+	workers, err := s.workers.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	processing, err := s.workers.ListProcessing(ctx)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := s.workers.CountCompletedSince(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	byWorker := map[string][]repos.TaskLeaseRef{}
+	for _, p := range processing {
+		byWorker[p.WorkerID] = append(byWorker[p.WorkerID], p)
+	}
+
+	now := time.Now()
+	out := make([]TaskWorkerView, 0, len(workers))
+	for i := range workers {
+		w := workers[i]
+		w.TaskTypes = json.RawMessage(nonEmptyJSON(w.TaskTypesJSON, "[]"))
+		inflight := byWorker[w.ID]
+		if inflight == nil {
+			inflight = []repos.TaskLeaseRef{}
+		}
+		out = append(out, TaskWorkerView{
+			TaskWorker:        w,
+			Online:            now.Sub(time.Unix(w.LastSeenAt, 0)) <= workerOnlineTTL,
+			InFlight:          inflight,
+			InFlightCount:     len(inflight),
+			CompletedLastHour: completed[w.ID],
+		})
+	}
+	return out, nil
+}
+
+func (s *TaskService) ListTaskLimits(ctx context.Context) ([]models.TaskConcurrencyLimit, error) {
+	rows, err := s.workers.ListLimits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Extensions = json.RawMessage(nonEmptyJSON(rows[i].ExtensionsJSON, "[]"))
+	}
+	return rows, nil
+}
+
+// SetTaskLimit 创建或更新并发上限；id 为空时新建
+func (s *TaskService) SetTaskLimit(ctx context.Context, req SetTaskLimitRequest) (*models.TaskConcurrencyLimit, error) {
+	taskType := strings.TrimSpace(req.TaskType)
+	if taskType == "" {
+		return nil, errors.New("task_type is required")
+	}
+	if req.MaxConcurrent < 1 {
+		return nil, errors.New("max_concurrent must be >= 1")
+	}
+	exts := encodeJSONStringList(normalizeExtensions(req.Extensions))
+	id := strings.TrimSpace(req.ID)
+	if id == "" {
+		id = utils.NewID()
+	}
+	now := time.Now().Unix()
+	l := &models.TaskConcurrencyLimit{
+		ID:             id,
+		TaskType:       taskType,
+		ExtensionsJSON: exts,
+		MaxConcurrent:  req.MaxConcurrent,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := s.workers.UpsertLimit(ctx, l); err != nil {
+		return nil, err
+	}
+	// 上限放宽后可能有任务变为可领取
+	s.notifier.Notify()
+	l.Extensions = json.RawMessage(nonEmptyJSON(l.ExtensionsJSON, "[]"))
+	return l, nil
+}
+
+func (s *TaskService) DeleteTaskLimit(ctx context.Context, id string) error {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return errors.New("id is required")
+	}
+	if err := s.workers.DeleteLimit(ctx, id); err != nil {
+		return err
+	}
+	s.notifier.Notify()
+	return nil
+}
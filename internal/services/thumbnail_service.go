@@ -0,0 +1,603 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/processor"
+	"media-assistant-os/internal/repos"
+
+	"github.com/nfnt/resize"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrThumbnailNotFound    = errors.New("thumbnail not found")
+	ErrInvalidThumbnailSize = errors.New("invalid thumbnail size")
+)
+
+// ThumbnailSpec 缩略图规格；VideoOnly 的规格只为视频生成
+type ThumbnailSpec struct {
+	Name      string
+	MaxEdge   int
+	VideoOnly bool
+}
+
+// thumbnailSpecs 按长边升序
+var thumbnailSpecs = []ThumbnailSpec{
+	{Name: "grid", MaxEdge: 256},
+	{Name: "preview", MaxEdge: 1024},
+	{Name: "poster", MaxEdge: 1920, VideoOnly: true},
+}
+
+const (
+	defaultThumbnailSpec = "grid"
+	thumbnailJPEGQuality = 82
+	thumbnailWebPQuality = 80
+	// thumbnailGCInterval 孤儿缩略图清理间隔；启动后延迟首轮，避免与启动扫描争抢 IO
+	thumbnailGCInterval = 6 * time.Hour
+	thumbnailGCDelay    = 2 * time.Minute
+	thumbnailGCBatch    = 500
+)
+
+// ThumbnailResult 一次生成的结果；Reused 表示全部规格已存在（重复文件或重新关联的资产），未重新渲染
+type ThumbnailResult struct {
+	ContentKey string                      `json:"content_key"`
+	Renditions []models.ThumbnailRendition `json:"renditions"`
+	Reused     bool                        `json:"reused"`
+}
+
+// ThumbnailFile 解析后的可服务文件
+type ThumbnailFile struct {
+	models.ThumbnailRendition
+	Path        string
+	ContentType string
+}
+
+type ThumbnailGCResult struct {
+	Removed       int   `json:"removed"`
+	LegacyRemoved int   `json:"legacy_removed"`
+	FreedBytes    int64 `json:"freed_bytes"`
+}
+
+// ThumbnailService 多规格缩略图：按资产指纹内容寻址存储，优先输出 WebP（需 cwebp 或带 libwebp 的 ffmpeg），否则 JPEG
+type ThumbnailService struct {
+	thumbs   *repos.ThumbnailRepo
+	assets   *repos.AssetRepo
+	dataDir  string
+	encoder  *thumbnailEncoder
+	locksMu  sync.Mutex
+	keyLocks map[string]*thumbnailKeyLock // content key -> 锁，避免重复文件并发渲染同一份；无人持有时移除
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+func NewThumbnailService(thumbs *repos.ThumbnailRepo, assets *repos.AssetRepo, dataDir string) *ThumbnailService {
+	return &ThumbnailService{
+		thumbs:   thumbs,
+		assets:   assets,
+		dataDir:  dataDir,
+		encoder:  &thumbnailEncoder{},
+		keyLocks: make(map[string]*thumbnailKeyLock),
+		stopChan: make(chan struct{}),
+	}
+}
+
+type thumbnailKeyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func (s *ThumbnailService) Start() {
+	s.wg.Add(1)
+	go s.gcLoop()
+}
+
+func (s *ThumbnailService) Stop() {
+	close(s.stopChan)
+	s.wg.Wait()
+}
+
+func (s *ThumbnailService) gcLoop() {
+	defer s.wg.Done()
+	timer := time.NewTimer(thumbnailGCDelay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-timer.C:
+		}
+		if res, err := s.CollectGarbage(context.Background()); err != nil {
+			logger.Warn("Thumbnail GC failed", zap.Error(err))
+		} else if res.Removed > 0 || res.LegacyRemoved > 0 {
+			logger.Info("Thumbnail GC finished",
+				zap.Int("removed", res.Removed),
+				zap.Int("legacy_removed", res.LegacyRemoved),
+				zap.Int64("freed_bytes", res.FreedBytes))
+		}
+		timer.Reset(thumbnailGCInterval)
+	}
+}
+
+func thumbnailSpecsFor(asset *models.Asset) []ThumbnailSpec {
+	isVideo := detectAssetFileType(asset.Path) == "video"
+	specs := make([]ThumbnailSpec, 0, len(thumbnailSpecs))
+	for _, spec := range thumbnailSpecs {
+		if spec.VideoOnly && !isVideo {
+			continue
+		}
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+func thumbnailContentKey(asset *models.Asset) (string, error) {
+	if asset.Fingerprint == nil || strings.TrimSpace(*asset.Fingerprint) == "" {
+		return "", errors.New("asset has no fingerprint yet")
+	}
+	return strings.TrimSpace(*asset.Fingerprint), nil
+}
+
+// thumbnailRelDir 内容寻址目录：cache/thumbnails/<前两位>/<key>；非十六进制的指纹先做哈希，保证路径安全
+func thumbnailRelDir(key string) string {
+	name := key
+	for _, c := range key {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			sum := sha256.Sum256([]byte(key))
+			name = hex.EncodeToString(sum[:])
+			break
+		}
+	}
+	if len(name) < 2 {
+		name = "0" + name
+	}
+	return filepath.Join("cache", "thumbnails", name[:2], name)
+}
+
+func (s *ThumbnailService) lockKey(key string) func() {
+	s.locksMu.Lock()
+	l := s.keyLocks[key]
+	if l == nil {
+		l = &thumbnailKeyLock{}
+		s.keyLocks[key] = l
+	}
+	l.refs++
+	s.locksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		s.locksMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.keyLocks, key)
+		}
+		s.locksMu.Unlock()
+	}
+}
+
+func (s *ThumbnailService) exists(rel string) bool {
+	info, err := os.Stat(filepath.Join(s.dataDir, rel))
+	return err == nil && !info.IsDir()
+}
+
+// Generate 生成资产缺失的规格；force 时全部重新渲染。格式不支持或工具缺失时返回空结果（前端回退到图标）
+func (s *ThumbnailService) Generate(ctx context.Context, asset *models.Asset, force bool) (*ThumbnailResult, error) {
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		return nil, err
+	}
+	unlock := s.lockKey(key)
+	defer unlock()
+
+	existing, err := s.thumbs.ListByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	have := make(map[string]models.ThumbnailRendition, len(existing))
+	for _, r := range existing {
+		if s.exists(r.RelPath) {
+			have[r.Rendition] = r
+		}
+	}
+
+	res := &ThumbnailResult{ContentKey: key, Renditions: []models.ThumbnailRendition{}}
+	var missing []ThumbnailSpec
+	for _, spec := range thumbnailSpecsFor(asset) {
+		if r, ok := have[spec.Name]; ok && !force {
+			res.Renditions = append(res.Renditions, r)
+			continue
+		}
+		missing = append(missing, spec)
+	}
+	if len(missing) == 0 {
+		res.Reused = true
+		return res, nil
+	}
+
+	src, err := s.renderSource(ctx, asset, missing[len(missing)-1].MaxEdge)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		logger.Warn("No thumbnail source, skip thumbnail generation",
+			zap.String("asset_id", asset.ID),
+			zap.String("path", asset.Path),
+			zap.Error(err))
+		return res, nil
+	}
+
+	relDir := thumbnailRelDir(key)
+	if err := os.MkdirAll(filepath.Join(s.dataDir, relDir), 0o755); err != nil {
+		return nil, err
+	}
+	for _, spec := range missing {
+		img := resize.Thumbnail(uint(spec.MaxEdge), uint(spec.MaxEdge), src, resize.Lanczos3)
+		data, format, err := s.encoder.encode(ctx, img)
+		if err != nil {
+			return nil, err
+		}
+		rel := filepath.Join(relDir, spec.Name+"."+format)
+		if err := writeFileAtomic(filepath.Join(s.dataDir, rel), data); err != nil {
+			return nil, err
+		}
+		// 格式变化（如 WebP 工具被卸载）时清理旧文件
+		if old, ok := have[spec.Name]; ok && old.RelPath != rel {
+			_ = os.Remove(filepath.Join(s.dataDir, old.RelPath))
+		}
+		sum := sha256.Sum256(data)
+		bounds := img.Bounds()
+		rend := models.ThumbnailRendition{
+			ContentKey: key,
+			Rendition:  spec.Name,
+			Format:     format,
+			RelPath:    rel,
+			Width:      bounds.Dx(),
+			Height:     bounds.Dy(),
+			Bytes:      int64(len(data)),
+			ETag:       hex.EncodeToString(sum[:8]),
+			CreatedAt:  time.Now().Unix(),
+		}
+		if err := s.thumbs.Upsert(ctx, &rend); err != nil {
+			return nil, err
+		}
+		res.Renditions = append(res.Renditions, rend)
+	}
+	// 旧版按资产 id 存放的单张缩略图已被取代
+	_ = os.Remove(s.LegacyPath(asset.ID))
+	return res, nil
+}
+
+// renderSource 先走注册的缩略图源；没有可用源时退回解析器自带的缩略图（外部插件解析器）
+func (s *ThumbnailService) renderSource(ctx context.Context, asset *models.Asset, maxEdge int) (image.Image, error) {
+	ext := filepath.Ext(asset.Path)
+	mgr := processor.GetManager()
+	img, err := mgr.RenderThumbnailSource(ctx, asset.Path, ext, maxEdge)
+	if err == nil {
+		return img, nil
+	}
+	if !errors.Is(err, processor.ErrNoThumbnailSource) {
+		return nil, err
+	}
+	parsed, perr := mgr.Process(ctx, asset.Path, ext)
+	if perr != nil || parsed == nil || len(parsed.Thumbnail) == 0 {
+		return nil, err
+	}
+	img, _, derr := image.Decode(bytes.NewReader(parsed.Thumbnail))
+	if derr != nil {
+		return nil, fmt.Errorf("decode parser thumbnail: %w", derr)
+	}
+	return img, nil
+}
+
+// Invalidate 删除资产当前内容的全部规格，下次生成时重新渲染
+func (s *ThumbnailService) Invalidate(ctx context.Context, asset *models.Asset) error {
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		// 尚无指纹，也就没有规格
+		return nil
+	}
+	unlock := s.lockKey(key)
+	defer unlock()
+	rends, err := s.thumbs.ListByKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	for _, r := range rends {
+		_ = os.Remove(filepath.Join(s.dataDir, r.RelPath))
+		if err := s.thumbs.Delete(ctx, r.ContentKey, r.Rendition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve 按 size 协商规格：规格名（grid / preview / poster）或像素数（取长边不小于它的最小规格，不够时取最大）。
+// 请求的规格不存在时退回最接近的较小规格
+func (s *ThumbnailService) Resolve(ctx context.Context, asset *models.Asset, size string) (*ThumbnailFile, error) {
+	size = strings.ToLower(strings.TrimSpace(size))
+	if size == "" {
+		size = defaultThumbnailSpec
+	}
+	want := -1
+	if px, err := strconv.Atoi(size); err == nil {
+		if px <= 0 {
+			return nil, ErrInvalidThumbnailSize
+		}
+		want = px
+	} else {
+		for _, spec := range thumbnailSpecs {
+			if spec.Name == size {
+				want = spec.MaxEdge
+				break
+			}
+		}
+		if want < 0 {
+			return nil, ErrInvalidThumbnailSize
+		}
+	}
+
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		return nil, ErrThumbnailNotFound
+	}
+	rends, err := s.thumbs.ListByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]models.ThumbnailRendition, len(rends))
+	for _, r := range rends {
+		if s.exists(r.RelPath) {
+			byName[r.Rendition] = r
+		}
+	}
+
+	var best, fallback *models.ThumbnailRendition
+	for _, spec := range thumbnailSpecs {
+		r, ok := byName[spec.Name]
+		if !ok {
+			continue
+		}
+		if spec.MaxEdge >= want {
+			best = &r
+			break
+		}
+		fallback = &r
+	}
+	if best == nil {
+		best = fallback
+	}
+	if best == nil {
+		return nil, ErrThumbnailNotFound
+	}
+	return &ThumbnailFile{
+		ThumbnailRendition: *best,
+		Path:               filepath.Join(s.dataDir, best.RelPath),
+		ContentType:        "image/" + best.Format,
+	}, nil
+}
+
+// LegacyPath 旧版按资产 id 存放的单张缩略图（尚未重新生成的资产仍在使用）
+func (s *ThumbnailService) LegacyPath(assetID string) string {
+	return filepath.Join(s.dataDir, "cache", "thumbnails", assetID+".jpg")
+}
+
+// CollectGarbage 删除已没有资产引用其指纹的规格，以及资产已不存在的旧版缩略图
+func (s *ThumbnailService) CollectGarbage(ctx context.Context) (*ThumbnailGCResult, error) {
+	res := &ThumbnailGCResult{}
+	for {
+		orphans, err := s.thumbs.ListOrphans(ctx, thumbnailGCBatch)
+		if err != nil {
+			return res, err
+		}
+		for start := 0; start < len(orphans); {
+			end := start + 1
+			for end < len(orphans) && orphans[end].ContentKey == orphans[start].ContentKey {
+				end++
+			}
+			if err := s.collectKey(ctx, orphans[start:end], res); err != nil {
+				return res, err
+			}
+			start = end
+		}
+		if len(orphans) < thumbnailGCBatch {
+			break
+		}
+	}
+
+	n, freed, err := s.collectLegacy(ctx)
+	res.LegacyRemoved = n
+	res.FreedBytes += freed
+	return res, err
+}
+
+// collectKey 在 key 的锁内删除同一内容的孤儿规格；列出后已有资产重新引用该指纹时跳过
+func (s *ThumbnailService) collectKey(ctx context.Context, rends []models.ThumbnailRendition, res *ThumbnailGCResult) error {
+	key := rends[0].ContentKey
+	unlock := s.lockKey(key)
+	defer unlock()
+	referenced, err := s.thumbs.IsReferenced(ctx, key)
+	if err != nil || referenced {
+		return err
+	}
+	for _, r := range rends {
+		abs := filepath.Join(s.dataDir, r.RelPath)
+		if err := os.Remove(abs); err == nil {
+			res.FreedBytes += r.Bytes
+		}
+		// 目录空了顺带删除（非空时 Remove 失败，忽略）
+		_ = os.Remove(filepath.Dir(abs))
+		_ = os.Remove(filepath.Dir(filepath.Dir(abs)))
+		if err := s.thumbs.Delete(ctx, r.ContentKey, r.Rendition); err != nil {
+			return err
+		}
+		res.Removed++
+	}
+	return nil
+}
+
+func (s *ThumbnailService) collectLegacy(ctx context.Context) (int, int64, error) {
+	dir := filepath.Join(s.dataDir, "cache", "thumbnails")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	var ids []string
+	sizes := map[string]int64{}
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".jpg" {
+			continue
+		}
+		id := strings.TrimSuffix(e.Name(), ".jpg")
+		ids = append(ids, id)
+		if info, err := e.Info(); err == nil {
+			sizes[id] = info.Size()
+		}
+	}
+	removed := 0
+	var freed int64
+	for start := 0; start < len(ids); start += thumbnailGCBatch {
+		end := start + thumbnailGCBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+		found, err := s.assets.GetByIDs(ctx, batch)
+		if err != nil {
+			return removed, freed, err
+		}
+		alive := make(map[string]bool, len(found))
+		for i := range found {
+			alive[found[i].ID] = true
+		}
+		for _, id := range batch {
+			if alive[id] {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, id+".jpg")); err == nil {
+				removed++
+				freed += sizes[id]
+			}
+		}
+	}
+	return removed, freed, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".thumb-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// thumbnailEncoder WebP 需外部工具（cwebp 优先，其次带 libwebp 的 ffmpeg），首次使用时探测；不可用或编码失败时输出 JPEG
+type thumbnailEncoder struct {
+	once   sync.Once
+	cwebp  string
+	ffmpeg string
+}
+
+func (e *thumbnailEncoder) detect() {
+	e.once.Do(func() {
+		if p, err := exec.LookPath("cwebp"); err == nil {
+			e.cwebp = p
+			return
+		}
+		if p, err := exec.LookPath("ffmpeg"); err == nil {
+			out, err := exec.Command(p, "-hide_banner", "-encoders").Output()
+			if err == nil && bytes.Contains(out, []byte("libwebp")) {
+				e.ffmpeg = p
+			}
+		}
+	})
+}
+
+func (e *thumbnailEncoder) encode(ctx context.Context, img image.Image) ([]byte, string, error) {
+	e.detect()
+	if e.cwebp != "" || e.ffmpeg != "" {
+		data, err := e.encodeWebP(ctx, img)
+		if err == nil && len(data) > 0 {
+			return data, "webp", nil
+		}
+		logger.Debug("WebP encode failed, fall back to JPEG", zap.Error(err))
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: thumbnailJPEGQuality}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "jpeg", nil
+}
+
+func (e *thumbnailEncoder) encodeWebP(ctx context.Context, img image.Image) ([]byte, error) {
+	var pngBuf bytes.Buffer
+	if err := png.Encode(&pngBuf, img); err != nil {
+		return nil, err
+	}
+	quality := strconv.Itoa(thumbnailWebPQuality)
+	var out bytes.Buffer
+	if e.cwebp != "" {
+		tmp, err := os.CreateTemp("", "thumb-*.png")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(tmp.Name())
+		if _, err := tmp.Write(pngBuf.Bytes()); err != nil {
+			_ = tmp.Close()
+			return nil, err
+		}
+		_ = tmp.Close()
+		cmd := exec.CommandContext(ctx, e.cwebp, "-quiet", "-q", quality, "-o", "-", "--", tmp.Name())
+		cmd.Stdout = &out
+		if err := cmd.Run(); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}
+	cmd := exec.CommandContext(ctx, e.ffmpeg,
+		"-hide_banner", "-loglevel", "error",
+		"-f", "png_pipe", "-i", "pipe:0",
+		"-c:v", "libwebp", "-quality", quality,
+		"-f", "webp", "pipe:1",
+	)
+	cmd.Stdin = &pngBuf
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
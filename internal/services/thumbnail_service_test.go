@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+func TestThumbnailRelDir(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"ab12cd", filepath.Join("cache", "thumbnails", "ab", "ab12cd")},
+		{"F00D", filepath.Join("cache", "thumbnails", "F0", "F00D")},
+		{"7", filepath.Join("cache", "thumbnails", "07", "07")},
+		{"../../etc", ""},
+		{"sha:1", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.key, func(t *testing.T) {
+			got := thumbnailRelDir(tc.key)
+			if tc.want != "" && got != tc.want {
+				t.Fatalf("thumbnailRelDir(%q) = %s, want %s", tc.key, got, tc.want)
+			}
+			// 非十六进制的指纹必须哈希成 64 位十六进制目录，不能逃出缓存目录
+			name := filepath.Base(got)
+			if strings.Contains(got, "..") || filepath.Dir(filepath.Dir(got)) != filepath.Join("cache", "thumbnails") || name[:2] != filepath.Base(filepath.Dir(got)) {
+				t.Fatalf("unsafe dir %s", got)
+			}
+			if tc.want == "" && len(name) != 64 {
+				t.Fatalf("non-hex key not hashed: %s", got)
+			}
+		})
+	}
+}
+
+func newTestThumbnailService(t *testing.T) (*ThumbnailService, *repos.AssetRepo, *repos.ThumbnailRepo) {
+	t.Helper()
+	d := newTestDB(t)
+	thumbs := repos.NewThumbnailRepo(d.ORM())
+	assets := repos.NewAssetRepo(d.ORM())
+	return NewThumbnailService(thumbs, assets, t.TempDir()), assets, thumbs
+}
+
+// writeTestRendition 写入规格文件与记录
+func writeTestRendition(t *testing.T, s *ThumbnailService, key, name string, edge int) models.ThumbnailRendition {
+	t.Helper()
+	rel := filepath.Join(thumbnailRelDir(key), name+".jpeg")
+	if err := os.MkdirAll(filepath.Join(s.dataDir, filepath.Dir(rel)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dataDir, rel), []byte("jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r := models.ThumbnailRendition{ContentKey: key, Rendition: name, Format: "jpeg", RelPath: rel, Width: edge, Height: edge / 2, Bytes: 4, ETag: name, MetaJSON: "{}"}
+	if err := s.thumbs.Upsert(context.Background(), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestThumbnailService_Resolve(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newTestThumbnailService(t)
+	key := "abcdef"
+	fp := key
+	asset := &models.Asset{ID: "a1", Path: "/media/clip.mov", Fingerprint: &fp}
+	writeTestRendition(t, s, key, "grid", 256)
+	writeTestRendition(t, s, key, "poster", 1920)
+	// preview 有记录但文件丢失，视为不存在
+	preview := writeTestRendition(t, s, key, "preview", 1024)
+	if err := os.Remove(filepath.Join(s.dataDir, preview.RelPath)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		size    string
+		want    string
+		wantErr error
+	}{
+		{"", "grid", nil},
+		{"grid", "grid", nil},
+		{" Poster ", "poster", nil},
+		{"preview", "poster", nil},
+		{"100", "grid", nil},
+		{"256", "grid", nil},
+		{"257", "poster", nil},
+		{"4000", "poster", nil},
+		{"0", "", ErrInvalidThumbnailSize},
+		{"-5", "", ErrInvalidThumbnailSize},
+		{"huge", "", ErrInvalidThumbnailSize},
+	}
+	for _, tc := range tests {
+		t.Run(tc.size, func(t *testing.T) {
+			f, err := s.Resolve(ctx, asset, tc.size)
+			if tc.wantErr != nil {
+				if !errors.Is(err, tc.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.Rendition != tc.want || f.ContentType != "image/jpeg" || f.Path != filepath.Join(s.dataDir, f.RelPath) {
+				t.Fatalf("resolved %+v, want %s", f, tc.want)
+			}
+		})
+	}
+
+	// 只有较小规格时退回最接近的较小规格
+	small := "0123"
+	writeTestRendition(t, s, small, "grid", 256)
+	if f, err := s.Resolve(ctx, &models.Asset{Fingerprint: &small}, "poster"); err != nil || f.Rendition != "grid" {
+		t.Fatalf("fallback = %+v, %v", f, err)
+	}
+	if _, err := s.Resolve(ctx, &models.Asset{}, "grid"); !errors.Is(err, ErrThumbnailNotFound) {
+		t.Fatalf("no fingerprint error = %v", err)
+	}
+	none := "ffff"
+	if _, err := s.Resolve(ctx, &models.Asset{Fingerprint: &none}, "grid"); !errors.Is(err, ErrThumbnailNotFound) {
+		t.Fatalf("no renditions error = %v", err)
+	}
+}
+
+func TestThumbnailService_CollectGarbage(t *testing.T) {
+	ctx := context.Background()
+	s, assets, thumbs := newTestThumbnailService(t)
+
+	live, err := assets.Create(ctx, "/media/live.jpg", 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := assets.UpdateFingerprint(ctx, live.ID, "aaaa", nil); err != nil {
+		t.Fatal(err)
+	}
+	kept := writeTestRendition(t, s, "aaaa", "grid", 256)
+	orphanGrid := writeTestRendition(t, s, "bbbb", "grid", 256)
+	orphanPreview := writeTestRendition(t, s, "bbbb", "preview", 1024)
+
+	legacyDir := filepath.Join(s.dataDir, "cache", "thumbnails")
+	for _, name := range []string{live.ID + ".jpg", "gone.jpg"} {
+		if err := os.WriteFile(filepath.Join(legacyDir, name), []byte("legacy"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := s.CollectGarbage(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 2 || res.LegacyRemoved != 1 || res.FreedBytes != 8+6 {
+		t.Fatalf("result = %+v", res)
+	}
+	for _, r := range []models.ThumbnailRendition{orphanGrid, orphanPreview} {
+		if _, err := os.Stat(filepath.Join(s.dataDir, r.RelPath)); !os.IsNotExist(err) {
+			t.Errorf("%s still on disk", r.RelPath)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(s.dataDir, thumbnailRelDir("bbbb"))); !os.IsNotExist(err) {
+		t.Error("empty content dir not removed")
+	}
+	if _, err := os.Stat(filepath.Join(s.dataDir, kept.RelPath)); err != nil {
+		t.Errorf("referenced rendition removed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(legacyDir, live.ID+".jpg")); err != nil {
+		t.Errorf("legacy thumbnail of a live asset removed: %v", err)
+	}
+	if rends, err := thumbs.ListByKey(ctx, "bbbb"); err != nil || len(rends) != 0 {
+		t.Fatalf("orphan rows = %v, %v", rends, err)
+	}
+
+	// 列出孤儿后资产重新引用了该指纹：锁内复查后保留
+	again := writeTestRendition(t, s, "cccc", "grid", 256)
+	orphans, err := thumbs.ListOrphans(ctx, 10)
+	if err != nil || len(orphans) != 1 {
+		t.Fatalf("orphans = %v, %v", orphans, err)
+	}
+	if err := assets.UpdateFingerprint(ctx, live.ID, "cccc", nil); err != nil {
+		t.Fatal(err)
+	}
+	res = &ThumbnailGCResult{}
+	if err := s.collectKey(ctx, orphans, res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Removed != 0 {
+		t.Fatalf("re-referenced key collected: %+v", res)
+	}
+	if _, err := os.Stat(filepath.Join(s.dataDir, again.RelPath)); err != nil {
+		t.Fatalf("re-referenced rendition removed: %v", err)
+	}
+	if len(s.keyLocks) != 0 {
+		t.Fatalf("key locks not released: %d", len(s.keyLocks))
+	}
+}
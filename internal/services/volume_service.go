@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/pkg/logger"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+
+	"go.uber.org/zap"
+)
+
+const (
+	VolumeOnline  = "online"
+	VolumeOffline = "offline"
+
+	// volumeCheckInterval 轮询挂载表的间隔
+	volumeCheckInterval = 15 * time.Second
+	// volumeOfflineTaskDelay 源文件在离线卷上的任务推迟多久再领取
+	volumeOfflineTaskDelay = 10 * time.Minute
+)
+
+// LibraryVolumeStatus 库来源及其所在卷的当前状态
+type LibraryVolumeStatus struct {
+	models.LibrarySource
+	Online     bool   `json:"online"`
+	MountPoint string `json:"mount_point,omitempty"`
+	Removable  bool   `json:"removable"`
+}
+
+// VolumeService 把库来源绑定到卷标识（文件系统 UUID / 卷标 / 网络共享），
+// 卷拔出时标记来源离线而不是把资产标成 MISSING，重新挂载到其他位置时自动迁移路径
+type VolumeService struct {
+	librarySources *repos.LibrarySourceRepo
+	assetService   *AssetService
+	eventHub       *EventHub
+	listVolumes    func() ([]utils.VolumeInfo, error)
+
+	mu           sync.RWMutex
+	offlineRoots []string
+	volumes      []utils.VolumeInfo
+	onRelocate   []func(oldRoot, newRoot string)
+
+	refreshMu sync.Mutex
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+}
+
+func NewVolumeService(librarySources *repos.LibrarySourceRepo, assetService *AssetService, eventHub *EventHub) *VolumeService {
+	return &VolumeService{
+		librarySources: librarySources,
+		assetService:   assetService,
+		eventHub:       eventHub,
+		listVolumes:    utils.ListVolumes,
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// Start 先同步刷新一次（启动对账前即知道哪些来源离线），再定期轮询
+func (s *VolumeService) Start(ctx context.Context) {
+	if err := s.Refresh(ctx); err != nil {
+		logger.Warn("Volume refresh failed", zap.Error(err))
+	}
+	go func() {
+		ticker := time.NewTicker(volumeCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Refresh(context.Background()); err != nil {
+					logger.Warn("Volume refresh failed", zap.Error(err))
+				}
+			case <-s.stopChan:
+				return
+			}
+		}
+	}()
+}
+
+func (s *VolumeService) Stop() {
+	s.stopOnce.Do(func() { close(s.stopChan) })
+}
+
+// OnRelocate 注册来源根目录迁移回调（卷换挂载点后）
+func (s *VolumeService) OnRelocate(fn func(oldRoot, newRoot string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRelocate = append(s.onRelocate, fn)
+}
+
+// IsOffline path 是否位于离线卷上的库来源中；nil 安全
+func (s *VolumeService) IsOffline(path string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.offlineRoots) == 0 {
+		return false
+	}
+	path = filepath.Clean(path)
+	for _, root := range s.offlineRoots {
+		if pathWithinRoot(path, root) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListSources 列出库来源及卷状态
+func (s *VolumeService) ListSources(ctx context.Context) ([]LibraryVolumeStatus, error) {
+	sources, err := s.librarySources.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	vols := s.volumes
+	s.mu.RUnlock()
+	out := make([]LibraryVolumeStatus, 0, len(sources))
+	for _, src := range sources {
+		st := LibraryVolumeStatus{LibrarySource: src, Online: src.VolumeState != VolumeOffline}
+		if v := utils.VolumeForPath(src.RootPath, vols); v != nil && st.Online {
+			st.MountPoint = v.MountPoint
+			st.Removable = v.Removable
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Refresh 读取挂载表，绑定新来源、更新在线状态，并迁移换了挂载点的来源
+func (s *VolumeService) Refresh(ctx context.Context) error {
+	s.refreshMu.Lock()
+	defer s.refreshMu.Unlock()
+
+	vols, err := s.listVolumes()
+	if err != nil {
+		return err
+	}
+	sources, err := s.librarySources.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var offline []string
+	for _, src := range sources {
+		root := filepath.Clean(src.RootPath)
+		if src.VolumeID == "" {
+			if !pathExists(root) {
+				continue
+			}
+			v := utils.VolumeForPath(root, vols)
+			if v == nil || v.ID == "" {
+				continue
+			}
+			fsPath, ok := v.FSPath(root)
+			if !ok {
+				continue
+			}
+			if err := s.librarySources.BindVolume(ctx, src.ID, v.ID, v.Label, fsPath); err != nil {
+				return err
+			}
+			src.VolumeID, src.VolumeLabel, src.VolumePath = v.ID, v.Label, fsPath
+		}
+
+		local, mounted := locateVolumePath(vols, src.VolumeID, src.VolumePath, root)
+		state := VolumeOffline
+		if mounted {
+			state = VolumeOnline
+			if local != root && pathExists(local) && !pathExists(root) {
+				if s.relocate(ctx, src, root, local) {
+					root = local
+				}
+			}
+		} else {
+			offline = append(offline, root)
+		}
+
+		seenAt := int64(0)
+		if state == VolumeOnline {
+			seenAt = time.Now().Unix()
+		}
+		if err := s.librarySources.SetVolumeState(ctx, src.ID, state, seenAt); err != nil {
+			return err
+		}
+		if state != src.VolumeState && src.VolumeState != "" {
+			s.broadcastState(src, root, state)
+		}
+	}
+
+	s.mu.Lock()
+	s.offlineRoots = offline
+	s.volumes = vols
+	s.mu.Unlock()
+	return nil
+}
+
+// locateVolumePath 在挂载表中找到卷标识对应的挂载，并换算出来源当前的本地路径；
+// 同一卷有多个挂载（bind mount）时优先选择与原路径一致的
+func locateVolumePath(vols []utils.VolumeInfo, volumeID string, fsPath string, root string) (string, bool) {
+	found := ""
+	for i := range vols {
+		if vols[i].ID != volumeID {
+			continue
+		}
+		local, ok := vols[i].LocalPath(fsPath)
+		if !ok {
+			continue
+		}
+		if local == root {
+			return local, true
+		}
+		if found == "" {
+			found = local
+		}
+	}
+	return found, found != ""
+}
+
+func (s *VolumeService) relocate(ctx context.Context, src models.LibrarySource, oldRoot, newRoot string) bool {
+	moved, err := s.librarySources.RelocateRoot(ctx, oldRoot, newRoot)
+	if err != nil {
+		logger.Warn("Relocate library source failed",
+			zap.String("from", oldRoot), zap.String("to", newRoot), zap.Error(err))
+		return false
+	}
+	if s.assetService != nil {
+		// 路径整体变化，缓存与 Bloom Filter 需要重建，否则重扫会把已有资产当成新文件
+		if err := s.assetService.ReloadPathIndex(ctx); err != nil {
+			logger.Warn("Reload asset path index failed", zap.Error(err))
+		}
+		if s.assetService.activities != nil {
+			s.assetService.activities.LogEx(ctx, "INFO", "卷已重新挂载: "+oldRoot+" → "+newRoot, "", "")
+		}
+	}
+	if s.eventHub != nil {
+		s.eventHub.Broadcast(map[string]any{
+			"type": "volume_reattached",
+			"data": map[string]any{
+				"volume_id":    src.VolumeID,
+				"volume_label": src.VolumeLabel,
+				"old_root":     oldRoot,
+				"new_root":     newRoot,
+				"moved_assets": moved,
+			},
+		})
+	}
+	s.mu.RLock()
+	fns := append([]func(string, string){}, s.onRelocate...)
+	s.mu.RUnlock()
+	for _, fn := range fns {
+		fn(oldRoot, newRoot)
+	}
+	return true
+}
+
+func (s *VolumeService) broadcastState(src models.LibrarySource, root string, state string) {
+	if s.assetService != nil && s.assetService.activities != nil {
+		msg := "卷已上线: " + root
+		level := "INFO"
+		if state == VolumeOffline {
+			msg = "卷已离线: " + root
+			level = "WARN"
+		}
+		s.assetService.activities.LogEx(context.Background(), level, msg, "", "")
+	}
+	if s.eventHub == nil {
+		return
+	}
+	s.eventHub.Broadcast(map[string]any{
+		"type": "volume_state_changed",
+		"data": map[string]any{
+			"root_path":    root,
+			"volume_id":    src.VolumeID,
+			"volume_label": src.VolumeLabel,
+			"state":        state,
+		},
+	})
+}
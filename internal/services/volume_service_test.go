@@ -0,0 +1,40 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"media-assistant-os/internal/utils"
+)
+
+func TestLocateVolumePath(t *testing.T) {
+	vols := []utils.VolumeInfo{
+		{ID: "uuid:other", MountPoint: "/media/x"},
+		{ID: "uuid:disk", MountPoint: "/run/media/disk"},
+		{ID: "uuid:disk", MountPoint: "/srv/footage", Root: "/footage"},
+		{ID: "uuid:disk", MountPoint: "/srv/music", Root: "/music"},
+		{ID: "uuid:bind", MountPoint: "/srv/b", Root: "/b"},
+	}
+	tests := []struct {
+		name   string
+		id     string
+		fsPath string
+		root   string
+		want   string
+		wantOK bool
+	}{
+		{"first covering mount", "uuid:disk", "/footage/day1", "/old/footage/day1", "/run/media/disk/footage/day1", true},
+		{"prefers unchanged root", "uuid:disk", "/footage/day1", "/srv/footage/day1", "/srv/footage/day1", true},
+		{"skips bind mounts of other dirs", "uuid:disk", "/music/a", "/srv/music/a", "/srv/music/a", true},
+		{"unknown volume", "uuid:gone", "/footage", "/old", "", false},
+		{"bind mount does not cover path", "uuid:bind", "/c/day1", "/old", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := locateVolumePath(vols, tc.id, tc.fsPath, filepath.FromSlash(tc.root))
+			if got != filepath.FromSlash(tc.want) || ok != tc.wantOK {
+				t.Fatalf("locateVolumePath = %q, %v; want %q, %v", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// watchDebounce 同一路径的连续事件合并为一次：最后一次事件后静默这么久才检查文件
+	watchDebounce = 500 * time.Millisecond
+	// watchStableFor 文件大小与 mtime 保持不变这么久才视为写入完成，拷贝中的大文件不会被反复索引
+	watchStableFor = 2 * time.Second
+	// watchRenamePairWindow Rename 之后该窗口内出现的 Create 视为同一次移动/重命名
+	watchRenamePairWindow = time.Second
+	// watchMaxPending 待处理路径上限；超出后新事件按所在目录合并，静默后整目录重扫
+	watchMaxPending = 4096
+	// watchReadyQueueSize 已稳定、等待索引的路径队列；满时留在待处理表中下一轮再投递
+	watchReadyQueueSize = 256
+	watchIndexWorkers   = 2
+	watchTickInterval   = 250 * time.Millisecond
+	// watchRescanRetry 扫描队列已满时目录重扫推迟多久再试
+	watchRescanRetry = 30 * time.Second
+	// watchRenameSample 目录配对后抽查的资产数，相对路径须在新目录下都存在才迁移
+	watchRenameSample = 8
+)
+
+type watchPending struct {
+	projectID   string
+	lastEvent   time.Time
+	checked     bool
+	size        int64
+	modTime     int64
+	stableSince time.Time
+}
+
+type watchRename struct {
+	path      string
+	projectID string
+	at        time.Time
+	isDir     bool
+	size      int64 // 对应资产的大小，目录为 -1
+}
+
+type watchRescan struct {
+	projectID string
+	lastEvent time.Time
+}
+
+type watchReady struct {
+	path      string
+	projectID string
+}
+
+// watchQueue 监控事件合并层：按路径防抖、等待写入完成、配对 Rename/Create，
+// 待处理表有上限，溢出时退化为目录重扫
+type watchQueue struct {
+	mu      sync.Mutex
+	pending map[string]*watchPending
+	renames []watchRename
+	rescans map[string]*watchRescan // dir -> 待重扫
+	ready   chan watchReady
+}
+
+func newWatchQueue() *watchQueue {
+	return &watchQueue{
+		pending: make(map[string]*watchPending),
+		rescans: make(map[string]*watchRescan),
+		ready:   make(chan watchReady, watchReadyQueueSize),
+	}
+}
+
+// touch 记录文件的 Create/Write 事件；待处理表已满时返回 false，由调用方改为目录重扫
+func (q *watchQueue) touch(path string, projectID string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if p, ok := q.pending[path]; ok {
+		p.lastEvent = now
+		return true
+	}
+	if len(q.pending) >= watchMaxPending {
+		return false
+	}
+	q.pending[path] = &watchPending{projectID: projectID, lastEvent: now}
+	return true
+}
+
+// markRescan 登记目录重扫，返回是否为新登记
+func (q *watchQueue) markRescan(dir string, projectID string, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if r, ok := q.rescans[dir]; ok {
+		r.lastEvent = now
+		return false
+	}
+	q.rescans[dir] = &watchRescan{projectID: projectID, lastEvent: now}
+	return true
+}
+
+func (q *watchQueue) forget(path string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.pending, path)
+}
+
+func (q *watchQueue) addRename(r watchRename) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.renames = append(q.renames, r)
+}
+
+// pairRename 为新出现的路径找到窗口内对应的 Rename：类型一致、文件大小一致；
+// 多个候选时优先同名（移动）或同目录（重命名）。目录没有大小可比，必须同名或同父目录才配对
+func (q *watchQueue) pairRename(path string, isDir bool, size int64, now time.Time) (watchRename, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.expireRenamesLocked(now)
+	best := -1
+	for i, r := range q.renames {
+		if r.isDir != isDir || (!isDir && r.size != size) || r.path == path {
+			continue
+		}
+		if filepath.Base(r.path) == filepath.Base(path) || filepath.Dir(r.path) == filepath.Dir(path) {
+			best = i
+			break
+		}
+		if best < 0 && !isDir {
+			best = i
+		}
+	}
+	if best < 0 {
+		return watchRename{}, false
+	}
+	r := q.renames[best]
+	q.renames = append(q.renames[:best], q.renames[best+1:]...)
+	return r, true
+}
+
+// expireRenamesLocked 过期未配对的 Rename 视为移出监控范围，由对账扫描处理
+func (q *watchQueue) expireRenamesLocked(now time.Time) {
+	n := 0
+	for _, r := range q.renames {
+		if now.Sub(r.at) <= watchRenamePairWindow {
+			q.renames[n] = r
+			n++
+		}
+	}
+	q.renames = q.renames[:n]
+}
+
+// flush 检查静默超过防抖窗口的路径：已消失的丢弃，仍在变化的重新计时，稳定的投递索引；
+// 返回静默后需要重扫的目录
+func (q *watchQueue) flush(now time.Time) map[string]string {
+	type check struct {
+		path string
+		p    watchPending
+	}
+	q.mu.Lock()
+	q.expireRenamesLocked(now)
+	due := make([]check, 0, 16)
+	for path, p := range q.pending {
+		if now.Sub(p.lastEvent) >= watchDebounce {
+			due = append(due, check{path: path, p: *p})
+		}
+	}
+	rescans := map[string]string{}
+	for dir, r := range q.rescans {
+		if now.Sub(r.lastEvent) >= watchStableFor {
+			rescans[dir] = r.projectID
+			delete(q.rescans, dir)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, c := range due {
+		info, err := os.Stat(c.path)
+		q.mu.Lock()
+		p, ok := q.pending[c.path]
+		if !ok || !p.lastEvent.Equal(c.p.lastEvent) {
+			// 检查期间又有新事件，下一轮再看
+			q.mu.Unlock()
+			continue
+		}
+		switch {
+		case err != nil || info.IsDir():
+			delete(q.pending, c.path)
+		case !p.checked || info.Size() != p.size || info.ModTime().UnixNano() != p.modTime:
+			p.checked = true
+			p.size = info.Size()
+			p.modTime = info.ModTime().UnixNano()
+			p.stableSince = now
+		case now.Sub(p.stableSince) >= watchStableFor:
+			select {
+			case q.ready <- watchReady{path: c.path, projectID: p.projectID}:
+				delete(q.pending, c.path)
+			default:
+			}
+		}
+		q.mu.Unlock()
+	}
+	return rescans
+}
+
+// SetScanService 用于事件溢出或新目录移入时的目录重扫
+func (s *WatcherService) SetScanService(scanService *ScanService) {
+	s.scanService = scanService
+}
+
+func (s *WatcherService) runEventQueue() {
+	ticker := time.NewTicker(watchTickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			for dir, projectID := range s.events.flush(now) {
+				s.rescanDir(projectID, dir)
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+func (s *WatcherService) runIndexWorker() {
+	for {
+		select {
+		case item := <-s.events.ready:
+			ctx := context.Background()
+			_ = s.projectRepo.UpdateActivity(ctx, item.projectID)
+			_, _ = s.assetService.IndexFile(ctx, IndexFileRequest{
+				Path:      item.path,
+				ProjectID: item.projectID,
+				Trigger:   "watcher",
+			})
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// enqueueFile 文件事件进入合并队列，队列已满时改为所在目录重扫
+func (s *WatcherService) enqueueFile(projectID string, path string) {
+	now := time.Now()
+	if s.events.touch(path, projectID, now) {
+		return
+	}
+	dir := filepath.Dir(path)
+	if s.events.markRescan(dir, projectID, now) {
+		log.Printf("监控事件队列已满，退化为目录重扫: %s", dir)
+	}
+}
+
+// recordRename 记录 Rename 的旧路径以便与随后的 Create 配对；只有已入库的文件或含资产的目录需要配对
+func (s *WatcherService) recordRename(projectID string, path string) {
+	s.events.forget(path)
+	s.mu.Lock()
+	_, watched := s.watchedPaths[path]
+	s.mu.Unlock()
+
+	r := watchRename{path: path, projectID: projectID, at: time.Now(), isDir: watched, size: -1}
+	if !watched {
+		ctx := context.Background()
+		if asset, err := s.assetService.assets.GetByPath(ctx, path); err == nil && asset != nil {
+			r.size = asset.Size
+		} else if n, err := s.assetService.assets.CountByDirectory(ctx, path); err == nil && n > 0 {
+			r.isDir = true
+		} else {
+			return
+		}
+	}
+	s.events.addRename(r)
+}
+
+// applyRename 已配对的移动/重命名直接迁移资产路径
+func (s *WatcherService) applyRename(from watchRename, projectID string, path string, isDir bool) {
+	ctx := context.Background()
+	_ = s.projectRepo.UpdateActivity(ctx, projectID)
+	if !isDir {
+		_, _ = s.assetService.MoveAssetPath(ctx, from.path, path, projectID)
+		return
+	}
+	if !s.renamedDirMatches(ctx, from.path, path) {
+		log.Printf("renamed directory does not match old contents, rescanning: %s -> %s", from.path, path)
+		s.rescanDir(projectID, path)
+		return
+	}
+	if _, err := s.assetService.MoveAssetDirectory(ctx, from.path, path, projectID); err != nil {
+		log.Printf("migrate renamed directory failed: %s -> %s: %v", from.path, path, err)
+		s.rescanDir(projectID, path)
+	}
+}
+
+// renamedDirMatches 抽查旧目录下的资产：相对路径在新目录下都存在才认为是同一目录
+func (s *WatcherService) renamedDirMatches(ctx context.Context, oldDir string, newDir string) bool {
+	sample, err := s.assetService.assets.ListRecentByDirectory(ctx, oldDir, "", 0, 0, watchRenameSample)
+	if err != nil || len(sample) == 0 {
+		return false
+	}
+	for _, a := range sample {
+		rel, err := filepath.Rel(oldDir, a.Path)
+		if err != nil {
+			return false
+		}
+		if _, err := os.Stat(filepath.Join(newDir, rel)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// rescanDir 目录重扫：事件已丢失，目录 mtime 未必变化，因此忽略检查点全量重扫；
+// 扫描队列已满时重新登记，推迟 watchRescanRetry 后再试
+func (s *WatcherService) rescanDir(projectID string, dir string) {
+	if s.scanService == nil {
+		return
+	}
+	_ = s.projectRepo.UpdateActivity(context.Background(), projectID)
+	err := s.scanService.ScanMultipleProjects(context.Background(), []ScanTask{{
+		ProjectID: projectID,
+		Path:      dir,
+		Name:      filepath.Base(dir),
+		Full:      true,
+	}})
+	if errors.Is(err, ErrScanQueueFull) {
+		s.events.markRescan(dir, projectID, time.Now().Add(watchRescanRetry))
+		return
+	}
+	if err != nil {
+		log.Printf("watcher rescan failed: %s: %v", dir, err)
+	}
+}
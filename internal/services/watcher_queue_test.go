@@ -0,0 +1,227 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestWatchQueue_FlushLifecycle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.mov")
+	if err := os.WriteFile(path, []byte("12"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	q := newWatchQueue()
+	t0 := time.Unix(1000, 0)
+	q.touch(path, "p1", t0)
+
+	steps := []struct {
+		name   string
+		at     time.Duration
+		before func()
+		ready  bool
+		queued bool
+	}{
+		{name: "within debounce", at: watchDebounce / 2, queued: true},
+		{name: "first check records size", at: watchDebounce, queued: true},
+		{name: "not yet stable", at: watchDebounce + watchStableFor/2, queued: true},
+		{
+			name:   "size change restarts stability",
+			at:     watchDebounce + watchStableFor,
+			before: func() { _ = os.WriteFile(path, []byte("1234"), 0o644) },
+			queued: true,
+		},
+		{name: "stable after change", at: 2*watchDebounce + 2*watchStableFor, ready: true},
+	}
+	for _, st := range steps {
+		if st.before != nil {
+			st.before()
+		}
+		q.flush(t0.Add(st.at))
+		select {
+		case item := <-q.ready:
+			if !st.ready {
+				t.Fatalf("%s: unexpected ready %+v", st.name, item)
+			}
+			if item.path != path || item.projectID != "p1" {
+				t.Fatalf("%s: ready = %+v", st.name, item)
+			}
+		default:
+			if st.ready {
+				t.Fatalf("%s: expected ready item", st.name)
+			}
+		}
+		if _, ok := q.pending[path]; ok != st.queued {
+			t.Fatalf("%s: pending = %v, want %v", st.name, ok, st.queued)
+		}
+	}
+}
+
+func TestWatchQueue_FlushDropsAndDefers(t *testing.T) {
+	dir := t.TempDir()
+	q := newWatchQueue()
+	t0 := time.Unix(1000, 0)
+
+	// 已删除的文件与目录直接丢弃
+	q.touch(filepath.Join(dir, "gone.mov"), "p1", t0)
+	q.touch(dir, "p1", t0)
+	// 防抖期间的新事件重新计时
+	busy := filepath.Join(dir, "busy.mov")
+	if err := os.WriteFile(busy, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	q.touch(busy, "p1", t0)
+	q.touch(busy, "p1", t0.Add(watchDebounce-time.Millisecond))
+
+	q.flush(t0.Add(watchDebounce))
+	if len(q.pending) != 1 || q.pending[busy] == nil || q.pending[busy].checked {
+		t.Fatalf("pending after flush = %v", q.pending)
+	}
+
+	// 就绪队列已满时稳定文件留在待处理表
+	for i := 0; i < watchReadyQueueSize; i++ {
+		q.ready <- watchReady{}
+	}
+	q.flush(t0.Add(2 * watchDebounce))
+	q.flush(t0.Add(2*watchDebounce + watchStableFor))
+	if q.pending[busy] == nil {
+		t.Fatal("stable file dropped while ready queue is full")
+	}
+	<-q.ready
+	q.flush(t0.Add(2*watchDebounce + watchStableFor + watchTickInterval))
+	if q.pending[busy] != nil {
+		t.Fatal("stable file not delivered once ready queue has room")
+	}
+}
+
+func TestWatchQueue_Overflow(t *testing.T) {
+	q := newWatchQueue()
+	now := time.Unix(1000, 0)
+	for i := 0; i < watchMaxPending; i++ {
+		if !q.touch("/lib/"+strconv.Itoa(i), "p1", now) {
+			t.Fatalf("touch %d rejected below limit", i)
+		}
+	}
+	if q.touch("/lib/overflow", "p1", now) {
+		t.Fatal("touch accepted beyond limit")
+	}
+	if !q.touch("/lib/0", "p1", now) {
+		t.Fatal("known path must still refresh when full")
+	}
+}
+
+func TestWatchQueue_Rescans(t *testing.T) {
+	q := newWatchQueue()
+	t0 := time.Unix(1000, 0)
+	if !q.markRescan("/lib/a", "p1", t0) {
+		t.Fatal("first markRescan should be new")
+	}
+	if q.markRescan("/lib/a", "p1", t0.Add(time.Second)) {
+		t.Fatal("second markRescan should refresh, not add")
+	}
+	// 扫描队列满时以未来时间登记，推迟重试
+	q.markRescan("/lib/b", "p2", t0.Add(watchRescanRetry))
+
+	if got := q.flush(t0.Add(watchStableFor)); len(got) != 0 {
+		t.Fatalf("rescans before quiet period = %v", got)
+	}
+	got := q.flush(t0.Add(time.Second + watchStableFor))
+	if len(got) != 1 || got["/lib/a"] != "p1" {
+		t.Fatalf("rescans = %v", got)
+	}
+	if got := q.flush(t0.Add(watchRescanRetry + watchStableFor)); len(got) != 1 || got["/lib/b"] != "p2" {
+		t.Fatalf("deferred rescans = %v", got)
+	}
+}
+
+func TestWatchQueue_PairRename(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	tests := []struct {
+		name    string
+		renames []watchRename
+		path    string
+		isDir   bool
+		size    int64
+		at      time.Duration
+		want    string // 配对到的旧路径，空表示不配对
+	}{
+		{
+			name:    "file rename in place",
+			renames: []watchRename{{path: "/lib/a/x.mov", size: 10}},
+			path:    "/lib/a/y.mov", size: 10,
+			want: "/lib/a/x.mov",
+		},
+		{
+			name:    "file falls back to size match",
+			renames: []watchRename{{path: "/lib/a/x.mov", size: 10}},
+			path:    "/lib/b/y.mov", size: 10,
+			want: "/lib/a/x.mov",
+		},
+		{
+			name:    "file size differs",
+			renames: []watchRename{{path: "/lib/a/x.mov", size: 10}},
+			path:    "/lib/a/y.mov", size: 11,
+		},
+		{
+			name: "file prefers same name",
+			renames: []watchRename{
+				{path: "/lib/a/other.mov", size: 10},
+				{path: "/lib/a/x.mov", size: 10},
+			},
+			path: "/lib/b/x.mov", size: 10,
+			want: "/lib/a/x.mov",
+		},
+		{
+			name:    "type must match",
+			renames: []watchRename{{path: "/lib/a/x", isDir: true, size: -1}},
+			path:    "/lib/a/x2", size: -1,
+		},
+		{
+			name:    "dir moved keeps name",
+			renames: []watchRename{{path: "/lib/a/day1", isDir: true, size: -1}},
+			path:    "/lib/b/day1", isDir: true,
+			want: "/lib/a/day1",
+		},
+		{
+			name:    "dir renamed in place",
+			renames: []watchRename{{path: "/lib/a/day1", isDir: true, size: -1}},
+			path:    "/lib/a/day01", isDir: true,
+			want: "/lib/a/day1",
+		},
+		{
+			name:    "dir with new name and parent is not paired",
+			renames: []watchRename{{path: "/lib/a/day1", isDir: true, size: -1}},
+			path:    "/lib/b/other", isDir: true,
+		},
+		{
+			name:    "expired rename",
+			renames: []watchRename{{path: "/lib/a/x.mov", size: 10}},
+			path:    "/lib/a/y.mov", size: 10,
+			at: watchRenamePairWindow + time.Millisecond,
+		},
+		{
+			name:    "same path is not a rename",
+			renames: []watchRename{{path: "/lib/a/x.mov", size: 10}},
+			path:    "/lib/a/x.mov", size: 10,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			q := newWatchQueue()
+			for _, r := range tc.renames {
+				r.at = t0
+				q.addRename(r)
+			}
+			got, ok := q.pairRename(tc.path, tc.isDir, tc.size, t0.Add(tc.at))
+			if ok != (tc.want != "") || got.path != tc.want {
+				t.Fatalf("pairRename(%s) = %q, %v; want %q", tc.path, got.path, ok, tc.want)
+			}
+			if ok && len(q.renames) != len(tc.renames)-1 {
+				t.Fatalf("paired rename not consumed: %v", q.renames)
+			}
+		})
+	}
+}
@@ -28,6 +28,8 @@ type WatcherService struct {
 	assetService      *AssetService
 	projectRepo       *repos.ProjectRepo
 	projectSourceRepo *repos.ProjectSourceRepo
+	scanService       *ScanService
+	events            *watchQueue
 	stopChan          chan struct{}
 	stopOnce          sync.Once
 	mu                sync.Mutex
@@ -48,6 +50,7 @@ func NewWatcherService(assetService *AssetService, projectRepo *repos.ProjectRep
 		assetService:      assetService,
 		projectRepo:       projectRepo,
 		projectSourceRepo: projectSourceRepo,
+		events:            newWatchQueue(),
 		stopChan:          make(chan struct{}),
 		watchedPaths:      make(map[string]string),
 		warnedPermissions: make(map[string]struct{}),
@@ -92,6 +95,10 @@ func (s *WatcherService) Start(ctx context.Context) {
 	}()
 
 	go s.runSessionJanitor()
+	go s.runEventQueue()
+	for i := 0; i < watchIndexWorkers; i++ {
+		go s.runIndexWorker()
+	}
 }
 
 func (s *WatcherService) WatchProject(projectID string, rootPath string) {
@@ -172,6 +179,77 @@ func (s *WatcherService) StopSessionWatch(_ context.Context, projectID string, p
 	return s.stopSessionByKey(key)
 }
 
+// Relocate 库来源根目录迁移（卷换了挂载点）后，把旧根下的监控迁到新根：
+// 临时会话按剩余时长在新路径重建，其余监控目录逐个映射；再为新根下的项目来源补上根目录监控
+// （卷离线期间启动时这些根目录不存在，没有被监控）
+func (s *WatcherService) Relocate(ctx context.Context, oldRoot string, newRoot string) {
+	oldRoot, newRoot = filepath.Clean(oldRoot), filepath.Clean(newRoot)
+	mapPath := func(path string) string {
+		rel, err := filepath.Rel(oldRoot, path)
+		if err != nil {
+			return path
+		}
+		return filepath.Join(newRoot, rel)
+	}
+
+	type movedSession struct {
+		key, projectID, path string
+		ttl                  int
+	}
+	now := time.Now().Unix()
+	var sessions []movedSession
+	s.mu.Lock()
+	for key, sess := range s.sessionWatches {
+		if sess != nil && pathWithinRoot(sess.RootPath, oldRoot) {
+			sessions = append(sessions, movedSession{key: key, projectID: sess.ProjectID, path: mapPath(sess.RootPath), ttl: int(sess.ExpiresAt - now)})
+		}
+	}
+	s.mu.Unlock()
+	for _, m := range sessions {
+		_ = s.stopSessionByKey(m.key)
+	}
+
+	moved := map[string]string{}
+	s.mu.Lock()
+	for path, projectID := range s.watchedPaths {
+		if pathWithinRoot(path, oldRoot) {
+			moved[path] = projectID
+		}
+	}
+	s.mu.Unlock()
+	for path, projectID := range moved {
+		s.removeWatch(path)
+		s.watchRootOnly(projectID, mapPath(path))
+	}
+
+	for _, m := range sessions {
+		if m.ttl <= 0 {
+			continue // 已过期
+		}
+		if _, err := s.StartSessionWatch(ctx, m.projectID, m.path, m.ttl); err != nil {
+			log.Printf("restart session watch after relocate failed: %s: %v", m.path, err)
+		}
+	}
+
+	projects, err := s.projectRepo.List(ctx)
+	if err != nil {
+		log.Printf("list projects after relocate failed: %v", err)
+		return
+	}
+	for _, p := range projects {
+		roots, err := s.resolveWatchRoots(ctx, p.ID, p.Path)
+		if err != nil {
+			continue
+		}
+		for _, root := range roots {
+			if pathWithinRoot(root, newRoot) {
+				s.watchRootOnly(p.ID, root)
+			}
+		}
+	}
+	log.Printf("监控已迁移: %s -> %s (%d 个目录)", oldRoot, newRoot, len(moved))
+}
+
 func (s *WatcherService) watchRootOnly(projectID string, rootPath string) {
 	rootPath = strings.TrimSpace(rootPath)
 	if rootPath == "" {
@@ -227,7 +305,7 @@ func (s *WatcherService) watchRecursive(projectID string, rootPath string, sessi
 		if !d.IsDir() {
 			return nil
 		}
-		if strings.HasPrefix(d.Name(), ".") && d.Name() != "." {
+		if path != rootPath && s.assetService.scanIgnore.Match(path, true, false).Ignored {
 			return filepath.SkipDir
 		}
 
@@ -286,11 +364,6 @@ func (s *WatcherService) removeWatch(path string) {
 }
 
 func (s *WatcherService) handleEvent(event fsnotify.Event) {
-	base := filepath.Base(event.Name)
-	if strings.HasPrefix(base, ".") || base == "Thumbs.db" {
-		return
-	}
-
 	dir := filepath.Dir(event.Name)
 	projectID := ""
 	s.mu.Lock()
@@ -309,24 +382,46 @@ func (s *WatcherService) handleEvent(event fsnotify.Event) {
 		return
 	}
 
-	_ = s.projectRepo.UpdateActivity(context.Background(), projectID)
-
-	if event.Has(fsnotify.Create) {
+	// Create/Write 先进入合并队列，文件写入完成后才索引；Rename 与随后的 Create 配对为一次移动
+	switch {
+	case event.Has(fsnotify.Create) || event.Has(fsnotify.Write):
 		info, err := os.Stat(event.Name)
-		if err == nil && info.IsDir() {
+		if err != nil {
+			return
+		}
+		isDir := info.IsDir()
+		// 忽略规则与扫描一致：被忽略的目录不加监听，被忽略的文件不入库
+		if s.assetService.scanIgnore.Match(event.Name, isDir, false).Ignored {
+			return
+		}
+		if !event.Has(fsnotify.Create) {
+			if !isDir {
+				s.enqueueFile(projectID, event.Name)
+			}
+			return
+		}
+		if isDir {
 			for _, key := range s.matchingSessionKeys(projectID, event.Name) {
 				_, _ = s.watchRecursive(projectID, event.Name, key)
 			}
 		}
-	}
-
-	if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
-		_, _ = s.assetService.IndexFile(context.Background(), IndexFileRequest{
-			Path:      event.Name,
-			ProjectID: projectID,
-			Trigger:   "watcher",
-		})
-	} else if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		if from, ok := s.events.pairRename(event.Name, isDir, info.Size(), time.Now()); ok {
+			s.applyRename(from, projectID, event.Name, isDir)
+			return
+		}
+		if isDir {
+			// 从监控范围外移入的目录，静默后重扫
+			s.events.markRescan(event.Name, projectID, time.Now())
+			return
+		}
+		s.enqueueFile(projectID, event.Name)
+	case event.Has(fsnotify.Rename):
+		_ = s.projectRepo.UpdateActivity(context.Background(), projectID)
+		s.recordRename(projectID, event.Name)
+		s.removeWatch(event.Name)
+	case event.Has(fsnotify.Remove):
+		_ = s.projectRepo.UpdateActivity(context.Background(), projectID)
+		s.events.forget(event.Name)
 		s.removeWatch(event.Name)
 	}
 }
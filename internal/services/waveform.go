@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/processor/parsers/audio"
+)
+
+const (
+	waveformTaskType     = "waveform"
+	waveformTaskPriority = 20
+	waveformRendition    = "waveform"
+	// waveformPeaksPerSecond 解码时的峰值密度；长音频再合并到 waveformMaxPeaks 以内
+	waveformPeaksPerSecond = 100
+	waveformMaxPeaks       = 4000
+)
+
+// Waveform 预计算的峰值数据，字段与 audiowaveform JSON 格式一致，前端可直接交给 peaks.js 等组件。
+// Data 为 [min0, max0, min1, max1, ...]，8 位有符号取值
+type Waveform struct {
+	Version         int     `json:"version"`
+	Channels        int     `json:"channels"`
+	SampleRate      int     `json:"sample_rate"`
+	SamplesPerPixel int     `json:"samples_per_pixel"`
+	Bits            int     `json:"bits"`
+	Length          int     `json:"length"`
+	Duration        float64 `json:"duration"`
+	Data            []int8  `json:"data"`
+}
+
+// WaveformFile 已生成的波形文件
+type WaveformFile struct {
+	models.ThumbnailRendition
+	Path string
+}
+
+// GetWaveform 返回资产当前内容已生成的波形文件
+func (s *ThumbnailService) GetWaveform(ctx context.Context, asset *models.Asset) (*WaveformFile, error) {
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		return nil, ErrThumbnailNotFound
+	}
+	rends, err := s.thumbs.ListByKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range rends {
+		if r.Rendition == waveformRendition && s.exists(r.RelPath) {
+			return &WaveformFile{ThumbnailRendition: r, Path: filepath.Join(s.dataDir, r.RelPath)}, nil
+		}
+	}
+	return nil, ErrThumbnailNotFound
+}
+
+// GenerateWaveform 为音频计算峰值并按内容指纹缓存；非音频返回 nil，已存在时直接复用
+func (s *ThumbnailService) GenerateWaveform(ctx context.Context, asset *models.Asset) (*WaveformFile, error) {
+	if detectAssetFileType(asset.Path) != "audio" {
+		return nil, nil
+	}
+	key, err := thumbnailContentKey(asset)
+	if err != nil {
+		return nil, err
+	}
+	unlock := s.lockKey(key)
+	defer unlock()
+	if existing, err := s.GetWaveform(ctx, asset); err == nil {
+		return existing, nil
+	} else if !errors.Is(err, ErrThumbnailNotFound) {
+		return nil, err
+	}
+
+	peaks, err := audio.ComputePeaks(ctx, asset.Path, waveformPeaksPerSecond)
+	if err != nil {
+		return nil, err
+	}
+	wf := buildWaveform(peaks, waveformMaxPeaks)
+	data, err := json.Marshal(wf)
+	if err != nil {
+		return nil, err
+	}
+
+	relDir := thumbnailRelDir(key)
+	if err := os.MkdirAll(filepath.Join(s.dataDir, relDir), 0o755); err != nil {
+		return nil, err
+	}
+	rel := filepath.Join(relDir, waveformRendition+".json")
+	if err := writeFileAtomic(filepath.Join(s.dataDir, rel), data); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	rend := models.ThumbnailRendition{
+		ContentKey: key,
+		Rendition:  waveformRendition,
+		Format:     "json",
+		RelPath:    rel,
+		Width:      wf.Length,
+		Bytes:      int64(len(data)),
+		ETag:       hex.EncodeToString(sum[:8]),
+		CreatedAt:  time.Now().Unix(),
+	}
+	if err := s.thumbs.Upsert(ctx, &rend); err != nil {
+		return nil, err
+	}
+	return &WaveformFile{ThumbnailRendition: rend, Path: filepath.Join(s.dataDir, rel)}, nil
+}
+
+// buildWaveform 相邻峰值合并到不超过 maxPeaks 对，并量化为 8 位
+func buildWaveform(p *audio.Peaks, maxPeaks int) *Waveform {
+	group := 1
+	if n := len(p.Max); n > maxPeaks {
+		group = (n + maxPeaks - 1) / maxPeaks
+	}
+	wf := &Waveform{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      p.SampleRate,
+		SamplesPerPixel: p.SamplesPerPeak * group,
+		Bits:            8,
+	}
+	if p.SampleRate > 0 {
+		wf.Duration = float64(len(p.Max)*p.SamplesPerPeak) / float64(p.SampleRate)
+	}
+	for i := 0; i < len(p.Max); i += group {
+		lo, hi := p.Min[i], p.Max[i]
+		for j := i + 1; j < i+group && j < len(p.Max); j++ {
+			lo = min(lo, p.Min[j])
+			hi = max(hi, p.Max[j])
+		}
+		wf.Data = append(wf.Data, quantizePeak(lo), quantizePeak(hi))
+	}
+	wf.Length = len(wf.Data) / 2
+	return wf
+}
+
+func quantizePeak(v float32) int8 {
+	return int8(max(-1, min(1, v)) * 127)
+}
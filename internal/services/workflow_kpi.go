@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+)
+
+// KPI 规则类型
+//
+//	asset_count        绑定资产数量 >= min（可按 role / status 过滤）
+//	assets_all_status  绑定资产全部处于 status（默认 READY，可按 role 过滤）
+//	artifact_count     指定 kind 的产物数量 >= min
+//	publish_record     指定平台的发布记录数量 >= min
+const (
+	KPIRuleAssetCount      = "asset_count"
+	KPIRuleAssetsAllStatus = "assets_all_status"
+	KPIRuleArtifactCount   = "artifact_count"
+	KPIRulePublishRecord   = "publish_record"
+)
+
+// KPIRule 存储于 project_workflow_steps.kpi_rules_json 的单条规则
+type KPIRule struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Label    string `json:"label,omitempty"`
+	Required *bool  `json:"required,omitempty"`
+	Min      int    `json:"min,omitempty"`
+	Role     string `json:"role,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Kind     string `json:"kind,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+func (r KPIRule) isRequired() bool {
+	return r.Required == nil || *r.Required
+}
+
+// KPIRuleResult 单条规则的评估结果
+type KPIRuleResult struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Label    string `json:"label,omitempty"`
+	Required bool   `json:"required"`
+	Passed   bool   `json:"passed"`
+	Actual   int    `json:"actual"`
+	Expected int    `json:"expected"`
+	Message  string `json:"message,omitempty"`
+}
+
+// KPIResult 写入 kpi_result_json 的评估结果
+type KPIResult struct {
+	EvaluatedAt    int64           `json:"evaluated_at"`
+	Passed         bool            `json:"passed"`
+	RequiredTotal  int             `json:"required_total"`
+	RequiredPassed int             `json:"required_passed"`
+	Rules          []KPIRuleResult `json:"rules"`
+}
+
+// parseKPIRules 解析并规范化规则列表，未知类型直接报错
+func parseKPIRules(raw string) ([]KPIRule, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || raw == "null" {
+		return []KPIRule{}, nil
+	}
+	var rules []KPIRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return nil, errors.New("kpi_rules must be a json array")
+	}
+	for i := range rules {
+		r := &rules[i]
+		r.Type = strings.ToLower(strings.TrimSpace(r.Type))
+		role, ok := normalizeKPIRole(r.Role)
+		if !ok {
+			return nil, fmt.Errorf("kpi rule #%d: unknown role %q (source, deliverable, engine)", i, r.Role)
+		}
+		r.Role = role
+		r.Status = strings.ToUpper(strings.TrimSpace(r.Status))
+		r.Kind = strings.TrimSpace(r.Kind)
+		r.Platform = strings.TrimSpace(r.Platform)
+		if r.Min < 0 {
+			return nil, fmt.Errorf("kpi rule #%d: min must be >= 0", i)
+		}
+		switch r.Type {
+		case KPIRuleAssetCount, KPIRulePublishRecord:
+		case KPIRuleAssetsAllStatus:
+			if r.Status == "" {
+				r.Status = "READY"
+			}
+		case KPIRuleArtifactCount:
+			if r.Kind == "" {
+				return nil, fmt.Errorf("kpi rule #%d: kind is required", i)
+			}
+		default:
+			return nil, fmt.Errorf("kpi rule #%d: unknown type %q", i, r.Type)
+		}
+		if r.ID == "" {
+			r.ID = fmt.Sprintf("rule_%d", i+1)
+		}
+	}
+	return rules, nil
+}
+
+// normalizeKPIRole 兼容 final 等别名，映射到 project_assets.role 的取值；未知角色返回 false
+func normalizeKPIRole(role string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(role)) {
+	case "":
+		return "", true
+	case "source":
+		return "source", true
+	case "final", "deliverable", "output":
+		return "deliverable", true
+	case "engine", "project_file":
+		return "engine", true
+	default:
+		return "", false
+	}
+}
+
+// evaluateKPIRules 基于项目实时数据评估规则
+func (s *WorkflowService) evaluateKPIRules(ctx context.Context, projectID string, rules []KPIRule) (*KPIResult, error) {
+	res := &KPIResult{
+		EvaluatedAt: time.Now().Unix(),
+		Rules:       make([]KPIRuleResult, 0, len(rules)),
+	}
+
+	var bindings []struct{ role, status string }
+	bindingsLoaded := false
+	loadBindings := func() error {
+		if bindingsLoaded {
+			return nil
+		}
+		bindingsLoaded = true
+		if s.projectAssets == nil {
+			return nil
+		}
+		details, err := s.projectAssets.ListBindingDetailsByProject(ctx, projectID)
+		if err != nil {
+			return err
+		}
+		for _, d := range details {
+			bindings = append(bindings, struct{ role, status string }{d.Role, strings.ToUpper(d.AssetStatus)})
+		}
+		return nil
+	}
+
+	for _, rule := range rules {
+		rr := KPIRuleResult{
+			ID:       rule.ID,
+			Type:     rule.Type,
+			Label:    rule.Label,
+			Required: rule.isRequired(),
+		}
+		minCount := rule.Min
+		if minCount == 0 {
+			minCount = 1
+		}
+
+		switch rule.Type {
+		case KPIRuleAssetCount:
+			if err := loadBindings(); err != nil {
+				return nil, err
+			}
+			for _, b := range bindings {
+				if rule.Role != "" && b.role != rule.Role {
+					continue
+				}
+				if rule.Status != "" && b.status != rule.Status {
+					continue
+				}
+				rr.Actual++
+			}
+			rr.Expected = minCount
+			rr.Passed = rr.Actual >= minCount
+		case KPIRuleAssetsAllStatus:
+			if err := loadBindings(); err != nil {
+				return nil, err
+			}
+			total := 0
+			for _, b := range bindings {
+				if rule.Role != "" && b.role != rule.Role {
+					continue
+				}
+				total++
+				if b.status == rule.Status {
+					rr.Actual++
+				}
+			}
+			rr.Expected = total
+			rr.Passed = total > 0 && rr.Actual == total
+			if total == 0 {
+				rr.Message = "no bound assets"
+			}
+		case KPIRuleArtifactCount:
+			if s.artifacts != nil {
+				n, err := s.artifacts.CountByProject(ctx, projectID, rule.Kind)
+				if err != nil {
+					return nil, err
+				}
+				rr.Actual = n
+			}
+			rr.Expected = minCount
+			rr.Passed = rr.Actual >= minCount
+		case KPIRulePublishRecord:
+			if s.publish != nil {
+				n, err := s.publish.CountRecords(ctx, projectID, rule.Platform)
+				if err != nil {
+					return nil, err
+				}
+				rr.Actual = n
+			}
+			rr.Expected = minCount
+			rr.Passed = rr.Actual >= minCount
+		}
+
+		if rr.Required {
+			res.RequiredTotal++
+			if rr.Passed {
+				res.RequiredPassed++
+			}
+		}
+		res.Rules = append(res.Rules, rr)
+	}
+	res.Passed = len(rules) > 0 && res.RequiredPassed == res.RequiredTotal
+	return res, nil
+}
+
+type EvaluateWorkflowStepKPIRequest struct {
+	StepID string `json:"step_id"`
+	// Rules 非空时先替换步骤上的规则再评估
+	Rules json.RawMessage `json:"rules,omitempty"`
+	// AutoComplete 为 true 且所有必需规则通过时，将步骤标记为 done 并推进工作流
+	AutoComplete bool `json:"auto_complete"`
+}
+
+type WorkflowStepKPIEvaluation struct {
+	Step          *models.ProjectWorkflowStep `json:"step"`
+	Result        *KPIResult                  `json:"result"`
+	AutoCompleted bool                        `json:"auto_completed"`
+	Snapshot      *ProjectWorkflowSnapshot    `json:"snapshot,omitempty"`
+}
+
+// EvaluateWorkflowStepKPI 评估步骤 KPI 并写回 kpi_result_json
+func (s *WorkflowService) EvaluateWorkflowStepKPI(ctx context.Context, req EvaluateWorkflowStepKPIRequest) (*WorkflowStepKPIEvaluation, error) {
+	if req.StepID == "" {
+		return nil, errors.New("step_id is required")
+	}
+	step, err := s.steps.Get(ctx, req.StepID)
+	if err != nil {
+		return nil, err
+	}
+	if step == nil {
+		return nil, errors.New("workflow step not found")
+	}
+	wf, err := s.workflows.Get(ctx, step.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	if wf == nil {
+		return nil, errors.New("workflow not found")
+	}
+
+	rulesJSON := step.KPIRulesJSON
+	if len(req.Rules) > 0 {
+		rulesJSON = string(req.Rules)
+	}
+	rules, err := parseKPIRules(rulesJSON)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.evaluateKPIRules(ctx, wf.ProjectID, rules)
+	if err != nil {
+		return nil, err
+	}
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Rules) > 0 {
+		rulesOut, err := json.Marshal(rules)
+		if err != nil {
+			return nil, err
+		}
+		step.KPIRulesJSON = string(rulesOut)
+	}
+	step.KPIResultJSON = string(resultJSON)
+
+	now := time.Now().Unix()
+	out := &WorkflowStepKPIEvaluation{Result: result}
+	autoComplete := req.AutoComplete && result.Passed &&
+		step.Status != models.WorkflowStepDone && step.Status != models.WorkflowStepSkipped
+	if autoComplete {
+		if err := applyWorkflowStepStatus(step, models.WorkflowStepDone, now); err != nil {
+			return nil, err
+		}
+		out.AutoCompleted = true
+	}
+	step.UpdatedAt = now
+	if err := s.steps.Update(ctx, step); err != nil {
+		return nil, err
+	}
+	if autoComplete {
+		snap, err := s.syncWorkflowProgress(ctx, wf)
+		if err != nil {
+			return nil, err
+		}
+		out.Snapshot = snap
+	}
+	attachWorkflowStepJSON(step)
+	out.Step = step
+	return out, nil
+}
+
+// kpiRulesFromMeta 从模板步骤 meta_json 的 kpi_rules 字段提取规则
+func kpiRulesFromMeta(metaJSON string) string {
+	var meta struct {
+		KPIRules json.RawMessage `json:"kpi_rules"`
+	}
+	if json.Unmarshal([]byte(metaJSON), &meta) != nil || len(meta.KPIRules) == 0 {
+		return "[]"
+	}
+	if _, err := parseKPIRules(string(meta.KPIRules)); err != nil {
+		return "[]"
+	}
+	return string(meta.KPIRules)
+}
+
+func validateTemplateStepKPIRules(metaJSON string) error {
+	var meta struct {
+		KPIRules json.RawMessage `json:"kpi_rules"`
+	}
+	if json.Unmarshal([]byte(metaJSON), &meta) != nil || len(meta.KPIRules) == 0 {
+		return nil
+	}
+	_, err := parseKPIRules(string(meta.KPIRules))
+	return err
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"media-assistant-os/internal/db"
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+)
+
+func TestParseKPIRules_Roles(t *testing.T) {
+	tests := []struct {
+		role    string
+		want    string
+		wantErr bool
+	}{
+		{role: "", want: ""},
+		{role: "source", want: "source"},
+		{role: " Final ", want: "deliverable"},
+		{role: "output", want: "deliverable"},
+		{role: "project_file", want: "engine"},
+		{role: "deliverabel", wantErr: true},
+		{role: "thumbnail", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.role, func(t *testing.T) {
+			rules, err := parseKPIRules(`[{"type":"asset_count","role":"` + tc.role + `","min":1}]`)
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "unknown role") {
+					t.Fatalf("expected unknown role error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rules[0].Role != tc.want {
+				t.Fatalf("role = %q, want %q", rules[0].Role, tc.want)
+			}
+		})
+	}
+}
+
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "db"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d, err := db.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = d.Close() })
+	if err := db.Migrate(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestEvaluateKPIRules(t *testing.T) {
+	ctx := context.Background()
+	d := newTestDB(t)
+
+	projects := repos.NewProjectRepo(d.ORM())
+	assets := repos.NewAssetRepo(d.ORM())
+	projectAssets := repos.NewProjectAssetRepo(d.ORM())
+	artifacts := repos.NewProjectArtifactRepo(d.ORM())
+	p, err := projects.Create(ctx, "kpi", "video")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 个 source（2 READY），1 个 READY deliverable
+	bind := []struct{ path, role, status string }{
+		{"/kpi/a.mov", "source", "READY"},
+		{"/kpi/b.mov", "source", "READY"},
+		{"/kpi/c.mov", "source", "PENDING"},
+		{"/kpi/final.mp4", "deliverable", "READY"},
+	}
+	for _, b := range bind {
+		a, err := assets.Create(ctx, b.path, 10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.SQL().ExecContext(ctx, "UPDATE assets SET status = ? WHERE id = ?", b.status, a.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := projectAssets.LinkWithOptions(ctx, p.ID, a.ID, &repos.ProjectAssetLinkOptions{Role: b.role}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := artifacts.Create(ctx, &models.ProjectArtifact{ID: "cover" + strconv.Itoa(i), ProjectID: p.ID, Kind: "cover"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// publish 为 nil 时发布记录计数为 0
+	svc := NewWorkflowService(projects, nil, nil, nil, nil, nil, projectAssets, artifacts, nil)
+
+	type ruleWant struct {
+		actual, expected int
+		passed           bool
+		message          string
+	}
+	tests := []struct {
+		name     string
+		rules    string
+		want     []ruleWant
+		required [2]int // RequiredPassed, RequiredTotal
+		passed   bool
+	}{
+		{
+			name:   "no rules never pass",
+			rules:  `[]`,
+			passed: false,
+		},
+		{
+			name: "counts and statuses",
+			rules: `[
+				{"type":"asset_count","role":"source","min":2},
+				{"type":"asset_count","role":"source","status":"ready","min":3},
+				{"type":"assets_all_status","role":"final"},
+				{"type":"artifact_count","kind":"cover"}
+			]`,
+			want: []ruleWant{
+				{actual: 3, expected: 2, passed: true},
+				{actual: 2, expected: 3, passed: false},
+				{actual: 1, expected: 1, passed: true},
+				{actual: 2, expected: 1, passed: true},
+			},
+			required: [2]int{3, 4},
+			passed:   false,
+		},
+		{
+			name: "optional failures do not block",
+			rules: `[
+				{"type":"asset_count"},
+				{"type":"assets_all_status","required":false},
+				{"type":"assets_all_status","role":"engine","required":false},
+				{"type":"publish_record","platform":"youtube","required":false}
+			]`,
+			want: []ruleWant{
+				{actual: 4, expected: 1, passed: true},
+				{actual: 3, expected: 4, passed: false},
+				{actual: 0, expected: 0, passed: false, message: "no bound assets"},
+				{actual: 0, expected: 1, passed: false},
+			},
+			required: [2]int{1, 1},
+			passed:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := parseKPIRules(tc.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := svc.evaluateKPIRules(ctx, p.ID, rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Rules) != len(tc.want) {
+				t.Fatalf("got %d rule results, want %d", len(res.Rules), len(tc.want))
+			}
+			for i, w := range tc.want {
+				got := res.Rules[i]
+				if got.Actual != w.actual || got.Expected != w.expected || got.Passed != w.passed || got.Message != w.message {
+					t.Errorf("rule %s = %+v, want %+v", got.ID, got, w)
+				}
+			}
+			if res.RequiredPassed != tc.required[0] || res.RequiredTotal != tc.required[1] {
+				t.Errorf("required = %d/%d, want %d/%d", res.RequiredPassed, res.RequiredTotal, tc.required[0], tc.required[1])
+			}
+			if res.Passed != tc.passed {
+				t.Errorf("passed = %v, want %v", res.Passed, tc.passed)
+			}
+		})
+	}
+}
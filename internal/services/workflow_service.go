@@ -0,0 +1,1115 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"media-assistant-os/internal/models"
+	"media-assistant-os/internal/repos"
+	"media-assistant-os/internal/utils"
+)
+
+// WorkflowService 管理工作流模板、项目工作流、路线图与项目笔记（工作台）
+type WorkflowService struct {
+	projects  *repos.ProjectRepo
+	templates *repos.WorkflowTemplateRepo
+	workflows *repos.ProjectWorkflowRepo
+	steps     *repos.ProjectWorkflowStepRepo
+	roadmap   *repos.ProjectRoadmapRepo
+	notes     *repos.ProjectNoteRepo
+
+	// KPI 评估数据源
+	projectAssets *repos.ProjectAssetRepo
+	artifacts     *repos.ProjectArtifactRepo
+	publish       *repos.PublishRepo
+}
+
+func NewWorkflowService(
+	projects *repos.ProjectRepo,
+	templates *repos.WorkflowTemplateRepo,
+	workflows *repos.ProjectWorkflowRepo,
+	steps *repos.ProjectWorkflowStepRepo,
+	roadmap *repos.ProjectRoadmapRepo,
+	notes *repos.ProjectNoteRepo,
+	projectAssets *repos.ProjectAssetRepo,
+	artifacts *repos.ProjectArtifactRepo,
+	publish *repos.PublishRepo,
+) *WorkflowService {
+	return &WorkflowService{
+		projects:      projects,
+		templates:     templates,
+		workflows:     workflows,
+		steps:         steps,
+		roadmap:       roadmap,
+		notes:         notes,
+		projectAssets: projectAssets,
+		artifacts:     artifacts,
+		publish:       publish,
+	}
+}
+
+type systemTemplateStep struct {
+	Title         string
+	StepType      string
+	SuggestedDays int
+	Required      bool
+	KPIRules      string
+}
+
+type systemTemplate struct {
+	Name        string
+	Domain      string
+	Description string
+	Steps       []systemTemplateStep
+}
+
+var builtinWorkflowTemplates = []systemTemplate{
+	{
+		Name:        "短视频制作",
+		Domain:      "video",
+		Description: "选题 → 脚本 → 拍摄 → 剪辑 → 包装 → 发布 → 复盘",
+		Steps: []systemTemplateStep{
+			{Title: "选题策划", StepType: "plan", SuggestedDays: 1, Required: true},
+			{Title: "脚本撰写", StepType: "script", SuggestedDays: 1, Required: true},
+			{Title: "素材拍摄", StepType: "shoot", SuggestedDays: 2, Required: true},
+			{Title: "剪辑制作", StepType: "edit", SuggestedDays: 2, Required: true,
+				KPIRules: `[{"id":"final_export","type":"asset_count","role":"final","min":1,"label":"至少一个成片"}]`},
+			{Title: "封面包装", StepType: "package", SuggestedDays: 1, Required: false,
+				KPIRules: `[{"id":"cover","type":"artifact_count","kind":"cover","min":1,"label":"封面已产出"}]`},
+			{Title: "发布上线", StepType: "publish", SuggestedDays: 1, Required: true,
+				KPIRules: `[{"id":"published","type":"publish_record","min":1,"label":"至少一条发布记录"}]`},
+			{Title: "数据复盘", StepType: "review", SuggestedDays: 3, Required: false},
+		},
+	},
+	{
+		Name:        "图文创作",
+		Domain:      "article",
+		Description: "选题 → 撰写 → 配图 → 发布 → 复盘",
+		Steps: []systemTemplateStep{
+			{Title: "选题策划", StepType: "plan", SuggestedDays: 1, Required: true},
+			{Title: "正文撰写", StepType: "script", SuggestedDays: 2, Required: true},
+			{Title: "配图排版", StepType: "package", SuggestedDays: 1, Required: true},
+			{Title: "发布上线", StepType: "publish", SuggestedDays: 1, Required: true,
+				KPIRules: `[{"id":"published","type":"publish_record","min":1,"label":"至少一条发布记录"}]`},
+			{Title: "数据复盘", StepType: "review", SuggestedDays: 3, Required: false},
+		},
+	},
+}
+
+// EnsureSystemTemplates 写入内置模板（按名称幂等）
+func (s *WorkflowService) EnsureSystemTemplates(ctx context.Context) error {
+	for _, def := range builtinWorkflowTemplates {
+		existing, err := s.templates.GetByName(ctx, def.Name)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			continue
+		}
+		now := time.Now().Unix()
+		t := &models.WorkflowTemplate{
+			ID:          utils.NewID(),
+			Name:        def.Name,
+			Domain:      def.Domain,
+			Description: def.Description,
+			IsSystem:    true,
+			IsActive:    true,
+			MetaJSON:    "{}",
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		steps := make([]models.WorkflowTemplateStep, 0, len(def.Steps))
+		for i, st := range def.Steps {
+			metaJSON := "{}"
+			if st.KPIRules != "" {
+				metaJSON = `{"kpi_rules":` + st.KPIRules + `}`
+			}
+			steps = append(steps, models.WorkflowTemplateStep{
+				ID:            utils.NewID(),
+				TemplateID:    t.ID,
+				Title:         st.Title,
+				StepType:      st.StepType,
+				OrderIndex:    i,
+				IsRequired:    st.Required,
+				SuggestedDays: st.SuggestedDays,
+				ChecklistJSON: "[]",
+				MetaJSON:      metaJSON,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			})
+		}
+		if err := s.templates.CreateWithSteps(ctx, t, steps); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ---- Templates ----
+
+func (s *WorkflowService) ListTemplates(ctx context.Context, domain string, includeInactive bool) ([]models.WorkflowTemplate, error) {
+	out, err := s.templates.List(ctx, strings.TrimSpace(domain), includeInactive)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Meta = decodeRawJSON(out[i].MetaJSON, "{}")
+	}
+	return out, nil
+}
+
+type CreateWorkflowTemplateRequest struct {
+	Name        string          `json:"name"`
+	Domain      string          `json:"domain"`
+	Description string          `json:"description"`
+	Meta        json.RawMessage `json:"meta"`
+}
+
+func (s *WorkflowService) CreateTemplate(ctx context.Context, req CreateWorkflowTemplateRequest) (*models.WorkflowTemplate, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("name is required")
+	}
+	existing, err := s.templates.GetByName(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errors.New("template name already exists")
+	}
+	metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	t := &models.WorkflowTemplate{
+		ID:          utils.NewID(),
+		Name:        name,
+		Domain:      strings.TrimSpace(req.Domain),
+		Description: req.Description,
+		IsActive:    true,
+		MetaJSON:    metaJSON,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.templates.Create(ctx, t); err != nil {
+		return nil, err
+	}
+	t.Meta = decodeRawJSON(t.MetaJSON, "{}")
+	return t, nil
+}
+
+func (s *WorkflowService) ListTemplateSteps(ctx context.Context, templateID string) ([]models.WorkflowTemplateStep, error) {
+	if templateID == "" {
+		return nil, errors.New("template_id is required")
+	}
+	out, err := s.templates.ListSteps(ctx, templateID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		attachTemplateStepJSON(&out[i])
+	}
+	return out, nil
+}
+
+type CreateWorkflowTemplateStepRequest struct {
+	TemplateID    string          `json:"template_id"`
+	Title         string          `json:"title"`
+	StepType      string          `json:"step_type"`
+	OrderIndex    *int            `json:"order_index,omitempty"`
+	IsRequired    *bool           `json:"is_required,omitempty"`
+	SuggestedDays int             `json:"suggested_days"`
+	Checklist     json.RawMessage `json:"checklist"`
+	Meta          json.RawMessage `json:"meta"`
+}
+
+func (s *WorkflowService) CreateTemplateStep(ctx context.Context, req CreateWorkflowTemplateStepRequest) (*models.WorkflowTemplateStep, error) {
+	if req.TemplateID == "" {
+		return nil, errors.New("template_id is required")
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, errors.New("title is required")
+	}
+	t, err := s.templates.Get(ctx, req.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, errors.New("template not found")
+	}
+	if t.IsSystem {
+		return nil, errors.New("system template is read-only")
+	}
+
+	orderIndex := 0
+	if req.OrderIndex != nil {
+		orderIndex = *req.OrderIndex
+	} else {
+		maxOrder, err := s.templates.MaxStepOrder(ctx, t.ID)
+		if err != nil {
+			return nil, err
+		}
+		orderIndex = maxOrder + 1
+	}
+	isRequired := true
+	if req.IsRequired != nil {
+		isRequired = *req.IsRequired
+	}
+	stepType := strings.TrimSpace(req.StepType)
+	if stepType == "" {
+		stepType = "task"
+	}
+	if req.SuggestedDays < 0 {
+		return nil, errors.New("suggested_days must be >= 0")
+	}
+	checklistJSON, err := encodeRawJSON(req.Checklist, "[]", "checklist")
+	if err != nil {
+		return nil, err
+	}
+	metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTemplateStepKPIRules(metaJSON); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	step := &models.WorkflowTemplateStep{
+		ID:            utils.NewID(),
+		TemplateID:    t.ID,
+		Title:         title,
+		StepType:      stepType,
+		OrderIndex:    orderIndex,
+		IsRequired:    isRequired,
+		SuggestedDays: req.SuggestedDays,
+		ChecklistJSON: checklistJSON,
+		MetaJSON:      metaJSON,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.templates.CreateStep(ctx, step); err != nil {
+		return nil, err
+	}
+	attachTemplateStepJSON(step)
+	return step, nil
+}
+
+// ---- Project workflow ----
+
+// ProjectWorkflowSnapshot 项目工作流快照：工作流、模板、各步骤状态与整体进度
+type ProjectWorkflowSnapshot struct {
+	Workflow    *models.ProjectWorkflow     `json:"workflow"`
+	Template    *models.WorkflowTemplate    `json:"template,omitempty"`
+	Steps       []WorkflowStepStatus        `json:"steps"`
+	CurrentStep *models.ProjectWorkflowStep `json:"current_step,omitempty"`
+	Progress    WorkflowProgress            `json:"progress"`
+}
+
+type WorkflowStepStatus struct {
+	models.ProjectWorkflowStep
+	IsCurrent bool `json:"is_current"`
+}
+
+type WorkflowProgress struct {
+	Total        int     `json:"total"`
+	Done         int     `json:"done"`
+	Skipped      int     `json:"skipped"`
+	Required     int     `json:"required"`
+	RequiredDone int     `json:"required_done"`
+	Percent      float64 `json:"percent"`
+}
+
+type InitProjectWorkflowRequest struct {
+	ProjectID   string          `json:"project_id"`
+	TemplateID  string          `json:"template_id"`
+	Name        string          `json:"name"`
+	StartAt     int64           `json:"start_at"`
+	TargetEndAt int64           `json:"target_end_at"`
+	Meta        json.RawMessage `json:"meta"`
+	// Reset 为 true 时丢弃现有工作流并按新模板重建，路线图与笔记会迁移到新工作流
+	Reset bool `json:"reset"`
+}
+
+// InitProjectWorkflow 按模板为项目实例化工作流步骤
+func (s *WorkflowService) InitProjectWorkflow(ctx context.Context, req InitProjectWorkflowRequest) (*ProjectWorkflowSnapshot, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	if req.TemplateID == "" {
+		return nil, errors.New("template_id is required")
+	}
+	if err := s.ensureProject(ctx, req.ProjectID); err != nil {
+		return nil, err
+	}
+	t, err := s.templates.Get(ctx, req.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, errors.New("template not found")
+	}
+	if !t.IsActive {
+		return nil, errors.New("template is inactive")
+	}
+	tplSteps, err := s.templates.ListSteps(ctx, t.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(tplSteps) == 0 {
+		return nil, errors.New("template has no steps")
+	}
+	metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.workflows.GetByProject(ctx, req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil && !req.Reset {
+		return nil, errors.New("project workflow already exists")
+	}
+
+	now := time.Now().Unix()
+	startAt := req.StartAt
+	if startAt <= 0 {
+		startAt = now
+	}
+	targetEndAt := req.TargetEndAt
+	if targetEndAt <= 0 {
+		days := 0
+		for _, st := range tplSteps {
+			days += st.SuggestedDays
+		}
+		if days > 0 {
+			targetEndAt = startAt + int64(days)*86400
+		}
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		name = t.Name
+	}
+
+	wf := &models.ProjectWorkflow{
+		ID:               utils.NewID(),
+		ProjectID:        req.ProjectID,
+		TemplateID:       t.ID,
+		Name:             name,
+		Status:           models.ProjectWorkflowActive,
+		CurrentStepIndex: 0,
+		StartAt:          startAt,
+		TargetEndAt:      targetEndAt,
+		MetaJSON:         metaJSON,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+	steps := make([]models.ProjectWorkflowStep, 0, len(tplSteps))
+	for i, st := range tplSteps {
+		tplStepID := st.ID
+		step := models.ProjectWorkflowStep{
+			ID:             utils.NewID(),
+			WorkflowID:     wf.ID,
+			TemplateStepID: &tplStepID,
+			Title:          st.Title,
+			StepType:       st.StepType,
+			OrderIndex:     i,
+			Status:         models.WorkflowStepTodo,
+			IsRequired:     st.IsRequired,
+			KPIRulesJSON:   kpiRulesFromMeta(st.MetaJSON),
+			KPIResultJSON:  "{}",
+			MetaJSON:       nonEmptyJSON(st.MetaJSON, "{}"),
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		}
+		if i == 0 {
+			step.Status = models.WorkflowStepInProgress
+			step.StartedAt = now
+		}
+		steps = append(steps, step)
+	}
+	if existing != nil {
+		err = s.workflows.Reset(ctx, existing.ID, wf, steps)
+	} else {
+		err = s.workflows.CreateWithSteps(ctx, wf, steps)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.buildSnapshot(ctx, wf, steps)
+}
+
+// GetProjectWorkflowSnapshot 返回项目工作流快照；项目尚未初始化工作流时返回 nil
+func (s *WorkflowService) GetProjectWorkflowSnapshot(ctx context.Context, projectID string) (*ProjectWorkflowSnapshot, error) {
+	if projectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	wf, err := s.workflows.GetByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if wf == nil {
+		return nil, nil
+	}
+	steps, err := s.steps.ListByWorkflow(ctx, wf.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.buildSnapshot(ctx, wf, steps)
+}
+
+func (s *WorkflowService) ListProjectWorkflowSteps(ctx context.Context, projectID string) ([]models.ProjectWorkflowStep, error) {
+	if projectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	wf, err := s.workflows.GetByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if wf == nil {
+		return []models.ProjectWorkflowStep{}, nil
+	}
+	steps, err := s.steps.ListByWorkflow(ctx, wf.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range steps {
+		attachWorkflowStepJSON(&steps[i])
+	}
+	return steps, nil
+}
+
+type UpdateProjectWorkflowStepRequest struct {
+	ID       string          `json:"id"`
+	Status   *string         `json:"status,omitempty"`
+	Note     *string         `json:"note,omitempty"`
+	KPIRules json.RawMessage `json:"kpi_rules,omitempty"`
+	Meta     json.RawMessage `json:"meta,omitempty"`
+}
+
+// UpdateProjectWorkflowStep 更新步骤状态/备注，并重新推进 current_step_index
+func (s *WorkflowService) UpdateProjectWorkflowStep(ctx context.Context, req UpdateProjectWorkflowStepRequest) (*ProjectWorkflowSnapshot, error) {
+	if req.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	step, err := s.steps.Get(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if step == nil {
+		return nil, errors.New("workflow step not found")
+	}
+	wf, err := s.workflows.Get(ctx, step.WorkflowID)
+	if err != nil {
+		return nil, err
+	}
+	if wf == nil {
+		return nil, errors.New("workflow not found")
+	}
+
+	now := time.Now().Unix()
+	if req.Status != nil {
+		if err := applyWorkflowStepStatus(step, *req.Status, now); err != nil {
+			return nil, err
+		}
+	}
+	if req.Note != nil {
+		step.Note = *req.Note
+	}
+	if len(req.KPIRules) > 0 {
+		rules, err := parseKPIRules(string(req.KPIRules))
+		if err != nil {
+			return nil, err
+		}
+		rulesJSON, err := json.Marshal(rules)
+		if err != nil {
+			return nil, err
+		}
+		step.KPIRulesJSON = string(rulesJSON)
+	}
+	if len(req.Meta) > 0 {
+		metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+		if err != nil {
+			return nil, err
+		}
+		step.MetaJSON = metaJSON
+	}
+	step.UpdatedAt = now
+	if err := s.steps.Update(ctx, step); err != nil {
+		return nil, err
+	}
+	return s.syncWorkflowProgress(ctx, wf)
+}
+
+// AdvanceProjectWorkflow 完成当前步骤并进入下一步
+func (s *WorkflowService) AdvanceProjectWorkflow(ctx context.Context, projectID string) (*ProjectWorkflowSnapshot, error) {
+	if projectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	wf, err := s.workflows.GetByProject(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if wf == nil {
+		return nil, errors.New("project workflow not found")
+	}
+	steps, err := s.steps.ListByWorkflow(ctx, wf.ID)
+	if err != nil {
+		return nil, err
+	}
+	current := currentWorkflowStep(steps)
+	if current == nil {
+		return nil, errors.New("workflow already completed")
+	}
+	now := time.Now().Unix()
+	if err := applyWorkflowStepStatus(current, models.WorkflowStepDone, now); err != nil {
+		return nil, err
+	}
+	current.UpdatedAt = now
+	if err := s.steps.Update(ctx, current); err != nil {
+		return nil, err
+	}
+	return s.syncWorkflowProgress(ctx, wf)
+}
+
+// syncWorkflowProgress 以第一个未完成（非 done/skipped）步骤作为当前步骤，
+// 将其置为进行中并回写 current_step_index 与工作流状态
+func (s *WorkflowService) syncWorkflowProgress(ctx context.Context, wf *models.ProjectWorkflow) (*ProjectWorkflowSnapshot, error) {
+	steps, err := s.steps.ListByWorkflow(ctx, wf.ID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	index := len(steps)
+	if current := currentWorkflowStep(steps); current != nil {
+		index = current.OrderIndex
+		if current.Status == models.WorkflowStepTodo {
+			current.Status = models.WorkflowStepInProgress
+			if current.StartedAt == 0 {
+				current.StartedAt = now
+			}
+			current.UpdatedAt = now
+			if err := s.steps.Update(ctx, current); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	status := wf.Status
+	if index >= len(steps) {
+		status = models.ProjectWorkflowCompleted
+	} else if status == models.ProjectWorkflowCompleted {
+		status = models.ProjectWorkflowActive
+	}
+	if index != wf.CurrentStepIndex || status != wf.Status {
+		wf.CurrentStepIndex = index
+		wf.Status = status
+		wf.UpdatedAt = now
+		if err := s.workflows.Update(ctx, wf); err != nil {
+			return nil, err
+		}
+	}
+	return s.buildSnapshot(ctx, wf, steps)
+}
+
+func (s *WorkflowService) buildSnapshot(ctx context.Context, wf *models.ProjectWorkflow, steps []models.ProjectWorkflowStep) (*ProjectWorkflowSnapshot, error) {
+	wf.Meta = decodeRawJSON(wf.MetaJSON, "{}")
+	snap := &ProjectWorkflowSnapshot{
+		Workflow: wf,
+		Steps:    make([]WorkflowStepStatus, 0, len(steps)),
+	}
+	t, err := s.templates.Get(ctx, wf.TemplateID)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		t.Meta = decodeRawJSON(t.MetaJSON, "{}")
+		snap.Template = t
+	}
+
+	p := WorkflowProgress{Total: len(steps)}
+	for i := range steps {
+		attachWorkflowStepJSON(&steps[i])
+		st := steps[i]
+		switch st.Status {
+		case models.WorkflowStepDone:
+			p.Done++
+		case models.WorkflowStepSkipped:
+			p.Skipped++
+		}
+		if st.IsRequired {
+			p.Required++
+			if st.Status == models.WorkflowStepDone {
+				p.RequiredDone++
+			}
+		}
+		isCurrent := st.OrderIndex == wf.CurrentStepIndex && wf.Status != models.ProjectWorkflowCompleted
+		if isCurrent {
+			cur := st
+			snap.CurrentStep = &cur
+		}
+		snap.Steps = append(snap.Steps, WorkflowStepStatus{ProjectWorkflowStep: st, IsCurrent: isCurrent})
+	}
+	if p.Total > 0 {
+		p.Percent = float64(p.Done+p.Skipped) * 100 / float64(p.Total)
+	}
+	snap.Progress = p
+	return snap, nil
+}
+
+func currentWorkflowStep(steps []models.ProjectWorkflowStep) *models.ProjectWorkflowStep {
+	for i := range steps {
+		if steps[i].Status != models.WorkflowStepDone && steps[i].Status != models.WorkflowStepSkipped {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+func applyWorkflowStepStatus(step *models.ProjectWorkflowStep, status string, now int64) error {
+	status = strings.ToLower(strings.TrimSpace(status))
+	switch status {
+	case models.WorkflowStepTodo:
+		step.StartedAt = 0
+		step.CompletedAt = 0
+	case models.WorkflowStepInProgress:
+		if step.StartedAt == 0 {
+			step.StartedAt = now
+		}
+		step.CompletedAt = 0
+	case models.WorkflowStepDone:
+		if step.StartedAt == 0 {
+			step.StartedAt = now
+		}
+		step.CompletedAt = now
+	case models.WorkflowStepSkipped:
+		if step.IsRequired {
+			return errors.New("required step cannot be skipped")
+		}
+		step.CompletedAt = now
+	default:
+		return errors.New("invalid step status")
+	}
+	step.Status = status
+	return nil
+}
+
+// ---- Roadmap ----
+
+func (s *WorkflowService) ListRoadmapItems(ctx context.Context, projectID string, status string, limit int) ([]models.ProjectRoadmapItem, error) {
+	if projectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	out, err := s.roadmap.ListByProject(ctx, projectID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Meta = decodeRawJSON(out[i].MetaJSON, "{}")
+	}
+	return out, nil
+}
+
+type CreateRoadmapItemRequest struct {
+	ProjectID  string          `json:"project_id"`
+	ParentID   *string         `json:"parent_id,omitempty"`
+	Title      string          `json:"title"`
+	ItemType   string          `json:"item_type"`
+	Status     string          `json:"status"`
+	Priority   int             `json:"priority"`
+	OrderIndex int             `json:"order_index"`
+	StartAt    int64           `json:"start_at"`
+	DueAt      int64           `json:"due_at"`
+	Note       string          `json:"note"`
+	Meta       json.RawMessage `json:"meta"`
+}
+
+func (s *WorkflowService) CreateRoadmapItem(ctx context.Context, req CreateRoadmapItemRequest) (*models.ProjectRoadmapItem, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return nil, errors.New("title is required")
+	}
+	wf, err := s.workflows.GetByProject(ctx, req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if wf == nil {
+		return nil, errors.New("project workflow not found")
+	}
+	status, err := normalizeRoadmapStatus(req.Status)
+	if err != nil {
+		return nil, err
+	}
+	parentID, err := s.validateRoadmapParent(ctx, req.ProjectID, "", req.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+	if err != nil {
+		return nil, err
+	}
+	itemType := strings.TrimSpace(req.ItemType)
+	if itemType == "" {
+		itemType = "task"
+	}
+
+	now := time.Now().Unix()
+	item := &models.ProjectRoadmapItem{
+		ID:         utils.NewID(),
+		ProjectID:  req.ProjectID,
+		WorkflowID: wf.ID,
+		ParentID:   parentID,
+		Title:      title,
+		ItemType:   itemType,
+		Status:     status,
+		Priority:   req.Priority,
+		OrderIndex: req.OrderIndex,
+		StartAt:    req.StartAt,
+		DueAt:      req.DueAt,
+		Note:       req.Note,
+		MetaJSON:   metaJSON,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.roadmap.Create(ctx, item); err != nil {
+		return nil, err
+	}
+	item.Meta = decodeRawJSON(item.MetaJSON, "{}")
+	return item, nil
+}
+
+type UpdateRoadmapItemRequest struct {
+	ID         string          `json:"id"`
+	ParentID   *string         `json:"parent_id,omitempty"`
+	Title      *string         `json:"title,omitempty"`
+	ItemType   *string         `json:"item_type,omitempty"`
+	Status     *string         `json:"status,omitempty"`
+	Priority   *int            `json:"priority,omitempty"`
+	OrderIndex *int            `json:"order_index,omitempty"`
+	StartAt    *int64          `json:"start_at,omitempty"`
+	DueAt      *int64          `json:"due_at,omitempty"`
+	Note       *string         `json:"note,omitempty"`
+	Meta       json.RawMessage `json:"meta,omitempty"`
+}
+
+func (s *WorkflowService) UpdateRoadmapItem(ctx context.Context, req UpdateRoadmapItemRequest) (*models.ProjectRoadmapItem, error) {
+	if req.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	item, err := s.roadmap.Get(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, errors.New("roadmap item not found")
+	}
+
+	if req.ParentID != nil {
+		parentID, err := s.validateRoadmapParent(ctx, item.ProjectID, item.ID, req.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		item.ParentID = parentID
+	}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return nil, errors.New("title cannot be empty")
+		}
+		item.Title = title
+	}
+	if req.ItemType != nil {
+		if strings.TrimSpace(*req.ItemType) == "" {
+			return nil, errors.New("item_type cannot be empty")
+		}
+		item.ItemType = strings.TrimSpace(*req.ItemType)
+	}
+	if req.Status != nil {
+		status, err := normalizeRoadmapStatus(*req.Status)
+		if err != nil {
+			return nil, err
+		}
+		item.Status = status
+	}
+	if req.Priority != nil {
+		item.Priority = *req.Priority
+	}
+	if req.OrderIndex != nil {
+		item.OrderIndex = *req.OrderIndex
+	}
+	if req.StartAt != nil {
+		item.StartAt = *req.StartAt
+	}
+	if req.DueAt != nil {
+		item.DueAt = *req.DueAt
+	}
+	if req.Note != nil {
+		item.Note = *req.Note
+	}
+	if len(req.Meta) > 0 {
+		metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+		if err != nil {
+			return nil, err
+		}
+		item.MetaJSON = metaJSON
+	}
+
+	item.UpdatedAt = time.Now().Unix()
+	if err := s.roadmap.Update(ctx, item); err != nil {
+		return nil, err
+	}
+	item.Meta = decodeRawJSON(item.MetaJSON, "{}")
+	return item, nil
+}
+
+// validateRoadmapParent 校验父条目属于同一项目；空字符串表示清除父级
+func (s *WorkflowService) validateRoadmapParent(ctx context.Context, projectID string, selfID string, parentID *string) (*string, error) {
+	if parentID == nil {
+		return nil, nil
+	}
+	id := strings.TrimSpace(*parentID)
+	if id == "" {
+		return nil, nil
+	}
+	if id == selfID {
+		return nil, errors.New("roadmap item cannot be its own parent")
+	}
+	parent, err := s.roadmap.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil || parent.ProjectID != projectID {
+		return nil, errors.New("parent roadmap item not found")
+	}
+	return &id, nil
+}
+
+func normalizeRoadmapStatus(status string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "", "todo":
+		return "todo", nil
+	case "in_progress":
+		return "in_progress", nil
+	case "done":
+		return "done", nil
+	case "blocked":
+		return "blocked", nil
+	case "cancelled":
+		return "cancelled", nil
+	default:
+		return "", errors.New("invalid roadmap status")
+	}
+}
+
+// ---- Notes ----
+
+func (s *WorkflowService) ListProjectNotes(ctx context.Context, projectID string, noteType string, limit int) ([]models.ProjectNote, error) {
+	if projectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	out, err := s.notes.ListByProject(ctx, projectID, noteType, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range out {
+		out[i].Meta = decodeRawJSON(out[i].MetaJSON, "{}")
+	}
+	return out, nil
+}
+
+type CreateProjectNoteRequest struct {
+	ProjectID     string          `json:"project_id"`
+	NoteType      string          `json:"note_type"`
+	Title         string          `json:"title"`
+	Content       string          `json:"content"`
+	SourceAssetID *string         `json:"source_asset_id,omitempty"`
+	IsPinned      bool            `json:"is_pinned"`
+	Meta          json.RawMessage `json:"meta"`
+}
+
+func (s *WorkflowService) CreateProjectNote(ctx context.Context, req CreateProjectNoteRequest) (*models.ProjectNote, error) {
+	if req.ProjectID == "" {
+		return nil, errors.New("project_id is required")
+	}
+	if strings.TrimSpace(req.Title) == "" && strings.TrimSpace(req.Content) == "" {
+		return nil, errors.New("title or content is required")
+	}
+	if err := s.ensureProject(ctx, req.ProjectID); err != nil {
+		return nil, err
+	}
+	metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+	if err != nil {
+		return nil, err
+	}
+	noteType := strings.TrimSpace(req.NoteType)
+	if noteType == "" {
+		noteType = "note"
+	}
+
+	var workflowID *string
+	wf, err := s.workflows.GetByProject(ctx, req.ProjectID)
+	if err != nil {
+		return nil, err
+	}
+	if wf != nil {
+		id := wf.ID
+		workflowID = &id
+	}
+
+	now := time.Now().Unix()
+	n := &models.ProjectNote{
+		ID:            utils.NewID(),
+		ProjectID:     req.ProjectID,
+		WorkflowID:    workflowID,
+		NoteType:      noteType,
+		Title:         strings.TrimSpace(req.Title),
+		Content:       req.Content,
+		SourceAssetID: trimOptionalID(req.SourceAssetID),
+		Status:        "active",
+		IsPinned:      req.IsPinned,
+		MetaJSON:      metaJSON,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.notes.Create(ctx, n); err != nil {
+		return nil, err
+	}
+	n.Meta = decodeRawJSON(n.MetaJSON, "{}")
+	return n, nil
+}
+
+type UpdateProjectNoteRequest struct {
+	ID       string          `json:"id"`
+	NoteType *string         `json:"note_type,omitempty"`
+	Title    *string         `json:"title,omitempty"`
+	Content  *string         `json:"content,omitempty"`
+	Status   *string         `json:"status,omitempty"`
+	IsPinned *bool           `json:"is_pinned,omitempty"`
+	Meta     json.RawMessage `json:"meta,omitempty"`
+}
+
+func (s *WorkflowService) UpdateProjectNote(ctx context.Context, req UpdateProjectNoteRequest) (*models.ProjectNote, error) {
+	if req.ID == "" {
+		return nil, errors.New("id is required")
+	}
+	n, err := s.notes.Get(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	if n == nil {
+		return nil, errors.New("note not found")
+	}
+	if req.NoteType != nil {
+		if strings.TrimSpace(*req.NoteType) == "" {
+			return nil, errors.New("note_type cannot be empty")
+		}
+		n.NoteType = strings.TrimSpace(*req.NoteType)
+	}
+	if req.Title != nil {
+		n.Title = strings.TrimSpace(*req.Title)
+	}
+	if req.Content != nil {
+		n.Content = *req.Content
+	}
+	if req.Status != nil {
+		switch *req.Status {
+		case "active", "archived":
+			n.Status = *req.Status
+		default:
+			return nil, errors.New("invalid note status")
+		}
+	}
+	if req.IsPinned != nil {
+		n.IsPinned = *req.IsPinned
+	}
+	if len(req.Meta) > 0 {
+		metaJSON, err := encodeRawJSON(req.Meta, "{}", "meta")
+		if err != nil {
+			return nil, err
+		}
+		n.MetaJSON = metaJSON
+	}
+
+	n.UpdatedAt = time.Now().Unix()
+	if err := s.notes.Update(ctx, n); err != nil {
+		return nil, err
+	}
+	n.Meta = decodeRawJSON(n.MetaJSON, "{}")
+	return n, nil
+}
+
+// ---- helpers ----
+
+func (s *WorkflowService) ensureProject(ctx context.Context, projectID string) error {
+	if s.projects == nil {
+		return nil
+	}
+	p, err := s.projects.Get(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return errors.New("project not found")
+	}
+	return nil
+}
+
+func attachTemplateStepJSON(st *models.WorkflowTemplateStep) {
+	st.Checklist = decodeRawJSON(st.ChecklistJSON, "[]")
+	st.Meta = decodeRawJSON(st.MetaJSON, "{}")
+}
+
+func attachWorkflowStepJSON(st *models.ProjectWorkflowStep) {
+	st.KPIRules = decodeRawJSON(st.KPIRulesJSON, "[]")
+	st.KPIResult = decodeRawJSON(st.KPIResultJSON, "{}")
+	st.Meta = decodeRawJSON(st.MetaJSON, "{}")
+}
+
+// encodeRawJSON 校验请求中的 JSON 字段，空值时返回 empty
+func encodeRawJSON(raw json.RawMessage, empty string, field string) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return empty, nil
+	}
+	if !json.Valid(raw) {
+		return "", errors.New(field + " must be valid json")
+	}
+	return string(raw), nil
+}
+
+// decodeRawJSON 将存储的 JSON 文本转换为响应字段，空值（与 empty 相同）时返回 nil
+func decodeRawJSON(stored string, empty string) json.RawMessage {
+	if stored == "" || stored == empty {
+		return nil
+	}
+	if !json.Valid([]byte(stored)) {
+		return nil
+	}
+	return json.RawMessage(stored)
+}
+
+func nonEmptyJSON(v string, fallback string) string {
+	if strings.TrimSpace(v) == "" {
+		return fallback
+	}
+	return v
+}
+
+func trimOptionalID(v *string) *string {
+	if v == nil {
+		return nil
+	}
+	id := strings.TrimSpace(*v)
+	if id == "" {
+		return nil
+	}
+	return &id
+}
@@ -0,0 +1,119 @@
+package utils
+
+import "sync"
+
+// BKTree 基于汉明距离的 BK 树，用于 64 位感知哈希的近邻检索。
+// 相同哈希的多个 ID 合并在同一节点；并发安全。
+type BKTree struct {
+	mu   sync.RWMutex
+	root *bkNode
+	size int
+}
+
+type bkNode struct {
+	hash     uint64
+	ids      []string
+	children map[int]*bkNode
+}
+
+// BKMatch 检索命中项
+type BKMatch struct {
+	ID       string
+	Hash     uint64
+	Distance int
+}
+
+func NewBKTree() *BKTree {
+	return &BKTree{}
+}
+
+// Len 返回树中 ID 数量
+func (t *BKTree) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.size
+}
+
+// Add 插入 (hash, id)；同一 ID 在同一哈希下重复插入无副作用
+func (t *BKTree) Add(hash uint64, id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.root == nil {
+		t.root = &bkNode{hash: hash, ids: []string{id}}
+		t.size++
+		return
+	}
+	node := t.root
+	for {
+		d := HammingDistance(node.hash, hash)
+		if d == 0 {
+			for _, existing := range node.ids {
+				if existing == id {
+					return
+				}
+			}
+			node.ids = append(node.ids, id)
+			t.size++
+			return
+		}
+		child, ok := node.children[d]
+		if !ok {
+			if node.children == nil {
+				node.children = make(map[int]*bkNode)
+			}
+			node.children[d] = &bkNode{hash: hash, ids: []string{id}}
+			t.size++
+			return
+		}
+		node = child
+	}
+}
+
+// Remove 删除 (hash, id)；节点本身保留以维持树结构
+func (t *BKTree) Remove(hash uint64, id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	node := t.root
+	for node != nil {
+		d := HammingDistance(node.hash, hash)
+		if d == 0 {
+			for i, existing := range node.ids {
+				if existing == id {
+					node.ids = append(node.ids[:i], node.ids[i+1:]...)
+					t.size--
+					return
+				}
+			}
+			return
+		}
+		node = node.children[d]
+	}
+}
+
+// Search 返回与 hash 距离不超过 maxDistance 的全部 ID
+func (t *BKTree) Search(hash uint64, maxDistance int) []BKMatch {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var out []BKMatch
+	if t.root == nil {
+		return out
+	}
+	stack := []*bkNode{t.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		d := HammingDistance(node.hash, hash)
+		if d <= maxDistance {
+			for _, id := range node.ids {
+				out = append(out, BKMatch{ID: id, Hash: node.hash, Distance: d})
+			}
+		}
+		// 三角不等式：只有边权落在 [d-max, d+max] 的子树可能命中
+		for edge, child := range node.children {
+			if edge >= d-maxDistance && edge <= d+maxDistance {
+				stack = append(stack, child)
+			}
+		}
+	}
+	return out
+}
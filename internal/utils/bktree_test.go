@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestBKTree_Search(t *testing.T) {
+	tree := NewBKTree()
+	tree.Add(0x0, "a")
+	tree.Add(0x1, "b")  // 距离 1
+	tree.Add(0x3, "c")  // 距离 2
+	tree.Add(0xff, "d") // 距离 8
+	tree.Add(0x1, "b2") // 同哈希合并
+	tree.Add(0x1, "b")  // 重复插入无副作用
+
+	tests := []struct {
+		name string
+		hash uint64
+		max  int
+		want []string
+	}{
+		{"exact", 0x0, 0, []string{"a"}},
+		{"radius one", 0x0, 1, []string{"a", "b", "b2"}},
+		{"radius two", 0x0, 2, []string{"a", "b", "b2", "c"}},
+		{"radius eight", 0x0, 8, []string{"a", "b", "b2", "c", "d"}},
+		{"from far side", 0xff, 0, []string{"d"}},
+		{"no hit", 0xffff0000, 3, nil},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := matchIDs(tree.Search(tc.hash, tc.max)); !equalIDs(got, tc.want) {
+				t.Fatalf("Search(%#x, %d) = %v, want %v", tc.hash, tc.max, got, tc.want)
+			}
+		})
+	}
+	if tree.Len() != 5 {
+		t.Fatalf("Len = %d, want 5", tree.Len())
+	}
+}
+
+func TestBKTree_Remove(t *testing.T) {
+	tree := NewBKTree()
+	tree.Add(0x0, "a")
+	tree.Add(0x1, "b")
+	tree.Add(0x3, "c")
+
+	tree.Remove(0x1, "b")
+	tree.Remove(0x1, "missing")
+	tree.Remove(0xf0, "a") // 哈希不符不删除
+
+	if tree.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", tree.Len())
+	}
+	// 被删节点保留结构，其子树仍可检索
+	if got := matchIDs(tree.Search(0x0, 2)); !equalIDs(got, []string{"a", "c"}) {
+		t.Fatalf("Search after remove = %v", got)
+	}
+}
+
+func TestBKTree_MatchesLinearScan(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	tree := NewBKTree()
+	hashes := make([]uint64, 500)
+	for i := range hashes {
+		// 以少量基准哈希翻转若干位，制造聚集的近邻
+		h := uint64(rng.Intn(4)) * 0x0f0f0f0f0f0f0f0f
+		for j := 0; j < rng.Intn(12); j++ {
+			h ^= 1 << uint(rng.Intn(64))
+		}
+		hashes[i] = h
+		tree.Add(h, strconv.Itoa(i))
+	}
+	for q := 0; q < 50; q++ {
+		query := hashes[rng.Intn(len(hashes))] ^ 1<<uint(rng.Intn(64))
+		for _, max := range []int{0, 3, 10} {
+			want := 0
+			seen := map[uint64]bool{}
+			for _, h := range hashes {
+				if !seen[h] && HammingDistance(h, query) <= max {
+					want++
+				}
+				seen[h] = true
+			}
+			hashesHit := map[uint64]bool{}
+			for _, m := range tree.Search(query, max) {
+				if m.Distance != HammingDistance(m.Hash, query) || m.Distance > max {
+					t.Fatalf("bad match %+v for query %#x max %d", m, query, max)
+				}
+				hashesHit[m.Hash] = true
+			}
+			if len(hashesHit) != want {
+				t.Fatalf("query %#x max %d: tree found %d hashes, linear scan %d", query, max, len(hashesHit), want)
+			}
+		}
+	}
+}
+
+func matchIDs(ms []BKMatch) []string {
+	var ids []string
+	for _, m := range ms {
+		ids = append(ids, m.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,22 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// DeviceID 返回路径所在设备的标识，用于按设备限流
+func DeviceID(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+	return strconv.FormatUint(uint64(st.Dev), 10), true
+}
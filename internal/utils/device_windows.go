@@ -0,0 +1,18 @@
+//go:build windows
+
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DeviceID 返回路径所在卷（盘符或 UNC 共享），用于按设备限流
+func DeviceID(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	vol := strings.ToLower(filepath.VolumeName(abs))
+	return vol, vol != ""
+}
@@ -4,6 +4,7 @@ import (
 	"image"
 	_ "image/jpeg" // Register decoders
 	_ "image/png"
+	"math/bits"
 	"os"
 )
 
@@ -93,13 +94,5 @@ func DHashFromImage(img image.Image) (uint64, error) {
 // < 5: 极相似/同一张图
 // > 10: 不同的图
 func HammingDistance(h1, h2 uint64) int {
-	xor := h1 ^ h2
-	dist := 0
-	for xor > 0 {
-		if xor&1 == 1 {
-			dist++
-		}
-		xor >>= 1
-	}
-	return dist
+	return bits.OnesCount64(h1 ^ h2)
 }
@@ -0,0 +1,125 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchToken 查询词元；Prefix 为 true 时按前缀匹配
+type SearchToken struct {
+	Text   string
+	Prefix bool
+}
+
+// IsCJK 判断字符是否属于中日韩文字（这些文字没有空格分词）
+func IsCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+type searchRun struct {
+	text string
+	cjk  bool
+}
+
+// splitSearchRuns 将文本切分为连续的 CJK 段和字母数字段，其余字符视为分隔符
+func splitSearchRuns(s string) []searchRun {
+	var out []searchRun
+	var buf []rune
+	cjk := false
+	flush := func() {
+		if len(buf) > 0 {
+			out = append(out, searchRun{text: string(buf), cjk: cjk})
+			buf = buf[:0]
+		}
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case IsCJK(r):
+			if !cjk {
+				flush()
+				cjk = true
+			}
+			buf = append(buf, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if cjk {
+				flush()
+				cjk = false
+			}
+			buf = append(buf, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return out
+}
+
+// splitAlnum 在字母/数字边界处再拆分（img2024 → img, 2024）
+func splitAlnum(word string) []string {
+	var out []string
+	start := 0
+	runes := []rune(word)
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsDigit(runes[i]) != unicode.IsDigit(runes[i-1]) {
+			out = append(out, string(runes[start:i]))
+			start = i
+		}
+	}
+	if start > 0 {
+		out = append(out, string(runes[start:]))
+	}
+	return out
+}
+
+// SearchIndexTokens 生成写入全文索引的词元：
+// CJK 段输出单字与二元组，字母数字段输出整词及字母/数字边界拆分后的子词。
+func SearchIndexTokens(s string) []string {
+	var out []string
+	seen := make(map[string]bool)
+	add := func(tok string) {
+		if tok != "" && !seen[tok] {
+			seen[tok] = true
+			out = append(out, tok)
+		}
+	}
+	for _, run := range splitSearchRuns(s) {
+		if !run.cjk {
+			add(run.text)
+			for _, part := range splitAlnum(run.text) {
+				add(part)
+			}
+			continue
+		}
+		runes := []rune(run.text)
+		for i := range runes {
+			add(string(runes[i]))
+			if i+1 < len(runes) {
+				add(string(runes[i : i+2]))
+			}
+		}
+	}
+	return out
+}
+
+// SearchQueryTokens 生成查询词元：CJK 段用二元组（单字时用单字）精确匹配，字母数字段按前缀匹配
+func SearchQueryTokens(s string) []SearchToken {
+	var out []SearchToken
+	for _, run := range splitSearchRuns(s) {
+		if !run.cjk {
+			out = append(out, SearchToken{Text: run.text, Prefix: true})
+			continue
+		}
+		runes := []rune(run.text)
+		if len(runes) == 1 {
+			out = append(out, SearchToken{Text: run.text})
+			continue
+		}
+		for i := 0; i+1 < len(runes); i++ {
+			out = append(out, SearchToken{Text: string(runes[i : i+2])})
+		}
+	}
+	return out
+}
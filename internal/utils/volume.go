@@ -0,0 +1,60 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// VolumeInfo 已挂载卷及其稳定标识（文件系统 UUID / 卷标 / 网络共享源）
+type VolumeInfo struct {
+	ID         string `json:"id"` // 空表示无法稳定识别（如 tmpfs、无 UUID 的设备）
+	UUID       string `json:"uuid,omitempty"`
+	Label      string `json:"label,omitempty"`
+	MountPoint string `json:"mount_point"`
+	Root       string `json:"root"` // 挂载的是文件系统内哪个目录（bind mount 时非 "/"）
+	Source     string `json:"source"`
+	FSType     string `json:"fs_type"`
+	Removable  bool   `json:"removable"`
+}
+
+// FSPath path 在卷文件系统内的路径（以 "/" 分隔），用于换挂载点后重新定位
+func (v *VolumeInfo) FSPath(path string) (string, bool) {
+	rel, err := filepath.Rel(v.MountPoint, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	root := v.Root
+	if root == "" {
+		root = "/"
+	}
+	return filepath.ToSlash(filepath.Join(filepath.FromSlash(root), rel)), true
+}
+
+// LocalPath FSPath 的逆运算：卷内路径在当前挂载点下的位置
+func (v *VolumeInfo) LocalPath(fsPath string) (string, bool) {
+	root := v.Root
+	if root == "" {
+		root = "/"
+	}
+	rel, err := filepath.Rel(filepath.FromSlash(root), filepath.FromSlash(fsPath))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.Join(v.MountPoint, rel), true
+}
+
+// VolumeForPath 返回包含 path 的最深挂载点
+func VolumeForPath(path string, vols []VolumeInfo) *VolumeInfo {
+	path = filepath.Clean(path)
+	var best *VolumeInfo
+	for i := range vols {
+		mp := filepath.Clean(vols[i].MountPoint)
+		if path != mp && !strings.HasPrefix(path, strings.TrimSuffix(mp, string(filepath.Separator))+string(filepath.Separator)) {
+			continue
+		}
+		if best == nil || len(mp) > len(filepath.Clean(best.MountPoint)) {
+			best = &vols[i]
+		}
+	}
+	return best
+}
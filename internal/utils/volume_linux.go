@@ -0,0 +1,153 @@
+//go:build linux
+
+package utils
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// networkFSTypes 没有 UUID 的网络文件系统，以共享源作为卷标识
+var networkFSTypes = map[string]bool{
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true,
+	"fuse.sshfs": true, "9p": true,
+}
+
+// ListVolumes 解析 /proc/self/mountinfo，结合 /dev/disk/by-uuid、by-label 得到卷标识
+func ListVolumes() ([]VolumeInfo, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	uuids := diskLinks("/dev/disk/by-uuid")
+	labels := diskLinks("/dev/disk/by-label")
+	partUUIDs := diskLinks("/dev/disk/by-partuuid")
+
+	var out []VolumeInfo
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		// 36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw
+		fields := strings.Fields(sc.Text())
+		sep := -1
+		for i, f := range fields {
+			if f == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 5 || sep+2 >= len(fields) {
+			continue
+		}
+		v := VolumeInfo{
+			MountPoint: unescapeMountField(fields[4]),
+			Root:       unescapeMountField(fields[3]),
+			FSType:     fields[sep+1],
+			Source:     unescapeMountField(fields[sep+2]),
+		}
+		devNum := fields[2]
+		switch {
+		case strings.HasPrefix(v.Source, "/dev/"):
+			dev := v.Source
+			if real, err := filepath.EvalSymlinks(dev); err == nil {
+				dev = real
+			}
+			v.UUID = uuids[dev]
+			v.Label = labels[dev]
+			v.Removable = blockRemovable(devNum)
+			switch {
+			case v.UUID != "":
+				v.ID = "uuid:" + v.UUID
+			case v.Label != "":
+				v.ID = "label:" + v.Label
+			case partUUIDs[dev] != "":
+				v.ID = "partuuid:" + partUUIDs[dev]
+			}
+		case networkFSTypes[v.FSType]:
+			v.ID = "net:" + v.Source
+			v.Removable = true
+		default:
+			// proc、sysfs、tmpfs、overlay 等虚拟文件系统
+			continue
+		}
+		out = append(out, v)
+	}
+	return out, sc.Err()
+}
+
+// diskLinks 读取 /dev/disk/by-* 目录：设备路径 → 链接名
+func diskLinks(dir string) map[string]string {
+	out := map[string]string{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return out
+	}
+	for _, e := range entries {
+		real, err := filepath.EvalSymlinks(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		out[real] = unescapeUdevName(e.Name())
+	}
+	return out
+}
+
+// blockRemovable 通过 sysfs 判断是否可移动设备；分区的 removable 在上级磁盘上
+func blockRemovable(devNum string) bool {
+	sys, err := filepath.EvalSymlinks("/sys/dev/block/" + devNum)
+	if err != nil {
+		return false
+	}
+	if strings.Contains(sys, "/usb") {
+		return true
+	}
+	for _, p := range []string{sys, filepath.Dir(sys)} {
+		if b, err := os.ReadFile(filepath.Join(p, "removable")); err == nil && strings.TrimSpace(string(b)) == "1" {
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeMountField mountinfo 中空格等字符以 \040 形式的八进制转义
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescapeUdevName udev 链接名中的 \x20 形式转义
+func unescapeUdevName(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if n, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package utils
+
+// ListVolumes 目前仅 Linux 通过 mountinfo 识别卷；其他平台返回空，来源不绑定卷
+func ListVolumes() ([]VolumeInfo, error) {
+	return nil, nil
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestVolumeInfo_FSPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		vol    VolumeInfo
+		path   string
+		want   string
+		wantOK bool
+	}{
+		{"mount root", VolumeInfo{MountPoint: "/media/a"}, "/media/a", "/", true},
+		{"nested", VolumeInfo{MountPoint: "/media/a"}, "/media/a/shoot/day1", "/shoot/day1", true},
+		{"bind mount root", VolumeInfo{MountPoint: "/srv/lib", Root: "/footage"}, "/srv/lib/day1", "/footage/day1", true},
+		{"sibling prefix", VolumeInfo{MountPoint: "/media/a"}, "/media/ab/x", "", false},
+		{"outside", VolumeInfo{MountPoint: "/media/a"}, "/home/x", "", false},
+		{"dotdot name is inside", VolumeInfo{MountPoint: "/media/a"}, "/media/a/..x", "/..x", true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.vol.FSPath(filepath.FromSlash(tc.path))
+			if got != tc.want || ok != tc.wantOK {
+				t.Fatalf("FSPath(%s) = %q, %v; want %q, %v", tc.path, got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestVolumeInfo_LocalPath(t *testing.T) {
+	tests := []struct {
+		name   string
+		vol    VolumeInfo
+		fsPath string
+		want   string
+		wantOK bool
+	}{
+		{"remounted elsewhere", VolumeInfo{MountPoint: "/run/media/b"}, "/shoot/day1", "/run/media/b/shoot/day1", true},
+		{"volume root", VolumeInfo{MountPoint: "/run/media/b"}, "/", "/run/media/b", true},
+		{"bind mount covers path", VolumeInfo{MountPoint: "/srv/lib", Root: "/footage"}, "/footage/day1", "/srv/lib/day1", true},
+		{"bind mount misses path", VolumeInfo{MountPoint: "/srv/lib", Root: "/footage"}, "/music/a", "", false},
+		{"bind mount sibling prefix", VolumeInfo{MountPoint: "/srv/lib", Root: "/footage"}, "/footage2/a", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := tc.vol.LocalPath(tc.fsPath)
+			if got != filepath.FromSlash(tc.want) || ok != tc.wantOK {
+				t.Fatalf("LocalPath(%s) = %q, %v; want %q, %v", tc.fsPath, got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestVolumeInfo_RoundTrip(t *testing.T) {
+	old := VolumeInfo{MountPoint: filepath.FromSlash("/media/old"), Root: "/"}
+	moved := VolumeInfo{MountPoint: filepath.FromSlash("/media/new"), Root: "/"}
+	fs, ok := old.FSPath(filepath.FromSlash("/media/old/a b/c"))
+	if !ok {
+		t.Fatal("FSPath failed")
+	}
+	if got, ok := moved.LocalPath(fs); !ok || got != filepath.FromSlash("/media/new/a b/c") {
+		t.Fatalf("LocalPath(%s) = %q, %v", fs, got, ok)
+	}
+}